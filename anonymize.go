@@ -0,0 +1,220 @@
+package mmdbwriter
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"strings"
+
+	"github.com/maxmind/mmdbwriter/inserter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+// AnonymizeOptions configures Tree.Anonymize.
+type AnonymizeOptions struct {
+	// IPv4PrefixLength, if greater than zero, is the prefix length that
+	// more specific IPv4 networks are generalized to, e.g., 24. In an IPv6
+	// tree, it applies to the networks within the IPv4 subtree, as with
+	// Options.MaxIPv4PrefixLength.
+	IPv4PrefixLength int
+
+	// IPv6PrefixLength, if greater than zero, is the prefix length that more
+	// specific IPv6 networks are generalized to, e.g., 48.
+	IPv6PrefixLength int
+
+	// StripFields is a list of dot-separated paths to the fields to remove
+	// from the records, e.g., "postal.code" or "location.latitude". Maps
+	// that are left empty are kept.
+	StripFields []string
+}
+
+// Anonymize returns a new tree with the same options as t in which no
+// network is more specific than the configured prefix lengths and the
+// configured fields are removed from the records. This is intended to
+// produce a less detailed derivative of a database, e.g., for privacy
+// compliance. The original tree is not modified.
+//
+// When the networks within a generalized network have different records,
+// the generalized network gets the record that covers the most addresses
+// after the fields are stripped, with ties going to the record of the
+// lowest addresses. The addresses within the generalized network without
+// data also get this record.
+func (t *Tree) Anonymize(opts AnonymizeOptions) (*Tree, error) {
+	if opts.IPv4PrefixLength < 0 || opts.IPv4PrefixLength > 32 {
+		return nil, fmt.Errorf("IPv4PrefixLength must be between 0 and 32: %d", opts.IPv4PrefixLength)
+	}
+	if opts.IPv6PrefixLength < 0 || opts.IPv6PrefixLength > 128 {
+		return nil, fmt.Errorf("IPv6PrefixLength must be between 0 and 128: %d", opts.IPv6PrefixLength)
+	}
+
+	paths := make([][]mmdbtype.String, 0, len(opts.StripFields))
+	for _, field := range opts.StripFields {
+		var path []mmdbtype.String
+		for _, key := range strings.Split(field, ".") {
+			if key == "" {
+				return nil, fmt.Errorf("invalid field to strip: %q", field)
+			}
+			path = append(path, mmdbtype.String(key))
+		}
+		paths = append(paths, path)
+	}
+
+	newTree, err := New(t.options())
+	if err != nil {
+		return nil, err
+	}
+
+	a := &anonymizer{
+		tree:     t,
+		newTree:  newTree,
+		opts:     opts,
+		paths:    paths,
+		stripped: map[valueRef]mmdbtype.DataType{},
+	}
+	err = t.root.walk(make(net.IP, t.treeDepth/8), 0, a.add)
+	if err == nil {
+		err = a.flush()
+	}
+	if err != nil {
+		newTree.Close() //nolint:errcheck // the walk error is more relevant
+		return nil, err
+	}
+	return newTree, nil
+}
+
+// anonymizer accumulates the records within each generalized network. As
+// the records are walked in address order, the records within a generalized
+// network are contiguous.
+type anonymizer struct {
+	tree    *Tree
+	newTree *Tree
+	opts    AnonymizeOptions
+	paths   [][]mmdbtype.String
+
+	// stripped caches the stripped value of each distinct value.
+	stripped map[valueRef]mmdbtype.DataType
+
+	// group is the generalized network currently being accumulated, or
+	// nil, and candidates are the distinct values within it.
+	group      *net.IPNet
+	candidates []anonymizeCandidate
+}
+
+type anonymizeCandidate struct {
+	value mmdbtype.DataType
+	// weight is the fraction of the generalized network covered by the
+	// value.
+	weight float64
+}
+
+func (a *anonymizer) add(ip net.IP, prefixLen int, r record) error {
+	if r.recordType != recordTypeData {
+		return nil
+	}
+
+	value, ok := a.stripped[r.value]
+	if !ok {
+		value = a.tree.dataMap.load(r.value)
+		for _, path := range a.paths {
+			value = stripPath(value, path)
+		}
+		a.stripped[r.value] = value
+	}
+
+	maxPrefixLen := a.maxPrefixLen(ip, prefixLen)
+	if maxPrefixLen == 0 || prefixLen <= maxPrefixLen {
+		if err := a.flush(); err != nil {
+			return err
+		}
+		return a.insert(ip, prefixLen, value)
+	}
+
+	if a.group != nil && !a.group.Contains(ip) {
+		if err := a.flush(); err != nil {
+			return err
+		}
+	}
+	if a.group == nil {
+		mask := net.CIDRMask(maxPrefixLen, a.tree.treeDepth)
+		a.group = &net.IPNet{IP: ip.Mask(mask), Mask: mask}
+	}
+
+	weight := math.Ldexp(1, maxPrefixLen-prefixLen)
+	for i := range a.candidates {
+		if a.candidates[i].value.Equal(value) {
+			a.candidates[i].weight += weight
+			return nil
+		}
+	}
+	a.candidates = append(a.candidates, anonymizeCandidate{value: value, weight: weight})
+	return nil
+}
+
+// maxPrefixLen returns the prefix length, in tree form, that the network
+// is generalized to, or 0 if it is not generalized.
+func (a *anonymizer) maxPrefixLen(ip net.IP, prefixLen int) int {
+	if a.tree.treeDepth == 32 {
+		return a.opts.IPv4PrefixLength
+	}
+	if a.tree.inIPv4Subtree(ip, prefixLen) {
+		if a.opts.IPv4PrefixLength == 0 {
+			return 0
+		}
+		return a.opts.IPv4PrefixLength + 96
+	}
+	return a.opts.IPv6PrefixLength
+}
+
+// flush inserts the value with the largest weight for the current
+// generalized network.
+func (a *anonymizer) flush() error {
+	if a.group == nil {
+		return nil
+	}
+	best := a.candidates[0]
+	for _, c := range a.candidates[1:] {
+		if c.weight > best.weight {
+			best = c
+		}
+	}
+	prefixLen, _ := a.group.Mask.Size()
+	err := a.insert(a.group.IP, prefixLen, best.value)
+
+	a.group = nil
+	a.candidates = a.candidates[:0]
+	return err
+}
+
+func (a *anonymizer) insert(ip net.IP, prefixLen int, value mmdbtype.DataType) error {
+	network := &net.IPNet{
+		IP:   make(net.IP, len(ip)),
+		Mask: net.CIDRMask(prefixLen, a.tree.treeDepth),
+	}
+	copy(network.IP, ip)
+	return a.newTree.insert(network, recordTypeData, inserter.ReplaceWith(value), nil)
+}
+
+// stripPath returns a copy of the value with the field at the path removed.
+// The value passed in is not modified.
+func stripPath(v mmdbtype.DataType, path []mmdbtype.String) mmdbtype.DataType {
+	m, ok := v.(mmdbtype.Map)
+	if !ok {
+		return v
+	}
+	child, ok := m[path[0]]
+	if !ok {
+		return v
+	}
+
+	// We make a shallow copy as the value may be shared with other records.
+	newMap := make(mmdbtype.Map, len(m))
+	for k, e := range m {
+		newMap[k] = e
+	}
+	if len(path) == 1 {
+		delete(newMap, path[0])
+	} else {
+		newMap[path[0]] = stripPath(child, path[1:])
+	}
+	return newMap
+}
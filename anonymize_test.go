@@ -0,0 +1,92 @@
+package mmdbwriter
+
+import (
+	"net"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnonymize(t *testing.T) {
+	tree, err := New(Options{})
+	require.NoError(t, err)
+
+	city := func(name string, postal string) mmdbtype.Map {
+		return mmdbtype.Map{
+			"city":     mmdbtype.Map{"name": mmdbtype.String(name)},
+			"postal":   mmdbtype.Map{"code": mmdbtype.String(postal)},
+			"location": mmdbtype.Map{"latitude": mmdbtype.Float64(1.5)},
+		}
+	}
+
+	inserts := []struct {
+		network string
+		value   mmdbtype.DataType
+	}{
+		// Within 1.1.1.0/24, "b" covers the most addresses once the postal
+		// codes are stripped.
+		{network: "1.1.1.0/26", value: city("a", "1")},
+		{network: "1.1.1.64/26", value: city("b", "2")},
+		{network: "1.1.1.128/26", value: city("b", "3")},
+		// A tie goes to the lowest addresses.
+		{network: "2.2.2.0/25", value: city("c", "4")},
+		{network: "2.2.2.128/25", value: city("d", "5")},
+		// Networks that are not more specific are copied.
+		{network: "3.3.0.0/16", value: city("e", "6")},
+		{network: "2003::1/128", value: mmdbtype.String("f")},
+		{network: "2003:0:0:1::/64", value: mmdbtype.String("g")},
+	}
+	for _, insert := range inserts {
+		require.NoError(t, tree.Insert(mustNetwork(t, insert.network), insert.value))
+	}
+
+	anonymized, err := tree.Anonymize(AnonymizeOptions{
+		IPv4PrefixLength: 24,
+		IPv6PrefixLength: 48,
+		StripFields:      []string{"postal.code", "location"},
+	})
+	require.NoError(t, err)
+	require.NoError(t, anonymized.Check())
+
+	expected := func(name string) mmdbtype.Map {
+		return mmdbtype.Map{
+			"city":   mmdbtype.Map{"name": mmdbtype.String(name)},
+			"postal": mmdbtype.Map{},
+		}
+	}
+
+	actual := map[string]mmdbtype.DataType{}
+	err = anonymized.Walk(func(network *net.IPNet, value mmdbtype.DataType) (bool, error) {
+		if value != nil {
+			actual[network.String()] = value
+		}
+		return true, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]mmdbtype.DataType{
+		"1.1.1.0/24": expected("b"),
+		"2.2.2.0/24": expected("c"),
+		"3.3.0.0/16": expected("e"),
+		"2003::/48":  mmdbtype.String("g"),
+	}, actual)
+
+	// The original tree is not modified.
+	_, value := tree.Get(net.ParseIP("1.1.1.1"))
+	assert.Equal(t, city("a", "1"), value)
+}
+
+func TestAnonymizeErrors(t *testing.T) {
+	tree, err := New(Options{})
+	require.NoError(t, err)
+
+	_, err = tree.Anonymize(AnonymizeOptions{IPv4PrefixLength: 33})
+	assert.EqualError(t, err, "IPv4PrefixLength must be between 0 and 32: 33")
+
+	_, err = tree.Anonymize(AnonymizeOptions{IPv6PrefixLength: -1})
+	assert.EqualError(t, err, "IPv6PrefixLength must be between 0 and 128: -1")
+
+	_, err = tree.Anonymize(AnonymizeOptions{StripFields: []string{"postal..code"}})
+	assert.EqualError(t, err, `invalid field to strip: "postal..code"`)
+}
@@ -0,0 +1,46 @@
+// Package asn provides a builder for databases with the same record layout
+// as the MaxMind GeoLite2 ASN database.
+package asn
+
+import (
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/builders/layout"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+// DefaultDatabaseType is the database type used if Options.DatabaseType is
+// not set. See layout.New.
+const DefaultDatabaseType = "GeoLite2-ASN"
+
+// Record is an ASN record.
+type Record struct {
+	// Number is the autonomous system number. A value of 0 means that the
+	// number is not included in the record.
+	Number uint32
+
+	// Organization is the organization associated with the autonomous
+	// system. An empty value means that the organization is not included
+	// in the record.
+	Organization string
+}
+
+// DataType returns the record in the GeoLite2 ASN layout.
+func (r Record) DataType() mmdbtype.Map {
+	m := mmdbtype.Map{}
+	if r.Number != 0 {
+		m["autonomous_system_number"] = mmdbtype.Uint32(r.Number)
+	}
+	if r.Organization != "" {
+		m["autonomous_system_organization"] = mmdbtype.String(r.Organization)
+	}
+	return m
+}
+
+// Builder builds an ASN database.
+type Builder = layout.Builder[Record]
+
+// New creates a new Builder. The options are passed to mmdbwriter.New. If
+// DatabaseType is not set, DefaultDatabaseType is used.
+func New(opts mmdbwriter.Options) (*Builder, error) {
+	return layout.New[Record](opts, DefaultDatabaseType)
+}
@@ -0,0 +1,68 @@
+package asn
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/oschwald/maxminddb-golang"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// This matches the ASN struct in github.com/oschwald/geoip2-golang.
+type geoip2ASN struct {
+	AutonomousSystemOrganization string `maxminddb:"autonomous_system_organization"`
+	AutonomousSystemNumber       uint   `maxminddb:"autonomous_system_number"`
+}
+
+func TestRecordDataType(t *testing.T) {
+	assert.Equal(
+		t,
+		mmdbtype.Map{
+			"autonomous_system_number":       mmdbtype.Uint32(15169),
+			"autonomous_system_organization": mmdbtype.String("GOOGLE"),
+		},
+		Record{Number: 15169, Organization: "GOOGLE"}.DataType(),
+	)
+	assert.Equal(t, mmdbtype.Map{}, Record{}.DataType())
+}
+
+func TestBuilder(t *testing.T) {
+	b, err := New(
+		mmdbwriter.Options{
+			Description: map[string]string{"en": "Test ASN database"},
+			RecordSize:  24,
+		},
+	)
+	require.NoError(t, err)
+
+	_, network, err := net.ParseCIDR("1.0.0.0/24")
+	require.NoError(t, err)
+	require.NoError(t, b.Insert(network, Record{Number: 13335, Organization: "CLOUDFLARENET"}))
+
+	require.NoError(t, b.InsertRange(
+		net.ParseIP("2001:4860::"),
+		net.ParseIP("2001:4860:ffff:ffff:ffff:ffff:ffff:ffff"),
+		Record{Number: 15169, Organization: "GOOGLE"},
+	))
+
+	buf := &bytes.Buffer{}
+	_, err = b.WriteTo(buf)
+	require.NoError(t, err)
+
+	reader, err := maxminddb.FromBytes(buf.Bytes())
+	require.NoError(t, err)
+	require.NoError(t, reader.Verify())
+	assert.Equal(t, DefaultDatabaseType, reader.Metadata.DatabaseType)
+
+	var record geoip2ASN
+	require.NoError(t, reader.Lookup(net.ParseIP("1.0.0.1"), &record))
+	assert.Equal(t, geoip2ASN{AutonomousSystemNumber: 13335, AutonomousSystemOrganization: "CLOUDFLARENET"}, record)
+
+	record = geoip2ASN{}
+	require.NoError(t, reader.Lookup(net.ParseIP("2001:4860:4860::8888"), &record))
+	assert.Equal(t, geoip2ASN{AutonomousSystemNumber: 15169, AutonomousSystemOrganization: "GOOGLE"}, record)
+}
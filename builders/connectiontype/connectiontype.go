@@ -0,0 +1,48 @@
+// Package connectiontype provides a builder for databases with the same
+// record layout as the MaxMind GeoIP2 Connection-Type database.
+package connectiontype
+
+import (
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/builders/layout"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+// DefaultDatabaseType is the database type used if Options.DatabaseType is
+// not set. See layout.New.
+const DefaultDatabaseType = "GeoIP2-Connection-Type"
+
+// The connection types used in the GeoIP2 Connection-Type database.
+const (
+	Dialup    = "Dialup"
+	CableDSL  = "Cable/DSL"
+	Corporate = "Corporate"
+	Cellular  = "Cellular"
+	Satellite = "Satellite"
+)
+
+// Record is a Connection-Type record.
+type Record struct {
+	// ConnectionType is the connection type of the network, e.g.,
+	// CableDSL. An empty value means that the connection type is not
+	// included in the record.
+	ConnectionType string
+}
+
+// DataType returns the record in the GeoIP2 Connection-Type layout.
+func (r Record) DataType() mmdbtype.Map {
+	m := mmdbtype.Map{}
+	if r.ConnectionType != "" {
+		m["connection_type"] = mmdbtype.String(r.ConnectionType)
+	}
+	return m
+}
+
+// Builder builds a Connection-Type database.
+type Builder = layout.Builder[Record]
+
+// New creates a new Builder. The options are passed to mmdbwriter.New. If
+// DatabaseType is not set, DefaultDatabaseType is used.
+func New(opts mmdbwriter.Options) (*Builder, error) {
+	return layout.New[Record](opts, DefaultDatabaseType)
+}
@@ -0,0 +1,65 @@
+package connectiontype
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/oschwald/maxminddb-golang"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// This matches the ConnectionType struct in
+// github.com/oschwald/geoip2-golang.
+type geoip2ConnectionType struct {
+	ConnectionType string `maxminddb:"connection_type"`
+}
+
+func TestRecordDataType(t *testing.T) {
+	assert.Equal(
+		t,
+		mmdbtype.Map{"connection_type": mmdbtype.String("Cable/DSL")},
+		Record{ConnectionType: CableDSL}.DataType(),
+	)
+	assert.Equal(t, mmdbtype.Map{}, Record{}.DataType())
+}
+
+func TestBuilder(t *testing.T) {
+	b, err := New(
+		mmdbwriter.Options{
+			Description: map[string]string{"en": "Test Connection-Type database"},
+			RecordSize:  24,
+		},
+	)
+	require.NoError(t, err)
+
+	_, network, err := net.ParseCIDR("1.0.0.0/24")
+	require.NoError(t, err)
+	require.NoError(t, b.Insert(network, Record{ConnectionType: Corporate}))
+
+	require.NoError(t, b.InsertRange(
+		net.ParseIP("2001:4860::"),
+		net.ParseIP("2001:4860:ffff:ffff:ffff:ffff:ffff:ffff"),
+		Record{ConnectionType: Cellular},
+	))
+
+	buf := &bytes.Buffer{}
+	_, err = b.WriteTo(buf)
+	require.NoError(t, err)
+
+	reader, err := maxminddb.FromBytes(buf.Bytes())
+	require.NoError(t, err)
+	require.NoError(t, reader.Verify())
+	assert.Equal(t, DefaultDatabaseType, reader.Metadata.DatabaseType)
+
+	var record geoip2ConnectionType
+	require.NoError(t, reader.Lookup(net.ParseIP("1.0.0.1"), &record))
+	assert.Equal(t, geoip2ConnectionType{ConnectionType: "Corporate"}, record)
+
+	record = geoip2ConnectionType{}
+	require.NoError(t, reader.Lookup(net.ParseIP("2001:4860:4860::8888"), &record))
+	assert.Equal(t, geoip2ConnectionType{ConnectionType: "Cellular"}, record)
+}
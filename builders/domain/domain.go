@@ -0,0 +1,39 @@
+// Package domain provides a builder for databases with the same record
+// layout as the MaxMind GeoIP2 Domain database.
+package domain
+
+import (
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/builders/layout"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+// DefaultDatabaseType is the database type used if Options.DatabaseType is
+// not set. See layout.New.
+const DefaultDatabaseType = "GeoIP2-Domain"
+
+// Record is a Domain record.
+type Record struct {
+	// Domain is the second level domain associated with the network, e.g.,
+	// "example.com". An empty value means that the domain is not included
+	// in the record.
+	Domain string
+}
+
+// DataType returns the record in the GeoIP2 Domain layout.
+func (r Record) DataType() mmdbtype.Map {
+	m := mmdbtype.Map{}
+	if r.Domain != "" {
+		m["domain"] = mmdbtype.String(r.Domain)
+	}
+	return m
+}
+
+// Builder builds a Domain database.
+type Builder = layout.Builder[Record]
+
+// New creates a new Builder. The options are passed to mmdbwriter.New. If
+// DatabaseType is not set, DefaultDatabaseType is used.
+func New(opts mmdbwriter.Options) (*Builder, error) {
+	return layout.New[Record](opts, DefaultDatabaseType)
+}
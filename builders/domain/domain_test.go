@@ -0,0 +1,64 @@
+package domain
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/oschwald/maxminddb-golang"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// This matches the Domain struct in github.com/oschwald/geoip2-golang.
+type geoip2Domain struct {
+	Domain string `maxminddb:"domain"`
+}
+
+func TestRecordDataType(t *testing.T) {
+	assert.Equal(
+		t,
+		mmdbtype.Map{"domain": mmdbtype.String("example.com")},
+		Record{Domain: "example.com"}.DataType(),
+	)
+	assert.Equal(t, mmdbtype.Map{}, Record{}.DataType())
+}
+
+func TestBuilder(t *testing.T) {
+	b, err := New(
+		mmdbwriter.Options{
+			Description: map[string]string{"en": "Test Domain database"},
+			RecordSize:  24,
+		},
+	)
+	require.NoError(t, err)
+
+	_, network, err := net.ParseCIDR("1.0.0.0/24")
+	require.NoError(t, err)
+	require.NoError(t, b.Insert(network, Record{Domain: "cloudflare.com"}))
+
+	require.NoError(t, b.InsertRange(
+		net.ParseIP("2001:4860::"),
+		net.ParseIP("2001:4860:ffff:ffff:ffff:ffff:ffff:ffff"),
+		Record{Domain: "google.com"},
+	))
+
+	buf := &bytes.Buffer{}
+	_, err = b.WriteTo(buf)
+	require.NoError(t, err)
+
+	reader, err := maxminddb.FromBytes(buf.Bytes())
+	require.NoError(t, err)
+	require.NoError(t, reader.Verify())
+	assert.Equal(t, DefaultDatabaseType, reader.Metadata.DatabaseType)
+
+	var record geoip2Domain
+	require.NoError(t, reader.Lookup(net.ParseIP("1.0.0.1"), &record))
+	assert.Equal(t, geoip2Domain{Domain: "cloudflare.com"}, record)
+
+	record = geoip2Domain{}
+	require.NoError(t, reader.Lookup(net.ParseIP("2001:4860:4860::8888"), &record))
+	assert.Equal(t, geoip2Domain{Domain: "google.com"}, record)
+}
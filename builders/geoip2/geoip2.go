@@ -0,0 +1,245 @@
+// Package geoip2 provides types for building databases with the same record
+// layout as the MaxMind GeoIP2 and GeoLite2 Country and City databases.
+// Databases built with these types may be read by existing GeoIP2 readers,
+// such as github.com/oschwald/geoip2-golang, without any changes.
+//
+// Fields with zero values are omitted from the records, matching the
+// MaxMind databases.
+package geoip2
+
+import (
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+// Readers such as github.com/oschwald/geoip2-golang use the database type to
+// determine which lookups are allowed. These database types are recognized
+// by those readers.
+const (
+	CityDatabaseType    = "GeoLite2-City"
+	CountryDatabaseType = "GeoLite2-Country"
+)
+
+// Names maps a locale code to the name in that locale. The locale codes
+// should be included in mmdbwriter.Options.Languages.
+type Names map[string]string
+
+// City contains data for the city record.
+type City struct {
+	Names     Names
+	GeoNameID uint32
+}
+
+// Continent contains data for the continent record.
+type Continent struct {
+	Names     Names
+	Code      string
+	GeoNameID uint32
+}
+
+// Country contains data for the country and registered country records.
+type Country struct {
+	Names             Names
+	ISOCode           string
+	GeoNameID         uint32
+	IsInEuropeanUnion bool
+}
+
+// RepresentedCountry contains data for the represented country record.
+type RepresentedCountry struct {
+	Country
+	// Type is the type of entity that is representing the country, e.g.,
+	// "military".
+	Type string
+}
+
+// Location contains data for the location record. The coordinates are
+// omitted if both are zero.
+type Location struct {
+	TimeZone       string
+	Latitude       float64
+	Longitude      float64
+	MetroCode      uint16
+	AccuracyRadius uint16
+}
+
+// Postal contains data for the postal record.
+type Postal struct {
+	Code string
+}
+
+// Subdivision contains data for a subdivision record.
+type Subdivision struct {
+	Names     Names
+	ISOCode   string
+	GeoNameID uint32
+}
+
+// Traits contains data for the traits record.
+type Traits struct {
+	IsAnonymousProxy    bool
+	IsAnycast           bool
+	IsSatelliteProvider bool
+}
+
+// CountryRecord is a record in the GeoIP2 Country layout.
+type CountryRecord struct {
+	Continent          Continent
+	Country            Country
+	RegisteredCountry  Country
+	RepresentedCountry RepresentedCountry
+	Traits             Traits
+}
+
+// DataType returns the record in the GeoIP2 Country layout.
+func (r CountryRecord) DataType() mmdbtype.Map {
+	m := mmdbtype.Map{}
+	addMap(m, "continent", r.Continent.dataType())
+	addMap(m, "country", r.Country.dataType())
+	addMap(m, "registered_country", r.RegisteredCountry.dataType())
+	addMap(m, "represented_country", r.RepresentedCountry.dataType())
+	addMap(m, "traits", r.Traits.dataType())
+	return m
+}
+
+// CityRecord is a record in the GeoIP2 City layout.
+type CityRecord struct {
+	City               City
+	Continent          Continent
+	Country            Country
+	Location           Location
+	Postal             Postal
+	RegisteredCountry  Country
+	RepresentedCountry RepresentedCountry
+	// Subdivisions are ordered from largest to smallest.
+	Subdivisions []Subdivision
+	Traits       Traits
+}
+
+// DataType returns the record in the GeoIP2 City layout.
+func (r CityRecord) DataType() mmdbtype.Map {
+	m := CountryRecord{
+		Continent:          r.Continent,
+		Country:            r.Country,
+		RegisteredCountry:  r.RegisteredCountry,
+		RepresentedCountry: r.RepresentedCountry,
+		Traits:             r.Traits,
+	}.DataType()
+
+	addMap(m, "city", r.City.dataType())
+	addMap(m, "location", r.Location.dataType())
+	addMap(m, "postal", r.Postal.dataType())
+
+	if len(r.Subdivisions) > 0 {
+		subdivisions := make(mmdbtype.Slice, 0, len(r.Subdivisions))
+		for _, s := range r.Subdivisions {
+			subdivisions = append(subdivisions, s.dataType())
+		}
+		m["subdivisions"] = subdivisions
+	}
+	return m
+}
+
+func (c City) dataType() mmdbtype.Map {
+	m := mmdbtype.Map{}
+	addGeoNameID(m, c.GeoNameID)
+	addNames(m, c.Names)
+	return m
+}
+
+func (c Continent) dataType() mmdbtype.Map {
+	m := mmdbtype.Map{}
+	addString(m, "code", c.Code)
+	addGeoNameID(m, c.GeoNameID)
+	addNames(m, c.Names)
+	return m
+}
+
+func (c Country) dataType() mmdbtype.Map {
+	m := mmdbtype.Map{}
+	addGeoNameID(m, c.GeoNameID)
+	if c.IsInEuropeanUnion {
+		m["is_in_european_union"] = mmdbtype.Bool(true)
+	}
+	addString(m, "iso_code", c.ISOCode)
+	addNames(m, c.Names)
+	return m
+}
+
+func (c RepresentedCountry) dataType() mmdbtype.Map {
+	m := c.Country.dataType()
+	addString(m, "type", c.Type)
+	return m
+}
+
+func (l Location) dataType() mmdbtype.Map {
+	m := mmdbtype.Map{}
+	if l.AccuracyRadius != 0 {
+		m["accuracy_radius"] = mmdbtype.Uint16(l.AccuracyRadius)
+	}
+	if l.Latitude != 0 || l.Longitude != 0 {
+		m["latitude"] = mmdbtype.Float64(l.Latitude)
+		m["longitude"] = mmdbtype.Float64(l.Longitude)
+	}
+	if l.MetroCode != 0 {
+		m["metro_code"] = mmdbtype.Uint16(l.MetroCode)
+	}
+	addString(m, "time_zone", l.TimeZone)
+	return m
+}
+
+func (p Postal) dataType() mmdbtype.Map {
+	m := mmdbtype.Map{}
+	addString(m, "code", p.Code)
+	return m
+}
+
+func (s Subdivision) dataType() mmdbtype.Map {
+	m := mmdbtype.Map{}
+	addGeoNameID(m, s.GeoNameID)
+	addString(m, "iso_code", s.ISOCode)
+	addNames(m, s.Names)
+	return m
+}
+
+func (t Traits) dataType() mmdbtype.Map {
+	m := mmdbtype.Map{}
+	if t.IsAnonymousProxy {
+		m["is_anonymous_proxy"] = mmdbtype.Bool(true)
+	}
+	if t.IsAnycast {
+		m["is_anycast"] = mmdbtype.Bool(true)
+	}
+	if t.IsSatelliteProvider {
+		m["is_satellite_provider"] = mmdbtype.Bool(true)
+	}
+	return m
+}
+
+func addMap(m mmdbtype.Map, key mmdbtype.String, v mmdbtype.Map) {
+	if len(v) > 0 {
+		m[key] = v
+	}
+}
+
+func addString(m mmdbtype.Map, key mmdbtype.String, v string) {
+	if v != "" {
+		m[key] = mmdbtype.String(v)
+	}
+}
+
+func addGeoNameID(m mmdbtype.Map, id uint32) {
+	if id != 0 {
+		m["geoname_id"] = mmdbtype.Uint32(id)
+	}
+}
+
+func addNames(m mmdbtype.Map, names Names) {
+	if len(names) == 0 {
+		return
+	}
+	nm := make(mmdbtype.Map, len(names))
+	for k, v := range names {
+		nm[mmdbtype.String(k)] = mmdbtype.String(v)
+	}
+	m["names"] = nm
+}
@@ -0,0 +1,189 @@
+package geoip2
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/oschwald/maxminddb-golang"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// This matches the City struct in github.com/oschwald/geoip2-golang.
+type geoip2City struct {
+	City struct {
+		Names     map[string]string `maxminddb:"names"`
+		GeoNameID uint              `maxminddb:"geoname_id"`
+	} `maxminddb:"city"`
+	Postal struct {
+		Code string `maxminddb:"code"`
+	} `maxminddb:"postal"`
+	Continent struct {
+		Names     map[string]string `maxminddb:"names"`
+		Code      string            `maxminddb:"code"`
+		GeoNameID uint              `maxminddb:"geoname_id"`
+	} `maxminddb:"continent"`
+	Subdivisions []struct {
+		Names     map[string]string `maxminddb:"names"`
+		IsoCode   string            `maxminddb:"iso_code"`
+		GeoNameID uint              `maxminddb:"geoname_id"`
+	} `maxminddb:"subdivisions"`
+	RepresentedCountry struct {
+		Names             map[string]string `maxminddb:"names"`
+		IsoCode           string            `maxminddb:"iso_code"`
+		Type              string            `maxminddb:"type"`
+		GeoNameID         uint              `maxminddb:"geoname_id"`
+		IsInEuropeanUnion bool              `maxminddb:"is_in_european_union"`
+	} `maxminddb:"represented_country"`
+	Country struct {
+		Names             map[string]string `maxminddb:"names"`
+		IsoCode           string            `maxminddb:"iso_code"`
+		GeoNameID         uint              `maxminddb:"geoname_id"`
+		IsInEuropeanUnion bool              `maxminddb:"is_in_european_union"`
+	} `maxminddb:"country"`
+	RegisteredCountry struct {
+		Names             map[string]string `maxminddb:"names"`
+		IsoCode           string            `maxminddb:"iso_code"`
+		GeoNameID         uint              `maxminddb:"geoname_id"`
+		IsInEuropeanUnion bool              `maxminddb:"is_in_european_union"`
+	} `maxminddb:"registered_country"`
+	Location struct {
+		TimeZone       string  `maxminddb:"time_zone"`
+		Latitude       float64 `maxminddb:"latitude"`
+		Longitude      float64 `maxminddb:"longitude"`
+		MetroCode      uint    `maxminddb:"metro_code"`
+		AccuracyRadius uint16  `maxminddb:"accuracy_radius"`
+	} `maxminddb:"location"`
+	Traits struct {
+		IsAnonymousProxy    bool `maxminddb:"is_anonymous_proxy"`
+		IsAnycast           bool `maxminddb:"is_anycast"`
+		IsSatelliteProvider bool `maxminddb:"is_satellite_provider"`
+	} `maxminddb:"traits"`
+}
+
+var testCityRecord = CityRecord{
+	City: City{
+		GeoNameID: 5375480,
+		Names:     Names{"en": "Mountain View"},
+	},
+	Continent: Continent{
+		Code:      "NA",
+		GeoNameID: 6255149,
+		Names:     Names{"en": "North America"},
+	},
+	Country: Country{
+		GeoNameID: 6252001,
+		ISOCode:   "US",
+		Names:     Names{"en": "United States"},
+	},
+	Location: Location{
+		AccuracyRadius: 1000,
+		Latitude:       37.386,
+		Longitude:      -122.0838,
+		MetroCode:      807,
+		TimeZone:       "America/Los_Angeles",
+	},
+	Postal: Postal{Code: "94035"},
+	RegisteredCountry: Country{
+		GeoNameID:         2921044,
+		ISOCode:           "DE",
+		IsInEuropeanUnion: true,
+		Names:             Names{"en": "Germany"},
+	},
+	RepresentedCountry: RepresentedCountry{
+		Country: Country{
+			GeoNameID: 6252001,
+			ISOCode:   "US",
+			Names:     Names{"en": "United States"},
+		},
+		Type: "military",
+	},
+	Subdivisions: []Subdivision{
+		{
+			GeoNameID: 5332921,
+			ISOCode:   "CA",
+			Names:     Names{"en": "California"},
+		},
+	},
+	Traits: Traits{IsAnycast: true},
+}
+
+func TestCountryRecordDataType(t *testing.T) {
+	assert.Equal(
+		t,
+		mmdbtype.Map{
+			"continent": mmdbtype.Map{
+				"code":       mmdbtype.String("EU"),
+				"geoname_id": mmdbtype.Uint32(6255148),
+			},
+			"country": mmdbtype.Map{
+				"geoname_id":           mmdbtype.Uint32(2921044),
+				"is_in_european_union": mmdbtype.Bool(true),
+				"iso_code":             mmdbtype.String("DE"),
+				"names": mmdbtype.Map{
+					"de": mmdbtype.String("Deutschland"),
+					"en": mmdbtype.String("Germany"),
+				},
+			},
+		},
+		CountryRecord{
+			Continent: Continent{Code: "EU", GeoNameID: 6255148},
+			Country: Country{
+				GeoNameID:         2921044,
+				ISOCode:           "DE",
+				IsInEuropeanUnion: true,
+				Names:             Names{"de": "Deutschland", "en": "Germany"},
+			},
+		}.DataType(),
+	)
+	assert.Equal(t, mmdbtype.Map{}, CountryRecord{}.DataType())
+	assert.Equal(t, mmdbtype.Map{}, CityRecord{}.DataType())
+}
+
+func TestCityRecordRoundTrip(t *testing.T) {
+	tree, err := mmdbwriter.New(
+		mmdbwriter.Options{
+			DatabaseType: CityDatabaseType,
+			Description:  map[string]string{"en": "Test City database"},
+			Languages:    []string{"en"},
+			RecordSize:   24,
+		},
+	)
+	require.NoError(t, err)
+
+	_, network, err := net.ParseCIDR("8.8.8.0/24")
+	require.NoError(t, err)
+	require.NoError(t, tree.Insert(network, testCityRecord.DataType()))
+
+	buf := &bytes.Buffer{}
+	_, err = tree.WriteTo(buf)
+	require.NoError(t, err)
+
+	reader, err := maxminddb.FromBytes(buf.Bytes())
+	require.NoError(t, err)
+	require.NoError(t, reader.Verify())
+
+	var city geoip2City
+	require.NoError(t, reader.Lookup(net.ParseIP("8.8.8.8"), &city))
+
+	r := testCityRecord
+	assert.Equal(t, r.City.Names, Names(city.City.Names))
+	assert.Equal(t, uint(r.City.GeoNameID), city.City.GeoNameID)
+	assert.Equal(t, r.Continent.Code, city.Continent.Code)
+	assert.Equal(t, r.Country.ISOCode, city.Country.IsoCode)
+	assert.Equal(t, r.Location.AccuracyRadius, city.Location.AccuracyRadius)
+	assert.Equal(t, r.Location.Latitude, city.Location.Latitude)
+	assert.Equal(t, r.Location.Longitude, city.Location.Longitude)
+	assert.Equal(t, uint(r.Location.MetroCode), city.Location.MetroCode)
+	assert.Equal(t, r.Location.TimeZone, city.Location.TimeZone)
+	assert.Equal(t, r.Postal.Code, city.Postal.Code)
+	assert.True(t, city.RegisteredCountry.IsInEuropeanUnion)
+	assert.Equal(t, r.RepresentedCountry.Type, city.RepresentedCountry.Type)
+	require.Len(t, city.Subdivisions, 1)
+	assert.Equal(t, r.Subdivisions[0].ISOCode, city.Subdivisions[0].IsoCode)
+	assert.True(t, city.Traits.IsAnycast)
+	assert.False(t, city.Traits.IsAnonymousProxy)
+}
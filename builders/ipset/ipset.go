@@ -0,0 +1,103 @@
+// Package ipset provides a builder for set-membership databases, such as
+// blocklists and allowlists, where the only question answered by a lookup
+// is whether the IP address is in the set.
+//
+// Each network in the set has the record {"present": true}. A network may
+// also be given a label, e.g., the name of the list it came from, in which
+// case its record is {"present": true, "label": "<label>"}. As the records
+// are shared between all the networks with the same label, adjacent
+// networks are aggregated and the data section is only a few bytes.
+package ipset
+
+import (
+	"io"
+	"net"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/inserter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+// DefaultDatabaseType is the database type used if Options.DatabaseType is
+// not set.
+const DefaultDatabaseType = "IP-Set"
+
+// The keys of the records.
+const (
+	PresentKey = "present"
+	LabelKey   = "label"
+)
+
+// Record returns the record for a network in the set with the label. An
+// empty label means that the label is not included in the record.
+func Record(label string) mmdbtype.Map {
+	m := mmdbtype.Map{PresentKey: mmdbtype.Bool(true)}
+	if label != "" {
+		m[LabelKey] = mmdbtype.String(label)
+	}
+	return m
+}
+
+// Builder builds a set-membership database.
+type Builder struct {
+	tree *mmdbwriter.Tree
+}
+
+// New creates a new Builder. The options are passed to mmdbwriter.New. If
+// DatabaseType is not set, DefaultDatabaseType is used. The tree's inserter
+// determines how a network is labeled when it is added more than once; by
+// default, the last label wins.
+func New(opts mmdbwriter.Options) (*Builder, error) {
+	if opts.DatabaseType == "" {
+		opts.DatabaseType = DefaultDatabaseType
+	}
+	tree, err := mmdbwriter.New(opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Builder{tree: tree}, nil
+}
+
+// Add adds the network to the set.
+func (b *Builder) Add(network *net.IPNet) error {
+	return b.AddWithLabel(network, "")
+}
+
+// AddWithLabel adds the network to the set with the label.
+func (b *Builder) AddWithLabel(network *net.IPNet, label string) error {
+	return b.tree.Insert(network, Record(label))
+}
+
+// AddRange adds all networks in the range of IPs specified by [start, end]
+// to the set.
+func (b *Builder) AddRange(start, end net.IP) error {
+	return b.AddRangeWithLabel(start, end, "")
+}
+
+// AddRangeWithLabel adds all networks in the range of IPs specified by
+// [start, end] to the set with the label.
+func (b *Builder) AddRangeWithLabel(start, end net.IP, label string) error {
+	return b.tree.InsertRange(start, end, Record(label))
+}
+
+// Remove removes the network from the set, e.g., to exclude an allowed
+// network from a blocklist.
+func (b *Builder) Remove(network *net.IPNet) error {
+	return b.tree.InsertFunc(network, inserter.Remove)
+}
+
+// Contains returns whether the IP address is in the set.
+func (b *Builder) Contains(ip net.IP) bool {
+	_, value := b.tree.Get(ip)
+	return value != nil
+}
+
+// Tree returns the underlying tree.
+func (b *Builder) Tree() *mmdbwriter.Tree {
+	return b.tree
+}
+
+// WriteTo writes the database to w.
+func (b *Builder) WriteTo(w io.Writer) (int64, error) {
+	return b.tree.WriteTo(w)
+}
@@ -0,0 +1,82 @@
+package ipset
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/oschwald/maxminddb-golang"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type setRecord struct {
+	Present bool   `maxminddb:"present"`
+	Label   string `maxminddb:"label"`
+}
+
+func TestRecord(t *testing.T) {
+	assert.Equal(t, mmdbtype.Map{"present": mmdbtype.Bool(true)}, Record(""))
+	assert.Equal(
+		t,
+		mmdbtype.Map{"present": mmdbtype.Bool(true), "label": mmdbtype.String("spam")},
+		Record("spam"),
+	)
+}
+
+func TestBuilder(t *testing.T) {
+	b, err := New(mmdbwriter.Options{
+		Description: map[string]string{"en": "Test IP set"},
+		RecordSize:  24,
+	})
+	require.NoError(t, err)
+
+	for _, network := range []string{"1.1.0.0/24", "1.1.1.0/24"} {
+		require.NoError(t, b.Add(parseCIDR(t, network)))
+	}
+	require.NoError(t, b.AddWithLabel(parseCIDR(t, "2.2.2.0/24"), "spam"))
+	require.NoError(t, b.AddRange(net.ParseIP("2600::"), net.ParseIP("2600::ff")))
+	require.NoError(t, b.Remove(parseCIDR(t, "1.1.1.128/25")))
+
+	assert.True(t, b.Contains(net.ParseIP("1.1.0.1")))
+	assert.False(t, b.Contains(net.ParseIP("1.1.1.129")))
+	assert.False(t, b.Contains(net.ParseIP("3.3.3.3")))
+
+	buf := &bytes.Buffer{}
+	_, err = b.WriteTo(buf)
+	require.NoError(t, err)
+
+	reader, err := maxminddb.FromBytes(buf.Bytes())
+	require.NoError(t, err)
+	require.NoError(t, reader.Verify())
+	assert.Equal(t, DefaultDatabaseType, reader.Metadata.DatabaseType)
+
+	tests := []struct {
+		ip       string
+		network  string
+		expected setRecord
+	}{
+		{ip: "1.1.0.1", network: "1.1.0.0/24", expected: setRecord{Present: true}},
+		{ip: "1.1.1.1", network: "1.1.1.0/25", expected: setRecord{Present: true}},
+		{ip: "1.1.1.129", network: "1.1.1.128/25"},
+		{ip: "2.2.2.2", network: "2.2.2.0/24", expected: setRecord{Present: true, Label: "spam"}},
+		{ip: "2600::1", network: "2600::/120", expected: setRecord{Present: true}},
+	}
+	for _, test := range tests {
+		t.Run(test.ip, func(t *testing.T) {
+			var record setRecord
+			network, _, err := reader.LookupNetwork(net.ParseIP(test.ip), &record)
+			require.NoError(t, err)
+			assert.Equal(t, test.network, network.String())
+			assert.Equal(t, test.expected, record)
+		})
+	}
+}
+
+func parseCIDR(t *testing.T, s string) *net.IPNet {
+	_, network, err := net.ParseCIDR(s)
+	require.NoError(t, err)
+	return network
+}
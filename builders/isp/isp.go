@@ -0,0 +1,69 @@
+// Package isp provides a builder for databases with the same record layout
+// as the MaxMind GeoIP2 ISP database.
+package isp
+
+import (
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/builders/layout"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+// DefaultDatabaseType is the database type used if Options.DatabaseType is
+// not set. See layout.New.
+const DefaultDatabaseType = "GeoIP2-ISP"
+
+// Record is an ISP record. An empty or zero field is not included in the
+// record.
+type Record struct {
+	// AutonomousSystemNumber is the autonomous system number associated
+	// with the network.
+	AutonomousSystemNumber uint32
+
+	// AutonomousSystemOrganization is the organization associated with the
+	// autonomous system.
+	AutonomousSystemOrganization string
+
+	// ISP is the name of the ISP associated with the network.
+	ISP string
+
+	// Organization is the name of the organization associated with the
+	// network.
+	Organization string
+
+	// MobileCountryCode is the mobile country code (MCC) associated with
+	// the network, e.g., "310".
+	MobileCountryCode string
+
+	// MobileNetworkCode is the mobile network code (MNC) associated with
+	// the network, e.g., "004".
+	MobileNetworkCode string
+}
+
+// DataType returns the record in the GeoIP2 ISP layout.
+func (r Record) DataType() mmdbtype.Map {
+	m := mmdbtype.Map{}
+	if r.AutonomousSystemNumber != 0 {
+		m["autonomous_system_number"] = mmdbtype.Uint32(r.AutonomousSystemNumber)
+	}
+	addString(m, "autonomous_system_organization", r.AutonomousSystemOrganization)
+	addString(m, "isp", r.ISP)
+	addString(m, "organization", r.Organization)
+	addString(m, "mobile_country_code", r.MobileCountryCode)
+	addString(m, "mobile_network_code", r.MobileNetworkCode)
+	return m
+}
+
+func addString(m mmdbtype.Map, key mmdbtype.String, v string) {
+	if v != "" {
+		m[key] = mmdbtype.String(v)
+	}
+}
+
+// Builder builds an ISP database.
+type Builder = layout.Builder[Record]
+
+// New creates a new Builder. The options are passed to mmdbwriter.New. If
+// DatabaseType is not set, DefaultDatabaseType is used.
+func New(opts mmdbwriter.Options) (*Builder, error) {
+	return layout.New[Record](opts, DefaultDatabaseType)
+}
@@ -0,0 +1,95 @@
+package isp
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/oschwald/maxminddb-golang"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// This matches the ISP struct in github.com/oschwald/geoip2-golang.
+type geoip2ISP struct {
+	AutonomousSystemOrganization string `maxminddb:"autonomous_system_organization"`
+	ISP                          string `maxminddb:"isp"`
+	MobileCountryCode            string `maxminddb:"mobile_country_code"`
+	MobileNetworkCode            string `maxminddb:"mobile_network_code"`
+	Organization                 string `maxminddb:"organization"`
+	AutonomousSystemNumber       uint   `maxminddb:"autonomous_system_number"`
+}
+
+func TestRecordDataType(t *testing.T) {
+	assert.Equal(
+		t,
+		mmdbtype.Map{
+			"autonomous_system_number":       mmdbtype.Uint32(6167),
+			"autonomous_system_organization": mmdbtype.String("CELLCO-PART"),
+			"isp":                            mmdbtype.String("Verizon Wireless"),
+			"organization":                   mmdbtype.String("Verizon Wireless"),
+			"mobile_country_code":            mmdbtype.String("310"),
+			"mobile_network_code":            mmdbtype.String("004"),
+		},
+		Record{
+			AutonomousSystemNumber:       6167,
+			AutonomousSystemOrganization: "CELLCO-PART",
+			ISP:                          "Verizon Wireless",
+			Organization:                 "Verizon Wireless",
+			MobileCountryCode:            "310",
+			MobileNetworkCode:            "004",
+		}.DataType(),
+	)
+	assert.Equal(t, mmdbtype.Map{}, Record{}.DataType())
+}
+
+func TestBuilder(t *testing.T) {
+	b, err := New(
+		mmdbwriter.Options{
+			Description: map[string]string{"en": "Test ISP database"},
+			RecordSize:  24,
+		},
+	)
+	require.NoError(t, err)
+
+	_, network, err := net.ParseCIDR("1.0.0.0/24")
+	require.NoError(t, err)
+	require.NoError(t, b.Insert(network, Record{
+		AutonomousSystemNumber:       13335,
+		AutonomousSystemOrganization: "CLOUDFLARENET",
+		ISP:                          "Cloudflare",
+	}))
+
+	require.NoError(t, b.InsertRange(
+		net.ParseIP("2001:4860::"),
+		net.ParseIP("2001:4860:ffff:ffff:ffff:ffff:ffff:ffff"),
+		Record{Organization: "Google", MobileCountryCode: "310", MobileNetworkCode: "260"},
+	))
+
+	buf := &bytes.Buffer{}
+	_, err = b.WriteTo(buf)
+	require.NoError(t, err)
+
+	reader, err := maxminddb.FromBytes(buf.Bytes())
+	require.NoError(t, err)
+	require.NoError(t, reader.Verify())
+	assert.Equal(t, DefaultDatabaseType, reader.Metadata.DatabaseType)
+
+	var record geoip2ISP
+	require.NoError(t, reader.Lookup(net.ParseIP("1.0.0.1"), &record))
+	assert.Equal(t, geoip2ISP{
+		AutonomousSystemNumber:       13335,
+		AutonomousSystemOrganization: "CLOUDFLARENET",
+		ISP:                          "Cloudflare",
+	}, record)
+
+	record = geoip2ISP{}
+	require.NoError(t, reader.Lookup(net.ParseIP("2001:4860:4860::8888"), &record))
+	assert.Equal(t, geoip2ISP{
+		Organization:      "Google",
+		MobileCountryCode: "310",
+		MobileNetworkCode: "260",
+	}, record)
+}
@@ -0,0 +1,62 @@
+// Package layout provides the Builder shared by the builders for the record
+// layouts of the MaxMind databases, e.g., builders/asn. Each of those
+// packages defines the Record type of its layout and its default database
+// type.
+package layout
+
+import (
+	"io"
+	"net"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+// Record is a record in a database layout.
+type Record interface {
+	// DataType returns the record in the layout.
+	DataType() mmdbtype.Map
+}
+
+// Builder builds a database with the record layout of R.
+type Builder[R Record] struct {
+	tree *mmdbwriter.Tree
+}
+
+// New creates a new Builder. The options are passed to mmdbwriter.New. If
+// DatabaseType is not set, defaultDatabaseType is used. Readers such as
+// github.com/oschwald/geoip2-golang use the database type to determine
+// which lookups are allowed, so using the type of the MaxMind database with
+// the layout allows the database to be read as such a database without
+// changes.
+func New[R Record](opts mmdbwriter.Options, defaultDatabaseType string) (*Builder[R], error) {
+	if opts.DatabaseType == "" {
+		opts.DatabaseType = defaultDatabaseType
+	}
+	tree, err := mmdbwriter.New(opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Builder[R]{tree: tree}, nil
+}
+
+// Insert inserts the record for the network using the tree's inserter.
+func (b *Builder[R]) Insert(network *net.IPNet, r R) error {
+	return b.tree.Insert(network, r.DataType())
+}
+
+// InsertRange inserts the record for all networks in the range of IPs
+// specified by [start, end] using the tree's inserter.
+func (b *Builder[R]) InsertRange(start, end net.IP, r R) error {
+	return b.tree.InsertRange(start, end, r.DataType())
+}
+
+// Tree returns the underlying tree.
+func (b *Builder[R]) Tree() *mmdbwriter.Tree {
+	return b.tree
+}
+
+// WriteTo writes the database to w.
+func (b *Builder[R]) WriteTo(w io.Writer) (int64, error) {
+	return b.tree.WriteTo(w)
+}
@@ -0,0 +1,62 @@
+package layout
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/oschwald/maxminddb-golang"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testRecord struct {
+	name string
+}
+
+func (r testRecord) DataType() mmdbtype.Map {
+	return mmdbtype.Map{"name": mmdbtype.String(r.name)}
+}
+
+func TestBuilder(t *testing.T) {
+	for _, test := range []struct {
+		databaseType string
+		expectedType string
+	}{
+		{expectedType: "Test-Default"},
+		{databaseType: "Test-Custom", expectedType: "Test-Custom"},
+	} {
+		t.Run(test.expectedType, func(t *testing.T) {
+			b, err := New[testRecord](
+				mmdbwriter.Options{DatabaseType: test.databaseType},
+				"Test-Default",
+			)
+			require.NoError(t, err)
+
+			_, network, err := net.ParseCIDR("1.0.0.0/24")
+			require.NoError(t, err)
+			require.NoError(t, b.Insert(network, testRecord{name: "a"}))
+			require.NoError(t, b.InsertRange(
+				net.ParseIP("2.0.0.0"),
+				net.ParseIP("2.0.0.255"),
+				testRecord{name: "b"},
+			))
+			_, value := b.Tree().Get(net.ParseIP("2.0.0.1"))
+			assert.Equal(t, mmdbtype.Map{"name": mmdbtype.String("b")}, value)
+
+			buf := &bytes.Buffer{}
+			_, err = b.WriteTo(buf)
+			require.NoError(t, err)
+
+			reader, err := maxminddb.FromBytes(buf.Bytes())
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedType, reader.Metadata.DatabaseType)
+
+			var record map[string]string
+			require.NoError(t, reader.Lookup(net.ParseIP("1.0.0.1"), &record))
+			assert.Equal(t, map[string]string{"name": "a"}, record)
+		})
+	}
+}
@@ -0,0 +1,239 @@
+package mmdbwriter
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+// Check validates the internal invariants of the tree and returns an error
+// describing the first violation found. It checks that:
+//
+//   - every record has a known type, node records point to a node, and
+//     other records do not;
+//   - each node is reachable through only one record and aliases point to
+//     the IPv4 subtree;
+//   - adjacent records with equal data have been merged;
+//   - the node count and the reference counts of the data values match
+//     the records in the tree;
+//   - each data value can be encoded and decoded back to an equal value;
+//   - if the tree has been finalized, the nodes are numbered contiguously
+//     in the order they are written; and
+//   - the records will fit in the record size.
+//
+// Check does not modify the tree. It is intended to be run in tests,
+// including those of programs using this package, to catch bugs in the
+// writer early. An error from Check indicates a bug in this package.
+func (t *Tree) Check() error {
+	c := &treeChecker{
+		tree:       t,
+		nodes:      map[*node]struct{}{},
+		refCounts:  map[valueRef]uint32{},
+		fixedNodes: map[*node]struct{}{},
+	}
+	if err := c.checkNode(t.root, make(net.IP, t.treeDepth/8), 0); err != nil {
+		return err
+	}
+
+	for _, alias := range c.aliases {
+		if _, ok := c.fixedNodes[alias]; !ok {
+			return fmt.Errorf("an alias points to node %p, which is not a fixed node in the tree", alias)
+		}
+	}
+
+	if len(c.nodes) != t.liveNodes {
+		return fmt.Errorf(
+			"the tree has %d reachable nodes, but the node count is %d",
+			len(c.nodes),
+			t.liveNodes,
+		)
+	}
+
+	if err := c.checkData(); err != nil {
+		return err
+	}
+
+	if t.nodeCount != 0 {
+		if c.finalizedNodes != len(c.nodes) || t.nodeCount != len(c.nodes)+t.paddingNodes {
+			return fmt.Errorf(
+				"the tree was finalized with %d nodes, but it has %d nodes and %d padding nodes",
+				t.nodeCount,
+				len(c.nodes),
+				t.paddingNodes,
+			)
+		}
+	}
+
+	return c.checkRecordSize()
+}
+
+type treeChecker struct {
+	tree       *Tree
+	nodes      map[*node]struct{}
+	refCounts  map[valueRef]uint32
+	fixedNodes map[*node]struct{}
+	aliases    []*node
+
+	// finalizedNodes is the number of nodes visited whose number matched
+	// their position in the order they are written.
+	finalizedNodes int
+}
+
+// checkNode checks the node at the given depth, whose address is ip, and
+// its subtree.
+func (c *treeChecker) checkNode(n *node, ip net.IP, depth int) error {
+	t := c.tree
+	if depth >= t.treeDepth {
+		return fmt.Errorf("%s has a node below the maximum depth", t.network(ip, depth))
+	}
+	if _, ok := c.nodes[n]; ok {
+		return fmt.Errorf("the node for %s is reachable through more than one record", t.network(ip, depth))
+	}
+	c.nodes[n] = struct{}{}
+	if n.nodeNum == len(c.nodes)-1 {
+		c.finalizedNodes++
+	}
+
+	for i := 0; i < 2; i++ {
+		if i == 1 {
+			setBitAt(ip, depth)
+		}
+		err := c.checkRecord(n.children[i], ip, depth+1)
+		if i == 1 {
+			clearBitAt(ip, depth)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkRecord checks the record for the network given by ip and
+// prefixLen.
+func (c *treeChecker) checkRecord(r record, ip net.IP, prefixLen int) error {
+	network := c.tree.network(ip, prefixLen)
+	switch r.recordType {
+	case recordTypeEmpty, recordTypeReserved:
+		if r.node != nil || r.value != 0 {
+			return fmt.Errorf("the empty or reserved record for %s has a node or value", network)
+		}
+		return nil
+	case recordTypeData:
+		if r.node != nil || r.value == 0 {
+			return fmt.Errorf("the data record for %s has a node or is missing its value", network)
+		}
+		c.refCounts[r.value]++
+		return nil
+	case recordTypeAlias:
+		if r.node == nil || r.value != 0 {
+			return fmt.Errorf("the alias record for %s is missing its node or has a value", network)
+		}
+		c.aliases = append(c.aliases, r.node)
+		return nil
+	case recordTypeNode, recordTypeFixedNode:
+		if r.node == nil || r.value != 0 {
+			return fmt.Errorf("the node record for %s is missing its node or has a value", network)
+		}
+		if r.recordType == recordTypeFixedNode {
+			c.fixedNodes[r.node] = struct{}{}
+		} else if mergeable(r.node) {
+			return fmt.Errorf("the children of the node for %s have not been merged", network)
+		}
+		return c.checkNode(r.node, ip, prefixLen)
+	default:
+		return fmt.Errorf("the record for %s has unknown type %d", network, r.recordType)
+	}
+}
+
+// mergeable returns true if the children of the node are equal records that
+// should have been merged into one record.
+func mergeable(n *node) bool {
+	child0 := n.children[0]
+	child1 := n.children[1]
+	if child0.recordType != child1.recordType {
+		return false
+	}
+	switch child0.recordType {
+	case recordTypeEmpty, recordTypeReserved:
+		return true
+	case recordTypeData:
+		return child0.value == child1.value
+	default:
+		return false
+	}
+}
+
+// checkData checks the reference counts of the data values and that they
+// can be encoded and decoded.
+func (c *treeChecker) checkData() error {
+	dm := c.tree.dataMap
+	if len(c.refCounts) != len(dm.data) {
+		return fmt.Errorf(
+			"the tree has %d distinct values, but the data map has %d",
+			len(c.refCounts),
+			len(dm.data),
+		)
+	}
+
+	dw := newDataWriter(dm, false)
+	buf := &bytes.Buffer{}
+	dw.dataBuffer = buf
+	for ref, count := range c.refCounts {
+		if int(ref) >= len(dm.refs) || dm.refs[ref] == nil {
+			return fmt.Errorf("a record refers to the missing value %d", ref)
+		}
+		v := dm.refs[ref]
+		data := v.load()
+		if dm.data[v.key] != v {
+			return fmt.Errorf("the value %v is not in the data map", data)
+		}
+		if v.refCount != count {
+			return fmt.Errorf(
+				"the value %v has a reference count of %d, but is used by %d records",
+				data,
+				v.refCount,
+				count,
+			)
+		}
+
+		offset := buf.Len()
+		if _, err := data.WriteTo(dw); err != nil {
+			return fmt.Errorf("encoding %v: %w", data, err)
+		}
+		decoded, _, err := mmdbtype.DecodeAt(buf.Bytes(), offset)
+		if err != nil {
+			return fmt.Errorf("decoding %v: %w", data, err)
+		}
+		if !decoded.Equal(data) {
+			return fmt.Errorf("%v was decoded as %v", data, decoded)
+		}
+	}
+	return nil
+}
+
+// checkRecordSize checks that the records will fit in the record size.
+func (c *treeChecker) checkRecordSize() error {
+	t := c.tree
+	nodeCount := len(c.nodes) + t.alignmentPadding(len(c.nodes))
+
+	dw, _, err := t.newDataWriter(&countingBuffer{})
+	if err != nil {
+		return err
+	}
+	maxOffset, err := t.maxDataOffset(t.root, make(net.IP, t.treeDepth/8), 0, dw)
+	if err != nil {
+		return err
+	}
+
+	maxRecord := nodeCount
+	if maxOffset >= 0 {
+		maxRecord = nodeCount + len(dataSectionSeparator) + maxOffset
+	}
+	if maxRecord >= 1<<t.recordSize {
+		return recordSizeError(maxRecord, t.recordSize)
+	}
+	return nil
+}
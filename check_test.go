@@ -0,0 +1,105 @@
+package mmdbwriter
+
+import (
+	"io"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/inserter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck(t *testing.T) {
+	for _, ipVersion := range []int{4, 6} {
+		tree, err := New(Options{IPVersion: ipVersion})
+		require.NoError(t, err)
+		require.NoError(t, tree.Check())
+
+		insert(t, tree, "1.1.1.0/24", "a")
+		insert(t, tree, "1.1.2.0/24", "a")
+		insert(t, tree, "1.1.0.0/16", "b")
+		insert(t, tree, "2.0.0.0/8", "c")
+		require.NoError(t, tree.InsertFunc(mustNetwork(t, "2.2.0.0/16"), inserter.Remove))
+		require.NoError(t, tree.Insert(
+			mustNetwork(t, "3.0.0.0/8"),
+			mmdbtype.Map{"list": mmdbtype.Slice{mmdbtype.Uint32(1), mmdbtype.Float64(1.5)}},
+		))
+		require.NoError(t, tree.Check())
+
+		_, err = tree.Finalize()
+		require.NoError(t, err)
+		require.NoError(t, tree.Check())
+
+		_, err = tree.WriteTo(io.Discard)
+		require.NoError(t, err)
+		require.NoError(t, tree.Check())
+	}
+}
+
+func TestCheckViolations(t *testing.T) {
+	tests := []struct {
+		name        string
+		corrupt     func(tree *Tree)
+		expectedErr string
+	}{
+		{
+			name: "node count",
+			corrupt: func(tree *Tree) {
+				tree.liveNodes++
+			},
+			expectedErr: "reachable nodes, but the node count is",
+		},
+		{
+			name: "reference count",
+			corrupt: func(tree *Tree) {
+				for _, v := range tree.dataMap.data {
+					v.refCount++
+				}
+			},
+			expectedErr: "has a reference count of 2, but is used by 1 records",
+		},
+		{
+			name: "unmerged node",
+			corrupt: func(tree *Tree) {
+				r := &tree.root.children[1]
+				r.node = &node{children: [2]record{{}, {}}}
+				r.recordType = recordTypeNode
+				tree.liveNodes++
+			},
+			expectedErr: "the children of the node for 128.0.0.0/1 have not been merged",
+		},
+		{
+			name: "node numbering",
+			corrupt: func(tree *Tree) {
+				_, err := tree.Finalize()
+				if err != nil {
+					panic(err)
+				}
+				tree.root.nodeNum = 5
+			},
+			expectedErr: "the tree was finalized with",
+		},
+		{
+			name: "record size",
+			corrupt: func(tree *Tree) {
+				tree.recordSize = 4
+			},
+			expectedErr: "record",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tree, err := New(Options{IPVersion: 4})
+			require.NoError(t, err)
+			insert(t, tree, "1.1.1.0/24", "a")
+			require.NoError(t, tree.Check())
+
+			test.corrupt(tree)
+			err = tree.Check()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), test.expectedErr)
+		})
+	}
+}
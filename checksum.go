@@ -0,0 +1,41 @@
+package mmdbwriter
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+// ChecksumKey is the metadata key for the hex-encoded SHA-256 digest of the
+// search tree and data section when Options.EmbedChecksum is used.
+const ChecksumKey = "mmdbwriter_sha256"
+
+// VerifyChecksum verifies the database in buf against the digest embedded
+// in its metadata. The buf must contain the entire database, e.g., as read
+// with os.ReadFile. An error is returned if the database does not have an
+// embedded digest or if the digest does not match.
+func VerifyChecksum(buf []byte) error {
+	metadata, err := readMetadata(buf)
+	if err != nil {
+		return err
+	}
+	expected, ok := metadata[ChecksumKey].(mmdbtype.String)
+	if !ok {
+		return errors.New("the database does not have an embedded checksum")
+	}
+
+	end := bytes.LastIndex(buf, metadataStartMarker)
+	sum := sha256.Sum256(buf[:end])
+	if actual := hex.EncodeToString(sum[:]); actual != string(expected) {
+		return fmt.Errorf(
+			"checksum mismatch: the metadata has %s but the database has %s",
+			expected,
+			actual,
+		)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package mmdbwriter
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChecksum(t *testing.T) {
+	tree, err := New(Options{EmbedChecksum: true})
+	require.NoError(t, err)
+
+	_, network, err := net.ParseCIDR("1.1.1.0/24")
+	require.NoError(t, err)
+	require.NoError(t, tree.Insert(network, mmdbtype.String("value")))
+
+	buf := &bytes.Buffer{}
+	n, sum, err := tree.WriteToWithChecksum(buf)
+	require.NoError(t, err)
+	assert.Equal(t, int64(buf.Len()), n)
+
+	db := buf.Bytes()
+	end := bytes.LastIndex(db, metadataStartMarker)
+	assert.Equal(t, sha256.Sum256(db[:end]), sum)
+
+	metadata, err := readMetadata(db)
+	require.NoError(t, err)
+	assert.Equal(t, mmdbtype.String(hex.EncodeToString(sum[:])), metadata[ChecksumKey])
+
+	require.NoError(t, VerifyChecksum(db))
+
+	corrupt := append([]byte(nil), db...)
+	corrupt[0] ^= 0xff
+	assert.ErrorContains(t, VerifyChecksum(corrupt), "checksum mismatch")
+
+	tree, err = New(Options{})
+	require.NoError(t, err)
+	buf.Reset()
+	_, err = tree.WriteTo(buf)
+	require.NoError(t, err)
+	assert.EqualError(
+		t,
+		VerifyChecksum(buf.Bytes()),
+		"the database does not have an embedded checksum",
+	)
+}
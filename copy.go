@@ -0,0 +1,87 @@
+package mmdbwriter
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/maxmind/mmdbwriter/inserter"
+)
+
+// CopyFrom replaces the data within prefix with the data of src within
+// prefix, e.g., to compose a database from subtrees built independently.
+// The data of t within prefix is removed first, so networks without data in
+// src also have none in t afterward. Data of src for a network larger than
+// prefix is reduced to prefix. src is not modified.
+//
+// The networks of src are converted as with Walk, so the trees may use
+// different IP versions and IPv4 placements, but an IPv4 tree may only
+// receive IPv4 networks. The copied values are inserted with t's insert
+// pipeline, e.g., its rounding and name filtering, but without its
+// Inserter or InsertMiddleware, as with Extract. Reserved and aliased
+// networks of t within prefix are left unchanged.
+//
+// This is not safe to call from multiple threads.
+func (t *Tree) CopyFrom(src *Tree, prefix *net.IPNet) error {
+	if prefix == nil {
+		return errors.New("the network is nil")
+	}
+	if src == t {
+		return errors.New("cannot copy a tree into itself")
+	}
+
+	srcIP, srcPrefixLen, err := src.validTreeNetwork(prefix)
+	if err != nil {
+		return err
+	}
+	ip, prefixLen, err := t.validTreeNetwork(prefix)
+	if err != nil {
+		return err
+	}
+
+	// We set this to 0 so that the tree must be finalized again.
+	t.nodeCount = 0
+	t.invalidateIndex()
+
+	err = t.root.insert(
+		insertRecord{
+			ip:         ip,
+			prefixLen:  prefixLen,
+			recordType: recordTypeData,
+			inserter:   inserter.Remove,
+			nodeCount:  &t.liveNodes,
+			report:     &t.report,
+
+			dataMap: t.dataMap,
+			nodes:   t.nodes,
+
+			overwritePolicy: OverwriteReplace,
+		},
+		0,
+	)
+	if err != nil {
+		return fmt.Errorf("removing the data within %s: %w", prefix, err)
+	}
+
+	return src.walkWithin(srcIP, srcPrefixLen, func(ip net.IP, prefixLen int, r record) error {
+		if r.recordType != recordTypeData {
+			return nil
+		}
+		return t.insert(
+			src.network(ip, prefixLen),
+			recordTypeData,
+			inserter.ReplaceWith(src.dataMap.load(r.value)),
+			nil,
+		)
+	})
+}
+
+// validTreeNetwork is the same as treeNetwork, except that it returns an
+// error if the network cannot be in the tree.
+func (t *Tree) validTreeNetwork(network *net.IPNet) (net.IP, int, error) {
+	ip, prefixLen := t.treeNetwork(network)
+	if len(ip)*8 != t.treeDepth {
+		return nil, 0, fmt.Errorf("%s is not a valid network for an IPv4 tree", network)
+	}
+	return ip, prefixLen, nil
+}
@@ -0,0 +1,75 @@
+package mmdbwriter
+
+import (
+	"net"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCopyFrom(t *testing.T) {
+	src, err := New(Options{IPVersion: 4})
+	require.NoError(t, err)
+	insert(t, src, "1.0.0.0/8", "src")
+	insert(t, src, "1.1.0.0/16", "src more specific")
+	insert(t, src, "2.0.0.0/8", "outside")
+
+	dst, err := New(Options{})
+	require.NoError(t, err)
+	insert(t, dst, "1.0.0.0/8", "dst")
+	insert(t, dst, "1.2.3.0/24", "dst removed")
+	insert(t, dst, "1.128.0.0/9", "dst kept")
+	insert(t, dst, "2003::/16", "dst IPv6")
+
+	require.NoError(t, dst.CopyFrom(src, mustNetwork(t, "1.0.0.0/9")))
+	require.NoError(t, dst.Check())
+
+	var got []string
+	require.NoError(t, dst.Walk(func(network *net.IPNet, value mmdbtype.DataType) (bool, error) {
+		if value != nil {
+			got = append(got, network.String()+" "+string(value.(mmdbtype.String)))
+		}
+		return true, nil
+	}))
+	assert.Equal(t, []string{
+		"1.0.0.0/16 src",
+		"1.1.0.0/16 src more specific",
+		"1.2.0.0/15 src",
+		"1.4.0.0/14 src",
+		"1.8.0.0/13 src",
+		"1.16.0.0/12 src",
+		"1.32.0.0/11 src",
+		"1.64.0.0/10 src",
+		"1.128.0.0/9 dst kept",
+		"2003::/16 dst IPv6",
+	}, got)
+
+	err = dst.CopyFrom(src, mustNetwork(t, "2003::/16"))
+	assert.EqualError(t, err, "2003::/16 is not a valid network for an IPv4 tree")
+
+	err = dst.CopyFrom(dst, mustNetwork(t, "1.0.0.0/8"))
+	assert.EqualError(t, err, "cannot copy a tree into itself")
+
+	err = dst.CopyFrom(src, nil)
+	assert.EqualError(t, err, "the network is nil")
+}
+
+func TestCopyFromEmpty(t *testing.T) {
+	src, err := New(Options{})
+	require.NoError(t, err)
+
+	dst, err := New(Options{})
+	require.NoError(t, err)
+	insert(t, dst, "2003::/16", "dst")
+
+	require.NoError(t, dst.CopyFrom(src, mustNetwork(t, "2003:1::/32")))
+	require.NoError(t, dst.Check())
+
+	_, value := dst.Get(net.ParseIP("2003:1::1"))
+	assert.Nil(t, value)
+	network, value := dst.Get(net.ParseIP("2003:2::1"))
+	assert.Equal(t, "2003:2::/31", network.String())
+	assert.Equal(t, mmdbtype.String("dst"), value)
+}
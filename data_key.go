@@ -20,18 +20,20 @@ func newKeyWriter() *keyWriter {
 }
 
 // This is just a quick hack. I am sure there is
-// something better.
-func (kw *keyWriter) key(t mmdbtype.DataType) ([]byte, error) {
+// something better. The size of the encoded value is also returned. The
+// encoded value remains in the buffer until the next call.
+func (kw *keyWriter) key(t mmdbtype.DataType) ([]byte, int, error) {
 	kw.Truncate(0)
 	kw.sha256.Reset()
 	_, err := t.WriteTo(kw)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
-	if _, err := kw.WriteTo(kw.sha256); err != nil {
-		return nil, err
+	size := kw.Len()
+	if _, err := kw.sha256.Write(kw.Bytes()); err != nil {
+		return nil, 0, err
 	}
-	return kw.sha256.Sum(nil), nil
+	return kw.sha256.Sum(nil), size, nil
 }
 
 func (kw *keyWriter) WriteOrWritePointer(t mmdbtype.DataType) (int64, error) {
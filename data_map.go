@@ -1,15 +1,35 @@
 package mmdbwriter
 
-import "github.com/maxmind/mmdbwriter/mmdbtype"
+import (
+	"reflect"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
 
 type dataMapKey string
 
+// valueRef refers to a dataMapValue by its index in dataMap.refs. The zero
+// valueRef refers to no value. Records refer to their values this way
+// rather than by pointer so that the nodes contain no pointers into the Go
+// heap and may be stored outside of it. See NodeStorageFile.
+type valueRef uint32
+
 // Please note, if you change the order of these fields, please check
 // alignment as we end up storing quite a few in memory.
 type dataMapValue struct {
 	data mmdbtype.DataType
 	key  dataMapKey
 
+	// encoded is the value encoded without pointers when it is stored in
+	// a valueStore rather than in data.
+	encoded []byte
+
+	// size is the size of the encoded value without pointers.
+	size uint32
+
+	// ref is the reference to the value in the dataMap.
+	ref valueRef
+
 	// Alternatively, we could use a weak map for the data map, but I
 	// don't see any very good options at the moment. We should revist
 	// if something happens with https://github.com/golang/go/issues/43615
@@ -21,20 +41,91 @@ type dataMapValue struct {
 type dataMap struct {
 	data      map[dataMapKey]*dataMapValue
 	keyWriter *keyWriter
+
+	// refs holds the values by their valueRef. The first entry is always
+	// nil. freeRefs holds the references of removed values, which are
+	// reused by the values stored afterward.
+	refs     []*dataMapValue
+	freeRefs []valueRef
+
+	// size is the total size of the distinct values when encoded without
+	// pointers. This is an upper bound on the size of the data section.
+	size int
+
+	// identities caches the dataMapValue for recently stored Map, Slice,
+	// Bytes, and Uint128 instances by their identity. When the same
+	// instance is inserted for many networks, which is common when
+	// importing, the cache allows us to skip generating the key, which
+	// requires encoding the value, for each of them.
+	identities map[valueIdentity]identityEntry
+
+	// cloneValues causes a deep copy of each distinct value to be stored
+	// rather than the value itself.
+	cloneValues bool
+
+	// session, if set, is used to intern the keys and values.
+	session *Session
+
+	// values, if set, stores the encoded values instead of data. See
+	// ValueStorageFile.
+	values valueStore
+}
+
+// load returns the value. If the value is in a valueStore, a new copy is
+// decoded each time.
+func (v *dataMapValue) load() mmdbtype.DataType {
+	if v.encoded == nil {
+		return v.data
+	}
+	// The value was encoded by the keyWriter, so decoding it never fails.
+	data, _ := mmdbtype.Decode(v.encoded)
+	return data
 }
 
 func newDataMap() *dataMap {
 	return &dataMap{
-		data:      map[dataMapKey]*dataMapValue{},
-		keyWriter: newKeyWriter(),
+		data:       map[dataMapKey]*dataMapValue{},
+		keyWriter:  newKeyWriter(),
+		refs:       []*dataMapValue{nil},
+		identities: map[valueIdentity]identityEntry{},
 	}
 }
 
+// maxIdentities is the maximum number of entries in dataMap.identities.
+const maxIdentities = 1024
+
+// valueIdentity identifies a Map, Slice, Bytes, or Uint128 instance.
+type valueIdentity struct {
+	ptr uintptr
+	len int
+	typ byte
+}
+
+type identityEntry struct {
+	// stored is the instance. Keeping a reference to it ensures that its
+	// address is not reused for another instance while it is cached.
+	stored mmdbtype.DataType
+	value  *dataMapValue
+}
+
 // store stores the value in the dataMap and returns the dataMapValue for it.
 // If the value is already in the dataMap, the reference count for it is
 // incremented.
 func (dm *dataMap) store(v mmdbtype.DataType) (*dataMapValue, error) {
-	key, err := dm.keyWriter.key(v)
+	// If we are cloning values, the caller may have modified the value
+	// since it was last stored, so we cannot rely on its identity.
+	id, hasIdentity := identityOf(v)
+	hasIdentity = hasIdentity && !dm.cloneValues
+	if hasIdentity {
+		// A value whose reference count dropped to zero has been removed
+		// from the dataMap and must not be reused.
+		if e, ok := dm.identities[id]; ok && e.value.refCount > 0 {
+			e.value.refCount++
+			return e.value, nil
+		}
+	}
+
+	key, size, err := dm.keyWriter.key(v)
 	if err != nil {
 		return nil, err
 	}
@@ -42,29 +133,159 @@ func (dm *dataMap) store(v mmdbtype.DataType) (*dataMapValue, error) {
 	dmv, ok := dm.data[dataMapKey(key)]
 	if !ok {
 		dmKey := dataMapKey(key)
+		data := v
+		var encoded []byte
+		switch {
+		case dm.values != nil:
+			data = nil
+			encoded, err = dm.values.put(dm.keyWriter.Bytes())
+			if err != nil {
+				return nil, err
+			}
+		case dm.session != nil:
+			dmKey, data = dm.session.intern(dmKey, v, dm.cloneValues)
+		case dm.cloneValues:
+			data = v.Copy()
+		}
 		dmv = &dataMapValue{
-			key:  dmKey,
-			data: v,
+			key:     dmKey,
+			data:    data,
+			encoded: encoded,
+			size:    uint32(size),
 		}
-		dm.data[dmKey] = dmv
+		dm.add(dmv)
 	}
 
 	dmv.refCount++
 
+	if hasIdentity {
+		if len(dm.identities) >= maxIdentities {
+			dm.identities = map[valueIdentity]identityEntry{}
+		}
+		dm.identities[id] = identityEntry{stored: v, value: dmv}
+	}
+
 	return dmv, nil
 }
 
+// identityOf returns the identity of a Map, Slice, Bytes, or Uint128
+// instance. It returns false for other types, which are cheap to generate
+// keys for, and for empty values, which have no identity.
+func identityOf(v mmdbtype.DataType) (valueIdentity, bool) {
+	switch v := v.(type) {
+	case mmdbtype.Map:
+		if len(v) == 0 {
+			return valueIdentity{}, false
+		}
+		return valueIdentity{ptr: reflect.ValueOf(v).Pointer(), len: len(v), typ: 1}, true
+	case mmdbtype.Slice:
+		if len(v) == 0 {
+			return valueIdentity{}, false
+		}
+		return valueIdentity{ptr: reflect.ValueOf(v).Pointer(), len: len(v), typ: 2}, true
+	case mmdbtype.Bytes:
+		if len(v) == 0 {
+			return valueIdentity{}, false
+		}
+		return valueIdentity{ptr: reflect.ValueOf(v).Pointer(), len: len(v), typ: 3}, true
+	case *mmdbtype.Uint128:
+		if v == nil {
+			return valueIdentity{}, false
+		}
+		return valueIdentity{ptr: reflect.ValueOf(v).Pointer(), typ: 4}, true
+	default:
+		return valueIdentity{}, false
+	}
+}
+
+// add adds a new value to the dataMap and assigns its reference.
+func (dm *dataMap) add(dmv *dataMapValue) {
+	if n := len(dm.freeRefs); n > 0 {
+		dmv.ref = dm.freeRefs[n-1]
+		dm.freeRefs = dm.freeRefs[:n-1]
+		dm.refs[dmv.ref] = dmv
+	} else {
+		dmv.ref = valueRef(len(dm.refs))
+		dm.refs = append(dm.refs, dmv)
+	}
+	dm.data[dmv.key] = dmv
+	dm.size += int(dmv.size)
+}
+
+// get returns the value for the reference. It returns nil for the zero
+// valueRef.
+func (dm *dataMap) get(ref valueRef) *dataMapValue {
+	return dm.refs[ref]
+}
+
+// load returns the data for the reference. It returns nil for the zero
+// valueRef.
+func (dm *dataMap) load(ref valueRef) mmdbtype.DataType {
+	if ref == 0 {
+		return nil
+	}
+	return dm.refs[ref].load()
+}
+
+// snapshot returns a copy of the references to the values. The copy may
+// only be used to get and load values. The values in a valueStore are
+// decoded into the copy so that it does not depend on the store, which is
+// closed with the tree.
+func (dm *dataMap) snapshot() *dataMap {
+	refs := make([]*dataMapValue, len(dm.refs))
+	for i, v := range dm.refs {
+		if v != nil && v.encoded != nil {
+			v = &dataMapValue{
+				data: v.load(),
+				key:  v.key,
+				size: v.size,
+				ref:  v.ref,
+			}
+		}
+		refs[i] = v
+	}
+	return &dataMap{refs: refs}
+}
+
+// retain adds a reference to the value.
+func (dm *dataMap) retain(ref valueRef) {
+	dm.refs[ref].refCount++
+}
+
 // remove removes a reference to the value. If the reference count
-// drops to zero, the value is removed from the dataMap.
-func (dm *dataMap) remove(v *dataMapValue) {
+// drops to zero, the value is removed from the dataMap and its reference
+// may be reused.
+func (dm *dataMap) remove(ref valueRef) {
 	// This is here mostly so that we don't have to guard against it
 	// elsewhere.
-	if v == nil {
+	if ref == 0 {
 		return
 	}
+	v := dm.refs[ref]
 	v.refCount--
 
 	if v.refCount == 0 {
 		delete(dm.data, v.key)
+		dm.size -= int(v.size)
+		dm.refs[ref] = nil
+		dm.freeRefs = append(dm.freeRefs, ref)
 	}
 }
+
+// adopt moves a reference to a value from another dataMap, src, to this
+// dataMap and returns the reference to the value in this dataMap.
+func (dm *dataMap) adopt(src *dataMap, ref valueRef) valueRef {
+	v := src.get(ref)
+	dmv, ok := dm.data[v.key]
+	if !ok {
+		dmv = &dataMapValue{
+			key:  v.key,
+			data: v.load(),
+			size: v.size,
+		}
+		dm.add(dmv)
+	}
+	dmv.refCount++
+	src.remove(ref)
+	return dmv.ref
+}
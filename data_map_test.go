@@ -22,27 +22,98 @@ func TestDataMap(t *testing.T) {
 			data: v,
 			key: "\x87\x02\xf53\x8b\x96\xfdǻQ\x97\x9c\xe2\xcc\\\xda\xf2\xb1\xd7" +
 				"\xc1L\xc5l\xfd\x83\xfc\x97\xd6\x03\xf5\xedr",
+			size:     5,
+			ref:      1,
 			refCount: 1,
 		},
 		dmv,
 	)
+	assert.Equal(t, 5, dm.size)
 
 	mapDMV := dm.data[dmv.key]
 
 	assert.Equal(t, dmv, mapDMV)
+	assert.Same(t, dmv, dm.get(dmv.ref))
 
 	dmv, err = dm.store(v)
 	require.NoError(t, err)
 
 	assert.Equal(t, uint32(2), dmv.refCount, "refCount incremented on store")
 
-	dm.remove(dmv)
+	dm.remove(dmv.ref)
 
 	mapDMV = dm.data[dmv.key]
 
 	assert.Equal(t, uint32(1), mapDMV.refCount, "refCount decremented on remove")
 
-	dm.remove(dmv)
+	dm.remove(dmv.ref)
 	_, ok := dm.data[dmv.key]
 	assert.False(t, ok, "map value removed when refCount drops to 0")
+	assert.Zero(t, dm.size, "size decremented when value removed")
+	assert.Nil(t, dm.get(dmv.ref), "reference cleared when value removed")
+
+	other, err := dm.store(mmdbtype.String("other"))
+	require.NoError(t, err)
+	assert.Equal(t, dmv.ref, other.ref, "reference of removed value reused")
+	assert.Equal(t, other.data, dm.load(other.ref))
+}
+
+func TestDataMapIdentityCache(t *testing.T) {
+	v := mmdbtype.Map{"a": mmdbtype.String("b")}
+
+	dm := newDataMap()
+
+	dmv1, err := dm.store(v)
+	require.NoError(t, err)
+
+	dmv2, err := dm.store(v)
+	require.NoError(t, err)
+
+	assert.Same(t, dmv1, dmv2)
+	assert.Equal(t, uint32(2), dmv1.refCount)
+
+	dm.remove(dmv1.ref)
+	dm.remove(dmv2.ref)
+	assert.Empty(t, dm.data)
+
+	dmv3, err := dm.store(v)
+	require.NoError(t, err)
+	assert.NotSame(t, dmv1, dmv3, "a removed value is not reused from the cache")
+	assert.Equal(t, uint32(1), dmv3.refCount)
+	assert.Len(t, dm.data, 1)
+
+	other := mmdbtype.Slice{mmdbtype.String("c")}
+	_, err = dm.store(other)
+	require.NoError(t, err)
+
+	dmv4, err := dm.store(v)
+	require.NoError(t, err)
+	assert.Same(t, dmv3, dmv4, "the cache is not limited to the last value")
+	assert.Equal(t, uint32(2), dmv3.refCount)
+
+	for i := 0; i < 2*maxIdentities; i++ {
+		_, err := dm.store(mmdbtype.Map{"i": mmdbtype.Int32(i)})
+		require.NoError(t, err)
+	}
+	assert.LessOrEqual(t, len(dm.identities), maxIdentities)
+}
+
+func TestDataMapCloneValues(t *testing.T) {
+	v := mmdbtype.Map{"a": mmdbtype.String("b")}
+
+	dm := newDataMap()
+	dm.cloneValues = true
+
+	dmv1, err := dm.store(v)
+	require.NoError(t, err)
+
+	v["a"] = mmdbtype.String("c")
+
+	assert.Equal(t, mmdbtype.Map{"a": mmdbtype.String("b")}, dmv1.data, "stored value is not modified")
+
+	dmv2, err := dm.store(v)
+	require.NoError(t, err)
+
+	assert.NotSame(t, dmv1, dmv2, "modified value is stored separately")
+	assert.Equal(t, mmdbtype.Map{"a": mmdbtype.String("c")}, dmv2.data)
 }
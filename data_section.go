@@ -1,7 +1,14 @@
 package mmdbwriter
 
 import (
+	"bufio"
 	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
 
 	"github.com/maxmind/mmdbwriter/mmdbtype"
 )
@@ -12,16 +19,46 @@ type writtenType struct {
 }
 
 type dataWriter struct {
-	*bytes.Buffer
+	dataBuffer
 	dataMap     *dataMap
 	offsets     map[dataMapKey]writtenType
 	keyWriter   *keyWriter
 	usePointers bool
+
+	// transform, if set, is applied to each record before it is written.
+	transform func(mmdbtype.DataType) mmdbtype.DataType
+
+	// annotate, if set, adds fields derived from the network to each
+	// record. The annotated records are deduplicated by their own keys.
+	annotate func(v mmdbtype.DataType, ip net.IP, prefixLen int) mmdbtype.DataType
+
+	// keyRanks, if set, is the position of the keys to write before the
+	// other keys of a map. See Options.MapKeyOrder.
+	keyRanks map[string]int
+}
+
+var _ mmdbtype.KeyOrderer = (*dataWriter)(nil)
+
+// OrderKeys moves the keys in keyRanks to the front, keeping the other keys
+// in their sorted order.
+func (dw *dataWriter) OrderKeys(keys []string) {
+	if dw.keyRanks == nil {
+		return
+	}
+	rank := func(key string) int {
+		if r, ok := dw.keyRanks[key]; ok {
+			return r
+		}
+		return len(dw.keyRanks)
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		return rank(keys[i]) < rank(keys[j])
+	})
 }
 
 func newDataWriter(dataMap *dataMap, usePointers bool) *dataWriter {
 	return &dataWriter{
-		Buffer:      &bytes.Buffer{},
+		dataBuffer:  &bytes.Buffer{},
 		dataMap:     dataMap,
 		offsets:     map[dataMapKey]writtenType{},
 		keyWriter:   newKeyWriter(),
@@ -35,8 +72,13 @@ func (dw *dataWriter) maybeWrite(value *dataMapValue) (int, error) {
 		return int(written.pointer), nil
 	}
 
+	data := value.load()
+	if dw.transform != nil {
+		data = dw.transform(data)
+	}
+
 	offset := dw.Len()
-	size, err := value.data.WriteTo(dw)
+	size, err := data.WriteTo(dw)
 	if err != nil {
 		return 0, err
 	}
@@ -51,8 +93,40 @@ func (dw *dataWriter) maybeWrite(value *dataMapValue) (int, error) {
 	return int(written.pointer), nil
 }
 
+// writeRecord writes the value of the record for the network, if it has not
+// been written already, and returns its offset.
+func (dw *dataWriter) writeRecord(value *dataMapValue, ip net.IP, prefixLen int) (int, error) {
+	if dw.annotate == nil {
+		return dw.maybeWrite(value)
+	}
+
+	data := dw.annotate(value.load(), ip, prefixLen)
+	keyBytes, _, err := dw.keyWriter.key(data)
+	if err != nil {
+		return 0, err
+	}
+	key := dataMapKey(keyBytes)
+	if written, ok := dw.offsets[key]; ok {
+		return int(written.pointer), nil
+	}
+
+	if dw.transform != nil {
+		data = dw.transform(data)
+	}
+	offset := dw.Len()
+	size, err := data.WriteTo(dw)
+	if err != nil {
+		return 0, err
+	}
+	dw.offsets[key] = writtenType{
+		pointer: mmdbtype.Pointer(offset),
+		size:    size,
+	}
+	return offset, nil
+}
+
 func (dw *dataWriter) WriteOrWritePointer(t mmdbtype.DataType) (int64, error) {
-	keyBytes, err := dw.keyWriter.key(t)
+	keyBytes, _, err := dw.keyWriter.key(t)
 	if err != nil {
 		return 0, err
 	}
@@ -90,3 +164,110 @@ func (dw *dataWriter) WriteOrWritePointer(t mmdbtype.DataType) (int64, error) {
 	}
 	return size, nil
 }
+
+// dataBuffer accumulates the data section. The offsets of the values are
+// determined by the length of the buffer when they are written.
+type dataBuffer interface {
+	io.Writer
+	io.ByteWriter
+	io.StringWriter
+	io.WriterTo
+	Len() int
+}
+
+// countingBuffer is a dataBuffer that only counts the bytes written to it.
+// It is used when only the offsets of the values are needed.
+type countingBuffer struct {
+	n int
+}
+
+func (b *countingBuffer) Write(p []byte) (int, error) {
+	b.n += len(p)
+	return len(p), nil
+}
+
+func (b *countingBuffer) WriteByte(byte) error {
+	b.n++
+	return nil
+}
+
+func (b *countingBuffer) WriteString(s string) (int, error) {
+	b.n += len(s)
+	return len(s), nil
+}
+
+func (b *countingBuffer) Len() int {
+	return b.n
+}
+
+func (b *countingBuffer) WriteTo(io.Writer) (int64, error) {
+	return 0, errors.New("a countingBuffer cannot be written")
+}
+
+// spoolBuffer is a dataBuffer that spools the data to an
+// io.ReadWriteSeeker.
+type spoolBuffer struct {
+	spool io.ReadWriteSeeker
+	*bufio.Writer
+	n int
+}
+
+func newSpoolBuffer(spool io.ReadWriteSeeker) *spoolBuffer {
+	b := &spoolBuffer{spool: spool}
+	b.Writer = bufio.NewWriter(countingWriter{b})
+	return b
+}
+
+// countingWriter writes to the spool and counts the bytes written.
+type countingWriter struct {
+	b *spoolBuffer
+}
+
+func (w countingWriter) Write(p []byte) (int, error) {
+	n, err := w.b.spool.Write(p)
+	w.b.n += n
+	return n, err
+}
+
+// Len returns the number of bytes written, including those still buffered.
+func (b *spoolBuffer) Len() int {
+	return b.n + b.Buffered()
+}
+
+// WriteTo writes the spooled data to w.
+func (b *spoolBuffer) WriteTo(w io.Writer) (int64, error) {
+	if err := b.Flush(); err != nil {
+		return 0, fmt.Errorf("flushing data section spool: %w", err)
+	}
+	if _, err := b.spool.Seek(0, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("seeking data section spool: %w", err)
+	}
+	return io.CopyN(w, b.spool, int64(b.n))
+}
+
+// TempFileSpool returns a function for Options.DataSectionSpool that
+// creates a temporary file in dir. If dir is empty, the default directory
+// for temporary files is used. The file is removed when the write
+// completes.
+func TempFileSpool(dir string) func() (io.ReadWriteSeeker, error) {
+	return func() (io.ReadWriteSeeker, error) {
+		f, err := os.CreateTemp(dir, "mmdbwriter-data-*")
+		if err != nil {
+			return nil, fmt.Errorf("creating data section spool: %w", err)
+		}
+		return &tempFile{f}, nil
+	}
+}
+
+// tempFile is a temporary file that is removed when it is closed.
+type tempFile struct {
+	*os.File
+}
+
+func (f *tempFile) Close() error {
+	err := f.File.Close()
+	if removeErr := os.Remove(f.Name()); err == nil {
+		err = removeErr
+	}
+	return err
+}
@@ -1,6 +1,12 @@
 package mmdbwriter
 
 import (
+	"bytes"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"os"
 	"testing"
 
 	"github.com/maxmind/mmdbwriter/mmdbtype"
@@ -33,3 +39,141 @@ func TestDisablingPointers(t *testing.T) {
 
 	assert.Less(t, pointerWriter.Len(), noPointerWriter.Len())
 }
+
+// This tests decoding the output of dataWriter, which uses pointers.
+func TestDecodeDataSection(t *testing.T) {
+	uint128 := mmdbtype.Uint128(*big.NewInt(1 << 60))
+	repeated := mmdbtype.String("a repeated string")
+	value := mmdbtype.Map{
+		"array":   mmdbtype.Slice{repeated, repeated, repeated},
+		"boolean": mmdbtype.Bool(true),
+		"bytes":   mmdbtype.Bytes{0, 0, 0, 0x2a},
+		"double":  mmdbtype.Float64(42.123456),
+		"float":   mmdbtype.Float32(1.1),
+		"int32":   mmdbtype.Int32(-268435456),
+		"map":     mmdbtype.Map{"nested": repeated},
+		"uint128": &uint128,
+		"uint16":  mmdbtype.Uint16(100),
+		"uint32":  mmdbtype.Uint32(1 << 28),
+		"uint64":  mmdbtype.Uint64(1 << 60),
+	}
+
+	dw := newDataWriter(newDataMap(), true)
+	_, err := dw.WriteOrWritePointer(value)
+	require.NoError(t, err)
+
+	b := dw.dataBuffer.(*bytes.Buffer).Bytes()
+	decoded, offset, err := mmdbtype.DecodeAt(b, 0)
+	require.NoError(t, err)
+
+	assert.Equal(t, dw.Len(), offset)
+	assert.True(t, value.Equal(decoded), "decoded value equals the original")
+
+	_, _, err = mmdbtype.DecodeAt(b[:10], 0)
+	assert.EqualError(t, err, "unexpected end of data")
+}
+
+func TestDataSectionSpool(t *testing.T) {
+	build := func(spool func() (io.ReadWriteSeeker, error)) *Tree {
+		tree, err := New(Options{ValidateRecordSize: true, DataSectionSpool: spool})
+		require.NoError(t, err)
+		for i, network := range []string{"1.1.1.0/24", "2.0.0.0/8", "2600::/32"} {
+			_, ipNet, err := net.ParseCIDR(network)
+			require.NoError(t, err)
+			require.NoError(t, tree.Insert(ipNet, mmdbtype.Map{
+				"i":    mmdbtype.Int32(i),
+				"name": mmdbtype.String("network"),
+			}))
+		}
+		return tree
+	}
+
+	expected := &bytes.Buffer{}
+	_, err := build(nil).WriteTo(expected)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	actual := &bytes.Buffer{}
+	n, err := build(TempFileSpool(dir)).WriteTo(actual)
+	require.NoError(t, err)
+	assert.Equal(t, int64(actual.Len()), n)
+	assert.Equal(t, expected.Bytes(), actual.Bytes())
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "the spool file is removed")
+
+	errSpool := errors.New("spool failed")
+	_, err = build(func() (io.ReadWriteSeeker, error) {
+		return nil, errSpool
+	}).WriteTo(&bytes.Buffer{})
+	assert.ErrorIs(t, err, errSpool)
+}
+
+func TestMapKeyOrder(t *testing.T) {
+	record := mmdbtype.Map{
+		"a": mmdbtype.Uint16(1),
+		"c": mmdbtype.Uint16(2),
+		"z": mmdbtype.Map{"y": mmdbtype.Bool(true), "c": mmdbtype.Bool(false)},
+	}
+	encode := func(values ...mmdbtype.DataType) []byte {
+		var b []byte
+		for _, v := range values {
+			encoded, err := mmdbtype.Encode(v)
+			require.NoError(t, err)
+			b = append(b, encoded...)
+		}
+		return b
+	}
+
+	tests := []struct {
+		name     string
+		order    []string
+		expected []byte
+	}{
+		{
+			name: "sorted",
+			expected: bytes.Join([][]byte{
+				{0xE3},
+				encode(
+					mmdbtype.String("a"), mmdbtype.Uint16(1),
+					mmdbtype.String("c"), mmdbtype.Uint16(2),
+					mmdbtype.String("z"), record["z"],
+				),
+			}, nil),
+		},
+		{
+			name:  "ordered",
+			order: []string{"z", "c"},
+			// The keys of the nested map are not in MapKeyOrder, so they
+			// stay sorted.
+			expected: bytes.Join([][]byte{
+				{0xE3},
+				encode(mmdbtype.String("z")),
+				{0xE2},
+				encode(
+					mmdbtype.String("c"), mmdbtype.Bool(false),
+					mmdbtype.String("y"), mmdbtype.Bool(true),
+					mmdbtype.String("c"), mmdbtype.Uint16(2),
+					mmdbtype.String("a"), mmdbtype.Uint16(1),
+				),
+			}, nil),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tree, err := New(Options{MapKeyOrder: test.order})
+			require.NoError(t, err)
+			require.NoError(t, tree.Insert(mustNetwork(t, "1.1.1.0/24"), record))
+			require.NoError(t, tree.Insert(mustNetwork(t, "2.2.2.0/24"), record.Copy()))
+
+			buf := &bytes.Buffer{}
+			_, err = tree.WriteTo(buf)
+			require.NoError(t, err)
+			assert.Equal(t, 1, bytes.Count(buf.Bytes(), test.expected), "the record is written once")
+		})
+	}
+
+	_, err := New(Options{MapKeyOrder: []string{"a", "b", "a"}})
+	assert.EqualError(t, err, `duplicate key in MapKeyOrder: "a"`)
+}
@@ -0,0 +1,142 @@
+// Package decimalip converts between IP addresses and their representation
+// as decimal integers, e.g., "16777216" for 1.0.0.0, which is common in CSV
+// feeds. IPv4 addresses are 32-bit integers and IPv6 addresses are 128-bit
+// integers.
+package decimalip
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"net"
+	"net/netip"
+	"strconv"
+)
+
+var maxIPv6 = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 128), big.NewInt(1))
+
+// ParseIPv4 parses the decimal integer as an IPv4 address, returning it in
+// its 4-byte form.
+func ParseIPv4(s string) (net.IP, error) {
+	if !isDigits(s) {
+		return nil, fmt.Errorf("invalid decimal IPv4 address: %q", s)
+	}
+	n, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid decimal IPv4 address: %q", s)
+	}
+	return net.IPv4(byte(n>>24), byte(n>>16), byte(n>>8), byte(n)).To4(), nil
+}
+
+// ParseIPv6 parses the decimal integer as an IPv6 address.
+func ParseIPv6(s string) (net.IP, error) {
+	n, ok := parseBig(s)
+	if !ok {
+		return nil, fmt.Errorf("invalid decimal IPv6 address: %q", s)
+	}
+	return fill(n, net.IPv6len), nil
+}
+
+// Parse parses the decimal integer as an IP address. Integers that fit in
+// 32 bits are returned as 4-byte IPv4 addresses and larger integers as
+// IPv6 addresses. Use ParseIPv6 to parse small integers as IPv6 addresses,
+// e.g., ::1.
+func Parse(s string) (net.IP, error) {
+	n, ok := parseBig(s)
+	if !ok {
+		return nil, fmt.Errorf("invalid decimal IP address: %q", s)
+	}
+	if n.IsUint64() && n.Uint64() <= math.MaxUint32 {
+		return fill(n, net.IPv4len), nil
+	}
+	return fill(n, net.IPv6len), nil
+}
+
+// ParseAddr is the same as Parse except that it returns a netip.Addr.
+func ParseAddr(s string) (netip.Addr, error) {
+	ip, err := Parse(s)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	addr, _ := netip.AddrFromSlice(ip)
+	return addr, nil
+}
+
+// ParseRange parses the decimal integers as the first and last IP
+// addresses of a range. If to fits in 32 bits, both are returned as IPv4
+// addresses. Otherwise, both are returned as IPv6 addresses. An error is
+// returned if from is greater than to.
+func ParseRange(from, to string) (net.IP, net.IP, error) {
+	fromN, ok := parseBig(from)
+	if !ok {
+		return nil, nil, fmt.Errorf("invalid decimal IP address: %q", from)
+	}
+	toN, ok := parseBig(to)
+	if !ok {
+		return nil, nil, fmt.Errorf("invalid decimal IP address: %q", to)
+	}
+	if fromN.Cmp(toN) > 0 {
+		return nil, nil, fmt.Errorf("the start of the range (%s) is greater than the end (%s)", from, to)
+	}
+
+	size := net.IPv6len
+	if toN.IsUint64() && toN.Uint64() <= math.MaxUint32 {
+		size = net.IPv4len
+	}
+	return fill(fromN, size), fill(toN, size), nil
+}
+
+// ParseAddrRange is the same as ParseRange except that it returns
+// netip.Addrs.
+func ParseAddrRange(from, to string) (netip.Addr, netip.Addr, error) {
+	start, end, err := ParseRange(from, to)
+	if err != nil {
+		return netip.Addr{}, netip.Addr{}, err
+	}
+	startAddr, _ := netip.AddrFromSlice(start)
+	endAddr, _ := netip.AddrFromSlice(end)
+	return startAddr, endAddr, nil
+}
+
+// Format returns the IP address as a decimal integer. 4-byte IPv4
+// addresses are formatted as 32-bit integers and other addresses as 128-bit
+// integers. It returns an empty string if ip is not a valid address.
+func Format(ip net.IP) string {
+	if len(ip) != net.IPv4len && len(ip) != net.IPv6len {
+		return ""
+	}
+	return new(big.Int).SetBytes(ip).String()
+}
+
+// parseBig parses s as a decimal integer that fits in 128 bits.
+func parseBig(s string) (*big.Int, bool) {
+	if !isDigits(s) {
+		return nil, false
+	}
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok || n.Cmp(maxIPv6) > 0 {
+		return nil, false
+	}
+	return n, true
+}
+
+// isDigits returns true if s is a non-empty string of decimal digits.
+// Unlike the strconv and math/big parsers, it does not allow signs or
+// underscores.
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func fill(n *big.Int, size int) net.IP {
+	ip := make(net.IP, size)
+	n.FillBytes(ip)
+	return ip
+}
@@ -0,0 +1,96 @@
+package decimalip
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{input: "0", expected: "0.0.0.0"},
+		{input: "16777216", expected: "1.0.0.0"},
+		{input: "4294967295", expected: "255.255.255.255"},
+		{input: "4294967296", expected: "::1:0:0"},
+		{input: "281470698586112", expected: "::ffff:1.1.0.0"},
+		{input: "58569107296622255421594597096899477504", expected: "2c0f:fff0::"},
+		{
+			input:    "340282366920938463463374607431768211455",
+			expected: "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			ip, err := Parse(test.input)
+			require.NoError(t, err)
+			if ipv4 := ip.To4(); ipv4 != nil && len(ip) == net.IPv4len {
+				assert.Equal(t, test.expected, ipv4.String())
+			} else {
+				require.Len(t, ip, net.IPv6len)
+				addr, _ := netip.AddrFromSlice(ip)
+				assert.Equal(t, test.expected, addr.String())
+			}
+			assert.Equal(t, test.input, Format(ip))
+
+			addr, err := ParseAddr(test.input)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, addr.String())
+		})
+	}
+
+	for _, input := range []string{
+		"",
+		"-1",
+		"+1",
+		"1_000",
+		"1.0",
+		" 1",
+		"340282366920938463463374607431768211456",
+	} {
+		_, err := Parse(input)
+		assert.Error(t, err, input)
+	}
+}
+
+func TestParseIPv4AndIPv6(t *testing.T) {
+	ip, err := ParseIPv4("16843009")
+	require.NoError(t, err)
+	assert.Equal(t, net.IP{1, 1, 1, 1}, ip)
+
+	_, err = ParseIPv4("4294967296")
+	require.EqualError(t, err, `invalid decimal IPv4 address: "4294967296"`)
+
+	ip, err = ParseIPv6("1")
+	require.NoError(t, err)
+	assert.Equal(t, net.IPv6loopback, ip)
+	assert.Equal(t, "1", Format(ip))
+
+	_, err = ParseIPv6("x")
+	require.EqualError(t, err, `invalid decimal IPv6 address: "x"`)
+}
+
+func TestParseRange(t *testing.T) {
+	start, end, err := ParseRange("0", "16777215")
+	require.NoError(t, err)
+	assert.Equal(t, net.IP{0, 0, 0, 0}, start)
+	assert.Equal(t, net.IP{0, 255, 255, 255}, end)
+
+	start, end, err = ParseRange("0", "4294967296")
+	require.NoError(t, err)
+	assert.Len(t, start, net.IPv6len)
+	assert.Equal(t, "::1:0:0", end.String())
+
+	startAddr, endAddr, err := ParseAddrRange("16777216", "16777471")
+	require.NoError(t, err)
+	assert.Equal(t, netip.MustParseAddr("1.0.0.0"), startAddr)
+	assert.Equal(t, netip.MustParseAddr("1.0.0.255"), endAddr)
+
+	_, _, err = ParseRange("2", "1")
+	require.EqualError(t, err, "the start of the range (2) is greater than the end (1)")
+}
@@ -0,0 +1,227 @@
+package mmdbwriter
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+// DictionaryKey is the metadata key for the string dictionary when
+// Options.DictionaryFields is used. Its value is a map with two keys:
+//
+//   - "fields", an array of the dot-separated paths of the fields, and
+//   - "offset", the offset of the dictionary from the start of the data
+//     section, i.e., the byte after the data section separator.
+//
+// The dictionary itself is an array of strings in the data section. Each
+// of the fields holds a uint32 index into the array rather than a string.
+// Readers in other languages may decode the array at the offset with their
+// data section decoder and replace the indexes as Dictionary.Expand does.
+const DictionaryKey = "mmdbwriter_dictionary"
+
+// Dictionary is the string dictionary of a database written with
+// Options.DictionaryFields set. See DictionaryKey for the format.
+type Dictionary struct {
+	// Fields are the dot-separated paths to the fields that hold indexes
+	// into Values.
+	Fields []string
+
+	// Values are the strings in the dictionary. The most common strings
+	// come first so that their indexes are the smallest.
+	Values []string
+}
+
+// ReadDictionary reads the string dictionary of a database written with
+// Options.DictionaryFields set. The buf must contain the entire database,
+// e.g., as read with os.ReadFile. An error is returned if the database does
+// not have a dictionary.
+func ReadDictionary(buf []byte) (*Dictionary, error) {
+	metadata, err := readMetadata(buf)
+	if err != nil {
+		return nil, err
+	}
+	rawDictionary, ok := metadata[DictionaryKey].(mmdbtype.Map)
+	if !ok {
+		return nil, errors.New("the database does not have a dictionary")
+	}
+
+	d := &Dictionary{}
+	rawFields, ok := rawDictionary["fields"].(mmdbtype.Slice)
+	if !ok {
+		return nil, fmt.Errorf("unexpected type %T for the dictionary fields", rawDictionary["fields"])
+	}
+	for _, rawField := range rawFields {
+		field, ok := rawField.(mmdbtype.String)
+		if !ok {
+			return nil, fmt.Errorf("unexpected type %T in the dictionary fields", rawField)
+		}
+		d.Fields = append(d.Fields, string(field))
+	}
+
+	offset, ok := rawDictionary["offset"].(mmdbtype.Uint32)
+	if !ok {
+		return nil, fmt.Errorf("unexpected type %T for the dictionary offset", rawDictionary["offset"])
+	}
+	data, err := dataSection(buf, metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	rawValues, _, err := mmdbtype.DecodeAt(data, int(offset))
+	if err != nil {
+		return nil, fmt.Errorf("decoding the dictionary: %w", err)
+	}
+	values, ok := rawValues.(mmdbtype.Slice)
+	if !ok {
+		return nil, fmt.Errorf("unexpected type %T for the dictionary", rawValues)
+	}
+	d.Values = make([]string, 0, len(values))
+	for _, rawValue := range values {
+		v, ok := rawValue.(mmdbtype.String)
+		if !ok {
+			return nil, fmt.Errorf("unexpected type %T in the dictionary", rawValue)
+		}
+		d.Values = append(d.Values, string(v))
+	}
+	return d, nil
+}
+
+// Lookup returns the string for the index. The bool is false if the index
+// is not in the dictionary.
+func (d *Dictionary) Lookup(index uint64) (string, bool) {
+	if index >= uint64(len(d.Values)) {
+		return "", false
+	}
+	return d.Values[index], true
+}
+
+// Expand replaces the dictionary indexes in a record decoded into a
+// map[string]any with their strings. The record is modified in place.
+// Fields that are missing or that do not contain a valid index are left
+// unchanged.
+func (d *Dictionary) Expand(record map[string]any) {
+	for _, field := range d.Fields {
+		path := strings.Split(field, ".")
+		m := record
+		for _, key := range path[:len(path)-1] {
+			var ok bool
+			m, ok = m[key].(map[string]any)
+			if !ok {
+				break
+			}
+		}
+		if m == nil {
+			continue
+		}
+		last := path[len(path)-1]
+		index, ok := m[last].(uint64)
+		if !ok {
+			continue
+		}
+		if v, ok := d.Lookup(index); ok {
+			m[last] = v
+		}
+	}
+}
+
+// dictionaryEncoder replaces string values at the configured field paths
+// with indexes into a single dictionary shared by the fields.
+type dictionaryEncoder struct {
+	fields []string
+	paths  [][]mmdbtype.String
+	values mmdbtype.Slice
+	index  map[mmdbtype.String]int
+
+	// offset is the offset of the dictionary in the data section. It is
+	// set when the dictionary is written.
+	offset int
+}
+
+// newDictionaryEncoder builds the dictionary for the fields from the values
+// in the dataMap. The strings are ordered by the number of distinct records
+// they are in, most common first, and then by the strings themselves so
+// that the indexes are reproducible between builds.
+func newDictionaryEncoder(fields []string, dm *dataMap) (*dictionaryEncoder, error) {
+	e := &dictionaryEncoder{
+		fields: fields,
+		index:  map[mmdbtype.String]int{},
+	}
+	for _, field := range fields {
+		var path []mmdbtype.String
+		for _, key := range strings.Split(field, ".") {
+			path = append(path, mmdbtype.String(key))
+		}
+		e.paths = append(e.paths, path)
+	}
+
+	counts := map[mmdbtype.String]int{}
+	for _, dmv := range dm.data {
+		for _, path := range e.paths {
+			if s, ok := valueAtPath(dmv.load(), path); ok {
+				counts[s]++
+			}
+		}
+	}
+	if uint64(len(counts)) > math.MaxUint32+1 {
+		return nil, fmt.Errorf(
+			"the dictionary fields have %d distinct values; the maximum is %d",
+			len(counts),
+			uint64(math.MaxUint32+1),
+		)
+	}
+
+	values := make([]mmdbtype.String, 0, len(counts))
+	for v := range counts {
+		values = append(values, v)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if counts[values[i]] != counts[values[j]] {
+			return counts[values[i]] > counts[values[j]]
+		}
+		return values[i] < values[j]
+	})
+
+	e.values = make(mmdbtype.Slice, 0, len(values))
+	for i, v := range values {
+		e.values = append(e.values, v)
+		e.index[v] = i
+	}
+	return e, nil
+}
+
+// write writes the dictionary to the data section.
+func (e *dictionaryEncoder) write(dw *dataWriter) error {
+	e.offset = dw.Len()
+	if _, err := e.values.WriteTo(dw); err != nil {
+		return fmt.Errorf("writing the dictionary: %w", err)
+	}
+	return nil
+}
+
+// metadataValue returns the value to be written to the metadata under
+// DictionaryKey.
+func (e *dictionaryEncoder) metadataValue() mmdbtype.Map {
+	fields := make(mmdbtype.Slice, 0, len(e.fields))
+	for _, f := range e.fields {
+		fields = append(fields, mmdbtype.String(f))
+	}
+	return mmdbtype.Map{
+		"fields": fields,
+		"offset": mmdbtype.Uint32(e.offset),
+	}
+}
+
+// encode returns a copy of the value with the dictionary fields replaced by
+// their indexes. The value passed in is not modified.
+func (e *dictionaryEncoder) encode(v mmdbtype.DataType) mmdbtype.DataType {
+	for _, path := range e.paths {
+		v = replaceAtPath(v, path, func(s mmdbtype.String) mmdbtype.DataType {
+			return mmdbtype.Uint32(e.index[s])
+		})
+	}
+	return v
+}
@@ -0,0 +1,208 @@
+package mmdbwriter
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/oschwald/maxminddb-golang"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDictionaryFields(t *testing.T) {
+	tree, err := New(
+		Options{
+			DatabaseType:     "mmdbwriter-test",
+			DictionaryFields: []string{"organization", "isp.name"},
+			EnumFields:       []string{"connection_type"},
+		},
+	)
+	require.NoError(t, err)
+
+	records := map[string]mmdbtype.Map{
+		"1.1.1.0/24": {
+			"organization":    mmdbtype.String("Example Networks"),
+			"isp":             mmdbtype.Map{"name": mmdbtype.String("Example ISP")},
+			"connection_type": mmdbtype.String("Cable/DSL"),
+		},
+		"2.2.2.0/24": {
+			"organization":    mmdbtype.String("Example Networks"),
+			"isp":             mmdbtype.Map{"name": mmdbtype.String("Other ISP")},
+			"connection_type": mmdbtype.String("Corporate"),
+		},
+		"3.3.3.0/24": {
+			"organization": mmdbtype.String("Example Networks"),
+			"isp":          mmdbtype.Map{"name": mmdbtype.Uint32(7)},
+		},
+	}
+	for network, record := range records {
+		require.NoError(t, tree.Insert(mustNetwork(t, network), record))
+	}
+
+	_, value := tree.Get(net.ParseIP("1.1.1.1"))
+	assert.Equal(t, records["1.1.1.0/24"], value, "Get returns the original value")
+
+	buf := &bytes.Buffer{}
+	_, err = tree.WriteTo(buf)
+	require.NoError(t, err)
+
+	dictionary, err := ReadDictionary(buf.Bytes())
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		&Dictionary{
+			Fields: []string{"organization", "isp.name"},
+			Values: []string{"Example Networks", "Example ISP", "Other ISP"},
+		},
+		dictionary,
+	)
+
+	reader, err := maxminddb.FromBytes(buf.Bytes())
+	require.NoError(t, err)
+	var record map[string]any
+	require.NoError(t, reader.Lookup(net.ParseIP("2.2.2.2"), &record))
+	assert.Equal(
+		t,
+		map[string]any{
+			"organization":    uint64(0),
+			"isp":             map[string]any{"name": uint64(2)},
+			"connection_type": uint64(1),
+		},
+		record,
+	)
+
+	dictionary.Expand(record)
+	table, err := ReadEnumTable(buf.Bytes())
+	require.NoError(t, err)
+	table.Expand(record)
+	assert.Equal(
+		t,
+		map[string]any{
+			"organization":    "Example Networks",
+			"isp":             map[string]any{"name": "Other ISP"},
+			"connection_type": "Corporate",
+		},
+		record,
+	)
+
+	// Non-string values are left as is.
+	require.NoError(t, reader.Lookup(net.ParseIP("3.3.3.3"), &record))
+	assert.Equal(t, map[string]any{"name": uint64(7)}, record["isp"])
+
+	findings, err := Verify(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	assert.Empty(t, findings)
+}
+
+func TestDictionaryFieldsValidation(t *testing.T) {
+	_, err := New(Options{
+		DictionaryFields: []string{"organization"},
+		EnumFields:       []string{"organization"},
+	})
+	assert.EqualError(t, err, "organization is in both EnumFields and DictionaryFields")
+}
+
+func TestReadDictionaryMissing(t *testing.T) {
+	tree, err := New(Options{})
+	require.NoError(t, err)
+	require.NoError(t, tree.Insert(mustNetwork(t, "1.1.1.0/24"), mmdbtype.Map{"a": mmdbtype.String("b")}))
+
+	buf := &bytes.Buffer{}
+	_, err = tree.WriteTo(buf)
+	require.NoError(t, err)
+
+	_, err = ReadDictionary(buf.Bytes())
+	assert.EqualError(t, err, "the database does not have a dictionary")
+}
+
+func TestDictionaryAndEnumFieldsTemplated(t *testing.T) {
+	tree, err := New(
+		Options{
+			DatabaseType:     "mmdbwriter-test",
+			Description:      map[string]string{"en": "Test database"},
+			DictionaryFields: []string{"organization", "isp.name"},
+			EnumFields:       []string{"connection_type", "location.time_zone"},
+		},
+	)
+	require.NoError(t, err)
+
+	template := mmdbtype.Map{
+		"organization":    mmdbtype.String("Example Networks"),
+		"connection_type": mmdbtype.String("Cable/DSL"),
+		"location": mmdbtype.Templated{
+			Template: mmdbtype.Map{"time_zone": mmdbtype.String("Europe/Paris")},
+		},
+	}
+	records := map[string]mmdbtype.Templated{
+		"1.1.1.0/24": {
+			Template:  template,
+			Overrides: mmdbtype.Map{"isp": mmdbtype.Map{"name": mmdbtype.String("Example ISP")}},
+		},
+		"2.2.2.0/24": {
+			Template: template,
+			Overrides: mmdbtype.Map{
+				"connection_type": mmdbtype.String("Corporate"),
+				"isp":             mmdbtype.Map{"name": mmdbtype.String("Other ISP")},
+			},
+		},
+	}
+	for network, record := range records {
+		require.NoError(t, tree.Insert(mustNetwork(t, network), record))
+	}
+
+	buf := &bytes.Buffer{}
+	_, err = tree.WriteTo(buf)
+	require.NoError(t, err)
+
+	dictionary, err := ReadDictionary(buf.Bytes())
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		[]string{"Example Networks", "Example ISP", "Other ISP"},
+		dictionary.Values,
+	)
+	table, err := ReadEnumTable(buf.Bytes())
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		EnumTable{
+			"connection_type":    {"Cable/DSL", "Corporate"},
+			"location.time_zone": {"Europe/Paris"},
+		},
+		table,
+	)
+
+	reader, err := maxminddb.FromBytes(buf.Bytes())
+	require.NoError(t, err)
+	var record map[string]any
+	require.NoError(t, reader.Lookup(net.ParseIP("2.2.2.2"), &record))
+	assert.Equal(
+		t,
+		map[string]any{
+			"organization":    uint64(0),
+			"isp":             map[string]any{"name": uint64(2)},
+			"connection_type": uint64(1),
+			"location":        map[string]any{"time_zone": uint64(0)},
+		},
+		record,
+	)
+
+	dictionary.Expand(record)
+	table.Expand(record)
+	assert.Equal(
+		t,
+		map[string]any{
+			"organization":    "Example Networks",
+			"isp":             map[string]any{"name": "Other ISP"},
+			"connection_type": "Corporate",
+			"location":        map[string]any{"time_zone": "Europe/Paris"},
+		},
+		record,
+	)
+
+	findings, err := Verify(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	assert.Empty(t, findings)
+}
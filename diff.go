@@ -0,0 +1,82 @@
+package mmdbwriter
+
+import "errors"
+
+// Equal returns whether t and other have the same data for every IP
+// address, regardless of how the data was inserted or how the networks are
+// split in the search trees. Trees with different IP versions or IPv4
+// placements are never equal. See DiffCount.
+//
+// The trees must not be modified during the comparison.
+func (t *Tree) Equal(other *Tree) bool {
+	n, err := t.DiffCount(other)
+	return err == nil && n == 0
+}
+
+// DiffCount returns the number of networks whose data differs between t
+// and other. The networks are those of the records of either tree, split
+// where the other tree has more specific records, so adjacent differing
+// networks are counted separately. Networks without data, including
+// reserved and aliased networks, are considered to have the same data. The
+// values are compared by their encoding, as when deduplicating them.
+//
+// An error is returned if the trees have different IP versions or IPv4
+// placements, as their networks cannot be compared directly.
+//
+// The trees must not be modified during the comparison.
+func (t *Tree) DiffCount(other *Tree) (int, error) {
+	if t.treeDepth != other.treeDepth {
+		return 0, errors.New("cannot compare trees with different IP versions")
+	}
+	if t.treeDepth == 128 && t.ipv4Placement != other.ipv4Placement {
+		return 0, errors.New("cannot compare trees with different IPv4 placements")
+	}
+	d := differ{a: t.dataMap, b: other.dataMap}
+	return d.countDiffs(
+		record{node: t.root, recordType: recordTypeNode},
+		record{node: other.root, recordType: recordTypeNode},
+	), nil
+}
+
+// differ compares the records of two trees, whose values are in the
+// dataMaps a and b.
+type differ struct {
+	a, b *dataMap
+}
+
+// countDiffs returns the number of networks within the records whose data
+// differs.
+func (d differ) countDiffs(a, b record) int {
+	aNode := a.recordType == recordTypeNode || a.recordType == recordTypeFixedNode
+	bNode := b.recordType == recordTypeNode || b.recordType == recordTypeFixedNode
+	if !aNode && !bNode {
+		if d.sameData(a, b) {
+			return 0
+		}
+		return 1
+	}
+
+	n := 0
+	for i := 0; i < 2; i++ {
+		ac, bc := a, b
+		if aNode {
+			ac = a.node.children[i]
+		}
+		if bNode {
+			bc = b.node.children[i]
+		}
+		n += d.countDiffs(ac, bc)
+	}
+	return n
+}
+
+// sameData returns whether the records, which are not nodes, have the
+// same data.
+func (d differ) sameData(a, b record) bool {
+	aData := a.recordType == recordTypeData
+	bData := b.recordType == recordTypeData
+	if !aData || !bData {
+		return aData == bData
+	}
+	return d.a.get(a.value).key == d.b.get(b.value).key
+}
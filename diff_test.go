@@ -0,0 +1,68 @@
+package mmdbwriter
+
+import (
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/inserter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTreeEqual(t *testing.T) {
+	a, err := New(Options{})
+	require.NoError(t, err)
+	insert(t, a, "1.1.1.0/24", "a")
+	insert(t, a, "2003::/16", "b")
+
+	// The same data inserted in a different order and with different
+	// networks.
+	b, err := New(Options{})
+	require.NoError(t, err)
+	insert(t, b, "2003::/17", "b")
+	insert(t, b, "2003:8000::/17", "b")
+	for _, network := range []string{"1.1.1.0/26", "1.1.1.64/26", "1.1.1.128/25"} {
+		insert(t, b, network, "a")
+	}
+	insert(t, b, "3.3.3.0/24", "c")
+	require.NoError(t, b.InsertFunc(mustNetwork(t, "3.3.3.0/24"), inserter.Remove))
+
+	n, err := a.DiffCount(b)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+	assert.True(t, a.Equal(b))
+	assert.True(t, b.Equal(a))
+
+	// This changes the data of 1.1.1.0/25 and 1.1.1.192/26, which are
+	// counted separately, and adds a network.
+	insert(t, b, "1.1.1.0/25", "changed")
+	insert(t, b, "1.1.1.192/26", "changed")
+	insert(t, b, "4.4.4.0/24", "new")
+	n, err = a.DiffCount(b)
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+	assert.False(t, a.Equal(b))
+
+	n, err = b.DiffCount(a)
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+}
+
+func TestTreeDiffCountErrors(t *testing.T) {
+	ipv6, err := New(Options{})
+	require.NoError(t, err)
+	ipv4, err := New(Options{IPVersion: 4, RecordSize: 24})
+	require.NoError(t, err)
+	mapped, err := New(Options{IPv4Placement: IPv4PlacementMapped})
+	require.NoError(t, err)
+
+	_, err = ipv6.DiffCount(ipv4)
+	assert.EqualError(t, err, "cannot compare trees with different IP versions")
+	assert.False(t, ipv6.Equal(ipv4))
+
+	_, err = ipv6.DiffCount(mapped)
+	assert.EqualError(t, err, "cannot compare trees with different IPv4 placements")
+
+	require.NoError(t, ipv4.Insert(mustNetwork(t, "1.1.1.0/24"), mmdbtype.String("a")))
+	assert.True(t, ipv4.Equal(ipv4))
+}
@@ -0,0 +1,148 @@
+package mmdbwriter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+)
+
+// maxDOTValueLength is the maximum number of characters of a data record
+// that we include in a DOT leaf label. Larger values are truncated.
+const maxDOTValueLength = 40
+
+// WriteDOT writes a Graphviz DOT representation of the search tree to w. This
+// is intended for debugging and for illustrating the structure of the tree.
+//
+// Only nodes less than maxDepth bits deep are expanded. Deeper subtrees are
+// collapsed into a single placeholder. If maxDepth is less than or equal to
+// zero, the entire tree is rendered. This is rarely readable for anything
+// but very small trees.
+//
+// The tree will be finalized if it has not been already so that the node
+// numbers match those in the written database. An error is returned if the
+// finalization fails.
+func (t *Tree) WriteDOT(w io.Writer, maxDepth int) error {
+	if t.nodeCount == 0 {
+		if err := t.finalize(); err != nil {
+			return err
+		}
+	}
+	if maxDepth <= 0 {
+		maxDepth = t.treeDepth
+	}
+
+	buf := bufio.NewWriter(w)
+	dw := &dotWriter{
+		w:         buf,
+		maxDepth:  maxDepth,
+		treeDepth: t.treeDepth,
+		dataMap:   t.dataMap,
+	}
+
+	dw.printf("digraph mmdb {\n")
+	dw.printf("\tnode [fontname=\"monospace\"];\n")
+	dw.writeNode(t.root, make(net.IP, t.treeDepth/8), 0)
+	dw.printf("}\n")
+
+	if dw.err != nil {
+		return fmt.Errorf("writing DOT: %w", dw.err)
+	}
+	if err := buf.Flush(); err != nil {
+		return fmt.Errorf("flushing DOT: %w", err)
+	}
+	return nil
+}
+
+type dotWriter struct {
+	w         *bufio.Writer
+	err       error
+	maxDepth  int
+	treeDepth int
+	dataMap   *dataMap
+}
+
+func (dw *dotWriter) printf(format string, args ...any) {
+	if dw.err != nil {
+		return
+	}
+	_, dw.err = fmt.Fprintf(dw.w, format, args...)
+}
+
+func (dw *dotWriter) writeNode(n *node, ip net.IP, depth int) {
+	nodeID := fmt.Sprintf("n%d", n.nodeNum)
+	dw.printf(
+		"\t%s [shape=circle, label=\"%d\"];\n",
+		nodeID,
+		n.nodeNum,
+	)
+
+	for i := 0; i < 2; i++ {
+		child := n.children[i]
+
+		childIP := make(net.IP, len(ip))
+		copy(childIP, ip)
+		if i == 1 {
+			childIP[depth/8] |= 1 << (7 - (depth % 8))
+		}
+		childDepth := depth + 1
+
+		switch child.recordType {
+		case recordTypeNode, recordTypeFixedNode:
+			if childDepth >= dw.maxDepth {
+				truncatedID := fmt.Sprintf("n%d_truncated", child.node.nodeNum)
+				dw.printf(
+					"\t%s [shape=plaintext, label=\"%s\\n...\"];\n",
+					truncatedID,
+					dw.network(childIP, childDepth),
+				)
+				dw.printf("\t%s -> %s [label=\"%d\"];\n", nodeID, truncatedID, i)
+				continue
+			}
+			dw.printf("\t%s -> n%d [label=\"%d\"];\n", nodeID, child.node.nodeNum, i)
+			dw.writeNode(child.node, childIP, childDepth)
+		case recordTypeAlias:
+			// The aliased node is rendered where it is a fixed node in the
+			// tree. We only draw an edge to it here.
+			dw.printf(
+				"\t%s -> n%d [label=\"%d\", style=dashed];\n",
+				nodeID,
+				child.node.nodeNum,
+				i,
+			)
+		default:
+			leafID := fmt.Sprintf("%s_%d", nodeID, i)
+			dw.printf(
+				"\t%s [shape=box, label=\"%s\\n%s\"];\n",
+				leafID,
+				dw.network(childIP, childDepth),
+				dotEscape(dw.recordLabel(child)),
+			)
+			dw.printf("\t%s -> %s [label=\"%d\"];\n", nodeID, leafID, i)
+		}
+	}
+}
+
+func (dw *dotWriter) network(ip net.IP, depth int) string {
+	return (&net.IPNet{IP: ip, Mask: net.CIDRMask(depth, dw.treeDepth)}).String()
+}
+
+func (dw *dotWriter) recordLabel(r record) string {
+	switch r.recordType {
+	case recordTypeData:
+		v := []rune(fmt.Sprintf("%v", dw.dataMap.load(r.value)))
+		if len(v) > maxDOTValueLength {
+			return string(v[:maxDOTValueLength]) + "..."
+		}
+		return string(v)
+	case recordTypeReserved:
+		return "(reserved)"
+	default:
+		return "(empty)"
+	}
+}
+
+func dotEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
@@ -0,0 +1,51 @@
+package mmdbwriter
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteDOT(t *testing.T) {
+	tree, err := New(
+		Options{
+			IPVersion:               4,
+			IncludeReservedNetworks: true,
+		},
+	)
+	require.NoError(t, err)
+
+	_, network, err := net.ParseCIDR("128.0.0.0/2")
+	require.NoError(t, err)
+	require.NoError(t, tree.Insert(network, mmdbtype.String("a \"quoted\" value")))
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, tree.WriteDOT(buf, 0))
+
+	assert.Equal(
+		t,
+		`digraph mmdb {
+	node [fontname="monospace"];
+	n0 [shape=circle, label="0"];
+	n0_0 [shape=box, label="0.0.0.0/1\n(empty)"];
+	n0 -> n0_0 [label="0"];
+	n0 -> n1 [label="1"];
+	n1 [shape=circle, label="1"];
+	n1_0 [shape=box, label="128.0.0.0/2\na \"quoted\" value"];
+	n1 -> n1_0 [label="0"];
+	n1_1 [shape=box, label="192.0.0.0/2\n(empty)"];
+	n1 -> n1_1 [label="1"];
+}
+`,
+		buf.String(),
+	)
+
+	buf.Reset()
+	require.NoError(t, tree.WriteDOT(buf, 1))
+	assert.Contains(t, buf.String(), "n1_truncated [shape=plaintext, label=\"128.0.0.0/1\\n...\"];")
+	assert.NotContains(t, buf.String(), "n1_0")
+}
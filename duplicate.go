@@ -0,0 +1,160 @@
+package mmdbwriter
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+
+	"github.com/maxmind/mmdbwriter/inserter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+// DuplicatePolicy determines how an insert of exactly the same network as
+// an earlier insert is handled. Unlike OverwritePolicy, it does not apply
+// to inserts of networks that only overlap earlier inserts. Removals with
+// inserter.Remove are not inserts of data, so they are neither checked nor
+// counted as inserts of the network.
+type DuplicatePolicy int
+
+const (
+	// DuplicateMerge inserts the duplicate like any other insert, calling
+	// the inserter function with the existing value. With the default
+	// inserter, inserter.ReplaceWith, the last insert wins. This is the
+	// default.
+	DuplicateMerge DuplicatePolicy = iota
+
+	// DuplicateReplace replaces the data for the network with the
+	// duplicate. The inserter function is called as if the network had no
+	// data, so the last insert wins even if the inserter function would
+	// merge the values.
+	DuplicateReplace
+
+	// DuplicateKeep ignores the duplicate, so the first insert wins.
+	DuplicateKeep
+
+	// DuplicateError returns an error wrapping ErrDuplicateNetwork for the
+	// duplicate. The tree is not modified.
+	DuplicateError
+)
+
+func (p DuplicatePolicy) String() string {
+	switch p {
+	case DuplicateMerge:
+		return "DuplicateMerge"
+	case DuplicateReplace:
+		return "DuplicateReplace"
+	case DuplicateKeep:
+		return "DuplicateKeep"
+	case DuplicateError:
+		return "DuplicateError"
+	default:
+		return fmt.Sprintf("DuplicatePolicy(%d)", int(p))
+	}
+}
+
+// ErrDuplicateNetwork is wrapped by the error returned for a duplicate
+// insert when the DuplicatePolicy is DuplicateError.
+var ErrDuplicateNetwork = errors.New("the network has already been inserted")
+
+// duplicateTracker records the networks that have been inserted.
+type duplicateTracker struct {
+	seen  map[string]struct{}
+	count int
+
+	// journal, if set, holds the changes made since a transaction began
+	// so that they can be undone.
+	journal *duplicateJournal
+}
+
+// duplicateJournal is the change to a duplicateTracker during a
+// transaction.
+type duplicateJournal struct {
+	added []string
+	count int
+}
+
+// add records that the network with the key has been inserted.
+func (d *duplicateTracker) add(key string) {
+	if _, ok := d.seen[key]; ok {
+		return
+	}
+	d.seen[key] = struct{}{}
+	if d.journal != nil {
+		d.journal.added = append(d.journal.added, key)
+	}
+}
+
+// begin starts recording the changes for a transaction.
+func (d *duplicateTracker) begin() {
+	d.journal = &duplicateJournal{count: d.count}
+}
+
+// end stops recording the changes. If undo is true, the changes made since
+// begin are undone.
+func (d *duplicateTracker) end(undo bool) {
+	if undo {
+		for _, key := range d.journal.added {
+			delete(d.seen, key)
+		}
+		d.count = d.journal.count
+	}
+	d.journal = nil
+}
+
+// duplicateKey returns the key for the network, in tree form.
+func duplicateKey(ip net.IP, prefixLen, treeDepth int) string {
+	return string(append(ip.Mask(net.CIDRMask(prefixLen, treeDepth)), byte(prefixLen)))
+}
+
+// isRemove returns whether the inserter function is inserter.Remove.
+func isRemove(f inserter.Func) bool {
+	return reflect.ValueOf(f).Pointer() == reflect.ValueOf(inserter.Remove).Pointer()
+}
+
+// checkDuplicate applies the DuplicatePolicy to an insert of the network,
+// in tree form. It returns the inserter function to use, or nil if the
+// insert should be skipped, along with the key to record once the insert
+// succeeds.
+func (t *Tree) checkDuplicate(
+	network *net.IPNet,
+	ip net.IP,
+	prefixLen int,
+	inserterFunc inserter.Func,
+) (inserter.Func, string, error) {
+	key := duplicateKey(ip, prefixLen, t.treeDepth)
+	if _, ok := t.duplicates.seen[key]; !ok {
+		return inserterFunc, key, nil
+	}
+
+	t.duplicates.count++
+	if t.logger != nil && t.duplicatePolicy != DuplicateError {
+		t.logger.Warn(
+			"duplicate insert",
+			"network", network.String(),
+			"policy", t.duplicatePolicy.String(),
+		)
+	}
+	switch t.duplicatePolicy {
+	case DuplicateReplace:
+		return func(mmdbtype.DataType) (mmdbtype.DataType, error) {
+			return inserterFunc(nil)
+		}, key, nil
+	case DuplicateKeep:
+		return nil, key, nil
+	case DuplicateError:
+		return nil, key, fmt.Errorf("inserting %s: %w", network, ErrDuplicateNetwork)
+	case DuplicateMerge:
+	}
+	return inserterFunc, key, nil
+}
+
+// DuplicateCount returns the number of inserts of a network that had
+// already been inserted. It is always 0 unless Options.TrackDuplicates is
+// set or Options.DuplicatePolicy is not DuplicateMerge.
+func (t *Tree) DuplicateCount() int {
+	if t.duplicates == nil {
+		return 0
+	}
+	return t.duplicates.count
+}
@@ -0,0 +1,129 @@
+package mmdbwriter
+
+import (
+	"net"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/inserter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDuplicatePolicy(t *testing.T) {
+	tests := []struct {
+		policy        DuplicatePolicy
+		expectedErr   error
+		expectedValue mmdbtype.DataType
+	}{
+		{
+			policy: DuplicateMerge,
+			expectedValue: mmdbtype.Map{
+				"a": mmdbtype.String("first"),
+				"b": mmdbtype.String("second"),
+			},
+		},
+		{
+			policy:        DuplicateReplace,
+			expectedValue: mmdbtype.Map{"b": mmdbtype.String("second")},
+		},
+		{
+			policy:        DuplicateKeep,
+			expectedValue: mmdbtype.Map{"a": mmdbtype.String("first")},
+		},
+		{
+			policy:        DuplicateError,
+			expectedErr:   ErrDuplicateNetwork,
+			expectedValue: mmdbtype.Map{"a": mmdbtype.String("first")},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.policy.String(), func(t *testing.T) {
+			tree, err := New(Options{
+				Inserter:        inserter.TopLevelMergeWith,
+				DuplicatePolicy: test.policy,
+				TrackDuplicates: true,
+			})
+			require.NoError(t, err)
+
+			network := mustNetwork(t, "1.1.1.0/24")
+			require.NoError(t, tree.Insert(network, mmdbtype.Map{"a": mmdbtype.String("first")}))
+
+			// Overlapping networks are not duplicates.
+			require.NoError(t, tree.Insert(
+				mustNetwork(t, "1.1.0.0/16"),
+				mmdbtype.Map{"c": mmdbtype.String("other")},
+			))
+			require.NoError(t, tree.Insert(
+				mustNetwork(t, "1.1.1.0/25"),
+				mmdbtype.Map{"d": mmdbtype.String("other")},
+			))
+			assert.Equal(t, 0, tree.DuplicateCount())
+
+			// Policies other than DuplicateMerge enable tracking.
+			tree2, err := New(Options{
+				Inserter:        inserter.TopLevelMergeWith,
+				DuplicatePolicy: test.policy,
+				TrackDuplicates: test.policy == DuplicateMerge,
+			})
+			require.NoError(t, err)
+			require.NoError(t, tree2.Insert(network, mmdbtype.Map{"a": mmdbtype.String("first")}))
+
+			err = tree2.Insert(network, mmdbtype.Map{"b": mmdbtype.String("second")})
+			if test.expectedErr != nil {
+				require.ErrorIs(t, err, test.expectedErr)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, 1, tree2.DuplicateCount())
+
+			_, value := tree2.Get(net.ParseIP("1.1.1.1").To4())
+			assert.Equal(t, test.expectedValue, value)
+			assertRefCounts(t, tree2)
+		})
+	}
+}
+
+func TestDuplicateRemove(t *testing.T) {
+	tree, err := New(Options{DuplicatePolicy: DuplicateError})
+	require.NoError(t, err)
+
+	network := mustNetwork(t, "1.1.1.0/24")
+	insert(t, tree, "1.1.1.0/24", "a")
+	require.NoError(t, tree.InsertFunc(network, inserter.Remove))
+	require.NoError(t, tree.InsertFunc(network, inserter.Remove))
+	assert.Equal(t, 0, tree.DuplicateCount(), "removals are not duplicates")
+
+	// A removal does not count as an insert of the network.
+	other := mustNetwork(t, "2.2.2.0/24")
+	require.NoError(t, tree.InsertFunc(other, inserter.Remove))
+	insert(t, tree, "2.2.2.0/24", "b")
+	assert.Equal(t, 0, tree.DuplicateCount())
+
+	err = tree.Insert(network, mmdbtype.String("c"))
+	require.ErrorIs(t, err, ErrDuplicateNetwork)
+	assert.Equal(t, 1, tree.DuplicateCount())
+}
+
+func TestDuplicateCount(t *testing.T) {
+	tree, err := New(Options{})
+	require.NoError(t, err)
+	insert(t, tree, "1.1.1.0/24", "a")
+	insert(t, tree, "1.1.1.0/24", "b")
+	assert.Equal(t, 0, tree.DuplicateCount(), "not tracked by default")
+
+	tree, err = New(Options{TrackDuplicates: true})
+	require.NoError(t, err)
+	insert(t, tree, "1.1.1.0/24", "a")
+	insert(t, tree, "::1.1.1.0/120", "b")
+	insert(t, tree, "1.1.1.1/24", "c")
+	insert(t, tree, "1.1.2.0/24", "d")
+	assert.Equal(t, 2, tree.DuplicateCount())
+
+	_, value := tree.Get(net.ParseIP("1.1.1.1"))
+	assert.Equal(t, mmdbtype.String("c"), value)
+
+	_, err = New(Options{DuplicatePolicy: DuplicateError + 1})
+	require.Error(t, err)
+}
@@ -0,0 +1,272 @@
+package mmdbwriter
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+// EnumTableKey is the metadata key for the enum lookup table when
+// Options.EnumFields is used. Its value is a map with one key, "offset",
+// the offset of the table from the start of the data section, i.e., the
+// byte after the data section separator.
+//
+// The table itself is a map in the data section from the dot-separated
+// path of each field to an array of the strings of the field. Each of the
+// fields holds a uint16 index into its array rather than a string. Readers
+// in other languages may decode the map at the offset with their data
+// section decoder and replace the indexes as EnumTable.Expand does.
+const EnumTableKey = "mmdbwriter_enum_table"
+
+// EnumTable maps a dot-separated field path to the string values for the
+// field. The integer stored in a record for the field is the index of its
+// value in the slice.
+type EnumTable map[string][]string
+
+// ReadEnumTable reads the enum lookup table of a database written with
+// Options.EnumFields set. The buf must contain the entire database, e.g.,
+// as read with os.ReadFile. An error is returned if the database does not
+// have an enum table.
+func ReadEnumTable(buf []byte) (EnumTable, error) {
+	metadata, err := readMetadata(buf)
+	if err != nil {
+		return nil, err
+	}
+	rawKey, ok := metadata[EnumTableKey].(mmdbtype.Map)
+	if !ok {
+		return nil, errors.New("the database does not have an enum table")
+	}
+	offset, ok := rawKey["offset"].(mmdbtype.Uint32)
+	if !ok {
+		return nil, fmt.Errorf("unexpected type %T for the enum table offset", rawKey["offset"])
+	}
+	data, err := dataSection(buf, metadata)
+	if err != nil {
+		return nil, err
+	}
+	rawValue, _, err := mmdbtype.DecodeAt(data, int(offset))
+	if err != nil {
+		return nil, fmt.Errorf("decoding the enum table: %w", err)
+	}
+	rawTable, ok := rawValue.(mmdbtype.Map)
+	if !ok {
+		return nil, fmt.Errorf("unexpected type %T for the enum table", rawValue)
+	}
+
+	table := EnumTable{}
+	for field, rawValues := range rawTable {
+		values, ok := rawValues.(mmdbtype.Slice)
+		if !ok {
+			return nil, fmt.Errorf("unexpected type %T for enum field %s", rawValues, field)
+		}
+		for _, rawValue := range values {
+			v, ok := rawValue.(mmdbtype.String)
+			if !ok {
+				return nil, fmt.Errorf("unexpected type %T in enum field %s", rawValue, field)
+			}
+			table[string(field)] = append(table[string(field)], string(v))
+		}
+	}
+	return table, nil
+}
+
+// Lookup returns the string value for the index of the given field. The
+// bool is false if the field or index is not in the table.
+func (et EnumTable) Lookup(field string, index uint64) (string, bool) {
+	values, ok := et[field]
+	if !ok || index >= uint64(len(values)) {
+		return "", false
+	}
+	return values[index], true
+}
+
+// Expand replaces the enum indexes in a record decoded into a
+// map[string]any with their string values. The record is modified in place.
+// Fields that are missing or that do not contain a valid index are left
+// unchanged.
+func (et EnumTable) Expand(record map[string]any) {
+	for field := range et {
+		path := strings.Split(field, ".")
+		m := record
+		for _, key := range path[:len(path)-1] {
+			var ok bool
+			m, ok = m[key].(map[string]any)
+			if !ok {
+				break
+			}
+		}
+		if m == nil {
+			continue
+		}
+		last := path[len(path)-1]
+		index, ok := m[last].(uint64)
+		if !ok {
+			continue
+		}
+		if v, ok := et.Lookup(field, index); ok {
+			m[last] = v
+		}
+	}
+}
+
+// enumEncoder replaces string values at the configured field paths with
+// indexes into an EnumTable.
+type enumEncoder struct {
+	fields  [][]mmdbtype.String
+	table   EnumTable
+	indexes []map[mmdbtype.String]int
+
+	// offset is the offset of the table in the data section. It is set
+	// when the table is written.
+	offset int
+}
+
+// newEnumEncoder builds the lookup table for the fields from the values in
+// the dataMap. The values for each field are sorted so that the indexes
+// are reproducible between builds.
+func newEnumEncoder(fields []string, dm *dataMap) (*enumEncoder, error) {
+	e := &enumEncoder{
+		table: EnumTable{},
+	}
+	sets := make([]map[mmdbtype.String]struct{}, len(fields))
+	for i, field := range fields {
+		var path []mmdbtype.String
+		for _, key := range strings.Split(field, ".") {
+			path = append(path, mmdbtype.String(key))
+		}
+		e.fields = append(e.fields, path)
+		sets[i] = map[mmdbtype.String]struct{}{}
+	}
+
+	for _, dmv := range dm.data {
+		for i, path := range e.fields {
+			if s, ok := valueAtPath(dmv.load(), path); ok {
+				sets[i][s] = struct{}{}
+			}
+		}
+	}
+
+	for i, field := range fields {
+		if len(sets[i]) > math.MaxUint16+1 {
+			return nil, fmt.Errorf(
+				"the enum field %s has %d distinct values; the maximum is %d",
+				field,
+				len(sets[i]),
+				math.MaxUint16+1,
+			)
+		}
+		values := make([]string, 0, len(sets[i]))
+		for v := range sets[i] {
+			values = append(values, string(v))
+		}
+		sort.Strings(values)
+
+		index := make(map[mmdbtype.String]int, len(values))
+		for j, v := range values {
+			index[mmdbtype.String(v)] = j
+		}
+		e.table[field] = values
+		e.indexes = append(e.indexes, index)
+	}
+	return e, nil
+}
+
+// write writes the lookup table to the data section.
+func (e *enumEncoder) write(dw *dataWriter) error {
+	table := mmdbtype.Map{}
+	for field, values := range e.table {
+		s := make(mmdbtype.Slice, 0, len(values))
+		for _, v := range values {
+			s = append(s, mmdbtype.String(v))
+		}
+		table[mmdbtype.String(field)] = s
+	}
+	e.offset = dw.Len()
+	if _, err := table.WriteTo(dw); err != nil {
+		return fmt.Errorf("writing the enum table: %w", err)
+	}
+	return nil
+}
+
+// metadataValue returns the value to be written to the metadata under
+// EnumTableKey.
+func (e *enumEncoder) metadataValue() mmdbtype.Map {
+	return mmdbtype.Map{"offset": mmdbtype.Uint32(e.offset)}
+}
+
+// encode returns a copy of the value with the enum fields replaced by their
+// indexes. The value passed in is not modified.
+func (e *enumEncoder) encode(v mmdbtype.DataType) mmdbtype.DataType {
+	for i, path := range e.fields {
+		index := e.indexes[i]
+		v = replaceAtPath(v, path, func(s mmdbtype.String) mmdbtype.DataType {
+			return mmdbtype.Uint16(index[s])
+		})
+	}
+	return v
+}
+
+func valueAtPath(v mmdbtype.DataType, path []mmdbtype.String) (mmdbtype.String, bool) {
+	for _, key := range path {
+		if tv, ok := v.(mmdbtype.Templated); ok {
+			v = tv.Map()
+		}
+		m, ok := v.(mmdbtype.Map)
+		if !ok {
+			return "", false
+		}
+		v = m[key]
+	}
+	s, ok := v.(mmdbtype.String)
+	return s, ok
+}
+
+// replaceAtPath returns a copy of the value with the string at the path
+// replaced by the result of replace. The value is returned unchanged if it
+// does not have a string at the path. A Templated value with a string at
+// the path is returned as its composed Map.
+func replaceAtPath(
+	v mmdbtype.DataType,
+	path []mmdbtype.String,
+	replace func(mmdbtype.String) mmdbtype.DataType,
+) mmdbtype.DataType {
+	var m mmdbtype.Map
+	switch tv := v.(type) {
+	case mmdbtype.Map:
+		m = tv
+	case mmdbtype.Templated:
+		m = tv.Map()
+	default:
+		return v
+	}
+	child, ok := m[path[0]]
+	if !ok {
+		return v
+	}
+
+	var newChild mmdbtype.DataType
+	if len(path) == 1 {
+		s, ok := child.(mmdbtype.String)
+		if !ok {
+			return v
+		}
+		newChild = replace(s)
+	} else {
+		newChild = replaceAtPath(child, path[1:], replace)
+		if newChild.Equal(child) {
+			return v
+		}
+	}
+
+	// We make a shallow copy as the value may be shared with other records.
+	newMap := make(mmdbtype.Map, len(m))
+	for k, e := range m {
+		newMap[k] = e
+	}
+	newMap[path[0]] = newChild
+	return newMap
+}
@@ -0,0 +1,161 @@
+package mmdbwriter
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/oschwald/maxminddb-golang"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnumFields(t *testing.T) {
+	tree, err := New(
+		Options{
+			DatabaseType: "mmdbwriter-test",
+			Description:  map[string]string{"en": "Test database"},
+			EnumFields:   []string{"continent.code", "location.time_zone"},
+		},
+	)
+	require.NoError(t, err)
+
+	records := map[string]mmdbtype.Map{
+		"1.1.1.0/24": {
+			"continent": mmdbtype.Map{"code": mmdbtype.String("OC")},
+			"location":  mmdbtype.Map{"time_zone": mmdbtype.String("Australia/Sydney")},
+		},
+		"2.2.2.0/24": {
+			"continent": mmdbtype.Map{"code": mmdbtype.String("EU")},
+			"location":  mmdbtype.Map{"time_zone": mmdbtype.String("Europe/Paris")},
+		},
+		"3.3.3.0/24": {
+			"continent": mmdbtype.Map{"code": mmdbtype.String("NA")},
+			"location":  mmdbtype.Map{"time_zone": mmdbtype.Uint16(1)},
+		},
+	}
+	for network, record := range records {
+		_, ipNet, err := net.ParseCIDR(network)
+		require.NoError(t, err)
+		require.NoError(t, tree.Insert(ipNet, record))
+	}
+
+	_, value := tree.Get(net.ParseIP("1.1.1.1"))
+	assert.Equal(t, records["1.1.1.0/24"], value, "Get returns the original value")
+
+	buf := &bytes.Buffer{}
+	_, err = tree.WriteTo(buf)
+	require.NoError(t, err)
+
+	reader, err := maxminddb.FromBytes(buf.Bytes())
+	require.NoError(t, err)
+	// maxminddb-golang's Verify rejects the table as the search tree does
+	// not point to it.
+	findings, err := Verify(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	assert.Empty(t, findings)
+
+	table, err := ReadEnumTable(buf.Bytes())
+	require.NoError(t, err)
+	assert.Equal(
+		t,
+		EnumTable{
+			"continent.code":     {"EU", "NA", "OC"},
+			"location.time_zone": {"Australia/Sydney", "Europe/Paris"},
+		},
+		table,
+	)
+
+	var record map[string]any
+	require.NoError(t, reader.Lookup(net.ParseIP("1.1.1.1"), &record))
+	assert.Equal(
+		t,
+		map[string]any{
+			"continent": map[string]any{"code": uint64(2)},
+			"location":  map[string]any{"time_zone": uint64(0)},
+		},
+		record,
+	)
+
+	table.Expand(record)
+	assert.Equal(
+		t,
+		map[string]any{
+			"continent": map[string]any{"code": "OC"},
+			"location":  map[string]any{"time_zone": "Australia/Sydney"},
+		},
+		record,
+	)
+
+	// Non-string values are left as is.
+	require.NoError(t, reader.Lookup(net.ParseIP("3.3.3.3"), &record))
+	assert.Equal(t, map[string]any{"time_zone": uint64(1)}, record["location"])
+}
+
+func TestReadEnumTableMissing(t *testing.T) {
+	tree, err := New(Options{})
+	require.NoError(t, err)
+
+	_, network, err := net.ParseCIDR("1.1.1.0/24")
+	require.NoError(t, err)
+	require.NoError(t, tree.Insert(network, mmdbtype.Map{"a": mmdbtype.String("b")}))
+
+	buf := &bytes.Buffer{}
+	_, err = tree.WriteTo(buf)
+	require.NoError(t, err)
+
+	_, err = ReadEnumTable(buf.Bytes())
+	assert.EqualError(t, err, "the database does not have an enum table")
+}
+
+func TestEnumFieldsLargeTable(t *testing.T) {
+	tree, err := New(Options{IPVersion: 4, EnumFields: []string{"name"}})
+	require.NoError(t, err)
+
+	// The table is larger than the 128 KiB that readers search for the
+	// metadata, which is fine as it is in the data section.
+	var expected []string
+	for i := 0; i < 4096; i++ {
+		ip := net.IPv4(1, byte(i>>8), byte(i), 0).To4()
+		name := fmt.Sprintf("%040d", i)
+		expected = append(expected, name)
+		require.NoError(t, tree.Insert(
+			&net.IPNet{IP: ip, Mask: net.CIDRMask(24, 32)},
+			mmdbtype.Map{"name": mmdbtype.String(name)},
+		))
+	}
+
+	buf := &bytes.Buffer{}
+	_, err = tree.WriteTo(buf)
+	require.NoError(t, err)
+
+	table, err := ReadEnumTable(buf.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, EnumTable{"name": expected}, table)
+
+	reader, err := maxminddb.FromBytes(buf.Bytes())
+	require.NoError(t, err)
+	var record map[string]any
+	require.NoError(t, reader.Lookup(net.ParseIP("1.0.5.1"), &record))
+	table.Expand(record)
+	assert.Equal(t, map[string]any{"name": expected[5]}, record)
+}
+
+func TestMetadataTooLarge(t *testing.T) {
+	tree, err := New(Options{
+		IPVersion:   4,
+		Description: map[string]string{"en": strings.Repeat("x", 200_000)},
+	})
+	require.NoError(t, err)
+
+	_, err = tree.WriteTo(io.Discard)
+	assert.EqualError(
+		t,
+		err,
+		"the metadata is 200179 bytes, which exceeds the maximum of 131072 bytes that readers search for it",
+	)
+}
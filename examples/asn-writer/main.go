@@ -11,11 +11,11 @@ import (
 	"strconv"
 
 	"github.com/maxmind/mmdbwriter"
-	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/maxmind/mmdbwriter/builders/asn"
 )
 
 func main() {
-	writer, err := mmdbwriter.New(
+	writer, err := asn.New(
 		mmdbwriter.Options{
 			DatabaseType: "My-ASN-DB",
 			RecordSize:   24,
@@ -54,22 +54,15 @@ func main() {
 				log.Fatal(err)
 			}
 
-			asn, err := strconv.Atoi(row[1])
+			number, err := strconv.ParseUint(row[1], 10, 32)
 			if err != nil {
 				log.Fatal(err)
 			}
 
-			record := mmdbtype.Map{}
-
-			if asn != 0 {
-				record["autonomous_system_number"] = mmdbtype.Uint32(asn)
-			}
-
-			if row[2] != "" {
-				record["autonomous_system_organization"] = mmdbtype.String(row[2])
-			}
-
-			err = writer.Insert(network, record)
+			err = writer.Insert(network, asn.Record{
+				Number:       uint32(number),
+				Organization: row[2],
+			})
 			if err != nil {
 				log.Fatal(err)
 			}
@@ -0,0 +1,110 @@
+// kafka-consumer is an example of how to keep a MaxMind DB file up to date
+// from a stream of events, e.g., the messages of a Kafka topic. Each message
+// is a JSON event as described by stream.ParseEvent:
+//
+//	{"network": "1.1.1.0/24", "record": {"score": 10}}
+//	{"network": "1.1.1.0/24", "delete": true}
+//
+// To keep the example free of a Kafka client dependency, it reads one event
+// per line from standard input, e.g., piped from kafka-console-consumer.
+// With github.com/segmentio/kafka-go, the adapter is instead:
+//
+//	r := kafka.NewReader(kafka.ReaderConfig{Brokers: brokers, Topic: topic})
+//	read := func(ctx context.Context) ([]byte, error) {
+//		m, err := r.ReadMessage(ctx)
+//		return m.Value, err
+//	}
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/stream"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	tree, err := mmdbwriter.New(
+		mmdbwriter.Options{
+			DatabaseType: "My-IP-Reputation-DB",
+			RecordSize:   24,
+		},
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	consumer, err := stream.NewConsumer(tree, stream.Options{
+		Path:     "reputation.mmdb",
+		Interval: 30 * time.Second,
+		ErrorHandler: func(e stream.Event, err error) error {
+			log.Printf("skipping event: %v", err)
+			return nil
+		},
+		OnSnapshot: func(size int64) {
+			log.Printf("wrote snapshot of %d bytes", size)
+		},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	scanner := bufio.NewScanner(os.Stdin)
+	read := func(context.Context) ([]byte, error) {
+		if scanner.Scan() {
+			return scanner.Bytes(), nil
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+
+	events := make(chan stream.Event)
+	go func() {
+		defer close(events)
+		if err := readEvents(ctx, read, events); err != nil && !errors.Is(err, io.EOF) {
+			log.Print(err)
+		}
+	}()
+
+	if err := consumer.Run(ctx, events); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// readEvents is the adapter between the message source and the consumer. It
+// parses each message and sends the event on the channel until read returns
+// an error.
+func readEvents(
+	ctx context.Context,
+	read func(context.Context) ([]byte, error),
+	events chan<- stream.Event,
+) error {
+	for {
+		msg, err := read(ctx)
+		if err != nil {
+			return err
+		}
+		e, err := stream.ParseEvent(msg)
+		if err != nil {
+			log.Printf("skipping message: %v", err)
+			continue
+		}
+		select {
+		case events <- e:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
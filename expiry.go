@@ -0,0 +1,176 @@
+package mmdbwriter
+
+import (
+	"net"
+	"time"
+
+	"github.com/maxmind/mmdbwriter/inserter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+// expiryTracker records the expiration time of the most recent insert for
+// each network. Like sourceTracker, it is a binary trie that is independent
+// of the search tree. A set node with a zero expiration time is a network
+// whose most recent insert does not expire.
+type expiryTracker struct {
+	root    expiryNode
+	current time.Time
+}
+
+type expiryNode struct {
+	children  [2]*expiryNode
+	expiresAt time.Time
+	set       bool
+}
+
+// insert records the current expiration time for the network. As with
+// sourceTracker, the new insert supersedes any earlier inserts within the
+// network, so we drop the subtree below it.
+func (et *expiryTracker) insert(ip net.IP, prefixLen int) {
+	n := &et.root
+	for depth := 0; depth < prefixLen; depth++ {
+		bit := bitAt(ip, depth)
+		if n.children[bit] == nil {
+			n.children[bit] = &expiryNode{}
+		}
+		n = n.children[bit]
+	}
+	n.children = [2]*expiryNode{}
+	n.expiresAt = et.current
+	n.set = true
+}
+
+// InsertWithExpiry is the same as Insert, except that the network's data is
+// removed by Expire once expiresAt has passed. Expire is called
+// automatically when writing the tree.
+//
+// The expiration time applies to the whole network. Any later insert
+// overlapping the network, with or without an expiration time, supersedes
+// it for the overlapping part. Expiration times are only kept in memory.
+// They are not copied by Extract or written to the database.
+//
+// This is not safe to call from multiple threads.
+func (t *Tree) InsertWithExpiry(
+	network *net.IPNet,
+	value mmdbtype.DataType,
+	expiresAt time.Time,
+) error {
+	return t.InsertFuncWithExpiry(network, t.inserterFuncGen(value), expiresAt)
+}
+
+// InsertFuncWithExpiry is the same as InsertFunc, except that the network's
+// data is removed by Expire once expiresAt has passed. See
+// InsertWithExpiry.
+//
+// This is not safe to call from multiple threads.
+func (t *Tree) InsertFuncWithExpiry(
+	network *net.IPNet,
+	inserterFunc inserter.Func,
+	expiresAt time.Time,
+) error {
+	if t.expiries == nil {
+		t.expiries = &expiryTracker{}
+	}
+	t.expiries.current = expiresAt
+	defer func() { t.expiries.current = time.Time{} }()
+
+	return t.InsertFunc(network, inserterFunc)
+}
+
+// Expire removes the data for the networks inserted with InsertWithExpiry
+// or InsertFuncWithExpiry whose expiration time is not after now. Parts of
+// those networks that were covered by a later insert are kept. It returns
+// the number of expired inserts.
+//
+// This is not safe to call from multiple threads.
+func (t *Tree) Expire(now time.Time) (int, error) {
+	if t.expiries == nil {
+		return 0, nil
+	}
+	ip := make(net.IP, t.treeDepth/8)
+	return t.expireNode(&t.expiries.root, ip, 0, now)
+}
+
+// expireNode expires the set nodes in the subtree rooted at n, which is for
+// the network at ip and depth. Expired nodes are unset and subtrees without
+// set nodes are pruned.
+func (t *Tree) expireNode(n *expiryNode, ip net.IP, depth int, now time.Time) (int, error) {
+	expired := 0
+	if n.set && !n.expiresAt.IsZero() && !n.expiresAt.After(now) {
+		if err := t.removeUncovered(n, ip, depth); err != nil {
+			return expired, err
+		}
+		n.set = false
+		n.expiresAt = time.Time{}
+		expired++
+	}
+
+	for bit, child := range n.children {
+		if child == nil {
+			continue
+		}
+		childIP := make(net.IP, len(ip))
+		copy(childIP, ip)
+		if bit == 1 {
+			setBitAt(childIP, depth)
+		}
+		count, err := t.expireNode(child, childIP, depth+1, now)
+		expired += count
+		if err != nil {
+			return expired, err
+		}
+		if !child.set && child.children[0] == nil && child.children[1] == nil {
+			n.children[bit] = nil
+		}
+	}
+	return expired, nil
+}
+
+// removeUncovered removes the data for the parts of the network at ip and
+// depth that are not covered by a set node below n.
+func (t *Tree) removeUncovered(n *expiryNode, ip net.IP, depth int) error {
+	if n.children[0] == nil && n.children[1] == nil {
+		return t.replaceNetwork(ip, depth, nil)
+	}
+	for bit, child := range n.children {
+		childIP := make(net.IP, len(ip))
+		copy(childIP, ip)
+		if bit == 1 {
+			setBitAt(childIP, depth)
+		}
+		switch {
+		case child == nil:
+			if err := t.replaceNetwork(childIP, depth+1, nil); err != nil {
+				return err
+			}
+		case !child.set:
+			if err := t.removeUncovered(child, childIP, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// replaceNetwork replaces the data for the network, in tree form, with the
+// value. A nil value removes the data. Unlike inserting with
+// inserter.ReplaceWith, it ignores the OverwritePolicy and the
+// InsertMiddleware and does not record a new insert for the network.
+func (t *Tree) replaceNetwork(ip net.IP, prefixLen int, value mmdbtype.DataType) error {
+	t.nodeCount = 0
+	t.invalidateIndex()
+	return t.root.insert(
+		insertRecord{
+			ip:         ip,
+			prefixLen:  prefixLen,
+			recordType: recordTypeData,
+			inserter:   inserter.ReplaceWith(value),
+			nodeCount:  &t.liveNodes,
+			report:     &t.report,
+
+			dataMap: t.dataMap,
+			nodes:   t.nodes,
+		},
+		0,
+	)
+}
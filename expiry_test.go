@@ -0,0 +1,145 @@
+package mmdbwriter
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/oschwald/maxminddb-golang"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpire(t *testing.T) {
+	now := time.Date(2022, 11, 1, 12, 0, 0, 0, time.UTC)
+
+	type expectedGet struct {
+		ip      string
+		network string
+		value   mmdbtype.DataType
+	}
+
+	tests := []struct {
+		name            string
+		inserts         func(t *testing.T, tree *Tree)
+		expectedExpired int
+		expected        []expectedGet
+	}{
+		{
+			name: "expired and unexpired",
+			inserts: func(t *testing.T, tree *Tree) {
+				insertWithExpiry(t, tree, "1.1.1.0/24", "a", now.Add(-time.Hour))
+				insertWithExpiry(t, tree, "2.2.2.0/24", "b", now)
+				insertWithExpiry(t, tree, "3.3.3.0/24", "c", now.Add(time.Hour))
+				insertWithExpiry(t, tree, "4.4.4.0/24", "d", time.Time{})
+			},
+			expectedExpired: 2,
+			expected: []expectedGet{
+				{ip: "1.1.1.1", network: "1.0.0.0/8", value: nil},
+				{ip: "2.2.2.2", network: "2.0.0.0/8", value: nil},
+				{ip: "3.3.3.3", network: "3.3.3.0/24", value: mmdbtype.String("c")},
+				{ip: "4.4.4.4", network: "4.4.4.0/24", value: mmdbtype.String("d")},
+			},
+		},
+		{
+			name: "later insert within expired network",
+			inserts: func(t *testing.T, tree *Tree) {
+				insertWithExpiry(t, tree, "1.1.0.0/16", "a", now.Add(-time.Hour))
+				insert(t, tree, "1.1.1.0/24", "b")
+			},
+			expectedExpired: 1,
+			expected: []expectedGet{
+				{ip: "1.1.1.1", network: "1.1.1.0/24", value: mmdbtype.String("b")},
+				{ip: "1.1.2.1", network: "1.1.2.0/23", value: nil},
+			},
+		},
+		{
+			name: "later insert over expiring network",
+			inserts: func(t *testing.T, tree *Tree) {
+				insertWithExpiry(t, tree, "1.1.1.0/24", "a", now.Add(-time.Hour))
+				insertWithExpiry(t, tree, "1.1.0.0/16", "b", now.Add(time.Hour))
+			},
+			expectedExpired: 0,
+			expected: []expectedGet{
+				{ip: "1.1.1.1", network: "1.1.0.0/16", value: mmdbtype.String("b")},
+			},
+		},
+		{
+			name: "nested expired networks",
+			inserts: func(t *testing.T, tree *Tree) {
+				insertWithExpiry(t, tree, "1.1.0.0/16", "a", now.Add(-time.Hour))
+				insertWithExpiry(t, tree, "1.1.1.0/24", "b", now.Add(-time.Minute))
+				insertWithExpiry(t, tree, "1.1.2.0/24", "c", now.Add(time.Hour))
+			},
+			expectedExpired: 2,
+			expected: []expectedGet{
+				{ip: "1.1.1.1", network: "1.1.0.0/23", value: nil},
+				{ip: "1.1.2.1", network: "1.1.2.0/24", value: mmdbtype.String("c")},
+				{ip: "1.1.3.1", network: "1.1.3.0/24", value: nil},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tree, err := New(Options{IPVersion: 4})
+			require.NoError(t, err)
+
+			test.inserts(t, tree)
+
+			expired, err := tree.Expire(now)
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedExpired, expired)
+
+			for _, e := range test.expected {
+				network, value := tree.Get(net.ParseIP(e.ip).To4())
+				assert.Equal(t, e.network, network.String(), e.ip)
+				assert.Equal(t, e.value, value, e.ip)
+			}
+			assertRefCounts(t, tree)
+
+			expired, err = tree.Expire(now)
+			require.NoError(t, err)
+			assert.Zero(t, expired, "networks are only expired once")
+		})
+	}
+}
+
+func TestExpireOnWrite(t *testing.T) {
+	tree, err := New(Options{IncludeReservedNetworks: true})
+	require.NoError(t, err)
+
+	insertWithExpiry(t, tree, "1.1.1.0/24", "a", time.Now().Add(-time.Second))
+	insertWithExpiry(t, tree, "2.2.2.0/24", "b", time.Now().Add(time.Hour))
+
+	buf := &bytes.Buffer{}
+	_, err = tree.WriteTo(buf)
+	require.NoError(t, err)
+
+	reader, err := maxminddb.FromBytes(buf.Bytes())
+	require.NoError(t, err)
+
+	var value any
+	_, ok, err := reader.LookupNetwork(net.ParseIP("1.1.1.1"), &value)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	_, ok, err = reader.LookupNetwork(net.ParseIP("2.2.2.2"), &value)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "b", value)
+}
+
+func insertWithExpiry(t *testing.T, tree *Tree, network, value string, expiresAt time.Time) {
+	_, ipNet, err := net.ParseCIDR(network)
+	require.NoError(t, err)
+	require.NoError(t, tree.InsertWithExpiry(ipNet, mmdbtype.String(value), expiresAt))
+}
+
+func insert(t *testing.T, tree *Tree, network, value string) {
+	_, ipNet, err := net.ParseCIDR(network)
+	require.NoError(t, err)
+	require.NoError(t, tree.Insert(ipNet, mmdbtype.String(value)))
+}
@@ -0,0 +1,288 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+var magic = []byte("PAR1")
+
+// The Parquet physical types.
+const (
+	typeBoolean   = 0
+	typeInt64     = 2
+	typeDouble    = 5
+	typeByteArray = 6
+)
+
+// Other Parquet enum values.
+const (
+	repetitionRequired = 0
+	repetitionOptional = 1
+	convertedUTF8      = 0
+	encodingPlain      = 0
+	encodingRLE        = 3
+	codecUncompressed  = 0
+	pageTypeData       = 0
+)
+
+func (c *column) physicalType() int32 {
+	switch c.kind {
+	case kindBool:
+		return typeBoolean
+	case kindInt64:
+		return typeInt64
+	case kindDouble:
+		return typeDouble
+	case kindString, kindBytes:
+		return typeByteArray
+	default:
+		return typeByteArray
+	}
+}
+
+// chunk is a column chunk that has been written.
+type chunk struct {
+	offset    int64
+	size      int64
+	numValues int64
+}
+
+// countingWriter counts the bytes written to w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+func writeFile(w io.Writer, columns []*column, rows, rowGroupSize int) error {
+	cw := &countingWriter{w: w}
+	if _, err := cw.Write(magic); err != nil {
+		return fmt.Errorf("writing Parquet file: %w", err)
+	}
+
+	var rowGroups [][]chunk
+	for start := 0; start < rows; start += rowGroupSize {
+		end := start + rowGroupSize
+		if end > rows {
+			end = rows
+		}
+		chunks := make([]chunk, len(columns))
+		for i, c := range columns {
+			offset := cw.n
+			if err := writePage(cw, c, start, end); err != nil {
+				return fmt.Errorf("writing column %s: %w", c.name, err)
+			}
+			chunks[i] = chunk{
+				offset:    offset,
+				size:      cw.n - offset,
+				numValues: int64(end - start),
+			}
+		}
+		rowGroups = append(rowGroups, chunks)
+	}
+
+	metadata := fileMetadata(columns, rows, rowGroups, rowGroupSize)
+	if _, err := cw.Write(metadata); err != nil {
+		return fmt.Errorf("writing Parquet metadata: %w", err)
+	}
+	var footer [8]byte
+	binary.LittleEndian.PutUint32(footer[:4], uint32(len(metadata)))
+	copy(footer[4:], magic)
+	if _, err := cw.Write(footer[:]); err != nil {
+		return fmt.Errorf("writing Parquet footer: %w", err)
+	}
+	return nil
+}
+
+// writePage writes the values of the rows [start, end) of the column as a
+// single data page.
+func writePage(w io.Writer, c *column, start, end int) error {
+	var data bytes.Buffer
+	if !c.required {
+		levels := definitionLevels(c.values[start:end])
+		var n [4]byte
+		binary.LittleEndian.PutUint32(n[:], uint32(len(levels)))
+		data.Write(n[:])
+		data.Write(levels)
+	}
+	if err := c.writeValues(&data, start, end); err != nil {
+		return err
+	}
+
+	var header thriftWriter
+	header.i32(1, pageTypeData)
+	header.i32(2, int32(data.Len()))
+	header.i32(3, int32(data.Len()))
+	header.structField(5)
+	header.i32(1, int32(end-start))
+	header.i32(2, encodingPlain)
+	header.i32(3, encodingRLE)
+	header.i32(4, encodingRLE)
+	header.endStruct()
+	header.endStruct()
+
+	if _, err := w.Write(header.buf.Bytes()); err != nil {
+		return err
+	}
+	_, err := w.Write(data.Bytes())
+	return err
+}
+
+// definitionLevels returns the definition levels of the values, 1 for
+// present and 0 for null, with the RLE/bit-packing hybrid encoding. They
+// are written as a single bit-packed run.
+func definitionLevels(values []mmdbtype.DataType) []byte {
+	groups := (len(values) + 7) / 8
+	var header [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(header[:], uint64(groups)<<1|1)
+	levels := make([]byte, n+groups)
+	copy(levels, header[:n])
+	for i, v := range values {
+		if v != nil {
+			levels[n+i/8] |= 1 << (i % 8)
+		}
+	}
+	return levels
+}
+
+// writeValues writes the present values of the rows [start, end) with the
+// plain encoding.
+func (c *column) writeValues(buf *bytes.Buffer, start, end int) error {
+	var b [8]byte
+	var bits []byte
+	present := 0
+	for i := start; i < end; i++ {
+		v := c.values[i]
+		if v == nil {
+			continue
+		}
+		switch c.kind {
+		case kindString:
+			s := c.strings[i]
+			binary.LittleEndian.PutUint32(b[:4], uint32(len(s)))
+			buf.Write(b[:4])
+			buf.WriteString(s)
+		case kindBytes:
+			v := v.(mmdbtype.Bytes)
+			binary.LittleEndian.PutUint32(b[:4], uint32(len(v)))
+			buf.Write(b[:4])
+			buf.Write(v)
+		case kindBool:
+			if present%8 == 0 {
+				bits = append(bits, 0)
+			}
+			if v.(mmdbtype.Bool) {
+				bits[present/8] |= 1 << (present % 8)
+			}
+		case kindInt64:
+			binary.LittleEndian.PutUint64(b[:], uint64(intValue(v)))
+			buf.Write(b[:])
+		case kindDouble:
+			binary.LittleEndian.PutUint64(b[:], math.Float64bits(floatValue(v)))
+			buf.Write(b[:])
+		default:
+			return fmt.Errorf("unsupported column kind: %d", c.kind)
+		}
+		present++
+	}
+	buf.Write(bits)
+	return nil
+}
+
+func intValue(v mmdbtype.DataType) int64 {
+	switch v := v.(type) {
+	case mmdbtype.Int32:
+		return int64(v)
+	case mmdbtype.Uint16:
+		return int64(v)
+	case mmdbtype.Uint32:
+		return int64(v)
+	default:
+		return 0
+	}
+}
+
+func floatValue(v mmdbtype.DataType) float64 {
+	switch v := v.(type) {
+	case mmdbtype.Float32:
+		return float64(v)
+	case mmdbtype.Float64:
+		return float64(v)
+	default:
+		return 0
+	}
+}
+
+// fileMetadata returns the encoded FileMetaData.
+func fileMetadata(columns []*column, rows int, rowGroups [][]chunk, rowGroupSize int) []byte {
+	var w thriftWriter
+	w.i32(1, 1)
+
+	w.structList(2, len(columns)+1)
+	w.beginStruct()
+	w.string(4, "schema")
+	w.i32(5, int32(len(columns)))
+	w.endStruct()
+	for _, c := range columns {
+		w.beginStruct()
+		w.i32(1, c.physicalType())
+		repetition := int32(repetitionOptional)
+		if c.required {
+			repetition = repetitionRequired
+		}
+		w.i32(3, repetition)
+		w.string(4, c.name)
+		if c.kind == kindString {
+			w.i32(6, convertedUTF8)
+		}
+		w.endStruct()
+	}
+
+	w.i64(3, int64(rows))
+
+	w.structList(4, len(rowGroups))
+	for i, chunks := range rowGroups {
+		w.beginStruct()
+		w.structList(1, len(chunks))
+		var total int64
+		for j, ch := range chunks {
+			c := columns[j]
+			total += ch.size
+			w.beginStruct()
+			w.i64(2, ch.offset)
+			w.structField(3)
+			w.i32(1, c.physicalType())
+			w.i32List(2, []int32{encodingPlain, encodingRLE})
+			w.stringList(3, []string{c.name})
+			w.i32(4, codecUncompressed)
+			w.i64(5, ch.numValues)
+			w.i64(6, ch.size)
+			w.i64(7, ch.size)
+			w.i64(9, ch.offset)
+			w.endStruct()
+			w.endStruct()
+		}
+		w.i64(2, total)
+		numRows := rowGroupSize
+		if i == len(rowGroups)-1 {
+			numRows = rows - i*rowGroupSize
+		}
+		w.i64(3, int64(numRows))
+		w.endStruct()
+	}
+
+	w.string(6, "mmdbwriter")
+	w.endStruct()
+	return w.buf.Bytes()
+}
@@ -0,0 +1,269 @@
+// Package parquet writes the networks and records of an mmdbwriter.Tree as
+// a Parquet file, allowing the contents of a database to be queried with
+// tools such as Spark or Athena.
+//
+// Each network with data is a row. The network is in the "network" column
+// in CIDR notation. The records are flattened so that each value in a map
+// is a column named by its path, e.g., "country_iso_code" for
+// {"country": {"iso_code": ...}}. Slices are written as JSON strings. A
+// record that is not a map is written to the "value" column. Columns that
+// are missing from a record are null.
+//
+// The file is written without compression or dictionary encoding and the
+// rows are accumulated in memory before they are written.
+package parquet
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+// DefaultRowGroupSize is the number of rows in each row group if
+// Options.RowGroupSize is not set.
+const DefaultRowGroupSize = 100_000
+
+// Options configures Write.
+type Options struct {
+	// Columns are the paths of the record values to write, e.g.,
+	// "country.iso_code", in the order of the columns. By default, all the
+	// paths in the records are written in sorted order.
+	Columns []string
+
+	// Separator is used to join the keys of a path in the column names. It
+	// defaults to "_".
+	Separator string
+
+	// RowGroupSize is the number of rows in each row group. It defaults to
+	// DefaultRowGroupSize.
+	RowGroupSize int
+}
+
+// Write writes the networks in the tree and their records to w as a
+// Parquet file.
+func Write(w io.Writer, tree *mmdbwriter.Tree, opts Options) error {
+	if opts.Separator == "" {
+		opts.Separator = "_"
+	}
+	if opts.RowGroupSize == 0 {
+		opts.RowGroupSize = DefaultRowGroupSize
+	}
+	if opts.RowGroupSize < 0 {
+		return fmt.Errorf("invalid RowGroupSize: %d", opts.RowGroupSize)
+	}
+
+	t := newTable(opts.Columns)
+	err := tree.Walk(func(network *net.IPNet, value mmdbtype.DataType) (bool, error) {
+		if value != nil {
+			t.addRow(network, value)
+		}
+		return true, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	columns, err := t.columns(opts.Separator)
+	if err != nil {
+		return err
+	}
+	return writeFile(w, columns, t.rows, opts.RowGroupSize)
+}
+
+// table accumulates the values of the rows by path.
+type table struct {
+	rows     int
+	networks []mmdbtype.DataType
+	values   map[string][]mmdbtype.DataType
+
+	// selected contains the paths given in Options.Columns, if any.
+	selected []string
+}
+
+func newTable(selected []string) *table {
+	t := &table{
+		values:   map[string][]mmdbtype.DataType{},
+		selected: selected,
+	}
+	for _, path := range selected {
+		t.values[path] = nil
+	}
+	return t
+}
+
+func (t *table) addRow(network *net.IPNet, value mmdbtype.DataType) {
+	t.networks = append(t.networks, mmdbtype.String(network.String()))
+	if m, ok := value.(mmdbtype.Map); ok {
+		t.addMap("", m)
+	} else {
+		t.add("value", value)
+	}
+	t.rows++
+}
+
+func (t *table) addMap(prefix string, m mmdbtype.Map) {
+	for k, v := range m {
+		path := string(k)
+		if prefix != "" {
+			path = prefix + "." + path
+		}
+		if nested, ok := v.(mmdbtype.Map); ok {
+			t.addMap(path, nested)
+			continue
+		}
+		t.add(path, v)
+	}
+}
+
+func (t *table) add(path string, value mmdbtype.DataType) {
+	values, ok := t.values[path]
+	if !ok && t.selected != nil {
+		return
+	}
+	for len(values) < t.rows {
+		values = append(values, nil)
+	}
+	t.values[path] = append(values, value)
+}
+
+// columns returns the columns of the table, starting with the network.
+func (t *table) columns(separator string) ([]*column, error) {
+	paths := t.selected
+	if paths == nil {
+		for path := range t.values {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+	}
+
+	network := &column{
+		name:     "network",
+		required: true,
+		values:   t.networks,
+	}
+	if err := network.setKind(); err != nil {
+		return nil, err
+	}
+	columns := []*column{network}
+	names := map[string]string{"network": "network"}
+	for _, path := range paths {
+		name := strings.ReplaceAll(path, ".", separator)
+		if other, ok := names[name]; ok {
+			return nil, fmt.Errorf("the paths %q and %q have the same column name %q", other, path, name)
+		}
+		names[name] = path
+
+		values := t.values[path]
+		for len(values) < t.rows {
+			values = append(values, nil)
+		}
+		c := &column{name: name, values: values}
+		if err := c.setKind(); err != nil {
+			return nil, fmt.Errorf("column %s: %w", name, err)
+		}
+		columns = append(columns, c)
+	}
+	return columns, nil
+}
+
+type columnKind int
+
+const (
+	kindString columnKind = iota
+	kindBytes
+	kindBool
+	kindInt64
+	kindDouble
+)
+
+type column struct {
+	name     string
+	kind     columnKind
+	required bool
+	values   []mmdbtype.DataType
+
+	// strings are the values of kindString columns, which may be converted
+	// from other types.
+	strings []string
+}
+
+func kindOf(v mmdbtype.DataType) columnKind {
+	switch v.(type) {
+	case mmdbtype.Bytes:
+		return kindBytes
+	case mmdbtype.Bool:
+		return kindBool
+	case mmdbtype.Int32, mmdbtype.Uint16, mmdbtype.Uint32:
+		return kindInt64
+	case mmdbtype.Float32, mmdbtype.Float64:
+		return kindDouble
+	default:
+		return kindString
+	}
+}
+
+// setKind sets the kind of the column to the kind of its values. If the
+// values have different kinds, the column is a string column.
+func (c *column) setKind() error {
+	first := true
+	for _, v := range c.values {
+		if v == nil {
+			continue
+		}
+		k := kindOf(v)
+		if first {
+			c.kind = k
+			first = false
+		} else if k != c.kind {
+			c.kind = kindString
+			break
+		}
+	}
+	if c.kind != kindString {
+		return nil
+	}
+
+	c.strings = make([]string, len(c.values))
+	for i, v := range c.values {
+		if v == nil {
+			continue
+		}
+		s, err := stringValue(v)
+		if err != nil {
+			return err
+		}
+		c.strings[i] = s
+	}
+	return nil
+}
+
+// stringValue returns the value as a string. Strings and numbers are
+// formatted as is and other values as JSON.
+func stringValue(v mmdbtype.DataType) (string, error) {
+	switch v := v.(type) {
+	case mmdbtype.String:
+		return string(v), nil
+	case mmdbtype.Uint64:
+		return fmt.Sprint(uint64(v)), nil
+	case *mmdbtype.Uint128:
+		return (*big.Int)(v).String(), nil
+	case mmdbtype.FixedUint128:
+		return v.BigInt().String(), nil
+	}
+	jv, err := mmdbtype.ToAny(v)
+	if err != nil {
+		return "", err
+	}
+	b, err := json.Marshal(jv)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
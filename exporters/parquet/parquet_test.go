@@ -0,0 +1,293 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtest"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrite(t *testing.T) {
+	tree := testTree(t)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, Write(buf, tree, Options{RowGroupSize: 2}))
+
+	columns := readFile(t, buf.Bytes())
+	assert.Equal(t, map[string][]any{
+		"network":          {"1.1.1.0/24", "2.2.2.0/24", "3.3.3.0/24"},
+		"anycast":          {true, nil, false},
+		"asn":              {"13335", nil, "unknown"},
+		"country_iso_code": {"AU", "FR", nil},
+		"lat":              {nil, 48.85, nil},
+		"rank":             {int64(7), nil, nil},
+		"tags":             {nil, `["a",1]`, nil},
+	}, columns)
+
+	buf.Reset()
+	require.NoError(t, Write(buf, tree, Options{
+		Columns:   []string{"country.iso_code"},
+		Separator: ".",
+	}))
+	assert.Equal(t, map[string][]any{
+		"network":          {"1.1.1.0/24", "2.2.2.0/24", "3.3.3.0/24"},
+		"country.iso_code": {"AU", "FR", nil},
+	}, readFile(t, buf.Bytes()))
+
+	_, network, err := net.ParseCIDR("4.4.4.0/24")
+	require.NoError(t, err)
+	require.NoError(t, tree.Insert(network, mmdbtype.Map{"a_b": mmdbtype.Bool(true)}))
+	_, network, err = net.ParseCIDR("5.5.5.0/24")
+	require.NoError(t, err)
+	require.NoError(t, tree.Insert(network, mmdbtype.Map{"a": mmdbtype.Map{"b": mmdbtype.Bool(true)}}))
+	err = Write(&bytes.Buffer{}, tree, Options{})
+	require.EqualError(t, err, `the paths "a.b" and "a_b" have the same column name "a_b"`)
+}
+
+// TestWriteGolden checks that the file written for the tree is the same as
+// testdata/golden.parquet. As the file is encoded by this package, it is
+// checked with a reference implementation by testdata/verify_golden.py,
+// which reads it with pyarrow, and the script must be run again whenever
+// the golden file is updated.
+func TestWriteGolden(t *testing.T) {
+	buf := &bytes.Buffer{}
+	require.NoError(t, Write(buf, testTree(t), Options{RowGroupSize: 2}))
+
+	path := filepath.Join("testdata", "golden.parquet")
+	if os.Getenv(mmdbtest.UpdateGoldenEnv) != "" {
+		require.NoError(t, os.WriteFile(path, buf.Bytes(), 0o644))
+		return
+	}
+	expected, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, expected, buf.Bytes(), "set %s to update %s", mmdbtest.UpdateGoldenEnv, path)
+}
+
+func TestWriteEmpty(t *testing.T) {
+	tree, err := mmdbwriter.New(mmdbwriter.Options{})
+	require.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, Write(buf, tree, Options{}))
+	assert.Equal(t, map[string][]any{"network": nil}, readFile(t, buf.Bytes()))
+}
+
+func testTree(t *testing.T) *mmdbwriter.Tree {
+	tree, err := mmdbwriter.New(mmdbwriter.Options{IPVersion: 4})
+	require.NoError(t, err)
+
+	inserts := []struct {
+		network string
+		value   mmdbtype.DataType
+	}{
+		{
+			network: "1.1.1.0/24",
+			value: mmdbtype.Map{
+				"country": mmdbtype.Map{"iso_code": mmdbtype.String("AU")},
+				"asn":     mmdbtype.Uint32(13335),
+				"anycast": mmdbtype.Bool(true),
+				"rank":    mmdbtype.Uint16(7),
+			},
+		},
+		{
+			network: "2.2.2.0/24",
+			value: mmdbtype.Map{
+				"country": mmdbtype.Map{"iso_code": mmdbtype.String("FR")},
+				"tags":    mmdbtype.Slice{mmdbtype.String("a"), mmdbtype.Uint16(1)},
+				"lat":     mmdbtype.Float64(48.85),
+			},
+		},
+		{
+			network: "3.3.3.0/24",
+			value: mmdbtype.Map{
+				"asn":     mmdbtype.String("unknown"),
+				"anycast": mmdbtype.Bool(false),
+			},
+		},
+	}
+	for _, insert := range inserts {
+		_, network, err := net.ParseCIDR(insert.network)
+		require.NoError(t, err)
+		require.NoError(t, tree.Insert(network, insert.value))
+	}
+	return tree
+}
+
+// readFile reads the Parquet file written by Write, returning the values of
+// each column.
+func readFile(t *testing.T, b []byte) map[string][]any {
+	require.Equal(t, magic, b[:4])
+	require.Equal(t, magic, b[len(b)-4:])
+	metadataLen := int(binary.LittleEndian.Uint32(b[len(b)-8:]))
+	r := &thriftReader{b: b[len(b)-8-metadataLen : len(b)-8]}
+	metadata := r.readStruct(t)
+	require.Empty(t, r.b)
+
+	schema := metadata[2].([]any)
+	names := make([]string, 0, len(schema)-1)
+	columns := map[string][]any{}
+	required := map[string]bool{}
+	types := map[string]int64{}
+	for _, e := range schema[1:] {
+		element := e.(map[int16]any)
+		name := string(element[4].([]byte))
+		names = append(names, name)
+		columns[name] = nil
+		required[name] = element[3].(int64) == repetitionRequired
+		types[name] = element[1].(int64)
+	}
+	assert.Equal(t, int64(len(names)), schema[0].(map[int16]any)[5])
+
+	var rows int64
+	for _, rg := range metadata[4].([]any) {
+		rowGroup := rg.(map[int16]any)
+		numRows := rowGroup[3].(int64)
+		rows += numRows
+		for i, c := range rowGroup[1].([]any) {
+			meta := c.(map[int16]any)[3].(map[int16]any)
+			name := names[i]
+			assert.Equal(t, name, string(meta[3].([]any)[0].([]byte)))
+			assert.Equal(t, numRows, meta[5])
+
+			pr := &thriftReader{b: b[meta[9].(int64):]}
+			header := pr.readStruct(t)
+			dataHeader := header[5].(map[int16]any)
+			assert.Equal(t, numRows, dataHeader[1])
+			page := pr.b[:header[2].(int64)]
+			assert.Equal(t, meta[6].(int64), int64(len(b[meta[9].(int64):]))-int64(len(pr.b))+header[2].(int64))
+
+			columns[name] = append(columns[name], readPage(t, page, int(numRows), required[name], types[name])...)
+		}
+	}
+	assert.Equal(t, rows, metadata[3])
+	return columns
+}
+
+func readPage(t *testing.T, page []byte, numValues int, required bool, typ int64) []any {
+	present := make([]bool, numValues)
+	for i := range present {
+		present[i] = true
+	}
+	if !required {
+		n := int(binary.LittleEndian.Uint32(page))
+		levels := page[4 : 4+n]
+		page = page[4+n:]
+		header, hn := binary.Uvarint(levels)
+		require.Equal(t, uint64(1), header&1, "bit-packed run")
+		require.Equal(t, (numValues+7)/8, int(header>>1))
+		for i := range present {
+			present[i] = levels[hn+i/8]&(1<<(i%8)) != 0
+		}
+	}
+
+	values := make([]any, numValues)
+	bit := 0
+	numPresent := 0
+	for _, p := range present {
+		if p {
+			numPresent++
+		}
+	}
+	for i, p := range present {
+		if !p {
+			continue
+		}
+		switch typ {
+		case typeByteArray:
+			n := int(binary.LittleEndian.Uint32(page))
+			values[i] = string(page[4 : 4+n])
+			page = page[4+n:]
+		case typeDouble:
+			values[i] = math.Float64frombits(binary.LittleEndian.Uint64(page))
+			page = page[8:]
+		case typeInt64:
+			values[i] = int64(binary.LittleEndian.Uint64(page))
+			page = page[8:]
+		case typeBoolean:
+			values[i] = page[bit/8]&(1<<(bit%8)) != 0
+			bit++
+		default:
+			t.Fatalf("unexpected type %d", typ)
+		}
+	}
+	if typ == typeBoolean {
+		page = page[(numPresent+7)/8:]
+	}
+	require.Empty(t, page)
+	return values
+}
+
+// thriftReader decodes the Thrift compact protocol.
+type thriftReader struct {
+	b []byte
+}
+
+func (r *thriftReader) varint(t *testing.T) uint64 {
+	v, n := binary.Uvarint(r.b)
+	require.Positive(t, n)
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *thriftReader) zigzag(t *testing.T) int64 {
+	v := r.varint(t)
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *thriftReader) readStruct(t *testing.T) map[int16]any {
+	fields := map[int16]any{}
+	var last int16
+	for {
+		header := r.b[0]
+		r.b = r.b[1:]
+		if header == 0 {
+			return fields
+		}
+		typ := header & 0x0F
+		id := last + int16(header>>4)
+		if header>>4 == 0 {
+			id = int16(r.zigzag(t))
+		}
+		last = id
+		fields[id] = r.readValue(t, typ)
+	}
+}
+
+func (r *thriftReader) readValue(t *testing.T, typ byte) any {
+	switch typ {
+	case thriftI32, thriftI64:
+		return r.zigzag(t)
+	case thriftBinary:
+		n := r.varint(t)
+		v := r.b[:n]
+		r.b = r.b[n:]
+		return v
+	case thriftList:
+		header := r.b[0]
+		r.b = r.b[1:]
+		size := int(header >> 4)
+		if size == 15 {
+			size = int(r.varint(t))
+		}
+		list := make([]any, size)
+		for i := range list {
+			list[i] = r.readValue(t, header&0x0F)
+		}
+		return list
+	case thriftStruct:
+		return r.readStruct(t)
+	default:
+		require.NoError(t, errors.New("unexpected Thrift type"))
+		return nil
+	}
+}
@@ -0,0 +1,74 @@
+#!/usr/bin/env python3
+"""Checks golden.parquet with pyarrow, a reference Parquet implementation.
+
+The golden file is written by TestWriteGolden. Run this script after
+updating it:
+
+    pip install pyarrow
+    python3 exporters/parquet/testdata/verify_golden.py
+"""
+
+import os
+import sys
+
+import pyarrow as pa
+import pyarrow.parquet as pq
+
+path = os.path.join(os.path.dirname(os.path.abspath(__file__)), "golden.parquet")
+f = pq.ParquetFile(path)
+
+expected_schema = pa.schema(
+    [
+        pa.field("network", pa.string(), nullable=False),
+        pa.field("anycast", pa.bool_()),
+        pa.field("asn", pa.string()),
+        pa.field("country_iso_code", pa.string()),
+        pa.field("lat", pa.float64()),
+        pa.field("rank", pa.int64()),
+        pa.field("tags", pa.string()),
+    ]
+)
+expected_rows = [
+    {
+        "network": "1.1.1.0/24",
+        "anycast": True,
+        "asn": "13335",
+        "country_iso_code": "AU",
+        "lat": None,
+        "rank": 7,
+        "tags": None,
+    },
+    {
+        "network": "2.2.2.0/24",
+        "anycast": None,
+        "asn": None,
+        "country_iso_code": "FR",
+        "lat": 48.85,
+        "rank": None,
+        "tags": '["a",1]',
+    },
+    {
+        "network": "3.3.3.0/24",
+        "anycast": False,
+        "asn": "unknown",
+        "country_iso_code": None,
+        "lat": None,
+        "rank": None,
+        "tags": None,
+    },
+]
+
+table = f.read()
+errors = []
+if f.metadata.num_row_groups != 2:
+    errors.append(f"expected 2 row groups, got {f.metadata.num_row_groups}")
+if not table.schema.equals(expected_schema):
+    errors.append(f"expected the schema\n{expected_schema}\ngot\n{table.schema}")
+if table.to_pylist() != expected_rows:
+    errors.append(f"expected the rows\n{expected_rows}\ngot\n{table.to_pylist()}")
+
+for error in errors:
+    print(error, file=sys.stderr)
+if errors:
+    sys.exit(1)
+print(f"{path} is valid")
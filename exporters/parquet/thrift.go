@@ -0,0 +1,115 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// The types of the Thrift compact protocol.
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes structs with the Thrift compact protocol, which is
+// used for the page headers and the file metadata.
+type thriftWriter struct {
+	buf bytes.Buffer
+
+	// lastField is the ID of the last field written in the current struct.
+	// The IDs of the enclosing structs are kept in fieldStack.
+	lastField  int16
+	fieldStack []int16
+}
+
+func (w *thriftWriter) fieldHeader(id int16, typ byte) {
+	delta := id - w.lastField
+	if delta > 0 && delta <= 15 {
+		w.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		w.buf.WriteByte(typ)
+		w.varint(uint64(zigzag(int64(id))))
+	}
+	w.lastField = id
+}
+
+func (w *thriftWriter) varint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], v)
+	w.buf.Write(b[:n])
+}
+
+func zigzag(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
+
+func (w *thriftWriter) i32(id int16, v int32) {
+	w.fieldHeader(id, thriftI32)
+	w.varint(zigzag(int64(v)))
+}
+
+func (w *thriftWriter) i64(id int16, v int64) {
+	w.fieldHeader(id, thriftI64)
+	w.varint(zigzag(v))
+}
+
+func (w *thriftWriter) string(id int16, v string) {
+	w.fieldHeader(id, thriftBinary)
+	w.varint(uint64(len(v)))
+	w.buf.WriteString(v)
+}
+
+func (w *thriftWriter) listHeader(id int16, elemType byte, size int) {
+	w.fieldHeader(id, thriftList)
+	if size < 15 {
+		w.buf.WriteByte(byte(size)<<4 | elemType)
+		return
+	}
+	w.buf.WriteByte(0xF0 | elemType)
+	w.varint(uint64(size))
+}
+
+func (w *thriftWriter) i32List(id int16, vs []int32) {
+	w.listHeader(id, thriftI32, len(vs))
+	for _, v := range vs {
+		w.varint(zigzag(int64(v)))
+	}
+}
+
+func (w *thriftWriter) stringList(id int16, vs []string) {
+	w.listHeader(id, thriftBinary, len(vs))
+	for _, v := range vs {
+		w.varint(uint64(len(v)))
+		w.buf.WriteString(v)
+	}
+}
+
+// structList writes the header of a list of structs. Each struct must then
+// be written with beginStruct and endStruct.
+func (w *thriftWriter) structList(id int16, size int) {
+	w.listHeader(id, thriftStruct, size)
+}
+
+// structField begins a struct field, which must be ended with endStruct.
+func (w *thriftWriter) structField(id int16) {
+	w.fieldHeader(id, thriftStruct)
+	w.beginStruct()
+}
+
+// beginStruct begins a struct, e.g., an element of a list.
+func (w *thriftWriter) beginStruct() {
+	w.fieldStack = append(w.fieldStack, w.lastField)
+	w.lastField = 0
+}
+
+// endStruct writes the stop field of the struct.
+func (w *thriftWriter) endStruct() {
+	w.buf.WriteByte(0)
+	if len(w.fieldStack) > 0 {
+		w.lastField = w.fieldStack[len(w.fieldStack)-1]
+		w.fieldStack = w.fieldStack[:len(w.fieldStack)-1]
+	}
+}
@@ -0,0 +1,80 @@
+package mmdbwriter
+
+import (
+	"net"
+
+	"github.com/maxmind/mmdbwriter/inserter"
+)
+
+// Extract returns a new tree with the same options as t that contains only
+// the data within the given networks. Data for networks larger than one of
+// the given networks is reduced to that network. The original tree is not
+// modified.
+func (t *Tree) Extract(networks []*net.IPNet) (*Tree, error) {
+	newTree, err := New(t.options())
+	if err != nil {
+		return nil, err
+	}
+
+	for _, network := range networks {
+		ip, prefixLen := t.treeNetwork(network)
+		err := t.walkWithin(ip, prefixLen, func(ip net.IP, prefixLen int, r record) error {
+			if r.recordType != recordTypeData {
+				return nil
+			}
+			recNetwork := &net.IPNet{
+				IP:   make(net.IP, len(ip)),
+				Mask: net.CIDRMask(prefixLen, t.treeDepth),
+			}
+			copy(recNetwork.IP, ip)
+			return newTree.insert(
+				recNetwork,
+				recordTypeData,
+				inserter.ReplaceWith(t.dataMap.load(r.value)),
+				nil,
+			)
+		})
+		if err != nil {
+			newTree.Close() //nolint:errcheck // the walk error is more relevant
+			return nil, err
+		}
+	}
+	return newTree, nil
+}
+
+// treeNetwork returns a copy of the network's address, masked and converted
+// to the form used in the tree, along with its prefix length in the tree.
+func (t *Tree) treeNetwork(network *net.IPNet) (net.IP, int) {
+	prefixLen, _ := network.Mask.Size()
+
+	ip := network.IP.Mask(network.Mask)
+	switch {
+	case t.treeDepth == 128 && len(ip) == 4:
+		ip = t.ipv4ToTree(ip)
+		prefixLen += 96
+	case t.treeDepth == 32 && len(ip) == net.IPv6len:
+		ip, prefixLen = unmapIPv4(ip, prefixLen)
+	}
+	return ip, prefixLen
+}
+
+// walkWithin calls fn for each non-node record within the network given by
+// ip and prefixLen. If a record contains the network, fn is called once for
+// the network rather than the full record. Aliased records are skipped.
+func (t *Tree) walkWithin(ip net.IP, prefixLen int, fn walkFunc) error {
+	n := t.root
+	for depth := 0; ; depth++ {
+		if depth == prefixLen {
+			return n.walk(ip, depth, fn)
+		}
+		r := n.children[bitAt(ip, depth)]
+		switch r.recordType {
+		case recordTypeNode, recordTypeFixedNode:
+			n = r.node
+		case recordTypeAlias:
+			return nil
+		default:
+			return fn(ip, prefixLen, r)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package mmdbwriter
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtract(t *testing.T) {
+	tree, err := New(
+		Options{
+			DatabaseType: "mmdbwriter-test",
+			Description:  map[string]string{"en": "Test database"},
+		},
+	)
+	require.NoError(t, err)
+
+	for _, insert := range []testInsert{
+		{network: "1.0.0.0/8", value: mmdbtype.String("1/8")},
+		{network: "1.1.1.0/24", value: mmdbtype.String("1.1.1/24")},
+		{network: "2.2.2.0/24", value: mmdbtype.String("2.2.2/24")},
+		{network: "2003::/16", value: mmdbtype.String("2003/16")},
+		{network: "2400::/12", value: mmdbtype.String("2400/12")},
+	} {
+		_, network, err := net.ParseCIDR(insert.network)
+		require.NoError(t, err)
+		require.NoError(t, tree.Insert(network, insert.value))
+	}
+
+	var prefixes []*net.IPNet
+	for _, p := range []string{"1.1.0.0/16", "2400::/11"} {
+		_, network, err := net.ParseCIDR(p)
+		require.NoError(t, err)
+		prefixes = append(prefixes, network)
+	}
+
+	extracted, err := tree.Extract(prefixes)
+	require.NoError(t, err)
+
+	for _, get := range []testGet{
+		{ip: "1.1.1.1", expectedNetwork: "1.1.1.0/24", expectedGetValue: mmdbtype.String("1.1.1/24")},
+		{ip: "1.1.2.1", expectedNetwork: "1.1.2.0/23", expectedGetValue: mmdbtype.String("1/8")},
+		{ip: "1.2.1.1", expectedNetwork: "1.2.0.0/15"},
+		{ip: "2.2.2.2", expectedNetwork: "2.0.0.0/7"},
+		{ip: "2003::", expectedNetwork: "2003::/16"},
+		{ip: "2400::", expectedNetwork: "2400::/12", expectedGetValue: mmdbtype.String("2400/12")},
+	} {
+		network, value := extracted.Get(net.ParseIP(get.ip))
+		assert.Equal(t, get.expectedNetwork, network.String(), "network for %s", get.ip)
+		assert.Equal(t, get.expectedGetValue, value, "value for %s", get.ip)
+	}
+
+	_, value := tree.Get(net.ParseIP("2.2.2.2"))
+	assert.Equal(t, mmdbtype.String("2.2.2/24"), value, "original tree is unchanged")
+
+	buf := &bytes.Buffer{}
+	_, err = extracted.WriteTo(buf)
+	require.NoError(t, err)
+	checkMMDB(t, buf, []testGet{
+		{ip: "1.1.1.1", expectedNetwork: "1.1.1.0/24", expectedLookupValue: s2ip("1.1.1/24")},
+		{ip: "2.2.2.2", expectedNetwork: "2.0.0.0/7"},
+	}, "MMDB lookups on extracted tree")
+}
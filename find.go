@@ -0,0 +1,89 @@
+package mmdbwriter
+
+import (
+	"net"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+// FindIterator iterates over the networks matching the predicate passed to
+// Tree.Find. It is used like maxminddb.Networks:
+//
+//	it := tree.Find(pred)
+//	for it.Next() {
+//		network, value := it.Network()
+//		...
+//	}
+type FindIterator struct {
+	tree    *Tree
+	pred    func(mmdbtype.DataType) bool
+	stack   []findRecord
+	network *net.IPNet
+	value   mmdbtype.DataType
+}
+
+type findRecord struct {
+	r         record
+	ip        net.IP
+	prefixLen int
+}
+
+// Find returns an iterator over the networks with data for which pred
+// returns true, in the same order as Walk. Aliased networks are skipped,
+// and networks in the IPv4 subtree of an IPv6 tree are returned as IPv4
+// networks.
+//
+// The networks are found as the iterator advances rather than being
+// collected up front. The tree must not be modified until the iteration is
+// complete.
+func (t *Tree) Find(pred func(mmdbtype.DataType) bool) *FindIterator {
+	it := &FindIterator{
+		tree: t,
+		pred: pred,
+	}
+	it.pushChildren(t.root, make(net.IP, t.treeDepth/8), 0)
+	return it
+}
+
+// pushChildren pushes the records of the node so that the record for the
+// lower half of the network is popped first.
+func (it *FindIterator) pushChildren(n *node, ip net.IP, depth int) {
+	upper := make(net.IP, len(ip))
+	copy(upper, ip)
+	setBitAt(upper, depth)
+	it.stack = append(
+		it.stack,
+		findRecord{r: n.children[1], ip: upper, prefixLen: depth + 1},
+		findRecord{r: n.children[0], ip: ip, prefixLen: depth + 1},
+	)
+}
+
+// Next advances the iterator to the next matching network. It returns false
+// when there are no more networks.
+func (it *FindIterator) Next() bool {
+	for len(it.stack) > 0 {
+		fr := it.stack[len(it.stack)-1]
+		it.stack = it.stack[:len(it.stack)-1]
+
+		switch fr.r.recordType {
+		case recordTypeNode, recordTypeFixedNode:
+			it.pushChildren(fr.r.node, fr.ip, fr.prefixLen)
+		case recordTypeData:
+			value := it.tree.dataMap.load(fr.r.value)
+			if it.pred(value) {
+				it.network = it.tree.network(fr.ip, fr.prefixLen)
+				it.value = value
+				return true
+			}
+		default:
+		}
+	}
+	it.network = nil
+	it.value = nil
+	return false
+}
+
+// Network returns the current network and its value.
+func (it *FindIterator) Network() (*net.IPNet, mmdbtype.DataType) {
+	return it.network, it.value
+}
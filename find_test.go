@@ -0,0 +1,60 @@
+package mmdbwriter
+
+import (
+	"net"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFind(t *testing.T) {
+	tree, err := New(Options{})
+	require.NoError(t, err)
+
+	ru := mmdbtype.Map{"country": mmdbtype.String("RU")}
+	us := mmdbtype.Map{"country": mmdbtype.String("US")}
+
+	for _, insert := range []struct {
+		network string
+		value   mmdbtype.DataType
+	}{
+		{network: "1.1.1.0/24", value: ru},
+		{network: "1.1.2.0/24", value: us},
+		{network: "2.0.0.0/8", value: ru},
+		{network: "2600::/32", value: us},
+		{network: "2a00::/31", value: ru},
+		{network: "2a00::/32", value: us},
+		{network: "2c00::/16", value: mmdbtype.String("RU")},
+	} {
+		_, ipNet, err := net.ParseCIDR(insert.network)
+		require.NoError(t, err)
+		require.NoError(t, tree.Insert(ipNet, insert.value))
+	}
+
+	isRU := func(v mmdbtype.DataType) bool {
+		m, ok := v.(mmdbtype.Map)
+		return ok && m["country"] == mmdbtype.String("RU")
+	}
+
+	var networks []string
+	it := tree.Find(isRU)
+	for it.Next() {
+		network, value := it.Network()
+		assert.Equal(t, ru, value)
+		networks = append(networks, network.String())
+	}
+	assert.Equal(
+		t,
+		[]string{"1.1.1.0/24", "2.0.0.0/8", "2a00:1::/32"},
+		networks,
+	)
+	assert.False(t, it.Next(), "the iterator stays exhausted")
+
+	it = tree.Find(func(mmdbtype.DataType) bool { return false })
+	assert.False(t, it.Next())
+	network, value := it.Network()
+	assert.Nil(t, network)
+	assert.Nil(t, value)
+}
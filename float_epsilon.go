@@ -0,0 +1,72 @@
+package mmdbwriter
+
+import (
+	"math"
+
+	"github.com/maxmind/mmdbwriter/inserter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+// floatCanonicalizer replaces floating point values with an earlier value
+// that is within epsilon of them. The values seen are kept in buckets of
+// width epsilon, so only the bucket of a value and its neighbors need to be
+// searched.
+type floatCanonicalizer struct {
+	epsilon float64
+	buckets map[int64][]float64
+}
+
+func newFloatCanonicalizer(epsilon float64) *floatCanonicalizer {
+	return &floatCanonicalizer{
+		epsilon: epsilon,
+		buckets: map[int64][]float64{},
+	}
+}
+
+// canonical returns the closest value within epsilon of f that was seen
+// before, or f itself if there is none. If register is set, f is recorded
+// as a canonical value in that case. NaN, infinite, and very large values
+// are returned unchanged.
+func (c *floatCanonicalizer) canonical(f float64, register bool) float64 {
+	b := math.Floor(f / c.epsilon)
+	if math.IsNaN(b) || math.Abs(b) >= 1<<62 {
+		return f
+	}
+	bucket := int64(b)
+
+	closest := f
+	closestDiff := math.Inf(1)
+	for i := bucket - 1; i <= bucket+1; i++ {
+		for _, v := range c.buckets[i] {
+			if d := math.Abs(v - f); d <= c.epsilon && d < closestDiff {
+				closest = v
+				closestDiff = d
+			}
+		}
+	}
+	if register && math.IsInf(closestDiff, 1) {
+		c.buckets[bucket] = append(c.buckets[bucket], f)
+	}
+	return closest
+}
+
+// apply returns v with all floating point values replaced by their
+// canonical values. As with roundFloats, v is never modified.
+func (c *floatCanonicalizer) apply(v mmdbtype.DataType, register bool) mmdbtype.DataType {
+	return mapFloats(v, func(f float64) float64 {
+		return c.canonical(f, register)
+	})
+}
+
+// floatEpsilonInserter wraps an inserter function so that the floating
+// point values in the value it returns are replaced by their canonical
+// values.
+func floatEpsilonInserter(f inserter.Func, c *floatCanonicalizer, register bool) inserter.Func {
+	return func(existing mmdbtype.DataType) (mmdbtype.DataType, error) {
+		v, err := f(existing)
+		if err != nil || v == nil {
+			return v, err
+		}
+		return c.apply(v, register), nil
+	}
+}
@@ -0,0 +1,71 @@
+package mmdbwriter
+
+import (
+	"math"
+	"net"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFloatEpsilon(t *testing.T) {
+	tree, err := New(Options{IPVersion: 4, FloatEpsilon: 1e-6})
+	require.NoError(t, err)
+
+	location := func(lat float64, lon float32) mmdbtype.Map {
+		return mmdbtype.Map{
+			"location": mmdbtype.Map{
+				"latitude":  mmdbtype.Float64(lat),
+				"longitude": mmdbtype.Float32(lon),
+			},
+		}
+	}
+	original := location(47.6062095, -122.3320708)
+	require.NoError(t, tree.Insert(mustNetwork(t, "1.1.0.0/24"), original))
+
+	noisy := location(47.60620951, -122.33207)
+	noisyCopy := noisy.Copy()
+	require.NoError(t, tree.Insert(mustNetwork(t, "1.1.1.0/24"), noisy))
+	assert.Equal(t, noisyCopy, noisy, "inserted value is not modified")
+
+	network, value := tree.Get(net.ParseIP("1.1.1.1").To4())
+	assert.Equal(t, original, value)
+	assert.Equal(t, "1.1.0.0/23", network.String(), "records within the epsilon are merged")
+
+	require.NoError(t, tree.Insert(mustNetwork(t, "2.2.2.0/24"), location(47.6063, -122.3320708)))
+	_, value = tree.Get(net.ParseIP("2.2.2.2").To4())
+	assert.Equal(t, location(47.6063, -122.3320708), value, "values outside of the epsilon are kept")
+
+	assert.Len(t, tree.dataMap.data, 2)
+	assert.Equal(t, 1e-6, tree.options().FloatEpsilon)
+}
+
+func TestFloatCanonicalizer(t *testing.T) {
+	c := newFloatCanonicalizer(0.1)
+
+	assert.Equal(t, 1.0, c.canonical(1.0, true))
+	assert.Equal(t, 1.0, c.canonical(1.05, true))
+	// 0.95 is in the neighboring bucket.
+	assert.Equal(t, 1.0, c.canonical(0.95, true))
+	// The closest value is used.
+	assert.Equal(t, 1.2, c.canonical(1.2, true))
+	assert.Equal(t, 1.2, c.canonical(1.14, true))
+	assert.Equal(t, -1.0, c.canonical(-1.0, true))
+
+	// Values that are not registered are not used.
+	assert.Equal(t, 5.0, c.canonical(5.0, false))
+	assert.Equal(t, 5.05, c.canonical(5.05, true))
+
+	assert.True(t, math.IsNaN(c.canonical(math.NaN(), true)))
+	assert.Equal(t, math.Inf(1), c.canonical(math.Inf(1), true))
+	assert.Equal(t, math.MaxFloat64, c.canonical(math.MaxFloat64, true))
+}
+
+func TestFloatEpsilonInvalid(t *testing.T) {
+	for _, epsilon := range []float64{-1, math.NaN(), math.Inf(1)} {
+		_, err := New(Options{FloatEpsilon: epsilon})
+		assert.ErrorContains(t, err, "FloatEpsilon must be a finite, non-negative number")
+	}
+}
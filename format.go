@@ -0,0 +1,125 @@
+package mmdbwriter
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+
+	"github.com/maxmind/mmdbwriter/inserter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+// FormatFeature is a feature of the MaxMind DB format that some readers,
+// e.g., old or minimal implementations, do not support. All of the
+// features are part of version 2.0 of the format, so they may only be
+// disabled explicitly with Options.DisabledFormatFeatures.
+type FormatFeature int
+
+const (
+	// FormatFeaturePointers is the use of pointers to values written
+	// earlier in the data section and the metadata. Without pointers, each
+	// distinct record is still only written once, but the values shared
+	// between records are repeated in each of them.
+	FormatFeaturePointers FormatFeature = iota + 1
+
+	// FormatFeatureUint128 is the uint128 type, mmdbtype.Uint128. Inserting
+	// a value containing a Uint128 returns an error.
+	FormatFeatureUint128
+)
+
+func (f FormatFeature) String() string {
+	switch f {
+	case FormatFeaturePointers:
+		return "FormatFeaturePointers"
+	case FormatFeatureUint128:
+		return "FormatFeatureUint128"
+	default:
+		return fmt.Sprintf("FormatFeature(%d)", int(f))
+	}
+}
+
+// formatOptions are the format settings of a tree.
+type formatOptions struct {
+	majorVersion    int
+	minorVersion    int
+	disabled        []FormatFeature
+	disablePointers bool
+	disableUint128  bool
+}
+
+func newFormatOptions(opts Options) (formatOptions, error) {
+	f := formatOptions{
+		majorVersion: 2,
+		minorVersion: opts.BinaryFormatMinorVersion,
+		disabled:     append([]FormatFeature(nil), opts.DisabledFormatFeatures...),
+	}
+	if opts.BinaryFormatMajorVersion != 0 {
+		f.majorVersion = opts.BinaryFormatMajorVersion
+	}
+	if f.majorVersion < 0 || f.majorVersion > math.MaxUint16 {
+		return f, fmt.Errorf("invalid BinaryFormatMajorVersion: %d", opts.BinaryFormatMajorVersion)
+	}
+	if f.minorVersion < 0 || f.minorVersion > math.MaxUint16 {
+		return f, fmt.Errorf("invalid BinaryFormatMinorVersion: %d", opts.BinaryFormatMinorVersion)
+	}
+
+	for _, feature := range f.disabled {
+		switch feature {
+		case FormatFeaturePointers:
+			f.disablePointers = true
+		case FormatFeatureUint128:
+			f.disableUint128 = true
+		default:
+			return f, fmt.Errorf("unsupported FormatFeature: %d", int(feature))
+		}
+	}
+	return f, nil
+}
+
+// ErrFormatFeatureDisabled is wrapped by the error returned when inserting
+// a value that requires a feature disabled by
+// Options.DisabledFormatFeatures.
+var ErrFormatFeatureDisabled = errors.New("the format feature is disabled")
+
+// noUint128Inserter returns an error if the value returned by f contains a
+// Uint128.
+func noUint128Inserter(f inserter.Func, network *net.IPNet) inserter.Func {
+	return func(existing mmdbtype.DataType) (mmdbtype.DataType, error) {
+		v, err := f(existing)
+		if err != nil || v == nil {
+			return v, err
+		}
+		if containsUint128(v) {
+			return nil, fmt.Errorf(
+				"the value for %s contains a Uint128 (%s): %w",
+				network,
+				FormatFeatureUint128,
+				ErrFormatFeatureDisabled,
+			)
+		}
+		return v, nil
+	}
+}
+
+func containsUint128(v mmdbtype.DataType) bool {
+	switch v := v.(type) {
+	case *mmdbtype.Uint128:
+		return true
+	case mmdbtype.Map:
+		for _, e := range v {
+			if containsUint128(e) {
+				return true
+			}
+		}
+	case mmdbtype.Slice:
+		for _, e := range v {
+			if containsUint128(e) {
+				return true
+			}
+		}
+	case mmdbtype.Templated:
+		return containsUint128(v.Template) || containsUint128(v.Overrides)
+	}
+	return false
+}
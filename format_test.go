@@ -0,0 +1,117 @@
+package mmdbwriter
+
+import (
+	"bytes"
+	"math/big"
+	"net"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/oschwald/maxminddb-golang"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBinaryFormatVersion(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  Options
+		major uint16
+		minor uint16
+	}{
+		{name: "default", major: 2, minor: 0},
+		{name: "minor", opts: Options{BinaryFormatMinorVersion: 1}, major: 2, minor: 1},
+		{
+			name:  "major",
+			opts:  Options{BinaryFormatMajorVersion: 3, BinaryFormatMinorVersion: 2},
+			major: 3,
+			minor: 2,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tree, err := New(test.opts)
+			require.NoError(t, err)
+			buf := &bytes.Buffer{}
+			_, err = tree.WriteTo(buf)
+			require.NoError(t, err)
+
+			metadata, err := readMetadata(buf.Bytes())
+			require.NoError(t, err)
+			assert.Equal(t, mmdbtype.Uint16(test.major), metadata["binary_format_major_version"])
+			assert.Equal(t, mmdbtype.Uint16(test.minor), metadata["binary_format_minor_version"])
+		})
+	}
+}
+
+func TestFormatFeaturePointers(t *testing.T) {
+	shared := mmdbtype.String("a string that is shared between the records")
+	write := func(opts Options) []byte {
+		tree, err := New(opts)
+		require.NoError(t, err)
+		for i, network := range []string{"1.1.1.0/24", "2.2.2.0/24"} {
+			require.NoError(t, tree.Insert(mustNetwork(t, network), mmdbtype.Map{
+				"i":      mmdbtype.Uint32(i),
+				"shared": shared,
+			}))
+		}
+		buf := &bytes.Buffer{}
+		_, err = tree.WriteTo(buf)
+		require.NoError(t, err)
+		return buf.Bytes()
+	}
+
+	db := write(Options{})
+	assert.Equal(t, 1, bytes.Count(db, []byte(shared)))
+
+	db = write(Options{DisabledFormatFeatures: []FormatFeature{FormatFeaturePointers}})
+	assert.Equal(t, 2, bytes.Count(db, []byte(shared)))
+
+	reader, err := maxminddb.FromBytes(db)
+	require.NoError(t, err)
+	var record map[string]any
+	require.NoError(t, reader.Lookup(net.ParseIP("2.2.2.2"), &record))
+	assert.Equal(t, map[string]any{"i": uint64(1), "shared": string(shared)}, record)
+}
+
+func TestFormatFeatureUint128(t *testing.T) {
+	tree, err := New(Options{DisabledFormatFeatures: []FormatFeature{FormatFeatureUint128}})
+	require.NoError(t, err)
+
+	require.NoError(t, tree.Insert(mustNetwork(t, "1.1.1.0/24"), mmdbtype.Map{"a": mmdbtype.Uint64(1)}))
+
+	value := mmdbtype.Map{
+		"a": mmdbtype.Slice{(*mmdbtype.Uint128)(big.NewInt(1))},
+	}
+	err = tree.Insert(mustNetwork(t, "2.2.2.0/24"), value)
+	assert.ErrorIs(t, err, ErrFormatFeatureDisabled)
+	assert.EqualError(
+		t,
+		err,
+		"the value for 2.2.2.0/24 contains a Uint128 (FormatFeatureUint128): the format feature is disabled",
+	)
+}
+
+func TestFormatOptionsValidation(t *testing.T) {
+	tests := []struct {
+		opts Options
+		err  string
+	}{
+		{
+			opts: Options{BinaryFormatMajorVersion: -1},
+			err:  "invalid BinaryFormatMajorVersion: -1",
+		},
+		{
+			opts: Options{BinaryFormatMinorVersion: 1 << 16},
+			err:  "invalid BinaryFormatMinorVersion: 65536",
+		},
+		{
+			opts: Options{DisabledFormatFeatures: []FormatFeature{10}},
+			err:  "unsupported FormatFeature: 10",
+		},
+	}
+	for _, test := range tests {
+		_, err := New(test.opts)
+		assert.EqualError(t, err, test.err)
+	}
+}
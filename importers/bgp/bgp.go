@@ -0,0 +1,134 @@
+// Package bgp builds ASN databases from BGP routing tables, such as the RIB
+// dumps published by the RouteViews and RIPE RIS route collectors.
+//
+// Routes are read with an MRTReader or a TextReader and collected in a
+// Table, which determines the origin AS for each prefix and inserts the
+// prefixes into a tree in the GeoLite2 ASN layout.
+package bgp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/builders/asn"
+)
+
+// Route is a route to a prefix as seen by a single BGP peer.
+type Route struct {
+	// Network is the prefix of the route.
+	Network *net.IPNet
+
+	// OriginAS is the AS that originated the route, i.e., the last AS in the
+	// AS path.
+	OriginAS uint32
+}
+
+// RouteReader is implemented by MRTReader and TextReader.
+type RouteReader interface {
+	// Read returns the next route. It returns io.EOF when there are no more
+	// routes.
+	Read() (Route, error)
+}
+
+// Table collects the routes for each prefix.
+type Table struct {
+	prefixes map[string]*prefixRoutes
+}
+
+type prefixRoutes struct {
+	network *net.IPNet
+	origins map[uint32]int
+}
+
+// NewTable returns an empty Table.
+func NewTable() *Table {
+	return &Table{prefixes: map[string]*prefixRoutes{}}
+}
+
+// Add adds a route to the table.
+func (t *Table) Add(route Route) {
+	key := route.Network.String()
+	p, ok := t.prefixes[key]
+	if !ok {
+		p = &prefixRoutes{
+			network: route.Network,
+			origins: map[uint32]int{},
+		}
+		t.prefixes[key] = p
+	}
+	p.origins[route.OriginAS]++
+}
+
+// AddAll adds all routes from the reader to the table.
+func (t *Table) AddAll(r RouteReader) error {
+	for {
+		route, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		t.Add(route)
+	}
+}
+
+// Len returns the number of prefixes in the table.
+func (t *Table) Len() int {
+	return len(t.prefixes)
+}
+
+// Origin returns the origin AS for the network. When peers disagree about
+// the origin of a prefix, the origin seen by the most peers is used. Ties
+// are broken by using the lowest AS number. The bool is false if the table
+// has no routes for the network.
+func (t *Table) Origin(network *net.IPNet) (uint32, bool) {
+	p, ok := t.prefixes[network.String()]
+	if !ok {
+		return 0, false
+	}
+	return p.origin(), true
+}
+
+func (p *prefixRoutes) origin() uint32 {
+	var origin uint32
+	var count int
+	for asn, c := range p.origins {
+		if c > count || (c == count && asn < origin) {
+			origin = asn
+			count = c
+		}
+	}
+	return origin
+}
+
+// Insert inserts each prefix in the table into the tree with its origin AS
+// in the GeoLite2 ASN layout. Prefixes are inserted from least to most
+// specific so that, when using the default inserter, a more specific prefix
+// takes precedence over the less specific prefixes containing it.
+func (t *Table) Insert(tree *mmdbwriter.Tree) error {
+	prefixes := make([]*prefixRoutes, 0, len(t.prefixes))
+	for _, p := range t.prefixes {
+		prefixes = append(prefixes, p)
+	}
+	sort.Slice(prefixes, func(i, j int) bool {
+		iOnes, _ := prefixes[i].network.Mask.Size()
+		jOnes, _ := prefixes[j].network.Mask.Size()
+		if iOnes != jOnes {
+			return iOnes < jOnes
+		}
+		return prefixes[i].network.String() < prefixes[j].network.String()
+	})
+
+	for _, p := range prefixes {
+		record := asn.Record{Number: p.origin()}
+		if err := tree.Insert(p.network, record.DataType()); err != nil {
+			return fmt.Errorf("inserting %s: %w", p.network, err)
+		}
+	}
+	return nil
+}
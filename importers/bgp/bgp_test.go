@@ -0,0 +1,89 @@
+package bgp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/builders/asn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTextReader(t *testing.T) {
+	r := NewTextReader(strings.NewReader(`# prefix AS_path
+1.0.0.0/24 3356 13335
+
+1.0.4.0/22 174 {64512}
+1.0.8.0/22 174 {64512,64513}
+2001:db8::/32 6939 64496
+`))
+
+	var routes []string
+	for {
+		route, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		routes = append(routes, fmt.Sprintf("%s %d", route.Network, route.OriginAS))
+	}
+	assert.Equal(
+		t,
+		[]string{
+			"1.0.0.0/24 13335",
+			"1.0.4.0/22 64512",
+			"2001:db8::/32 64496",
+		},
+		routes,
+	)
+
+	_, err := NewTextReader(strings.NewReader("1.0.0.0/24\n")).Read()
+	assert.EqualError(t, err, `parsing line 1: expected a prefix and an AS path: "1.0.0.0/24"`)
+
+	_, err = NewTextReader(strings.NewReader("1.0.0.0/24 3356 x\n")).Read()
+	assert.EqualError(t, err, `parsing line 1: invalid origin AS: "x"`)
+}
+
+func TestTable(t *testing.T) {
+	table := NewTable()
+	require.NoError(t, table.AddAll(NewTextReader(strings.NewReader(`1.0.0.0/16 174 64500
+1.0.1.0/24 174 64502
+1.0.1.0/24 3356 64501
+1.0.1.0/24 6939 64502
+1.0.2.0/24 174 64504
+1.0.2.0/24 3356 64503
+`))))
+	assert.Equal(t, 3, table.Len())
+
+	_, network, err := net.ParseCIDR("1.0.1.0/24")
+	require.NoError(t, err)
+	origin, ok := table.Origin(network)
+	assert.True(t, ok)
+	assert.Equal(t, uint32(64502), origin, "the most common origin is used")
+
+	_, network, err = net.ParseCIDR("1.0.2.0/24")
+	require.NoError(t, err)
+	origin, ok = table.Origin(network)
+	assert.True(t, ok)
+	assert.Equal(t, uint32(64503), origin, "ties use the lowest AS number")
+
+	tree, err := mmdbwriter.New(mmdbwriter.Options{})
+	require.NoError(t, err)
+	require.NoError(t, table.Insert(tree))
+
+	tests := map[string]uint32{
+		"1.0.0.1":   64500,
+		"1.0.1.1":   64502,
+		"1.0.2.1":   64503,
+		"1.0.255.1": 64500,
+	}
+	for ip, expected := range tests {
+		_, value := tree.Get(net.ParseIP(ip))
+		assert.Equal(t, asn.Record{Number: expected}.DataType(), value, ip)
+	}
+}
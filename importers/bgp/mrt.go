@@ -0,0 +1,239 @@
+package bgp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+)
+
+// See RFC 6396 for the MRT format and RFC 4271 for the BGP path attributes.
+const (
+	mrtTypeTableDumpV2 = 13
+
+	mrtSubtypeRIBIPv4Unicast = 2
+	mrtSubtypeRIBIPv6Unicast = 4
+
+	bgpAttrFlagExtendedLength = 0x10
+	bgpAttrTypeASPath         = 2
+
+	asPathSegmentSet            = 1
+	asPathSegmentSequence       = 2
+	asPathSegmentConfedSequence = 3
+	asPathSegmentConfedSet      = 4
+)
+
+// MRTReader reads routes from an MRT TABLE_DUMP_V2 RIB dump, as described in
+// RFC 6396. Only the IPv4 and IPv6 unicast RIB records are read. All other
+// records are skipped. Dumps are often compressed; the reader passed to
+// NewMRTReader must return the uncompressed data.
+type MRTReader struct {
+	r       io.Reader
+	body    bytes.Buffer
+	pending []Route
+	offset  int64
+}
+
+// NewMRTReader returns a new MRTReader that reads from r.
+func NewMRTReader(r io.Reader) *MRTReader {
+	return &MRTReader{r: r}
+}
+
+// Read returns the next route. A RIB record contains a route from each peer
+// and these are returned one at a time. Routes whose origin is an AS set
+// with more than one AS are skipped as their origin is ambiguous. It returns
+// io.EOF when there are no more routes.
+func (r *MRTReader) Read() (Route, error) {
+	for len(r.pending) == 0 {
+		if err := r.readRecord(); err != nil {
+			return Route{}, err
+		}
+	}
+	route := r.pending[0]
+	r.pending = r.pending[1:]
+	return route, nil
+}
+
+func (r *MRTReader) readRecord() error {
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(r.r, header); err != nil {
+		if errors.Is(err, io.EOF) {
+			return io.EOF
+		}
+		return fmt.Errorf("reading MRT header at offset %d: %w", r.offset, err)
+	}
+	mrtType := binary.BigEndian.Uint16(header[4:6])
+	subtype := binary.BigEndian.Uint16(header[6:8])
+	length := binary.BigEndian.Uint32(header[8:12])
+
+	offset := r.offset
+	r.offset += int64(len(header)) + int64(length)
+
+	var addrLen int
+	if mrtType == mrtTypeTableDumpV2 {
+		switch subtype {
+		case mrtSubtypeRIBIPv4Unicast:
+			addrLen = net.IPv4len
+		case mrtSubtypeRIBIPv6Unicast:
+			addrLen = net.IPv6len
+		}
+	}
+
+	// The body is copied rather than read into a buffer of the given
+	// length so that a corrupt length cannot cause a huge allocation.
+	var dst io.Writer = io.Discard
+	if addrLen != 0 {
+		r.body.Reset()
+		dst = &r.body
+	}
+	n, err := io.Copy(dst, io.LimitReader(r.r, int64(length)))
+	if err != nil {
+		return fmt.Errorf("reading MRT record at offset %d: %w", offset, err)
+	}
+	if n != int64(length) {
+		return fmt.Errorf("reading MRT record at offset %d: %w", offset, io.ErrUnexpectedEOF)
+	}
+	if addrLen == 0 {
+		return nil
+	}
+
+	routes, err := parseRIB(r.body.Bytes(), addrLen)
+	if err != nil {
+		return fmt.Errorf("parsing MRT RIB record at offset %d: %w", offset, err)
+	}
+	r.pending = routes
+	return nil
+}
+
+func parseRIB(b []byte, addrLen int) ([]Route, error) {
+	// Skip the sequence number.
+	b, err := skip(b, 4)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(b) < 1 {
+		return nil, errors.New("unexpected end of record")
+	}
+	prefixLen := int(b[0])
+	if prefixLen > addrLen*8 {
+		return nil, fmt.Errorf("invalid prefix length: %d", prefixLen)
+	}
+	b = b[1:]
+	prefixBytes := (prefixLen + 7) / 8
+	if len(b) < prefixBytes {
+		return nil, errors.New("unexpected end of record")
+	}
+	ip := make(net.IP, addrLen)
+	copy(ip, b[:prefixBytes])
+	b = b[prefixBytes:]
+	mask := net.CIDRMask(prefixLen, addrLen*8)
+	network := &net.IPNet{IP: ip.Mask(mask), Mask: mask}
+
+	if len(b) < 2 {
+		return nil, errors.New("unexpected end of record")
+	}
+	entryCount := int(binary.BigEndian.Uint16(b))
+	b = b[2:]
+
+	routes := make([]Route, 0, entryCount)
+	for i := 0; i < entryCount; i++ {
+		// Skip the peer index and the originated time.
+		b, err = skip(b, 6)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) < 2 {
+			return nil, errors.New("unexpected end of record")
+		}
+		attrLen := int(binary.BigEndian.Uint16(b))
+		b = b[2:]
+		if len(b) < attrLen {
+			return nil, errors.New("unexpected end of record")
+		}
+
+		origin, ok, err := originFromAttributes(b[:attrLen])
+		if err != nil {
+			return nil, err
+		}
+		b = b[attrLen:]
+		if ok {
+			routes = append(routes, Route{Network: network, OriginAS: origin})
+		}
+	}
+	return routes, nil
+}
+
+func originFromAttributes(b []byte) (uint32, bool, error) {
+	for len(b) > 0 {
+		if len(b) < 3 {
+			return 0, false, errors.New("unexpected end of path attributes")
+		}
+		flags := b[0]
+		attrType := b[1]
+		var length int
+		if flags&bgpAttrFlagExtendedLength != 0 {
+			if len(b) < 4 {
+				return 0, false, errors.New("unexpected end of path attributes")
+			}
+			length = int(binary.BigEndian.Uint16(b[2:4]))
+			b = b[4:]
+		} else {
+			length = int(b[2])
+			b = b[3:]
+		}
+		if len(b) < length {
+			return 0, false, errors.New("unexpected end of path attributes")
+		}
+		if attrType == bgpAttrTypeASPath {
+			return originFromASPath(b[:length])
+		}
+		b = b[length:]
+	}
+	return 0, false, nil
+}
+
+// originFromASPath returns the last AS of the path. AS numbers are always
+// four bytes in TABLE_DUMP_V2 records. The confederation segments of RFC
+// 5065 are skipped as they only contain the member ASes of the
+// confederation the route passed through.
+func originFromASPath(b []byte) (uint32, bool, error) {
+	var origin uint32
+	var ok bool
+	for len(b) > 0 {
+		if len(b) < 2 {
+			return 0, false, errors.New("unexpected end of AS path")
+		}
+		segmentType := b[0]
+		count := int(b[1])
+		b = b[2:]
+		if len(b) < count*4 {
+			return 0, false, errors.New("unexpected end of AS path")
+		}
+		if count > 0 {
+			switch segmentType {
+			case asPathSegmentSequence:
+				origin = binary.BigEndian.Uint32(b[(count-1)*4:])
+				ok = true
+			case asPathSegmentSet:
+				origin = binary.BigEndian.Uint32(b)
+				ok = count == 1
+			case asPathSegmentConfedSequence, asPathSegmentConfedSet:
+				// Skipped. See above.
+			default:
+				return 0, false, fmt.Errorf("invalid AS path segment type: %d", segmentType)
+			}
+		}
+		b = b[count*4:]
+	}
+	return origin, ok, nil
+}
+
+func skip(b []byte, n int) ([]byte, error) {
+	if len(b) < n {
+		return nil, errors.New("unexpected end of record")
+	}
+	return b[n:], nil
+}
@@ -0,0 +1,177 @@
+package bgp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testRIBEntry struct {
+	segments [][]uint32
+	set      bool
+	// segmentTypes, if set, are the types of the segments, overriding set.
+	segmentTypes []byte
+}
+
+func mrtRecord(mrtType, subtype uint16, body []byte) []byte {
+	b := make([]byte, 12, 12+len(body))
+	binary.BigEndian.PutUint16(b[4:], mrtType)
+	binary.BigEndian.PutUint16(b[6:], subtype)
+	binary.BigEndian.PutUint32(b[8:], uint32(len(body)))
+	return append(b, body...)
+}
+
+func ribRecord(prefix []byte, prefixLen int, entries []testRIBEntry) []byte {
+	b := []byte{0, 0, 0, 1, byte(prefixLen)}
+	b = append(b, prefix[:(prefixLen+7)/8]...)
+	b = appendUint16(b, uint16(len(entries)))
+	for _, e := range entries {
+		// An ORIGIN attribute precedes the AS_PATH to check that other
+		// attributes are skipped.
+		attrs := []byte{0x40, 1, 1, 0}
+
+		var path []byte
+		for i, s := range e.segments {
+			segmentType := byte(asPathSegmentSequence)
+			if e.set {
+				segmentType = asPathSegmentSet
+			}
+			if e.segmentTypes != nil {
+				segmentType = e.segmentTypes[i]
+			}
+			path = append(path, segmentType, byte(len(s)))
+			for _, asn := range s {
+				path = appendUint32(path, asn)
+			}
+		}
+		attrs = append(attrs, 0x50, bgpAttrTypeASPath)
+		attrs = appendUint16(attrs, uint16(len(path)))
+		attrs = append(attrs, path...)
+
+		b = append(b, 0, 0, 0, 0, 0, 0)
+		b = appendUint16(b, uint16(len(attrs)))
+		b = append(b, attrs...)
+	}
+	return b
+}
+
+func TestMRTReader(t *testing.T) {
+	var dump []byte
+	// The peer index table is skipped.
+	dump = append(dump, mrtRecord(mrtTypeTableDumpV2, 1, []byte{1, 2, 3, 4})...)
+	dump = append(dump, mrtRecord(
+		mrtTypeTableDumpV2,
+		mrtSubtypeRIBIPv4Unicast,
+		ribRecord([]byte{1, 0, 0, 0}, 24, []testRIBEntry{
+			{segments: [][]uint32{{3356, 13335}}},
+			{segments: [][]uint32{{174}, {6939, 13335}}},
+		}),
+	)...)
+	// Other MRT types are skipped.
+	dump = append(dump, mrtRecord(16, 4, []byte{1, 2, 3})...)
+	dump = append(dump, mrtRecord(
+		mrtTypeTableDumpV2,
+		mrtSubtypeRIBIPv4Unicast,
+		ribRecord([]byte{1, 0, 8, 0}, 22, []testRIBEntry{
+			{segments: [][]uint32{{64512, 64513}}, set: true},
+		}),
+	)...)
+	// Confederation segments are skipped.
+	dump = append(dump, mrtRecord(
+		mrtTypeTableDumpV2,
+		mrtSubtypeRIBIPv4Unicast,
+		ribRecord([]byte{1, 0, 16, 0}, 24, []testRIBEntry{
+			{
+				segments:     [][]uint32{{65001, 65002}, {3356, 15169}},
+				segmentTypes: []byte{asPathSegmentConfedSequence, asPathSegmentSequence},
+			},
+			{
+				segments:     [][]uint32{{3356, 15169}, {65001}},
+				segmentTypes: []byte{asPathSegmentSequence, asPathSegmentConfedSet},
+			},
+			{
+				segments:     [][]uint32{{65001}},
+				segmentTypes: []byte{asPathSegmentConfedSequence},
+			},
+		}),
+	)...)
+	dump = append(dump, mrtRecord(
+		mrtTypeTableDumpV2,
+		mrtSubtypeRIBIPv6Unicast,
+		ribRecord([]byte{0x20, 0x01, 0x0d, 0xb8}, 32, []testRIBEntry{
+			{segments: [][]uint32{{6939, 4200000000}}},
+		}),
+	)...)
+
+	r := NewMRTReader(bytes.NewReader(dump))
+	var routes []string
+	for {
+		route, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		routes = append(routes, fmt.Sprintf("%s %d", route.Network, route.OriginAS))
+	}
+	assert.Equal(
+		t,
+		[]string{
+			"1.0.0.0/24 13335",
+			"1.0.0.0/24 13335",
+			"1.0.16.0/24 15169",
+			"1.0.16.0/24 15169",
+			"2001:db8::/32 4200000000",
+		},
+		routes,
+	)
+}
+
+func TestMRTReaderTruncated(t *testing.T) {
+	record := mrtRecord(
+		mrtTypeTableDumpV2,
+		mrtSubtypeRIBIPv4Unicast,
+		ribRecord([]byte{1, 0, 0, 0}, 24, []testRIBEntry{
+			{segments: [][]uint32{{3356, 13335}}},
+		}),
+	)
+
+	_, err := NewMRTReader(bytes.NewReader(record[:len(record)-2])).Read()
+	assert.EqualError(t, err, "reading MRT record at offset 0: unexpected EOF")
+
+	// The length in the header covers the truncated body.
+	truncated := mrtRecord(
+		mrtTypeTableDumpV2,
+		mrtSubtypeRIBIPv4Unicast,
+		record[12:len(record)-2],
+	)
+	_, err = NewMRTReader(bytes.NewReader(truncated)).Read()
+	assert.EqualError(
+		t,
+		err,
+		"parsing MRT RIB record at offset 0: unexpected end of record",
+	)
+}
+
+func TestMRTReaderCorruptLength(t *testing.T) {
+	// A corrupt length must not cause the whole length to be allocated.
+	record := mrtRecord(mrtTypeTableDumpV2, mrtSubtypeRIBIPv4Unicast, []byte{1, 2, 3})
+	binary.BigEndian.PutUint32(record[8:], math.MaxUint32)
+
+	_, err := NewMRTReader(bytes.NewReader(record)).Read()
+	assert.EqualError(t, err, "reading MRT record at offset 0: unexpected EOF")
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
@@ -0,0 +1,75 @@
+package bgp
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// TextReader reads routes from text with one route per line. Each line
+// consists of the prefix followed by the AS path, separated by whitespace,
+// e.g., "1.0.0.0/24 3356 13335". An AS set at the end of the path may be
+// written in braces, e.g., "{64512,64513}". Empty lines and lines starting
+// with "#" are skipped.
+type TextReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+// NewTextReader returns a new TextReader that reads from r.
+func NewTextReader(r io.Reader) *TextReader {
+	return &TextReader{scanner: bufio.NewScanner(r)}
+}
+
+// Read returns the next route. Routes whose origin is an AS set with more
+// than one AS are skipped as their origin is ambiguous. It returns io.EOF
+// when there are no more routes.
+func (r *TextReader) Read() (Route, error) {
+	for r.scanner.Scan() {
+		r.line++
+		line := strings.TrimSpace(r.scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		route, ok, err := parseTextRoute(line)
+		if err != nil {
+			return Route{}, fmt.Errorf("parsing line %d: %w", r.line, err)
+		}
+		if ok {
+			return route, nil
+		}
+	}
+	if err := r.scanner.Err(); err != nil {
+		return Route{}, fmt.Errorf("reading routes: %w", err)
+	}
+	return Route{}, io.EOF
+}
+
+func parseTextRoute(line string) (Route, bool, error) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return Route{}, false, fmt.Errorf("expected a prefix and an AS path: %q", line)
+	}
+
+	_, network, err := net.ParseCIDR(fields[0])
+	if err != nil {
+		return Route{}, false, fmt.Errorf("parsing prefix: %w", err)
+	}
+
+	origin := fields[len(fields)-1]
+	if strings.HasPrefix(origin, "{") {
+		origin = strings.Trim(origin, "{}")
+		if strings.Contains(origin, ",") {
+			return Route{}, false, nil
+		}
+	}
+	asn, err := strconv.ParseUint(origin, 10, 32)
+	if err != nil {
+		return Route{}, false, fmt.Errorf("invalid origin AS: %q", origin)
+	}
+	return Route{Network: network, OriginAS: uint32(asn)}, true, nil
+}
@@ -0,0 +1,173 @@
+// Package firewall reads the networks in firewall set and rule exports, so
+// that existing blocklists can be turned into set-membership databases
+// with the builders/ipset package.
+//
+// The supported formats are the output of "ipset save", the named sets in
+// the output of "nft list ruleset" or "nft list set", and the output of
+// "iptables-save" or "ip6tables-save".
+package firewall
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/maxmind/mmdbwriter/builders/ipset"
+)
+
+// Entry is a range of IP addresses from a firewall set or rule.
+type Entry struct {
+	// Set is the name of the set the entry is from or, for iptables, the
+	// name of the chain.
+	Set string
+
+	// Start is the first IP address in the entry.
+	Start net.IP
+
+	// End is the last IP address in the entry.
+	End net.IP
+}
+
+// EntryReader is implemented by IPSetReader, NftablesReader, and
+// IptablesReader.
+type EntryReader interface {
+	// Read returns the next entry. It returns io.EOF when there are no more
+	// entries.
+	Read() (Entry, error)
+}
+
+// Insert reads the entries from r and adds them to the builder, labeled
+// with the name of their set. It returns the number of entries added.
+func Insert(b *ipset.Builder, r EntryReader) (int, error) {
+	var n int
+	for {
+		entry, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		if err := b.AddRangeWithLabel(entry.Start, entry.End, entry.Set); err != nil {
+			return n, fmt.Errorf(
+				"adding %s-%s from %s: %w",
+				entry.Start,
+				entry.End,
+				entry.Set,
+				err,
+			)
+		}
+		n++
+	}
+}
+
+// lineReader reads the lines of an export, keeping track of the line
+// number for errors.
+type lineReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func newLineReader(r io.Reader) lineReader {
+	return lineReader{scanner: bufio.NewScanner(r)}
+}
+
+// next returns the next line that isn't blank or a comment, with leading
+// and trailing whitespace removed. It returns io.EOF when there are no more
+// lines.
+func (r *lineReader) next() (string, error) {
+	for r.scanner.Scan() {
+		r.line++
+		line := strings.TrimSpace(r.scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		return line, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", io.EOF
+}
+
+// errorf returns an error for the current line.
+func (r *lineReader) errorf(format string, args ...any) error {
+	return fmt.Errorf("parsing line %d: %s", r.line, fmt.Sprintf(format, args...))
+}
+
+// parseElement parses an IP address, a network in CIDR notation, or a
+// range of IP addresses in the form start-end.
+func parseElement(s string) (net.IP, net.IP, error) {
+	if start, end, ok := strings.Cut(s, "-"); ok {
+		startIP := parseIP(start)
+		endIP := parseIP(end)
+		if startIP == nil || endIP == nil || len(startIP) != len(endIP) {
+			return nil, nil, fmt.Errorf("invalid range: %q", s)
+		}
+		return startIP, endIP, nil
+	}
+
+	if strings.Contains(s, "/") {
+		_, network, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid network: %q", s)
+		}
+		end := make(net.IP, len(network.IP))
+		for i := range end {
+			end[i] = network.IP[i] | ^network.Mask[i]
+		}
+		return network.IP, end, nil
+	}
+
+	ip := parseIP(s)
+	if ip == nil {
+		return nil, nil, fmt.Errorf("invalid IP address: %q", s)
+	}
+	return ip, ip, nil
+}
+
+// parseIP parses the IP address, returning IPv4 addresses in their 4-byte
+// form.
+func parseIP(s string) net.IP {
+	ip := net.ParseIP(s)
+	if ipv4 := ip.To4(); ipv4 != nil && !strings.Contains(s, ":") {
+		return ipv4
+	}
+	return ip
+}
+
+// splitFields splits s around whitespace and, if sep is not zero, the
+// separator. Whitespace and separators within double quotes do not split
+// the fields, and the quotes are kept.
+func splitFields(s string, sep rune) []string {
+	var fields []string
+	var field strings.Builder
+	inQuotes := false
+	flush := func() {
+		if field.Len() > 0 {
+			fields = append(fields, field.String())
+			field.Reset()
+		}
+	}
+	for _, c := range s {
+		switch {
+		case c == '"':
+			inQuotes = !inQuotes
+			field.WriteRune(c)
+		case inQuotes:
+			field.WriteRune(c)
+		case c == ' ' || c == '\t':
+			flush()
+		case sep != 0 && c == sep:
+			flush()
+			fields = append(fields, string(sep))
+		default:
+			field.WriteRune(c)
+		}
+	}
+	flush()
+	return fields
+}
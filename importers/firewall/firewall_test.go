@@ -0,0 +1,183 @@
+package firewall
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/builders/ipset"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readAll(t *testing.T, r EntryReader) []string {
+	var entries []string
+	for {
+		entry, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return entries
+		}
+		require.NoError(t, err)
+		entries = append(entries, fmt.Sprintf("%s %s-%s", entry.Set, entry.Start, entry.End))
+	}
+}
+
+func TestIPSetReader(t *testing.T) {
+	r := NewIPSetReader(strings.NewReader(`create blocklist hash:net family inet hashsize 1024 maxelem 65536
+add blocklist 1.2.3.0/24
+add blocklist 5.6.7.8 timeout 300 comment "spam, mostly"
+create ports hash:ip,port family inet
+add ports 9.9.9.9,tcp:80
+create macs hash:mac
+add macs 00:11:22:33:44:55
+
+create blocklist6 hash:net family inet6
+add blocklist6 2600::/32
+create range bitmap:ip range 10.0.0.0-10.0.0.255
+add range 10.0.0.1-10.0.0.5
+`))
+
+	assert.Equal(t, []string{
+		"blocklist 1.2.3.0-1.2.3.255",
+		"blocklist 5.6.7.8-5.6.7.8",
+		"ports 9.9.9.9-9.9.9.9",
+		"blocklist6 2600::-2600:0:ffff:ffff:ffff:ffff:ffff:ffff",
+		"range 10.0.0.1-10.0.0.5",
+	}, readAll(t, r))
+}
+
+func TestNftablesReader(t *testing.T) {
+	r := NewNftablesReader(strings.NewReader(`table inet filter {
+	set blocklist {
+		type ipv4_addr
+		flags interval
+		elements = { 1.2.3.0/24, 5.6.7.8 timeout 1h expires 59m comment "a, b",
+			     10.0.0.1-10.0.0.5 }
+	}
+
+	set blocklist6 {
+		type ipv6_addr
+		elements = { 2600::1 }
+	}
+
+	set services {
+		type inet_service
+		elements = { 22, 80 }
+	}
+
+	set pairs {
+		type ipv4_addr . inet_service
+		elements = { 9.9.9.9 . 53 }
+	}
+
+	map verdicts {
+		type ipv4_addr : verdict
+		elements = { 8.8.8.8 : drop }
+	}
+
+	set empty {
+		type ipv4_addr
+	}
+
+	chain input {
+		type filter hook input priority filter; policy accept;
+		ip saddr @blocklist drop
+	}
+}
+`))
+
+	assert.Equal(t, []string{
+		"blocklist 1.2.3.0-1.2.3.255",
+		"blocklist 5.6.7.8-5.6.7.8",
+		"blocklist 10.0.0.1-10.0.0.5",
+		"blocklist6 2600::1-2600::1",
+		"pairs 9.9.9.9-9.9.9.9",
+		"verdicts 8.8.8.8-8.8.8.8",
+	}, readAll(t, r))
+}
+
+func TestIptablesReader(t *testing.T) {
+	input := `# Generated by iptables-save
+*filter
+:INPUT ACCEPT [0:0]
+-A INPUT -s 1.2.3.0/24 -j DROP
+-A INPUT -s 5.6.7.8/32,9.9.9.9/32 -m comment --comment "spam list" -j REJECT --reject-with icmp-port-unreachable
+-A INPUT ! -s 10.0.0.0/8 -j DROP
+-A INPUT -s 11.0.0.0/8 -j ACCEPT
+-A INPUT -m iprange --src-range 12.0.0.1-12.0.0.5 -j DROP
+-A INPUT -p tcp --dport 22 -j DROP
+COMMIT
+`
+
+	assert.Equal(t, []string{
+		"INPUT 1.2.3.0-1.2.3.255",
+		"INPUT 5.6.7.8-5.6.7.8",
+		"INPUT 9.9.9.9-9.9.9.9",
+		"INPUT 12.0.0.1-12.0.0.5",
+	}, readAll(t, NewIptablesReader(strings.NewReader(input))))
+
+	assert.Equal(t, []string{
+		"INPUT 11.0.0.0-11.255.255.255",
+	}, readAll(t, NewIptablesReader(strings.NewReader(input), "ACCEPT")))
+}
+
+func TestReaderErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		reader      EntryReader
+		expectedErr string
+	}{
+		{
+			name:        "ipset",
+			reader:      NewIPSetReader(strings.NewReader("create s hash:ip\nadd s 1.2.3\n")),
+			expectedErr: `parsing line 2: invalid IP address: "1.2.3"`,
+		},
+		{
+			name:        "nftables unterminated",
+			reader:      NewNftablesReader(strings.NewReader("set s {\ntype ipv4_addr\nelements = { 1.2.3.4,\n")),
+			expectedErr: "parsing line 3: unterminated elements of set s",
+		},
+		{
+			name:        "iptables",
+			reader:      NewIptablesReader(strings.NewReader("-A INPUT -s 1.2.3.4-1.2.3 -j DROP\n")),
+			expectedErr: `parsing line 1: invalid range: "1.2.3.4-1.2.3"`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := test.reader.Read()
+			require.EqualError(t, err, test.expectedErr)
+		})
+	}
+}
+
+func TestInsert(t *testing.T) {
+	b, err := ipset.New(mmdbwriter.Options{})
+	require.NoError(t, err)
+
+	n, err := Insert(b, NewIPSetReader(strings.NewReader(`create blocklist hash:net
+add blocklist 1.2.3.0/24
+add blocklist 11.0.0.1-11.0.0.5
+`)))
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	network, value := b.Tree().Get(net.ParseIP("11.0.0.4"))
+	assert.Equal(t, "11.0.0.4/31", network.String())
+	assert.Equal(t, ipset.Record("blocklist"), value)
+
+	_, value = b.Tree().Get(net.ParseIP("1.2.3.4"))
+	assert.Equal(t, mmdbtype.Map{
+		"present": mmdbtype.Bool(true),
+		"label":   mmdbtype.String("blocklist"),
+	}, value)
+
+	_, err = b.WriteTo(&bytes.Buffer{})
+	require.NoError(t, err)
+}
@@ -0,0 +1,66 @@
+package firewall
+
+import (
+	"io"
+	"strings"
+)
+
+// IPSetReader reads the entries from the output of "ipset save". The
+// entries of sets that do not contain IP addresses, e.g., hash:mac sets,
+// are skipped. For sets with multiple dimensions, e.g., hash:net,port, only
+// the first dimension is read.
+type IPSetReader struct {
+	lines lineReader
+
+	// skipped contains the sets whose entries are skipped.
+	skipped map[string]bool
+}
+
+// NewIPSetReader returns a new IPSetReader that reads from r.
+func NewIPSetReader(r io.Reader) *IPSetReader {
+	return &IPSetReader{
+		lines:   newLineReader(r),
+		skipped: map[string]bool{},
+	}
+}
+
+// Read returns the next entry. It returns io.EOF when there are no more
+// entries.
+func (r *IPSetReader) Read() (Entry, error) {
+	for {
+		line, err := r.lines.next()
+		if err != nil {
+			return Entry{}, err
+		}
+		fields := strings.Fields(line)
+		switch fields[0] {
+		case "create", "-N":
+			if len(fields) < 3 {
+				return Entry{}, r.lines.errorf("expected a set name and type")
+			}
+			r.skipped[fields[1]] = !isIPSetType(fields[2])
+		case "add", "-A":
+			if len(fields) < 3 {
+				return Entry{}, r.lines.errorf("expected a set name and entry")
+			}
+			if r.skipped[fields[1]] {
+				continue
+			}
+			element, _, _ := strings.Cut(fields[2], ",")
+			start, end, err := parseElement(element)
+			if err != nil {
+				return Entry{}, r.lines.errorf("%s", err)
+			}
+			return Entry{Set: fields[1], Start: start, End: end}, nil
+		default:
+		}
+	}
+}
+
+// isIPSetType returns true if the first dimension of the set type is an IP
+// address or network.
+func isIPSetType(setType string) bool {
+	return strings.HasPrefix(setType, "hash:ip") ||
+		strings.HasPrefix(setType, "hash:net") ||
+		strings.HasPrefix(setType, "bitmap:ip")
+}
@@ -0,0 +1,95 @@
+package firewall
+
+import (
+	"io"
+	"strings"
+)
+
+// DefaultIptablesTargets are the targets of the rules read by an
+// IptablesReader if no targets are given.
+var DefaultIptablesTargets = []string{"DROP", "REJECT"}
+
+// IptablesReader reads the source addresses of the rules in the output of
+// "iptables-save" or "ip6tables-save". Only rules that jump to one of the
+// targets and have a source address, given with -s, --source, or
+// --src-range, are read. Negated source addresses are skipped.
+type IptablesReader struct {
+	lines   lineReader
+	targets map[string]bool
+	pending []Entry
+}
+
+// NewIptablesReader returns a new IptablesReader that reads the rules with
+// the targets from r. If no targets are given, DefaultIptablesTargets are
+// used.
+func NewIptablesReader(r io.Reader, targets ...string) *IptablesReader {
+	if len(targets) == 0 {
+		targets = DefaultIptablesTargets
+	}
+	reader := &IptablesReader{
+		lines:   newLineReader(r),
+		targets: map[string]bool{},
+	}
+	for _, target := range targets {
+		reader.targets[target] = true
+	}
+	return reader
+}
+
+// Read returns the next entry. It returns io.EOF when there are no more
+// entries.
+func (r *IptablesReader) Read() (Entry, error) {
+	for len(r.pending) == 0 {
+		line, err := r.lines.next()
+		if err != nil {
+			return Entry{}, err
+		}
+		if err := r.readRule(line); err != nil {
+			return Entry{}, err
+		}
+	}
+	entry := r.pending[0]
+	r.pending = r.pending[1:]
+	return entry, nil
+}
+
+// readRule reads the source addresses of the rule on line if it has one
+// of the targets.
+func (r *IptablesReader) readRule(line string) error {
+	args := splitFields(line, 0)
+	if len(args) < 2 || (args[0] != "-A" && args[0] != "--append") {
+		return nil
+	}
+	chain := args[1]
+
+	var sources []string
+	var target string
+	for i := 2; i < len(args)-1; i++ {
+		if args[i] == "!" {
+			// Skip the negated option and its value.
+			i += 2
+			continue
+		}
+		switch args[i] {
+		case "-s", "--source", "--src-range":
+			i++
+			sources = append(sources, strings.Split(args[i], ",")...)
+		case "-j", "--jump":
+			i++
+			target = args[i]
+		default:
+		}
+	}
+	if !r.targets[target] {
+		return nil
+	}
+
+	for _, source := range sources {
+		start, end, err := parseElement(source)
+		if err != nil {
+			return r.lines.errorf("%s", err)
+		}
+		r.pending = append(r.pending, Entry{Set: chain, Start: start, End: end})
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package firewall
+
+import (
+	"io"
+	"strings"
+)
+
+// NftablesReader reads the entries of the named sets and maps in the output
+// of "nft list ruleset" or "nft list set". The entries of sets whose type
+// is not ipv4_addr or ipv6_addr are skipped. For sets with concatenated
+// types, e.g., ipv4_addr . inet_service, only the first part is read.
+// Anonymous sets within rules are not read.
+type NftablesReader struct {
+	lines lineReader
+
+	set     string
+	skipSet bool
+	pending []Entry
+}
+
+// NewNftablesReader returns a new NftablesReader that reads from r.
+func NewNftablesReader(r io.Reader) *NftablesReader {
+	return &NftablesReader{lines: newLineReader(r)}
+}
+
+// Read returns the next entry. It returns io.EOF when there are no more
+// entries.
+func (r *NftablesReader) Read() (Entry, error) {
+	for len(r.pending) == 0 {
+		line, err := r.lines.next()
+		if err != nil {
+			return Entry{}, err
+		}
+		fields := strings.Fields(line)
+		switch {
+		case (fields[0] == "set" || fields[0] == "map") && len(fields) >= 3 && fields[2] == "{":
+			r.set = fields[1]
+			r.skipSet = false
+		case fields[0] == "type" && r.set != "":
+			r.skipSet = len(fields) < 2 || (fields[1] != "ipv4_addr" && fields[1] != "ipv6_addr")
+		case fields[0] == "elements" && r.set != "":
+			if err := r.readElements(line); err != nil {
+				return Entry{}, err
+			}
+		default:
+		}
+	}
+	entry := r.pending[0]
+	r.pending = r.pending[1:]
+	return entry, nil
+}
+
+// readElements reads the elements of the current set, which start on line
+// and may continue on the following lines until the closing brace.
+func (r *NftablesReader) readElements(line string) error {
+	_, elements, ok := strings.Cut(line, "{")
+	if !ok {
+		return r.lines.errorf("expected { after elements")
+	}
+	for !strings.Contains(elements, "}") {
+		next, err := r.lines.next()
+		if err == io.EOF {
+			return r.lines.errorf("unterminated elements of set %s", r.set)
+		}
+		if err != nil {
+			return err
+		}
+		elements += " " + next
+	}
+	elements, _, _ = strings.Cut(elements, "}")
+	if r.skipSet {
+		return nil
+	}
+
+	var element []string
+	for _, field := range append(splitFields(elements, ','), ",") {
+		if field != "," {
+			element = append(element, field)
+			continue
+		}
+		if len(element) == 0 {
+			continue
+		}
+		// The first field is the key, which may be followed by the rest of
+		// a concatenation, the value of a map, or options such as the
+		// timeout or comment.
+		start, end, err := parseElement(element[0])
+		if err != nil {
+			return r.lines.errorf("set %s: %s", r.set, err)
+		}
+		r.pending = append(r.pending, Entry{Set: r.set, Start: start, End: end})
+		element = element[:0]
+	}
+	return nil
+}
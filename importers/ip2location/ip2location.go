@@ -0,0 +1,304 @@
+// Package ip2location reads the CSV files of the IP2Location DB and
+// IP2Proxy PX databases, allowing users of these databases to build MaxMind
+// DB files from them.
+//
+// Each row of these files is a range of IP addresses, given as quoted
+// decimal integers, followed by the fields for the range. The fields
+// depend on the database, e.g., DB5 or PX2, and are mapped to the record
+// with a list of Columns. Layouts for the common databases are provided,
+// e.g., DB5Columns and PX2Columns.
+package ip2location
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/decimalip"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+// ColumnType is the type of the value of a column in the record.
+type ColumnType int
+
+const (
+	// String stores the value as a String. This is the default.
+	String ColumnType = iota
+
+	// Float64 stores the value as a Float64, e.g., for coordinates.
+	Float64
+
+	// Uint32 stores the value as a Uint32, e.g., for ASNs.
+	Uint32
+)
+
+// Column maps a field of the rows to the record.
+type Column struct {
+	// Path is the dot-separated path of the value in the record, e.g.,
+	// "country.iso_code". An empty path means that the field is skipped.
+	Path string
+
+	// Type is the type of the value.
+	Type ColumnType
+}
+
+// The layouts of the IP2Location DB databases. The fields are mapped to
+// paths similar to those of the GeoIP2 databases where possible.
+var (
+	DB1Columns = []Column{
+		{Path: "country.iso_code"},
+		{Path: "country.names.en"},
+	}
+	DB3Columns = append(
+		DB1Columns[:len(DB1Columns):len(DB1Columns)],
+		Column{Path: "region.names.en"},
+		Column{Path: "city.names.en"},
+	)
+	DB5Columns = append(
+		DB3Columns[:len(DB3Columns):len(DB3Columns)],
+		Column{Path: "location.latitude", Type: Float64},
+		Column{Path: "location.longitude", Type: Float64},
+	)
+	DB9Columns = append(
+		DB5Columns[:len(DB5Columns):len(DB5Columns)],
+		Column{Path: "postal.code"},
+	)
+	DB11Columns = append(
+		DB9Columns[:len(DB9Columns):len(DB9Columns)],
+		Column{Path: "location.time_zone"},
+	)
+)
+
+// The layouts of the IP2Proxy PX databases.
+var (
+	PX1Columns = []Column{
+		{Path: "country.iso_code"},
+		{Path: "country.names.en"},
+	}
+	PX2Columns = []Column{
+		{Path: "proxy.type"},
+		{Path: "country.iso_code"},
+		{Path: "country.names.en"},
+	}
+	PX4Columns = append(
+		PX2Columns[:len(PX2Columns):len(PX2Columns)],
+		Column{Path: "region.names.en"},
+		Column{Path: "city.names.en"},
+		Column{Path: "isp"},
+	)
+	PX11Columns = append(
+		PX4Columns[:len(PX4Columns):len(PX4Columns)],
+		Column{Path: "domain"},
+		Column{Path: "usage_type"},
+		Column{Path: "autonomous_system_number", Type: Uint32},
+		Column{Path: "autonomous_system_organization"},
+		Column{Path: "proxy.last_seen", Type: Uint32},
+		Column{Path: "proxy.threat"},
+		Column{Path: "proxy.provider"},
+	)
+	PX12Columns = append(
+		PX11Columns[:len(PX11Columns):len(PX11Columns)],
+		Column{Path: "proxy.fraud_score", Type: Uint32},
+	)
+)
+
+// Record is a row of a CSV file.
+type Record struct {
+	// Start is the first IP address in the range. IPv4 addresses, including
+	// IPv4-mapped IPv6 addresses, are returned in their 4-byte form.
+	Start net.IP
+
+	// End is the last IP address in the range.
+	End net.IP
+
+	// Fields are the fields following the range.
+	Fields []string
+}
+
+// DataType returns the record with the fields mapped using the columns.
+// Fields that are empty or "-", which IP2Location uses for unknown values,
+// are omitted.
+func (r Record) DataType(columns []Column) (mmdbtype.Map, error) {
+	if len(r.Fields) != len(columns) {
+		return nil, fmt.Errorf(
+			"the row has %d fields, but there are %d columns",
+			len(r.Fields),
+			len(columns),
+		)
+	}
+	m := mmdbtype.Map{}
+	for i, column := range columns {
+		field := r.Fields[i]
+		if column.Path == "" || field == "" || field == "-" {
+			continue
+		}
+		value, err := column.value(field)
+		if err != nil {
+			return nil, err
+		}
+		setPath(m, strings.Split(column.Path, "."), value)
+	}
+	return m, nil
+}
+
+func (c Column) value(field string) (mmdbtype.DataType, error) {
+	switch c.Type {
+	case String:
+		return mmdbtype.String(field), nil
+	case Float64:
+		f, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %q", c.Path, field)
+		}
+		return mmdbtype.Float64(f), nil
+	case Uint32:
+		n, err := strconv.ParseUint(field, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %q", c.Path, field)
+		}
+		return mmdbtype.Uint32(n), nil
+	default:
+		return nil, fmt.Errorf("unsupported type for %s: %d", c.Path, c.Type)
+	}
+}
+
+// setPath sets the value at the path in m, creating the maps along the
+// path as needed.
+func setPath(m mmdbtype.Map, path []string, value mmdbtype.DataType) {
+	for _, key := range path[:len(path)-1] {
+		next, ok := m[mmdbtype.String(key)].(mmdbtype.Map)
+		if !ok {
+			next = mmdbtype.Map{}
+			m[mmdbtype.String(key)] = next
+		}
+		m = next
+	}
+	m[mmdbtype.String(path[len(path)-1])] = value
+}
+
+// Reader reads the rows of an IP2Location DB or IP2Proxy PX CSV file. A
+// header row, if present, is skipped.
+type Reader struct {
+	csv     *csv.Reader
+	line    int
+	started bool
+}
+
+// NewReader returns a new Reader that reads from r.
+func NewReader(r io.Reader) *Reader {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+	return &Reader{csv: reader}
+}
+
+// Read returns the next record. It returns io.EOF when there are no more
+// records.
+func (r *Reader) Read() (Record, error) {
+	for {
+		fields, err := r.csv.Read()
+		if errors.Is(err, io.EOF) {
+			return Record{}, io.EOF
+		}
+		r.line++
+		if err != nil {
+			return Record{}, fmt.Errorf("reading CSV: %w", err)
+		}
+		if len(fields) < 2 {
+			return Record{}, fmt.Errorf(
+				"parsing line %d: expected at least 2 fields but found %d",
+				r.line,
+				len(fields),
+			)
+		}
+
+		if !r.started {
+			r.started = true
+			if _, err := decimalip.Parse(fields[0]); err != nil {
+				// The header row.
+				continue
+			}
+		}
+
+		record, err := parseRecord(fields)
+		if err != nil {
+			return Record{}, fmt.Errorf("parsing line %d: %w", r.line, err)
+		}
+		return record, nil
+	}
+}
+
+func parseRecord(fields []string) (Record, error) {
+	start, end, err := decimalip.ParseRange(fields[0], fields[1])
+	if err != nil {
+		return Record{}, err
+	}
+	// IPv4-mapped ranges are returned as IPv4 ranges.
+	if len(start) == net.IPv6len && start.To4() != nil && end.To4() != nil {
+		start = start.To4()
+		end = end.To4()
+	}
+	return Record{
+		Start:  start,
+		End:    end,
+		Fields: append([]string(nil), fields[2:]...),
+	}, nil
+}
+
+// ipv4DerivedNetworks are the networks whose rows IP2Location derives from
+// the IPv4 rows. In IPv6 trees with IPv4 aliasing, these networks are
+// aliases of the IPv4 networks.
+var ipv4DerivedNetworks = []*net.IPNet{
+	{IP: net.ParseIP("2001::"), Mask: net.CIDRMask(32, 128)},
+	{IP: net.ParseIP("2002::"), Mask: net.CIDRMask(16, 128)},
+}
+
+// Insert reads the CSV file from r and inserts the ranges into the tree
+// using Record.DataType with the columns and the tree's inserter. Rows
+// with no known fields are skipped, as are the rows for the Teredo
+// (2001::/32) and 6to4 (2002::/16) networks, which IP2Location derives from
+// the IPv4 rows and which are aliases of the IPv4 networks in IPv6 trees
+// built with the default options. Use a Reader to insert these rows into a
+// tree with IPv4 aliasing disabled. It returns the number of rows
+// inserted.
+func Insert(tree *mmdbwriter.Tree, r io.Reader, columns []Column) (int, error) {
+	reader := NewReader(r)
+	var n int
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		if isIPv4Derived(record) {
+			continue
+		}
+		value, err := record.DataType(columns)
+		if err != nil {
+			return n, fmt.Errorf("parsing line %d: %w", reader.line, err)
+		}
+		if len(value) == 0 {
+			continue
+		}
+		if err := tree.InsertRange(record.Start, record.End, value); err != nil {
+			return n, fmt.Errorf("inserting %s-%s: %w", record.Start, record.End, err)
+		}
+		n++
+	}
+}
+
+func isIPv4Derived(record Record) bool {
+	for _, network := range ipv4DerivedNetworks {
+		if network.Contains(record.Start) && network.Contains(record.End) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,157 @@
+package ip2location
+
+import (
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReader(t *testing.T) {
+	r := NewReader(strings.NewReader(`"ip_from","ip_to","country_code","country_name"
+"0","16777215","-","-"
+"16777216","16777471","AU","Australia"
+"281470698586112","281470698586367","US","United States of America"
+"58569107296622255421594597096899477504","58569107296622255421594597096899477504","DE","Germany"
+`))
+
+	var records []Record
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		records = append(records, record)
+	}
+
+	require.Len(t, records, 4)
+	assert.Equal(t, "0.0.0.0", records[0].Start.String())
+	assert.Equal(t, "0.255.255.255", records[0].End.String())
+	assert.Equal(t, "1.0.0.0", records[1].Start.String())
+	assert.Equal(t, "1.0.0.255", records[1].End.String())
+	assert.Equal(t, []string{"AU", "Australia"}, records[1].Fields)
+
+	// IPv4-mapped addresses are returned as IPv4 addresses.
+	assert.Equal(t, net.IP{1, 1, 0, 0}, records[2].Start)
+	assert.Equal(t, net.IP{1, 1, 0, 255}, records[2].End)
+
+	assert.Equal(t, "2c0f:fff0::", records[3].Start.String())
+}
+
+func TestRecordDataType(t *testing.T) {
+	record := Record{Fields: []string{
+		"US", "United States of America", "California", "Los Angeles",
+		"34.052230", "-118.243680", "90001", "-07:00",
+	}}
+	m, err := record.DataType(DB11Columns)
+	require.NoError(t, err)
+	assert.Equal(t, mmdbtype.Map{
+		"country": mmdbtype.Map{
+			"iso_code": mmdbtype.String("US"),
+			"names":    mmdbtype.Map{"en": mmdbtype.String("United States of America")},
+		},
+		"region": mmdbtype.Map{"names": mmdbtype.Map{"en": mmdbtype.String("California")}},
+		"city":   mmdbtype.Map{"names": mmdbtype.Map{"en": mmdbtype.String("Los Angeles")}},
+		"location": mmdbtype.Map{
+			"latitude":  mmdbtype.Float64(34.05223),
+			"longitude": mmdbtype.Float64(-118.24368),
+			"time_zone": mmdbtype.String("-07:00"),
+		},
+		"postal": mmdbtype.Map{"code": mmdbtype.String("90001")},
+	}, m)
+
+	record = Record{Fields: []string{
+		"VPN", "US", "United States of America", "-", "-", "Example ISP",
+		"example.com", "DCH", "64496", "Example AS", "27", "-", "ExampleVPN", "12",
+	}}
+	m, err = record.DataType(PX12Columns)
+	require.NoError(t, err)
+	assert.Equal(t, mmdbtype.Map{
+		"proxy": mmdbtype.Map{
+			"type":        mmdbtype.String("VPN"),
+			"last_seen":   mmdbtype.Uint32(27),
+			"provider":    mmdbtype.String("ExampleVPN"),
+			"fraud_score": mmdbtype.Uint32(12),
+		},
+		"country": mmdbtype.Map{
+			"iso_code": mmdbtype.String("US"),
+			"names":    mmdbtype.Map{"en": mmdbtype.String("United States of America")},
+		},
+		"isp":                            mmdbtype.String("Example ISP"),
+		"domain":                         mmdbtype.String("example.com"),
+		"usage_type":                     mmdbtype.String("DCH"),
+		"autonomous_system_number":       mmdbtype.Uint32(64496),
+		"autonomous_system_organization": mmdbtype.String("Example AS"),
+	}, m)
+
+	_, err = record.DataType(PX2Columns)
+	require.EqualError(t, err, "the row has 14 fields, but there are 3 columns")
+
+	_, err = Record{Fields: []string{"x", "y"}}.DataType(
+		[]Column{{Path: "a", Type: Float64}, {}},
+	)
+	require.EqualError(t, err, `invalid value for a: "x"`)
+}
+
+func TestReaderErrors(t *testing.T) {
+	tests := []struct {
+		input       string
+		expectedErr string
+	}{
+		{
+			input:       `"1","x","AU"`,
+			expectedErr: `parsing line 1: invalid decimal IP address: "x"`,
+		},
+		{
+			input:       `"2","1","AU"`,
+			expectedErr: "parsing line 1: the start of the range (2) is greater than the end (1)",
+		},
+		{
+			input:       `"1"`,
+			expectedErr: "parsing line 1: expected at least 2 fields but found 1",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			_, err := NewReader(strings.NewReader(test.input)).Read()
+			require.EqualError(t, err, test.expectedErr)
+		})
+	}
+}
+
+func TestInsert(t *testing.T) {
+	tree, err := mmdbwriter.New(mmdbwriter.Options{})
+	require.NoError(t, err)
+
+	n, err := Insert(tree, strings.NewReader(`"0","16777215","-","-"
+"16777216","16777471","AU","Australia"
+"281470698586112","281470698586367","US","United States of America"
+"42545700741243981239849310868880752640","42545700741552257323851041308430827520","AU","Australia"
+"58569107296622255421594597096899477504","58569107296622255421594597096899477504","DE","Germany"
+`), DB1Columns)
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+
+	_, value := tree.Get(net.ParseIP("1.0.0.1"))
+	assert.Equal(t, mmdbtype.Map{
+		"country": mmdbtype.Map{
+			"iso_code": mmdbtype.String("AU"),
+			"names":    mmdbtype.Map{"en": mmdbtype.String("Australia")},
+		},
+	}, value)
+
+	network, value := tree.Get(net.ParseIP("1.1.0.1"))
+	assert.Equal(t, "1.1.0.0/24", network.String())
+	assert.NotNil(t, value)
+
+	network, value = tree.Get(net.ParseIP("2c0f:fff0::"))
+	assert.Equal(t, "2c0f:fff0::/128", network.String())
+	assert.NotNil(t, value)
+}
@@ -0,0 +1,218 @@
+// Package rir reads the delegated statistics files published by the Regional
+// Internet Registries, such as the "delegated-extended" files from ARIN,
+// RIPE NCC, APNIC, LACNIC, and AFRINIC. These files are the canonical public
+// source for the country to which an IP block is delegated.
+//
+// The format is described at
+// https://www.nro.net/wp-content/uploads/nro-extended-stats-readme5.txt.
+package rir
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+// Record is an IP delegation record from a delegated statistics file.
+type Record struct {
+	// Registry is the registry the block is delegated from, e.g., "ripencc".
+	Registry string
+
+	// CountryCode is the ISO 3166 2-letter code of the country the block is
+	// delegated to. It is empty for available and reserved blocks.
+	CountryCode string
+
+	// Start is the first IP address in the block.
+	Start net.IP
+
+	// End is the last IP address in the block. IPv4 blocks are not
+	// required to be CIDR aligned.
+	End net.IP
+
+	// Date is the date of the delegation in the format YYYYMMDD. It may be
+	// empty or "00000000" if the date is unknown.
+	Date string
+
+	// Status is the type of delegation, e.g., "allocated", "assigned",
+	// "available", or "reserved".
+	Status string
+
+	// OpaqueID is the identifier of the organization the block is
+	// delegated to. It is only present in the extended format.
+	OpaqueID string
+}
+
+// IsDelegated returns true if the block is allocated or assigned.
+func (r Record) IsDelegated() bool {
+	return r.Status == "allocated" || r.Status == "assigned"
+}
+
+// DataType returns the record as a map with the country under
+// country.iso_code, matching the GeoIP2 layout, and the registry under
+// registry. Empty values are omitted.
+func (r Record) DataType() mmdbtype.Map {
+	m := mmdbtype.Map{}
+	if r.CountryCode != "" {
+		m["country"] = mmdbtype.Map{
+			"iso_code": mmdbtype.String(r.CountryCode),
+		}
+	}
+	if r.Registry != "" {
+		m["registry"] = mmdbtype.String(r.Registry)
+	}
+	return m
+}
+
+// Reader reads IP records from a delegated statistics file. The version
+// line, summary lines, comments, and ASN records are skipped.
+type Reader struct {
+	scanner   *bufio.Scanner
+	line      int
+	sawHeader bool
+}
+
+// NewReader returns a new Reader that reads from r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{scanner: bufio.NewScanner(r)}
+}
+
+// Read returns the next IP record. It returns io.EOF when there are no more
+// records.
+func (r *Reader) Read() (Record, error) {
+	for r.scanner.Scan() {
+		r.line++
+		line := strings.TrimSpace(r.scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "|")
+
+		if !r.sawHeader {
+			r.sawHeader = true
+			if _, err := strconv.ParseFloat(fields[0], 64); err == nil {
+				// The version line.
+				continue
+			}
+		}
+
+		if len(fields) >= 6 && fields[5] == "summary" {
+			continue
+		}
+		if len(fields) < 7 {
+			return Record{}, fmt.Errorf(
+				"parsing line %d: expected at least 7 fields but found %d",
+				r.line,
+				len(fields),
+			)
+		}
+		if fields[2] != "ipv4" && fields[2] != "ipv6" {
+			continue
+		}
+
+		record, err := parseRecord(fields)
+		if err != nil {
+			return Record{}, fmt.Errorf("parsing line %d: %w", r.line, err)
+		}
+		return record, nil
+	}
+	if err := r.scanner.Err(); err != nil {
+		return Record{}, fmt.Errorf("reading delegated statistics: %w", err)
+	}
+	return Record{}, io.EOF
+}
+
+func parseRecord(fields []string) (Record, error) {
+	record := Record{
+		Registry:    fields[0],
+		CountryCode: strings.ToUpper(fields[1]),
+		Date:        fields[5],
+		Status:      fields[6],
+	}
+	if len(fields) > 7 {
+		record.OpaqueID = fields[7]
+	}
+
+	start := net.ParseIP(fields[3])
+	if start == nil {
+		return Record{}, fmt.Errorf("invalid start address: %q", fields[3])
+	}
+
+	switch fields[2] {
+	case "ipv4":
+		start = start.To4()
+		if start == nil {
+			return Record{}, fmt.Errorf("invalid IPv4 start address: %q", fields[3])
+		}
+		count, err := strconv.ParseUint(fields[4], 10, 64)
+		if err != nil || count == 0 {
+			return Record{}, fmt.Errorf("invalid IPv4 address count: %q", fields[4])
+		}
+		first := uint64(binary.BigEndian.Uint32(start))
+		if first+count-1 > math.MaxUint32 {
+			return Record{}, fmt.Errorf("the block at %s with %d addresses exceeds the IPv4 space", start, count)
+		}
+		end := make(net.IP, net.IPv4len)
+		binary.BigEndian.PutUint32(end, uint32(first+count-1))
+		record.Start = start
+		record.End = end
+	default:
+		if start.To4() != nil {
+			return Record{}, fmt.Errorf("invalid IPv6 start address: %q", fields[3])
+		}
+		prefixLen, err := strconv.Atoi(fields[4])
+		if err != nil || prefixLen < 0 || prefixLen > 128 {
+			return Record{}, fmt.Errorf("invalid IPv6 prefix length: %q", fields[4])
+		}
+		mask := net.CIDRMask(prefixLen, 128)
+		record.Start = start.Mask(mask)
+		end := make(net.IP, net.IPv6len)
+		for i := range end {
+			end[i] = record.Start[i] | ^mask[i]
+		}
+		record.End = end
+	}
+
+	if record.CountryCode == "" && record.IsDelegated() {
+		return Record{}, errors.New("missing country code for delegated block")
+	}
+	return record, nil
+}
+
+// Insert reads the delegated statistics from r and inserts the allocated and
+// assigned blocks into the tree using Record.DataType and the tree's
+// inserter. It returns the number of records inserted.
+func Insert(tree *mmdbwriter.Tree, r io.Reader) (int, error) {
+	reader := NewReader(r)
+	var n int
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		if !record.IsDelegated() {
+			continue
+		}
+		if err := tree.InsertRange(record.Start, record.End, record.DataType()); err != nil {
+			return n, fmt.Errorf(
+				"inserting %s-%s from %s: %w",
+				record.Start,
+				record.End,
+				record.Registry,
+				err,
+			)
+		}
+		n++
+	}
+}
@@ -0,0 +1,139 @@
+package rir
+
+import (
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testDelegated = `# a comment
+2.3|ripencc|1700000000|5|19830705|20231114|+0100
+ripencc|*|asn|*|1|summary
+ripencc|*|ipv4|*|3|summary
+ripencc|*|ipv6|*|1|summary
+ripencc|FR|asn|3215|1|19930901|allocated|abc
+ripencc|DE|ipv4|2.16.0.0|768|20100712|allocated|def
+ripencc|FR|ipv4|2.16.3.0|256|20100712|assigned|abc
+ripencc||ipv4|2.16.4.0|1024||available
+ripencc|NL|ipv6|2001:610::|32|19990819|allocated|ghi
+`
+
+func TestReader(t *testing.T) {
+	r := NewReader(strings.NewReader(testDelegated))
+
+	var records []Record
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		records = append(records, record)
+	}
+
+	require.Len(t, records, 4)
+
+	assert.Equal(
+		t,
+		Record{
+			Registry:    "ripencc",
+			CountryCode: "DE",
+			Start:       net.ParseIP("2.16.0.0").To4(),
+			End:         net.ParseIP("2.16.2.255").To4(),
+			Date:        "20100712",
+			Status:      "allocated",
+			OpaqueID:    "def",
+		},
+		records[0],
+	)
+	assert.True(t, records[1].IsDelegated())
+	assert.False(t, records[2].IsDelegated())
+	assert.Equal(t, "2.16.7.255", records[2].End.String())
+	assert.Equal(t, "2001:610::", records[3].Start.String())
+	assert.Equal(t, "2001:610:ffff:ffff:ffff:ffff:ffff:ffff", records[3].End.String())
+}
+
+func TestReaderErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		err   string
+	}{
+		{
+			name:  "too few fields",
+			input: "ripencc|DE|ipv4|2.16.0.0|768\n",
+			err:   "parsing line 1: expected at least 7 fields but found 5",
+		},
+		{
+			name:  "invalid start",
+			input: "ripencc|DE|ipv4|2.16.0|768|20100712|allocated\n",
+			err:   `parsing line 1: invalid start address: "2.16.0"`,
+		},
+		{
+			name:  "invalid count",
+			input: "ripencc|DE|ipv4|2.16.0.0|0|20100712|allocated\n",
+			err:   `parsing line 1: invalid IPv4 address count: "0"`,
+		},
+		{
+			name:  "overflowing count",
+			input: "ripencc|DE|ipv4|255.255.255.0|512|20100712|allocated\n",
+			err:   "parsing line 1: the block at 255.255.255.0 with 512 addresses exceeds the IPv4 space",
+		},
+		{
+			name:  "invalid prefix length",
+			input: "ripencc|NL|ipv6|2001:610::|129|19990819|allocated\n",
+			err:   `parsing line 1: invalid IPv6 prefix length: "129"`,
+		},
+		{
+			name:  "missing country",
+			input: "ripencc||ipv6|2001:610::|32|19990819|allocated\n",
+			err:   "parsing line 1: missing country code for delegated block",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := NewReader(strings.NewReader(test.input)).Read()
+			assert.EqualError(t, err, test.err)
+		})
+	}
+}
+
+func TestInsert(t *testing.T) {
+	tree, err := mmdbwriter.New(mmdbwriter.Options{})
+	require.NoError(t, err)
+
+	n, err := Insert(tree, strings.NewReader(testDelegated))
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+
+	tests := []struct {
+		ip      string
+		network string
+		country string
+	}{
+		{ip: "2.16.1.1", network: "2.16.0.0/23", country: "DE"},
+		{ip: "2.16.2.1", network: "2.16.2.0/24", country: "DE"},
+		{ip: "2.16.3.1", network: "2.16.3.0/24", country: "FR"},
+		{ip: "2001:610::1", network: "2001:610::/32", country: "NL"},
+	}
+	for _, test := range tests {
+		network, value := tree.Get(net.ParseIP(test.ip))
+		require.NotNil(t, value, test.ip)
+		assert.Equal(t, test.network, network.String(), test.ip)
+		assert.Equal(
+			t,
+			Record{Registry: "ripencc", CountryCode: test.country}.DataType(),
+			value,
+			test.ip,
+		)
+	}
+
+	_, value := tree.Get(net.ParseIP("2.16.4.1"))
+	assert.Nil(t, value)
+}
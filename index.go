@@ -0,0 +1,163 @@
+package mmdbwriter
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+// fieldIndex maps the values of the indexed fields to the networks whose
+// records have them. The networks are found with a single walk of the tree
+// the first time the index is queried after the tree was modified, so any
+// number of queries between modifications share one walk.
+type fieldIndex struct {
+	paths map[string][]mmdbtype.String
+	// networks maps each field and value to the networks, in the order of
+	// Walk. It is nil if the index must be rebuilt.
+	networks map[string]map[string][]*net.IPNet
+}
+
+func newFieldIndex(fields []string) (*fieldIndex, error) {
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	paths := make(map[string][]mmdbtype.String, len(fields))
+	for _, field := range fields {
+		var path []mmdbtype.String
+		for _, key := range strings.Split(field, ".") {
+			if key == "" {
+				return nil, fmt.Errorf("invalid index field: %q", field)
+			}
+			path = append(path, mmdbtype.String(key))
+		}
+		paths[field] = path
+	}
+	return &fieldIndex{paths: paths}, nil
+}
+
+// invalidateIndex discards the networks of the index, if any, so that they
+// are found again on the next query.
+func (t *Tree) invalidateIndex() {
+	if t.index != nil {
+		t.index.networks = nil
+	}
+}
+
+// NetworksWhere returns the networks whose records have the value for the
+// field, e.g., all the networks with "DE" for "country.iso_code". The field
+// must be one of Options.IndexFields. Values are compared by their string
+// form, so the networks of an autonomous system are found with, e.g.,
+// "15169" for "autonomous_system_number". Only strings, integers, floating
+// point values, and booleans are indexed. If a field within the path is a
+// slice, e.g., "subdivisions.iso_code", the records where any element has
+// the value match.
+//
+// The networks are returned in the same order and form as with Walk. After
+// the tree is modified, the first call walks the tree once to rebuild the
+// index for all of the fields.
+func (t *Tree) NetworksWhere(field, value string) ([]*net.IPNet, error) {
+	if t.index == nil {
+		return nil, errors.New("no fields are indexed; set Options.IndexFields")
+	}
+	if _, ok := t.index.paths[field]; !ok {
+		return nil, fmt.Errorf("field %q is not indexed", field)
+	}
+	if t.index.networks == nil {
+		if err := t.buildIndex(); err != nil {
+			return nil, err
+		}
+	}
+	return append([]*net.IPNet(nil), t.index.networks[field][value]...), nil
+}
+
+func (t *Tree) buildIndex() error {
+	networks := make(map[string]map[string][]*net.IPNet, len(t.index.paths))
+	for field := range t.index.paths {
+		networks[field] = map[string][]*net.IPNet{}
+	}
+	err := t.Walk(func(network *net.IPNet, value mmdbtype.DataType) (bool, error) {
+		if value == nil {
+			return true, nil
+		}
+		for field, path := range t.index.paths {
+			for _, key := range indexKeys(value, path, nil) {
+				networks[field][key] = append(networks[field][key], network)
+			}
+		}
+		return true, nil
+	})
+	if err != nil {
+		return err
+	}
+	t.index.networks = networks
+	return nil
+}
+
+// indexKeys appends the string forms of the values at the path in v to
+// keys. A value is only added once.
+func indexKeys(v mmdbtype.DataType, path []mmdbtype.String, keys []string) []string {
+	if s, ok := v.(mmdbtype.Slice); ok {
+		for _, e := range s {
+			keys = indexKeys(e, path, keys)
+		}
+		return keys
+	}
+	if tv, ok := v.(mmdbtype.Templated); ok {
+		v = tv.Map()
+	}
+	if len(path) > 0 {
+		m, ok := v.(mmdbtype.Map)
+		if !ok {
+			return keys
+		}
+		e, ok := m[path[0]]
+		if !ok {
+			return keys
+		}
+		return indexKeys(e, path[1:], keys)
+	}
+
+	key, ok := indexKey(v)
+	if !ok {
+		return keys
+	}
+	for _, k := range keys {
+		if k == key {
+			return keys
+		}
+	}
+	return append(keys, key)
+}
+
+// indexKey returns the string form of a scalar value.
+func indexKey(v mmdbtype.DataType) (string, bool) {
+	switch v := v.(type) {
+	case mmdbtype.String:
+		return string(v), true
+	case mmdbtype.Bool:
+		return strconv.FormatBool(bool(v)), true
+	case mmdbtype.Int32:
+		return strconv.FormatInt(int64(v), 10), true
+	case mmdbtype.Uint16:
+		return strconv.FormatUint(uint64(v), 10), true
+	case mmdbtype.Uint32:
+		return strconv.FormatUint(uint64(v), 10), true
+	case mmdbtype.Uint64:
+		return strconv.FormatUint(uint64(v), 10), true
+	case *mmdbtype.Uint128:
+		return (*big.Int)(v).String(), true
+	case mmdbtype.FixedUint128:
+		return v.BigInt().String(), true
+	case mmdbtype.Float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32), true
+	case mmdbtype.Float64:
+		return strconv.FormatFloat(float64(v), 'g', -1, 64), true
+	default:
+		return "", false
+	}
+}
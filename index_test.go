@@ -0,0 +1,79 @@
+package mmdbwriter
+
+import (
+	"net"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/inserter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNetworksWhere(t *testing.T) {
+	tree, err := New(Options{
+		IndexFields: []string{"country.iso_code", "autonomous_system_number", "subdivisions.iso_code"},
+	})
+	require.NoError(t, err)
+
+	record := func(country string, asn uint32, subdivisions ...string) mmdbtype.Map {
+		m := mmdbtype.Map{
+			"country":                  mmdbtype.Map{"iso_code": mmdbtype.String(country)},
+			"autonomous_system_number": mmdbtype.Uint32(asn),
+		}
+		if len(subdivisions) > 0 {
+			s := mmdbtype.Slice{}
+			for _, sub := range subdivisions {
+				s = append(s, mmdbtype.Map{"iso_code": mmdbtype.String(sub)})
+			}
+			m["subdivisions"] = s
+		}
+		return m
+	}
+	require.NoError(t, tree.Insert(mustNetwork(t, "1.1.1.0/24"), record("DE", 3320, "BE")))
+	require.NoError(t, tree.Insert(mustNetwork(t, "2.2.2.0/24"), record("US", 15169, "CA", "CA")))
+	require.NoError(t, tree.Insert(mustNetwork(t, "2003::/19"), record("DE", 3320, "BY")))
+
+	networks := func(field, value string) []string {
+		nets, err := tree.NetworksWhere(field, value)
+		require.NoError(t, err)
+		var s []string
+		for _, n := range nets {
+			s = append(s, n.String())
+		}
+		return s
+	}
+
+	assert.Equal(t, []string{"1.1.1.0/24", "2003::/19"}, networks("country.iso_code", "DE"))
+	assert.Equal(t, []string{"2.2.2.0/24"}, networks("autonomous_system_number", "15169"))
+	assert.Equal(t, []string{"2.2.2.0/24"}, networks("subdivisions.iso_code", "CA"))
+	assert.Empty(t, networks("country.iso_code", "FR"))
+
+	// The index is rebuilt after the tree is modified.
+	require.NoError(t, tree.Insert(mustNetwork(t, "1.1.1.128/25"), record("FR", 3215)))
+	assert.Equal(t, []string{"1.1.1.0/25", "2003::/19"}, networks("country.iso_code", "DE"))
+	assert.Equal(t, []string{"1.1.1.128/25"}, networks("country.iso_code", "FR"))
+
+	_, err = tree.RemoveIf(func(n *net.IPNet, _ mmdbtype.DataType) bool {
+		return n.IP.To4() == nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1.1.1.0/25"}, networks("country.iso_code", "DE"))
+
+	require.NoError(t, tree.InsertFunc(mustNetwork(t, "1.1.1.0/24"), inserter.Remove))
+	assert.Empty(t, networks("country.iso_code", "DE"))
+
+	_, err = tree.NetworksWhere("city.names.en", "Berlin")
+	assert.EqualError(t, err, `field "city.names.en" is not indexed`)
+	assert.Equal(t, tree.indexFields, tree.options().IndexFields)
+}
+
+func TestNetworksWhereErrors(t *testing.T) {
+	tree, err := New(Options{})
+	require.NoError(t, err)
+	_, err = tree.NetworksWhere("country.iso_code", "DE")
+	assert.EqualError(t, err, "no fields are indexed; set Options.IndexFields")
+
+	_, err = New(Options{IndexFields: []string{"country."}})
+	assert.EqualError(t, err, `invalid index field: "country."`)
+}
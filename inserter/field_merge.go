@@ -0,0 +1,253 @@
+package inserter
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+// FieldMerger merges the existing and new values of a field when a value is
+// inserted into a network that already has a value. See FieldMergers.
+type FieldMerger interface {
+	// MergeField returns the merged value of the field. It is only called
+	// when both values have the field, so neither value is nil. The values
+	// must not be modified. Returning nil removes the field.
+	MergeField(existingValue, newValue mmdbtype.DataType) (mmdbtype.DataType, error)
+}
+
+// FieldMergerFunc is a function that implements FieldMerger.
+type FieldMergerFunc func(existingValue, newValue mmdbtype.DataType) (mmdbtype.DataType, error)
+
+// MergeField calls f(existingValue, newValue).
+func (f FieldMergerFunc) MergeField(existingValue, newValue mmdbtype.DataType) (mmdbtype.DataType, error) {
+	return f(existingValue, newValue)
+}
+
+// FieldMergers is a set of FieldMerger values keyed by the dot-separated
+// path of the field they merge in Map values, e.g., "confidence" or
+// "city.names", allowing the merge logic for a dataset to be declared in
+// one place:
+//
+//	mergers := inserter.FieldMergers{
+//		"confidence": inserter.Max{},
+//		"names":      inserter.Union{},
+//		"last_seen":  inserter.Max{},
+//	}
+//	tree, err := mmdbwriter.New(mmdbwriter.Options{Inserter: mergers.MergeWith})
+type FieldMergers map[string]FieldMerger
+
+// MergeWith creates an inserter that merges the new Map value into the
+// existing value. Fields with a FieldMerger are merged with it. Fields that
+// are a Map in both values are merged recursively, so that mergers may be
+// given for their fields. Other fields are replaced by the new value. A
+// field that is only in one of the values is kept.
+//
+// Both the new and existing value must be a Map. A Templated value is
+// merged as its composed Map. An error will be returned otherwise.
+func (m FieldMergers) MergeWith(newValue mmdbtype.DataType) Func {
+	return func(existingValue mmdbtype.DataType) (mmdbtype.DataType, error) {
+		newMap, ok := asMap(newValue)
+		if !ok {
+			return nil, fmt.Errorf(
+				"the new value is a %T, not a Map; FieldMergers only works if both values are Map values",
+				newValue,
+			)
+		}
+
+		if existingValue == nil {
+			return newValue, nil
+		}
+
+		existingMap, ok := asMap(existingValue)
+		if !ok {
+			return nil, fmt.Errorf(
+				"the existing value is a %T, not a Map; FieldMergers only works if both values are Map values",
+				existingValue,
+			)
+		}
+		return m.mergeMaps("", existingMap, newMap)
+	}
+}
+
+func (m FieldMergers) mergeMaps(prefix string, existingMap, newMap mmdbtype.Map) (mmdbtype.Map, error) {
+	merged := existingMap.Copy().(mmdbtype.Map)
+	for k, nv := range newMap {
+		path := prefix + string(k)
+		ev, ok := existingMap[k]
+		if !ok {
+			merged[k] = nv.Copy()
+			continue
+		}
+
+		if merger, ok := m[path]; ok {
+			v, err := merger.MergeField(ev, nv)
+			if err != nil {
+				return nil, fmt.Errorf("merging %s: %w", path, err)
+			}
+			if v == nil {
+				delete(merged, k)
+			} else {
+				merged[k] = v
+			}
+			continue
+		}
+
+		em, existingIsMap := asMap(ev)
+		nm, newIsMap := asMap(nv)
+		if existingIsMap && newIsMap {
+			v, err := m.mergeMaps(path+".", em, nm)
+			if err != nil {
+				return nil, err
+			}
+			merged[k] = v
+			continue
+		}
+		merged[k] = nv.Copy()
+	}
+	return merged, nil
+}
+
+// Max is a FieldMerger that keeps the larger of the values, e.g., for a
+// confidence score or a last-seen timestamp. Integers of any type, floats
+// of either type, or strings may be compared. Strings are compared
+// lexically, which orders ISO 8601 timestamps in the same time zone
+// chronologically.
+type Max struct{}
+
+// MergeField returns the larger of the values.
+func (Max) MergeField(existingValue, newValue mmdbtype.DataType) (mmdbtype.DataType, error) {
+	c, err := compareValues(existingValue, newValue)
+	if err != nil {
+		return nil, err
+	}
+	if c >= 0 {
+		return existingValue, nil
+	}
+	return newValue, nil
+}
+
+// Min is a FieldMerger that keeps the smaller of the values, e.g., for a
+// first-seen timestamp. The values are compared as with Max.
+type Min struct{}
+
+// MergeField returns the smaller of the values.
+func (Min) MergeField(existingValue, newValue mmdbtype.DataType) (mmdbtype.DataType, error) {
+	c, err := compareValues(existingValue, newValue)
+	if err != nil {
+		return nil, err
+	}
+	if c <= 0 {
+		return existingValue, nil
+	}
+	return newValue, nil
+}
+
+// Union is a FieldMerger that combines the values. For Map values, the
+// result has the keys of both, with the new value for keys in both, e.g.,
+// for a names map with a name per language. For Slice values, the result
+// has the existing elements followed by the new elements that are not
+// equal to an existing element.
+type Union struct{}
+
+// MergeField returns the union of the values.
+func (Union) MergeField(existingValue, newValue mmdbtype.DataType) (mmdbtype.DataType, error) {
+	switch existing := existingValue.(type) {
+	case mmdbtype.Map:
+		if newMap, ok := newValue.(mmdbtype.Map); ok {
+			merged := existing.Copy().(mmdbtype.Map)
+			for k, v := range newMap {
+				merged[k] = v.Copy()
+			}
+			return merged, nil
+		}
+	case mmdbtype.Slice:
+		if newSlice, ok := newValue.(mmdbtype.Slice); ok {
+			merged := existing.Copy().(mmdbtype.Slice)
+			for _, nv := range newSlice {
+				if !containsValue(existing, nv) {
+					merged = append(merged, nv.Copy())
+				}
+			}
+			return merged, nil
+		}
+	}
+	return nil, fmt.Errorf("cannot take the union of a %T and a %T", existingValue, newValue)
+}
+
+func containsValue(s mmdbtype.Slice, v mmdbtype.DataType) bool {
+	for _, e := range s {
+		if e.Equal(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// Keep is a FieldMerger that keeps the existing value, so that the first
+// insert wins for the field.
+type Keep struct{}
+
+// MergeField returns the existing value.
+func (Keep) MergeField(existingValue, _ mmdbtype.DataType) (mmdbtype.DataType, error) {
+	return existingValue, nil
+}
+
+// compareValues returns -1, 0, or 1 if a is less than, equal to, or
+// greater than b.
+func compareValues(a, b mmdbtype.DataType) (int, error) {
+	if as, ok := a.(mmdbtype.String); ok {
+		if bs, ok := b.(mmdbtype.String); ok {
+			return strings.Compare(string(as), string(bs)), nil
+		}
+	}
+	if af, ok := floatValue(a); ok {
+		if bf, ok := floatValue(b); ok {
+			switch {
+			case af < bf:
+				return -1, nil
+			case af > bf:
+				return 1, nil
+			default:
+				return 0, nil
+			}
+		}
+	}
+	if ai, ok := intValue(a); ok {
+		if bi, ok := intValue(b); ok {
+			return ai.Cmp(bi), nil
+		}
+	}
+	return 0, fmt.Errorf("cannot compare a %T and a %T", a, b)
+}
+
+func floatValue(v mmdbtype.DataType) (float64, bool) {
+	switch v := v.(type) {
+	case mmdbtype.Float32:
+		return float64(v), true
+	case mmdbtype.Float64:
+		return float64(v), true
+	default:
+		return 0, false
+	}
+}
+
+func intValue(v mmdbtype.DataType) (*big.Int, bool) {
+	switch v := v.(type) {
+	case mmdbtype.Int32:
+		return big.NewInt(int64(v)), true
+	case mmdbtype.Uint16:
+		return new(big.Int).SetUint64(uint64(v)), true
+	case mmdbtype.Uint32:
+		return new(big.Int).SetUint64(uint64(v)), true
+	case mmdbtype.Uint64:
+		return new(big.Int).SetUint64(uint64(v)), true
+	case *mmdbtype.Uint128:
+		return (*big.Int)(v), true
+	case mmdbtype.FixedUint128:
+		return v.BigInt(), true
+	default:
+		return nil, false
+	}
+}
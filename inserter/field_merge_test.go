@@ -0,0 +1,220 @@
+package inserter
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFieldMergers(t *testing.T) {
+	mergers := FieldMergers{
+		"confidence":      Max{},
+		"first_seen":      Min{},
+		"last_seen":       Max{},
+		"names":           Union{},
+		"tags":            Union{},
+		"source":          Keep{},
+		"city.confidence": Max{},
+		"removed": FieldMergerFunc(func(_, _ mmdbtype.DataType) (mmdbtype.DataType, error) {
+			return nil, nil
+		}),
+	}
+
+	existing := mmdbtype.Map{
+		"confidence": mmdbtype.Uint16(50),
+		"first_seen": mmdbtype.String("2024-01-01"),
+		"last_seen":  mmdbtype.String("2024-01-01"),
+		"names":      mmdbtype.Map{"de": mmdbtype.String("Köln"), "en": mmdbtype.String("Cologne")},
+		"tags":       mmdbtype.Slice{mmdbtype.String("a"), mmdbtype.String("b")},
+		"source":     mmdbtype.String("first"),
+		"city": mmdbtype.Map{
+			"confidence": mmdbtype.Float64(0.9),
+			"name":       mmdbtype.String("old"),
+		},
+		"removed":  mmdbtype.Bool(true),
+		"only_old": mmdbtype.Bool(true),
+		"other":    mmdbtype.Uint32(1),
+	}
+	newValue := mmdbtype.Map{
+		"confidence": mmdbtype.Uint32(80),
+		"first_seen": mmdbtype.String("2024-02-01"),
+		"last_seen":  mmdbtype.String("2024-02-01"),
+		"names":      mmdbtype.Map{"en": mmdbtype.String("Cologne (EN)"), "fr": mmdbtype.String("Cologne")},
+		"tags":       mmdbtype.Slice{mmdbtype.String("b"), mmdbtype.String("c")},
+		"source":     mmdbtype.String("second"),
+		"city": mmdbtype.Map{
+			"confidence": mmdbtype.Float32(0.5),
+			"name":       mmdbtype.String("new"),
+		},
+		"removed":  mmdbtype.Bool(false),
+		"only_new": mmdbtype.Bool(true),
+		"other":    mmdbtype.Uint32(2),
+	}
+	existingCopy := existing.Copy()
+
+	v, err := mergers.MergeWith(newValue)(existing)
+	require.NoError(t, err)
+	assert.Equal(t, mmdbtype.Map{
+		"confidence": mmdbtype.Uint32(80),
+		"first_seen": mmdbtype.String("2024-01-01"),
+		"last_seen":  mmdbtype.String("2024-02-01"),
+		"names": mmdbtype.Map{
+			"de": mmdbtype.String("Köln"),
+			"en": mmdbtype.String("Cologne (EN)"),
+			"fr": mmdbtype.String("Cologne"),
+		},
+		"tags":   mmdbtype.Slice{mmdbtype.String("a"), mmdbtype.String("b"), mmdbtype.String("c")},
+		"source": mmdbtype.String("first"),
+		"city": mmdbtype.Map{
+			"confidence": mmdbtype.Float64(0.9),
+			"name":       mmdbtype.String("new"),
+		},
+		"only_old": mmdbtype.Bool(true),
+		"only_new": mmdbtype.Bool(true),
+		"other":    mmdbtype.Uint32(2),
+	}, v)
+	assert.Equal(t, existingCopy, existing, "the existing value is not modified")
+
+	v, err = mergers.MergeWith(newValue)(nil)
+	require.NoError(t, err)
+	assert.Equal(t, newValue, v)
+}
+
+func TestFieldMergersErrors(t *testing.T) {
+	mergers := FieldMergers{"a": Max{}, "b": Union{}}
+
+	tests := []struct {
+		description string
+		existing    mmdbtype.DataType
+		new         mmdbtype.DataType
+		expectedErr string
+	}{
+		{
+			description: "new slice",
+			existing:    mmdbtype.Map{},
+			new:         mmdbtype.Slice{},
+			expectedErr: "the new value is a mmdbtype.Slice, not a Map; " +
+				"FieldMergers only works if both values are Map values",
+		},
+		{
+			description: "existing slice",
+			existing:    mmdbtype.Slice{},
+			new:         mmdbtype.Map{},
+			expectedErr: "the existing value is a mmdbtype.Slice, not a Map; " +
+				"FieldMergers only works if both values are Map values",
+		},
+		{
+			description: "incomparable",
+			existing:    mmdbtype.Map{"a": mmdbtype.String("1")},
+			new:         mmdbtype.Map{"a": mmdbtype.Uint32(1)},
+			expectedErr: "merging a: cannot compare a mmdbtype.String and a mmdbtype.Uint32",
+		},
+		{
+			description: "union of scalars",
+			existing:    mmdbtype.Map{"b": mmdbtype.Bool(true)},
+			new:         mmdbtype.Map{"b": mmdbtype.Bool(false)},
+			expectedErr: "merging b: cannot take the union of a mmdbtype.Bool and a mmdbtype.Bool",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			_, err := mergers.MergeWith(test.new)(test.existing)
+			assert.EqualError(t, err, test.expectedErr)
+		})
+	}
+}
+
+func TestCompareValues(t *testing.T) {
+	tests := []struct {
+		a, b     mmdbtype.DataType
+		expected int
+	}{
+		{a: mmdbtype.Int32(-1), b: mmdbtype.Uint64(1), expected: -1},
+		{a: mmdbtype.Uint16(2), b: mmdbtype.Uint32(2), expected: 0},
+		{a: (*mmdbtype.Uint128)(big.NewInt(3)), b: mmdbtype.Uint64(2), expected: 1},
+		{a: mmdbtype.Uint128FromUint64(3), b: (*mmdbtype.Uint128)(big.NewInt(3)), expected: 0},
+		{a: mmdbtype.Uint128FromUint64(1), b: mmdbtype.Int32(2), expected: -1},
+		{a: mmdbtype.Float32(1.5), b: mmdbtype.Float64(1.25), expected: 1},
+		{a: mmdbtype.String("a"), b: mmdbtype.String("b"), expected: -1},
+	}
+	for _, test := range tests {
+		c, err := compareValues(test.a, test.b)
+		require.NoError(t, err)
+		assert.Equal(t, test.expected, c, "%v <=> %v", test.a, test.b)
+	}
+}
+
+func TestUnionMixedUint128(t *testing.T) {
+	v, err := Union{}.MergeField(
+		mmdbtype.Slice{mmdbtype.Uint128FromUint64(1)},
+		mmdbtype.Slice{(*mmdbtype.Uint128)(big.NewInt(1)), mmdbtype.Uint128FromUint64(2)},
+	)
+	require.NoError(t, err)
+	assert.Equal(t, mmdbtype.Slice{mmdbtype.Uint128FromUint64(1), mmdbtype.Uint128FromUint64(2)}, v)
+}
+
+func TestFieldMergersTemplated(t *testing.T) {
+	mergers := FieldMergers{
+		"confidence":      Max{},
+		"city.confidence": Max{},
+	}
+	template := mmdbtype.Map{
+		"city": mmdbtype.Map{
+			"confidence": mmdbtype.Uint16(50),
+			"name":       mmdbtype.String("Berlin"),
+		},
+		"confidence": mmdbtype.Uint16(50),
+	}
+
+	tests := []struct {
+		description string
+		existing    mmdbtype.DataType
+		new         mmdbtype.DataType
+	}{
+		{
+			description: "existing templated",
+			existing: mmdbtype.Templated{
+				Template:  template,
+				Overrides: mmdbtype.Map{"confidence": mmdbtype.Uint16(80)},
+			},
+			new: mmdbtype.Map{
+				"city":       mmdbtype.Map{"confidence": mmdbtype.Uint16(70)},
+				"confidence": mmdbtype.Uint16(60),
+			},
+		},
+		{
+			description: "new templated",
+			existing: mmdbtype.Map{
+				"city":       mmdbtype.Map{"confidence": mmdbtype.Uint16(70)},
+				"confidence": mmdbtype.Uint16(60),
+			},
+			new: mmdbtype.Templated{
+				Template:  template,
+				Overrides: mmdbtype.Map{"confidence": mmdbtype.Uint16(80)},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.description, func(t *testing.T) {
+			v, err := mergers.MergeWith(test.new)(test.existing)
+			require.NoError(t, err)
+			assert.Equal(
+				t,
+				mmdbtype.Map{
+					"city": mmdbtype.Map{
+						"confidence": mmdbtype.Uint16(70),
+						"name":       mmdbtype.String("Berlin"),
+					},
+					"confidence": mmdbtype.Uint16(80),
+				},
+				v,
+			)
+			// The shared template is not modified.
+			assert.Equal(t, mmdbtype.Uint16(50), template["confidence"])
+		})
+	}
+}
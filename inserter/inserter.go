@@ -29,15 +29,29 @@ func ReplaceWith(value mmdbtype.DataType) Func {
 	}
 }
 
+// FillGapsWith generates an inserter function that only inserts the new
+// value where there is no existing value. Existing values, including those
+// for more specific networks, are kept, and the network is split around
+// them as needed. This is useful for applying a lower-priority dataset
+// after a higher-priority one.
+func FillGapsWith(value mmdbtype.DataType) Func {
+	return func(existingValue mmdbtype.DataType) (mmdbtype.DataType, error) {
+		if existingValue != nil {
+			return existingValue, nil
+		}
+		return value, nil
+	}
+}
+
 // TopLevelMergeWith creates an inserter for Map values that will update an
 // existing Map by adding the top-level keys and values from the new Map,
 // replacing any existing values for the keys.
 //
-// Both the new and existing value must be a Map. An error will be returned
-// otherwise.
+// Both the new and existing value must be a Map. A Templated value is
+// merged as its composed Map. An error will be returned otherwise.
 func TopLevelMergeWith(newValue mmdbtype.DataType) Func {
 	return func(existingValue mmdbtype.DataType) (mmdbtype.DataType, error) {
-		newMap, ok := newValue.(mmdbtype.Map)
+		newMap, ok := asMap(newValue)
 		if !ok {
 			return nil, fmt.Errorf(
 				"the new value is a %T, not a Map; TopLevelMergeWith only works if both values are Map values",
@@ -51,7 +65,7 @@ func TopLevelMergeWith(newValue mmdbtype.DataType) Func {
 
 		// A possible optimization would be to not bother copying
 		// values that will be replaced.
-		existingMap, ok := existingValue.(mmdbtype.Map)
+		existingMap, ok := asMap(existingValue)
 		if !ok {
 			return nil, fmt.Errorf(
 				"the existing value is a %T, not a Map; TopLevelMergeWith only works if both values are Map values",
@@ -70,8 +84,9 @@ func TopLevelMergeWith(newValue mmdbtype.DataType) Func {
 }
 
 // DeepMergeWith creates an inserter that will recursively update an existing
-// value. Map and Slice values will be merged recursively. Other values will
-// be replaced by the new value.
+// value. Map and Slice values will be merged recursively, with Templated
+// values merged as their composed Map. Other values will be replaced by the
+// new value.
 func DeepMergeWith(newValue mmdbtype.DataType) Func {
 	return func(existingValue mmdbtype.DataType) (mmdbtype.DataType, error) {
 		return deepMerge(existingValue, newValue)
@@ -85,9 +100,12 @@ func deepMerge(existingValue, newValue mmdbtype.DataType) (mmdbtype.DataType, er
 	if newValue == nil {
 		return existingValue, nil
 	}
+	if tv, ok := existingValue.(mmdbtype.Templated); ok {
+		existingValue = tv.Map()
+	}
 	switch existingValue := existingValue.(type) {
 	case mmdbtype.Map:
-		newMap, ok := newValue.(mmdbtype.Map)
+		newMap, ok := asMap(newValue)
 		if !ok {
 			return newValue, nil
 		}
@@ -130,3 +148,13 @@ func deepMerge(existingValue, newValue mmdbtype.DataType) (mmdbtype.DataType, er
 		return newValue, nil
 	}
 }
+
+// asMap returns the value as a Map. A Templated value is returned as its
+// composed Map.
+func asMap(v mmdbtype.DataType) (mmdbtype.Map, bool) {
+	if tv, ok := v.(mmdbtype.Templated); ok {
+		return tv.Map(), true
+	}
+	m, ok := v.(mmdbtype.Map)
+	return m, ok
+}
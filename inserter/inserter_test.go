@@ -20,6 +20,16 @@ func TestReplaceWith(t *testing.T) {
 	assert.Equal(t, mmdbtype.Uint64(1), v)
 }
 
+func TestFillGapsWith(t *testing.T) {
+	v, err := FillGapsWith(mmdbtype.Uint64(1))(mmdbtype.Bool(true))
+	require.NoError(t, err)
+	assert.Equal(t, mmdbtype.Bool(true), v)
+
+	v, err = FillGapsWith(mmdbtype.Uint64(1))(nil)
+	require.NoError(t, err)
+	assert.Equal(t, mmdbtype.Uint64(1), v)
+}
+
 func TestTopLevelMergeWith(t *testing.T) {
 	tests := []struct {
 		description string
@@ -78,6 +88,32 @@ func TestTopLevelMergeWith(t *testing.T) {
 				},
 			},
 		},
+		{
+			description: "existing templated, new map",
+			existing: mmdbtype.Templated{
+				Template:  mmdbtype.Map{"a": mmdbtype.String("template"), "b": mmdbtype.String("b")},
+				Overrides: mmdbtype.Map{"a": mmdbtype.String("override")},
+			},
+			new: mmdbtype.Map{"c": mmdbtype.String("c")},
+			expected: mmdbtype.Map{
+				"a": mmdbtype.String("override"),
+				"b": mmdbtype.String("b"),
+				"c": mmdbtype.String("c"),
+			},
+		},
+		{
+			description: "existing map, new templated",
+			existing:    mmdbtype.Map{"a": mmdbtype.String("existing"), "c": mmdbtype.String("c")},
+			new: mmdbtype.Templated{
+				Template:  mmdbtype.Map{"a": mmdbtype.String("template"), "b": mmdbtype.String("b")},
+				Overrides: mmdbtype.Map{"a": mmdbtype.String("override")},
+			},
+			expected: mmdbtype.Map{
+				"a": mmdbtype.String("override"),
+				"b": mmdbtype.String("b"),
+				"c": mmdbtype.String("c"),
+			},
+		},
 	}
 
 	for _, test := range tests {
@@ -167,6 +203,60 @@ func TestDeepMergeWith(t *testing.T) {
 					},
 				},
 			},
+		}, {
+			description: "existing templated, new map",
+			existing: mmdbtype.Templated{
+				Template: mmdbtype.Map{
+					"location": mmdbtype.Map{"time_zone": mmdbtype.String("UTC")},
+				},
+				Overrides: mmdbtype.Map{"a": mmdbtype.String("override")},
+			},
+			new: mmdbtype.Map{
+				"location": mmdbtype.Map{"accuracy_radius": mmdbtype.Uint16(5)},
+			},
+			expected: mmdbtype.Map{
+				"a": mmdbtype.String("override"),
+				"location": mmdbtype.Map{
+					"accuracy_radius": mmdbtype.Uint16(5),
+					"time_zone":       mmdbtype.String("UTC"),
+				},
+			},
+		},
+		{
+			description: "existing map, new templated",
+			existing: mmdbtype.Map{
+				"location": mmdbtype.Map{"accuracy_radius": mmdbtype.Uint16(5)},
+			},
+			new: mmdbtype.Templated{
+				Template: mmdbtype.Map{
+					"location": mmdbtype.Map{"time_zone": mmdbtype.String("UTC")},
+				},
+				Overrides: mmdbtype.Map{"a": mmdbtype.String("override")},
+			},
+			expected: mmdbtype.Map{
+				"a": mmdbtype.String("override"),
+				"location": mmdbtype.Map{
+					"accuracy_radius": mmdbtype.Uint16(5),
+					"time_zone":       mmdbtype.String("UTC"),
+				},
+			},
+		},
+		{
+			description: "nested templated",
+			existing: mmdbtype.Map{
+				"city": mmdbtype.Templated{
+					Template: mmdbtype.Map{"name": mmdbtype.String("Berlin")},
+				},
+			},
+			new: mmdbtype.Map{
+				"city": mmdbtype.Map{"confidence": mmdbtype.Uint16(90)},
+			},
+			expected: mmdbtype.Map{
+				"city": mmdbtype.Map{
+					"confidence": mmdbtype.Uint16(90),
+					"name":       mmdbtype.String("Berlin"),
+				},
+			},
 		},
 	}
 
@@ -0,0 +1,69 @@
+package mmdbwriter
+
+import (
+	"errors"
+	"fmt"
+	"net"
+)
+
+// IPv4Placement is the network where the IPv4 addresses are stored in an
+// IPv6 tree. See Options.IPv4Placement.
+type IPv4Placement int
+
+const (
+	// IPv4PlacementCompatible stores the IPv4 addresses in ::/96, where the
+	// MaxMind DB readers look them up. This is the default.
+	IPv4PlacementCompatible IPv4Placement = iota
+
+	// IPv4PlacementMapped stores the IPv4 addresses in ::ffff:0:0/96, the
+	// IPv4-mapped IPv6 addresses. Unless IPv4 aliasing is disabled, ::/96
+	// is an alias of ::ffff:0:0/96 instead, so readers still find the
+	// IPv4 data.
+	IPv4PlacementMapped
+)
+
+// network returns the IPv6 network containing the IPv4 addresses.
+func (p IPv4Placement) network() string {
+	if p == IPv4PlacementMapped {
+		return ipv4MappedNetwork
+	}
+	return ipv4CompatibleNetwork
+}
+
+// prefix returns the first 12 bytes of the IPv6 form of an IPv4 address.
+func (p IPv4Placement) prefix() net.IP {
+	if p == IPv4PlacementMapped {
+		return net.IP{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff}
+	}
+	return v4Prefix
+}
+
+func validateIPv4Placement(opts Options, ipVersion int) error {
+	switch opts.IPv4Placement {
+	case IPv4PlacementCompatible:
+		return nil
+	case IPv4PlacementMapped:
+	default:
+		return fmt.Errorf("unsupported IPv4Placement: %d", opts.IPv4Placement)
+	}
+	switch {
+	case ipVersion != 6:
+		return errors.New("IPv4PlacementMapped requires IPVersion to be 6")
+	case opts.IPv6Only:
+		return errors.New("IPv4PlacementMapped cannot be used with IPv6Only")
+	case opts.ReserveIPv4MappedNetwork:
+		return errors.New("IPv4PlacementMapped cannot be used with ReserveIPv4MappedNetwork")
+	}
+	return nil
+}
+
+// ipv4ToTree returns the IPv6 form of the IPv4 address in the tree.
+func (t *Tree) ipv4ToTree(ip net.IP) net.IP {
+	return append(append(make(net.IP, 0, net.IPv6len), t.ipv4Prefix...), ip...)
+}
+
+// inIPv4Subtree returns whether the network, in tree form, is within the
+// IPv4 subtree of an IPv6 tree.
+func (t *Tree) inIPv4Subtree(ip net.IP, prefixLen int) bool {
+	return t.treeDepth == 128 && prefixLen >= 96 && net.IP(ip[:12]).Equal(t.ipv4Prefix)
+}
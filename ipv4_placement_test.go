@@ -0,0 +1,105 @@
+package mmdbwriter
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/oschwald/maxminddb-golang"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIPv4PlacementMapped(t *testing.T) {
+	for _, disableAliasing := range []bool{false, true} {
+		tree, err := New(Options{
+			DatabaseType:        "Test",
+			IPv4Placement:       IPv4PlacementMapped,
+			DisableIPv4Aliasing: disableAliasing,
+		})
+		require.NoError(t, err)
+
+		require.NoError(t, tree.Insert(mustNetwork(t, "1.1.1.0/24"), mmdbtype.String("a")))
+		require.NoError(t, tree.Insert(mustNetwork(t, "::ffff:2.2.2.0/120"), mmdbtype.String("b")))
+		require.NoError(t, tree.Check())
+
+		network, value := tree.Get(net.ParseIP("1.1.1.1").To4())
+		assert.Equal(t, "1.1.1.0/24", network.String())
+		assert.Equal(t, mmdbtype.String("a"), value)
+		_, value = tree.Get(net.ParseIP("2.2.2.2"))
+		assert.Equal(t, mmdbtype.String("b"), value)
+
+		var networks []string
+		require.NoError(t, tree.Walk(func(network *net.IPNet, value mmdbtype.DataType) (bool, error) {
+			if value != nil {
+				networks = append(networks, network.String())
+			}
+			return true, nil
+		}))
+		assert.Equal(t, []string{"1.1.1.0/24", "2.2.2.0/24"}, networks)
+
+		buf := &bytes.Buffer{}
+		_, err = tree.WriteTo(buf)
+		require.NoError(t, err)
+		findings, err := Verify(bytes.NewReader(buf.Bytes()))
+		require.NoError(t, err)
+		assert.Empty(t, findings)
+
+		reader, err := maxminddb.FromBytes(buf.Bytes())
+		require.NoError(t, err)
+		lookup := func(ip string) any {
+			var record any
+			require.NoError(t, reader.Lookup(net.ParseIP(ip), &record))
+			return record
+		}
+		// maxminddb-golang looks up IPv4 and IPv4-mapped addresses in
+		// ::/96.
+		for _, ip := range []string{"1.1.1.1", "::ffff:1.1.1.1"} {
+			if disableAliasing {
+				assert.Nil(t, lookup(ip), "::/96 is empty")
+			} else {
+				assert.Equal(t, "a", lookup(ip), "::/96 is an alias")
+			}
+		}
+	}
+}
+
+func TestIPv4PlacementValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		err  string
+	}{
+		{
+			name: "unknown",
+			opts: Options{IPv4Placement: 5},
+			err:  "unsupported IPv4Placement: 5",
+		},
+		{
+			name: "IPv4 tree",
+			opts: Options{IPVersion: 4, IPv4Placement: IPv4PlacementMapped},
+			err:  "IPv4PlacementMapped requires IPVersion to be 6",
+		},
+		{
+			name: "IPv6Only",
+			opts: Options{IPv6Only: true, IPv4Placement: IPv4PlacementMapped},
+			err:  "IPv4PlacementMapped cannot be used with IPv6Only",
+		},
+		{
+			name: "ReserveIPv4MappedNetwork",
+			opts: Options{
+				DisableIPv4Aliasing:      true,
+				ReserveIPv4MappedNetwork: true,
+				IPv4Placement:            IPv4PlacementMapped,
+			},
+			err: "IPv4PlacementMapped cannot be used with ReserveIPv4MappedNetwork",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := New(test.opts)
+			assert.EqualError(t, err, test.err)
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package mmdbwriter
+
+import (
+	"errors"
+	"fmt"
+	"net"
+)
+
+// ErrIPv4InIPv6OnlyTree is wrapped by the errors returned when an IPv4
+// network or address is used with a tree with Options.IPv6Only set.
+var ErrIPv4InIPv6OnlyTree = errors.New("IPv4 is not supported by an IPv6-only tree")
+
+// ipv4CompatibleNetwork is the network where the IPv4 addresses are stored
+// in an IPv6 tree. It is reserved by Options.IPv6Only along with
+// ipv4MappedNetwork.
+const ipv4CompatibleNetwork = "::/96"
+
+// isIPv4Network returns whether the network is an IPv4 network, including
+// an IPv4-mapped IPv6 network, e.g., ::ffff:1.1.1.0/120.
+func isIPv4Network(network *net.IPNet) bool {
+	if len(network.IP) == net.IPv4len {
+		return true
+	}
+	prefixLen, _ := network.Mask.Size()
+	return prefixLen >= 96 && network.IP.To4() != nil
+}
+
+// checkIPv6Only returns an error if the tree is IPv6-only and the network
+// is an IPv4 network.
+func (t *Tree) checkIPv6Only(network *net.IPNet) error {
+	if t.ipv6Only && isIPv4Network(network) {
+		return fmt.Errorf("cannot insert %s: %w", network, ErrIPv4InIPv6OnlyTree)
+	}
+	return nil
+}
+
+// insertIPv6OnlyReservations reserves the networks where IPv4 addresses
+// are found in an IPv6 tree without aliases, so that lookups of IPv4
+// addresses never find data, even that of a network containing them such
+// as ::/0.
+func (t *Tree) insertIPv6OnlyReservations() error {
+	for _, network := range []string{ipv4CompatibleNetwork, ipv4MappedNetwork} {
+		if err := t.insertStringNetwork(network, recordTypeReserved, nil, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
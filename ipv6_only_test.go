@@ -0,0 +1,68 @@
+package mmdbwriter
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/oschwald/maxminddb-golang"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIPv6Only(t *testing.T) {
+	tree, err := New(Options{DatabaseType: "Test", IPv6Only: true})
+	require.NoError(t, err)
+
+	value := mmdbtype.String("value")
+	for _, network := range []string{"1.1.1.0/24", "::ffff:1.1.1.0/120"} {
+		err := tree.Insert(mustNetwork(t, network), value)
+		assert.ErrorIs(t, err, ErrIPv4InIPv6OnlyTree, network)
+	}
+	err = tree.InsertRange(net.ParseIP("1.1.1.0"), net.ParseIP("1.1.1.255"), value)
+	assert.ErrorIs(t, err, ErrIPv4InIPv6OnlyTree)
+
+	err = tree.Insert(mustNetwork(t, "::1.1.1.0/120"), value)
+	assert.EqualError(t, err, "attempt to insert ::101:100/120, which is in a reserved network")
+
+	require.NoError(t, tree.Insert(mustNetwork(t, "::/0"), value))
+	require.NoError(t, tree.Check())
+
+	network, v, found := tree.GetRecord(net.ParseIP("2003::1"))
+	assert.True(t, network.Contains(net.ParseIP("2003::1")))
+	assert.Equal(t, value, v)
+	assert.True(t, found)
+
+	network, v, found = tree.GetRecord(net.ParseIP("1.1.1.1"))
+	assert.Nil(t, network)
+	assert.Nil(t, v)
+	assert.False(t, found)
+
+	var result string
+	err = tree.Lookup(net.ParseIP("1.1.1.1"), &result)
+	assert.ErrorIs(t, err, ErrIPv4InIPv6OnlyTree)
+	err = tree.Snapshot().Lookup(net.ParseIP("1.1.1.1").To4(), &result)
+	assert.ErrorIs(t, err, ErrIPv4InIPv6OnlyTree)
+	network, _ = tree.Snapshot().Get(net.ParseIP("1.1.1.1"))
+	assert.Nil(t, network)
+
+	buf := &bytes.Buffer{}
+	_, err = tree.WriteTo(buf)
+	require.NoError(t, err)
+	reader, err := maxminddb.FromBytes(buf.Bytes())
+	require.NoError(t, err)
+	for _, ip := range []string{"1.1.1.1", "::1.1.1.1", "::ffff:1.1.1.1"} {
+		var record any
+		_, ok, err := reader.LookupNetwork(net.ParseIP(ip), &record)
+		require.NoError(t, err)
+		assert.False(t, ok, ip)
+	}
+	var record any
+	_, ok, err := reader.LookupNetwork(net.ParseIP("2003::1"), &record)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	_, err = New(Options{IPVersion: 4, IPv6Only: true})
+	assert.EqualError(t, err, "IPv6Only requires IPVersion to be 6")
+}
@@ -0,0 +1,82 @@
+package mmdbwriter
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// languageTagRE matches the general syntax of BCP 47 language tags, e.g.,
+// "en", "pt-BR", or "zh-Hans-CN". It does not check that the subtags are
+// registered.
+var languageTagRE = regexp.MustCompile(`^[a-zA-Z]{2,8}(?:-[a-zA-Z0-9]{1,8})*$`)
+
+// setLanguages validates the languages and description and sets them on
+// the tree. The metadata that Load read from an existing database is
+// flagged in loaded and is not validated, as the database may predate the
+// validation.
+func (t *Tree) setLanguages(opts Options, loaded loadedMetadata) error {
+	if !loaded.languages {
+		for _, lang := range opts.Languages {
+			if !languageTagRE.MatchString(lang) {
+				return fmt.Errorf("invalid language in Languages: %q is not a BCP 47 language tag", lang)
+			}
+		}
+	}
+
+	descLangs := make([]string, 0, len(opts.Description))
+	for lang := range opts.Description {
+		if !loaded.description && !languageTagRE.MatchString(lang) {
+			return fmt.Errorf("invalid language in Description: %q is not a BCP 47 language tag", lang)
+		}
+		descLangs = append(descLangs, lang)
+	}
+	sort.Strings(descLangs)
+
+	languages := opts.Languages
+	if opts.PopulateLanguagesFromDescription {
+		languages = append([]string(nil), languages...)
+		for _, lang := range descLangs {
+			if !containsString(languages, lang) {
+				languages = append(languages, lang)
+			}
+		}
+	} else if len(languages) > 0 && !loaded.languages && !loaded.description {
+		// Databases without localized data often do not set Languages but
+		// still have an English description. We only require consistency
+		// if Languages is set.
+		for _, lang := range descLangs {
+			if !containsString(languages, lang) {
+				return fmt.Errorf(
+					"the Description language %q is not in Languages; "+
+						"add it to Languages or set PopulateLanguagesFromDescription",
+					lang,
+				)
+			}
+		}
+	}
+
+	if opts.Description != nil {
+		t.description = opts.Description
+	}
+	if languages != nil {
+		t.languages = languages
+	}
+	return nil
+}
+
+// loadedMetadata records which of the options Load took from the metadata
+// of the existing database.
+type loadedMetadata struct {
+	description bool
+	languages   bool
+}
+
+func containsString(s []string, v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
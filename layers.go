@@ -0,0 +1,174 @@
+package mmdbwriter
+
+import (
+	"fmt"
+	"io"
+	"net"
+)
+
+// LayeredTree holds the data of several datasets, or layers, e.g., a
+// commercial feed, corrections from customers, and manual overrides. Each
+// layer is a separate Tree, and the layers are only merged into a single
+// tree when the database is built. As the data of each layer is kept, the
+// precedence of the layers may be changed without inserting the data again.
+//
+// The records of a tree still hold a single value. Rather than keeping a
+// value per layer in each leaf, every layer has its own nodes, so a network
+// present in several layers is stored once per layer, and merging walks
+// all of the layers and inserts their networks into a new tree.
+//
+// The layers are merged from the lowest precedence to the highest. The
+// networks of each layer are inserted into the merged tree with the
+// Options.Inserter, so with the default inserter.ReplaceWith, the value of
+// the layer with the highest precedence containing an IP address is used,
+// while with inserter.DeepMergeWith, the values of all the layers are
+// merged, with the values of the higher layers winning conflicts.
+type LayeredTree struct {
+	opts   Options
+	layers map[string]*Tree
+	// order lists the layers from the lowest precedence to the highest.
+	order []string
+}
+
+// NewLayeredTree creates a LayeredTree without any layers. The options are
+// used for each layer as well as for the merged tree.
+func NewLayeredTree(opts Options) (*LayeredTree, error) {
+	// We create a tree to validate the options.
+	tree, err := New(opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := tree.Close(); err != nil {
+		return nil, err
+	}
+	return &LayeredTree{
+		opts:   opts,
+		layers: map[string]*Tree{},
+	}, nil
+}
+
+// Layer returns the tree of the named layer. If the layer does not exist,
+// it is created with a higher precedence than the existing layers. Data is
+// added to the layer by inserting into the tree.
+func (lt *LayeredTree) Layer(name string) (*Tree, error) {
+	if tree, ok := lt.layers[name]; ok {
+		return tree, nil
+	}
+	tree, err := New(lt.opts)
+	if err != nil {
+		return nil, fmt.Errorf("creating layer %q: %w", name, err)
+	}
+	lt.layers[name] = tree
+	lt.order = append(lt.order, name)
+	return tree, nil
+}
+
+// RemoveLayer removes the named layer and closes its tree.
+func (lt *LayeredTree) RemoveLayer(name string) error {
+	tree, ok := lt.layers[name]
+	if !ok {
+		return fmt.Errorf("unknown layer: %q", name)
+	}
+	delete(lt.layers, name)
+	for i, n := range lt.order {
+		if n == name {
+			lt.order = append(lt.order[:i], lt.order[i+1:]...)
+			break
+		}
+	}
+	return tree.Close()
+}
+
+// Precedence returns the names of the layers from the lowest precedence to
+// the highest.
+func (lt *LayeredTree) Precedence() []string {
+	return append([]string(nil), lt.order...)
+}
+
+// SetPrecedence sets the precedence of the layers. The names must list
+// every layer exactly once, from the lowest precedence to the highest.
+func (lt *LayeredTree) SetPrecedence(names ...string) error {
+	if len(names) != len(lt.layers) {
+		return fmt.Errorf(
+			"the precedence must list all %d layers but it lists %d",
+			len(lt.layers),
+			len(names),
+		)
+	}
+	seen := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		if _, ok := lt.layers[name]; !ok {
+			return fmt.Errorf("unknown layer: %q", name)
+		}
+		if _, ok := seen[name]; ok {
+			return fmt.Errorf("layer %q is listed more than once", name)
+		}
+		seen[name] = struct{}{}
+	}
+	lt.order = append(lt.order[:0], names...)
+	return nil
+}
+
+// Merge returns a new tree with the layers merged in order of precedence.
+// The layers are not modified. Inserting into the merged tree does not
+// affect the layers.
+//
+// If Options.TrackSources is set, the source of each network in the merged
+// tree is the name of the layer it was inserted from. The merge is not
+// reported to Options.Metrics, and as the layers may overlap, the
+// Options.DuplicatePolicy is not applied.
+func (lt *LayeredTree) Merge() (*Tree, error) {
+	opts := lt.opts
+	opts.Metrics = nil
+	opts.DuplicatePolicy = DuplicateMerge
+	opts.TrackDuplicates = false
+	merged, err := New(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range lt.order {
+		if err := merged.insertLayer(lt.layers[name], name); err != nil {
+			merged.Close() //nolint:errcheck // the merge error is more relevant
+			return nil, fmt.Errorf("merging layer %q: %w", name, err)
+		}
+	}
+	return merged, nil
+}
+
+// insertLayer inserts the data of the layer into t with t's inserter.
+func (t *Tree) insertLayer(layer *Tree, name string) error {
+	t.SetSource(name)
+	return layer.root.walk(make(net.IP, layer.treeDepth/8), 0, func(ip net.IP, prefixLen int, r record) error {
+		if r.recordType != recordTypeData {
+			return nil
+		}
+		network := &net.IPNet{
+			IP:   make(net.IP, len(ip)),
+			Mask: net.CIDRMask(prefixLen, layer.treeDepth),
+		}
+		copy(network.IP, ip)
+		return t.insert(network, recordTypeData, t.inserterFuncGen(layer.dataMap.load(r.value)), nil)
+	})
+}
+
+// WriteTo merges the layers and writes the merged tree to w.
+func (lt *LayeredTree) WriteTo(w io.Writer) (int64, error) {
+	merged, err := lt.Merge()
+	if err != nil {
+		return 0, err
+	}
+	defer merged.Close() //nolint:errcheck // closing only releases the storage of the temporary merged tree
+	return merged.WriteTo(w)
+}
+
+// Close closes the trees of all of the layers.
+func (lt *LayeredTree) Close() error {
+	var firstErr error
+	for _, name := range lt.order {
+		if err := lt.layers[name].Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
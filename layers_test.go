@@ -0,0 +1,114 @@
+package mmdbwriter
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/inserter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLayeredTree(t *testing.T) {
+	lt, err := NewLayeredTree(Options{IPVersion: 4, TrackSources: true, BuildEpoch: 1})
+	require.NoError(t, err)
+	defer lt.Close() //nolint:errcheck // test
+
+	feed, err := lt.Layer("feed")
+	require.NoError(t, err)
+	require.NoError(t, feed.Insert(mustNetwork(t, "1.1.0.0/16"), mmdbtype.String("feed")))
+	require.NoError(t, feed.Insert(mustNetwork(t, "2.2.2.0/24"), mmdbtype.String("feed")))
+
+	overrides, err := lt.Layer("overrides")
+	require.NoError(t, err)
+	require.NoError(t, overrides.Insert(mustNetwork(t, "1.1.1.0/24"), mmdbtype.String("overrides")))
+
+	same, err := lt.Layer("feed")
+	require.NoError(t, err)
+	assert.Same(t, feed, same)
+	assert.Equal(t, []string{"feed", "overrides"}, lt.Precedence())
+
+	get := func(tree *Tree, ip string) mmdbtype.DataType {
+		_, value := tree.Get(net.ParseIP(ip).To4())
+		return value
+	}
+
+	merged, err := lt.Merge()
+	require.NoError(t, err)
+	assert.Equal(t, mmdbtype.String("overrides"), get(merged, "1.1.1.1"))
+	assert.Equal(t, mmdbtype.String("feed"), get(merged, "1.1.2.1"))
+	assert.Equal(t, mmdbtype.String("feed"), get(merged, "2.2.2.2"))
+	_, source, ok := merged.Source(net.ParseIP("1.1.1.1").To4())
+	require.True(t, ok)
+	assert.Equal(t, "overrides", source)
+	require.NoError(t, merged.Check())
+
+	// Changing the precedence does not require inserting the data again.
+	require.NoError(t, lt.SetPrecedence("overrides", "feed"))
+	merged, err = lt.Merge()
+	require.NoError(t, err)
+	assert.Equal(t, mmdbtype.String("feed"), get(merged, "1.1.1.1"))
+	network, _ := merged.Get(net.ParseIP("1.1.1.1").To4())
+	assert.Equal(t, "1.1.0.0/16", network.String())
+
+	// The layers are not modified by the merge.
+	assert.Equal(t, mmdbtype.String("overrides"), get(overrides, "1.1.1.1"))
+
+	buf := &bytes.Buffer{}
+	_, err = lt.WriteTo(buf)
+	require.NoError(t, err)
+	expected := &bytes.Buffer{}
+	_, err = merged.WriteTo(expected)
+	require.NoError(t, err)
+	assert.Equal(t, expected.Bytes(), buf.Bytes())
+
+	require.NoError(t, lt.RemoveLayer("feed"))
+	assert.Equal(t, []string{"overrides"}, lt.Precedence())
+	merged, err = lt.Merge()
+	require.NoError(t, err)
+	assert.Nil(t, get(merged, "2.2.2.2"))
+}
+
+func TestLayeredTreeDeepMerge(t *testing.T) {
+	lt, err := NewLayeredTree(Options{IPVersion: 4, Inserter: inserter.DeepMergeWith})
+	require.NoError(t, err)
+
+	base, err := lt.Layer("base")
+	require.NoError(t, err)
+	require.NoError(t, base.Insert(mustNetwork(t, "1.1.1.0/24"), mmdbtype.Map{
+		"country": mmdbtype.String("US"),
+		"isp":     mmdbtype.String("a"),
+	}))
+	corrections, err := lt.Layer("corrections")
+	require.NoError(t, err)
+	require.NoError(t, corrections.Insert(mustNetwork(t, "1.1.1.0/25"), mmdbtype.Map{
+		"isp": mmdbtype.String("b"),
+	}))
+
+	merged, err := lt.Merge()
+	require.NoError(t, err)
+	_, value := merged.Get(net.ParseIP("1.1.1.1").To4())
+	assert.Equal(t, mmdbtype.Map{"country": mmdbtype.String("US"), "isp": mmdbtype.String("b")}, value)
+	_, value = merged.Get(net.ParseIP("1.1.1.200").To4())
+	assert.Equal(t, mmdbtype.Map{"country": mmdbtype.String("US"), "isp": mmdbtype.String("a")}, value)
+}
+
+func TestLayeredTreeErrors(t *testing.T) {
+	_, err := NewLayeredTree(Options{IPVersion: 5})
+	assert.EqualError(t, err, "unsupported IPVersion: 5")
+
+	lt, err := NewLayeredTree(Options{})
+	require.NoError(t, err)
+	_, err = lt.Layer("a")
+	require.NoError(t, err)
+	_, err = lt.Layer("b")
+	require.NoError(t, err)
+
+	assert.EqualError(t, lt.SetPrecedence("a"), "the precedence must list all 2 layers but it lists 1")
+	assert.EqualError(t, lt.SetPrecedence("a", "c"), `unknown layer: "c"`)
+	assert.EqualError(t, lt.SetPrecedence("a", "a"), `layer "a" is listed more than once`)
+	assert.EqualError(t, lt.RemoveLayer("c"), `unknown layer: "c"`)
+	assert.Equal(t, []string{"a", "b"}, lt.Precedence())
+}
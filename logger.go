@@ -0,0 +1,22 @@
+package mmdbwriter
+
+// Logger receives messages about decisions made by a Tree that do not
+// cause an error, e.g., skipping a duplicate insert, and about the progress
+// of writes. See Options.Logger.
+//
+// The arguments after the message are alternating keys and values, as with
+// log/slog, so a *slog.Logger may be used directly.
+type Logger interface {
+	// Debug is called for detailed progress, e.g., the time taken to write
+	// each section of the database.
+	Debug(msg string, args ...any)
+
+	// Info is called for progress, e.g., when the tree has been finalized
+	// or written.
+	Info(msg string, args ...any)
+
+	// Warn is called when the tree changes or rejects data in a way the
+	// caller may not expect, e.g., when a network is truncated to the
+	// maximum prefix length.
+	Warn(msg string, args ...any)
+}
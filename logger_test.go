@@ -0,0 +1,111 @@
+package mmdbwriter
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type logEntry struct {
+	level string
+	msg   string
+	args  []any
+}
+
+type recordingLogger struct {
+	entries []logEntry
+}
+
+func (l *recordingLogger) Debug(msg string, args ...any) {
+	l.entries = append(l.entries, logEntry{level: "debug", msg: msg, args: args})
+}
+
+func (l *recordingLogger) Info(msg string, args ...any) {
+	l.entries = append(l.entries, logEntry{level: "info", msg: msg, args: args})
+}
+
+func (l *recordingLogger) Warn(msg string, args ...any) {
+	l.entries = append(l.entries, logEntry{level: "warn", msg: msg, args: args})
+}
+
+func (l *recordingLogger) messages(level string) []string {
+	var msgs []string
+	for _, e := range l.entries {
+		if e.level == level {
+			msgs = append(msgs, e.msg)
+		}
+	}
+	return msgs
+}
+
+func TestLoggerWarnings(t *testing.T) {
+	l := &recordingLogger{}
+	tree, err := New(Options{
+		Logger:              l,
+		DuplicatePolicy:     DuplicateKeep,
+		MaxIPv4PrefixLength: 24,
+		PrefixLengthPolicy:  PrefixLengthTruncate,
+		MaxRecordSize:       16,
+	})
+	require.NoError(t, err)
+
+	insert(t, tree, "1.1.1.0/24", "a")
+	insert(t, tree, "1.1.1.0/24", "b")
+	insert(t, tree, "2.2.2.128/25", "c")
+	err = tree.Insert(
+		mustNetwork(t, "3.3.3.0/24"),
+		mmdbtype.String("a string that is longer than the maximum"),
+	)
+	require.ErrorIs(t, err, ErrLimitExceeded)
+
+	require.Len(t, l.entries, 3)
+	assert.Equal(
+		t,
+		logEntry{
+			level: "warn",
+			msg:   "duplicate insert",
+			args:  []any{"network", "1.1.1.0/24", "policy", "DuplicateKeep"},
+		},
+		l.entries[0],
+	)
+	assert.Equal(
+		t,
+		logEntry{
+			level: "warn",
+			msg:   "truncating network to the maximum prefix length",
+			args:  []any{"network", "2.2.2.128/25", "max_prefix_length", 24},
+		},
+		l.entries[1],
+	)
+	assert.Equal(
+		t,
+		logEntry{
+			level: "warn",
+			msg:   "record exceeds MaxRecordSize",
+			args:  []any{"network", "3.3.3.0/24", "size", 42, "max_size", 16},
+		},
+		l.entries[2],
+	)
+}
+
+func TestLoggerWriteProgress(t *testing.T) {
+	l := &recordingLogger{}
+	tree, err := New(Options{Logger: l})
+	require.NoError(t, err)
+
+	insert(t, tree, "1.1.1.0/24", "a")
+
+	buf := &bytes.Buffer{}
+	n, err := tree.WriteTo(buf)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"finalized tree", "wrote database"}, l.messages("info"))
+	assert.Equal(t, []string{"wrote search tree", "wrote data section"}, l.messages("debug"))
+	assert.Empty(t, l.messages("warn"))
+
+	last := l.entries[len(l.entries)-1]
+	assert.Equal(t, []any{"bytes", n}, last.args[:2])
+}
@@ -0,0 +1,314 @@
+package mmdbwriter
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"net"
+	"reflect"
+	"sync"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+// Lookup looks up the IP address in the tree and decodes its data into
+// result, which must be a non-nil pointer. The data is decoded using the
+// same rules and `maxminddb` struct tags as
+// github.com/oschwald/maxminddb-golang, allowing the data to be checked
+// without writing the database. If the tree has no data for the IP address,
+// result is left unchanged.
+//
+// Data is decoded as it is stored in the tree. Transformations that are
+// applied when the database is written, such as Options.EnumFields, are
+// not applied.
+func (t *Tree) Lookup(ip net.IP, result any) error {
+	rv := reflect.ValueOf(result)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.New("result param must be a pointer")
+	}
+	if t.ipv6Only && ip.To4() != nil {
+		return fmt.Errorf("cannot look up %s: %w", ip, ErrIPv4InIPv6OnlyTree)
+	}
+
+	_, value := t.Get(ip)
+	if value == nil {
+		return nil
+	}
+	return unmarshal(value, rv)
+}
+
+var bigIntType = reflect.TypeOf(big.Int{})
+
+// unmarshal decodes v into result, allocating pointers as needed.
+func unmarshal(v mmdbtype.DataType, result reflect.Value) error {
+	for result.Kind() == reflect.Ptr {
+		if result.IsNil() {
+			result.Set(reflect.New(result.Type().Elem()))
+		}
+		result = result.Elem()
+	}
+
+	switch v := v.(type) {
+	case mmdbtype.Map:
+		return unmarshalMap(v, result)
+	case mmdbtype.Templated:
+		return unmarshalMap(v.Map(), result)
+	case mmdbtype.Slice:
+		return unmarshalSlice(v, result)
+	case mmdbtype.Bool:
+		switch result.Kind() {
+		case reflect.Bool:
+			result.SetBool(bool(v))
+			return nil
+		case reflect.Interface:
+			return setInterface(result, bool(v), v)
+		default:
+		}
+	case mmdbtype.Bytes:
+		switch result.Kind() {
+		case reflect.Slice:
+			if result.Type().Elem().Kind() == reflect.Uint8 {
+				result.SetBytes(append([]byte(nil), v...))
+				return nil
+			}
+		case reflect.Interface:
+			return setInterface(result, append([]byte(nil), v...), v)
+		default:
+		}
+	case mmdbtype.String:
+		switch result.Kind() {
+		case reflect.String:
+			result.SetString(string(v))
+			return nil
+		case reflect.Interface:
+			return setInterface(result, string(v), v)
+		default:
+		}
+	case mmdbtype.Float32:
+		return unmarshalFloat(float64(v), float32(v), v, result)
+	case mmdbtype.Float64:
+		return unmarshalFloat(float64(v), float64(v), v, result)
+	case mmdbtype.Int32:
+		return unmarshalInt(int64(v), v, result)
+	case mmdbtype.Uint16:
+		return unmarshalUint(uint64(v), v, result)
+	case mmdbtype.Uint32:
+		return unmarshalUint(uint64(v), v, result)
+	case mmdbtype.Uint64:
+		return unmarshalUint(uint64(v), v, result)
+	case *mmdbtype.Uint128:
+		return unmarshalUint128((*big.Int)(v), v, result)
+	case mmdbtype.FixedUint128:
+		return unmarshalUint128(v.BigInt(), v, result)
+	default:
+		return fmt.Errorf("unsupported data type: %T", v)
+	}
+	return newUnmarshalTypeError(v, result.Type())
+}
+
+func setInterface(result reflect.Value, v any, dt mmdbtype.DataType) error {
+	rv := reflect.ValueOf(v)
+	if !rv.Type().AssignableTo(result.Type()) {
+		return newUnmarshalTypeError(dt, result.Type())
+	}
+	result.Set(rv)
+	return nil
+}
+
+func unmarshalFloat(f float64, native any, v mmdbtype.DataType, result reflect.Value) error {
+	switch result.Kind() {
+	case reflect.Float32, reflect.Float64:
+		if result.OverflowFloat(f) {
+			return newUnmarshalTypeError(v, result.Type())
+		}
+		result.SetFloat(f)
+		return nil
+	case reflect.Interface:
+		return setInterface(result, native, v)
+	default:
+		return newUnmarshalTypeError(v, result.Type())
+	}
+}
+
+func unmarshalInt(n int64, v mmdbtype.DataType, result reflect.Value) error {
+	switch result.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if !result.OverflowInt(n) {
+			result.SetInt(n)
+			return nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if n >= 0 && !result.OverflowUint(uint64(n)) {
+			result.SetUint(uint64(n))
+			return nil
+		}
+	case reflect.Interface:
+		return setInterface(result, int(n), v)
+	default:
+	}
+	return newUnmarshalTypeError(v, result.Type())
+}
+
+func unmarshalUint(n uint64, v mmdbtype.DataType, result reflect.Value) error {
+	switch result.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n <= math.MaxInt64 && !result.OverflowInt(int64(n)) {
+			result.SetInt(int64(n))
+			return nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if !result.OverflowUint(n) {
+			result.SetUint(n)
+			return nil
+		}
+	case reflect.Interface:
+		return setInterface(result, n, v)
+	default:
+	}
+	return newUnmarshalTypeError(v, result.Type())
+}
+
+func unmarshalUint128(n *big.Int, v mmdbtype.DataType, result reflect.Value) error {
+	switch result.Kind() {
+	case reflect.Struct:
+		if result.Type() == bigIntType {
+			result.Set(reflect.ValueOf(*new(big.Int).Set(n)))
+			return nil
+		}
+	case reflect.Interface:
+		return setInterface(result, new(big.Int).Set(n), v)
+	default:
+	}
+	return newUnmarshalTypeError(v, result.Type())
+}
+
+func unmarshalMap(m mmdbtype.Map, result reflect.Value) error {
+	switch result.Kind() {
+	case reflect.Struct:
+		fields := cachedFields(result.Type())
+		for k, e := range m {
+			index, ok := fields[string(k)]
+			if !ok {
+				continue
+			}
+			if err := unmarshal(e, result.FieldByIndex(index)); err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Map:
+		rt := result.Type()
+		if rt.Key().Kind() != reflect.String {
+			return newUnmarshalTypeError(m, rt)
+		}
+		if result.IsNil() {
+			result.Set(reflect.MakeMapWithSize(rt, len(m)))
+		}
+		for k, e := range m {
+			ev := reflect.New(rt.Elem()).Elem()
+			if err := unmarshal(e, ev); err != nil {
+				return err
+			}
+			result.SetMapIndex(reflect.ValueOf(string(k)).Convert(rt.Key()), ev)
+		}
+		return nil
+	case reflect.Interface:
+		if result.NumMethod() != 0 {
+			return newUnmarshalTypeError(m, result.Type())
+		}
+		rv := reflect.New(reflect.TypeOf(map[string]any{})).Elem()
+		if err := unmarshalMap(m, rv); err != nil {
+			return err
+		}
+		result.Set(rv)
+		return nil
+	default:
+		return newUnmarshalTypeError(m, result.Type())
+	}
+}
+
+func unmarshalSlice(s mmdbtype.Slice, result reflect.Value) error {
+	switch result.Kind() {
+	case reflect.Slice:
+		rv := reflect.MakeSlice(result.Type(), len(s), len(s))
+		for i, e := range s {
+			if err := unmarshal(e, rv.Index(i)); err != nil {
+				return err
+			}
+		}
+		result.Set(rv)
+		return nil
+	case reflect.Interface:
+		if result.NumMethod() != 0 {
+			return newUnmarshalTypeError(s, result.Type())
+		}
+		rv := reflect.New(reflect.TypeOf([]any{})).Elem()
+		if err := unmarshalSlice(s, rv); err != nil {
+			return err
+		}
+		result.Set(rv)
+		return nil
+	default:
+		return newUnmarshalTypeError(s, result.Type())
+	}
+}
+
+var fieldsCache sync.Map
+
+// cachedFields returns the index of each field of the struct type keyed by
+// its name in the data. Embedded structs are flattened and the fields of
+// the outer struct take precedence.
+func cachedFields(t reflect.Type) map[string][]int {
+	if fields, ok := fieldsCache.Load(t); ok {
+		return fields.(map[string][]int) //nolint:forcetypeassert // only we store here
+	}
+
+	fields := map[string][]int{}
+	var embedded []reflect.StructField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct && f.Tag.Get("maxminddb") == "" {
+			embedded = append(embedded, f)
+			continue
+		}
+		if f.PkgPath != "" {
+			// Unexported field.
+			continue
+		}
+		name := f.Tag.Get("maxminddb")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = f.Index
+	}
+	for _, f := range embedded {
+		for name, index := range cachedFields(f.Type) {
+			if _, ok := fields[name]; ok {
+				continue
+			}
+			fields[name] = append(append([]int(nil), f.Index...), index...)
+		}
+	}
+
+	fieldsCache.Store(t, fields)
+	return fields
+}
+
+// UnmarshalTypeError is returned by Tree.Lookup when a value cannot be
+// decoded into the Go type.
+type UnmarshalTypeError struct {
+	Value mmdbtype.DataType
+	Type  reflect.Type
+}
+
+func newUnmarshalTypeError(v mmdbtype.DataType, t reflect.Type) *UnmarshalTypeError {
+	return &UnmarshalTypeError{Value: v, Type: t}
+}
+
+func (e *UnmarshalTypeError) Error() string {
+	return fmt.Sprintf("cannot unmarshal %T into type %s", e.Value, e.Type)
+}
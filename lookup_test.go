@@ -0,0 +1,145 @@
+package mmdbwriter
+
+import (
+	"bytes"
+	"math/big"
+	"net"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/oschwald/maxminddb-golang"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type lookupTestNames struct {
+	Names map[string]string `maxminddb:"names"`
+}
+
+type lookupTestRecord struct {
+	lookupTestNames
+	City struct {
+		GeoNameID uint `maxminddb:"geoname_id"`
+		lookupTestNames
+	} `maxminddb:"city"`
+	Location *struct {
+		Latitude  float64 `maxminddb:"latitude"`
+		Longitude float32 `maxminddb:"longitude"`
+	} `maxminddb:"location"`
+	Subdivisions []struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"subdivisions"`
+	Int       int32   `maxminddb:"int"`
+	Uint64    uint64  `maxminddb:"uint64"`
+	Uint128   big.Int `maxminddb:"uint128"`
+	Bytes     []byte  `maxminddb:"bytes"`
+	IsAnycast bool    `maxminddb:"is_anycast"`
+	Any       any     `maxminddb:"any"`
+	Skipped   string  `maxminddb:"-"`
+	Untagged  string
+}
+
+func TestLookup(t *testing.T) {
+	uint128 := mmdbtype.Uint128(*big.NewInt(0).Lsh(big.NewInt(1), 100))
+	value := mmdbtype.Map{
+		"names": mmdbtype.Map{"en": mmdbtype.String("Top")},
+		"city": mmdbtype.Map{
+			"geoname_id": mmdbtype.Uint32(5375480),
+			"names":      mmdbtype.Map{"en": mmdbtype.String("Mountain View")},
+		},
+		"location": mmdbtype.Map{
+			"latitude":  mmdbtype.Float64(37.386),
+			"longitude": mmdbtype.Float32(-122.0838),
+		},
+		"subdivisions": mmdbtype.Slice{
+			mmdbtype.Map{"iso_code": mmdbtype.String("CA")},
+		},
+		"int":        mmdbtype.Int32(-5),
+		"uint64":     mmdbtype.Uint64(1 << 40),
+		"uint128":    &uint128,
+		"bytes":      mmdbtype.Bytes{1, 2, 3},
+		"is_anycast": mmdbtype.Bool(true),
+		"any": mmdbtype.Map{
+			"list": mmdbtype.Slice{mmdbtype.Uint16(1), mmdbtype.String("a")},
+			"int":  mmdbtype.Int32(7),
+		},
+		"-":        mmdbtype.String("skipped"),
+		"Untagged": mmdbtype.String("untagged"),
+	}
+
+	tree, err := New(Options{})
+	require.NoError(t, err)
+
+	_, network, err := net.ParseCIDR("1.1.1.0/24")
+	require.NoError(t, err)
+	require.NoError(t, tree.Insert(network, value))
+
+	buf := &bytes.Buffer{}
+	_, err = tree.WriteTo(buf)
+	require.NoError(t, err)
+	reader, err := maxminddb.FromBytes(buf.Bytes())
+	require.NoError(t, err)
+
+	ip := net.ParseIP("1.1.1.1")
+
+	var expected lookupTestRecord
+	require.NoError(t, reader.Lookup(ip, &expected))
+
+	var actual lookupTestRecord
+	require.NoError(t, tree.Lookup(ip, &actual))
+
+	assert.Equal(t, expected, actual)
+	assert.Equal(t, "Mountain View", actual.City.Names["en"])
+	assert.Equal(t, "Top", actual.Names["en"])
+	assert.Empty(t, actual.Skipped)
+
+	var expectedAny, actualAny any
+	require.NoError(t, reader.Lookup(ip, &expectedAny))
+	require.NoError(t, tree.Lookup(ip, &actualAny))
+	assert.Equal(t, expectedAny, actualAny)
+
+	var notFound lookupTestRecord
+	require.NoError(t, tree.Lookup(net.ParseIP("2.2.2.2"), &notFound))
+	assert.Equal(t, lookupTestRecord{}, notFound)
+}
+
+func TestLookupErrors(t *testing.T) {
+	tree, err := New(Options{})
+	require.NoError(t, err)
+
+	_, network, err := net.ParseCIDR("1.1.1.0/24")
+	require.NoError(t, err)
+	require.NoError(t, tree.Insert(network, mmdbtype.Map{
+		"string": mmdbtype.String("a"),
+		"big":    mmdbtype.Uint32(1000),
+	}))
+
+	ip := net.ParseIP("1.1.1.1")
+
+	var s string
+	assert.EqualError(t, tree.Lookup(ip, s), "result param must be a pointer")
+
+	assert.EqualError(
+		t,
+		tree.Lookup(ip, &s),
+		"cannot unmarshal mmdbtype.Map into type string",
+	)
+
+	var wrongType struct {
+		String int `maxminddb:"string"`
+	}
+	assert.EqualError(
+		t,
+		tree.Lookup(ip, &wrongType),
+		"cannot unmarshal mmdbtype.String into type int",
+	)
+
+	var overflow struct {
+		Big uint8 `maxminddb:"big"`
+	}
+	assert.EqualError(
+		t,
+		tree.Lookup(ip, &overflow),
+		"cannot unmarshal mmdbtype.Uint32 into type uint8",
+	)
+}
@@ -0,0 +1,267 @@
+// Package lookupbench compares lookups in an in-memory mmdbwriter.Tree with
+// lookups in the database written from it. It reports any IP addresses for
+// which the two disagree, along with the time taken by each, so that it can
+// be used as a release gate for correctness and performance regressions.
+//
+// The database is read with github.com/oschwald/maxminddb-golang. Data that
+// is transformed when the database is written, e.g., by
+// mmdbwriter.Options.EnumFields, is reported as divergent.
+package lookupbench
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// dataSectionSeparatorSize is the size of the separator between the search
+// tree and the data section.
+const dataSectionSeparatorSize = 16
+
+// Options configures Run.
+type Options struct {
+	// Rounds is the number of times the corpus is looked up in each of the
+	// tree and the database when measuring performance. It defaults to 1.
+	Rounds int
+
+	// MaxDivergences is the maximum number of divergences included in the
+	// Result. All divergences are counted. It defaults to 100.
+	MaxDivergences int
+}
+
+// Divergence is an IP address for which the tree and the database disagree.
+type Divergence struct {
+	IP net.IP
+
+	TreeNetwork *net.IPNet
+	TreeValue   mmdbtype.DataType
+
+	DBNetwork *net.IPNet
+	DBValue   mmdbtype.DataType
+}
+
+func (d Divergence) String() string {
+	return fmt.Sprintf(
+		"%s: tree has %s %v, database has %s %v",
+		d.IP,
+		d.TreeNetwork,
+		d.TreeValue,
+		d.DBNetwork,
+		d.DBValue,
+	)
+}
+
+// Result is the result of Run.
+type Result struct {
+	// Lookups is the number of IP addresses compared.
+	Lookups int
+
+	// Skipped is the number of IP addresses that were not compared because
+	// they are IPv6 addresses and the database is an IPv4 database.
+	Skipped int
+
+	// DivergenceCount is the number of IP addresses for which the tree and
+	// the database disagree.
+	DivergenceCount int
+
+	// Divergences are the first Options.MaxDivergences divergences found.
+	Divergences []Divergence
+
+	// TreeDuration is the total time taken by Tree.Get for all rounds.
+	TreeDuration time.Duration
+
+	// DBDuration is the total time taken by the database lookups, which
+	// decode the data into an any value, for all rounds.
+	DBDuration time.Duration
+
+	rounds int
+}
+
+// TreeLookupTime returns the mean time taken by a lookup in the tree.
+func (r *Result) TreeLookupTime() time.Duration {
+	return r.perLookup(r.TreeDuration)
+}
+
+// DBLookupTime returns the mean time taken by a lookup in the database.
+func (r *Result) DBLookupTime() time.Duration {
+	return r.perLookup(r.DBDuration)
+}
+
+func (r *Result) perLookup(d time.Duration) time.Duration {
+	if r.Lookups == 0 {
+		return 0
+	}
+	return d / time.Duration(r.Lookups*r.rounds)
+}
+
+// Relative returns the time taken by the database lookups relative to the
+// tree lookups, e.g., 2 if the database lookups took twice as long.
+func (r *Result) Relative() float64 {
+	if r.TreeDuration == 0 {
+		return 0
+	}
+	return float64(r.DBDuration) / float64(r.TreeDuration)
+}
+
+// Run looks up each IP address in the corpus in the tree and in db, which
+// must be the database written from the tree, e.g., with Tree.WriteTo.
+func Run(tree *mmdbwriter.Tree, db []byte, corpus []net.IP, opts Options) (*Result, error) {
+	if opts.Rounds == 0 {
+		opts.Rounds = 1
+	}
+	if opts.Rounds < 0 {
+		return nil, fmt.Errorf("invalid Rounds: %d", opts.Rounds)
+	}
+	if opts.MaxDivergences == 0 {
+		opts.MaxDivergences = 100
+	}
+
+	reader, err := maxminddb.FromBytes(db)
+	if err != nil {
+		return nil, fmt.Errorf("opening database: %w", err)
+	}
+	dataSection, err := dataSection(db, reader.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{rounds: opts.Rounds}
+	ips := make([]net.IP, 0, len(corpus))
+	for _, ip := range corpus {
+		if ipv4 := ip.To4(); ipv4 != nil {
+			ip = ipv4
+		} else if reader.Metadata.IPVersion == 4 {
+			result.Skipped++
+			continue
+		}
+		ips = append(ips, ip)
+	}
+	result.Lookups = len(ips)
+
+	for _, ip := range ips {
+		d, err := compare(tree, reader, dataSection, ip)
+		if err != nil {
+			return nil, err
+		}
+		if d == nil {
+			continue
+		}
+		result.DivergenceCount++
+		if len(result.Divergences) < opts.MaxDivergences {
+			result.Divergences = append(result.Divergences, *d)
+		}
+	}
+
+	start := time.Now()
+	for i := 0; i < opts.Rounds; i++ {
+		for _, ip := range ips {
+			tree.Get(ip)
+		}
+	}
+	result.TreeDuration = time.Since(start)
+
+	start = time.Now()
+	for i := 0; i < opts.Rounds; i++ {
+		for _, ip := range ips {
+			var v any
+			if err := reader.Lookup(ip, &v); err != nil {
+				return nil, fmt.Errorf("looking up %s in the database: %w", ip, err)
+			}
+		}
+	}
+	result.DBDuration = time.Since(start)
+
+	return result, nil
+}
+
+// dataSection returns the data section of the database, which starts
+// after the search tree and runs to the end of the database.
+func dataSection(db []byte, metadata maxminddb.Metadata) ([]byte, error) {
+	start := int(metadata.NodeCount*metadata.RecordSize/4) + dataSectionSeparatorSize
+	if start > len(db) {
+		return nil, fmt.Errorf(
+			"the search tree size (%d) exceeds the database size (%d)",
+			start,
+			len(db),
+		)
+	}
+	return db[start:], nil
+}
+
+// compare compares the lookup of ip in the tree and in the database. It
+// returns nil if they agree.
+func compare(
+	tree *mmdbwriter.Tree,
+	reader *maxminddb.Reader,
+	dataSection []byte,
+	ip net.IP,
+) (*Divergence, error) {
+	treeNetwork, treeValue := tree.Get(ip)
+
+	var ignored any
+	dbNetwork, ok, err := reader.LookupNetwork(ip, &ignored)
+	if err != nil {
+		return nil, fmt.Errorf("looking up %s in the database: %w", ip, err)
+	}
+	var dbValue mmdbtype.DataType
+	if ok {
+		offset, err := reader.LookupOffset(ip)
+		if err != nil {
+			return nil, fmt.Errorf("looking up %s in the database: %w", ip, err)
+		}
+		dbValue, _, err = mmdbtype.DecodeAt(dataSection, int(offset))
+		if err != nil {
+			return nil, fmt.Errorf("decoding the data for %s: %w", ip, err)
+		}
+	}
+
+	if treeNetwork.String() == dbNetwork.String() && equal(treeValue, dbValue) {
+		return nil, nil
+	}
+	return &Divergence{
+		IP:          ip,
+		TreeNetwork: treeNetwork,
+		TreeValue:   treeValue,
+		DBNetwork:   dbNetwork,
+		DBValue:     dbValue,
+	}, nil
+}
+
+func equal(a, b mmdbtype.DataType) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Equal(b)
+}
+
+// ReadCorpus reads IP addresses from r, one per line. Blank lines and lines
+// starting with # are ignored.
+func ReadCorpus(r io.Reader) ([]net.IP, error) {
+	var ips []net.IP
+	scanner := bufio.NewScanner(r)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := string(bytes.TrimSpace(scanner.Bytes()))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		ip := net.ParseIP(line)
+		if ip == nil {
+			return nil, fmt.Errorf("line %d: invalid IP address %q", lineNum, line)
+		}
+		ips = append(ips, ip)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading corpus: %w", err)
+	}
+	return ips, nil
+}
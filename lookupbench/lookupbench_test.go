@@ -0,0 +1,79 @@
+package lookupbench
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	for _, ipVersion := range []int{4, 6} {
+		tree, err := mmdbwriter.New(mmdbwriter.Options{
+			IPVersion:    ipVersion,
+			DatabaseType: "Test",
+		})
+		require.NoError(t, err)
+
+		for network, value := range map[string]mmdbtype.DataType{
+			"1.1.1.0/24": mmdbtype.Map{"name": mmdbtype.String("one")},
+			"2.2.0.0/16": mmdbtype.Uint32(2),
+		} {
+			_, n, err := net.ParseCIDR(network)
+			require.NoError(t, err)
+			require.NoError(t, tree.Insert(n, value))
+		}
+
+		buf := &bytes.Buffer{}
+		_, err = tree.WriteTo(buf)
+		require.NoError(t, err)
+
+		corpus, err := ReadCorpus(strings.NewReader(`# test corpus
+1.1.1.1
+2.2.3.4
+
+3.3.3.3
+2600::1
+`))
+		require.NoError(t, err)
+
+		result, err := Run(tree, buf.Bytes(), corpus, Options{Rounds: 3})
+		require.NoError(t, err)
+		assert.Equal(t, 0, result.DivergenceCount, "%v", result.Divergences)
+		if ipVersion == 4 {
+			assert.Equal(t, 3, result.Lookups)
+			assert.Equal(t, 1, result.Skipped)
+		} else {
+			assert.Equal(t, 4, result.Lookups)
+			assert.Equal(t, 0, result.Skipped)
+		}
+		assert.Positive(t, result.TreeLookupTime())
+		assert.Positive(t, result.DBLookupTime())
+		assert.Positive(t, result.Relative())
+
+		// Modifying the tree after writing it causes a divergence.
+		_, n, err := net.ParseCIDR("1.1.1.0/25")
+		require.NoError(t, err)
+		require.NoError(t, tree.Insert(n, mmdbtype.String("changed")))
+
+		result, err = Run(tree, buf.Bytes(), corpus, Options{})
+		require.NoError(t, err)
+		require.Equal(t, 1, result.DivergenceCount)
+		d := result.Divergences[0]
+		assert.Equal(t, "1.1.1.1", d.IP.String())
+		assert.Equal(t, "1.1.1.0/25", d.TreeNetwork.String())
+		assert.Equal(t, mmdbtype.String("changed"), d.TreeValue)
+		assert.Equal(t, "1.1.1.0/24", d.DBNetwork.String())
+		assert.Equal(t, mmdbtype.Map{"name": mmdbtype.String("one")}, d.DBValue)
+	}
+}
+
+func TestReadCorpusInvalid(t *testing.T) {
+	_, err := ReadCorpus(strings.NewReader("1.1.1.1\nnot-an-ip\n"))
+	require.EqualError(t, err, `line 2: invalid IP address "not-an-ip"`)
+}
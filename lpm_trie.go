@@ -0,0 +1,109 @@
+package mmdbwriter
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+// LPMTrieOptions are the options for ExportLPMTrie.
+type LPMTrieOptions struct {
+	// ValueEncoder encodes a record's value as the value of the map entry.
+	// The encoded values must all have the same length, the map's
+	// value_size. If it returns a nil slice, the network is skipped. This
+	// is required.
+	ValueEncoder func(value mmdbtype.DataType) ([]byte, error)
+
+	// IPVersion is the IP version of the map's keys. The keys are 8 bytes
+	// for IPv4 and 20 bytes for IPv6. For an IPv6 tree, 4 exports the IPv4
+	// subtree, i.e., the networks within ::/96 or where
+	// Options.IPv4Placement put them, as IPv4 networks, and 6
+	// exports the whole tree other than the aliased networks. For an IPv4
+	// tree, only 4 is supported. The default is the tree's IP version.
+	IPVersion int
+
+	// ByteOrder is the byte order of the prefix length in the keys. This
+	// must be the byte order of the host loading the map. The default is
+	// binary.LittleEndian.
+	ByteOrder binary.ByteOrder
+}
+
+// ExportLPMTrie calls fn with the key and value for each network with data
+// in the tree, using the layout of a BPF_MAP_TYPE_LPM_TRIE map. This allows
+// programs such as XDP firewalls to use the same data as the database. The
+// key is a struct bpf_lpm_trie_key: the prefix length as a uint32 in
+// LPMTrieOptions.ByteOrder followed by the network address. The networks
+// are passed in order. As the networks in the tree do not overlap, a
+// longest prefix match in the map finds the same data as a lookup in the
+// tree.
+//
+// The key and value slices are only valid until fn returns. If fn returns
+// an error, ExportLPMTrie stops and returns it.
+func (t *Tree) ExportLPMTrie(opts LPMTrieOptions, fn func(key, value []byte) error) error {
+	if opts.ValueEncoder == nil {
+		return errors.New("ValueEncoder is required")
+	}
+	ipVersion := opts.IPVersion
+	if ipVersion == 0 {
+		ipVersion = t.ipVersion
+	}
+	if ipVersion != 4 && ipVersion != 6 {
+		return fmt.Errorf("unsupported IPVersion: %d", ipVersion)
+	}
+	if ipVersion == 6 && t.ipVersion == 4 {
+		return errors.New("IPVersion 6 is not supported for an IPv4 tree")
+	}
+	byteOrder := opts.ByteOrder
+	if byteOrder == nil {
+		byteOrder = binary.LittleEndian
+	}
+
+	// The network of the exported networks in the tree's addresses and
+	// the offset of the exported networks in them.
+	start := make(net.IP, t.treeDepth/8)
+	offset := 0
+	if ipVersion == 4 && t.treeDepth == 128 {
+		start = t.ipv4ToTree(make(net.IP, net.IPv4len))
+		offset = 96
+	}
+	addrLen := 4
+	if ipVersion == 6 {
+		addrLen = 16
+	}
+
+	key := make([]byte, 4+addrLen)
+	valueSize := -1
+	return t.walkWithin(
+		start,
+		offset,
+		func(ip net.IP, prefixLen int, r record) error {
+			if r.recordType != recordTypeData {
+				return nil
+			}
+			value, err := opts.ValueEncoder(t.dataMap.load(r.value))
+			if err != nil {
+				return fmt.Errorf("encoding value for %s: %w", t.formatNetwork(ip, prefixLen), err)
+			}
+			if value == nil {
+				return nil
+			}
+			if valueSize == -1 {
+				valueSize = len(value)
+			} else if len(value) != valueSize {
+				return fmt.Errorf(
+					"the encoded value for %s is %d bytes, but the previous values were %d bytes",
+					t.formatNetwork(ip, prefixLen),
+					len(value),
+					valueSize,
+				)
+			}
+
+			byteOrder.PutUint32(key, uint32(prefixLen-offset))
+			copy(key[4:], ip[offset/8:])
+			return fn(key, value)
+		},
+	)
+}
@@ -0,0 +1,124 @@
+package mmdbwriter
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportLPMTrie(t *testing.T) {
+	encoder := func(value mmdbtype.DataType) ([]byte, error) {
+		s := string(value.(mmdbtype.String))
+		if s == "skip" {
+			return nil, nil
+		}
+		return []byte(s), nil
+	}
+
+	tests := []struct {
+		name          string
+		treeIPVersion int
+		opts          LPMTrieOptions
+		expected      []string
+		expectedErr   string
+	}{
+		{
+			name:          "IPv4 tree",
+			treeIPVersion: 4,
+			opts:          LPMTrieOptions{ValueEncoder: encoder},
+			expected: []string{
+				"18000000 01010100 a",
+				"08000000 02000000 b",
+			},
+		},
+		{
+			name:          "IPv4 keys from IPv6 tree",
+			treeIPVersion: 6,
+			opts:          LPMTrieOptions{ValueEncoder: encoder, IPVersion: 4},
+			expected: []string{
+				"18000000 01010100 a",
+				"08000000 02000000 b",
+			},
+		},
+		{
+			name:          "IPv6 keys",
+			treeIPVersion: 6,
+			opts: LPMTrieOptions{
+				ValueEncoder: encoder,
+				IPVersion:    6,
+				ByteOrder:    binary.BigEndian,
+			},
+			expected: []string{
+				"00000078 00000000000000000000000001010100 a",
+				"00000068 00000000000000000000000002000000 b",
+				"00000020 26000000000000000000000000000000 c",
+			},
+		},
+		{
+			name:          "IPv6 keys from IPv4 tree",
+			treeIPVersion: 4,
+			opts:          LPMTrieOptions{ValueEncoder: encoder, IPVersion: 6},
+			expectedErr:   "IPVersion 6 is not supported for an IPv4 tree",
+		},
+		{
+			name:          "missing encoder",
+			treeIPVersion: 4,
+			expectedErr:   "ValueEncoder is required",
+		},
+		{
+			name:          "inconsistent value size",
+			treeIPVersion: 6,
+			opts: LPMTrieOptions{
+				ValueEncoder: func(value mmdbtype.DataType) ([]byte, error) {
+					if value == mmdbtype.String("c") {
+						return []byte("c"), nil
+					}
+					return []byte("ab"), nil
+				},
+				IPVersion: 6,
+			},
+			expectedErr: "the encoded value for 2600::/32 is 1 bytes, but the previous values were 2 bytes",
+		},
+		{
+			name:          "encoder error",
+			treeIPVersion: 4,
+			opts: LPMTrieOptions{
+				ValueEncoder: func(mmdbtype.DataType) ([]byte, error) {
+					return nil, errors.New("bad value")
+				},
+			},
+			expectedErr: "encoding value for 1.1.1.0/24: bad value",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tree, err := New(Options{IPVersion: test.treeIPVersion})
+			require.NoError(t, err)
+
+			insert(t, tree, "1.1.1.0/24", "a")
+			insert(t, tree, "2.0.0.0/8", "b")
+			insert(t, tree, "3.0.0.0/8", "skip")
+			if test.treeIPVersion == 6 {
+				insert(t, tree, "2600::/32", "c")
+			}
+
+			var entries []string
+			err = tree.ExportLPMTrie(test.opts, func(key, value []byte) error {
+				entries = append(entries, fmt.Sprintf("%x %x %s", key[:4], key[4:], value))
+				return nil
+			})
+			if test.expectedErr != "" {
+				assert.EqualError(t, err, test.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, entries)
+		})
+	}
+}
@@ -0,0 +1,19 @@
+package mmdbwriter
+
+import (
+	"github.com/maxmind/mmdbwriter/inserter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+// membershipInserter wraps an inserter function so that any value it
+// returns is replaced by an empty Map. A nil value still removes the data
+// for the network.
+func membershipInserter(f inserter.Func) inserter.Func {
+	return func(existing mmdbtype.DataType) (mmdbtype.DataType, error) {
+		v, err := f(existing)
+		if err != nil || v == nil {
+			return v, err
+		}
+		return mmdbtype.Map{}, nil
+	}
+}
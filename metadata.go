@@ -0,0 +1,76 @@
+package mmdbwriter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+// readMetadata decodes the metadata section of the database in buf. This
+// is used for metadata that maxminddb-golang does not expose, such as
+// non-standard metadata keys.
+func readMetadata(buf []byte) (mmdbtype.Map, error) {
+	start := bytes.LastIndex(buf, metadataStartMarker)
+	if start == -1 {
+		return nil, errors.New("invalid MaxMind DB: metadata start marker not found")
+	}
+	v, _, err := mmdbtype.DecodeAt(buf[start+len(metadataStartMarker):], 0)
+	if err != nil {
+		return nil, fmt.Errorf("decoding metadata: %w", err)
+	}
+	m, ok := v.(mmdbtype.Map)
+	if !ok {
+		return nil, fmt.Errorf("unexpected metadata type: %T", v)
+	}
+	return m, nil
+}
+
+// dataSection returns the data section of the database in buf, whose
+// metadata has been read with readMetadata.
+func dataSection(buf []byte, metadata mmdbtype.Map) ([]byte, error) {
+	nodeCount, ok := metadata["node_count"].(mmdbtype.Uint32)
+	if !ok {
+		return nil, fmt.Errorf("unexpected type %T for node_count", metadata["node_count"])
+	}
+	recordSize, ok := metadata["record_size"].(mmdbtype.Uint16)
+	if !ok {
+		return nil, fmt.Errorf("unexpected type %T for record_size", metadata["record_size"])
+	}
+	dataStart := int(nodeCount)*int(recordSize)/4 + len(dataSectionSeparator)
+	metadataStart := bytes.LastIndex(buf, metadataStartMarker)
+	if dataStart > metadataStart {
+		return nil, errors.New("the data section is outside of the database")
+	}
+	return buf[dataStart:metadataStart], nil
+}
+
+// requiredMetadataKeys are the metadata keys required by the MaxMind DB
+// format.
+var requiredMetadataKeys = []mmdbtype.String{
+	"binary_format_major_version",
+	"binary_format_minor_version",
+	"build_epoch",
+	"database_type",
+	"ip_version",
+	"node_count",
+	"record_size",
+}
+
+// applyMetadataHook calls the hook with a copy of the metadata and checks
+// that the metadata it returns is still valid for the tree.
+func applyMetadataHook(hook func(mmdbtype.Map) mmdbtype.Map, metadata mmdbtype.Map) (mmdbtype.Map, error) {
+	hooked := hook(metadata.Copy().(mmdbtype.Map))
+	for _, key := range requiredMetadataKeys {
+		if v, ok := hooked[key]; !ok || v == nil {
+			return nil, fmt.Errorf("MetadataHook removed the required metadata key %q", key)
+		}
+	}
+	for _, key := range []mmdbtype.String{"ip_version", "node_count", "record_size"} {
+		if !hooked[key].Equal(metadata[key]) {
+			return nil, fmt.Errorf("MetadataHook changed the metadata key %q", key)
+		}
+	}
+	return hooked, nil
+}
@@ -0,0 +1,82 @@
+package mmdbwriter
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/oschwald/maxminddb-golang"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetadataHook(t *testing.T) {
+	tests := []struct {
+		name        string
+		hook        func(mmdbtype.Map) mmdbtype.Map
+		expectedErr string
+	}{
+		{
+			name: "add and remove keys",
+			hook: func(m mmdbtype.Map) mmdbtype.Map {
+				m["git_sha"] = mmdbtype.String("abc123")
+				delete(m, "languages")
+				return m
+			},
+		},
+		{
+			name: "removed required key",
+			hook: func(m mmdbtype.Map) mmdbtype.Map {
+				delete(m, "database_type")
+				return m
+			},
+			expectedErr: `writing metadata: MetadataHook removed the required metadata key "database_type"`,
+		},
+		{
+			name: "changed node count",
+			hook: func(m mmdbtype.Map) mmdbtype.Map {
+				m["node_count"] = mmdbtype.Uint32(1)
+				return m
+			},
+			expectedErr: `writing metadata: MetadataHook changed the metadata key "node_count"`,
+		},
+		{
+			name: "nil map",
+			hook: func(mmdbtype.Map) mmdbtype.Map {
+				return nil
+			},
+			expectedErr: `writing metadata: MetadataHook removed the required metadata key "binary_format_major_version"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tree, err := New(Options{
+				DatabaseType: "mmdbwriter-test",
+				Description:  map[string]string{"en": "Test database"},
+				Languages:    []string{"en"},
+				MetadataHook: test.hook,
+			})
+			require.NoError(t, err)
+			insert(t, tree, "1.1.1.0/24", "a")
+
+			buf := &bytes.Buffer{}
+			_, err = tree.WriteTo(buf)
+			if test.expectedErr != "" {
+				assert.EqualError(t, err, test.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+
+			metadata, err := readMetadata(buf.Bytes())
+			require.NoError(t, err)
+			assert.Equal(t, mmdbtype.String("abc123"), metadata["git_sha"])
+			assert.NotContains(t, metadata, mmdbtype.String("languages"))
+
+			reader, err := maxminddb.FromBytes(buf.Bytes())
+			require.NoError(t, err)
+			require.NoError(t, reader.Verify())
+			assert.Equal(t, "mmdbwriter-test", reader.Metadata.DatabaseType)
+		})
+	}
+}
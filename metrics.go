@@ -0,0 +1,42 @@
+package mmdbwriter
+
+import (
+	"time"
+	"unsafe"
+)
+
+// Metrics receives measurements from a Tree, e.g., to export them to
+// Prometheus or OpenTelemetry. See Options.Metrics.
+type Metrics interface {
+	// ObserveInsert is called after each insert of data with the time it
+	// took, whether or not it succeeded.
+	ObserveInsert(d time.Duration)
+
+	// ObserveFinalize is called after the tree is finalized with the time
+	// it took.
+	ObserveFinalize(d time.Duration)
+
+	// ObserveWrite is called after the tree is successfully written with
+	// the number of bytes written and the time it took.
+	ObserveWrite(bytes int64, d time.Duration)
+
+	// ObserveMemoryHighWater is called when the estimated memory used by
+	// the tree exceeds its previous high-water mark, with the new mark in
+	// bytes. The estimate is the size of the nodes plus the encoded size
+	// of the distinct data values. It does not include the overhead of
+	// the decoded values or of the Go runtime.
+	ObserveMemoryHighWater(bytes int64)
+}
+
+// nodeSize is the size in bytes of a node.
+const nodeSize = int64(unsafe.Sizeof(node{}))
+
+// observeMemory reports the estimated memory used by the tree to the
+// metrics if it exceeds the high-water mark.
+func (t *Tree) observeMemory() {
+	estimate := int64(t.liveNodes)*nodeSize + int64(t.dataMap.size)
+	if estimate > t.memoryHighWater {
+		t.memoryHighWater = estimate
+		t.metrics.ObserveMemoryHighWater(estimate)
+	}
+}
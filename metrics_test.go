@@ -0,0 +1,61 @@
+package mmdbwriter
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingMetrics struct {
+	inserts    []time.Duration
+	finalizes  []time.Duration
+	writes     []int64
+	highWaters []int64
+}
+
+func (m *recordingMetrics) ObserveInsert(d time.Duration) {
+	m.inserts = append(m.inserts, d)
+}
+
+func (m *recordingMetrics) ObserveFinalize(d time.Duration) {
+	m.finalizes = append(m.finalizes, d)
+}
+
+func (m *recordingMetrics) ObserveWrite(bytes int64, _ time.Duration) {
+	m.writes = append(m.writes, bytes)
+}
+
+func (m *recordingMetrics) ObserveMemoryHighWater(bytes int64) {
+	m.highWaters = append(m.highWaters, bytes)
+}
+
+func TestMetrics(t *testing.T) {
+	m := &recordingMetrics{}
+	tree, err := New(Options{Metrics: m})
+	require.NoError(t, err)
+
+	insert(t, tree, "1.1.1.0/24", "a")
+	insert(t, tree, "2.2.2.0/24", "b")
+	// This doesn't increase the memory used.
+	insert(t, tree, "2.2.2.0/24", "b")
+
+	buf := &bytes.Buffer{}
+	n, err := tree.WriteTo(buf)
+	require.NoError(t, err)
+
+	assert.Len(t, m.inserts, 3)
+	assert.Len(t, m.finalizes, 1)
+	assert.Equal(t, []int64{n}, m.writes)
+	assert.EqualValues(t, buf.Len(), n)
+
+	require.Len(t, m.highWaters, 2)
+	assert.Less(t, m.highWaters[0], m.highWaters[1])
+	assert.Equal(
+		t,
+		int64(tree.liveNodes)*nodeSize+int64(tree.dataMap.size),
+		m.highWaters[1],
+	)
+}
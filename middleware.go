@@ -0,0 +1,28 @@
+package mmdbwriter
+
+import (
+	"net"
+
+	"github.com/maxmind/mmdbwriter/inserter"
+)
+
+// InsertMiddleware wraps the inserter function of an insert. It is called
+// once per insert with the network being inserted and the inserter function
+// to wrap, next. The returned function is called for each existing record
+// in the network, like any other inserter function. It may modify the value
+// returned by next, e.g., to normalize keys or add a field, or return an
+// error to reject the insert. A nil value returned by next means that the
+// record is being removed.
+//
+// As with inserter functions, the values passed to and returned by next
+// must not be modified. Copy them first.
+type InsertMiddleware func(network *net.IPNet, next inserter.Func) inserter.Func
+
+// applyMiddleware wraps the inserter function with the tree's middleware.
+// The first middleware is the outermost.
+func (t *Tree) applyMiddleware(network *net.IPNet, f inserter.Func) inserter.Func {
+	for i := len(t.insertMiddleware) - 1; i >= 0; i-- {
+		f = t.insertMiddleware[i](network, f)
+	}
+	return f
+}
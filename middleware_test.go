@@ -0,0 +1,104 @@
+package mmdbwriter
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/inserter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInsertMiddleware(t *testing.T) {
+	lowercaseKeys := func(_ *net.IPNet, next inserter.Func) inserter.Func {
+		return func(existing mmdbtype.DataType) (mmdbtype.DataType, error) {
+			v, err := next(existing)
+			m, ok := v.(mmdbtype.Map)
+			if err != nil || !ok {
+				return v, err
+			}
+			nm := make(mmdbtype.Map, len(m))
+			for k, e := range m {
+				nm[mmdbtype.String(strings.ToLower(string(k)))] = e
+			}
+			return nm, nil
+		}
+	}
+	requireName := func(network *net.IPNet, next inserter.Func) inserter.Func {
+		return func(existing mmdbtype.DataType) (mmdbtype.DataType, error) {
+			v, err := next(existing)
+			if err != nil || v == nil {
+				return v, err
+			}
+			if _, ok := v.(mmdbtype.Map)["name"]; !ok {
+				return nil, fmt.Errorf("the record for %s is missing name", network)
+			}
+			return v, nil
+		}
+	}
+	var networks []string
+	recordNetwork := func(network *net.IPNet, next inserter.Func) inserter.Func {
+		networks = append(networks, network.String())
+		return next
+	}
+
+	tree, err := New(Options{
+		IPVersion: 4,
+		// requireName runs after lowercaseKeys has processed the value.
+		InsertMiddleware: []InsertMiddleware{recordNetwork, requireName, lowercaseKeys},
+	})
+	require.NoError(t, err)
+
+	_, network, err := net.ParseCIDR("1.1.1.0/24")
+	require.NoError(t, err)
+	require.NoError(t, tree.Insert(network, mmdbtype.Map{"NAME": mmdbtype.String("a")}))
+
+	_, value := tree.Get(net.ParseIP("1.1.1.1").To4())
+	assert.Equal(t, mmdbtype.Map{"name": mmdbtype.String("a")}, value)
+
+	err = tree.Insert(network, mmdbtype.Map{"other": mmdbtype.String("a")})
+	assert.EqualError(t, err, "the record for 1.1.1.0/24 is missing name")
+
+	require.NoError(t, tree.InsertFunc(network, inserter.Remove))
+
+	require.NoError(t, tree.InsertRange(
+		net.ParseIP("2.0.0.0"),
+		net.ParseIP("2.0.1.127"),
+		mmdbtype.Map{"Name": mmdbtype.String("b")},
+	))
+
+	assert.Equal(
+		t,
+		[]string{"1.1.1.0/24", "1.1.1.0/24", "1.1.1.0/24", "2.0.0.0/24", "2.0.1.0/25"},
+		networks,
+	)
+
+	extracted, err := tree.Extract([]*net.IPNet{{IP: net.IPv4zero.To4(), Mask: net.CIDRMask(0, 32)}})
+	require.NoError(t, err)
+	assert.Len(t, networks, 5, "middleware is not applied by Extract")
+	_, value = extracted.Get(net.ParseIP("2.0.0.1").To4())
+	assert.Equal(t, mmdbtype.Map{"name": mmdbtype.String("b")}, value)
+}
+
+func TestInsertMiddlewareError(t *testing.T) {
+	errRejected := errors.New("rejected")
+	tree, err := New(Options{
+		InsertMiddleware: []InsertMiddleware{
+			func(_ *net.IPNet, _ inserter.Func) inserter.Func {
+				return func(mmdbtype.DataType) (mmdbtype.DataType, error) {
+					return nil, errRejected
+				}
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	_, network, err := net.ParseCIDR("1.1.1.0/24")
+	require.NoError(t, err)
+	err = tree.Insert(network, mmdbtype.String("a"))
+	assert.ErrorIs(t, err, errRejected)
+}
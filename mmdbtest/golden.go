@@ -0,0 +1,82 @@
+package mmdbtest
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter"
+)
+
+// GoldenBuildEpoch is the build epoch of the databases written by
+// AssertGolden, so that the golden files do not depend on when the tests
+// are run.
+const GoldenBuildEpoch = 1
+
+// UpdateGoldenEnv is the environment variable that, if set to a non-empty
+// value, causes AssertGolden to write the golden files rather than compare
+// with them, e.g., after an intended change to a database.
+const UpdateGoldenEnv = "MMDBWRITER_UPDATE_GOLDEN"
+
+// AssertGolden writes the tree with the build epoch GoldenBuildEpoch and
+// checks that the database is byte for byte the same as the golden file at
+// path, e.g., "testdata/city.mmdb". If it is not, the test fails with the
+// differences found by Compare, one per network, or, if there are none,
+// with a note that only the layout of the database differs. It returns
+// whether the database matched.
+//
+// If UpdateGoldenEnv is set, the golden file is written instead.
+func AssertGolden(t testing.TB, tree *mmdbwriter.Tree, path string) bool {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	_, err := tree.WriteOutputs([]mmdbwriter.Output{{Writer: buf, BuildEpoch: GoldenBuildEpoch}})
+	if err != nil {
+		t.Errorf("writing the tree: %v", err)
+		return false
+	}
+	actual := buf.Bytes()
+
+	if os.Getenv(UpdateGoldenEnv) != "" {
+		//nolint:gosec // golden files are checked in like the other test data
+		if err := os.WriteFile(path, actual, 0o644); err != nil {
+			t.Errorf("writing the golden file: %v", err)
+			return false
+		}
+		return true
+	}
+
+	expected, err := os.ReadFile(path) //nolint:gosec // the path is given by the test
+	if err != nil {
+		t.Errorf("reading the golden file: %v; set %s to create it", err, UpdateGoldenEnv)
+		return false
+	}
+	if bytes.Equal(expected, actual) {
+		return true
+	}
+
+	diffs, err := compareBytes(expected, actual)
+	if err != nil {
+		t.Errorf("the database differs from %s, which could not be compared: %v", path, err)
+		return false
+	}
+	if len(diffs) == 0 {
+		t.Errorf(
+			"the database differs from %s in its layout but not in the records or metadata compared "+
+				"by Compare (%d bytes, expected %d); set %s to update it",
+			path,
+			len(actual),
+			len(expected),
+			UpdateGoldenEnv,
+		)
+		return false
+	}
+	t.Errorf(
+		"the database differs from %s; set %s to update it:\n%s",
+		path,
+		UpdateGoldenEnv,
+		strings.Join(listDifferences(diffs, 50), "\n"),
+	)
+	return false
+}
@@ -0,0 +1,67 @@
+package mmdbtest
+
+import (
+	"fmt"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingT records the errors of a test rather than failing it.
+type recordingT struct {
+	testing.TB
+	errors []string
+}
+
+func (*recordingT) Helper() {}
+
+func (r *recordingT) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestAssertGolden(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "golden.mmdb")
+
+	build := func(value string) *mmdbwriter.Tree {
+		opts := testOptions()
+		opts.BuildEpoch = 0
+		tree, err := mmdbwriter.New(opts)
+		require.NoError(t, err)
+		for _, network := range []string{"1.1.1.0/24", "2.2.2.0/24"} {
+			_, ipNet, err := net.ParseCIDR(network)
+			require.NoError(t, err)
+			require.NoError(t, tree.Insert(ipNet, mmdbtype.String(network)))
+		}
+		_, ipNet, err := net.ParseCIDR("3.3.3.0/24")
+		require.NoError(t, err)
+		require.NoError(t, tree.Insert(ipNet, mmdbtype.String(value)))
+		return tree
+	}
+
+	rt := &recordingT{TB: t}
+	assert.False(t, AssertGolden(rt, build("a"), path))
+	require.Len(t, rt.errors, 1)
+	assert.Contains(t, rt.errors[0], "set MMDBWRITER_UPDATE_GOLDEN to create it")
+
+	t.Setenv(UpdateGoldenEnv, "1")
+	assert.True(t, AssertGolden(t, build("a"), path))
+	t.Setenv(UpdateGoldenEnv, "")
+
+	// The build epoch is fixed, so a tree built at another time matches.
+	assert.True(t, AssertGolden(t, build("a"), path))
+
+	rt = &recordingT{TB: t}
+	assert.False(t, AssertGolden(rt, build("b"), path))
+	require.Len(t, rt.errors, 1)
+	assert.Equal(
+		t,
+		"the database differs from "+path+"; set MMDBWRITER_UPDATE_GOLDEN to update it:\n"+
+			"3.3.3.0/24: expected a, got b",
+		rt.errors[0],
+	)
+}
@@ -0,0 +1,438 @@
+// Package mmdbtest supports testing that the databases written by
+// mmdbwriter are compatible with databases written by other writers, such
+// as the test databases of the MaxMind DB specification at
+// https://github.com/maxmind/MaxMind-DB.
+//
+// ReadSource and BuildFromSource rebuild a database from the source JSON
+// files of those test databases, and Compare compares two databases
+// semantically, i.e., by the records returned for each address rather than
+// byte for byte, as valid writers may lay out the search tree and data
+// section differently.
+package mmdbtest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/netip"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/oschwald/maxminddb-golang"
+	"go4.org/netipx"
+)
+
+// ReadSource reads the records from a source JSON file in the format used
+// by the MaxMind DB test data, i.e., an array of objects that each map a
+// network in CIDR notation to its record. The records are converted with
+// mmdbtype.FromAny, with integers decoded as integers rather than floats.
+// As the JSON does not include the MaxMind DB types, e.g., Uint16 or
+// Uint32, the types of the values may differ from those of the test
+// databases, which Compare ignores.
+func ReadSource(r io.Reader) ([]mmdbwriter.NetworkRecord, error) {
+	dec := json.NewDecoder(r)
+	dec.UseNumber()
+
+	var entries []map[string]any
+	if err := dec.Decode(&entries); err != nil {
+		return nil, fmt.Errorf("decoding source: %w", err)
+	}
+
+	var records []mmdbwriter.NetworkRecord
+	for i, entry := range entries {
+		// The networks within an entry are sorted so that the order of
+		// the records is deterministic.
+		networks := make([]string, 0, len(entry))
+		for network := range entry {
+			networks = append(networks, network)
+		}
+		sort.Strings(networks)
+
+		for _, network := range networks {
+			_, ipNet, err := net.ParseCIDR(network)
+			if err != nil {
+				return nil, fmt.Errorf("entry %d: %w", i, err)
+			}
+			value, err := mmdbtype.FromAny(entry[network])
+			if err != nil {
+				return nil, fmt.Errorf("entry %d: converting record for %s: %w", i, network, err)
+			}
+			records = append(records, mmdbwriter.NetworkRecord{Network: ipNet, Value: value})
+		}
+	}
+	return records, nil
+}
+
+// BuildFromSource creates a tree with the options and inserts the records
+// read from the source JSON with ReadSource, in order.
+func BuildFromSource(r io.Reader, opts mmdbwriter.Options) (*mmdbwriter.Tree, error) {
+	records, err := ReadSource(r)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := mmdbwriter.New(opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		if err := tree.Insert(record.Network, record.Value); err != nil {
+			return nil, fmt.Errorf("inserting %s: %w", record.Network, err)
+		}
+	}
+	return tree, nil
+}
+
+// OptionsFromMetadata returns the Options to rebuild a database with the
+// metadata, e.g., that of one of the test databases. Reserved networks are
+// included, as the test databases contain them.
+func OptionsFromMetadata(metadata maxminddb.Metadata) mmdbwriter.Options {
+	description := make(map[string]string, len(metadata.Description))
+	for k, v := range metadata.Description {
+		description[k] = v
+	}
+	return mmdbwriter.Options{
+		BuildEpoch:              int64(metadata.BuildEpoch),
+		DatabaseType:            metadata.DatabaseType,
+		Description:             description,
+		IPVersion:               int(metadata.IPVersion),
+		Languages:               append([]string(nil), metadata.Languages...),
+		RecordSize:              int(metadata.RecordSize),
+		IncludeReservedNetworks: true,
+	}
+}
+
+// Difference is a difference between two databases found by Compare.
+type Difference struct {
+	// Field is the metadata field that differs, e.g., "database_type". It
+	// is empty for a difference in the records.
+	Field string
+
+	// Network is the network whose records differ. It is the zero Prefix
+	// for a difference in the metadata. Networks within ::/96 in an IPv6
+	// database are reported as IPv4 networks.
+	Network netip.Prefix
+
+	// Expected and Actual are the differing values as decoded by
+	// maxminddb-golang. A nil value means that the database has no record
+	// for the network.
+	Expected any
+	Actual   any
+}
+
+func (d Difference) String() string {
+	if d.Field != "" {
+		return fmt.Sprintf("metadata %s: expected %v, got %v", d.Field, d.Expected, d.Actual)
+	}
+	return fmt.Sprintf("%s: expected %v, got %v", d.Network, d.Expected, d.Actual)
+}
+
+// Compare compares the actual database with the expected one and returns
+// the differences, if any. The metadata fields that describe the data are
+// compared, i.e., the format version, database type, description, IP
+// version, and languages, while those that depend on the build, e.g., the
+// build epoch, node count, and record size, are not.
+//
+// The records are compared for every address, so the databases match even
+// if the networks are split or merged differently. Integers are compared by
+// value regardless of their MaxMind DB type, e.g., a Uint16 matches a
+// Uint32 with the same value. The differences for adjacent networks with
+// the same records are combined.
+func Compare(expected, actual *maxminddb.Reader) ([]Difference, error) {
+	diffs := compareMetadata(expected.Metadata, actual.Metadata)
+
+	is6 := expected.Metadata.IPVersion == 6 || actual.Metadata.IPVersion == 6
+	expectedRecords, err := readRecords(expected, is6)
+	if err != nil {
+		return nil, fmt.Errorf("reading expected database: %w", err)
+	}
+	actualRecords, err := readRecords(actual, is6)
+	if err != nil {
+		return nil, fmt.Errorf("reading actual database: %w", err)
+	}
+	return append(diffs, compareRecords(expectedRecords, actualRecords)...), nil
+}
+
+func compareMetadata(expected, actual maxminddb.Metadata) []Difference {
+	var diffs []Difference
+	add := func(field string, e, a any) {
+		if !reflect.DeepEqual(e, a) {
+			diffs = append(diffs, Difference{Field: field, Expected: e, Actual: a})
+		}
+	}
+	add("binary_format_major_version", expected.BinaryFormatMajorVersion, actual.BinaryFormatMajorVersion)
+	add("binary_format_minor_version", expected.BinaryFormatMinorVersion, actual.BinaryFormatMinorVersion)
+	add("database_type", expected.DatabaseType, actual.DatabaseType)
+	add("description", nonNilMap(expected.Description), nonNilMap(actual.Description))
+	add("ip_version", expected.IPVersion, actual.IPVersion)
+	add("languages", nonNilSlice(expected.Languages), nonNilSlice(actual.Languages))
+	return diffs
+}
+
+func nonNilMap(m map[string]string) map[string]string {
+	if m == nil {
+		return map[string]string{}
+	}
+	return m
+}
+
+func nonNilSlice(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+// rangeRecord is the record of a range of addresses.
+type rangeRecord struct {
+	r     netipx.IPRange
+	value any
+}
+
+// readRecords returns the records of the database in order. If is6 is set,
+// the addresses are returned in IPv6 form, with IPv4 networks within ::/96.
+func readRecords(reader *maxminddb.Reader, is6 bool) ([]rangeRecord, error) {
+	var records []rangeRecord
+	networks := reader.Networks(maxminddb.SkipAliasedNetworks)
+	for networks.Next() {
+		var value any
+		network, err := networks.Network(&value)
+		if err != nil {
+			return nil, err
+		}
+		// We do not use netipx.FromStdIPNet as it unmaps IPv4-mapped
+		// addresses, which are distinct networks in an IPv6 database.
+		addr, ok := netip.AddrFromSlice(network.IP)
+		if !ok {
+			return nil, fmt.Errorf("invalid network: %s", network)
+		}
+		bits, _ := network.Mask.Size()
+		prefix := netip.PrefixFrom(addr, bits)
+		if is6 && prefix.Addr().Is4() {
+			prefix = netip.PrefixFrom(ipv4In6(prefix.Addr()), prefix.Bits()+96)
+		}
+		records = append(records, rangeRecord{r: netipx.RangeOfPrefix(prefix), value: value})
+	}
+	if err := networks.Err(); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+// ipv4In6 returns the IPv4 address within ::/96.
+func ipv4In6(addr netip.Addr) netip.Addr {
+	var b [16]byte
+	a4 := addr.As4()
+	copy(b[12:], a4[:])
+	return netip.AddrFrom16(b)
+}
+
+// compareRecords compares two sorted lists of non-overlapping ranges by
+// splitting the address space at each of their boundaries.
+func compareRecords(expected, actual []rangeRecord) []Difference {
+	var points []netip.Addr
+	for _, records := range [][]rangeRecord{expected, actual} {
+		for _, r := range records {
+			points = append(points, r.r.From())
+			if next := r.r.To().Next(); next.IsValid() {
+				points = append(points, next)
+			}
+		}
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].Less(points[j]) })
+
+	var (
+		diffs []Difference
+		// pending is the range of the current difference, which is
+		// extended while the following segments differ in the same way.
+		pending      netipx.IPRange
+		pendingValid bool
+		pendingE     any
+		pendingA     any
+	)
+	flush := func() {
+		if !pendingValid {
+			return
+		}
+		for _, prefix := range pending.Prefixes() {
+			diffs = append(diffs, Difference{
+				Network:  displayPrefix(prefix),
+				Expected: pendingE,
+				Actual:   pendingA,
+			})
+		}
+		pendingValid = false
+	}
+
+	var i, j int
+	for k, from := range points {
+		if k > 0 && from == points[k-1] {
+			continue
+		}
+		to := maxAddr(from)
+		for n := k + 1; n < len(points); n++ {
+			if points[n] != from {
+				to = points[n].Prev()
+				break
+			}
+		}
+
+		var e, a any
+		e, i = valueAt(expected, i, from)
+		a, j = valueAt(actual, j, from)
+		if equalValues(e, a) {
+			flush()
+			continue
+		}
+		if pendingValid && pending.To().Next() == from &&
+			reflect.DeepEqual(pendingE, e) && reflect.DeepEqual(pendingA, a) {
+			pending = netipx.IPRangeFrom(pending.From(), to)
+			continue
+		}
+		flush()
+		pending = netipx.IPRangeFrom(from, to)
+		pendingValid = true
+		pendingE = e
+		pendingA = a
+	}
+	flush()
+	return diffs
+}
+
+// valueAt returns the value of the range containing addr, starting the
+// search at index i, and the index to start the next search at.
+func valueAt(records []rangeRecord, i int, addr netip.Addr) (any, int) {
+	for i < len(records) && records[i].r.To().Less(addr) {
+		i++
+	}
+	if i < len(records) && records[i].r.Contains(addr) {
+		return records[i].value, i
+	}
+	return nil, i
+}
+
+func maxAddr(addr netip.Addr) netip.Addr {
+	if addr.Is4() {
+		return netip.AddrFrom4([4]byte{255, 255, 255, 255})
+	}
+	var b [16]byte
+	for i := range b {
+		b[i] = 0xFF
+	}
+	return netip.AddrFrom16(b)
+}
+
+// displayPrefix returns networks within ::/96 as IPv4 networks.
+func displayPrefix(prefix netip.Prefix) netip.Prefix {
+	addr := prefix.Addr()
+	if !addr.Is6() || prefix.Bits() < 96 {
+		return prefix
+	}
+	b := addr.As16()
+	for _, c := range b[:12] {
+		if c != 0 {
+			return prefix
+		}
+	}
+	var a4 [4]byte
+	copy(a4[:], b[12:])
+	return netip.PrefixFrom(netip.AddrFrom4(a4), prefix.Bits()-96)
+}
+
+// equalValues compares values decoded by maxminddb-golang, treating
+// integers of different types with the same value as equal.
+func equalValues(a, b any) bool {
+	if ai, ok := toBigInt(a); ok {
+		bi, ok := toBigInt(b)
+		return ok && ai.Cmp(bi) == 0
+	}
+	switch a := a.(type) {
+	case map[string]any:
+		b, ok := b.(map[string]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for k, v := range a {
+			bv, ok := b[k]
+			if !ok || !equalValues(v, bv) {
+				return false
+			}
+		}
+		return true
+	case []any:
+		b, ok := b.([]any)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !equalValues(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		return reflect.DeepEqual(a, b)
+	}
+}
+
+func toBigInt(v any) (*big.Int, bool) {
+	switch v := v.(type) {
+	case int:
+		return big.NewInt(int64(v)), true
+	case uint64:
+		return new(big.Int).SetUint64(v), true
+	case *big.Int:
+		return v, true
+	default:
+		return nil, false
+	}
+}
+
+// ErrDifferent is returned by CompareBytes if the databases differ.
+var ErrDifferent = errors.New("the databases differ")
+
+// CompareBytes compares two databases in the MaxMind DB format with
+// Compare. It returns an error wrapping ErrDifferent that lists the first
+// differences if the databases differ.
+func CompareBytes(expected, actual []byte) error {
+	diffs, err := compareBytes(expected, actual)
+	if err != nil {
+		return err
+	}
+	if len(diffs) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s", ErrDifferent, strings.Join(listDifferences(diffs, 10), "; "))
+}
+
+func compareBytes(expected, actual []byte) ([]Difference, error) {
+	expectedReader, err := maxminddb.FromBytes(expected)
+	if err != nil {
+		return nil, fmt.Errorf("opening expected database: %w", err)
+	}
+	actualReader, err := maxminddb.FromBytes(actual)
+	if err != nil {
+		return nil, fmt.Errorf("opening actual database: %w", err)
+	}
+	return Compare(expectedReader, actualReader)
+}
+
+// listDifferences returns the first maxListed differences as strings,
+// followed by the number of differences not listed, if any.
+func listDifferences(diffs []Difference, maxListed int) []string {
+	var listed []string
+	for i, d := range diffs {
+		if i == maxListed {
+			listed = append(listed, fmt.Sprintf("and %d more", len(diffs)-maxListed))
+			break
+		}
+		listed = append(listed, d.String())
+	}
+	return listed
+}
@@ -0,0 +1,221 @@
+package mmdbtest
+
+import (
+	"bytes"
+	"net"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/oschwald/maxminddb-golang"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go4.org/netipx"
+)
+
+func testOptions() mmdbwriter.Options {
+	return mmdbwriter.Options{
+		BuildEpoch:              1,
+		DatabaseType:            "Test-Country",
+		Description:             map[string]string{"en": "Test database"},
+		IncludeReservedNetworks: true,
+		Languages:               []string{"en"},
+		RecordSize:              24,
+	}
+}
+
+func buildSource(t *testing.T, opts mmdbwriter.Options) []byte {
+	f, err := os.Open(filepath.Join("testdata", "Test-Country.json"))
+	require.NoError(t, err)
+	defer f.Close() //nolint:errcheck // test
+
+	tree, err := BuildFromSource(f, opts)
+	require.NoError(t, err)
+	return write(t, tree)
+}
+
+func write(t *testing.T, tree *mmdbwriter.Tree) []byte {
+	buf := &bytes.Buffer{}
+	_, err := tree.WriteTo(buf)
+	require.NoError(t, err)
+	return buf.Bytes()
+}
+
+func TestReadSource(t *testing.T) {
+	records, err := ReadSource(strings.NewReader(
+		`[{"1.1.1.0/24": {"a": 1, "b": 1.5}}, {"::/64": "x", "2.2.2.0/24": [true]}]`,
+	))
+	require.NoError(t, err)
+
+	var networks []string
+	for _, r := range records {
+		networks = append(networks, r.Network.String())
+	}
+	assert.Equal(t, []string{"1.1.1.0/24", "2.2.2.0/24", "::/64"}, networks)
+	assert.Equal(t, mmdbtype.Map{"a": mmdbtype.Int32(1), "b": mmdbtype.Float64(1.5)}, records[0].Value)
+
+	_, err = ReadSource(strings.NewReader(`[{"1.1.1.0": {}}]`))
+	assert.EqualError(t, err, "entry 0: invalid CIDR address: 1.1.1.0")
+}
+
+func TestCompare(t *testing.T) {
+	expected := buildSource(t, testOptions())
+
+	t.Run("same database", func(t *testing.T) {
+		assert.NoError(t, CompareBytes(expected, buildSource(t, testOptions())))
+	})
+
+	t.Run("different layout", func(t *testing.T) {
+		// The record size and integer types differ, but the records of
+		// each address match.
+		opts := testOptions()
+		opts.RecordSize = 32
+		opts.BuildEpoch = 2
+		tree, err := mmdbwriter.New(opts)
+		require.NoError(t, err)
+
+		f, err := os.Open(filepath.Join("testdata", "Test-Country.json"))
+		require.NoError(t, err)
+		defer f.Close() //nolint:errcheck // test
+		records, err := ReadSource(f)
+		require.NoError(t, err)
+		for _, r := range records {
+			require.NoError(t, tree.Insert(r.Network, toUint32(r.Value)))
+		}
+		assert.NoError(t, CompareBytes(expected, write(t, tree)))
+	})
+
+	t.Run("differences", func(t *testing.T) {
+		opts := testOptions()
+		opts.DatabaseType = "Other"
+		tree, err := mmdbwriter.New(opts)
+		require.NoError(t, err)
+
+		f, err := os.Open(filepath.Join("testdata", "Test-Country.json"))
+		require.NoError(t, err)
+		defer f.Close() //nolint:errcheck // test
+		records, err := ReadSource(f)
+		require.NoError(t, err)
+		for _, r := range records {
+			require.NoError(t, tree.Insert(r.Network, r.Value))
+		}
+		_, network, err := net.ParseCIDR("81.2.69.176/28")
+		require.NoError(t, err)
+		require.NoError(t, tree.Insert(network, mmdbtype.Map{"country": mmdbtype.Map{"iso_code": mmdbtype.String("FR")}}))
+		_, network, err = net.ParseCIDR("3.3.3.0/24")
+		require.NoError(t, err)
+		require.NoError(t, tree.Insert(network, mmdbtype.String("new")))
+
+		expectedReader, err := maxminddb.FromBytes(expected)
+		require.NoError(t, err)
+		actualReader, err := maxminddb.FromBytes(write(t, tree))
+		require.NoError(t, err)
+
+		diffs, err := Compare(expectedReader, actualReader)
+		require.NoError(t, err)
+		require.Len(t, diffs, 3)
+
+		assert.Equal(t, "database_type", diffs[0].Field)
+		assert.Equal(t, "metadata database_type: expected Test-Country, got Other", diffs[0].String())
+
+		assert.Equal(t, netip.MustParsePrefix("3.3.3.0/24"), diffs[1].Network)
+		assert.Nil(t, diffs[1].Expected)
+		assert.Equal(t, "new", diffs[1].Actual)
+
+		assert.Equal(t, netip.MustParsePrefix("81.2.69.176/28"), diffs[2].Network)
+		assert.Equal(t, map[string]any{"country": map[string]any{"iso_code": "FR"}}, diffs[2].Actual)
+
+		err = CompareBytes(expected, write(t, tree))
+		assert.ErrorIs(t, err, ErrDifferent)
+		assert.Contains(t, err.Error(), "3.3.3.0/24: expected <nil>, got new")
+	})
+}
+
+func TestCompareRecordsSplit(t *testing.T) {
+	rangeOf := func(s string, value any) rangeRecord {
+		return rangeRecord{r: netipx.RangeOfPrefix(netip.MustParsePrefix(s)), value: value}
+	}
+
+	// The expected records are split differently, but only 1.1.3.0/24
+	// differs.
+	expected := []rangeRecord{
+		rangeOf("1.1.0.0/24", "a"),
+		rangeOf("1.1.1.0/24", "a"),
+		rangeOf("1.1.2.0/23", "b"),
+	}
+	actual := []rangeRecord{
+		rangeOf("1.1.0.0/23", "a"),
+		rangeOf("1.1.2.0/24", "b"),
+		rangeOf("1.1.3.0/25", "c"),
+		rangeOf("1.1.3.128/25", "c"),
+	}
+	assert.Equal(t, []Difference{{
+		Network:  netip.MustParsePrefix("1.1.3.0/24"),
+		Expected: "b",
+		Actual:   "c",
+	}}, compareRecords(expected, actual))
+	assert.Empty(t, compareRecords(expected, expected))
+}
+
+func TestEqualValues(t *testing.T) {
+	assert.True(t, equalValues(uint64(1), 1))
+	assert.True(t, equalValues(map[string]any{"a": []any{uint64(2)}}, map[string]any{"a": []any{2}}))
+	assert.False(t, equalValues(map[string]any{"a": 1}, map[string]any{"a": 1, "b": 2}))
+	assert.False(t, equalValues(1, "1"))
+	assert.False(t, equalValues(float32(1.5), 1.5))
+}
+
+// TestUpstreamTestData rebuilds the test databases of the MaxMind DB
+// specification from their source JSON and compares them with the
+// databases. Set MAXMIND_DB_DIR to a checkout of
+// https://github.com/maxmind/MaxMind-DB to run it.
+func TestUpstreamTestData(t *testing.T) {
+	dir := os.Getenv("MAXMIND_DB_DIR")
+	if dir == "" {
+		t.Skip("MAXMIND_DB_DIR is not set")
+	}
+
+	sources, err := filepath.Glob(filepath.Join(dir, "source-data", "*.json"))
+	require.NoError(t, err)
+	for _, source := range sources {
+		name := strings.TrimSuffix(filepath.Base(source), ".json")
+		database := filepath.Join(dir, "test-data", name+".mmdb")
+		if _, err := os.Stat(database); err != nil {
+			continue
+		}
+		t.Run(name, func(t *testing.T) {
+			expected, err := os.ReadFile(database)
+			require.NoError(t, err)
+			reader, err := maxminddb.FromBytes(expected)
+			require.NoError(t, err)
+
+			f, err := os.Open(source)
+			require.NoError(t, err)
+			defer f.Close() //nolint:errcheck // test
+
+			tree, err := BuildFromSource(f, OptionsFromMetadata(reader.Metadata))
+			require.NoError(t, err)
+			assert.NoError(t, CompareBytes(expected, write(t, tree)))
+		})
+	}
+}
+
+// toUint32 converts the Int32 values to Uint32 values.
+func toUint32(v mmdbtype.DataType) mmdbtype.DataType {
+	switch v := v.(type) {
+	case mmdbtype.Int32:
+		return mmdbtype.Uint32(v)
+	case mmdbtype.Map:
+		m := mmdbtype.Map{}
+		for k, e := range v {
+			m[k] = toUint32(e)
+		}
+		return m
+	default:
+		return v
+	}
+}
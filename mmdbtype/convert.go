@@ -0,0 +1,299 @@
+package mmdbtype
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"net"
+	"net/netip"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// FromAny converts a native Go value to a DataType. This is primarily
+// intended for building records from decoded JSON or YAML. The conversion
+// rules are:
+//
+//   - A DataType is returned as is.
+//   - nil, including nil pointers, maps, and interfaces, converts to a nil
+//     DataType. Map entries with nil values are omitted. A nil slice element
+//     is an error.
+//   - bool converts to Bool and string to String.
+//   - []byte converts to Bytes.
+//   - int8, int16, and int32 convert to Int32. int and int64 convert to
+//     Int32 if the value fits, Uint64 if it is larger, and are an error if
+//     they are less than the minimum int32.
+//   - uint8 and uint16 convert to Uint16, uint32 to Uint32, and uint,
+//     uint64, and uintptr to Uint64.
+//   - float32 converts to Float32 and float64 to Float64.
+//   - json.Number converts using the int64 rules if it is an integer that
+//     fits in an int64, to Uint64 or Uint128 if it is a larger integer, and
+//     to Float64 otherwise. Decode JSON with json.Decoder.UseNumber to keep
+//     integers from becoming Float64 values.
+//   - *big.Int converts to Uint128. It must not be negative or larger than
+//     128 bits.
+//   - time.Time converts to a String in RFC 3339 format in UTC, e.g.,
+//     "2022-11-01T12:30:15Z".
+//   - net.IP, *net.IPNet, netip.Addr, and netip.Prefix convert to a String,
+//     e.g., "1.1.1.1" and "1.1.1.0/24".
+//   - Maps with string keys convert to Map and other slices and arrays to
+//     Slice, with the values converted recursively.
+//   - Pointers are dereferenced.
+//   - Other types whose underlying type is one of the above basic types,
+//     e.g., type Code string, convert as the underlying type.
+//
+// Any other type is an error.
+func FromAny(v any) (DataType, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case DataType:
+		return v, nil
+	case bool:
+		return Bool(v), nil
+	case string:
+		return String(v), nil
+	case []byte:
+		if v == nil {
+			return nil, nil
+		}
+		return Bytes(v), nil
+	case int8:
+		return Int32(v), nil
+	case int16:
+		return Int32(v), nil
+	case int32:
+		return Int32(v), nil
+	case int:
+		return fromInt64(int64(v))
+	case int64:
+		return fromInt64(v)
+	case uint8:
+		return Uint16(v), nil
+	case uint16:
+		return Uint16(v), nil
+	case uint32:
+		return Uint32(v), nil
+	case uint:
+		return Uint64(v), nil
+	case uint64:
+		return Uint64(v), nil
+	case uintptr:
+		return Uint64(v), nil
+	case float32:
+		return Float32(v), nil
+	case float64:
+		return Float64(v), nil
+	case json.Number:
+		return fromJSONNumber(v)
+	case *big.Int:
+		if v == nil {
+			return nil, nil
+		}
+		return fromBigInt(v)
+	case time.Time:
+		return String(v.UTC().Format(time.RFC3339Nano)), nil
+	case net.IP:
+		if v == nil {
+			return nil, nil
+		}
+		if len(v) != net.IPv4len && len(v) != net.IPv6len {
+			return nil, errors.New("invalid IP address")
+		}
+		return String(v.String()), nil
+	case *net.IPNet:
+		if v == nil {
+			return nil, nil
+		}
+		return String(v.String()), nil
+	case netip.Addr:
+		if !v.IsValid() {
+			return nil, errors.New("invalid IP address")
+		}
+		return String(v.String()), nil
+	case netip.Prefix:
+		if !v.IsValid() {
+			return nil, errors.New("invalid network")
+		}
+		return String(v.String()), nil
+	}
+	return fromReflectValue(reflect.ValueOf(v))
+}
+
+func fromInt64(v int64) (DataType, error) {
+	switch {
+	case v >= math.MinInt32 && v <= math.MaxInt32:
+		return Int32(v), nil
+	case v > 0:
+		return Uint64(v), nil
+	default:
+		return nil, fmt.Errorf("%d is less than the minimum int32", v)
+	}
+}
+
+func fromJSONNumber(n json.Number) (DataType, error) {
+	if i, err := strconv.ParseInt(string(n), 10, 64); err == nil {
+		return fromInt64(i)
+	}
+	if u, err := strconv.ParseUint(string(n), 10, 64); err == nil {
+		return Uint64(u), nil
+	}
+	if i, ok := new(big.Int).SetString(string(n), 10); ok {
+		return fromBigInt(i)
+	}
+	f, err := strconv.ParseFloat(string(n), 64)
+	if err != nil {
+		return nil, fmt.Errorf("converting %q to a number: %w", n, err)
+	}
+	return Float64(f), nil
+}
+
+func fromBigInt(v *big.Int) (DataType, error) {
+	if v.Sign() < 0 || v.BitLen() > 128 {
+		return nil, fmt.Errorf("%s does not fit in a uint128", v)
+	}
+	u := Uint128(*new(big.Int).Set(v))
+	return &u, nil
+}
+
+// fromReflectValue converts the values whose type is not handled directly by
+// FromAny, e.g., maps, slices, and named types.
+func fromReflectValue(rv reflect.Value) (DataType, error) {
+	switch rv.Kind() {
+	case reflect.Bool:
+		return Bool(rv.Bool()), nil
+	case reflect.String:
+		return String(rv.String()), nil
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return Int32(rv.Int()), nil
+	case reflect.Int, reflect.Int64:
+		return fromInt64(rv.Int())
+	case reflect.Uint8, reflect.Uint16:
+		return Uint16(rv.Uint()), nil
+	case reflect.Uint32:
+		return Uint32(rv.Uint()), nil
+	case reflect.Uint, reflect.Uint64, reflect.Uintptr:
+		return Uint64(rv.Uint()), nil
+	case reflect.Float32:
+		return Float32(rv.Float()), nil
+	case reflect.Float64:
+		return Float64(rv.Float()), nil
+	case reflect.Pointer, reflect.Interface:
+		if rv.IsNil() {
+			return nil, nil
+		}
+		return FromAny(rv.Elem().Interface())
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("unsupported map key type: %s", rv.Type().Key())
+		}
+		if rv.IsNil() {
+			return nil, nil
+		}
+		m := make(Map, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			key := iter.Key().String()
+			v, err := FromAny(iter.Value().Interface())
+			if err != nil {
+				return nil, fmt.Errorf("map key %q: %w", key, err)
+			}
+			if v != nil {
+				m[String(key)] = v
+			}
+		}
+		return m, nil
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() {
+			return nil, nil
+		}
+		if rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8 {
+			return Bytes(rv.Bytes()), nil
+		}
+		s := make(Slice, rv.Len())
+		for i := range s {
+			v, err := FromAny(rv.Index(i).Interface())
+			if err != nil {
+				return nil, fmt.Errorf("slice index %d: %w", i, err)
+			}
+			if v == nil {
+				return nil, fmt.Errorf("slice index %d: nil values are not supported", i)
+			}
+			s[i] = v
+		}
+		return s, nil
+	default:
+		if !rv.IsValid() {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("unsupported type: %s", rv.Type())
+	}
+}
+
+// ToAny converts a DataType to a native Go value, e.g., for encoding with
+// encoding/json. It is the inverse of FromAny for the native types:
+//
+//   - Map converts to map[string]any and Slice to []any, with the values
+//     converted recursively.
+//   - Bool, Bytes, Float32, Float64, Int32, String, Uint16, Uint32, and
+//     Uint64 convert to the corresponding Go type, e.g., Uint32 to uint32.
+//   - Uint128 and FixedUint128 convert to *big.Int.
+//   - Templated converts to map[string]any, the same as its composed Map.
+//   - A nil DataType converts to nil.
+//
+// Any other type is an error.
+func ToAny(v DataType) (any, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case Map:
+		m := make(map[string]any, len(v))
+		for k, e := range v {
+			av, err := ToAny(e)
+			if err != nil {
+				return nil, err
+			}
+			m[string(k)] = av
+		}
+		return m, nil
+	case Templated:
+		return ToAny(v.Map())
+	case Slice:
+		s := make([]any, len(v))
+		for i, e := range v {
+			av, err := ToAny(e)
+			if err != nil {
+				return nil, err
+			}
+			s[i] = av
+		}
+		return s, nil
+	case Bool:
+		return bool(v), nil
+	case Bytes:
+		return []byte(v), nil
+	case Float32:
+		return float32(v), nil
+	case Float64:
+		return float64(v), nil
+	case Int32:
+		return int32(v), nil
+	case String:
+		return string(v), nil
+	case Uint16:
+		return uint16(v), nil
+	case Uint32:
+		return uint32(v), nil
+	case Uint64:
+		return uint64(v), nil
+	case *Uint128:
+		return (*big.Int)(v), nil
+	case FixedUint128:
+		return v.BigInt(), nil
+	default:
+		return nil, fmt.Errorf("unsupported type: %T", v)
+	}
+}
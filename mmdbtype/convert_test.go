@@ -0,0 +1,188 @@
+package mmdbtype
+
+import (
+	"encoding/json"
+	"math"
+	"math/big"
+	"net"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromAny(t *testing.T) {
+	type code string
+
+	bigValue, ok := new(big.Int).SetString("18446744073709551616", 10)
+	require.True(t, ok)
+	bigUint128 := Uint128(*bigValue)
+
+	str := "pointer"
+	_, network, err := net.ParseCIDR("1.1.1.0/24")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		value    any
+		expected DataType
+	}{
+		{name: "nil", value: nil, expected: nil},
+		{name: "DataType", value: Uint16(1), expected: Uint16(1)},
+		{name: "bool", value: true, expected: Bool(true)},
+		{name: "string", value: "a", expected: String("a")},
+		{name: "named string", value: code("US"), expected: String("US")},
+		{name: "bytes", value: []byte{1}, expected: Bytes{1}},
+		{name: "int8", value: int8(-1), expected: Int32(-1)},
+		{name: "int32", value: int32(math.MinInt32), expected: Int32(math.MinInt32)},
+		{name: "int", value: 5, expected: Int32(5)},
+		{name: "large int64", value: int64(math.MaxInt64), expected: Uint64(math.MaxInt64)},
+		{name: "uint8", value: uint8(1), expected: Uint16(1)},
+		{name: "uint16", value: uint16(1), expected: Uint16(1)},
+		{name: "uint32", value: uint32(1), expected: Uint32(1)},
+		{name: "uint", value: uint(1), expected: Uint64(1)},
+		{name: "uint64", value: uint64(math.MaxUint64), expected: Uint64(math.MaxUint64)},
+		{name: "float32", value: float32(1.5), expected: Float32(1.5)},
+		{name: "float64", value: 1.5, expected: Float64(1.5)},
+		{name: "json int", value: json.Number("-3"), expected: Int32(-3)},
+		{name: "json uint64", value: json.Number("18446744073709551615"), expected: Uint64(math.MaxUint64)},
+		{name: "json uint128", value: json.Number("18446744073709551616"), expected: &bigUint128},
+		{name: "json float", value: json.Number("1.5e3"), expected: Float64(1500)},
+		{name: "big.Int", value: bigValue, expected: &bigUint128},
+		{
+			name:     "time",
+			value:    time.Date(2022, 11, 1, 12, 30, 15, 0, time.FixedZone("", 3600)),
+			expected: String("2022-11-01T11:30:15Z"),
+		},
+		{name: "IP", value: net.ParseIP("1.1.1.1"), expected: String("1.1.1.1")},
+		{name: "IPNet", value: network, expected: String("1.1.1.0/24")},
+		{name: "netip.Addr", value: netip.MustParseAddr("2001:db8::1"), expected: String("2001:db8::1")},
+		{name: "netip.Prefix", value: netip.MustParsePrefix("2001:db8::/32"), expected: String("2001:db8::/32")},
+		{name: "pointer", value: &str, expected: String("pointer")},
+		{name: "nil pointer", value: (*string)(nil), expected: nil},
+		{
+			name: "map",
+			value: map[string]any{
+				"a": 1,
+				"b": nil,
+				"c": []any{"x", 2.5},
+				"d": map[string]string{"e": "f"},
+			},
+			expected: Map{
+				"a": Int32(1),
+				"c": Slice{String("x"), Float64(2.5)},
+				"d": Map{"e": String("f")},
+			},
+		},
+		{name: "array", value: [2]int{1, 2}, expected: Slice{Int32(1), Int32(2)}},
+		{name: "nil map", value: map[string]any(nil), expected: nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			v, err := FromAny(test.value)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, v)
+		})
+	}
+}
+
+func TestFromAnyJSON(t *testing.T) {
+	d := json.NewDecoder(strings.NewReader(
+		`{"country":{"iso_code":"US","geoname_id":6252001},"location":{"latitude":37.751}}`,
+	))
+	d.UseNumber()
+	var v any
+	require.NoError(t, d.Decode(&v))
+
+	dt, err := FromAny(v)
+	require.NoError(t, err)
+	assert.Equal(t, Map{
+		"country": Map{
+			"iso_code":   String("US"),
+			"geoname_id": Int32(6252001),
+		},
+		"location": Map{"latitude": Float64(37.751)},
+	}, dt)
+}
+
+func TestFromAnyErrors(t *testing.T) {
+	tests := []struct {
+		value       any
+		expectedErr string
+	}{
+		{value: int64(math.MinInt64), expectedErr: "-9223372036854775808 is less than the minimum int32"},
+		{value: big.NewInt(-1), expectedErr: "-1 does not fit in a uint128"},
+		{value: json.Number("1e400"), expectedErr: `converting "1e400" to a number: strconv.ParseFloat: parsing "1e400": value out of range`},
+		{value: net.IP{1}, expectedErr: "invalid IP address"},
+		{value: netip.Addr{}, expectedErr: "invalid IP address"},
+		{value: map[int]string{}, expectedErr: "unsupported map key type: int"},
+		{value: []any{nil}, expectedErr: "slice index 0: nil values are not supported"},
+		{value: map[string]any{"a": []any{make(chan int)}}, expectedErr: `map key "a": slice index 0: unsupported type: chan int`},
+		{value: struct{}{}, expectedErr: "unsupported type: struct {}"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expectedErr, func(t *testing.T) {
+			_, err := FromAny(test.value)
+			assert.EqualError(t, err, test.expectedErr)
+		})
+	}
+}
+
+func TestToAny(t *testing.T) {
+	u := Uint128(*big.NewInt(5))
+	u8 := Uint128(*big.NewInt(8))
+	value := Map{
+		"bool":    Bool(true),
+		"bytes":   Bytes{1, 2},
+		"float32": Float32(1.5),
+		"float64": Float64(2.5),
+		"int32":   Int32(-3),
+		"slice":   Slice{String("a"), Uint16(4)},
+		"uint32":  Uint32(6),
+		"uint64":  Uint64(7),
+		"uint128": &u,
+		"fixed":   Uint128FromUint64(8),
+	}
+
+	v, err := ToAny(value)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"bool":    true,
+		"bytes":   []byte{1, 2},
+		"float32": float32(1.5),
+		"float64": 2.5,
+		"int32":   int32(-3),
+		"slice":   []any{"a", uint16(4)},
+		"uint32":  uint32(6),
+		"uint64":  uint64(7),
+		"uint128": big.NewInt(5),
+		"fixed":   big.NewInt(8),
+	}, v)
+
+	dt, err := FromAny(v)
+	require.NoError(t, err)
+	assert.Equal(t, Map{
+		"bool":    Bool(true),
+		"bytes":   Bytes{1, 2},
+		"float32": Float32(1.5),
+		"float64": Float64(2.5),
+		"int32":   Int32(-3),
+		"slice":   Slice{String("a"), Uint16(4)},
+		"uint32":  Uint32(6),
+		"uint64":  Uint64(7),
+		"uint128": &u,
+		"fixed":   &u8,
+	}, dt)
+
+	v, err = ToAny(nil)
+	require.NoError(t, err)
+	assert.Nil(t, v)
+
+	_, err = ToAny(Slice{Pointer(1)})
+	assert.EqualError(t, err, "unsupported type: mmdbtype.Pointer")
+}
@@ -0,0 +1,258 @@
+package mmdbtype
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+)
+
+const (
+	// maxDecodeDepth limits the nesting of maps and slices so that corrupt
+	// data cannot exhaust the stack.
+	maxDecodeDepth = 512
+
+	// maxDecodeValues limits the total number of values decoded. As
+	// pointers allow values to be reused, a small amount of data may
+	// otherwise expand exponentially.
+	maxDecodeValues = 1 << 20
+)
+
+// Decode decodes a single value encoded in the MaxMind DB data format, such
+// as the output of Encode. The value must use all of b. Use DecodeAt to
+// decode values in a data section that contains other values or pointers.
+func Decode(b []byte) (DataType, error) {
+	v, offset, err := DecodeAt(b, 0)
+	if err != nil {
+		return nil, err
+	}
+	if offset != len(b) {
+		return nil, fmt.Errorf("unexpected data after the value at offset %d", offset)
+	}
+	return v, nil
+}
+
+// DecodeAt decodes the value at offset in b, which is typically the data
+// section of a database. Pointers are resolved relative to the start of b.
+// It returns the value and the offset immediately following it.
+func DecodeAt(b []byte, offset int) (DataType, int, error) {
+	d := decoder{buf: b}
+	return d.decode(offset, 0)
+}
+
+type decoder struct {
+	buf    []byte
+	values int
+}
+
+func (d *decoder) decode(offset, depth int) (DataType, int, error) {
+	if depth > maxDecodeDepth {
+		return nil, 0, errors.New("exceeded maximum data structure depth")
+	}
+	d.values++
+	if d.values > maxDecodeValues {
+		return nil, 0, errors.New("exceeded maximum number of values")
+	}
+	ctrl, offset, err := d.readBytes(offset, 1)
+	if err != nil {
+		return nil, 0, err
+	}
+	tn := typeNum(ctrl[0] >> 5)
+
+	if tn == typeNumPointer {
+		pointer, newOffset, err := d.decodePointer(ctrl[0], offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		// Pointers to pointers are not allowed by the spec, which also
+		// guarantees that we cannot loop.
+		target, _, err := d.readBytes(pointer, 1)
+		if err != nil {
+			return nil, 0, err
+		}
+		if typeNum(target[0]>>5) == typeNumPointer {
+			return nil, 0, fmt.Errorf("pointer at offset %d points to another pointer", offset-1)
+		}
+		v, _, err := d.decode(pointer, depth)
+		return v, newOffset, err
+	}
+
+	if tn == typeNumExtended {
+		var ext []byte
+		ext, offset, err = d.readBytes(offset, 1)
+		if err != nil {
+			return nil, 0, err
+		}
+		if int(ext[0])+7 <= int(typeNumMap) || int(ext[0])+7 > int(typeNumFloat32) {
+			return nil, 0, fmt.Errorf("invalid extended type: %d", int(ext[0])+7)
+		}
+		tn = typeNum(ext[0] + 7)
+	}
+
+	size, offset, err := d.sizeFromCtrlByte(ctrl[0], offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	switch tn {
+	case typeNumMap:
+		// Each key and value takes at least one byte.
+		if size > (len(d.buf)-offset)/2 {
+			return nil, 0, errors.New("unexpected end of data")
+		}
+		m := make(Map, size)
+		for i := 0; i < size; i++ {
+			var k, v DataType
+			k, offset, err = d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			key, ok := k.(String)
+			if !ok {
+				return nil, 0, fmt.Errorf("unexpected map key type: %T", k)
+			}
+			v, offset, err = d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			m[key] = v
+		}
+		return m, offset, nil
+	case typeNumSlice:
+		// Each element takes at least one byte.
+		if size > len(d.buf)-offset {
+			return nil, 0, errors.New("unexpected end of data")
+		}
+		s := make(Slice, size)
+		for i := range s {
+			s[i], offset, err = d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+		}
+		return s, offset, nil
+	case typeNumBool:
+		if size > 1 {
+			return nil, 0, fmt.Errorf("invalid size for bool: %d", size)
+		}
+		return Bool(size == 1), offset, nil
+	default:
+	}
+
+	b, offset, err := d.readBytes(offset, size)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	switch tn {
+	case typeNumString:
+		return String(b), offset, nil
+	case typeNumBytes:
+		v := make(Bytes, len(b))
+		copy(v, b)
+		return v, offset, nil
+	case typeNumFloat64:
+		if size != 8 {
+			return nil, 0, fmt.Errorf("invalid size for float64: %d", size)
+		}
+		return Float64(math.Float64frombits(binary.BigEndian.Uint64(b))), offset, nil
+	case typeNumFloat32:
+		if size != 4 {
+			return nil, 0, fmt.Errorf("invalid size for float32: %d", size)
+		}
+		return Float32(math.Float32frombits(binary.BigEndian.Uint32(b))), offset, nil
+	case typeNumUint16:
+		if size > 2 {
+			return nil, 0, fmt.Errorf("invalid size for uint16: %d", size)
+		}
+		return Uint16(decodeUint(b)), offset, nil
+	case typeNumUint32:
+		if size > 4 {
+			return nil, 0, fmt.Errorf("invalid size for uint32: %d", size)
+		}
+		return Uint32(decodeUint(b)), offset, nil
+	case typeNumInt32:
+		if size > 4 {
+			return nil, 0, fmt.Errorf("invalid size for int32: %d", size)
+		}
+		return Int32(decodeUint(b)), offset, nil
+	case typeNumUint64:
+		if size > 8 {
+			return nil, 0, fmt.Errorf("invalid size for uint64: %d", size)
+		}
+		return Uint64(decodeUint(b)), offset, nil
+	case typeNumUint128:
+		if size > 16 {
+			return nil, 0, fmt.Errorf("invalid size for uint128: %d", size)
+		}
+		v := Uint128(*new(big.Int).SetBytes(b))
+		return &v, offset, nil
+	default:
+		return nil, 0, fmt.Errorf("unsupported data type: %d", tn)
+	}
+}
+
+func (d *decoder) decodePointer(ctrl byte, offset int) (int, int, error) {
+	pointerSize := int((ctrl>>3)&0x3) + 1
+	b, newOffset, err := d.readBytes(offset, pointerSize)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var prefix int
+	if pointerSize != 4 {
+		prefix = int(ctrl & 0x7)
+	}
+	pointer := prefix
+	for _, v := range b {
+		pointer = (pointer << 8) | int(v)
+	}
+
+	switch pointerSize {
+	case 2:
+		pointer += 2048
+	case 3:
+		pointer += 526336
+	default:
+	}
+	return pointer, newOffset, nil
+}
+
+func (d *decoder) sizeFromCtrlByte(ctrl byte, offset int) (int, int, error) {
+	size := int(ctrl & 0x1f)
+	if size < 29 {
+		return size, offset, nil
+	}
+
+	bytesToRead := size - 28
+	b, offset, err := d.readBytes(offset, bytesToRead)
+	if err != nil {
+		return 0, 0, err
+	}
+	v := int(decodeUint(b))
+	switch size {
+	case 29:
+		size = 29 + v
+	case 30:
+		size = 285 + v
+	default:
+		size = 65821 + v
+	}
+	return size, offset, nil
+}
+
+func (d *decoder) readBytes(offset, n int) ([]byte, int, error) {
+	if offset < 0 || n < 0 || offset > len(d.buf) || n > len(d.buf)-offset {
+		return nil, 0, errors.New("unexpected end of data")
+	}
+	return d.buf[offset : offset+n], offset + n, nil
+}
+
+func decodeUint(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = (v << 8) | uint64(c)
+	}
+	return v
+}
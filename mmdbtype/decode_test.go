@@ -0,0 +1,147 @@
+package mmdbtype
+
+import (
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	uint128 := Uint128(*big.NewInt(1 << 60))
+	tests := []DataType{
+		Bool(false),
+		Bool(true),
+		Bytes{0, 0, 0, 0x2a},
+		Float32(1.1),
+		Float64(-42.123456),
+		Int32(-268435456),
+		Int32(math.MaxInt32),
+		Map{},
+		Map{
+			"map":   Map{"nested": String("value")},
+			"slice": Slice{String("a"), Uint16(1)},
+		},
+		Slice{},
+		String(""),
+		String(string(make([]byte, 70000))),
+		Uint16(100),
+		Uint32(1 << 28),
+		Uint64(math.MaxUint64),
+		&uint128,
+	}
+
+	for _, v := range tests {
+		b, err := Encode(v)
+		require.NoError(t, err)
+
+		decoded, err := Decode(b)
+		require.NoError(t, err)
+		assert.True(t, v.Equal(decoded), "%#v", v)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	b, err := Encode(Map{"a": String("b")})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		b    []byte
+		err  string
+	}{
+		{name: "empty", b: nil, err: "unexpected end of data"},
+		{name: "truncated", b: b[:len(b)-1], err: "unexpected end of data"},
+		{
+			name: "trailing data",
+			b:    append(append([]byte(nil), b...), 0),
+			err:  "unexpected data after the value at offset 5",
+		},
+		{
+			name: "invalid map key",
+			b:    []byte{0xe1, 0xa1, 0x01, 0x41, 0x61},
+			err:  "unexpected map key type: mmdbtype.Uint16",
+		},
+		{
+			name: "pointer to pointer",
+			b:    []byte{0x20, 0x00},
+			err:  "pointer at offset 0 points to another pointer",
+		},
+		{
+			name: "invalid extended type",
+			b:    []byte{0x00, 0x00},
+			err:  "invalid extended type: 7",
+		},
+		{
+			name: "huge slice",
+			b:    []byte{0x1f, 0x04, 0xff, 0xff, 0xff},
+			err:  "unexpected end of data",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := Decode(test.b)
+			assert.EqualError(t, err, test.err)
+		})
+	}
+}
+
+func TestDecodeExpansion(t *testing.T) {
+	// Each slice contains two pointers to the next, doubling the number of
+	// values at each level.
+	var b []byte
+	for i := 0; i < 30; i++ {
+		next := len(b) + 6
+		b = append(
+			b,
+			0x02, 0x04,
+			0x20|byte(next>>8), byte(next),
+			0x20|byte(next>>8), byte(next),
+		)
+	}
+	b = append(b, 0x01, 0x07)
+
+	_, err := Decode(b)
+	assert.EqualError(t, err, "exceeded maximum number of values")
+}
+
+func TestDecodeAtWithPointer(t *testing.T) {
+	// A string followed by a pointer to it.
+	b := []byte{0x41, 0x61, 0x20, 0x00}
+	v, offset, err := DecodeAt(b, 2)
+	require.NoError(t, err)
+	assert.Equal(t, String("a"), v)
+	assert.Equal(t, 4, offset)
+}
+
+func FuzzDecode(f *testing.F) {
+	for _, v := range []DataType{
+		Map{"a": Slice{Uint32(1), Float64(1.5), Bool(true)}},
+		Bytes{1, 2},
+		Int32(-1),
+	} {
+		b, err := Encode(v)
+		require.NoError(f, err)
+		f.Add(b)
+	}
+
+	f.Fuzz(func(t *testing.T, b []byte) {
+		v, err := Decode(b)
+		if err != nil {
+			return
+		}
+
+		encoded, err := Encode(v)
+		require.NoError(t, err)
+
+		// We compare the encodings as NaN is not equal to itself.
+		decoded, err := Decode(encoded)
+		require.NoError(t, err)
+		reencoded, err := Encode(decoded)
+		require.NoError(t, err)
+		assert.Equal(t, encoded, reencoded)
+	})
+}
@@ -0,0 +1,68 @@
+package mmdbtype
+
+import (
+	"bytes"
+	"io"
+)
+
+// encodeWriter writes values without using pointers.
+type encodeWriter struct {
+	*bytes.Buffer
+}
+
+func (w encodeWriter) WriteOrWritePointer(t DataType) (int64, error) {
+	return t.WriteTo(w)
+}
+
+// Encode returns the value encoded in the MaxMind DB data format. Pointers
+// are never used. Decode is the inverse of Encode.
+func Encode(t DataType) ([]byte, error) {
+	w := encodeWriter{&bytes.Buffer{}}
+	if _, err := t.WriteTo(w); err != nil {
+		return nil, err
+	}
+	return w.Bytes(), nil
+}
+
+// Encoder writes values in the MaxMind DB data format to an io.Writer, e.g.,
+// to produce fragments of a data section for tests or patch files. As with
+// Encode, pointers are never used, so each value is self-contained.
+//
+// The zero value is ready to use. An Encoder reuses its buffer between
+// calls and must not be used concurrently.
+type Encoder struct {
+	buf bytes.Buffer
+}
+
+// streamWriter writes values directly to a writer that already supports
+// writing single bytes and strings, e.g., a *bufio.Writer.
+type streamWriter interface {
+	io.Writer
+	io.ByteWriter
+	io.StringWriter
+}
+
+type directWriter struct {
+	streamWriter
+}
+
+func (w directWriter) WriteOrWritePointer(t DataType) (int64, error) {
+	return t.WriteTo(w)
+}
+
+// Encode writes the encoded value to w and returns the number of bytes
+// written. If w implements io.ByteWriter and io.StringWriter, e.g., a
+// *bufio.Writer or *bytes.Buffer, the value is written to it directly.
+// Otherwise, it is encoded to a buffer first and written with a single
+// call to Write.
+func (e *Encoder) Encode(w io.Writer, v DataType) (int64, error) {
+	if sw, ok := w.(streamWriter); ok {
+		return v.WriteTo(directWriter{sw})
+	}
+
+	e.buf.Reset()
+	if _, err := v.WriteTo(encodeWriter{&e.buf}); err != nil {
+		return 0, err
+	}
+	return e.buf.WriteTo(w)
+}
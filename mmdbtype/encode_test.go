@@ -0,0 +1,81 @@
+package mmdbtype
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// plainWriter only implements io.Writer.
+type plainWriter struct {
+	io.Writer
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("write failed")
+}
+
+func TestEncoder(t *testing.T) {
+	values := []DataType{
+		Map{
+			"map":   Map{"nested": String("value")},
+			"slice": Slice{String("a"), Uint16(1)},
+		},
+		String("value"),
+		Uint32(1 << 28),
+	}
+
+	var expected []byte
+	for _, v := range values {
+		b, err := Encode(v)
+		require.NoError(t, err)
+		expected = append(expected, b...)
+	}
+
+	newWriters := map[string]func(*bytes.Buffer) io.Writer{
+		"bytes.Buffer": func(b *bytes.Buffer) io.Writer { return b },
+		"io.Writer":    func(b *bytes.Buffer) io.Writer { return plainWriter{b} },
+	}
+	for name, newWriter := range newWriters {
+		t.Run(name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			w := newWriter(buf)
+
+			var e Encoder
+			var total int64
+			for _, v := range values {
+				n, err := e.Encode(w, v)
+				require.NoError(t, err)
+				total += n
+			}
+			assert.Equal(t, expected, buf.Bytes())
+			assert.Equal(t, int64(len(expected)), total)
+		})
+	}
+
+	t.Run("bufio.Writer", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		w := bufio.NewWriter(buf)
+
+		var e Encoder
+		for _, v := range values {
+			_, err := e.Encode(w, v)
+			require.NoError(t, err)
+		}
+		require.NoError(t, w.Flush())
+		assert.Equal(t, expected, buf.Bytes())
+	})
+}
+
+func TestEncoderWriteError(t *testing.T) {
+	var e Encoder
+	_, err := e.Encode(failingWriter{}, String("value"))
+	assert.EqualError(t, err, "write failed")
+}
@@ -0,0 +1,49 @@
+package mmdbtype
+
+import (
+	"hash"
+	"hash/fnv"
+)
+
+// hashWriter writes the encoded value to a hash. It never uses pointers so
+// that equal values always produce the same hash.
+type hashWriter struct {
+	hash.Hash64
+	byteBuf [1]byte
+}
+
+func (w *hashWriter) WriteByte(b byte) error {
+	w.byteBuf[0] = b
+	_, err := w.Write(w.byteBuf[:])
+	return err
+}
+
+func (w *hashWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *hashWriter) WriteOrWritePointer(t DataType) (int64, error) {
+	// -0 and 0 are equal, but they have different encodings.
+	switch v := t.(type) {
+	case Float32:
+		if v == 0 {
+			t = Float32(0)
+		}
+	case Float64:
+		if v == 0 {
+			t = Float64(0)
+		}
+	default:
+	}
+	return t.WriteTo(w)
+}
+
+// hashValue returns the 64-bit FNV-1a hash of the encoded value.
+func hashValue(t DataType) uint64 {
+	w := &hashWriter{Hash64: fnv.New64a()}
+	// Writing to a hash never fails. The only possible error is for a value
+	// that is too large to encode, in which case we hash what was written.
+	//nolint:errcheck // see above
+	w.WriteOrWritePointer(t)
+	return w.Sum64()
+}
@@ -0,0 +1,520 @@
+package mmdbtype
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+)
+
+// The types in this package are represented in JSON as an object with the
+// name of the type and its value so that they may be round-tripped without
+// loss, e.g., Uint32(1) is {"type":"uint32","value":1}. The values are
+// represented as follows:
+//
+//	bool      a JSON boolean
+//	bytes     a base64 string
+//	float32   a JSON number, or "NaN", "+Inf", or "-Inf"
+//	float64   a JSON number, or "NaN", "+Inf", or "-Inf"
+//	int32     a JSON number
+//	map       a JSON object with the values in the same representation
+//	pointer   a JSON number
+//	slice     a JSON array with the values in the same representation
+//	string    a JSON string
+//	uint16    a JSON number
+//	uint32    a JSON number
+//	uint64    a decimal string
+//	uint128   a decimal string
+//
+// The 64- and 128-bit integers are represented as strings as many JSON
+// implementations cannot represent them as numbers without loss. Both
+// Uint128 and FixedUint128 use the "uint128" type. UnmarshalJSON returns a
+// *Uint128 for it, the same as Decode.
+//
+// A nil DataType is represented as null.
+
+const (
+	jsonTypeBool    = "bool"
+	jsonTypeBytes   = "bytes"
+	jsonTypeFloat32 = "float32"
+	jsonTypeFloat64 = "float64"
+	jsonTypeInt32   = "int32"
+	jsonTypeMap     = "map"
+	jsonTypePointer = "pointer"
+	jsonTypeSlice   = "slice"
+	jsonTypeString  = "string"
+	jsonTypeUint16  = "uint16"
+	jsonTypeUint32  = "uint32"
+	jsonTypeUint64  = "uint64"
+	jsonTypeUint128 = "uint128"
+)
+
+type jsonValue struct {
+	Type  string          `json:"type"`
+	Value json.RawMessage `json:"value"`
+}
+
+// UnmarshalJSON decodes a value in the JSON representation produced by the
+// MarshalJSON methods of the types in this package.
+func UnmarshalJSON(data []byte) (DataType, error) {
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		return nil, nil
+	}
+	jv, err := unmarshalJSONObject(data)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshaling MaxMind DB value: %w", err)
+	}
+
+	var v DataType
+	switch jv.Type {
+	case jsonTypeBool:
+		var t Bool
+		err = t.unmarshalJSONValue(jv.Value)
+		v = t
+	case jsonTypeBytes:
+		var t Bytes
+		err = t.unmarshalJSONValue(jv.Value)
+		v = t
+	case jsonTypeFloat32:
+		var t Float32
+		err = t.unmarshalJSONValue(jv.Value)
+		v = t
+	case jsonTypeFloat64:
+		var t Float64
+		err = t.unmarshalJSONValue(jv.Value)
+		v = t
+	case jsonTypeInt32:
+		var t Int32
+		err = t.unmarshalJSONValue(jv.Value)
+		v = t
+	case jsonTypeMap:
+		var t Map
+		err = t.unmarshalJSONValue(jv.Value)
+		v = t
+	case jsonTypePointer:
+		var t Pointer
+		err = t.unmarshalJSONValue(jv.Value)
+		v = t
+	case jsonTypeSlice:
+		var t Slice
+		err = t.unmarshalJSONValue(jv.Value)
+		v = t
+	case jsonTypeString:
+		var t String
+		err = t.unmarshalJSONValue(jv.Value)
+		v = t
+	case jsonTypeUint16:
+		var t Uint16
+		err = t.unmarshalJSONValue(jv.Value)
+		v = t
+	case jsonTypeUint32:
+		var t Uint32
+		err = t.unmarshalJSONValue(jv.Value)
+		v = t
+	case jsonTypeUint64:
+		var t Uint64
+		err = t.unmarshalJSONValue(jv.Value)
+		v = t
+	case jsonTypeUint128:
+		t := &Uint128{}
+		err = t.unmarshalJSONValue(jv.Value)
+		v = t
+	default:
+		return nil, fmt.Errorf("unmarshaling MaxMind DB value: unknown type %q", jv.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// unmarshalJSONObject unmarshals the object containing the type and value.
+func unmarshalJSONObject(data []byte) (jsonValue, error) {
+	var jv jsonValue
+	if err := json.Unmarshal(data, &jv); err != nil {
+		return jv, err
+	}
+	if len(jv.Value) == 0 || bytes.Equal(jv.Value, []byte("null")) {
+		return jv, errors.New("the value is missing")
+	}
+	return jv, nil
+}
+
+func marshalJSONValue(typ string, value any) ([]byte, error) {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(jsonValue{Type: typ, Value: raw})
+}
+
+// unmarshalJSONTyped unmarshals the JSON representation of a value of the
+// given type, calling unmarshalValue with the "value" member.
+func unmarshalJSONTyped(data []byte, typ string, unmarshalValue func(json.RawMessage) error) error {
+	jv, err := unmarshalJSONObject(data)
+	if err != nil {
+		return fmt.Errorf("unmarshaling %s: %w", typ, err)
+	}
+	if jv.Type != typ {
+		return fmt.Errorf("unmarshaling %s: the value has type %q", typ, jv.Type)
+	}
+	return unmarshalValue(jv.Value)
+}
+
+func unmarshalJSONPrimitive(typ string, raw json.RawMessage, v any) error {
+	if err := json.Unmarshal(raw, v); err != nil {
+		return fmt.Errorf("unmarshaling %s: %w", typ, err)
+	}
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (t Bool) MarshalJSON() ([]byte, error) {
+	return marshalJSONValue(jsonTypeBool, bool(t))
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (t *Bool) UnmarshalJSON(data []byte) error {
+	return unmarshalJSONTyped(data, jsonTypeBool, t.unmarshalJSONValue)
+}
+
+func (t *Bool) unmarshalJSONValue(raw json.RawMessage) error {
+	return unmarshalJSONPrimitive(jsonTypeBool, raw, (*bool)(t))
+}
+
+// MarshalJSON implements json.Marshaler.
+func (t Bytes) MarshalJSON() ([]byte, error) {
+	if t == nil {
+		// A nil slice would be marshaled as null.
+		t = Bytes{}
+	}
+	return marshalJSONValue(jsonTypeBytes, []byte(t))
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (t *Bytes) UnmarshalJSON(data []byte) error {
+	return unmarshalJSONTyped(data, jsonTypeBytes, t.unmarshalJSONValue)
+}
+
+func (t *Bytes) unmarshalJSONValue(raw json.RawMessage) error {
+	var b []byte
+	if err := unmarshalJSONPrimitive(jsonTypeBytes, raw, &b); err != nil {
+		return err
+	}
+	if b == nil {
+		b = []byte{}
+	}
+	*t = b
+	return nil
+}
+
+func marshalJSONFloat(typ string, f float64, bitSize int) ([]byte, error) {
+	switch {
+	case math.IsNaN(f):
+		return marshalJSONValue(typ, "NaN")
+	case math.IsInf(f, 1):
+		return marshalJSONValue(typ, "+Inf")
+	case math.IsInf(f, -1):
+		return marshalJSONValue(typ, "-Inf")
+	default:
+		return marshalJSONValue(typ, json.Number(strconv.FormatFloat(f, 'g', -1, bitSize)))
+	}
+}
+
+func unmarshalJSONFloat(typ string, raw json.RawMessage, bitSize int) (float64, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		switch s {
+		case "NaN":
+			return math.NaN(), nil
+		case "+Inf":
+			return math.Inf(1), nil
+		case "-Inf":
+			return math.Inf(-1), nil
+		default:
+			return 0, fmt.Errorf("unmarshaling %s: invalid value %q", typ, s)
+		}
+	}
+	var n json.Number
+	if err := json.Unmarshal(raw, &n); err != nil {
+		return 0, fmt.Errorf("unmarshaling %s: %w", typ, err)
+	}
+	f, err := strconv.ParseFloat(string(n), bitSize)
+	if err != nil {
+		return 0, fmt.Errorf("unmarshaling %s: %w", typ, err)
+	}
+	return f, nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (t Float32) MarshalJSON() ([]byte, error) {
+	return marshalJSONFloat(jsonTypeFloat32, float64(t), 32)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (t *Float32) UnmarshalJSON(data []byte) error {
+	return unmarshalJSONTyped(data, jsonTypeFloat32, t.unmarshalJSONValue)
+}
+
+func (t *Float32) unmarshalJSONValue(raw json.RawMessage) error {
+	f, err := unmarshalJSONFloat(jsonTypeFloat32, raw, 32)
+	if err != nil {
+		return err
+	}
+	*t = Float32(f)
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (t Float64) MarshalJSON() ([]byte, error) {
+	return marshalJSONFloat(jsonTypeFloat64, float64(t), 64)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (t *Float64) UnmarshalJSON(data []byte) error {
+	return unmarshalJSONTyped(data, jsonTypeFloat64, t.unmarshalJSONValue)
+}
+
+func (t *Float64) unmarshalJSONValue(raw json.RawMessage) error {
+	f, err := unmarshalJSONFloat(jsonTypeFloat64, raw, 64)
+	if err != nil {
+		return err
+	}
+	*t = Float64(f)
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (t Int32) MarshalJSON() ([]byte, error) {
+	return marshalJSONValue(jsonTypeInt32, int32(t))
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (t *Int32) UnmarshalJSON(data []byte) error {
+	return unmarshalJSONTyped(data, jsonTypeInt32, t.unmarshalJSONValue)
+}
+
+func (t *Int32) unmarshalJSONValue(raw json.RawMessage) error {
+	return unmarshalJSONPrimitive(jsonTypeInt32, raw, (*int32)(t))
+}
+
+// MarshalJSON implements json.Marshaler.
+func (t Map) MarshalJSON() ([]byte, error) {
+	m := make(map[string]json.RawMessage, len(t))
+	for k, v := range t {
+		raw, err := marshalJSONElement(v)
+		if err != nil {
+			return nil, err
+		}
+		m[string(k)] = raw
+	}
+	return marshalJSONValue(jsonTypeMap, m)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (t *Map) UnmarshalJSON(data []byte) error {
+	return unmarshalJSONTyped(data, jsonTypeMap, t.unmarshalJSONValue)
+}
+
+func (t *Map) unmarshalJSONValue(raw json.RawMessage) error {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &m); err != nil {
+		return fmt.Errorf("unmarshaling map: %w", err)
+	}
+	rv := make(Map, len(m))
+	for k, e := range m {
+		v, err := UnmarshalJSON(e)
+		if err != nil {
+			return fmt.Errorf("unmarshaling map key %q: %w", k, err)
+		}
+		rv[String(k)] = v
+	}
+	*t = rv
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (t Pointer) MarshalJSON() ([]byte, error) {
+	return marshalJSONValue(jsonTypePointer, uint32(t))
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (t *Pointer) UnmarshalJSON(data []byte) error {
+	return unmarshalJSONTyped(data, jsonTypePointer, t.unmarshalJSONValue)
+}
+
+func (t *Pointer) unmarshalJSONValue(raw json.RawMessage) error {
+	return unmarshalJSONPrimitive(jsonTypePointer, raw, (*uint32)(t))
+}
+
+// MarshalJSON implements json.Marshaler.
+func (t Slice) MarshalJSON() ([]byte, error) {
+	s := make([]json.RawMessage, len(t))
+	for i, v := range t {
+		raw, err := marshalJSONElement(v)
+		if err != nil {
+			return nil, err
+		}
+		s[i] = raw
+	}
+	return marshalJSONValue(jsonTypeSlice, s)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (t *Slice) UnmarshalJSON(data []byte) error {
+	return unmarshalJSONTyped(data, jsonTypeSlice, t.unmarshalJSONValue)
+}
+
+func (t *Slice) unmarshalJSONValue(raw json.RawMessage) error {
+	var s []json.RawMessage
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return fmt.Errorf("unmarshaling slice: %w", err)
+	}
+	rv := make(Slice, len(s))
+	for i, e := range s {
+		v, err := UnmarshalJSON(e)
+		if err != nil {
+			return fmt.Errorf("unmarshaling slice index %d: %w", i, err)
+		}
+		rv[i] = v
+	}
+	*t = rv
+	return nil
+}
+
+// marshalJSONElement marshals an element of a Map or Slice.
+func marshalJSONElement(v DataType) (json.RawMessage, error) {
+	if v == nil {
+		return json.RawMessage("null"), nil
+	}
+	m, ok := v.(json.Marshaler)
+	if !ok {
+		return nil, fmt.Errorf("marshaling %T to JSON is not supported", v)
+	}
+	return m.MarshalJSON()
+}
+
+// MarshalJSON implements json.Marshaler.
+func (t String) MarshalJSON() ([]byte, error) {
+	return marshalJSONValue(jsonTypeString, string(t))
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (t *String) UnmarshalJSON(data []byte) error {
+	return unmarshalJSONTyped(data, jsonTypeString, t.unmarshalJSONValue)
+}
+
+func (t *String) unmarshalJSONValue(raw json.RawMessage) error {
+	return unmarshalJSONPrimitive(jsonTypeString, raw, (*string)(t))
+}
+
+// MarshalJSON implements json.Marshaler. The value is represented as the
+// composed Map, so it is unmarshaled as a Map.
+func (t Templated) MarshalJSON() ([]byte, error) {
+	return t.Map().MarshalJSON()
+}
+
+// MarshalJSON implements json.Marshaler.
+func (t Uint16) MarshalJSON() ([]byte, error) {
+	return marshalJSONValue(jsonTypeUint16, uint16(t))
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (t *Uint16) UnmarshalJSON(data []byte) error {
+	return unmarshalJSONTyped(data, jsonTypeUint16, t.unmarshalJSONValue)
+}
+
+func (t *Uint16) unmarshalJSONValue(raw json.RawMessage) error {
+	return unmarshalJSONPrimitive(jsonTypeUint16, raw, (*uint16)(t))
+}
+
+// MarshalJSON implements json.Marshaler.
+func (t Uint32) MarshalJSON() ([]byte, error) {
+	return marshalJSONValue(jsonTypeUint32, uint32(t))
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (t *Uint32) UnmarshalJSON(data []byte) error {
+	return unmarshalJSONTyped(data, jsonTypeUint32, t.unmarshalJSONValue)
+}
+
+func (t *Uint32) unmarshalJSONValue(raw json.RawMessage) error {
+	return unmarshalJSONPrimitive(jsonTypeUint32, raw, (*uint32)(t))
+}
+
+// MarshalJSON implements json.Marshaler.
+func (t Uint64) MarshalJSON() ([]byte, error) {
+	return marshalJSONValue(jsonTypeUint64, strconv.FormatUint(uint64(t), 10))
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (t *Uint64) UnmarshalJSON(data []byte) error {
+	return unmarshalJSONTyped(data, jsonTypeUint64, t.unmarshalJSONValue)
+}
+
+func (t *Uint64) unmarshalJSONValue(raw json.RawMessage) error {
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return fmt.Errorf("unmarshaling uint64: %w", err)
+	}
+	v, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("unmarshaling uint64: %w", err)
+	}
+	*t = Uint64(v)
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (t *Uint128) MarshalJSON() ([]byte, error) {
+	return marshalJSONValue(jsonTypeUint128, (*big.Int)(t).String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (t *Uint128) UnmarshalJSON(data []byte) error {
+	return unmarshalJSONTyped(data, jsonTypeUint128, t.unmarshalJSONValue)
+}
+
+func (t *Uint128) unmarshalJSONValue(raw json.RawMessage) error {
+	v, err := unmarshalJSONUint128(raw)
+	if err != nil {
+		return err
+	}
+	(*big.Int)(t).Set(v)
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (t FixedUint128) MarshalJSON() ([]byte, error) {
+	return marshalJSONValue(jsonTypeUint128, t.BigInt().String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (t *FixedUint128) UnmarshalJSON(data []byte) error {
+	return unmarshalJSONTyped(data, jsonTypeUint128, func(raw json.RawMessage) error {
+		v, err := unmarshalJSONUint128(raw)
+		if err != nil {
+			return err
+		}
+		*t, err = Uint128FromBigInt(v)
+		return err
+	})
+}
+
+func unmarshalJSONUint128(raw json.RawMessage) (*big.Int, error) {
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return nil, fmt.Errorf("unmarshaling uint128: %w", err)
+	}
+	v, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return nil, fmt.Errorf("unmarshaling uint128: invalid value %q", s)
+	}
+	if v.Sign() < 0 || v.BitLen() > 128 {
+		return nil, fmt.Errorf("unmarshaling uint128: %s does not fit in a uint128", s)
+	}
+	return v, nil
+}
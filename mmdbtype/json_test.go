@@ -0,0 +1,157 @@
+package mmdbtype
+
+import (
+	"encoding/json"
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSON(t *testing.T) {
+	maxUint128 := Uint128(*new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 128), big.NewInt(1)))
+
+	tests := []struct {
+		value    DataType
+		expected string
+	}{
+		{value: Bool(true), expected: `{"type":"bool","value":true}`},
+		{value: Bytes{1, 2, 3}, expected: `{"type":"bytes","value":"AQID"}`},
+		{value: Bytes{}, expected: `{"type":"bytes","value":""}`},
+		{value: Float32(1.1), expected: `{"type":"float32","value":1.1}`},
+		{value: Float32(math.Inf(-1)), expected: `{"type":"float32","value":"-Inf"}`},
+		{value: Float64(0.1), expected: `{"type":"float64","value":0.1}`},
+		{value: Float64(1e300), expected: `{"type":"float64","value":1e+300}`},
+		{value: Float64(math.Inf(1)), expected: `{"type":"float64","value":"+Inf"}`},
+		{value: Int32(-5), expected: `{"type":"int32","value":-5}`},
+		{value: Pointer(10), expected: `{"type":"pointer","value":10}`},
+		{value: String("a"), expected: `{"type":"string","value":"a"}`},
+		{value: Uint16(1), expected: `{"type":"uint16","value":1}`},
+		{value: Uint32(1), expected: `{"type":"uint32","value":1}`},
+		{
+			value:    Uint64(math.MaxUint64),
+			expected: `{"type":"uint64","value":"18446744073709551615"}`,
+		},
+		{
+			value:    &maxUint128,
+			expected: `{"type":"uint128","value":"340282366920938463463374607431768211455"}`,
+		},
+		{
+			value: Map{
+				"b": Slice{Uint32(1), Int32(1)},
+				"a": Map{},
+			},
+			expected: `{"type":"map","value":{` +
+				`"a":{"type":"map","value":{}},` +
+				`"b":{"type":"slice","value":[{"type":"uint32","value":1},{"type":"int32","value":1}]}}}`,
+		},
+		{value: Slice{}, expected: `{"type":"slice","value":[]}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expected, func(t *testing.T) {
+			b, err := json.Marshal(test.value)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, string(b))
+
+			v, err := UnmarshalJSON(b)
+			require.NoError(t, err)
+			assert.True(t, test.value.Equal(v), "round trip: %#v", v)
+		})
+	}
+}
+
+func TestJSONNaN(t *testing.T) {
+	b, err := json.Marshal(Float64(math.NaN()))
+	require.NoError(t, err)
+	assert.Equal(t, `{"type":"float64","value":"NaN"}`, string(b))
+
+	v, err := UnmarshalJSON(b)
+	require.NoError(t, err)
+	assert.True(t, math.IsNaN(float64(v.(Float64))))
+}
+
+func TestJSONConcreteTypes(t *testing.T) {
+	var m Map
+	require.NoError(t, json.Unmarshal(
+		[]byte(`{"type":"map","value":{"a":{"type":"uint16","value":2}}}`),
+		&m,
+	))
+	assert.Equal(t, Map{"a": Uint16(2)}, m)
+
+	fixed := Uint128FromUint64(7)
+	b, err := json.Marshal(fixed)
+	require.NoError(t, err)
+	assert.Equal(t, `{"type":"uint128","value":"7"}`, string(b))
+
+	var decodedFixed FixedUint128
+	require.NoError(t, json.Unmarshal(b, &decodedFixed))
+	assert.Equal(t, fixed, decodedFixed)
+
+	var u Uint32
+	err = json.Unmarshal([]byte(`{"type":"uint16","value":2}`), &u)
+	assert.EqualError(t, err, `unmarshaling uint32: the value has type "uint16"`)
+
+	v, err := UnmarshalJSON([]byte("null"))
+	require.NoError(t, err)
+	assert.Nil(t, v)
+}
+
+func TestJSONErrors(t *testing.T) {
+	tests := []struct {
+		json        string
+		expectedErr string
+	}{
+		{
+			json:        `{"type":"uint8","value":1}`,
+			expectedErr: `unmarshaling MaxMind DB value: unknown type "uint8"`,
+		},
+		{
+			json:        `{"type":"uint16"}`,
+			expectedErr: `unmarshaling MaxMind DB value: the value is missing`,
+		},
+		{
+			json:        `{"type":"uint16","value":65536}`,
+			expectedErr: `unmarshaling uint16: json: cannot unmarshal number 65536 into Go value of type uint16`,
+		},
+		{
+			json:        `{"type":"int32","value":1.5}`,
+			expectedErr: `unmarshaling int32: json: cannot unmarshal number 1.5 into Go value of type int32`,
+		},
+		{
+			json:        `{"type":"float32","value":1e39}`,
+			expectedErr: `unmarshaling float32: strconv.ParseFloat: parsing "1e39": value out of range`,
+		},
+		{
+			json:        `{"type":"float64","value":"Infinity"}`,
+			expectedErr: `unmarshaling float64: invalid value "Infinity"`,
+		},
+		{
+			json:        `{"type":"uint64","value":"-1"}`,
+			expectedErr: `unmarshaling uint64: strconv.ParseUint: parsing "-1": invalid syntax`,
+		},
+		{
+			json:        `{"type":"uint128","value":"340282366920938463463374607431768211456"}`,
+			expectedErr: `unmarshaling uint128: 340282366920938463463374607431768211456 does not fit in a uint128`,
+		},
+		{
+			json: `{"type":"map","value":{"a":{"type":"bool","value":1}}}`,
+			expectedErr: `unmarshaling map key "a": ` +
+				`unmarshaling bool: json: cannot unmarshal number into Go value of type bool`,
+		},
+		{
+			json: `{"type":"slice","value":[{"type":"bool"}]}`,
+			expectedErr: `unmarshaling slice index 0: ` +
+				`unmarshaling MaxMind DB value: the value is missing`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.json, func(t *testing.T) {
+			_, err := UnmarshalJSON([]byte(test.json))
+			assert.EqualError(t, err, test.expectedErr)
+		})
+	}
+}
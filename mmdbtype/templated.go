@@ -0,0 +1,77 @@
+package mmdbtype
+
+// Templated is a Map composed of a template shared between many records and
+// the overrides of a single record, e.g., a city record where only the
+// "location" differs between networks. The Map is only composed when the
+// value is encoded, so storing millions of nearly identical records does not
+// require a Map for each of them.
+//
+// The composed Map has the entries of the Template with the top-level keys
+// of the Overrides replaced. Nested maps are not merged; an override of
+// "location" replaces the whole "location" map of the template.
+//
+// A Templated value is encoded as a Map and decoding it returns a Map. The
+// Template must not be modified after the value is inserted into a tree.
+type Templated struct {
+	Template  Map
+	Overrides Map
+}
+
+var _ DataType = Templated{}
+
+// Map returns the composed Map. The values are shared with the Template and
+// Overrides rather than copied.
+func (t Templated) Map() Map {
+	m := make(Map, len(t.Template)+len(t.Overrides))
+	for k, v := range t.Template {
+		m[k] = v
+	}
+	for k, v := range t.Overrides {
+		m[k] = v
+	}
+	return m
+}
+
+// Copy makes a deep copy of the Overrides. Unlike with the other types, the
+// Template is not copied so that the copies continue to share it.
+func (t Templated) Copy() DataType {
+	var overrides Map
+	if t.Overrides != nil {
+		overrides = t.Overrides.Copy().(Map)
+	}
+	return Templated{Template: t.Template, Overrides: overrides}
+}
+
+// Equal checks whether other is a Templated with the same composed Map.
+func (t Templated) Equal(other DataType) bool {
+	otherT, ok := other.(Templated)
+	if !ok {
+		return false
+	}
+	return t.Map().Equal(otherT.Map())
+}
+
+// Hash returns a stable hash of the value. It is the same as the hash of
+// the composed Map.
+func (t Templated) Hash() uint64 {
+	return hashValue(t)
+}
+
+func (t Templated) size() int {
+	size := len(t.Template)
+	for k := range t.Overrides {
+		if _, ok := t.Template[k]; !ok {
+			size++
+		}
+	}
+	return size
+}
+
+func (t Templated) typeNum() typeNum {
+	return typeNumMap
+}
+
+// WriteTo writes the composed Map to w.
+func (t Templated) WriteTo(w writer) (int64, error) {
+	return t.Map().WriteTo(w)
+}
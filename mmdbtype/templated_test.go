@@ -0,0 +1,55 @@
+package mmdbtype
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplated(t *testing.T) {
+	template := Map{
+		"city":     Map{"names": Map{"en": String("Berlin")}},
+		"location": Map{"latitude": Float64(52.5), "longitude": Float64(13.4)},
+	}
+	v := Templated{
+		Template:  template,
+		Overrides: Map{"location": Map{"latitude": Float64(52.6), "longitude": Float64(13.3)}},
+	}
+	composed := Map{
+		"city":     Map{"names": Map{"en": String("Berlin")}},
+		"location": Map{"latitude": Float64(52.6), "longitude": Float64(13.3)},
+	}
+
+	assert.Equal(t, composed, v.Map())
+	assert.Equal(t, composed.Hash(), v.Hash())
+	assert.Equal(t, composed.size(), v.size())
+
+	want, err := Encode(composed)
+	require.NoError(t, err)
+	got, err := Encode(v)
+	require.NoError(t, err)
+	assert.Equal(t, want, got, "encoded as the composed map")
+
+	decoded, err := Decode(got)
+	require.NoError(t, err)
+	assert.Equal(t, composed, decoded)
+
+	assert.True(t, v.Equal(Templated{Template: composed}))
+	assert.False(t, v.Equal(Templated{Template: template}))
+	assert.False(t, v.Equal(composed), "only equal to Templated values")
+
+	c := v.Copy().(Templated)
+	assert.True(t, v.Equal(c))
+	c.Overrides["extra"] = String("x")
+	assert.NotContains(t, v.Overrides, String("extra"), "overrides are copied")
+	assert.Equal(t, template, c.Template)
+
+	anyV, err := ToAny(v)
+	require.NoError(t, err)
+	anyComposed, err := ToAny(composed)
+	require.NoError(t, err)
+	assert.Equal(t, anyComposed, anyV)
+
+	assert.Equal(t, 3, Templated{Template: Map{"a": Bool(true)}, Overrides: Map{"b": Bool(true), "c": Bool(true)}}.size())
+}
@@ -46,6 +46,9 @@ type writer interface {
 type DataType interface {
 	Copy() DataType
 	Equal(DataType) bool
+	// Hash returns a hash of the value that is stable across processes and
+	// releases of this package. Values that are Equal have the same hash.
+	Hash() uint64
 	size() int
 	typeNum() typeNum
 	WriteTo(writer) (int64, error)
@@ -65,6 +68,11 @@ func (t Bool) Equal(other DataType) bool {
 	return ok && t == otherT
 }
 
+// Hash returns a stable hash of the value.
+func (t Bool) Hash() uint64 {
+	return hashValue(t)
+}
+
 func (t Bool) size() int {
 	if t {
 		return 1
@@ -103,6 +111,11 @@ func (t Bytes) Equal(other DataType) bool {
 	return bytes.Equal(t, otherT)
 }
 
+// Hash returns a stable hash of the value.
+func (t Bytes) Hash() uint64 {
+	return hashValue(t)
+}
+
 func (t Bytes) size() int {
 	return len(t)
 }
@@ -140,6 +153,11 @@ func (t Float32) Equal(other DataType) bool {
 	return ok && t == otherT
 }
 
+// Hash returns a stable hash of the value.
+func (t Float32) Hash() uint64 {
+	return hashValue(t)
+}
+
 func (t Float32) size() int {
 	return 4
 }
@@ -176,6 +194,11 @@ func (t Float64) Equal(other DataType) bool {
 	return ok && t == otherT
 }
 
+// Hash returns a stable hash of the value.
+func (t Float64) Hash() uint64 {
+	return hashValue(t)
+}
+
 func (t Float64) size() int {
 	return 8
 }
@@ -212,6 +235,11 @@ func (t Int32) Equal(other DataType) bool {
 	return ok && t == otherT
 }
 
+// Hash returns a stable hash of the value.
+func (t Int32) Hash() uint64 {
+	return hashValue(t)
+}
+
 func (t Int32) size() int {
 	return 4 - bits.LeadingZeros32(uint32(t))/8
 }
@@ -238,9 +266,21 @@ func (t Int32) WriteTo(w writer) (int64, error) {
 	return numBytes + int64(size), nil
 }
 
-// Map is the MaxMind DB map type.
+// Map is the MaxMind DB map type. The entries are encoded in the order of
+// their keys so that equal maps are always encoded as the same bytes. The
+// writer may change the order by implementing KeyOrderer.
 type Map map[String]DataType
 
+// KeyOrderer may be implemented by the writer passed to WriteTo to change
+// the order in which the entries of a Map are encoded, e.g., to put the
+// fields that are most likely to be accessed first. OrderKeys is passed
+// the keys of the map in sorted order and reorders them in place. The new
+// order must only depend on the keys so that equal maps are still encoded
+// as the same bytes.
+type KeyOrderer interface {
+	OrderKeys(keys []string)
+}
+
 var _ DataType = Map(nil)
 
 // Copy makes a deep copy of the Map.
@@ -275,6 +315,11 @@ func (t Map) Equal(other DataType) bool {
 	return true
 }
 
+// Hash returns a stable hash of the value.
+func (t Map) Hash() uint64 {
+	return hashValue(t)
+}
+
 func (t Map) size() int {
 	return len(t)
 }
@@ -291,14 +336,16 @@ func (t Map) WriteTo(w writer) (int64, error) {
 	}
 
 	// We want database builds to be reproducible. As such, we insert
-	// the map items in order by key value. In the future, we will
-	// likely use a more relevant characteristic here (e.g., putting
-	// fields more likely to be accessed first).
+	// the map items in order by key value unless the writer provides
+	// another order.
 	keys := make([]string, 0, len(t))
 	for k := range t {
 		keys = append(keys, string(k))
 	}
 	sort.Strings(keys)
+	if o, ok := w.(KeyOrderer); ok {
+		o.OrderKeys(keys)
+	}
 
 	for _, ks := range keys {
 		k := String(ks)
@@ -332,6 +379,11 @@ func (t Pointer) Equal(other DataType) bool {
 	return ok && t == otherT
 }
 
+// Hash returns a stable hash of the value.
+func (t Pointer) Hash() uint64 {
+	return hashValue(t)
+}
+
 const (
 	pointerMaxSize0 = 1 << 11
 	pointerMaxSize1 = pointerMaxSize0 + (1 << 19)
@@ -468,6 +520,11 @@ func (t Slice) Equal(other DataType) bool {
 	return true
 }
 
+// Hash returns a stable hash of the value.
+func (t Slice) Hash() uint64 {
+	return hashValue(t)
+}
+
 func (t Slice) size() int {
 	return len(t)
 }
@@ -507,6 +564,11 @@ func (t String) Equal(other DataType) bool {
 	return ok && t == otherT
 }
 
+// Hash returns a stable hash of the value.
+func (t String) Hash() uint64 {
+	return hashValue(t)
+}
+
 func (t String) size() int {
 	return len(t)
 }
@@ -544,6 +606,11 @@ func (t Uint16) Equal(other DataType) bool {
 	return ok && t == otherT
 }
 
+// Hash returns a stable hash of the value.
+func (t Uint16) Hash() uint64 {
+	return hashValue(t)
+}
+
 func (t Uint16) size() int {
 	return 2 - bits.LeadingZeros16(uint16(t))/8
 }
@@ -581,6 +648,11 @@ func (t Uint32) Equal(other DataType) bool {
 	return ok && t == otherT
 }
 
+// Hash returns a stable hash of the value.
+func (t Uint32) Hash() uint64 {
+	return hashValue(t)
+}
+
 // Copy the value.
 func (t Uint32) Copy() DataType { return t }
 
@@ -624,6 +696,11 @@ func (t Uint64) Equal(other DataType) bool {
 	return ok && t == otherT
 }
 
+// Hash returns a stable hash of the value.
+func (t Uint64) Hash() uint64 {
+	return hashValue(t)
+}
+
 func (t Uint64) size() int {
 	return 8 - bits.LeadingZeros64(uint64(t))/8
 }
@@ -664,12 +741,21 @@ func (t *Uint128) Copy() DataType {
 	return &uv
 }
 
-// Equal checks for equality.
+// Equal checks for equality. A FixedUint128 with the same value is equal,
+// as it is encoded identically.
 func (t *Uint128) Equal(other DataType) bool {
+	if otherT, ok := other.(FixedUint128); ok {
+		return otherT.Equal(t)
+	}
 	otherT, ok := other.(*Uint128)
 	return ok && (*big.Int)(t).Cmp((*big.Int)(otherT)) == 0
 }
 
+// Hash returns a stable hash of the value.
+func (t *Uint128) Hash() uint64 {
+	return hashValue(t)
+}
+
 func (t *Uint128) size() int {
 	// We add 7 here as we want the ceiling of the division operation rather
 	// than the floor.
@@ -3,6 +3,7 @@ package mmdbtype
 import (
 	"bytes"
 	"encoding/hex"
+	"math"
 	"math/big"
 	"strings"
 	"testing"
@@ -88,6 +89,23 @@ func TestMap(t *testing.T) {
 	validateEncoding(t, maps)
 }
 
+type reversingWriter struct {
+	dataWriter
+}
+
+func (w *reversingWriter) OrderKeys(keys []string) {
+	for i, j := 0, len(keys)-1; i < j; i, j = i+1, j-1 {
+		keys[i], keys[j] = keys[j], keys[i]
+	}
+}
+
+func TestMapKeyOrderer(t *testing.T) {
+	w := &reversingWriter{dataWriter{&bytes.Buffer{}}}
+	_, err := Map{"en": String("Foo"), "zh": String("人")}.WriteTo(w)
+	require.NoError(t, err)
+	assert.Equal(t, "e2427a6843e4baba42656e43466f6f", hex.EncodeToString(w.Bytes()))
+}
+
 func TestPointers(t *testing.T) {
 	pointers := map[string]DataType{
 		"2000":       Pointer(0),
@@ -471,3 +489,75 @@ type dataWriter struct {
 func (dw *dataWriter) WriteOrWritePointer(t DataType) (int64, error) {
 	return t.WriteTo(dw)
 }
+
+func TestHash(t *testing.T) {
+	tests := []struct {
+		name   string
+		a      DataType
+		b      DataType
+		expect bool
+	}{
+		{
+			name:   "same strings",
+			a:      String("a"),
+			b:      String("a"),
+			expect: true,
+		},
+		{
+			name:   "different strings",
+			a:      String("a"),
+			b:      String("b"),
+			expect: false,
+		},
+		{
+			name:   "Int32 and Uint32 with same value",
+			a:      Int32(1),
+			b:      Uint32(1),
+			expect: false,
+		},
+		{
+			name:   "negative and positive zero",
+			a:      Map{"a": Slice{Float64(0), Float32(0)}},
+			b:      Map{"a": Slice{Float64(math.Copysign(0, -1)), Float32(math.Copysign(0, -1))}},
+			expect: true,
+		},
+		{
+			name: "maps with same values",
+			a: Map{
+				"a": String("a"),
+				"b": Slice{Uint16(1), Bool(true)},
+				"c": (*Uint128)(big.NewInt(1)),
+			},
+			b: Map{
+				"c": (*Uint128)(big.NewInt(1)),
+				"b": Slice{Uint16(1), Bool(true)},
+				"a": String("a"),
+			},
+			expect: true,
+		},
+		{
+			name:   "maps with different values",
+			a:      Map{"a": String("a")},
+			b:      Map{"a": String("b")},
+			expect: false,
+		},
+		{
+			name:   "slices in different order",
+			a:      Slice{Uint64(1), Uint64(2)},
+			b:      Slice{Uint64(2), Uint64(1)},
+			expect: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expect, test.a.Hash() == test.b.Hash())
+			if test.expect {
+				assert.True(t, test.a.Equal(test.b), "equal values have equal hashes")
+			}
+		})
+	}
+
+	// The hash must be stable across releases.
+	assert.Equal(t, uint64(0x526b0fc8755e11de), Map{"en": String("Foo")}.Hash())
+}
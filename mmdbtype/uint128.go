@@ -0,0 +1,194 @@
+package mmdbtype
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"math/bits"
+	"net"
+	"strings"
+)
+
+// FixedUint128 is the MaxMind DB unsigned 128-bit integer type stored as a
+// fixed-size, big-endian array. Unlike Uint128, it does not require any
+// allocations and may be compared with ==. It is encoded identically to
+// Uint128.
+type FixedUint128 [16]byte
+
+var _ DataType = FixedUint128{}
+
+// Uint128FromUint64 returns a FixedUint128 with the value of v.
+func Uint128FromUint64(v uint64) FixedUint128 {
+	var t FixedUint128
+	binary.BigEndian.PutUint64(t[8:], v)
+	return t
+}
+
+// Uint128FromHex parses a hexadecimal string, optionally prefixed by "0x",
+// into a FixedUint128. An error is returned if the string is empty, is not
+// valid hexadecimal, or has more than 32 digits.
+func Uint128FromHex(s string) (FixedUint128, error) {
+	var t FixedUint128
+
+	digits := strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+	if digits == "" {
+		return t, fmt.Errorf("parsing %q as a uint128: no digits", s)
+	}
+	if len(digits) > 32 {
+		return t, fmt.Errorf("parsing %q as a uint128: value is too large", s)
+	}
+	if len(digits)%2 == 1 {
+		digits = "0" + digits
+	}
+
+	b, err := hex.DecodeString(digits)
+	if err != nil {
+		return t, fmt.Errorf("parsing %q as a uint128: %w", s, err)
+	}
+	copy(t[len(t)-len(b):], b)
+	return t, nil
+}
+
+// Uint128FromIP returns the integer value of the IP address. IPv4 addresses,
+// including those in their 16-byte IPv4-mapped form, are converted to their
+// 32-bit value.
+func Uint128FromIP(ip net.IP) (FixedUint128, error) {
+	var t FixedUint128
+	if ipv4 := ip.To4(); ipv4 != nil {
+		copy(t[12:], ipv4)
+		return t, nil
+	}
+	if len(ip) != net.IPv6len {
+		return t, errors.New("invalid IP address")
+	}
+	copy(t[:], ip)
+	return t, nil
+}
+
+// Uint128FromBigInt converts a big.Int to a FixedUint128. An error is
+// returned if the value is negative or does not fit in 128 bits.
+func Uint128FromBigInt(v *big.Int) (FixedUint128, error) {
+	var t FixedUint128
+	if v.Sign() < 0 || v.BitLen() > 128 {
+		return t, fmt.Errorf("%s does not fit in a uint128", v)
+	}
+	v.FillBytes(t[:])
+	return t, nil
+}
+
+// Copy the value.
+func (t FixedUint128) Copy() DataType { return t }
+
+// Equal checks for equality. A Uint128 with the same value is equal, as it
+// is encoded identically.
+func (t FixedUint128) Equal(other DataType) bool {
+	switch other := other.(type) {
+	case FixedUint128:
+		return t == other
+	case *Uint128:
+		return other != nil && t.BigInt().Cmp((*big.Int)(other)) == 0
+	default:
+		return false
+	}
+}
+
+// Hash returns a stable hash of the value.
+func (t FixedUint128) Hash() uint64 { return hashValue(t) }
+
+// Cmp compares t and other. It returns -1 if t is less than other, 0 if they
+// are equal, and 1 if t is greater than other.
+func (t FixedUint128) Cmp(other FixedUint128) int {
+	tHi, tLo := t.halves()
+	oHi, oLo := other.halves()
+	switch {
+	case tHi < oHi, tHi == oHi && tLo < oLo:
+		return -1
+	case tHi == oHi && tLo == oLo:
+		return 0
+	default:
+		return 1
+	}
+}
+
+// IsZero returns true if the value is zero.
+func (t FixedUint128) IsZero() bool {
+	return t == FixedUint128{}
+}
+
+// Add returns t + other. The carry is 1 if the addition overflowed and 0
+// otherwise.
+func (t FixedUint128) Add(other FixedUint128) (sum FixedUint128, carry uint64) {
+	tHi, tLo := t.halves()
+	oHi, oLo := other.halves()
+	lo, c := bits.Add64(tLo, oLo, 0)
+	hi, carry := bits.Add64(tHi, oHi, c)
+	return fromHalves(hi, lo), carry
+}
+
+// Sub returns t - other. The borrow is 1 if the subtraction underflowed and
+// 0 otherwise.
+func (t FixedUint128) Sub(other FixedUint128) (diff FixedUint128, borrow uint64) {
+	tHi, tLo := t.halves()
+	oHi, oLo := other.halves()
+	lo, b := bits.Sub64(tLo, oLo, 0)
+	hi, borrow := bits.Sub64(tHi, oHi, b)
+	return fromHalves(hi, lo), borrow
+}
+
+// BigInt returns the value as a new big.Int.
+func (t FixedUint128) BigInt() *big.Int {
+	return new(big.Int).SetBytes(t[:])
+}
+
+// IP returns the value as a 16-byte IP address.
+func (t FixedUint128) IP() net.IP {
+	ip := make(net.IP, net.IPv6len)
+	copy(ip, t[:])
+	return ip
+}
+
+// String returns the value in base 10.
+func (t FixedUint128) String() string {
+	return t.BigInt().String()
+}
+
+func (t FixedUint128) halves() (hi, lo uint64) {
+	return binary.BigEndian.Uint64(t[:8]), binary.BigEndian.Uint64(t[8:])
+}
+
+func fromHalves(hi, lo uint64) FixedUint128 {
+	var t FixedUint128
+	binary.BigEndian.PutUint64(t[:8], hi)
+	binary.BigEndian.PutUint64(t[8:], lo)
+	return t
+}
+
+func (t FixedUint128) size() int {
+	for i, b := range t {
+		if b != 0 {
+			return len(t) - i
+		}
+	}
+	return 0
+}
+
+func (t FixedUint128) typeNum() typeNum {
+	return typeNumUint128
+}
+
+// WriteTo writes the value to w.
+func (t FixedUint128) WriteTo(w writer) (int64, error) {
+	numBytes, err := writeCtrlByte(w, t)
+	if err != nil {
+		return numBytes, err
+	}
+
+	written, err := w.Write(t[len(t)-t.size():])
+	numBytes += int64(written)
+	if err != nil {
+		return numBytes, fmt.Errorf("writing uint128: %w", err)
+	}
+	return numBytes, nil
+}
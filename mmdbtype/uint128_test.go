@@ -0,0 +1,124 @@
+package mmdbtype
+
+import (
+	"encoding/hex"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFixedUint128(t *testing.T) {
+	ctrlByte := "03"
+
+	uints := map[string]DataType{
+		"00" + ctrlByte:          FixedUint128{},
+		"02" + ctrlByte + "01f4": Uint128FromUint64(500),
+		"02" + ctrlByte + "2a78": Uint128FromUint64(10872),
+	}
+	for i := 1; i <= 16; i++ {
+		var v FixedUint128
+		for j := 16 - i; j < 16; j++ {
+			v[j] = 0xff
+		}
+		input := hex.EncodeToString([]byte{byte(i)}) + ctrlByte + strings.Repeat("ff", i)
+		uints[input] = v
+	}
+
+	validateEncoding(t, uints)
+}
+
+func TestUint128FromHex(t *testing.T) {
+	v, err := Uint128FromHex("0x1f4")
+	require.NoError(t, err)
+	assert.Equal(t, Uint128FromUint64(500), v)
+
+	v, err = Uint128FromHex("ffffffffffffffffffffffffffffffff")
+	require.NoError(t, err)
+	assert.Equal(t, "340282366920938463463374607431768211455", v.String())
+
+	_, err = Uint128FromHex("1ffffffffffffffffffffffffffffffff")
+	assert.EqualError(t, err, `parsing "1ffffffffffffffffffffffffffffffff" as a uint128: value is too large`)
+
+	_, err = Uint128FromHex("0x")
+	assert.EqualError(t, err, `parsing "0x" as a uint128: no digits`)
+
+	_, err = Uint128FromHex("xyz")
+	assert.Error(t, err)
+}
+
+func TestUint128FromIP(t *testing.T) {
+	v, err := Uint128FromIP(net.ParseIP("1.2.3.4"))
+	require.NoError(t, err)
+	assert.Equal(t, Uint128FromUint64(0x01020304), v)
+
+	v, err = Uint128FromIP(net.ParseIP("2001:db8::1"))
+	require.NoError(t, err)
+	assert.Equal(t, "2001:db8::1", v.IP().String())
+
+	_, err = Uint128FromIP(net.IP{1, 2, 3})
+	assert.EqualError(t, err, "invalid IP address")
+}
+
+func TestUint128FromBigInt(t *testing.T) {
+	v, err := Uint128FromBigInt(big.NewInt(10872))
+	require.NoError(t, err)
+	assert.Equal(t, Uint128FromUint64(10872), v)
+	assert.Equal(t, big.NewInt(10872), v.BigInt())
+
+	_, err = Uint128FromBigInt(big.NewInt(-1))
+	assert.EqualError(t, err, "-1 does not fit in a uint128")
+
+	tooLarge := new(big.Int).Lsh(big.NewInt(1), 128)
+	_, err = Uint128FromBigInt(tooLarge)
+	assert.Error(t, err)
+}
+
+func TestFixedUint128EqualUint128(t *testing.T) {
+	fixed := Uint128FromUint64(10872)
+	bigValue := (*Uint128)(big.NewInt(10872))
+	other := (*Uint128)(big.NewInt(500))
+
+	assert.True(t, fixed.Equal(bigValue))
+	assert.True(t, bigValue.Equal(fixed))
+	assert.Equal(t, fixed.Hash(), bigValue.Hash(), "equal values have the same hash")
+
+	assert.False(t, fixed.Equal(other))
+	assert.False(t, other.Equal(fixed))
+	assert.False(t, fixed.Equal((*Uint128)(nil)))
+	assert.False(t, fixed.Equal(Uint64(10872)))
+}
+
+func TestFixedUint128Math(t *testing.T) {
+	maxUint64 := Uint128FromUint64(^uint64(0))
+	one := Uint128FromUint64(1)
+
+	sum, carry := maxUint64.Add(one)
+	assert.Equal(t, "18446744073709551616", sum.String())
+	assert.Zero(t, carry)
+
+	diff, borrow := sum.Sub(one)
+	assert.Equal(t, maxUint64, diff)
+	assert.Zero(t, borrow)
+
+	_, borrow = FixedUint128{}.Sub(one)
+	assert.Equal(t, uint64(1), borrow)
+
+	var allOnes FixedUint128
+	for i := range allOnes {
+		allOnes[i] = 0xff
+	}
+	sum, carry = allOnes.Add(one)
+	assert.True(t, sum.IsZero())
+	assert.Equal(t, uint64(1), carry)
+
+	assert.Equal(t, -1, one.Cmp(maxUint64))
+	assert.Equal(t, 0, one.Cmp(Uint128FromUint64(1)))
+	assert.Equal(t, 1, allOnes.Cmp(maxUint64))
+
+	assert.True(t, one.Equal(Uint128FromUint64(1)))
+	assert.False(t, one.Equal(Uint128FromUint64(2)))
+}
@@ -0,0 +1,232 @@
+package mmdbwriter
+
+import (
+	"fmt"
+
+	"github.com/maxmind/mmdbwriter/inserter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+// namesKey is the key of the maps of localized names in the GeoIP2 record
+// layouts, e.g., {"city": {"names": {"en": "Berlin", "de": "Berlin"}}}.
+const namesKey = mmdbtype.String("names")
+
+// languageSet returns the set of the languages for filterNamesInserter.
+func languageSet(languages []string) map[mmdbtype.String]struct{} {
+	keep := make(map[mmdbtype.String]struct{}, len(languages))
+	for _, lang := range languages {
+		keep[mmdbtype.String(lang)] = struct{}{}
+	}
+	return keep
+}
+
+// filterNamesInserter wraps an inserter function so that the names maps in
+// the value it returns only contain the languages in keep.
+func filterNamesInserter(f inserter.Func, keep map[mmdbtype.String]struct{}) inserter.Func {
+	return func(existing mmdbtype.DataType) (mmdbtype.DataType, error) {
+		v, err := f(existing)
+		if err != nil || v == nil {
+			return v, err
+		}
+		return filterNames(v, keep), nil
+	}
+}
+
+// filterNames returns v with the languages not in keep removed from all of
+// its names maps. A names map without any of the languages is removed. As
+// with roundFloats, v is never modified. Instead, maps and slices are
+// copied if they contain a value that changed.
+func filterNames(v mmdbtype.DataType, keep map[mmdbtype.String]struct{}) mmdbtype.DataType {
+	return mapNames(v, func(names mmdbtype.Map) (mmdbtype.Map, bool) {
+		filtered := filterLanguages(names, keep)
+		return filtered, len(filtered) != len(names)
+	})
+}
+
+// mapNames returns v with fn applied to all of its names maps. fn returns
+// the new names map and whether it differs from the original. If the new
+// map is empty, the names map is removed. As with roundFloats, v is never
+// modified.
+func mapNames(
+	v mmdbtype.DataType,
+	fn func(names mmdbtype.Map) (mmdbtype.Map, bool),
+) mmdbtype.DataType {
+	switch v := v.(type) {
+	case mmdbtype.Map:
+		var newMap mmdbtype.Map
+		copyMap := func() {
+			if newMap == nil {
+				newMap = make(mmdbtype.Map, len(v))
+				for k, e := range v {
+					newMap[k] = e
+				}
+			}
+		}
+		for k, e := range v {
+			if names, ok := e.(mmdbtype.Map); ok && k == namesKey {
+				mapped, changed := fn(names)
+				if !changed {
+					continue
+				}
+				copyMap()
+				if len(mapped) == 0 {
+					delete(newMap, k)
+				} else {
+					newMap[k] = mapped
+				}
+				continue
+			}
+			ne := mapNames(e, fn)
+			if ne.Equal(e) {
+				continue
+			}
+			copyMap()
+			newMap[k] = ne
+		}
+		if newMap == nil {
+			return v
+		}
+		return newMap
+	case mmdbtype.Templated:
+		return mmdbtype.Templated{
+			Template:  mapNames(v.Template, fn).(mmdbtype.Map),
+			Overrides: mapNames(v.Overrides, fn).(mmdbtype.Map),
+		}
+	case mmdbtype.Slice:
+		var newSlice mmdbtype.Slice
+		for i, e := range v {
+			ne := mapNames(e, fn)
+			if newSlice == nil && ne.Equal(e) {
+				continue
+			}
+			if newSlice == nil {
+				newSlice = make(mmdbtype.Slice, len(v))
+				copy(newSlice, v)
+			}
+			newSlice[i] = ne
+		}
+		if newSlice == nil {
+			return v
+		}
+		return newSlice
+	default:
+		return v
+	}
+}
+
+// filterLanguages returns the names map with only the languages in keep. If
+// all of the languages are kept, the map itself is returned.
+func filterLanguages(names mmdbtype.Map, keep map[mmdbtype.String]struct{}) mmdbtype.Map {
+	n := 0
+	for lang := range names {
+		if _, ok := keep[lang]; ok {
+			n++
+		}
+	}
+	if n == len(names) {
+		return names
+	}
+	filtered := make(mmdbtype.Map, n)
+	for lang, name := range names {
+		if _, ok := keep[lang]; ok {
+			filtered[lang] = name
+		}
+	}
+	return filtered
+}
+
+// languageFallbacks maps each language to the languages whose names are
+// used, in order, if a names map does not have it.
+type languageFallbacks map[mmdbtype.String][]mmdbtype.String
+
+func newLanguageFallbacks(fallbacks map[string][]string, languages []string) (languageFallbacks, error) {
+	if len(fallbacks) == 0 {
+		return nil, nil
+	}
+	lf := make(languageFallbacks, len(fallbacks))
+	for lang, chain := range fallbacks {
+		if !languageTagRE.MatchString(lang) {
+			return nil, fmt.Errorf(
+				"invalid language in LanguageFallbacks: %q is not a BCP 47 language tag",
+				lang,
+			)
+		}
+		if len(languages) > 0 && !containsString(languages, lang) {
+			return nil, fmt.Errorf("the LanguageFallbacks language %q is not in Languages", lang)
+		}
+		if len(chain) == 0 {
+			return nil, fmt.Errorf("the LanguageFallbacks for %q are empty", lang)
+		}
+		for _, fallback := range chain {
+			if !languageTagRE.MatchString(fallback) {
+				return nil, fmt.Errorf(
+					"invalid fallback for %q in LanguageFallbacks: %q is not a BCP 47 language tag",
+					lang,
+					fallback,
+				)
+			}
+			lf[mmdbtype.String(lang)] = append(lf[mmdbtype.String(lang)], mmdbtype.String(fallback))
+		}
+	}
+	return lf, nil
+}
+
+// options returns the fallbacks in the form of Options.LanguageFallbacks.
+func (lf languageFallbacks) options() map[string][]string {
+	if lf == nil {
+		return nil
+	}
+	fallbacks := make(map[string][]string, len(lf))
+	for lang, chain := range lf {
+		for _, fallback := range chain {
+			fallbacks[string(lang)] = append(fallbacks[string(lang)], string(fallback))
+		}
+	}
+	return fallbacks
+}
+
+// fallbackNamesInserter wraps an inserter function so that the names maps
+// in the value it returns have the languages of the fallbacks.
+func fallbackNamesInserter(f inserter.Func, lf languageFallbacks) inserter.Func {
+	return func(existing mmdbtype.DataType) (mmdbtype.DataType, error) {
+		v, err := f(existing)
+		if err != nil || v == nil {
+			return v, err
+		}
+		return fallbackNames(v, lf), nil
+	}
+}
+
+// fallbackNames returns v with the missing languages of the fallbacks added
+// to all of its names maps. A missing language gets the name of the first
+// language in its chain that the original names map has. Names added for
+// other languages are not used as fallbacks, so the result does not depend
+// on the order of the languages. As with roundFloats, v is never modified.
+func fallbackNames(v mmdbtype.DataType, lf languageFallbacks) mmdbtype.DataType {
+	return mapNames(v, func(names mmdbtype.Map) (mmdbtype.Map, bool) {
+		var filled mmdbtype.Map
+		for lang, chain := range lf {
+			if _, ok := names[lang]; ok {
+				continue
+			}
+			for _, fallback := range chain {
+				name, ok := names[fallback]
+				if !ok {
+					continue
+				}
+				if filled == nil {
+					filled = make(mmdbtype.Map, len(names)+1)
+					for k, e := range names {
+						filled[k] = e
+					}
+				}
+				filled[lang] = name
+				break
+			}
+		}
+		if filled == nil {
+			return names, false
+		}
+		return filled, true
+	})
+}
@@ -0,0 +1,156 @@
+package mmdbwriter
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFilterNamesByLanguages(t *testing.T) {
+	tree, err := New(Options{
+		IPVersion:              4,
+		Languages:              []string{"en", "pt-BR"},
+		FilterNamesByLanguages: true,
+	})
+	require.NoError(t, err)
+
+	names := func(langs ...string) mmdbtype.Map {
+		m := mmdbtype.Map{}
+		for _, lang := range langs {
+			m[mmdbtype.String(lang)] = mmdbtype.String("name-" + lang)
+		}
+		return m
+	}
+	value := mmdbtype.Map{
+		"city":    mmdbtype.Map{"names": names("de", "en", "ja")},
+		"country": mmdbtype.Map{"names": names("en", "pt-BR"), "iso_code": mmdbtype.String("BR")},
+		"continent": mmdbtype.Map{
+			"names": names("de", "ja"),
+			"code":  mmdbtype.String("SA"),
+		},
+		"subdivisions": mmdbtype.Slice{
+			mmdbtype.Map{"names": names("pt-BR", "ru")},
+		},
+		// A names value that is not a map is left as is.
+		"other": mmdbtype.Map{"names": mmdbtype.String("x")},
+	}
+	original := value.Copy()
+
+	require.NoError(t, tree.Insert(mustNetwork(t, "1.1.1.0/24"), value))
+
+	_, actual := tree.Get(net.ParseIP("1.1.1.1").To4())
+	assert.Equal(t, mmdbtype.Map{
+		"city":         mmdbtype.Map{"names": names("en")},
+		"country":      mmdbtype.Map{"names": names("en", "pt-BR"), "iso_code": mmdbtype.String("BR")},
+		"continent":    mmdbtype.Map{"code": mmdbtype.String("SA")},
+		"subdivisions": mmdbtype.Slice{mmdbtype.Map{"names": names("pt-BR")}},
+		"other":        mmdbtype.Map{"names": mmdbtype.String("x")},
+	}, actual)
+	assert.Equal(t, original, value, "the inserted value is not modified")
+
+	// A value without names to remove is stored as is.
+	unchanged := mmdbtype.Map{"country": mmdbtype.Map{"names": names("en")}}
+	filtered := filterNames(unchanged, languageSet([]string{"en"}))
+	assert.Equal(t, reflect.ValueOf(unchanged).Pointer(), reflect.ValueOf(filtered).Pointer())
+
+	_, err = New(Options{FilterNamesByLanguages: true})
+	assert.EqualError(t, err, "FilterNamesByLanguages requires Languages to be set")
+}
+
+func TestLanguageFallbacks(t *testing.T) {
+	tree, err := New(Options{
+		IPVersion:              4,
+		Languages:              []string{"de", "en", "pt-BR"},
+		FilterNamesByLanguages: true,
+		LanguageFallbacks: map[string][]string{
+			"de":    {"en"},
+			"pt-BR": {"pt", "es", "en"},
+		},
+	})
+	require.NoError(t, err)
+
+	value := mmdbtype.Map{
+		"city": mmdbtype.Map{"names": mmdbtype.Map{
+			"en": mmdbtype.String("Munich"),
+			"de": mmdbtype.String("München"),
+			"es": mmdbtype.String("Múnich"),
+		}},
+		"country": mmdbtype.Map{"names": mmdbtype.Map{
+			"en": mmdbtype.String("Brazil"),
+			"pt": mmdbtype.String("Brasil"),
+		}},
+		"subdivisions": mmdbtype.Slice{
+			mmdbtype.Map{"names": mmdbtype.Map{"fr": mmdbtype.String("Bavière")}},
+		},
+	}
+	original := value.Copy()
+
+	require.NoError(t, tree.Insert(mustNetwork(t, "1.1.1.0/24"), value))
+
+	_, actual := tree.Get(net.ParseIP("1.1.1.1").To4())
+	assert.Equal(t, mmdbtype.Map{
+		"city": mmdbtype.Map{"names": mmdbtype.Map{
+			"en":    mmdbtype.String("Munich"),
+			"de":    mmdbtype.String("München"),
+			"pt-BR": mmdbtype.String("Múnich"),
+		}},
+		"country": mmdbtype.Map{"names": mmdbtype.Map{
+			"en":    mmdbtype.String("Brazil"),
+			"de":    mmdbtype.String("Brazil"),
+			"pt-BR": mmdbtype.String("Brasil"),
+		}},
+		"subdivisions": mmdbtype.Slice{mmdbtype.Map{}},
+	}, actual, "the fallbacks are applied before filtering")
+	assert.Equal(t, original, value, "the inserted value is not modified")
+
+	// A value without missing languages is stored as is.
+	lf := languageFallbacks{"de": {"en"}}
+	unchanged := mmdbtype.Map{"country": mmdbtype.Map{"names": mmdbtype.Map{"de": mmdbtype.String("x")}}}
+	assert.Equal(
+		t,
+		reflect.ValueOf(unchanged).Pointer(),
+		reflect.ValueOf(fallbackNames(unchanged, lf)).Pointer(),
+	)
+
+	assert.Equal(t, map[string][]string{"de": {"en"}}, lf.options())
+
+	tests := []struct {
+		name string
+		opts Options
+		err  string
+	}{
+		{
+			name: "invalid language",
+			opts: Options{LanguageFallbacks: map[string][]string{"d e": {"en"}}},
+			err:  `invalid language in LanguageFallbacks: "d e" is not a BCP 47 language tag`,
+		},
+		{
+			name: "invalid fallback",
+			opts: Options{LanguageFallbacks: map[string][]string{"de": {"e n"}}},
+			err:  `invalid fallback for "de" in LanguageFallbacks: "e n" is not a BCP 47 language tag`,
+		},
+		{
+			name: "empty chain",
+			opts: Options{LanguageFallbacks: map[string][]string{"de": nil}},
+			err:  `the LanguageFallbacks for "de" are empty`,
+		},
+		{
+			name: "not in Languages",
+			opts: Options{
+				Languages:         []string{"en"},
+				LanguageFallbacks: map[string][]string{"de": {"en"}},
+			},
+			err: `the LanguageFallbacks language "de" is not in Languages`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := New(test.opts)
+			assert.EqualError(t, err, test.err)
+		})
+	}
+}
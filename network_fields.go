@@ -0,0 +1,77 @@
+package mmdbwriter
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+// NetworkField is a field derived from the network of a record that may be
+// added to the record when the tree is written. See Options.NetworkFields.
+// The value of the constant is the key of the field in the record.
+type NetworkField string
+
+const (
+	// NetworkFieldNetwork adds the network in CIDR notation, e.g.,
+	// "1.1.1.0/24", as a String.
+	NetworkFieldNetwork NetworkField = "network"
+
+	// NetworkFieldPrefixLength adds the prefix length of the network as a
+	// Uint16.
+	NetworkFieldPrefixLength NetworkField = "prefix_length"
+
+	// NetworkFieldIsIPv4 adds whether the network is an IPv4 network as a
+	// Bool.
+	NetworkFieldIsIPv4 NetworkField = "is_ipv4"
+)
+
+func validateNetworkFields(fields []NetworkField) error {
+	for _, f := range fields {
+		switch f {
+		case NetworkFieldNetwork, NetworkFieldPrefixLength, NetworkFieldIsIPv4:
+		default:
+			return fmt.Errorf("unsupported NetworkField: %q", string(f))
+		}
+	}
+	return nil
+}
+
+// annotateNetwork returns a copy of the value with the network fields of
+// the tree added. The ip is in the tree's address space and prefixLen is
+// the depth of the record. In an IPv6 tree, the networks within the IPv4
+// subtree are the IPv4 networks, and they are reported in their IPv4 form. Values that
+// are not maps are returned unchanged.
+func (t *Tree) annotateNetwork(v mmdbtype.DataType, ip net.IP, prefixLen int) mmdbtype.DataType {
+	if tv, ok := v.(mmdbtype.Templated); ok {
+		v = tv.Map()
+	}
+	m, ok := v.(mmdbtype.Map)
+	if !ok {
+		return v
+	}
+
+	isIPv4 := t.ipVersion == 4
+	if !isIPv4 && t.inIPv4Subtree(ip, prefixLen) {
+		ip = ip[12:]
+		prefixLen -= 96
+		isIPv4 = true
+	}
+
+	newMap := make(mmdbtype.Map, len(m)+len(t.networkFields))
+	for k, e := range m {
+		newMap[k] = e
+	}
+	for _, f := range t.networkFields {
+		switch f {
+		case NetworkFieldNetwork:
+			network := &net.IPNet{IP: ip, Mask: net.CIDRMask(prefixLen, len(ip)*8)}
+			newMap[mmdbtype.String(f)] = mmdbtype.String(network.String())
+		case NetworkFieldPrefixLength:
+			newMap[mmdbtype.String(f)] = mmdbtype.Uint16(prefixLen)
+		case NetworkFieldIsIPv4:
+			newMap[mmdbtype.String(f)] = mmdbtype.Bool(isIPv4)
+		}
+	}
+	return newMap
+}
@@ -0,0 +1,148 @@
+package mmdbwriter
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/oschwald/maxminddb-golang"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNetworkFields(t *testing.T) {
+	tests := []struct {
+		name      string
+		ipVersion int
+		ip        string
+		expected  map[string]any
+	}{
+		{
+			name:      "IPv4 network in IPv6 tree",
+			ipVersion: 6,
+			ip:        "1.1.1.1",
+			expected: map[string]any{
+				"country":       "US",
+				"network":       "1.1.0.0/23",
+				"prefix_length": uint64(23),
+				"is_ipv4":       true,
+			},
+		},
+		{
+			name:      "IPv4 network through alias",
+			ipVersion: 6,
+			ip:        "::ffff:1.1.0.1",
+			expected: map[string]any{
+				"country":       "US",
+				"network":       "1.1.0.0/23",
+				"prefix_length": uint64(23),
+				"is_ipv4":       true,
+			},
+		},
+		{
+			name:      "IPv6 network",
+			ipVersion: 6,
+			ip:        "2001:4860::1",
+			expected: map[string]any{
+				"country":       "US",
+				"network":       "2001:4860::/32",
+				"prefix_length": uint64(32),
+				"is_ipv4":       false,
+			},
+		},
+		{
+			name:      "IPv4 tree",
+			ipVersion: 4,
+			ip:        "1.1.1.1",
+			expected: map[string]any{
+				"country":       "US",
+				"network":       "1.1.0.0/23",
+				"prefix_length": uint64(23),
+				"is_ipv4":       true,
+			},
+		},
+		{
+			name:      "existing field is replaced",
+			ipVersion: 4,
+			ip:        "2.2.2.2",
+			expected: map[string]any{
+				"network":       "2.2.2.0/24",
+				"prefix_length": uint64(24),
+				"is_ipv4":       true,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tree, err := New(Options{
+				IPVersion: test.ipVersion,
+				NetworkFields: []NetworkField{
+					NetworkFieldNetwork,
+					NetworkFieldPrefixLength,
+					NetworkFieldIsIPv4,
+				},
+			})
+			require.NoError(t, err)
+
+			value := mmdbtype.Map{"country": mmdbtype.String("US")}
+			require.NoError(t, tree.Insert(mustNetwork(t, "1.1.0.0/24"), value))
+			require.NoError(t, tree.Insert(mustNetwork(t, "1.1.1.0/24"), value))
+			require.NoError(t, tree.Insert(
+				mustNetwork(t, "2.2.2.0/24"),
+				mmdbtype.Map{"network": mmdbtype.String("x")},
+			))
+			if test.ipVersion == 6 {
+				require.NoError(t, tree.Insert(mustNetwork(t, "2001:4860::/32"), value))
+			}
+
+			// The fields are only added when writing.
+			_, stored := tree.Get(net.ParseIP("1.1.1.1").To4())
+			assert.Equal(t, value, stored)
+
+			buf := &bytes.Buffer{}
+			_, err = tree.WriteTo(buf)
+			require.NoError(t, err)
+
+			reader, err := maxminddb.FromBytes(buf.Bytes())
+			require.NoError(t, err)
+
+			var record map[string]any
+			require.NoError(t, reader.Lookup(net.ParseIP(test.ip), &record))
+			assert.Equal(t, test.expected, record)
+		})
+	}
+}
+
+func TestNetworkFieldsDeduplication(t *testing.T) {
+	tree, err := New(Options{
+		IPVersion:     4,
+		NetworkFields: []NetworkField{NetworkFieldIsIPv4},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, tree.Insert(mustNetwork(t, "1.1.1.0/24"), mmdbtype.Map{"a": mmdbtype.Bool(true)}))
+	require.NoError(t, tree.Insert(mustNetwork(t, "2.2.2.0/24"), mmdbtype.Map{"a": mmdbtype.Bool(true)}))
+	require.NoError(t, tree.Insert(mustNetwork(t, "3.3.3.0/24"), mmdbtype.Map{"a": mmdbtype.Bool(false)}))
+
+	buf := &bytes.Buffer{}
+	_, err = tree.WriteTo(buf)
+	require.NoError(t, err)
+
+	reader, err := maxminddb.FromBytes(buf.Bytes())
+	require.NoError(t, err)
+
+	offset := func(ip string) uintptr {
+		o, err := reader.LookupOffset(net.ParseIP(ip))
+		require.NoError(t, err)
+		return o
+	}
+	assert.Equal(t, offset("1.1.1.1"), offset("2.2.2.2"))
+	assert.NotEqual(t, offset("1.1.1.1"), offset("3.3.3.3"))
+}
+
+func TestNetworkFieldsInvalid(t *testing.T) {
+	_, err := New(Options{NetworkFields: []NetworkField{"asn"}})
+	assert.EqualError(t, err, `unsupported NetworkField: "asn"`)
+}
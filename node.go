@@ -20,8 +20,20 @@ const (
 
 type record struct {
 	node       *node
-	value      *dataMapValue
+	value      valueRef
 	recordType recordType
+	// prefixLen is the prefix length, in tree form, of the network that
+	// the data of a data record was inserted for. It is shorter than the
+	// depth of the record when the record is part of a larger network that
+	// was split by a more specific insert. OverwritePolicy uses it to tell
+	// the more specific networks apart from such parts.
+	prefixLen uint8
+}
+
+// moreSpecificThan returns whether the data of the record was inserted for
+// a network more specific than one with the prefix length.
+func (r record) moreSpecificThan(prefixLen int) bool {
+	return int(r.prefixLen) > prefixLen
 }
 
 // each node contains two records.
@@ -34,12 +46,21 @@ type insertRecord struct {
 	inserter func(value mmdbtype.DataType) (mmdbtype.DataType, error)
 
 	dataMap      *dataMap
+	nodes        nodeStore
 	insertedNode *node
 
+	// nodeCount is the number of nodes in the tree. It is updated as nodes
+	// are added and merged.
+	nodeCount *int
+
+	// report is updated with the overwrites, merges, and removals.
+	report *BuildReport
+
 	ip        net.IP
 	prefixLen int
 
-	recordType recordType
+	recordType      recordType
+	overwritePolicy OverwritePolicy
 }
 
 func (n *node) insert(iRec insertRecord, currentDepth int) error {
@@ -67,79 +88,115 @@ func (r *record) insert(
 ) error {
 	switch r.recordType {
 	case recordTypeNode:
-		err := r.node.insert(iRec, newDepth)
-		if err != nil {
-			return err
-		}
+		// If the insert fails part way, e.g., because the inserter
+		// returned an error, we still merge what was inserted so that the
+		// tree remains aggregated.
+		insertErr := r.node.insert(iRec, newDepth)
 
-		// Check to see if the children are the same and can be merged.
-		child0 := r.node.children[0]
-		child1 := r.node.children[1]
-		if child0.recordType != child1.recordType {
-			return nil
-		}
-		switch child0.recordType {
-		// Nodes can't be merged
-		case recordTypeFixedNode,
-			recordTypeNode:
-			return nil
-		case recordTypeEmpty,
-			recordTypeReserved:
-			r.recordType = child0.recordType
-			r.node = nil
-			return nil
-		case recordTypeData:
-			if child0.value.key != child1.value.key {
-				return nil
+		merged, err := r.merge(iRec.dataMap, iRec.nodes)
+		if merged {
+			*iRec.nodeCount--
+			if r.recordType == recordTypeData {
+				iRec.report.Merges++
 			}
-			// Children have same data and can be merged
-			r.recordType = recordTypeData
-			r.value = child0.value
-			iRec.dataMap.remove(child1.value)
-			r.node = nil
-			return nil
-		default:
-			return fmt.Errorf("merging record type %d is not implemented", child0.recordType)
 		}
+		if insertErr != nil {
+			return insertErr
+		}
+		return err
 	case recordTypeFixedNode:
 		return r.node.insert(iRec, newDepth)
 	case recordTypeEmpty, recordTypeData:
 		if newDepth >= iRec.prefixLen {
 			r.node = iRec.insertedNode
 			r.recordType = iRec.recordType
+			if iRec.recordType == recordTypeFixedNode {
+				*iRec.nodeCount++
+			}
 			if iRec.recordType == recordTypeData {
 				var oldData mmdbtype.DataType
-				if r.value != nil {
-					oldData = r.value.data
+				if r.value != 0 {
+					oldData = iRec.dataMap.load(r.value)
+				}
+				// The record's data was inserted for a more specific
+				// network than the one being inserted. Records that are
+				// only part of a larger network are not.
+				if r.moreSpecificThan(iRec.prefixLen) && oldData != nil {
+					switch iRec.overwritePolicy {
+					case OverwriteKeep:
+						return nil
+					case OverwriteReplace:
+						oldData = nil
+					case OverwriteMerge, OverwriteError:
+					}
 				}
 				newData, err := iRec.inserter(oldData)
 				if err != nil {
+					if r.value == 0 {
+						r.recordType = recordTypeEmpty
+					}
 					return err
 				}
 				if newData == nil {
+					if oldData != nil {
+						iRec.report.RemovedNetworks++
+					}
 					iRec.dataMap.remove(r.value)
 					r.recordType = recordTypeEmpty
-					r.value = nil
-				} else if oldData == nil || !oldData.Equal(newData) {
+					r.value = 0
+					r.prefixLen = 0
+					return nil
+				}
+				if oldData == nil || !oldData.Equal(newData) {
+					if oldData != nil {
+						iRec.report.Overwrites++
+					}
 					iRec.dataMap.remove(r.value)
 					value, err := iRec.dataMap.store(newData)
 					if err != nil {
 						return err
 					}
-					r.value = value
+					r.value = value.ref
+				}
+				if oldData == nil || !r.moreSpecificThan(iRec.prefixLen) {
+					r.prefixLen = uint8(iRec.prefixLen)
 				}
 			} else {
-				r.value = nil
+				r.value = 0
 			}
 			return nil
 		}
 
 		// We are splitting this record so we create two duplicate child
 		// records.
-		r.node = &node{children: [2]record{*r, *r}}
-		r.value = nil
+		if r.recordType == recordTypeData {
+			iRec.dataMap.retain(r.value)
+		}
+		n, err := iRec.nodes.newNode()
+		if err != nil {
+			return err
+		}
+		n.children = [2]record{*r, *r}
+		r.node = n
+		r.value = 0
 		r.recordType = recordTypeNode
-		return r.node.insert(iRec, newDepth)
+		*iRec.nodeCount++
+		insertErr := r.node.insert(iRec, newDepth)
+
+		// The inserted data may be the same as the data we split, e.g.,
+		// when inserting a network into a larger network with the same
+		// data. If the insert failed, this undoes the split.
+		merged, err := r.merge(iRec.dataMap, iRec.nodes)
+		if merged {
+			*iRec.nodeCount--
+			if r.recordType == recordTypeData {
+				iRec.report.Merges++
+			}
+		}
+		if insertErr != nil {
+			return insertErr
+		}
+		return err
 	case recordTypeReserved:
 		if iRec.prefixLen >= newDepth {
 			return fmt.Errorf(
@@ -168,6 +225,46 @@ func (r *record) insert(
 	}
 }
 
+// merge replaces the node record with its children's record if the children
+// are the same. It returns true if the records were merged, in which case
+// the node is returned to the store.
+func (r *record) merge(dm *dataMap, nodes nodeStore) (bool, error) {
+	child0 := r.node.children[0]
+	child1 := r.node.children[1]
+	if child0.recordType != child1.recordType {
+		return false, nil
+	}
+	switch child0.recordType {
+	// Nodes can't be merged
+	case recordTypeFixedNode,
+		recordTypeNode:
+		return false, nil
+	case recordTypeEmpty,
+		recordTypeReserved:
+		r.recordType = child0.recordType
+		nodes.freeNode(r.node)
+		r.node = nil
+		return true, nil
+	case recordTypeData:
+		if child0.value != child1.value {
+			return false, nil
+		}
+		// Children have same data and can be merged
+		r.recordType = recordTypeData
+		r.value = child0.value
+		r.prefixLen = child0.prefixLen
+		if child1.prefixLen > r.prefixLen {
+			r.prefixLen = child1.prefixLen
+		}
+		dm.remove(child1.value)
+		nodes.freeNode(r.node)
+		r.node = nil
+		return true, nil
+	default:
+		return false, fmt.Errorf("merging record type %d is not implemented", child0.recordType)
+	}
+}
+
 func (n *node) get(
 	ip net.IP,
 	depth int,
@@ -184,6 +281,38 @@ func (n *node) get(
 	}
 }
 
+// walkFunc is called for each record visited by walk. The ip is the
+// network address of the record, which has the length prefixLen. The ip is
+// reused between calls and must be copied if it is retained.
+type walkFunc func(ip net.IP, prefixLen int, r record) error
+
+// walk calls fn for each non-node record in the subtree. The ip is the
+// address of the node, which is at the given depth. Aliased records are
+// skipped as the data they point to is visited through the fixed node.
+func (n *node) walk(ip net.IP, depth int, fn walkFunc) error {
+	for i := 0; i < 2; i++ {
+		if i == 1 {
+			setBitAt(ip, depth)
+		}
+		var err error
+		r := n.children[i]
+		switch r.recordType {
+		case recordTypeNode, recordTypeFixedNode:
+			err = r.node.walk(ip, depth+1, fn)
+		case recordTypeAlias:
+		default:
+			err = fn(ip, depth+1, r)
+		}
+		if i == 1 {
+			clearBitAt(ip, depth)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // finalize  sets the node number for the node. It returns the current node
 // count, including the subtree.
 func (n *node) finalize(currentNum int) int {
@@ -205,3 +334,11 @@ func (n *node) finalize(currentNum int) int {
 func bitAt(ip net.IP, depth int) byte {
 	return (ip[depth/8] >> (7 - (depth % 8))) & 1
 }
+
+func setBitAt(ip net.IP, depth int) {
+	ip[depth/8] |= 1 << (7 - (depth % 8))
+}
+
+func clearBitAt(ip net.IP, depth int) {
+	ip[depth/8] &^= 1 << (7 - (depth % 8))
+}
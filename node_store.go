@@ -0,0 +1,68 @@
+package mmdbwriter
+
+import "fmt"
+
+// NodeStorage selects where the nodes of the search tree are stored while
+// the tree is being built.
+type NodeStorage int
+
+const (
+	// NodeStorageMemory stores the nodes in memory. This is the default.
+	NodeStorageMemory NodeStorage = iota
+
+	// NodeStorageFile stores the nodes in a memory-mapped temporary file.
+	// This allows trees with more nodes than fit in memory to be built as
+	// the operating system may page the nodes out to the file. Building is
+	// slower, particularly once the nodes no longer fit in memory. The data
+	// values are still stored in memory.
+	//
+	// This is only supported on Unix-like systems.
+	NodeStorageFile
+)
+
+func (s NodeStorage) String() string {
+	switch s {
+	case NodeStorageMemory:
+		return "NodeStorageMemory"
+	case NodeStorageFile:
+		return "NodeStorageFile"
+	default:
+		return fmt.Sprintf("NodeStorage(%d)", int(s))
+	}
+}
+
+// nodeStore allocates the nodes of a tree.
+type nodeStore interface {
+	// newNode returns a zeroed node.
+	newNode() (*node, error)
+	// freeNode returns a node that is no longer in the tree to the store.
+	freeNode(n *node)
+	// close releases the resources used by the store. The nodes must not
+	// be used afterward.
+	close() error
+}
+
+func newNodeStore(storage NodeStorage, dir string) (nodeStore, error) {
+	switch storage {
+	case NodeStorageMemory:
+		return memoryNodeStore{}, nil
+	case NodeStorageFile:
+		return newFileNodeStore(dir)
+	default:
+		return nil, fmt.Errorf("unsupported NodeStorage: %d", int(storage))
+	}
+}
+
+// memoryNodeStore allocates nodes on the heap. Freed nodes are left to the
+// garbage collector.
+type memoryNodeStore struct{}
+
+func (memoryNodeStore) newNode() (*node, error) {
+	return &node{}, nil
+}
+
+func (memoryNodeStore) freeNode(*node) {}
+
+func (memoryNodeStore) close() error {
+	return nil
+}
@@ -0,0 +1,104 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+
+package mmdbwriter
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// fileNodeStoreChunkNodes is the number of nodes mapped at a time. The
+// chunks are mapped separately so that existing nodes never move.
+const fileNodeStoreChunkNodes = 1 << 20
+
+// fileNodeStore allocates nodes from a memory-mapped file.
+//
+// The mapped memory is not part of the Go heap and is not scanned by the
+// garbage collector, so the nodes must not contain pointers into the Go
+// heap. The records refer to their values by valueRef, and the only
+// pointers are to other nodes from the same store. Nodes from other
+// stores, e.g., those allocated on the heap, must never be added to a tree
+// that uses this store.
+type fileNodeStore struct {
+	file   *os.File
+	size   int64
+	chunks [][]byte
+	// next holds the nodes in the last chunk that have not been allocated.
+	next []node
+	free []*node
+}
+
+func newFileNodeStore(dir string) (nodeStore, error) {
+	f, err := os.CreateTemp(dir, "mmdbwriter-nodes-")
+	if err != nil {
+		return nil, fmt.Errorf("creating node storage file: %w", err)
+	}
+	// We remove the file immediately so that it is cleaned up even if the
+	// tree is never closed. It remains usable until it is closed.
+	if err := os.Remove(f.Name()); err != nil {
+		f.Close() //nolint:errcheck // the remove error is more relevant
+		return nil, fmt.Errorf("removing node storage file: %w", err)
+	}
+	return &fileNodeStore{file: f}, nil
+}
+
+func (s *fileNodeStore) newNode() (*node, error) {
+	if len(s.free) > 0 {
+		n := s.free[len(s.free)-1]
+		s.free = s.free[:len(s.free)-1]
+		*n = node{}
+		return n, nil
+	}
+	if len(s.next) == 0 {
+		if err := s.grow(); err != nil {
+			return nil, err
+		}
+	}
+	n := &s.next[0]
+	s.next = s.next[1:]
+	return n, nil
+}
+
+func (s *fileNodeStore) freeNode(n *node) {
+	s.free = append(s.free, n)
+}
+
+// grow extends the file and maps another chunk of nodes.
+func (s *fileNodeStore) grow() error {
+	length := fileNodeStoreChunkNodes * int(unsafe.Sizeof(node{}))
+	if err := s.file.Truncate(s.size + int64(length)); err != nil {
+		return fmt.Errorf("extending node storage file: %w", err)
+	}
+	b, err := syscall.Mmap(
+		int(s.file.Fd()),
+		s.size,
+		length,
+		syscall.PROT_READ|syscall.PROT_WRITE,
+		syscall.MAP_SHARED,
+	)
+	if err != nil {
+		return fmt.Errorf("mapping node storage file: %w", err)
+	}
+	s.size += int64(length)
+	s.chunks = append(s.chunks, b)
+	s.next = unsafe.Slice((*node)(unsafe.Pointer(&b[0])), fileNodeStoreChunkNodes)
+	return nil
+}
+
+func (s *fileNodeStore) close() error {
+	var err error
+	for _, b := range s.chunks {
+		if unmapErr := syscall.Munmap(b); unmapErr != nil && err == nil {
+			err = fmt.Errorf("unmapping node storage file: %w", unmapErr)
+		}
+	}
+	s.chunks = nil
+	s.next = nil
+	s.free = nil
+	if closeErr := s.file.Close(); closeErr != nil && err == nil {
+		err = fmt.Errorf("closing node storage file: %w", closeErr)
+	}
+	return err
+}
@@ -0,0 +1,151 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+
+package mmdbwriter
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"reflect"
+	"runtime"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/inserter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNodeStorageFile(t *testing.T) {
+	dir := t.TempDir()
+	records := testNetworkRecords(t, 6)
+
+	build := func(opts Options) []byte {
+		opts.BuildEpoch = 1000
+		tree, err := New(opts)
+		require.NoError(t, err)
+		defer func() {
+			require.NoError(t, tree.Close())
+		}()
+
+		for _, r := range records {
+			require.NoError(t, tree.Insert(r.Network, r.Value))
+		}
+		// Removing data merges nodes, which returns them to the store
+		// to be reused by the following inserts.
+		for _, r := range records[:len(records)/2] {
+			require.NoError(t, tree.InsertFunc(r.Network, inserter.Remove))
+		}
+		for _, r := range records[len(records)/4:] {
+			require.NoError(t, tree.Insert(r.Network, r.Value))
+		}
+
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Empty(t, entries, "the file is removed once created")
+
+		buf := &bytes.Buffer{}
+		_, err = tree.WriteTo(buf)
+		require.NoError(t, err)
+		return buf.Bytes()
+	}
+
+	expected := build(Options{IncludeReservedNetworks: true})
+	actual := build(Options{
+		IncludeReservedNetworks: true,
+		NodeStorage:             NodeStorageFile,
+		NodeStorageDir:          dir,
+	})
+	assert.Equal(t, expected, actual)
+}
+
+// TestNodeStorageFilePointers checks that the only pointers in a node are
+// to other nodes, as the garbage collector does not scan the nodes in a
+// fileNodeStore.
+func TestNodeStorageFilePointers(t *testing.T) {
+	for _, typ := range []reflect.Type{reflect.TypeOf(node{}), reflect.TypeOf(record{})} {
+		for i := 0; i < typ.NumField(); i++ {
+			f := typ.Field(i)
+			switch f.Type.Kind() {
+			case reflect.Array, reflect.Bool, reflect.Int, reflect.Uint8, reflect.Uint32:
+			case reflect.Ptr:
+				assert.Equal(t, reflect.TypeOf(&node{}), f.Type, "%s.%s", typ, f.Name)
+			default:
+				t.Errorf("%s.%s has the unexpected kind %s", typ, f.Name, f.Type.Kind())
+			}
+		}
+	}
+}
+
+func TestNodeStorageFileGC(t *testing.T) {
+	tree, err := New(Options{
+		IncludeReservedNetworks: true,
+		NodeStorage:             NodeStorageFile,
+		NodeStorageDir:          t.TempDir(),
+	})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, tree.Close())
+	}()
+
+	// The values are only reachable through the tree, so they would be
+	// collected if the tree held them only through the mapped nodes.
+	for i := 0; i < 1000; i++ {
+		network := &net.IPNet{IP: net.IPv4(1, 1, byte(i>>8), byte(i)).To4(), Mask: net.CIDRMask(32, 32)}
+		value := mmdbtype.Map{"i": mmdbtype.String(fmt.Sprint(i))}
+		require.NoError(t, tree.Insert(network, value))
+		if i%100 == 0 {
+			runtime.GC()
+		}
+	}
+	runtime.GC()
+
+	for i := 0; i < 1000; i++ {
+		_, value := tree.Get(net.IPv4(1, 1, byte(i>>8), byte(i)))
+		assert.Equal(t, mmdbtype.Map{"i": mmdbtype.String(fmt.Sprint(i))}, value)
+	}
+	require.NoError(t, tree.Check())
+}
+
+func TestNodeStorageFileGrow(t *testing.T) {
+	store, err := newFileNodeStore(t.TempDir())
+	require.NoError(t, err)
+
+	var nodes []*node
+	for i := 0; i < fileNodeStoreChunkNodes+1; i++ {
+		n, err := store.newNode()
+		require.NoError(t, err)
+		nodes = append(nodes, n)
+	}
+	nodes[0].nodeNum = 1
+	nodes[len(nodes)-1].nodeNum = 2
+	assert.Len(t, store.(*fileNodeStore).chunks, 2)
+
+	store.freeNode(nodes[0])
+	n, err := store.newNode()
+	require.NoError(t, err)
+	assert.Same(t, nodes[0], n, "freed nodes are reused")
+	assert.Zero(t, n.nodeNum, "reused nodes are zeroed")
+	assert.Equal(t, 2, nodes[len(nodes)-1].nodeNum)
+
+	require.NoError(t, store.close())
+}
+
+func TestNodeStorageErrors(t *testing.T) {
+	_, err := New(Options{NodeStorage: NodeStorage(5)})
+	assert.EqualError(t, err, "unsupported NodeStorage: 5")
+
+	_, err = New(Options{
+		NodeStorage:    NodeStorageFile,
+		NodeStorageDir: "/does/not/exist",
+	})
+	assert.ErrorContains(t, err, "creating node storage file: ")
+
+	_, network, err := net.ParseCIDR("1.1.1.0/24")
+	require.NoError(t, err)
+	tree, err := New(Options{NodeStorage: NodeStorageFile})
+	require.NoError(t, err)
+	require.NoError(t, tree.Insert(network, nil))
+	require.NoError(t, tree.Close())
+}
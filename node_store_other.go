@@ -0,0 +1,9 @@
+//go:build !(aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris)
+
+package mmdbwriter
+
+import "errors"
+
+func newFileNodeStore(string) (nodeStore, error) {
+	return nil, errors.New("NodeStorageFile is not supported on this platform")
+}
@@ -0,0 +1,236 @@
+package mmdbwriter
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/maxmind/mmdbwriter/inserter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+// Output describes one of the databases written by Tree.WriteOutputs.
+type Output struct {
+	// Writer is where the database is written.
+	Writer io.Writer
+
+	// RecordSize is the record size of the database. It defaults to the
+	// record size of the tree.
+	RecordSize int
+
+	// DatabaseType, if set, replaces the database type of the tree in the
+	// metadata.
+	DatabaseType string
+
+	// BuildEpoch, if not zero, replaces the build epoch of the tree in the
+	// metadata, e.g., to write a reproducible database for a test.
+	BuildEpoch int64
+
+	// Fields is a list of dot-separated paths to the fields of the records
+	// to keep, e.g., "country" or "location.time_zone". Other fields are
+	// removed, and networks whose records have none of the fields have no
+	// data in the database. Records that are not maps are kept unchanged.
+	// If Fields is empty, the records are written unchanged.
+	Fields []string
+}
+
+// WriteOutputs writes several databases derived from the tree concurrently,
+// e.g., a full database and a smaller one with a subset of the fields. It
+// returns the number of bytes written to each output.
+//
+// The tree is finalized once. Outputs without Fields share its search tree
+// and node numbering and only differ in how the records are written. For
+// outputs with Fields, a new tree is built with the projected records, as
+// removing fields may allow networks to be merged.
+//
+// The outputs are not passed to Options.Metrics. The tree must not be
+// modified while WriteOutputs is running. If writing any output fails, the
+// error for the first such output is returned after all of the writes have
+// finished.
+func (t *Tree) WriteOutputs(outputs []Output) ([]int64, error) {
+	if _, err := t.Expire(time.Now()); err != nil {
+		return nil, err
+	}
+	if t.nodeCount == 0 {
+		if err := t.finalize(); err != nil {
+			return nil, err
+		}
+	}
+
+	projections := make([]*fieldProjection, len(outputs))
+	for i, o := range outputs {
+		if o.Writer == nil {
+			return nil, fmt.Errorf("output %d: the writer is nil", i)
+		}
+		if o.RecordSize != 0 {
+			if _, err := newRecordEncoder(o.RecordSize); err != nil {
+				return nil, fmt.Errorf("output %d: %w", i, err)
+			}
+		}
+		if o.BuildEpoch < 0 {
+			return nil, fmt.Errorf("output %d: BuildEpoch must not be negative: %d", i, o.BuildEpoch)
+		}
+		if len(o.Fields) > 0 {
+			p, err := newFieldProjection(o.Fields)
+			if err != nil {
+				return nil, fmt.Errorf("output %d: %w", i, err)
+			}
+			projections[i] = p
+		}
+	}
+
+	sizes := make([]int64, len(outputs))
+	errs := make([]error, len(outputs))
+	var wg sync.WaitGroup
+	for i := range outputs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			sizes[i], errs[i] = t.writeOutput(outputs[i], projections[i])
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return sizes, fmt.Errorf("output %d: %w", i, err)
+		}
+	}
+	return sizes, nil
+}
+
+func (t *Tree) writeOutput(o Output, p *fieldProjection) (int64, error) {
+	if p != nil {
+		return t.writeProjectedOutput(o, p)
+	}
+
+	// The copy shares the nodes and the dataMap, which writing only reads.
+	v := *t
+	v.expiries = nil
+	v.metrics = nil
+	if o.RecordSize != 0 {
+		v.recordSize = o.RecordSize
+	}
+	if o.DatabaseType != "" {
+		v.databaseType = o.DatabaseType
+	}
+	if o.BuildEpoch != 0 {
+		v.buildEpoch = o.BuildEpoch
+	}
+	if v.recordSize != t.recordSize {
+		nodeCount := t.nodeCount - t.paddingNodes
+		v.paddingNodes = v.alignmentPadding(nodeCount)
+		v.nodeCount = nodeCount + v.paddingNodes
+		if v.nodeCount >= 1<<v.recordSize {
+			return 0, recordSizeError(v.nodeCount, v.recordSize)
+		}
+	}
+	return v.WriteTo(o.Writer)
+}
+
+func (t *Tree) writeProjectedOutput(o Output, p *fieldProjection) (int64, error) {
+	opts := t.options()
+	opts.Metrics = nil
+	if o.RecordSize != 0 {
+		opts.RecordSize = o.RecordSize
+	}
+	if o.DatabaseType != "" {
+		opts.DatabaseType = o.DatabaseType
+	}
+	if o.BuildEpoch != 0 {
+		opts.BuildEpoch = o.BuildEpoch
+	}
+	projected, err := New(opts)
+	if err != nil {
+		return 0, err
+	}
+	defer projected.Close() //nolint:errcheck // the nodes are only read by the write
+
+	err = t.root.walk(make(net.IP, t.treeDepth/8), 0, func(ip net.IP, prefixLen int, r record) error {
+		if r.recordType != recordTypeData {
+			return nil
+		}
+		value := p.apply(t.dataMap.load(r.value))
+		if m, ok := value.(mmdbtype.Map); ok && len(m) == 0 {
+			return nil
+		}
+		network := &net.IPNet{
+			IP:   make(net.IP, len(ip)),
+			Mask: net.CIDRMask(prefixLen, t.treeDepth),
+		}
+		copy(network.IP, ip)
+		return projected.insert(network, recordTypeData, inserter.ReplaceWith(value), nil)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return projected.WriteTo(o.Writer)
+}
+
+// fieldProjection is a tree of the map keys to keep. A nil fieldProjection
+// keeps the whole value.
+type fieldProjection struct {
+	keys map[mmdbtype.String]*fieldProjection
+}
+
+func newFieldProjection(fields []string) (*fieldProjection, error) {
+	root := &fieldProjection{keys: map[mmdbtype.String]*fieldProjection{}}
+	for _, field := range fields {
+		p := root
+		keys := strings.Split(field, ".")
+		for i, key := range keys {
+			if key == "" {
+				return nil, fmt.Errorf("invalid field: %q", field)
+			}
+			child, ok := p.keys[mmdbtype.String(key)]
+			if ok && child == nil {
+				// A parent of the field is already kept in full.
+				break
+			}
+			if i == len(keys)-1 {
+				p.keys[mmdbtype.String(key)] = nil
+				break
+			}
+			if !ok {
+				child = &fieldProjection{keys: map[mmdbtype.String]*fieldProjection{}}
+				p.keys[mmdbtype.String(key)] = child
+			}
+			p = child
+		}
+	}
+	return root, nil
+}
+
+// apply returns a copy of the value with only the projected fields. The
+// value passed in is not modified.
+func (p *fieldProjection) apply(v mmdbtype.DataType) mmdbtype.DataType {
+	if p == nil {
+		return v
+	}
+	m, ok := v.(mmdbtype.Map)
+	if !ok {
+		return v
+	}
+	newMap := mmdbtype.Map{}
+	for key, child := range p.keys {
+		e, ok := m[key]
+		if !ok {
+			continue
+		}
+		if child != nil {
+			// Only the fields of a map may be selected.
+			if _, ok := e.(mmdbtype.Map); !ok {
+				continue
+			}
+			e = child.apply(e)
+			if len(e.(mmdbtype.Map)) == 0 {
+				continue
+			}
+		}
+		newMap[key] = e
+	}
+	return newMap
+}
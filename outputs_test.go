@@ -0,0 +1,123 @@
+package mmdbwriter
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/oschwald/maxminddb-golang"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteOutputs(t *testing.T) {
+	for _, alignment := range []int{0, 4096} {
+		tree, err := New(Options{
+			BuildEpoch:           1000,
+			DatabaseType:         "City",
+			DataSectionAlignment: alignment,
+		})
+		require.NoError(t, err)
+
+		city := func(iso, name string) mmdbtype.Map {
+			return mmdbtype.Map{
+				"country": mmdbtype.Map{"iso_code": mmdbtype.String(iso)},
+				"city":    mmdbtype.Map{"names": mmdbtype.Map{"en": mmdbtype.String(name)}},
+			}
+		}
+		require.NoError(t, tree.Insert(mustNetwork(t, "1.1.0.0/24"), city("US", "a")))
+		require.NoError(t, tree.Insert(mustNetwork(t, "1.1.1.0/24"), city("US", "b")))
+		require.NoError(t, tree.Insert(mustNetwork(t, "2.2.2.0/24"), mmdbtype.Map{
+			"city": mmdbtype.Map{"names": mmdbtype.Map{"en": mmdbtype.String("c")}},
+		}))
+
+		full := &bytes.Buffer{}
+		recordSize32 := &bytes.Buffer{}
+		country := &bytes.Buffer{}
+		sizes, err := tree.WriteOutputs([]Output{
+			{Writer: full},
+			{Writer: recordSize32, RecordSize: 32},
+			{
+				Writer:       country,
+				RecordSize:   24,
+				DatabaseType: "Country",
+				BuildEpoch:   2000,
+				Fields:       []string{"country"},
+			},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []int64{int64(full.Len()), int64(recordSize32.Len()), int64(country.Len())}, sizes)
+
+		// Each output matches the database written by a tree built with the
+		// same options.
+		expectedFull := &bytes.Buffer{}
+		_, err = tree.WriteTo(expectedFull)
+		require.NoError(t, err)
+		assert.Equal(t, expectedFull.Bytes(), full.Bytes())
+
+		opts := tree.options()
+		opts.RecordSize = 32
+		expectedTree, err := New(opts)
+		require.NoError(t, err)
+		for _, network := range []string{"1.1.0.0/24", "1.1.1.0/24", "2.2.2.0/24"} {
+			_, value := tree.Get(mustNetwork(t, network).IP)
+			require.NoError(t, expectedTree.Insert(mustNetwork(t, network), value))
+		}
+		expected32 := &bytes.Buffer{}
+		_, err = expectedTree.WriteTo(expected32)
+		require.NoError(t, err)
+		assert.Equal(t, expected32.Bytes(), recordSize32.Bytes())
+
+		reader, err := maxminddb.FromBytes(country.Bytes())
+		require.NoError(t, err)
+		assert.Equal(t, "Country", reader.Metadata.DatabaseType)
+		assert.Equal(t, uint(24), reader.Metadata.RecordSize)
+		assert.Equal(t, uint(2000), reader.Metadata.BuildEpoch)
+
+		var record any
+		network, ok, err := reader.LookupNetwork(net.ParseIP("1.1.1.1"), &record)
+		require.NoError(t, err)
+		require.True(t, ok)
+		assert.Equal(t, map[string]any{"country": map[string]any{"iso_code": "US"}}, record)
+		assert.Equal(t, "1.1.0.0/23", network.String(), "the projected networks are merged")
+
+		_, ok, err = reader.LookupNetwork(net.ParseIP("2.2.2.2"), &record)
+		require.NoError(t, err)
+		assert.False(t, ok)
+	}
+}
+
+func TestWriteOutputsErrors(t *testing.T) {
+	tree, err := New(Options{})
+	require.NoError(t, err)
+
+	_, err = tree.WriteOutputs([]Output{{}})
+	assert.EqualError(t, err, "output 0: the writer is nil")
+
+	_, err = tree.WriteOutputs([]Output{{Writer: &bytes.Buffer{}, RecordSize: 30}})
+	assert.EqualError(t, err, "output 0: unsupported record size of 30")
+
+	_, err = tree.WriteOutputs([]Output{{Writer: &bytes.Buffer{}, BuildEpoch: -1}})
+	assert.EqualError(t, err, "output 0: BuildEpoch must not be negative: -1")
+
+	_, err = tree.WriteOutputs([]Output{{Writer: &bytes.Buffer{}, Fields: []string{"a."}}})
+	assert.EqualError(t, err, `output 0: invalid field: "a."`)
+}
+
+func TestFieldProjection(t *testing.T) {
+	p, err := newFieldProjection([]string{"a.b", "a", "c.d.e", "f.g"})
+	require.NoError(t, err)
+
+	value := mmdbtype.Map{
+		"a": mmdbtype.Map{"b": mmdbtype.Bool(true), "x": mmdbtype.Bool(true)},
+		"c": mmdbtype.Map{"d": mmdbtype.Map{"e": mmdbtype.Uint32(1), "y": mmdbtype.Uint32(2)}},
+		"f": mmdbtype.String("not a map"),
+		"z": mmdbtype.String("z"),
+	}
+	assert.Equal(t, mmdbtype.Map{
+		"a": mmdbtype.Map{"b": mmdbtype.Bool(true), "x": mmdbtype.Bool(true)},
+		"c": mmdbtype.Map{"d": mmdbtype.Map{"e": mmdbtype.Uint32(1)}},
+	}, p.apply(value))
+	assert.Equal(t, mmdbtype.String("x"), p.apply(mmdbtype.String("x")))
+}
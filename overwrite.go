@@ -0,0 +1,79 @@
+package mmdbwriter
+
+import (
+	"errors"
+	"fmt"
+	"net"
+)
+
+// OverwritePolicy determines how inserting a network affects the existing
+// data for more specific networks within it, e.g., the data for the /24
+// networks within a /16 being inserted.
+type OverwritePolicy int
+
+const (
+	// OverwriteMerge calls the inserter function with the existing value of
+	// each more specific network, merging the inserted value into them.
+	// With inserter.ReplaceWith, this replaces the more specific networks.
+	// This is the default.
+	OverwriteMerge OverwritePolicy = iota
+
+	// OverwriteReplace replaces the more specific networks. The inserter
+	// function is called as if they had no data, so their data is
+	// discarded even if the inserter function would merge it.
+	OverwriteReplace
+
+	// OverwriteKeep keeps the data for the more specific networks
+	// unchanged. The inserted value is only applied to the rest of the
+	// network.
+	OverwriteKeep
+
+	// OverwriteError returns an error wrapping ErrOverwrite if the network
+	// contains a more specific network with data. The tree is not modified.
+	OverwriteError
+)
+
+func (p OverwritePolicy) String() string {
+	switch p {
+	case OverwriteMerge:
+		return "OverwriteMerge"
+	case OverwriteReplace:
+		return "OverwriteReplace"
+	case OverwriteKeep:
+		return "OverwriteKeep"
+	case OverwriteError:
+		return "OverwriteError"
+	default:
+		return fmt.Sprintf("OverwritePolicy(%d)", int(p))
+	}
+}
+
+// ErrOverwrite is wrapped by the error returned when inserting a network
+// would overwrite a more specific network and the OverwritePolicy is
+// OverwriteError.
+var ErrOverwrite = errors.New("the network contains a more specific network with data")
+
+// checkOverwrite returns an error wrapping ErrOverwrite if the network, in
+// tree form, contains a record with data for a more specific network.
+func (t *Tree) checkOverwrite(ip net.IP, prefixLen int) error {
+	// walkWithin modifies the IP while walking.
+	walkIP := make(net.IP, len(ip))
+	copy(walkIP, ip)
+	return t.walkWithin(walkIP, prefixLen, func(recIP net.IP, recPrefixLen int, r record) error {
+		if r.recordType != recordTypeData || !r.moreSpecificThan(prefixLen) {
+			return nil
+		}
+		return fmt.Errorf(
+			"inserting %s would overwrite %s: %w",
+			t.formatNetwork(ip, prefixLen),
+			t.formatNetwork(recIP, recPrefixLen),
+			ErrOverwrite,
+		)
+	})
+}
+
+// formatNetwork formats a network in tree form. Networks in the IPv4 subtree
+// of an IPv6 tree are formatted as IPv4 networks.
+func (t *Tree) formatNetwork(ip net.IP, prefixLen int) string {
+	return t.network(ip, prefixLen).String()
+}
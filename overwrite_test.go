@@ -0,0 +1,192 @@
+package mmdbwriter
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/inserter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOverwritePolicy(t *testing.T) {
+	sub := mmdbtype.Map{"sub": mmdbtype.Bool(true)}
+	super := mmdbtype.Map{"super": mmdbtype.Bool(true)}
+	both := mmdbtype.Map{"sub": mmdbtype.Bool(true), "super": mmdbtype.Bool(true)}
+
+	type expectedGet struct {
+		ip      string
+		network string
+		value   mmdbtype.DataType
+	}
+
+	tests := []struct {
+		policy      OverwritePolicy
+		expectedErr string
+		expected    []expectedGet
+	}{
+		{
+			policy: OverwriteMerge,
+			expected: []expectedGet{
+				{ip: "1.1.1.1", network: "1.1.1.0/24", value: both},
+				{ip: "1.1.2.1", network: "1.1.2.0/23", value: super},
+			},
+		},
+		{
+			policy: OverwriteReplace,
+			expected: []expectedGet{
+				{ip: "1.1.1.1", network: "1.1.0.0/16", value: super},
+				{ip: "1.1.2.1", network: "1.1.0.0/16", value: super},
+			},
+		},
+		{
+			policy: OverwriteKeep,
+			expected: []expectedGet{
+				{ip: "1.1.1.1", network: "1.1.1.0/24", value: sub},
+				{ip: "1.1.2.1", network: "1.1.2.0/23", value: super},
+			},
+		},
+		{
+			policy: OverwriteError,
+			expectedErr: "inserting 1.1.0.0/16 would overwrite 1.1.1.0/24: " +
+				"the network contains a more specific network with data",
+			expected: []expectedGet{
+				{ip: "1.1.1.1", network: "1.1.1.0/24", value: sub},
+				{ip: "1.1.2.1", network: "1.1.2.0/23", value: nil},
+			},
+		},
+	}
+
+	for _, ipVersion := range []int{4, 6} {
+		for _, test := range tests {
+			t.Run(fmt.Sprintf("IPv%d %s", ipVersion, test.policy), func(t *testing.T) {
+				tree, err := New(Options{
+					IPVersion:       ipVersion,
+					Inserter:        inserter.TopLevelMergeWith,
+					OverwritePolicy: test.policy,
+				})
+				require.NoError(t, err)
+
+				_, network, err := net.ParseCIDR("1.1.1.0/24")
+				require.NoError(t, err)
+				require.NoError(t, tree.Insert(network, sub))
+
+				_, supernet, err := net.ParseCIDR("1.1.0.0/16")
+				require.NoError(t, err)
+				err = tree.Insert(supernet, super)
+				if test.expectedErr != "" {
+					require.ErrorIs(t, err, ErrOverwrite)
+					assert.EqualError(t, err, test.expectedErr)
+				} else {
+					require.NoError(t, err)
+				}
+
+				for _, e := range test.expected {
+					network, value := tree.Get(net.ParseIP(e.ip).To4())
+					assert.Equal(t, e.network, network.String(), e.ip)
+					assert.Equal(t, e.value, value, e.ip)
+				}
+
+				// Inserting a network within an existing network is not
+				// affected by the policy.
+				_, subnet, err := net.ParseCIDR("1.1.1.0/25")
+				require.NoError(t, err)
+				require.NoError(t, tree.Insert(subnet, super))
+				_, value := tree.Get(net.ParseIP("1.1.1.1").To4())
+				assert.Equal(t, super["super"], value.(mmdbtype.Map)["super"])
+			})
+		}
+	}
+
+	_, err := New(Options{OverwritePolicy: OverwritePolicy(9)})
+	assert.EqualError(t, err, "unsupported OverwritePolicy: 9")
+}
+
+func TestOverwritePolicyNested(t *testing.T) {
+	a := mmdbtype.Map{"a": mmdbtype.Bool(true)}
+	b := mmdbtype.Map{"b": mmdbtype.Bool(true)}
+	c := mmdbtype.Map{"c": mmdbtype.Bool(true)}
+	ab := mmdbtype.Map{"a": mmdbtype.Bool(true), "b": mmdbtype.Bool(true)}
+	ac := mmdbtype.Map{"a": mmdbtype.Bool(true), "c": mmdbtype.Bool(true)}
+
+	// The parts of 1.0.0.0/8 that were split off by inserting 1.1.1.0/24
+	// are not more specific networks when inserting 1.1.0.0/16.
+	tests := []struct {
+		policy      OverwritePolicy
+		expectedErr string
+		expected    map[string]string
+		values      map[string]mmdbtype.DataType
+	}{
+		{
+			policy: OverwriteKeep,
+			expected: map[string]string{
+				"1.1.1.1":   "1.1.1.0/24",
+				"1.1.2.1":   "1.1.2.0/23",
+				"1.1.128.1": "1.1.128.0/17",
+				"1.2.0.1":   "1.2.0.0/15",
+			},
+			values: map[string]mmdbtype.DataType{
+				"1.1.1.1":   ab,
+				"1.1.2.1":   ac,
+				"1.1.128.1": ac,
+				"1.2.0.1":   a,
+			},
+		},
+		{
+			policy: OverwriteError,
+			expectedErr: "inserting 1.1.0.0/16 would overwrite 1.1.1.0/24: " +
+				"the network contains a more specific network with data",
+			expected: map[string]string{
+				"1.1.1.1":   "1.1.1.0/24",
+				"1.1.2.1":   "1.1.2.0/23",
+				"1.1.128.1": "1.1.128.0/17",
+			},
+			values: map[string]mmdbtype.DataType{
+				"1.1.1.1":   ab,
+				"1.1.2.1":   a,
+				"1.1.128.1": a,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.policy.String(), func(t *testing.T) {
+			tree, err := New(Options{
+				IPVersion:       4,
+				Inserter:        inserter.TopLevelMergeWith,
+				OverwritePolicy: test.policy,
+			})
+			require.NoError(t, err)
+
+			for _, insert := range []struct {
+				network string
+				value   mmdbtype.DataType
+			}{
+				{"1.0.0.0/8", a},
+				{"1.1.1.0/24", b},
+			} {
+				_, network, err := net.ParseCIDR(insert.network)
+				require.NoError(t, err)
+				require.NoError(t, tree.Insert(network, insert.value))
+			}
+
+			_, network, err := net.ParseCIDR("1.1.0.0/16")
+			require.NoError(t, err)
+			err = tree.Insert(network, c)
+			if test.expectedErr != "" {
+				require.ErrorIs(t, err, ErrOverwrite)
+				assert.EqualError(t, err, test.expectedErr)
+			} else {
+				require.NoError(t, err)
+			}
+
+			for ip, expectedNetwork := range test.expected {
+				network, value := tree.Get(net.ParseIP(ip).To4())
+				assert.Equal(t, expectedNetwork, network.String(), ip)
+				assert.Equal(t, test.values[ip], value, ip)
+			}
+		})
+	}
+}
@@ -0,0 +1,547 @@
+package mmdbwriter
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"runtime"
+	"sort"
+	"sync"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+const defaultPartitionBits = 8
+
+// NetworkRecord is a network and the value to insert for it.
+type NetworkRecord struct {
+	Network *net.IPNet
+	Value   mmdbtype.DataType
+}
+
+// ParallelOptions configures a ParallelBuilder.
+type ParallelOptions struct {
+	// PartitionBits is the number of leading bits used to partition the
+	// address space. For instance, 8 partitions IPv4 by /8 and IPv6 by
+	// /8. IPv4 networks in an IPv6 tree are partitioned by their IPv4
+	// bits. It must be between 1 and 16. If zero, 8 is used.
+	PartitionBits int
+
+	// Workers is the number of goroutines to insert with. If zero,
+	// runtime.GOMAXPROCS(0) is used.
+	Workers int
+}
+
+// ParallelBuilder builds a Tree by inserting into independent subtrees
+// concurrently. The address space is partitioned by the leading bits of
+// each network. Each partition is built as a separate tree by a single
+// worker, and the subtrees are stitched together under a single root once
+// all records have been inserted.
+//
+// Records for a partition are inserted in the order they are received, so
+// the resulting tree is the same as if the records had been inserted
+// sequentially with Tree.Insert. A network that spans several partitions
+// is inserted into each of them, so a ParallelBuilder works best when most
+// networks are longer than the partitions.
+type ParallelBuilder struct {
+	opts          Options
+	partitionBits int
+	workers       int
+	ipVersion     int
+	treeDepth     int
+}
+
+// NewParallelBuilder returns a ParallelBuilder for trees created with the
+// options. The Inserter, InsertMiddleware, and Metrics, if set, are called
+// concurrently from multiple goroutines and must be safe for concurrent use.
+// The memory high-water marks reported to Metrics are for each partition.
+// Options.TrackSources, Options.TrackDuplicates, and
+// Options.DuplicatePolicy are not supported, and Options.NodeStorage and
+// Options.ValueStorage must be NodeStorageMemory and ValueStorageMemory.
+// Options.MaxNodes and Options.MaxDataSize are enforced for each partition
+// while inserting and for the whole tree after stitching.
+func NewParallelBuilder(opts Options, popts ParallelOptions) (*ParallelBuilder, error) {
+	if opts.TrackSources {
+		return nil, errors.New("TrackSources is not supported by ParallelBuilder")
+	}
+	if opts.TrackDuplicates || opts.DuplicatePolicy != DuplicateMerge {
+		return nil, errors.New("duplicate tracking is not supported by ParallelBuilder")
+	}
+	if opts.NodeStorage != NodeStorageMemory {
+		return nil, errors.New("only NodeStorageMemory is supported by ParallelBuilder")
+	}
+	if opts.ValueStorage != ValueStorageMemory {
+		return nil, errors.New("only ValueStorageMemory is supported by ParallelBuilder")
+	}
+	if opts.IPv4Placement != IPv4PlacementCompatible {
+		return nil, errors.New("IPv4Placement is not supported by ParallelBuilder")
+	}
+	if popts.PartitionBits == 0 {
+		popts.PartitionBits = defaultPartitionBits
+	}
+	if popts.PartitionBits < 1 || popts.PartitionBits > 16 {
+		return nil, fmt.Errorf(
+			"PartitionBits must be between 1 and 16: %d",
+			popts.PartitionBits,
+		)
+	}
+	if popts.Workers < 0 {
+		return nil, fmt.Errorf("Workers must not be negative: %d", popts.Workers)
+	}
+	if popts.Workers == 0 {
+		popts.Workers = runtime.GOMAXPROCS(0)
+	}
+
+	// We create a tree to validate the options so that invalid options
+	// are reported here rather than by the workers.
+	tree, err := New(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ParallelBuilder{
+		opts:          opts,
+		partitionBits: popts.PartitionBits,
+		workers:       popts.Workers,
+		ipVersion:     tree.ipVersion,
+		treeDepth:     tree.treeDepth,
+	}, nil
+}
+
+// fallbackPartition is the partition id used for the tree that the
+// partition trees are grafted into.
+const fallbackPartition = -1
+
+// partitionedRecord is a record routed to a partition.
+type partitionedRecord struct {
+	partition int
+	network   *net.IPNet
+	value     mmdbtype.DataType
+}
+
+// wideRecord is a record whose network spans more than one partition. For
+// each region, the partitions in [first, last] are overlapped. If last is
+// less than first, no partitions in the region are overlapped.
+type wideRecord struct {
+	record partitionedRecord
+	first  [2]int
+	last   [2]int
+}
+
+// Build inserts the records read from the channel and returns the
+// resulting tree. It reads until the channel is closed, even if an error
+// occurs. Once an error occurs, the remaining records are discarded and the
+// first error is returned.
+func (b *ParallelBuilder) Build(records <-chan NetworkRecord) (*Tree, error) {
+	// The last input is for the fallback tree.
+	inputs := make([]chan partitionedRecord, b.workers+1)
+	results := make([]map[int]*Tree, len(inputs))
+	errs := make([]error, len(inputs))
+
+	var wg sync.WaitGroup
+	for i := range inputs {
+		inputs[i] = make(chan partitionedRecord, 1024)
+		results[i] = map[int]*Tree{}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = b.work(inputs[i], results[i])
+		}(i)
+	}
+
+	rt := &router{
+		b: b,
+		send: func(pr partitionedRecord) {
+			if pr.partition == fallbackPartition {
+				inputs[b.workers] <- pr
+				return
+			}
+			inputs[pr.partition%b.workers] <- pr
+		},
+		created: map[int]bool{},
+	}
+
+	var routeErr error
+	for r := range records {
+		if routeErr != nil {
+			continue
+		}
+		routeErr = rt.route(r)
+	}
+	for _, input := range inputs {
+		close(input)
+	}
+	wg.Wait()
+
+	if routeErr != nil {
+		return nil, routeErr
+	}
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	partitions := map[int]*Tree{}
+	for _, r := range results {
+		for p, tree := range r {
+			partitions[p] = tree
+		}
+	}
+	return b.stitch(partitions)
+}
+
+// work inserts the records into the trees for their partitions. The trees
+// are created as needed.
+func (b *ParallelBuilder) work(input <-chan partitionedRecord, trees map[int]*Tree) error {
+	var err error
+	for pr := range input {
+		if err != nil {
+			// We drain the channel so that Build does not block.
+			continue
+		}
+		tree, ok := trees[pr.partition]
+		if !ok {
+			tree, err = New(b.opts)
+			if err != nil {
+				continue
+			}
+			trees[pr.partition] = tree
+		}
+		if insertErr := tree.Insert(pr.network, pr.value); insertErr != nil {
+			err = fmt.Errorf("inserting %s: %w", pr.network, insertErr)
+		}
+	}
+	return err
+}
+
+// partitionDepth returns the depth in the tree of the partitions in the
+// given region. Region 0 is the entire tree. Region 1 is the IPv4 subtree
+// of an IPv6 tree.
+func (b *ParallelBuilder) partitionDepth(region int) int {
+	if region == 1 {
+		return 96 + b.partitionBits
+	}
+	return b.partitionBits
+}
+
+// partitionNetwork returns the network for the partition in tree
+// coordinates.
+func (b *ParallelBuilder) partitionNetwork(partition int) (net.IP, int) {
+	region := partition >> b.partitionBits
+	index := partition & (1<<b.partitionBits - 1)
+	depth := b.partitionDepth(region)
+
+	ip := make(net.IP, b.treeDepth/8)
+	for i := 0; i < b.partitionBits; i++ {
+		if index&(1<<(b.partitionBits-1-i)) != 0 {
+			setBitAt(ip, depth-b.partitionBits+i)
+		}
+	}
+	return ip, depth
+}
+
+// router routes records to the partitions.
+//
+// A record whose network is within a single partition is only inserted
+// into the tree for that partition. A record whose network spans several
+// partitions is inserted into the fallback tree and into the tree of each
+// partition it overlaps. The fallback tree provides the data for the
+// partitions that have no records of their own. To avoid creating a tree
+// for every partition, the partition trees are created when the first
+// record within the partition is received. The earlier records spanning the
+// partition are inserted into the new tree first so that the records are
+// inserted in the order received.
+type router struct {
+	b       *ParallelBuilder
+	send    func(partitionedRecord)
+	created map[int]bool
+	wide    []wideRecord
+}
+
+func (rt *router) route(r NetworkRecord) error {
+	b := rt.b
+	if r.Network == nil {
+		return errors.New("the network must not be nil")
+	}
+	prefixLen, bits := r.Network.Mask.Size()
+	ip := r.Network.IP
+	if bits == 32 {
+		if ipv4 := ip.To4(); ipv4 != nil {
+			ip = ipv4
+		}
+	}
+	if b.treeDepth == 32 && bits == 128 && len(ip) == net.IPv6len {
+		ip, prefixLen = unmapIPv4(ip, prefixLen)
+		bits = len(ip) * 8
+	}
+	if len(ip) != bits/8 || bits > b.treeDepth {
+		return fmt.Errorf("%s is not a valid network for an IPv%d tree", r.Network, b.ipVersion)
+	}
+	mask := net.CIDRMask(prefixLen, bits)
+	ip = ip.Mask(mask)
+	network := &net.IPNet{IP: ip, Mask: mask}
+
+	if bits == 32 && b.treeDepth == 128 {
+		ip = ipV4ToV6(ip)
+		prefixLen += 96
+	}
+
+	// The number of leading zero bits, which determines whether the
+	// network is in or contains the IPv4 subtree.
+	zeroBits := 0
+	for zeroBits < b.treeDepth && bitAt(ip, zeroBits) == 0 {
+		zeroBits++
+	}
+	inIPv4 := b.treeDepth == 128 && prefixLen >= 96 && zeroBits >= 96
+	containsIPv4 := b.treeDepth == 128 && prefixLen < 96 && zeroBits >= prefixLen
+
+	wr := wideRecord{
+		record: partitionedRecord{network: network, value: r.Value},
+		first:  [2]int{0, 0},
+		last:   [2]int{-1, -1},
+	}
+	isWide := false
+	for region := 0; region < 2; region++ {
+		if (region == 0 && inIPv4) || (region == 1 && !inIPv4 && !containsIPv4) {
+			continue
+		}
+		first, last := rt.partitions(ip, prefixLen, region)
+		wr.first[region] = first
+		wr.last[region] = last
+		if first == last {
+			rt.sendToPartition(wr.record, first)
+			continue
+		}
+		isWide = true
+	}
+	if !isWide {
+		return nil
+	}
+
+	rt.wide = append(rt.wide, wr)
+	fallback := wr.record
+	fallback.partition = fallbackPartition
+	rt.send(fallback)
+	for region := 0; region < 2; region++ {
+		if wr.first[region] == wr.last[region] {
+			continue
+		}
+		for p := wr.first[region]; p <= wr.last[region]; p++ {
+			if rt.created[p] {
+				pr := wr.record
+				pr.partition = p
+				rt.send(pr)
+			}
+		}
+	}
+	return nil
+}
+
+// partitions returns the first and last partitions in the region that the
+// network overlaps.
+func (rt *router) partitions(ip net.IP, prefixLen, region int) (int, int) {
+	b := rt.b
+	depth := b.partitionDepth(region)
+	start := depth - b.partitionBits
+
+	fixedBits := b.partitionBits
+	if prefixLen < depth {
+		fixedBits = prefixLen - start
+		if fixedBits < 0 {
+			fixedBits = 0
+		}
+	}
+	first := 0
+	for i := start; i < start+fixedBits; i++ {
+		first = first<<1 | int(bitAt(ip, i))
+	}
+	first <<= b.partitionBits - fixedBits
+	last := first + 1<<(b.partitionBits-fixedBits) - 1
+
+	offset := region << b.partitionBits
+	return offset + first, offset + last
+}
+
+// sendToPartition sends the record to the partition, creating the partition
+// if necessary.
+func (rt *router) sendToPartition(pr partitionedRecord, partition int) {
+	if !rt.created[partition] {
+		rt.created[partition] = true
+		region := partition >> rt.b.partitionBits
+		for _, wr := range rt.wide {
+			if wr.first[region] <= partition && partition <= wr.last[region] {
+				replay := wr.record
+				replay.partition = partition
+				rt.send(replay)
+			}
+		}
+	}
+	pr.partition = partition
+	rt.send(pr)
+}
+
+// stitch grafts the partition trees into the fallback tree.
+func (b *ParallelBuilder) stitch(partitions map[int]*Tree) (*Tree, error) {
+	tree, ok := partitions[fallbackPartition]
+	if !ok {
+		var err error
+		tree, err = New(b.opts)
+		if err != nil {
+			return nil, err
+		}
+	}
+	delete(partitions, fallbackPartition)
+
+	ids := make([]int, 0, len(partitions))
+	for id := range partitions {
+		ids = append(ids, id)
+	}
+	// The partitions in the IPv4 subtree are grafted first.
+	sort.Slice(ids, func(i, j int) bool {
+		if ids[i]>>b.partitionBits != ids[j]>>b.partitionBits {
+			return ids[i] > ids[j]
+		}
+		return ids[i] < ids[j]
+	})
+
+	for _, id := range ids {
+		src := partitions[id]
+		if id == 0 && b.treeDepth == 128 {
+			// The first partition contains the IPv4 subtree, which has
+			// already been assembled in the fallback tree. We move it,
+			// including its data, to the partition tree so that it is
+			// kept when grafting.
+			dstIPv4, err := tree.recordAt(make(net.IP, net.IPv6len), 96)
+			if err != nil {
+				return nil, err
+			}
+			srcIPv4, err := src.recordAt(make(net.IP, net.IPv6len), 96)
+			if err != nil {
+				return nil, err
+			}
+			*srcIPv4 = src.adopt(tree.dataMap, *dstIPv4)
+			*dstIPv4 = record{}
+		}
+		tree.report.add(src.report)
+		ip, depth := b.partitionNetwork(id)
+		if err := tree.graft(src, ip, depth); err != nil {
+			return nil, err
+		}
+	}
+
+	var ipv4Root *node
+	if tree.ipVersion == 6 && !tree.disableIPv4Aliasing {
+		r, err := tree.recordAt(make(net.IP, net.IPv6len), 96)
+		if err != nil {
+			return nil, err
+		}
+		ipv4Root = r.node
+	}
+	if err := tree.stitchNode(tree.root, ipv4Root); err != nil {
+		return nil, err
+	}
+	tree.liveNodes = tree.root.finalize(0)
+
+	if err := tree.checkLimits(); err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
+
+// graft replaces the record for the network in t with the record for the
+// same network in src. The data in the record is moved to t's dataMap.
+// src must not be used afterward.
+func (t *Tree) graft(src *Tree, ip net.IP, depth int) error {
+	dst, err := t.recordAt(ip, depth)
+	if err != nil {
+		return err
+	}
+	srcRecord, err := src.recordAt(ip, depth)
+	if err != nil {
+		return err
+	}
+
+	t.release(*dst)
+	*dst = t.adopt(src.dataMap, *srcRecord)
+	return nil
+}
+
+// recordAt returns the record for the network at the given depth, splitting
+// records as necessary.
+func (t *Tree) recordAt(ip net.IP, depth int) (*record, error) {
+	n := t.root
+	for d := 0; ; d++ {
+		r := &n.children[bitAt(ip, d)]
+		if d == depth-1 {
+			return r, nil
+		}
+		switch r.recordType {
+		case recordTypeNode, recordTypeFixedNode:
+		case recordTypeAlias:
+			return nil, fmt.Errorf("unexpected alias at depth %d", d+1)
+		default:
+			if r.recordType == recordTypeData {
+				t.dataMap.retain(r.value)
+			}
+			child, err := t.nodes.newNode()
+			if err != nil {
+				return nil, err
+			}
+			child.children = [2]record{*r, *r}
+			r.node = child
+			r.value = 0
+			r.recordType = recordTypeNode
+		}
+		n = r.node
+	}
+}
+
+// release removes the references to data in the record and its subtree.
+func (t *Tree) release(r record) {
+	switch r.recordType {
+	case recordTypeData:
+		t.dataMap.remove(r.value)
+	case recordTypeNode, recordTypeFixedNode:
+		t.release(r.node.children[0])
+		t.release(r.node.children[1])
+	default:
+	}
+}
+
+// adopt moves the data in the record and its subtree from src to t's
+// dataMap. The nodes are reused.
+func (t *Tree) adopt(src *dataMap, r record) record {
+	switch r.recordType {
+	case recordTypeData:
+		r.value = t.dataMap.adopt(src, r.value)
+	case recordTypeNode, recordTypeFixedNode:
+		r.node.children[0] = t.adopt(src, r.node.children[0])
+		r.node.children[1] = t.adopt(src, r.node.children[1])
+	default:
+	}
+	return r
+}
+
+// stitchNode points the aliases in the subtree at the IPv4 root and merges
+// identical sibling records, which may occur at partition boundaries.
+func (t *Tree) stitchNode(n *node, ipv4Root *node) error {
+	for i := range n.children {
+		r := &n.children[i]
+		switch r.recordType {
+		case recordTypeAlias:
+			r.node = ipv4Root
+		case recordTypeNode, recordTypeFixedNode:
+			if err := t.stitchNode(r.node, ipv4Root); err != nil {
+				return err
+			}
+			if r.recordType == recordTypeNode {
+				if _, err := r.merge(t.dataMap, t.nodes); err != nil {
+					return err
+				}
+			}
+		default:
+		}
+	}
+	return nil
+}
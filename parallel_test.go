@@ -0,0 +1,194 @@
+package mmdbwriter
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"net"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/inserter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParallelBuilder(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  Options
+		popts ParallelOptions
+	}{
+		{
+			name: "IPv6 defaults",
+		},
+		{
+			name:  "IPv6 /16 partitions",
+			popts: ParallelOptions{PartitionBits: 16, Workers: 3},
+		},
+		{
+			name:  "IPv6 one partition bit",
+			popts: ParallelOptions{PartitionBits: 1, Workers: 1},
+		},
+		{
+			name:  "IPv6 without aliasing",
+			opts:  Options{DisableIPv4Aliasing: true},
+			popts: ParallelOptions{Workers: 4},
+		},
+		{
+			name:  "IPv6 with reserved networks",
+			opts:  Options{IncludeReservedNetworks: true},
+			popts: ParallelOptions{PartitionBits: 4},
+		},
+		{
+			name:  "IPv4",
+			opts:  Options{IPVersion: 4},
+			popts: ParallelOptions{PartitionBits: 8, Workers: 2},
+		},
+		{
+			name:  "IPv4 merge inserter",
+			opts:  Options{IPVersion: 4, Inserter: inserter.TopLevelMergeWith},
+			popts: ParallelOptions{PartitionBits: 3},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.opts.BuildEpoch = 1000
+			records := testNetworkRecords(t, test.opts.IPVersion)
+
+			sequential, err := New(test.opts)
+			require.NoError(t, err)
+			for _, r := range records {
+				err := sequential.Insert(r.Network, r.Value)
+				if err != nil {
+					// The parallel build must fail as well, which is
+					// checked below.
+					sequential = nil
+					break
+				}
+			}
+
+			pb, err := NewParallelBuilder(test.opts, test.popts)
+			require.NoError(t, err)
+
+			ch := make(chan NetworkRecord)
+			go func() {
+				for _, r := range records {
+					ch <- r
+				}
+				close(ch)
+			}()
+			parallel, err := pb.Build(ch)
+			if sequential == nil {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			assert.Equal(t, sequential.liveNodes, parallel.liveNodes)
+			assert.Equal(t, sequential.dataMap.size, parallel.dataMap.size)
+			assertRefCounts(t, parallel)
+			assertAggregated(t, parallel.root)
+			require.NoError(t, parallel.Check())
+
+			expected := &bytes.Buffer{}
+			_, err = sequential.WriteTo(expected)
+			require.NoError(t, err)
+
+			actual := &bytes.Buffer{}
+			_, err = parallel.WriteTo(actual)
+			require.NoError(t, err)
+
+			assert.Equal(t, expected.Bytes(), actual.Bytes())
+		})
+	}
+}
+
+func TestParallelBuilderErrors(t *testing.T) {
+	_, err := NewParallelBuilder(Options{TrackSources: true}, ParallelOptions{})
+	assert.EqualError(t, err, "TrackSources is not supported by ParallelBuilder")
+
+	_, err = NewParallelBuilder(Options{}, ParallelOptions{PartitionBits: 17})
+	assert.EqualError(t, err, "PartitionBits must be between 1 and 16: 17")
+
+	_, err = NewParallelBuilder(Options{}, ParallelOptions{Workers: -1})
+	assert.EqualError(t, err, "Workers must not be negative: -1")
+
+	pb, err := NewParallelBuilder(Options{IPVersion: 4}, ParallelOptions{})
+	require.NoError(t, err)
+
+	_, network, err := net.ParseCIDR("2001:db8::/32")
+	require.NoError(t, err)
+
+	// The channel is buffered so that the test fails rather than blocks
+	// if Build stops reading after the error.
+	ch := make(chan NetworkRecord, 2)
+	ch <- NetworkRecord{Network: network, Value: mmdbtype.String("a")}
+	ch <- NetworkRecord{Network: network, Value: mmdbtype.String("b")}
+	close(ch)
+	_, err = pb.Build(ch)
+	assert.EqualError(t, err, "2001:db8::/32 is not a valid network for an IPv4 tree")
+	assert.Empty(t, ch)
+
+	pb, err = NewParallelBuilder(Options{}, ParallelOptions{})
+	require.NoError(t, err)
+
+	_, network, err = net.ParseCIDR("::ffff:1.1.1.0/120")
+	require.NoError(t, err)
+
+	ch = make(chan NetworkRecord, 1)
+	ch <- NetworkRecord{Network: network, Value: mmdbtype.String("a")}
+	close(ch)
+	_, err = pb.Build(ch)
+	assert.EqualError(
+		t,
+		err,
+		"inserting 1.1.1.0/24: attempt to insert 1.1.1.0/120, which is in an aliased network",
+	)
+}
+
+// testNetworkRecords returns a deterministic set of records with networks
+// of varying sizes, including networks that span several partitions.
+func testNetworkRecords(t *testing.T, ipVersion int) []NetworkRecord {
+	r := rand.New(rand.NewSource(1)) //nolint:gosec // deterministic test data
+	values := []mmdbtype.DataType{
+		mmdbtype.String("a"),
+		mmdbtype.String("b"),
+		mmdbtype.Map{"c": mmdbtype.Uint32(1)},
+		mmdbtype.Map{"d": mmdbtype.Uint32(2)},
+	}
+
+	networks := []string{"0.0.0.0/0", "0.0.0.0/2", "10.0.0.0/7"}
+	if ipVersion != 4 {
+		networks = append(networks, "::/0", "2000::/3", "::/64", "::/100", "2001::/16")
+	}
+	for i := 0; i < 2000; i++ {
+		ip := make(net.IP, net.IPv4len)
+		bits := 32
+		if ipVersion != 4 && r.Intn(2) == 0 {
+			ip = make(net.IP, net.IPv6len)
+			bits = 128
+		}
+		r.Read(ip)
+		prefixLen := r.Intn(bits/2) + bits/2
+		if r.Intn(10) == 0 {
+			prefixLen = r.Intn(bits/2) + 1
+		}
+		networks = append(
+			networks,
+			fmt.Sprintf("%s/%d", ip.Mask(net.CIDRMask(prefixLen, bits)), prefixLen),
+		)
+	}
+
+	var records []NetworkRecord
+	for _, n := range networks {
+		_, network, err := net.ParseCIDR(n)
+		require.NoError(t, err)
+		records = append(records, NetworkRecord{
+			Network: network,
+			Value:   values[r.Intn(len(values))],
+		})
+	}
+	return records
+}
@@ -0,0 +1,53 @@
+package mmdbwriter
+
+import "net"
+
+// PrefixHistogram describes the networks with data in a tree by prefix
+// length. It is returned by Tree.PrefixHistogram.
+type PrefixHistogram struct {
+	// IPv4 is the number of IPv4 networks with data for each prefix
+	// length, i.e., IPv4[24] is the number of /24 networks. In an IPv6
+	// tree, these are the networks in the IPv4 subtree.
+	IPv4 [33]int
+
+	// IPv6 is the number of the other networks with data in an IPv6 tree
+	// for each prefix length.
+	IPv6 [129]int
+
+	// Depth is the depth of the search tree, i.e., the longest prefix
+	// length of any record in the tree's address space, including records
+	// without data. In an IPv6 tree, an IPv4 /32 is at depth 128.
+	Depth int
+}
+
+// PrefixHistogram counts the networks with data in the tree by prefix
+// length. The networks are those of the tree's records, so adjacent
+// networks with equal data that were merged are counted once, and networks
+// that were split by a later insert are counted for each part. Comparing
+// the histograms of successive builds may be used to detect anomalies in
+// the source data, e.g., a sudden increase in the number of /32 networks.
+//
+// The aliased networks, e.g., ::ffff:0:0/96, are not counted.
+func (t *Tree) PrefixHistogram() PrefixHistogram {
+	var h PrefixHistogram
+	// The function never returns an error.
+	//nolint:errcheck // see above
+	t.root.walk(make(net.IP, t.treeDepth/8), 0, func(ip net.IP, prefixLen int, r record) error {
+		if prefixLen > h.Depth {
+			h.Depth = prefixLen
+		}
+		if r.recordType != recordTypeData {
+			return nil
+		}
+		switch {
+		case t.treeDepth == 32:
+			h.IPv4[prefixLen]++
+		case t.inIPv4Subtree(ip, prefixLen):
+			h.IPv4[prefixLen-96]++
+		default:
+			h.IPv6[prefixLen]++
+		}
+		return nil
+	})
+	return h
+}
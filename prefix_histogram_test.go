@@ -0,0 +1,51 @@
+package mmdbwriter
+
+import (
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrefixHistogram(t *testing.T) {
+	tests := []struct {
+		name      string
+		ipVersion int
+		depth     int
+	}{
+		{name: "IPv4", ipVersion: 4, depth: 32},
+		{name: "IPv6", ipVersion: 6, depth: 128},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tree, err := New(Options{IPVersion: test.ipVersion})
+			require.NoError(t, err)
+
+			inserts := map[string]mmdbtype.DataType{
+				"1.1.1.0/24": mmdbtype.String("a"),
+				"1.1.2.0/24": mmdbtype.String("b"),
+				// Merged with 1.1.2.0/24.
+				"1.1.3.0/24": mmdbtype.String("b"),
+				"2.2.2.2/32": mmdbtype.String("c"),
+			}
+			if test.ipVersion == 6 {
+				inserts["2003::/16"] = mmdbtype.String("d")
+			}
+			for network, value := range inserts {
+				require.NoError(t, tree.Insert(mustNetwork(t, network), value))
+			}
+
+			h := tree.PrefixHistogram()
+			var expected PrefixHistogram
+			expected.IPv4[23] = 1
+			expected.IPv4[24] = 1
+			expected.IPv4[32] = 1
+			if test.ipVersion == 6 {
+				expected.IPv6[16] = 1
+			}
+			expected.Depth = test.depth
+			assert.Equal(t, expected, h)
+		})
+	}
+}
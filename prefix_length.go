@@ -0,0 +1,103 @@
+package mmdbwriter
+
+import (
+	"errors"
+	"fmt"
+	"net"
+)
+
+// PrefixLengthPolicy determines how an insert of a network more specific
+// than Options.MaxIPv4PrefixLength or Options.MaxIPv6PrefixLength is
+// handled.
+type PrefixLengthPolicy int
+
+const (
+	// PrefixLengthError returns an error wrapping ErrPrefixLengthExceeded
+	// for the insert. The tree is not modified. This is the default.
+	PrefixLengthError PrefixLengthPolicy = iota
+
+	// PrefixLengthTruncate inserts into the network of the maximum prefix
+	// length that contains the network instead, e.g., 1.1.1.0/24 for
+	// 1.1.1.128/25 with a maximum of 24. The inserter function is called
+	// with the existing value of the truncated network, so with
+	// inserter.ReplaceWith, the last insert into the truncated network
+	// wins.
+	PrefixLengthTruncate
+)
+
+func (p PrefixLengthPolicy) String() string {
+	switch p {
+	case PrefixLengthError:
+		return "PrefixLengthError"
+	case PrefixLengthTruncate:
+		return "PrefixLengthTruncate"
+	default:
+		return fmt.Sprintf("PrefixLengthPolicy(%d)", int(p))
+	}
+}
+
+// ErrPrefixLengthExceeded is wrapped by the error returned for an insert of
+// a network that is more specific than the maximum prefix length when the
+// PrefixLengthPolicy is PrefixLengthError.
+var ErrPrefixLengthExceeded = errors.New("the network exceeds the maximum prefix length")
+
+// prefixLengthLimit holds the maximum prefix lengths of a tree.
+type prefixLengthLimit struct {
+	ipv4   int
+	ipv6   int
+	policy PrefixLengthPolicy
+}
+
+func newPrefixLengthLimit(opts Options, ipVersion int) (*prefixLengthLimit, error) {
+	if opts.PrefixLengthPolicy < PrefixLengthError || opts.PrefixLengthPolicy > PrefixLengthTruncate {
+		return nil, fmt.Errorf("unsupported PrefixLengthPolicy: %d", int(opts.PrefixLengthPolicy))
+	}
+	if opts.MaxIPv4PrefixLength < 0 || opts.MaxIPv4PrefixLength > 32 {
+		return nil, fmt.Errorf(
+			"MaxIPv4PrefixLength must be between 0 and 32: %d",
+			opts.MaxIPv4PrefixLength,
+		)
+	}
+	if opts.MaxIPv6PrefixLength < 0 || opts.MaxIPv6PrefixLength > 128 {
+		return nil, fmt.Errorf(
+			"MaxIPv6PrefixLength must be between 0 and 128: %d",
+			opts.MaxIPv6PrefixLength,
+		)
+	}
+	if opts.MaxIPv6PrefixLength != 0 && ipVersion != 6 {
+		return nil, errors.New("MaxIPv6PrefixLength requires an IPv6 tree")
+	}
+	if opts.MaxIPv4PrefixLength == 0 && opts.MaxIPv6PrefixLength == 0 {
+		return nil, nil
+	}
+	return &prefixLengthLimit{
+		ipv4:   opts.MaxIPv4PrefixLength,
+		ipv6:   opts.MaxIPv6PrefixLength,
+		policy: opts.PrefixLengthPolicy,
+	}, nil
+}
+
+// checkPrefixLength applies the maximum prefix lengths to an insert of the
+// network, in tree form. It returns the prefix length to insert.
+func (t *Tree) checkPrefixLength(network *net.IPNet, ip net.IP, prefixLen int) (int, error) {
+	maxPrefixLen := t.prefixLengths.ipv6
+	offset := 0
+	if t.treeDepth == 32 || t.inIPv4Subtree(ip, prefixLen) {
+		maxPrefixLen = t.prefixLengths.ipv4
+		offset = t.treeDepth - 32
+	}
+	if maxPrefixLen == 0 || prefixLen-offset <= maxPrefixLen {
+		return prefixLen, nil
+	}
+	if t.prefixLengths.policy == PrefixLengthTruncate {
+		if t.logger != nil {
+			t.logger.Warn(
+				"truncating network to the maximum prefix length",
+				"network", network.String(),
+				"max_prefix_length", maxPrefixLen,
+			)
+		}
+		return maxPrefixLen + offset, nil
+	}
+	return 0, fmt.Errorf("inserting %s: %w of %d", network, ErrPrefixLengthExceeded, maxPrefixLen)
+}
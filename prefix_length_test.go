@@ -0,0 +1,110 @@
+package mmdbwriter
+
+import (
+	"net"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrefixLengthError(t *testing.T) {
+	tree, err := New(Options{MaxIPv4PrefixLength: 24, MaxIPv6PrefixLength: 48})
+	require.NoError(t, err)
+
+	for _, network := range []string{"::/0", "2003::/16", "2003::/48", "1.1.0.0/16", "1.1.1.0/24"} {
+		require.NoError(t, tree.Insert(mustNetwork(t, network), mmdbtype.String(network)))
+	}
+
+	tests := []struct {
+		network     string
+		expectedErr string
+	}{
+		{
+			network:     "1.1.1.128/25",
+			expectedErr: "inserting 1.1.1.128/25: the network exceeds the maximum prefix length of 24",
+		},
+		{
+			network:     "2003::/49",
+			expectedErr: "inserting 2003::/49: the network exceeds the maximum prefix length of 48",
+		},
+		{
+			network:     "::102:300/121",
+			expectedErr: "inserting ::102:300/121: the network exceeds the maximum prefix length of 24",
+		},
+	}
+	for _, test := range tests {
+		err := tree.Insert(mustNetwork(t, test.network), mmdbtype.String("x"))
+		assert.ErrorIs(t, err, ErrPrefixLengthExceeded)
+		assert.EqualError(t, err, test.expectedErr)
+	}
+
+	_, value := tree.Get(net.ParseIP("1.1.1.200"))
+	assert.Equal(t, mmdbtype.String("1.1.1.0/24"), value)
+	require.NoError(t, tree.Check())
+}
+
+func TestPrefixLengthTruncate(t *testing.T) {
+	tree, err := New(Options{
+		IPVersion:           4,
+		MaxIPv4PrefixLength: 24,
+		PrefixLengthPolicy:  PrefixLengthTruncate,
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, tree.Insert(mustNetwork(t, "1.1.1.128/25"), mmdbtype.String("a")))
+	require.NoError(t, tree.Insert(mustNetwork(t, "1.1.1.7/32"), mmdbtype.String("b")))
+	require.NoError(t, tree.Insert(mustNetwork(t, "2.2.0.0/16"), mmdbtype.String("c")))
+
+	network, value := tree.Get(net.ParseIP("1.1.1.1").To4())
+	assert.Equal(t, "1.1.1.0/24", network.String())
+	assert.Equal(t, mmdbtype.String("b"), value)
+
+	network, value = tree.Get(net.ParseIP("2.2.2.2").To4())
+	assert.Equal(t, "2.2.0.0/16", network.String())
+	assert.Equal(t, mmdbtype.String("c"), value)
+
+	err = tree.Walk(func(network *net.IPNet, value mmdbtype.DataType) (bool, error) {
+		if value != nil {
+			ones, _ := network.Mask.Size()
+			assert.LessOrEqual(t, ones, 24)
+		}
+		return true, nil
+	})
+	require.NoError(t, err)
+}
+
+func TestPrefixLengthOptions(t *testing.T) {
+	tests := []struct {
+		opts        Options
+		expectedErr string
+	}{
+		{
+			opts:        Options{MaxIPv4PrefixLength: 33},
+			expectedErr: "MaxIPv4PrefixLength must be between 0 and 32: 33",
+		},
+		{
+			opts:        Options{MaxIPv6PrefixLength: -1},
+			expectedErr: "MaxIPv6PrefixLength must be between 0 and 128: -1",
+		},
+		{
+			opts:        Options{IPVersion: 4, MaxIPv6PrefixLength: 48},
+			expectedErr: "MaxIPv6PrefixLength requires an IPv6 tree",
+		},
+		{
+			opts:        Options{PrefixLengthPolicy: 2},
+			expectedErr: "unsupported PrefixLengthPolicy: 2",
+		},
+	}
+	for _, test := range tests {
+		_, err := New(test.opts)
+		assert.EqualError(t, err, test.expectedErr)
+	}
+
+	opts := Options{MaxIPv4PrefixLength: 24, PrefixLengthPolicy: PrefixLengthTruncate}
+	tree, err := New(opts)
+	require.NoError(t, err)
+	assert.Equal(t, 24, tree.options().MaxIPv4PrefixLength)
+	assert.Equal(t, PrefixLengthTruncate, tree.options().PrefixLengthPolicy)
+}
@@ -0,0 +1,98 @@
+package mmdbwriter
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"net"
+)
+
+// PrefixTableMagic is the magic number at the start of the output of
+// WritePrefixTable.
+const PrefixTableMagic = "MMDBPFX1"
+
+// WritePrefixTable writes the networks with data in the tree to w as a flat
+// table of (prefix, record) rows sorted by network. This is intended for
+// consumers that load the data into their own structures, e.g., eBPF LPM
+// maps or router tables, rather than reading a MaxMind DB. It returns the
+// number of bytes written.
+//
+// The format is as follows, with all integers in big-endian order:
+//
+//   - The magic number, PrefixTableMagic.
+//   - The IP version of the tree, 4 or 6, as a single byte. The addresses
+//     in the rows are 4 bytes for IPv4 and 16 bytes for IPv6.
+//   - The number of rows as a uint32.
+//   - For each row, the network address, the prefix length as a single
+//     byte, and the offset of the record's value in the data section as a
+//     uint32.
+//   - The length of the data section as a uint32, followed by the data
+//     section. The data section uses the MaxMind DB data section encoding,
+//     with pointers relative to the start of the data section.
+//
+// The networks are in the form used in the tree. In an IPv6 tree, the IPv4
+// networks are in ::/96, or ::ffff:0:0/96 with IPv4PlacementMapped, and the
+// aliased networks are not included. Options.EnumFields is not applied.
+func (t *Tree) WritePrefixTable(w io.Writer) (int64, error) {
+	usePointers := true
+	dataWriter := newDataWriter(t.dataMap, usePointers)
+
+	addrLen := t.treeDepth / 8
+	rowLen := addrLen + 5
+	var rows []byte
+	rowCount := 0
+	err := t.root.walk(make(net.IP, addrLen), 0, func(ip net.IP, prefixLen int, r record) error {
+		if r.recordType != recordTypeData {
+			return nil
+		}
+		offset, err := dataWriter.maybeWrite(t.dataMap.get(r.value))
+		if err != nil {
+			return err
+		}
+		row := make([]byte, rowLen)
+		copy(row, ip)
+		row[addrLen] = byte(prefixLen)
+		binary.BigEndian.PutUint32(row[addrLen+1:], uint32(offset))
+		rows = append(rows, row...)
+		rowCount++
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if dataWriter.Len() > math.MaxUint32 {
+		return 0, fmt.Errorf("the data section is too large for a prefix table: %d bytes", dataWriter.Len())
+	}
+
+	buf := bufio.NewWriter(w)
+
+	header := make([]byte, len(PrefixTableMagic)+5)
+	copy(header, PrefixTableMagic)
+	header[len(PrefixTableMagic)] = byte(t.ipVersion)
+	binary.BigEndian.PutUint32(header[len(PrefixTableMagic)+1:], uint32(rowCount))
+
+	dataLen := make([]byte, 4)
+	binary.BigEndian.PutUint32(dataLen, uint32(dataWriter.Len()))
+
+	numBytes := int64(0)
+	for _, b := range [][]byte{header, rows, dataLen} {
+		nb, err := buf.Write(b)
+		numBytes += int64(nb)
+		if err != nil {
+			return numBytes, fmt.Errorf("writing prefix table: %w", err)
+		}
+	}
+
+	nb64, err := dataWriter.WriteTo(buf)
+	numBytes += nb64
+	if err != nil {
+		return numBytes, fmt.Errorf("writing prefix table data section: %w", err)
+	}
+
+	if err := buf.Flush(); err != nil {
+		return numBytes, fmt.Errorf("flushing buffer to writer: %w", err)
+	}
+	return numBytes, nil
+}
@@ -0,0 +1,105 @@
+package mmdbwriter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWritePrefixTable(t *testing.T) {
+	type row struct {
+		network string
+		value   mmdbtype.DataType
+	}
+
+	tests := []struct {
+		ipVersion int
+		expected  []row
+	}{
+		{
+			ipVersion: 4,
+			expected: []row{
+				{network: "1.1.1.0/24", value: mmdbtype.String("a")},
+				{network: "2.0.0.0/8", value: mmdbtype.String("b")},
+				{network: "3.3.0.0/16", value: mmdbtype.String("a")},
+			},
+		},
+		{
+			ipVersion: 6,
+			expected: []row{
+				{network: "::101:100/120", value: mmdbtype.String("a")},
+				{network: "::200:0/104", value: mmdbtype.String("b")},
+				{network: "::303:0/112", value: mmdbtype.String("a")},
+				{network: "2600::/32", value: mmdbtype.String("c")},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("IPv%d", test.ipVersion), func(t *testing.T) {
+			tree, err := New(Options{IPVersion: test.ipVersion})
+			require.NoError(t, err)
+
+			insert(t, tree, "1.1.1.0/24", "a")
+			insert(t, tree, "2.0.0.0/8", "b")
+			insert(t, tree, "3.3.0.0/16", "a")
+			if test.ipVersion == 6 {
+				insert(t, tree, "2600::/32", "c")
+			}
+
+			buf := &bytes.Buffer{}
+			n, err := tree.WritePrefixTable(buf)
+			require.NoError(t, err)
+			assert.Equal(t, int64(buf.Len()), n)
+
+			b := buf.Bytes()
+			require.Equal(t, PrefixTableMagic, string(b[:8]))
+			require.Equal(t, byte(test.ipVersion), b[8])
+			rowCount := int(binary.BigEndian.Uint32(b[9:]))
+			b = b[13:]
+
+			addrLen := 4
+			if test.ipVersion == 6 {
+				addrLen = 16
+			}
+			rowLen := addrLen + 5
+			rows := b[:rowCount*rowLen]
+			b = b[rowCount*rowLen:]
+			dataLen := int(binary.BigEndian.Uint32(b))
+			data := b[4:]
+			require.Len(t, data, dataLen)
+
+			var actual []row
+			offsets := map[string]uint32{}
+			for i := 0; i < rowCount; i++ {
+				r := rows[i*rowLen : (i+1)*rowLen]
+				network := &net.IPNet{
+					IP:   net.IP(r[:addrLen]),
+					Mask: net.CIDRMask(int(r[addrLen]), addrLen*8),
+				}
+				offset := binary.BigEndian.Uint32(r[addrLen+1:])
+
+				_, value := tree.Get(network.IP)
+				actual = append(actual, row{network: network.String(), value: value})
+
+				encoded := newDataWriter(tree.dataMap, false)
+				_, err := value.WriteTo(encoded)
+				require.NoError(t, err)
+				assert.True(t, bytes.HasPrefix(data[offset:], encoded.dataBuffer.(*bytes.Buffer).Bytes()), network.String())
+
+				key := string(value.(mmdbtype.String))
+				if prev, ok := offsets[key]; ok {
+					assert.Equal(t, prev, offset, "identical values share an offset")
+				}
+				offsets[key] = offset
+			}
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package mmdbwriter
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/maxmind/mmdbwriter/inserter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+// InsertChange is a change to the data for a network that an insert would
+// make. A nil Before or After means that the network has no data.
+type InsertChange struct {
+	Network *net.IPNet
+	Before  mmdbtype.DataType
+	After   mmdbtype.DataType
+}
+
+// PreviewInsert returns the changes that Insert would make to the tree
+// without modifying the tree. The changes are for the existing records
+// within the network, in the same order and form as Walk, with a record
+// containing the network being limited to the network. Records whose data
+// would not change are not included.
+//
+// The Tree's inserter function, Options.InsertMiddleware, the
+// OverwritePolicy, and the priorities from InsertWithPriority are applied
+// as with Insert, so they must not have side effects that should only
+// happen for real inserts. If Insert would return an error, it is
+// returned instead.
+func (t *Tree) PreviewInsert(network *net.IPNet, value mmdbtype.DataType) ([]InsertChange, error) {
+	return t.PreviewInsertFunc(network, t.inserterFuncGen(value))
+}
+
+// PreviewInsertFunc is the same as PreviewInsert, except that it previews
+// InsertFunc with the inserter function.
+func (t *Tree) PreviewInsertFunc(network *net.IPNet, inserterFunc inserter.Func) ([]InsertChange, error) {
+	inserterFunc = t.applyMiddleware(network, inserterFunc)
+	if t.floatDecimalPlaces > 0 {
+		inserterFunc = roundFloatsInserter(inserterFunc, t.floatDecimalPlaces)
+	}
+	if t.floatEpsilon != nil {
+		inserterFunc = floatEpsilonInserter(inserterFunc, t.floatEpsilon, false)
+	}
+
+	ip, prefixLen := t.treeNetwork(network)
+	if t.inAliasedNetwork(ip, prefixLen) {
+		return nil, fmt.Errorf("attempt to insert %s/%d, which is in an aliased network", ip, prefixLen)
+	}
+
+	networks := []treeNetwork{{ip: ip, prefixLen: prefixLen}}
+	if t.priorities != nil {
+		networks = t.priorities.allowed(ip, prefixLen)
+	}
+
+	var changes []InsertChange
+	for _, n := range networks {
+		if t.overwritePolicy == OverwriteError {
+			if err := t.checkOverwrite(n.ip, n.prefixLen); err != nil {
+				return nil, err
+			}
+		}
+		err := t.walkWithin(n.ip, n.prefixLen, func(recIP net.IP, recPrefixLen int, r record) error {
+			var before mmdbtype.DataType
+			switch r.recordType {
+			case recordTypeData:
+				before = t.dataMap.load(r.value)
+			case recordTypeEmpty:
+			case recordTypeReserved:
+				if recPrefixLen <= n.prefixLen {
+					return fmt.Errorf(
+						"attempt to insert %s/%d, which is in a reserved network",
+						n.ip,
+						n.prefixLen,
+					)
+				}
+				return nil
+			default:
+				return nil
+			}
+
+			existing := before
+			if r.moreSpecificThan(n.prefixLen) && before != nil {
+				switch t.overwritePolicy {
+				case OverwriteKeep:
+					return nil
+				case OverwriteReplace:
+					existing = nil
+				case OverwriteMerge, OverwriteError:
+				}
+			}
+			after, err := inserterFunc(existing)
+			if err != nil {
+				return err
+			}
+			if (before == nil && after == nil) || (before != nil && after != nil && before.Equal(after)) {
+				return nil
+			}
+			changes = append(changes, InsertChange{
+				Network: t.network(recIP, recPrefixLen),
+				Before:  before,
+				After:   after,
+			})
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return changes, nil
+}
+
+// inAliasedNetwork returns true if the network, in tree form, is within an
+// aliased network.
+func (t *Tree) inAliasedNetwork(ip net.IP, prefixLen int) bool {
+	n := t.root
+	for depth := 0; depth < prefixLen; depth++ {
+		r := n.children[bitAt(ip, depth)]
+		switch r.recordType {
+		case recordTypeNode, recordTypeFixedNode:
+			n = r.node
+		case recordTypeAlias:
+			return true
+		default:
+			return false
+		}
+	}
+	return false
+}
@@ -0,0 +1,142 @@
+package mmdbwriter
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/inserter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreviewInsert(t *testing.T) {
+	a := mmdbtype.Map{"a": mmdbtype.Bool(true)}
+	b := mmdbtype.Map{"b": mmdbtype.Bool(true)}
+	override := mmdbtype.Map{"override": mmdbtype.Bool(true)}
+
+	type change struct {
+		network string
+		before  mmdbtype.DataType
+		after   mmdbtype.DataType
+	}
+
+	tests := []struct {
+		name        string
+		opts        Options
+		network     string
+		expected    []change
+		expectedErr string
+	}{
+		{
+			name:    "replace",
+			network: "1.1.0.0/23",
+			expected: []change{
+				{network: "1.1.0.0/24", before: nil, after: override},
+				{network: "1.1.1.0/25", before: a, after: override},
+				{network: "1.1.1.128/25", before: b, after: override},
+			},
+		},
+		{
+			name:    "within a record",
+			network: "1.1.1.0/26",
+			expected: []change{
+				{network: "1.1.1.0/26", before: a, after: override},
+			},
+		},
+		{
+			name:    "merge",
+			opts:    Options{Inserter: inserter.TopLevelMergeWith},
+			network: "1.1.1.0/24",
+			expected: []change{
+				{
+					network: "1.1.1.0/25",
+					before:  a,
+					after:   mmdbtype.Map{"a": mmdbtype.Bool(true), "override": mmdbtype.Bool(true)},
+				},
+				{
+					network: "1.1.1.128/25",
+					before:  b,
+					after:   mmdbtype.Map{"b": mmdbtype.Bool(true), "override": mmdbtype.Bool(true)},
+				},
+			},
+		},
+		{
+			name:    "keep",
+			opts:    Options{OverwritePolicy: OverwriteKeep},
+			network: "1.1.0.0/23",
+			expected: []change{
+				{network: "1.1.0.0/24", before: nil, after: override},
+			},
+		},
+		{
+			name:        "error",
+			opts:        Options{OverwritePolicy: OverwriteError},
+			network:     "1.1.0.0/23",
+			expectedErr: "inserting 1.1.0.0/23 would overwrite 1.1.1.0/25: " + ErrOverwrite.Error(),
+		},
+		{
+			name:        "reserved",
+			network:     "10.0.0.0/24",
+			expectedErr: "attempt to insert ::a00:0/120, which is in a reserved network",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tree, err := New(test.opts)
+			require.NoError(t, err)
+
+			_, network, err := net.ParseCIDR("1.1.1.0/25")
+			require.NoError(t, err)
+			require.NoError(t, tree.Insert(network, a))
+			_, network, err = net.ParseCIDR("1.1.1.128/25")
+			require.NoError(t, err)
+			require.NoError(t, tree.Insert(network, b))
+
+			expected := &bytes.Buffer{}
+			_, err = tree.WriteTo(expected)
+			require.NoError(t, err)
+
+			_, network, err = net.ParseCIDR(test.network)
+			require.NoError(t, err)
+			changes, err := tree.PreviewInsert(network, override)
+			if test.expectedErr != "" {
+				assert.EqualError(t, err, test.expectedErr)
+				assert.EqualError(t, tree.Insert(network, override), test.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+
+			var actual []change
+			for _, c := range changes {
+				actual = append(actual, change{network: c.Network.String(), before: c.Before, after: c.After})
+			}
+			assert.Equal(t, test.expected, actual)
+
+			actualBytes := &bytes.Buffer{}
+			_, err = tree.WriteTo(actualBytes)
+			require.NoError(t, err)
+			assert.Equal(t, expected.Bytes(), actualBytes.Bytes(), "the tree is not modified")
+		})
+	}
+}
+
+func TestPreviewInsertPriority(t *testing.T) {
+	tree, err := New(Options{IPVersion: 4})
+	require.NoError(t, err)
+
+	_, network, err := net.ParseCIDR("1.1.1.0/24")
+	require.NoError(t, err)
+	require.NoError(t, tree.InsertWithPriority(network, mmdbtype.String("a"), 1))
+
+	_, network, err = net.ParseCIDR("1.1.0.0/23")
+	require.NoError(t, err)
+	changes, err := tree.PreviewInsert(network, mmdbtype.String("b"))
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, "1.1.0.0/24", changes[0].Network.String())
+	assert.Nil(t, changes[0].Before)
+	assert.Equal(t, mmdbtype.String("b"), changes[0].After)
+}
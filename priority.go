@@ -0,0 +1,158 @@
+package mmdbwriter
+
+import (
+	"errors"
+	"net"
+
+	"github.com/maxmind/mmdbwriter/inserter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+// priorityTracker records the priority of the most recent insert for each
+// network. Like sourceTracker, it is a binary trie that is independent of
+// the search tree. Networks without a set node have priority 0.
+type priorityTracker struct {
+	root    priorityNode
+	current int
+}
+
+type priorityNode struct {
+	children [2]*priorityNode
+	priority int
+	set      bool
+}
+
+// insert records the current priority for the network. As with
+// sourceTracker, we drop the subtree below it. The caller must only insert
+// networks that do not contain a network with a higher priority.
+func (pt *priorityTracker) insert(ip net.IP, prefixLen int) {
+	n := &pt.root
+	for depth := 0; depth < prefixLen; depth++ {
+		bit := bitAt(ip, depth)
+		if n.children[bit] == nil {
+			n.children[bit] = &priorityNode{}
+		}
+		n = n.children[bit]
+	}
+	n.children = [2]*priorityNode{}
+	n.priority = pt.current
+	n.set = true
+}
+
+type treeNetwork struct {
+	ip        net.IP
+	prefixLen int
+}
+
+// allowed returns the largest networks within the network that do not have
+// a higher priority than the current priority.
+func (pt *priorityTracker) allowed(ip net.IP, prefixLen int) []treeNetwork {
+	n := &pt.root
+	priority := 0
+	for depth := 0; ; depth++ {
+		if n.set {
+			priority = n.priority
+		}
+		if depth == prefixLen {
+			break
+		}
+		n = n.children[bitAt(ip, depth)]
+		if n == nil {
+			break
+		}
+	}
+
+	var networks []treeNetwork
+	if pt.allowedWithin(n, ip, prefixLen, priority, &networks) {
+		networks = []treeNetwork{{ip: ip, prefixLen: prefixLen}}
+	}
+	return networks
+}
+
+// allowedWithin appends the allowed networks within the network at ip and
+// depth, whose trie node is n, to networks. If the whole network is
+// allowed, it returns true without appending it so that the caller may
+// append a larger network instead.
+func (pt *priorityTracker) allowedWithin(
+	n *priorityNode,
+	ip net.IP,
+	depth int,
+	priority int,
+	networks *[]treeNetwork,
+) bool {
+	if n != nil && n.set {
+		priority = n.priority
+	}
+	if priority > pt.current {
+		return false
+	}
+	if n == nil || (n.children[0] == nil && n.children[1] == nil) {
+		return true
+	}
+
+	var childIPs [2]net.IP
+	var childAllowed [2]bool
+	var childNetworks [2][]treeNetwork
+	for bit, child := range n.children {
+		childIPs[bit] = make(net.IP, len(ip))
+		copy(childIPs[bit], ip)
+		if bit == 1 {
+			setBitAt(childIPs[bit], depth)
+		}
+		childAllowed[bit] = pt.allowedWithin(child, childIPs[bit], depth+1, priority, &childNetworks[bit])
+	}
+	if childAllowed[0] && childAllowed[1] {
+		return true
+	}
+	for bit := range childIPs {
+		if childAllowed[bit] {
+			*networks = append(*networks, treeNetwork{ip: childIPs[bit], prefixLen: depth + 1})
+		} else {
+			*networks = append(*networks, childNetworks[bit]...)
+		}
+	}
+	return false
+}
+
+// InsertWithPriority is the same as Insert, except that the data is only
+// inserted into the parts of the network that have not been inserted with
+// a higher priority. This allows, e.g., authoritative overrides to be
+// inserted before or after a bulk import without being overwritten by it.
+//
+// Inserts with the same priority behave as usual, with the most recent
+// insert taking effect. Insert, InsertFunc, InsertRange, and InsertReader
+// use priority 0, and the priority must not be negative. The priority is
+// recorded even if the inserter function removes the data, e.g., with
+// inserter.Remove. Priorities are only kept in memory. They are not copied
+// by Extract or written to the database.
+//
+// This is not safe to call from multiple threads.
+func (t *Tree) InsertWithPriority(
+	network *net.IPNet,
+	value mmdbtype.DataType,
+	priority int,
+) error {
+	return t.InsertFuncWithPriority(network, t.inserterFuncGen(value), priority)
+}
+
+// InsertFuncWithPriority is the same as InsertFunc, except that the
+// function's output is only inserted into the parts of the network that
+// have not been inserted with a higher priority. See InsertWithPriority.
+//
+// This is not safe to call from multiple threads.
+func (t *Tree) InsertFuncWithPriority(
+	network *net.IPNet,
+	inserterFunc inserter.Func,
+	priority int,
+) error {
+	if priority < 0 {
+		return errors.New("the priority must not be negative")
+	}
+	if t.priorities == nil {
+		t.priorities = &priorityTracker{}
+	}
+	t.priorities.current = priority
+	defer func() { t.priorities.current = 0 }()
+
+	return t.InsertFunc(network, inserterFunc)
+}
@@ -0,0 +1,117 @@
+package mmdbwriter
+
+import (
+	"net"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInsertWithPriority(t *testing.T) {
+	type prioritizedInsert struct {
+		network  string
+		value    string
+		priority int
+	}
+
+	type expectedGet struct {
+		ip      string
+		network string
+		value   mmdbtype.DataType
+	}
+
+	tests := []struct {
+		name     string
+		inserts  []prioritizedInsert
+		expected []expectedGet
+	}{
+		{
+			name: "override before bulk",
+			inserts: []prioritizedInsert{
+				{network: "1.1.1.0/24", value: "override", priority: 10},
+				{network: "1.1.0.0/16", value: "bulk"},
+			},
+			expected: []expectedGet{
+				{ip: "1.1.1.1", network: "1.1.1.0/24", value: mmdbtype.String("override")},
+				{ip: "1.1.2.1", network: "1.1.2.0/23", value: mmdbtype.String("bulk")},
+				{ip: "1.1.128.1", network: "1.1.128.0/17", value: mmdbtype.String("bulk")},
+			},
+		},
+		{
+			name: "override after bulk",
+			inserts: []prioritizedInsert{
+				{network: "1.1.0.0/16", value: "bulk"},
+				{network: "1.1.1.0/24", value: "override", priority: 10},
+				{network: "1.1.1.0/25", value: "bulk"},
+			},
+			expected: []expectedGet{
+				{ip: "1.1.1.1", network: "1.1.1.0/24", value: mmdbtype.String("override")},
+				{ip: "1.1.2.1", network: "1.1.2.0/23", value: mmdbtype.String("bulk")},
+			},
+		},
+		{
+			name: "lower priority over higher priority",
+			inserts: []prioritizedInsert{
+				{network: "1.1.0.0/16", value: "a", priority: 5},
+				{network: "1.1.1.0/24", value: "b", priority: 1},
+				{network: "1.0.0.0/8", value: "c", priority: 2},
+			},
+			expected: []expectedGet{
+				{ip: "1.1.1.1", network: "1.1.0.0/16", value: mmdbtype.String("a")},
+				{ip: "1.2.1.1", network: "1.2.0.0/15", value: mmdbtype.String("c")},
+			},
+		},
+		{
+			name: "same priority",
+			inserts: []prioritizedInsert{
+				{network: "1.1.1.0/24", value: "a", priority: 3},
+				{network: "1.1.0.0/16", value: "b", priority: 3},
+			},
+			expected: []expectedGet{
+				{ip: "1.1.1.1", network: "1.1.0.0/16", value: mmdbtype.String("b")},
+			},
+		},
+		{
+			name: "higher priority replaces lower priorities within",
+			inserts: []prioritizedInsert{
+				{network: "1.1.1.0/24", value: "a", priority: 1},
+				{network: "1.1.2.0/24", value: "b", priority: 2},
+				{network: "1.1.0.0/16", value: "c", priority: 3},
+				{network: "1.1.0.0/16", value: "d", priority: 2},
+			},
+			expected: []expectedGet{
+				{ip: "1.1.1.1", network: "1.1.0.0/16", value: mmdbtype.String("c")},
+				{ip: "1.1.2.1", network: "1.1.0.0/16", value: mmdbtype.String("c")},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tree, err := New(Options{IPVersion: 4})
+			require.NoError(t, err)
+
+			for _, i := range test.inserts {
+				_, network, err := net.ParseCIDR(i.network)
+				require.NoError(t, err)
+				require.NoError(t, tree.InsertWithPriority(network, mmdbtype.String(i.value), i.priority))
+			}
+
+			for _, e := range test.expected {
+				network, value := tree.Get(net.ParseIP(e.ip).To4())
+				assert.Equal(t, e.network, network.String(), e.ip)
+				assert.Equal(t, e.value, value, e.ip)
+			}
+			assertRefCounts(t, tree)
+		})
+	}
+
+	tree, err := New(Options{})
+	require.NoError(t, err)
+	_, network, err := net.ParseCIDR("1.1.1.0/24")
+	require.NoError(t, err)
+	err = tree.InsertWithPriority(network, mmdbtype.String("a"), -1)
+	assert.EqualError(t, err, "the priority must not be negative")
+}
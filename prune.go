@@ -0,0 +1,168 @@
+package mmdbwriter
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+)
+
+// PruneOptions configures Tree.PruneToSize.
+type PruneOptions struct {
+	// TargetSize is the maximum size in bytes of the written database,
+	// e.g., for an embedded device. It is required.
+	TargetSize int64
+
+	// StripFields is a list of dot-separated paths to fields that may be
+	// removed from the records, from the lowest priority to the highest,
+	// e.g., "location.accuracy_radius" before "city.names". They are
+	// removed one at a time, before any network is coarsened.
+	StripFields []string
+
+	// IPv4PrefixLengths and IPv6PrefixLengths are the prefix lengths that
+	// the networks are coarsened to, in decreasing order, after all of the
+	// fields have been removed. Step i uses the i-th prefix length of each
+	// list, or the last if the list is shorter. The defaults are 24, 22,
+	// 20, 18, and 16 for IPv4 and 48, 40, and 32 for IPv6. Networks are
+	// coarsened as with Anonymize.
+	IPv4PrefixLengths []int
+	IPv6PrefixLengths []int
+}
+
+// PruneReport describes what Tree.PruneToSize discarded.
+type PruneReport struct {
+	// Size is the size in bytes of the database written from the pruned
+	// tree.
+	Size int64
+
+	// StrippedFields are the fields removed from the records.
+	StrippedFields []string
+
+	// IPv4PrefixLength and IPv6PrefixLength are the prefix lengths that
+	// the networks were coarsened to, or 0 if they were not coarsened.
+	IPv4PrefixLength int
+	IPv6PrefixLength int
+
+	// CoarsenedNetworks is the number of networks with data in the original
+	// tree that are more specific than the prefix lengths.
+	CoarsenedNetworks int
+}
+
+// PruneToSize returns a new tree with the same options as t whose database
+// is at most opts.TargetSize bytes. If the database of t is too large, the
+// fields in opts.StripFields are removed one at a time, and then the
+// networks are coarsened to progressively shorter prefix lengths, until the
+// database fits. The report describes what was discarded. If the database
+// does not fit after the last step, an error wrapping ErrLimitExceeded is
+// returned. The original tree is not modified.
+//
+// The size of each candidate is found by writing it, so this takes about
+// as long as writing the database once per step.
+func (t *Tree) PruneToSize(opts PruneOptions) (*Tree, PruneReport, error) {
+	if opts.TargetSize <= 0 {
+		return nil, PruneReport{}, errors.New("TargetSize must be greater than zero")
+	}
+	ipv4Lens := opts.IPv4PrefixLengths
+	if len(ipv4Lens) == 0 {
+		ipv4Lens = []int{24, 22, 20, 18, 16}
+	}
+	ipv6Lens := opts.IPv6PrefixLengths
+	if len(ipv6Lens) == 0 {
+		ipv6Lens = []int{48, 40, 32}
+	}
+	if err := validatePruneLengths("IPv4PrefixLengths", ipv4Lens, 32); err != nil {
+		return nil, PruneReport{}, err
+	}
+	if err := validatePruneLengths("IPv6PrefixLengths", ipv6Lens, 128); err != nil {
+		return nil, PruneReport{}, err
+	}
+
+	steps := []AnonymizeOptions{{}}
+	for i := range opts.StripFields {
+		steps = append(steps, AnonymizeOptions{StripFields: opts.StripFields[:i+1]})
+	}
+	numLens := len(ipv4Lens)
+	if len(ipv6Lens) > numLens && t.treeDepth == 128 {
+		numLens = len(ipv6Lens)
+	}
+	for i := 0; i < numLens; i++ {
+		step := AnonymizeOptions{
+			IPv4PrefixLength: pruneLength(ipv4Lens, i),
+			StripFields:      opts.StripFields,
+		}
+		if t.treeDepth == 128 {
+			step.IPv6PrefixLength = pruneLength(ipv6Lens, i)
+		}
+		steps = append(steps, step)
+	}
+
+	var size int64
+	for _, step := range steps {
+		pruned, err := t.Anonymize(step)
+		if err != nil {
+			return nil, PruneReport{}, err
+		}
+		size, err = pruned.WriteTo(io.Discard)
+		if err != nil {
+			pruned.Close() //nolint:errcheck // the write error is more relevant
+			return nil, PruneReport{}, err
+		}
+		if size <= opts.TargetSize {
+			return pruned, PruneReport{
+				Size:              size,
+				StrippedFields:    append([]string(nil), step.StripFields...),
+				IPv4PrefixLength:  step.IPv4PrefixLength,
+				IPv6PrefixLength:  step.IPv6PrefixLength,
+				CoarsenedNetworks: t.countCoarsened(step),
+			}, nil
+		}
+		if err := pruned.Close(); err != nil {
+			return nil, PruneReport{}, err
+		}
+	}
+	return nil, PruneReport{}, fmt.Errorf(
+		"the database is %d bytes after the last pruning step, which exceeds TargetSize (%d): %w",
+		size,
+		opts.TargetSize,
+		ErrLimitExceeded,
+	)
+}
+
+func validatePruneLengths(name string, lens []int, bits int) error {
+	for i, l := range lens {
+		if l < 1 || l > bits {
+			return fmt.Errorf("%s must be between 1 and %d: %d", name, bits, l)
+		}
+		if i > 0 && l >= lens[i-1] {
+			return fmt.Errorf("%s must be in decreasing order: %v", name, lens)
+		}
+	}
+	return nil
+}
+
+// countCoarsened returns the number of networks with data that Anonymize
+// generalizes with the options.
+func (t *Tree) countCoarsened(opts AnonymizeOptions) int {
+	a := &anonymizer{tree: t, opts: opts}
+	count := 0
+	//nolint:errcheck // the function never returns an error
+	t.root.walk(make(net.IP, t.treeDepth/8), 0, func(ip net.IP, prefixLen int, r record) error {
+		if r.recordType != recordTypeData {
+			return nil
+		}
+		if maxPrefixLen := a.maxPrefixLen(ip, prefixLen); maxPrefixLen != 0 && prefixLen > maxPrefixLen {
+			count++
+		}
+		return nil
+	})
+	return count
+}
+
+// pruneLength returns the prefix length of step i, which is the last one
+// if there are fewer lengths than steps.
+func pruneLength(lens []int, i int) int {
+	if i < len(lens) {
+		return lens[i]
+	}
+	return lens[len(lens)-1]
+}
@@ -0,0 +1,122 @@
+package mmdbwriter
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPruneToSize(t *testing.T) {
+	tree, err := New(Options{IPVersion: 4, RecordSize: 24})
+	require.NoError(t, err)
+	for i := 0; i < 256; i++ {
+		network := &net.IPNet{
+			IP:   net.IPv4(1, 1, byte(i/64), byte(i%64)).To4(),
+			Mask: net.CIDRMask(32, 32),
+		}
+		require.NoError(t, tree.Insert(network, mmdbtype.Map{
+			"country": mmdbtype.String("US"),
+			"id":      mmdbtype.Uint32(i),
+			"note":    mmdbtype.String(fmt.Sprintf("%s %d", strings.Repeat("x", 100), i)),
+		}))
+	}
+
+	size := func(opts AnonymizeOptions) int64 {
+		anonymized, err := tree.Anonymize(opts)
+		require.NoError(t, err)
+		n, err := anonymized.WriteTo(io.Discard)
+		require.NoError(t, err)
+		return n
+	}
+	fullSize := size(AnonymizeOptions{})
+	strippedSize := size(AnonymizeOptions{StripFields: []string{"note"}})
+	coarsenedSize := size(AnonymizeOptions{StripFields: []string{"note"}, IPv4PrefixLength: 24})
+	require.Less(t, strippedSize, fullSize)
+	require.Less(t, coarsenedSize, strippedSize)
+
+	tests := []struct {
+		name   string
+		target int64
+		report PruneReport
+	}{
+		{
+			name:   "fits",
+			target: fullSize,
+			report: PruneReport{Size: fullSize},
+		},
+		{
+			name:   "stripped",
+			target: strippedSize,
+			report: PruneReport{Size: strippedSize, StrippedFields: []string{"note"}},
+		},
+		{
+			name:   "coarsened",
+			target: strippedSize - 1,
+			report: PruneReport{
+				Size:              coarsenedSize,
+				StrippedFields:    []string{"note"},
+				IPv4PrefixLength:  24,
+				CoarsenedNetworks: 256,
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pruned, report, err := tree.PruneToSize(PruneOptions{
+				TargetSize:  test.target,
+				StripFields: []string{"note"},
+			})
+			require.NoError(t, err)
+			assert.Equal(t, test.report, report)
+
+			n, err := pruned.WriteTo(io.Discard)
+			require.NoError(t, err)
+			assert.Equal(t, report.Size, n)
+		})
+	}
+
+	_, value := tree.Get(net.IPv4(1, 1, 0, 1).To4())
+	assert.Contains(t, value, mmdbtype.String("note"), "the original tree is not modified")
+}
+
+func TestPruneToSizeErrors(t *testing.T) {
+	tree, err := New(Options{})
+	require.NoError(t, err)
+	insert(t, tree, "1.1.1.0/24", "a")
+
+	tests := []struct {
+		opts PruneOptions
+		err  string
+	}{
+		{
+			opts: PruneOptions{},
+			err:  "TargetSize must be greater than zero",
+		},
+		{
+			opts: PruneOptions{TargetSize: 1, IPv4PrefixLengths: []int{33}},
+			err:  "IPv4PrefixLengths must be between 1 and 32: 33",
+		},
+		{
+			opts: PruneOptions{TargetSize: 1, IPv6PrefixLengths: []int{32, 48}},
+			err:  "IPv6PrefixLengths must be in decreasing order: [32 48]",
+		},
+	}
+	for _, test := range tests {
+		_, _, err := tree.PruneToSize(test.opts)
+		assert.EqualError(t, err, test.err)
+	}
+
+	_, _, err = tree.PruneToSize(PruneOptions{TargetSize: 100})
+	assert.ErrorIs(t, err, ErrLimitExceeded)
+	assert.Regexp(
+		t,
+		`^the database is \d+ bytes after the last pruning step, which exceeds TargetSize \(100\)`,
+		err.Error(),
+	)
+}
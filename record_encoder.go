@@ -0,0 +1,124 @@
+package mmdbwriter
+
+import "fmt"
+
+// recordEncoder encodes and decodes the two records of a search tree node
+// for a record size. Adding a record size only requires a new implementation and a case
+// in newRecordEncoder.
+type recordEncoder interface {
+	// recordSize returns the size of a record in bits.
+	recordSize() int
+
+	// nodeSize returns the size of an encoded node in bytes.
+	nodeSize() int
+
+	// maxRecord returns the largest record value that may be encoded.
+	maxRecord() int
+
+	// encode writes the left and right record values to buf, which must
+	// be nodeSize bytes long. The values must not exceed maxRecord.
+	encode(buf []byte, left, right int)
+
+	// decode returns the left and right record values of the node in buf,
+	// which must be nodeSize bytes long.
+	decode(buf []byte) (left, right int)
+}
+
+// newRecordEncoder returns the recordEncoder for the record size.
+func newRecordEncoder(recordSize int) (recordEncoder, error) {
+	switch recordSize {
+	case 24:
+		return recordEncoder24{}, nil
+	case 28:
+		return recordEncoder28{}, nil
+	case 32:
+		return recordEncoder32{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported record size of %d", recordSize)
+	}
+}
+
+// encodeNode checks that the record values fit in the record size and
+// encodes them into buf.
+func encodeNode(enc recordEncoder, buf []byte, left, right int) error {
+	if left < 0 || right < 0 {
+		return fmt.Errorf("attempted to write the negative record values (%d, %d)", left, right)
+	}
+	if left > enc.maxRecord() || right > enc.maxRecord() {
+		maxRecord := left
+		if right > maxRecord {
+			maxRecord = right
+		}
+		return recordSizeError(maxRecord, enc.recordSize())
+	}
+	enc.encode(buf, left, right)
+	return nil
+}
+
+type recordEncoder24 struct{}
+
+func (recordEncoder24) recordSize() int { return 24 }
+func (recordEncoder24) nodeSize() int   { return 6 }
+func (recordEncoder24) maxRecord() int  { return 1<<24 - 1 }
+
+func (recordEncoder24) encode(buf []byte, left, right int) {
+	buf[0] = byte((left >> 16) & 0xFF)
+	buf[1] = byte((left >> 8) & 0xFF)
+	buf[2] = byte(left & 0xFF)
+	buf[3] = byte((right >> 16) & 0xFF)
+	buf[4] = byte((right >> 8) & 0xFF)
+	buf[5] = byte(right & 0xFF)
+}
+
+func (recordEncoder24) decode(buf []byte) (int, int) {
+	left := int(buf[0])<<16 | int(buf[1])<<8 | int(buf[2])
+	right := int(buf[3])<<16 | int(buf[4])<<8 | int(buf[5])
+	return left, right
+}
+
+// recordEncoder28 stores the high 4 bits of each record in the middle byte
+// of the node, with those of the left record in the high nibble.
+type recordEncoder28 struct{}
+
+func (recordEncoder28) recordSize() int { return 28 }
+func (recordEncoder28) nodeSize() int   { return 7 }
+func (recordEncoder28) maxRecord() int  { return 1<<28 - 1 }
+
+func (recordEncoder28) encode(buf []byte, left, right int) {
+	buf[0] = byte((left >> 16) & 0xFF)
+	buf[1] = byte((left >> 8) & 0xFF)
+	buf[2] = byte(left & 0xFF)
+	buf[3] = byte((((left >> 24) & 0x0F) << 4) | (right >> 24 & 0x0F))
+	buf[4] = byte((right >> 16) & 0xFF)
+	buf[5] = byte((right >> 8) & 0xFF)
+	buf[6] = byte(right & 0xFF)
+}
+
+func (recordEncoder28) decode(buf []byte) (int, int) {
+	left := int(buf[3]&0xF0)<<20 | int(buf[0])<<16 | int(buf[1])<<8 | int(buf[2])
+	right := int(buf[3]&0x0F)<<24 | int(buf[4])<<16 | int(buf[5])<<8 | int(buf[6])
+	return left, right
+}
+
+type recordEncoder32 struct{}
+
+func (recordEncoder32) recordSize() int { return 32 }
+func (recordEncoder32) nodeSize() int   { return 8 }
+func (recordEncoder32) maxRecord() int  { return 1<<32 - 1 }
+
+func (recordEncoder32) encode(buf []byte, left, right int) {
+	buf[0] = byte((left >> 24) & 0xFF)
+	buf[1] = byte((left >> 16) & 0xFF)
+	buf[2] = byte((left >> 8) & 0xFF)
+	buf[3] = byte(left & 0xFF)
+	buf[4] = byte((right >> 24) & 0xFF)
+	buf[5] = byte((right >> 16) & 0xFF)
+	buf[6] = byte((right >> 8) & 0xFF)
+	buf[7] = byte(right & 0xFF)
+}
+
+func (recordEncoder32) decode(buf []byte) (int, int) {
+	left := int(buf[0])<<24 | int(buf[1])<<16 | int(buf[2])<<8 | int(buf[3])
+	right := int(buf[4])<<24 | int(buf[5])<<16 | int(buf[6])<<8 | int(buf[7])
+	return left, right
+}
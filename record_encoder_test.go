@@ -0,0 +1,111 @@
+package mmdbwriter
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// decodeNode decodes a node as described in the MaxMind DB specification.
+func decodeNode(recordSize int, buf []byte) (int, int) {
+	switch recordSize {
+	case 24:
+		left := int(buf[0])<<16 | int(buf[1])<<8 | int(buf[2])
+		right := int(buf[3])<<16 | int(buf[4])<<8 | int(buf[5])
+		return left, right
+	case 28:
+		left := int(buf[3]&0xF0)<<20 | int(buf[0])<<16 | int(buf[1])<<8 | int(buf[2])
+		right := int(buf[3]&0x0F)<<24 | int(buf[4])<<16 | int(buf[5])<<8 | int(buf[6])
+		return left, right
+	case 32:
+		left := int(buf[0])<<24 | int(buf[1])<<16 | int(buf[2])<<8 | int(buf[3])
+		right := int(buf[4])<<24 | int(buf[5])<<16 | int(buf[6])<<8 | int(buf[7])
+		return left, right
+	default:
+		panic(fmt.Sprintf("unexpected record size: %d", recordSize))
+	}
+}
+
+func TestRecordEncoder(t *testing.T) {
+	for _, recordSize := range []int{24, 28, 32} {
+		t.Run(fmt.Sprintf("%d bits", recordSize), func(t *testing.T) {
+			enc, err := newRecordEncoder(recordSize)
+			require.NoError(t, err)
+			assert.Equal(t, recordSize, enc.recordSize())
+			assert.Equal(t, recordSize/4, enc.nodeSize())
+			assert.Equal(t, 1<<recordSize-1, enc.maxRecord())
+
+			// The values at the boundaries of each byte and nibble, as
+			// well as the largest value.
+			values := []int{0, 1, enc.maxRecord(), enc.maxRecord() - 1}
+			for bits := 4; bits < recordSize; bits += 4 {
+				values = append(values, 1<<bits-1, 1<<bits, 1<<bits+1)
+			}
+
+			buf := make([]byte, enc.nodeSize())
+			for _, left := range values {
+				for _, right := range values {
+					require.NoError(t, encodeNode(enc, buf, left, right))
+					actualLeft, actualRight := decodeNode(recordSize, buf)
+					require.Equal(t, left, actualLeft, "left of (%d, %d)", left, right)
+					require.Equal(t, right, actualRight, "right of (%d, %d)", left, right)
+
+					actualLeft, actualRight = enc.decode(buf)
+					require.Equal(t, left, actualLeft, "decoded left of (%d, %d)", left, right)
+					require.Equal(t, right, actualRight, "decoded right of (%d, %d)", left, right)
+				}
+			}
+
+			for _, values := range [][2]int{
+				{enc.maxRecord() + 1, 0},
+				{0, enc.maxRecord() + 1},
+			} {
+				err := encodeNode(enc, buf, values[0], values[1])
+				assert.EqualError(
+					t,
+					err,
+					recordSizeError(enc.maxRecord()+1, recordSize).Error(),
+				)
+			}
+			assert.EqualError(
+				t,
+				encodeNode(enc, buf, -1, 0),
+				"attempted to write the negative record values (-1, 0)",
+			)
+		})
+	}
+}
+
+func TestRecordEncoderBytes(t *testing.T) {
+	tests := []struct {
+		recordSize int
+		left       int
+		right      int
+		expected   []byte
+	}{
+		{24, 0xABCDEF, 0x123456, []byte{0xAB, 0xCD, 0xEF, 0x12, 0x34, 0x56}},
+		{28, 0xABCDEF1, 0x2345678, []byte{0xBC, 0xDE, 0xF1, 0xA2, 0x34, 0x56, 0x78}},
+		{28, 1<<28 - 1, 0, []byte{0xFF, 0xFF, 0xFF, 0xF0, 0x00, 0x00, 0x00}},
+		{28, 0, 1<<28 - 1, []byte{0x00, 0x00, 0x00, 0x0F, 0xFF, 0xFF, 0xFF}},
+		{32, 0xABCDEF12, 0x3456789A, []byte{0xAB, 0xCD, 0xEF, 0x12, 0x34, 0x56, 0x78, 0x9A}},
+	}
+	for _, test := range tests {
+		enc, err := newRecordEncoder(test.recordSize)
+		require.NoError(t, err)
+		buf := make([]byte, enc.nodeSize())
+		require.NoError(t, encodeNode(enc, buf, test.left, test.right))
+		assert.Equal(t, test.expected, buf)
+	}
+}
+
+func TestUnsupportedRecordSize(t *testing.T) {
+	for _, recordSize := range []int{16, 30, 64, 128} {
+		_, err := newRecordEncoder(recordSize)
+		assert.EqualError(t, err, fmt.Sprintf("unsupported record size of %d", recordSize))
+
+		_, err = New(Options{RecordSize: recordSize})
+		assert.EqualError(t, err, fmt.Sprintf("unsupported record size of %d", recordSize))
+	}
+}
@@ -0,0 +1,150 @@
+// Package reloader keeps a maxminddb.Reader up to date with a database file
+// that is replaced periodically, e.g., by a stream.Snapshotter or a
+// scheduled build. A Reloader polls the file and, when it changes, loads
+// the new database and swaps it in atomically, so lookups in the consuming
+// process are never interrupted.
+//
+// The file should be replaced atomically by renaming a new file over it, as
+// stream.WriteFile does, rather than rewritten in place.
+package reloader
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// DefaultInterval is the time between checks of the file if
+// Options.Interval is not set.
+const DefaultInterval = 5 * time.Second
+
+// Options configures a Reloader.
+type Options struct {
+	// Interval is the time between checks of the file in Run. It defaults
+	// to DefaultInterval.
+	Interval time.Duration
+
+	// Verify causes each new database to be verified with
+	// maxminddb.Reader.Verify before it replaces the current one.
+	Verify bool
+
+	// OnReload, if set, is called after a new database is swapped in.
+	OnReload func(reader *maxminddb.Reader)
+
+	// OnError, if set, is called when Run fails to load a new database. The
+	// current database remains in use.
+	OnError func(err error)
+}
+
+// Reloader holds the current maxminddb.Reader for a database file.
+//
+// The databases are read into memory rather than memory-mapped. This
+// allows a replaced Reader to be garbage collected once the lookups using
+// it have finished, without the need to coordinate closing it.
+type Reloader struct {
+	path string
+	opts Options
+
+	reader atomic.Value // *maxminddb.Reader
+
+	// mu serializes reloads and protects info.
+	mu   sync.Mutex
+	info os.FileInfo
+}
+
+// New returns a Reloader for the database at path. The database is loaded
+// before New returns.
+func New(path string, opts Options) (*Reloader, error) {
+	if opts.Interval < 0 {
+		return nil, fmt.Errorf("invalid interval: %s", opts.Interval)
+	}
+	if opts.Interval == 0 {
+		opts.Interval = DefaultInterval
+	}
+
+	r := &Reloader{path: path, opts: opts}
+	if _, err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reader returns the current Reader. It is safe to call from multiple
+// goroutines. Callers should call Reader for each lookup, or batch of
+// lookups, rather than holding on to the result.
+func (r *Reloader) Reader() *maxminddb.Reader {
+	return r.reader.Load().(*maxminddb.Reader)
+}
+
+// Reload loads the database if the file changed since it was last loaded,
+// as determined by its size, modification time, and identity. It returns
+// whether a new database was loaded. If loading fails, the current database
+// remains in use.
+func (r *Reloader) Reload() (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return false, fmt.Errorf("checking %s: %w", r.path, err)
+	}
+	if r.info != nil &&
+		os.SameFile(r.info, info) &&
+		r.info.Size() == info.Size() &&
+		r.info.ModTime().Equal(info.ModTime()) {
+		return false, nil
+	}
+
+	b, err := os.ReadFile(r.path)
+	if err != nil {
+		return false, fmt.Errorf("reading %s: %w", r.path, err)
+	}
+	reader, err := maxminddb.FromBytes(b)
+	if err != nil {
+		return false, fmt.Errorf("opening %s: %w", r.path, err)
+	}
+	if r.opts.Verify {
+		if err := reader.Verify(); err != nil {
+			return false, fmt.Errorf("verifying %s: %w", r.path, err)
+		}
+	}
+
+	r.reader.Store(reader)
+	r.info = info
+	if r.opts.OnReload != nil {
+		r.opts.OnReload(reader)
+	}
+	return true, nil
+}
+
+// Run checks the file every Options.Interval until the context is done,
+// reloading the database when it changes. Errors are passed to
+// Options.OnError and do not stop Run. Run returns the context's error.
+func (r *Reloader) Run(ctx context.Context) error {
+	ticker := time.NewTicker(r.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			_, err := r.Reload()
+			if err != nil && r.opts.OnError != nil {
+				r.opts.OnError(err)
+			}
+		}
+	}
+}
+
+// Lookup looks up the IP address in the current database and decodes the
+// record into result, as with maxminddb.Reader.Lookup.
+func (r *Reloader) Lookup(ip net.IP, result any) error {
+	return r.Reader().Lookup(ip, result)
+}
@@ -0,0 +1,110 @@
+package reloader
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/maxmind/mmdbwriter/stream"
+	"github.com/oschwald/maxminddb-golang"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReloader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.mmdb")
+	writeDB(t, path, "a")
+
+	reloaded := make(chan struct{}, 1)
+	r, err := New(path, Options{
+		Interval: time.Millisecond,
+		Verify:   true,
+		OnReload: func(*maxminddb.Reader) {
+			select {
+			case reloaded <- struct{}{}:
+			default:
+			}
+		},
+	})
+	require.NoError(t, err)
+	<-reloaded
+	assertValue(t, r, "a")
+
+	ok, err := r.Reload()
+	require.NoError(t, err)
+	assert.False(t, ok, "the file is unchanged")
+
+	old := r.Reader()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- r.Run(ctx) }()
+
+	writeDB(t, path, "b")
+	select {
+	case <-reloaded:
+	case <-time.After(10 * time.Second):
+		t.Fatal("the database was not reloaded")
+	}
+	assertValue(t, r, "b")
+
+	// A Reader that was replaced remains usable.
+	var value string
+	require.NoError(t, old.Lookup(net.ParseIP("1.1.1.1"), &value))
+	assert.Equal(t, "a", value)
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
+
+func TestReloaderErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	_, err := New(filepath.Join(dir, "test.mmdb"), Options{Interval: -time.Second})
+	assert.EqualError(t, err, "invalid interval: -1s")
+
+	_, err = New(filepath.Join(dir, "missing.mmdb"), Options{})
+	assert.ErrorContains(t, err, "checking ")
+
+	path := filepath.Join(dir, "test.mmdb")
+	require.NoError(t, os.WriteFile(path, []byte("not a database"), 0o600))
+	_, err = New(path, Options{})
+	assert.ErrorContains(t, err, "opening "+path+": ")
+
+	// A failed reload keeps the current database.
+	writeDB(t, path, "a")
+	r, err := New(path, Options{})
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(path, []byte("truncated"), 0o600))
+	_, err = r.Reload()
+	require.Error(t, err)
+	assertValue(t, r, "a")
+}
+
+func writeDB(t *testing.T, path, value string) {
+	tree, err := mmdbwriter.New(mmdbwriter.Options{
+		IPVersion:    4,
+		DatabaseType: "Test",
+		Description:  map[string]string{"en": "Test"},
+	})
+	require.NoError(t, err)
+
+	_, network, err := net.ParseCIDR("1.1.1.0/24")
+	require.NoError(t, err)
+	require.NoError(t, tree.Insert(network, mmdbtype.String(value)))
+
+	_, err = stream.WriteFile(path, tree)
+	require.NoError(t, err)
+}
+
+func assertValue(t *testing.T, r *Reloader, expected string) {
+	var value string
+	require.NoError(t, r.Lookup(net.ParseIP("1.1.1.1"), &value))
+	assert.Equal(t, expected, value)
+}
@@ -0,0 +1,73 @@
+package mmdbwriter
+
+import (
+	"net"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+// RemoveIf removes the data for every network for which pred returns true
+// in a single pass over the tree. The networks are passed to pred in the
+// same form as with Walk. It returns the number of networks removed.
+//
+// The removals do not go through the inserter, the InsertMiddleware, or the
+// OverwritePolicy, and pred must not modify the tree.
+//
+// This is not safe to call from multiple threads.
+func (t *Tree) RemoveIf(pred func(network *net.IPNet, value mmdbtype.DataType) bool) (int, error) {
+	// We set this to 0 so that the tree must be finalized again.
+	t.nodeCount = 0
+	t.invalidateIndex()
+
+	ip := make(net.IP, t.treeDepth/8)
+	return t.removeIf(t.root, ip, 0, pred)
+}
+
+func (t *Tree) removeIf(
+	n *node,
+	ip net.IP,
+	depth int,
+	pred func(network *net.IPNet, value mmdbtype.DataType) bool,
+) (int, error) {
+	removed := 0
+	for i := 0; i < 2; i++ {
+		if i == 1 {
+			setBitAt(ip, depth)
+		}
+		r := &n.children[i]
+		switch r.recordType {
+		case recordTypeNode, recordTypeFixedNode:
+			count, err := t.removeIf(r.node, ip, depth+1, pred)
+			removed += count
+			if err != nil {
+				return removed, err
+			}
+			if r.recordType == recordTypeNode {
+				merged, err := r.merge(t.dataMap, t.nodes)
+				if err != nil {
+					return removed, err
+				}
+				if merged {
+					t.liveNodes--
+					if r.recordType == recordTypeData {
+						t.report.Merges++
+					}
+				}
+			}
+		case recordTypeData:
+			if pred(t.network(ip, depth+1), t.dataMap.load(r.value)) {
+				t.dataMap.remove(r.value)
+				r.recordType = recordTypeEmpty
+				r.value = 0
+				r.prefixLen = 0
+				removed++
+				t.report.RemovedNetworks++
+			}
+		default:
+		}
+		if i == 1 {
+			clearBitAt(ip, depth)
+		}
+	}
+	return removed, nil
+}
@@ -0,0 +1,59 @@
+package mmdbwriter
+
+import (
+	"net"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemoveIf(t *testing.T) {
+	tree, err := New(Options{})
+	require.NoError(t, err)
+
+	near := mmdbtype.Map{"accuracy_radius": mmdbtype.Uint16(50)}
+	far := mmdbtype.Map{"accuracy_radius": mmdbtype.Uint16(1000)}
+
+	for _, insert := range []struct {
+		network string
+		value   mmdbtype.DataType
+	}{
+		{network: "1.1.0.0/24", value: near},
+		{network: "1.1.1.0/24", value: far},
+		{network: "2.0.0.0/8", value: far},
+		{network: "2600::/32", value: near},
+		{network: "2600:1::/32", value: far},
+	} {
+		_, ipNet, err := net.ParseCIDR(insert.network)
+		require.NoError(t, err)
+		require.NoError(t, tree.Insert(ipNet, insert.value))
+	}
+	liveNodes := tree.liveNodes
+
+	var networks []string
+	removed, err := tree.RemoveIf(func(network *net.IPNet, value mmdbtype.DataType) bool {
+		networks = append(networks, network.String())
+		return value.(mmdbtype.Map)["accuracy_radius"].(mmdbtype.Uint16) > 500
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 3, removed)
+	assert.Equal(t, []string{"1.1.0.0/24", "1.1.1.0/24", "2.0.0.0/8", "2600::/32", "2600:1::/32"}, networks)
+
+	for ip, expected := range map[string]mmdbtype.DataType{
+		"1.1.0.1":   near,
+		"1.1.1.1":   nil,
+		"2.2.2.2":   nil,
+		"2600::1":   near,
+		"2600:1::1": nil,
+	} {
+		_, value := tree.Get(net.ParseIP(ip))
+		assert.Equal(t, expected, value, ip)
+	}
+
+	// The emptied records are merged.
+	assert.Less(t, tree.liveNodes, liveNodes)
+	assert.Equal(t, tree.root.finalize(0), tree.liveNodes)
+	assertRefCounts(t, tree)
+}
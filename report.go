@@ -0,0 +1,65 @@
+package mmdbwriter
+
+import "time"
+
+// BuildReport summarizes how a tree was built. It is returned by
+// Tree.BuildReport.
+type BuildReport struct {
+	// Inserts is the number of inserts of data into the tree, including
+	// inserts that remove data, e.g., with inserter.Remove.
+	Inserts int
+
+	// Overwrites is the number of times an insert changed the existing
+	// data of a record to different data.
+	Overwrites int
+
+	// Merges is the number of times two adjacent records with equal data
+	// were merged into one record for the larger network.
+	Merges int
+
+	// RemovedNetworks is the number of times the data of a record was
+	// removed, e.g., by inserter.Remove, Tree.RemoveIf, or Tree.Expire.
+	RemovedNetworks int
+
+	// DistinctRecords is the number of distinct data values in the tree.
+	DistinctRecords int
+
+	// InsertDuration is the total time spent inserting data. For a tree
+	// built by a ParallelBuilder, it is the sum over all the partitions
+	// and so may exceed the elapsed time.
+	InsertDuration time.Duration
+
+	// FinalizeDuration is the time spent in the last finalization of the
+	// tree.
+	FinalizeDuration time.Duration
+
+	// SearchTreeDuration is the time spent writing the search tree in the
+	// last write, which includes encoding the data section.
+	SearchTreeDuration time.Duration
+
+	// DataSectionDuration is the time spent writing the data section in
+	// the last write.
+	DataSectionDuration time.Duration
+
+	// MetadataDuration is the time spent writing the metadata in the last
+	// write.
+	MetadataDuration time.Duration
+}
+
+// add adds the insert counters and durations of other to r.
+func (r *BuildReport) add(other BuildReport) {
+	r.Inserts += other.Inserts
+	r.Overwrites += other.Overwrites
+	r.Merges += other.Merges
+	r.RemovedNetworks += other.RemovedNetworks
+	r.InsertDuration += other.InsertDuration
+}
+
+// BuildReport returns the report on how the tree was built so far. The
+// durations of the write phases are set by the write methods, e.g.,
+// WriteTo.
+func (t *Tree) BuildReport() BuildReport {
+	report := t.report
+	report.DistinctRecords = len(t.dataMap.data)
+	return report
+}
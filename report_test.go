@@ -0,0 +1,49 @@
+package mmdbwriter
+
+import (
+	"io"
+	"net"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/inserter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildReport(t *testing.T) {
+	tree, err := New(Options{})
+	require.NoError(t, err)
+
+	insert(t, tree, "1.1.1.0/25", "a")
+	// Merged with 1.1.1.0/25.
+	insert(t, tree, "1.1.1.128/25", "a")
+	// Overwrites 1.1.1.0/24.
+	insert(t, tree, "1.1.1.0/24", "b")
+	// Unchanged, so not an overwrite.
+	insert(t, tree, "1.1.1.0/24", "b")
+	insert(t, tree, "2.2.2.0/24", "c")
+	insert(t, tree, "3.3.3.0/24", "d")
+	require.NoError(t, tree.InsertFunc(mustNetwork(t, "2.2.2.0/24"), inserter.Remove))
+
+	removed, err := tree.RemoveIf(func(_ *net.IPNet, value mmdbtype.DataType) bool {
+		return value == mmdbtype.String("d")
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, removed)
+
+	_, err = tree.WriteTo(io.Discard)
+	require.NoError(t, err)
+
+	report := tree.BuildReport()
+	assert.Equal(t, 7, report.Inserts)
+	assert.Equal(t, 1, report.Overwrites)
+	assert.Equal(t, 1, report.Merges)
+	assert.Equal(t, 2, report.RemovedNetworks)
+	assert.Equal(t, 1, report.DistinctRecords)
+	assert.Positive(t, report.InsertDuration)
+	assert.Positive(t, report.FinalizeDuration)
+	assert.Positive(t, report.SearchTreeDuration)
+	assert.Positive(t, report.DataSectionDuration)
+	assert.Positive(t, report.MetadataDuration)
+}
@@ -39,6 +39,10 @@ var reservedNetworksIPv4 = []string{
 	// 255.255.255.255/32 gets brought in by 240.0.0.0/4.
 }
 
+// ipv4MappedNetwork is the network of IPv4-mapped IPv6 addresses. It is
+// reserved by Options.ReserveIPv4MappedNetwork.
+const ipv4MappedNetwork = "::ffff:0:0/96"
+
 // https://www.iana.org/assignments/iana-ipv6-special-registry/iana-ipv6-special-registry.xhtml
 var reservedNetworksIPv6 = []string{
 	// ::/128 and ::1/128 are reserved under IPv6 but these are already
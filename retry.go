@@ -0,0 +1,238 @@
+package mmdbwriter
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+const (
+	defaultChunkSize   = 64 << 20
+	defaultMaxAttempts = 3
+	defaultRetryDelay  = time.Second
+)
+
+// Chunk is a contiguous part of the database passed to a ChunkUploader.
+type Chunk struct {
+	// Number is the number of the chunk, starting at 1. This matches the
+	// part numbers used by S3 multipart uploads.
+	Number int
+
+	// Offset is the offset of the chunk in the database.
+	Offset int64
+
+	// Size is the number of bytes in the chunk.
+	Size int64
+
+	// Data contains the bytes of the chunk. A new reader is provided on
+	// each attempt.
+	Data io.Reader
+}
+
+// ChunkUploader uploads the chunks of a database, e.g., as the parts of an
+// S3 multipart upload or the chunks of a GCS resumable upload. Chunks are
+// uploaded in order and only after the previous chunk was uploaded
+// successfully.
+type ChunkUploader interface {
+	// UploadChunk uploads the chunk. If it returns an error, it may be
+	// called again with the same chunk.
+	UploadChunk(chunk Chunk) error
+}
+
+// RetryOptions configures WriteToWithRetry.
+type RetryOptions struct {
+	// ChunkSize is the size of each chunk. All chunks other than the last
+	// have this size. If zero, 64 MiB is used.
+	ChunkSize int64
+
+	// MaxAttempts is the maximum number of times a chunk upload is
+	// attempted. If zero, 3 is used.
+	MaxAttempts int
+
+	// RetryDelay is the delay before the first retry of a chunk. The delay
+	// doubles with each subsequent retry. If zero, one second is used.
+	RetryDelay time.Duration
+
+	// TempDir, if set, causes each chunk to be buffered in a temporary file
+	// in the directory rather than in memory. This is useful when a large
+	// ChunkSize is used. The temporary file is removed before
+	// WriteToWithRetry returns.
+	TempDir string
+}
+
+// WriteToWithRetry writes the database in chunks to the uploader. Each chunk
+// is buffered before it is uploaded so that a failed upload may be retried
+// without rebuilding the database. A chunk is retried up to
+// RetryOptions.MaxAttempts times before giving up. The number of bytes
+// successfully uploaded is returned.
+func (t *Tree) WriteToWithRetry(uploader ChunkUploader, opts RetryOptions) (int64, error) {
+	if opts.ChunkSize < 0 {
+		return 0, errors.New("ChunkSize must not be negative")
+	}
+	if opts.MaxAttempts < 0 {
+		return 0, errors.New("MaxAttempts must not be negative")
+	}
+	if opts.ChunkSize == 0 {
+		opts.ChunkSize = defaultChunkSize
+	}
+	if opts.MaxAttempts == 0 {
+		opts.MaxAttempts = defaultMaxAttempts
+	}
+	if opts.RetryDelay == 0 {
+		opts.RetryDelay = defaultRetryDelay
+	}
+
+	cw := &chunkWriter{
+		uploader: uploader,
+		opts:     opts,
+	}
+	if opts.TempDir == "" {
+		cw.buf = &memoryChunkBuffer{}
+	} else {
+		f, err := os.CreateTemp(opts.TempDir, "mmdbwriter-chunk-*")
+		if err != nil {
+			return 0, fmt.Errorf("creating chunk buffer: %w", err)
+		}
+		fb := &fileChunkBuffer{f: f}
+		defer fb.close()
+		cw.buf = fb
+	}
+
+	if _, err := t.WriteTo(cw); err != nil {
+		return cw.uploaded, err
+	}
+	if err := cw.flush(); err != nil {
+		return cw.uploaded, err
+	}
+	return cw.uploaded, nil
+}
+
+// chunkBuffer holds the chunk currently being written.
+type chunkBuffer interface {
+	io.Writer
+	// reader returns a new reader for the buffered data.
+	reader() io.Reader
+	reset() error
+}
+
+// chunkWriter is an io.Writer that uploads the data written to it in chunks.
+type chunkWriter struct {
+	uploader ChunkUploader
+	opts     RetryOptions
+	buf      chunkBuffer
+	size     int64
+	number   int
+	uploaded int64
+}
+
+func (cw *chunkWriter) Write(p []byte) (int, error) {
+	var written int
+	for len(p) > 0 {
+		n := len(p)
+		if remaining := cw.opts.ChunkSize - cw.size; int64(n) > remaining {
+			n = int(remaining)
+		}
+		nw, err := cw.buf.Write(p[:n])
+		written += nw
+		cw.size += int64(nw)
+		if err != nil {
+			return written, fmt.Errorf("buffering chunk: %w", err)
+		}
+		p = p[n:]
+
+		if cw.size == cw.opts.ChunkSize {
+			if err := cw.flush(); err != nil {
+				return written, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// flush uploads the buffered chunk, if any.
+func (cw *chunkWriter) flush() error {
+	if cw.size == 0 {
+		return nil
+	}
+	cw.number++
+
+	delay := cw.opts.RetryDelay
+	var err error
+	for attempt := 1; attempt <= cw.opts.MaxAttempts; attempt++ {
+		if attempt > 1 {
+			time.Sleep(delay)
+			delay *= 2
+		}
+		err = cw.uploader.UploadChunk(Chunk{
+			Number: cw.number,
+			Offset: cw.uploaded,
+			Size:   cw.size,
+			Data:   cw.buf.reader(),
+		})
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return fmt.Errorf(
+			"uploading chunk %d after %d attempts: %w",
+			cw.number,
+			cw.opts.MaxAttempts,
+			err,
+		)
+	}
+
+	cw.uploaded += cw.size
+	cw.size = 0
+	if err := cw.buf.reset(); err != nil {
+		return fmt.Errorf("resetting chunk buffer: %w", err)
+	}
+	return nil
+}
+
+type memoryChunkBuffer struct {
+	bytes.Buffer
+}
+
+func (b *memoryChunkBuffer) reader() io.Reader {
+	return bytes.NewReader(b.Bytes())
+}
+
+func (b *memoryChunkBuffer) reset() error {
+	b.Reset()
+	return nil
+}
+
+type fileChunkBuffer struct {
+	f    *os.File
+	size int64
+}
+
+func (b *fileChunkBuffer) Write(p []byte) (int, error) {
+	n, err := b.f.Write(p)
+	b.size += int64(n)
+	return n, err
+}
+
+func (b *fileChunkBuffer) reader() io.Reader {
+	return io.NewSectionReader(b.f, 0, b.size)
+}
+
+func (b *fileChunkBuffer) reset() error {
+	b.size = 0
+	if err := b.f.Truncate(0); err != nil {
+		return err
+	}
+	_, err := b.f.Seek(0, io.SeekStart)
+	return err
+}
+
+func (b *fileChunkBuffer) close() {
+	//nolint:errcheck // The file is removed below and there is nothing to recover.
+	b.f.Close()
+	//nolint:errcheck // There is nothing useful to do if the removal fails.
+	os.Remove(b.f.Name())
+}
@@ -0,0 +1,133 @@
+package mmdbwriter
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testUploader struct {
+	buf      bytes.Buffer
+	failures map[int]int
+	attempts map[int]int
+	chunks   []Chunk
+}
+
+func (u *testUploader) UploadChunk(chunk Chunk) error {
+	u.attempts[chunk.Number]++
+	if u.failures[chunk.Number] > 0 {
+		u.failures[chunk.Number]--
+		// A partially read chunk must not affect the retry.
+		_, err := io.CopyN(io.Discard, chunk.Data, 1)
+		if err != nil {
+			return err
+		}
+		return errors.New("upload failed")
+	}
+	if chunk.Offset != int64(u.buf.Len()) {
+		return errors.New("unexpected offset")
+	}
+	n, err := io.Copy(&u.buf, chunk.Data)
+	if err != nil {
+		return err
+	}
+	if n != chunk.Size {
+		return errors.New("unexpected size")
+	}
+	chunk.Data = nil
+	u.chunks = append(u.chunks, chunk)
+	return nil
+}
+
+func newRetryTestTree(t *testing.T) *Tree {
+	tree, err := New(Options{})
+	require.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		ip := net.IPv4(1, 1, byte(i), 0)
+		require.NoError(t, tree.Insert(
+			&net.IPNet{IP: ip, Mask: net.CIDRMask(24, 32)},
+			mmdbtype.Map{"i": mmdbtype.Uint32(i)},
+		))
+	}
+	return tree
+}
+
+func TestWriteToWithRetry(t *testing.T) {
+	tree := newRetryTestTree(t)
+	expected := &bytes.Buffer{}
+	_, err := tree.WriteTo(expected)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		tempDir string
+	}{
+		{name: "memory"},
+		{name: "temporary file", tempDir: t.TempDir()},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			u := &testUploader{
+				failures: map[int]int{2: 2},
+				attempts: map[int]int{},
+			}
+			n, err := tree.WriteToWithRetry(u, RetryOptions{
+				ChunkSize:  1000,
+				RetryDelay: time.Nanosecond,
+				TempDir:    test.tempDir,
+			})
+			require.NoError(t, err)
+
+			assert.Equal(t, int64(expected.Len()), n)
+			assert.Equal(t, expected.Bytes(), u.buf.Bytes())
+			assert.Equal(t, 3, u.attempts[2])
+			assert.Equal(t, 1, u.attempts[1])
+
+			for i, c := range u.chunks {
+				assert.Equal(t, i+1, c.Number)
+				if i < len(u.chunks)-1 {
+					assert.Equal(t, int64(1000), c.Size)
+				}
+			}
+
+			if test.tempDir != "" {
+				entries, err := os.ReadDir(test.tempDir)
+				require.NoError(t, err)
+				assert.Empty(t, entries, "temporary file is removed")
+			}
+		})
+	}
+}
+
+func TestWriteToWithRetryFailure(t *testing.T) {
+	tree := newRetryTestTree(t)
+
+	u := &testUploader{
+		failures: map[int]int{2: 5},
+		attempts: map[int]int{},
+	}
+	n, err := tree.WriteToWithRetry(u, RetryOptions{
+		ChunkSize:   1000,
+		MaxAttempts: 2,
+		RetryDelay:  time.Nanosecond,
+	})
+	assert.EqualError(
+		t,
+		err,
+		"flushing buffer to writer: uploading chunk 2 after 2 attempts: upload failed",
+	)
+	assert.Equal(t, int64(1000), n)
+	assert.Equal(t, 2, u.attempts[2])
+	assert.Zero(t, u.attempts[3], "no further chunks are uploaded")
+
+	_, err = tree.WriteToWithRetry(u, RetryOptions{ChunkSize: -1})
+	assert.EqualError(t, err, "ChunkSize must not be negative")
+}
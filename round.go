@@ -0,0 +1,93 @@
+package mmdbwriter
+
+import (
+	"math"
+
+	"github.com/maxmind/mmdbwriter/inserter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+// maxFloatDecimalPlaces is the maximum value of Options.FloatDecimalPlaces.
+// A float64 has at most 15 significant decimal digits, and larger values
+// would eventually overflow the scale.
+const maxFloatDecimalPlaces = 15
+
+// roundFloatsInserter wraps an inserter function so that the floating point
+// values in the value it returns are rounded to the given number of decimal
+// places.
+func roundFloatsInserter(f inserter.Func, places int) inserter.Func {
+	scale := math.Pow10(places)
+	return func(existing mmdbtype.DataType) (mmdbtype.DataType, error) {
+		v, err := f(existing)
+		if err != nil || v == nil {
+			return v, err
+		}
+		return roundFloats(v, scale), nil
+	}
+}
+
+// roundFloats returns v with all floating point values rounded using the
+// scale. As values may be shared between records, v is never modified.
+// Instead, maps and slices are copied if they contain a value that changed.
+func roundFloats(v mmdbtype.DataType, scale float64) mmdbtype.DataType {
+	return mapFloats(v, func(f float64) float64 {
+		return math.Round(f*scale) / scale
+	})
+}
+
+// mapFloats returns v with fn applied to all floating point values. Float32
+// values are converted to and from float64. As with roundFloats, v is never
+// modified.
+func mapFloats(v mmdbtype.DataType, fn func(float64) float64) mmdbtype.DataType {
+	switch v := v.(type) {
+	case mmdbtype.Float64:
+		return mmdbtype.Float64(fn(float64(v)))
+	case mmdbtype.Float32:
+		return mmdbtype.Float32(fn(float64(v)))
+	case mmdbtype.Map:
+		var newMap mmdbtype.Map
+		for k, e := range v {
+			ne := mapFloats(e, fn)
+			if newMap == nil && ne.Equal(e) {
+				continue
+			}
+			if newMap == nil {
+				newMap = make(mmdbtype.Map, len(v))
+				for k2, e2 := range v {
+					newMap[k2] = e2
+				}
+			}
+			newMap[k] = ne
+		}
+		if newMap == nil {
+			return v
+		}
+		return newMap
+	case mmdbtype.Templated:
+		// The template is mapped separately so that it remains shared when
+		// none of its values change.
+		return mmdbtype.Templated{
+			Template:  mapFloats(v.Template, fn).(mmdbtype.Map),
+			Overrides: mapFloats(v.Overrides, fn).(mmdbtype.Map),
+		}
+	case mmdbtype.Slice:
+		var newSlice mmdbtype.Slice
+		for i, e := range v {
+			ne := mapFloats(e, fn)
+			if newSlice == nil && ne.Equal(e) {
+				continue
+			}
+			if newSlice == nil {
+				newSlice = make(mmdbtype.Slice, len(v))
+				copy(newSlice, v)
+			}
+			newSlice[i] = ne
+		}
+		if newSlice == nil {
+			return v
+		}
+		return newSlice
+	default:
+		return v
+	}
+}
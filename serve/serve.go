@@ -0,0 +1,173 @@
+// Package serve provides an HTTP JSON API for looking up IP addresses in an
+// in-memory mmdbwriter.Tree. This allows a database to be checked
+// interactively before it is written and published, and allows a tree to be
+// served directly with the tree replaced as new builds complete.
+//
+// The API has a single endpoint, GET /lookup/{ip}, which returns the network
+// containing the IP address and its data, e.g.:
+//
+//	{"network":"1.1.1.0/24","data":{"country":{"iso_code":"AU"}}}
+//
+// If the tree has no data for the IP address, a 404 response is returned.
+// Errors are returned as {"error":"..."}.
+package serve
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+const lookupPath = "/lookup/"
+
+// Server serves lookups from a Tree. It implements http.Handler.
+//
+// The Tree must not be modified while it is being served. To serve an
+// updated tree, build a new Tree and pass it to SetTree.
+type Server struct {
+	mu   sync.RWMutex
+	tree *mmdbwriter.Tree
+}
+
+var _ http.Handler = &Server{}
+
+// New returns a Server for the tree.
+func New(tree *mmdbwriter.Tree) *Server {
+	return &Server{tree: tree}
+}
+
+// SetTree replaces the tree being served. Requests that are in progress
+// complete using the previous tree.
+func (s *Server) SetTree(tree *mmdbwriter.Tree) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tree = tree
+}
+
+// Tree returns the tree being served.
+func (s *Server) Tree() *mmdbwriter.Tree {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.tree
+}
+
+// LookupResponse is the response body for a successful lookup.
+type LookupResponse struct {
+	Network string `json:"network"`
+	Data    any    `json:"data"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// ServeHTTP handles the lookup requests.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, lookupPath) {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	rawIP := strings.TrimPrefix(r.URL.Path, lookupPath)
+	ip := net.ParseIP(rawIP)
+	if ip == nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("invalid IP address: %q", rawIP))
+		return
+	}
+	if ipv4 := ip.To4(); ipv4 != nil {
+		// This ensures that we return an IPv4 network for IPv4 lookups.
+		ip = ipv4
+	}
+
+	network, value := s.Tree().Get(ip)
+	if value == nil {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("no data found for %s", ip))
+		return
+	}
+
+	data, err := jsonValue(value)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, LookupResponse{
+		Network: network.String(),
+		Data:    data,
+	})
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, errorResponse{Error: msg})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		status = http.StatusInternalServerError
+		body, _ = json.Marshal( //nolint:errcheck // marshaling a string cannot fail
+			errorResponse{Error: fmt.Sprintf("encoding response: %v", err)},
+		)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	//nolint:errcheck // there is nothing useful to do if the client has gone away
+	w.Write(append(body, '\n'))
+}
+
+// jsonValue converts the value to a type that may be encoded with
+// encoding/json using mmdbtype.ToAny. Bytes are encoded as base64 strings
+// and 128-bit integers as JSON numbers. An error is returned if the value
+// contains a NaN or infinite float, which cannot be represented in JSON.
+func jsonValue(v mmdbtype.DataType) (any, error) {
+	av, err := mmdbtype.ToAny(v)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkFloats(av); err != nil {
+		return nil, err
+	}
+	return av, nil
+}
+
+// checkFloats returns an error if the value returned by mmdbtype.ToAny
+// contains a NaN or infinite float.
+func checkFloats(v any) error {
+	switch v := v.(type) {
+	case map[string]any:
+		for _, e := range v {
+			if err := checkFloats(e); err != nil {
+				return err
+			}
+		}
+	case []any:
+		for _, e := range v {
+			if err := checkFloats(e); err != nil {
+				return err
+			}
+		}
+	case float32:
+		return checkFloat(float64(v))
+	case float64:
+		return checkFloat(v)
+	}
+	return nil
+}
+
+func checkFloat(f float64) error {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return fmt.Errorf("%v cannot be represented in JSON", f)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package serve
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestTree(t *testing.T, network string, value mmdbtype.DataType) *mmdbwriter.Tree {
+	tree, err := mmdbwriter.New(mmdbwriter.Options{})
+	require.NoError(t, err)
+
+	_, ipNet, err := net.ParseCIDR(network)
+	require.NoError(t, err)
+	require.NoError(t, tree.Insert(ipNet, value))
+	return tree
+}
+
+func TestServer(t *testing.T) {
+	server := New(newTestTree(t, "1.1.1.0/24", mmdbtype.Map{
+		"country": mmdbtype.Map{"iso_code": mmdbtype.String("AU")},
+		"bytes":   mmdbtype.Bytes{1, 2},
+		"big":     mmdbtype.Uint128FromUint64(math.MaxUint64),
+		"list":    mmdbtype.Slice{mmdbtype.Bool(true), mmdbtype.Int32(-1)},
+	}))
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		status int
+		body   string
+	}{
+		{
+			name:   "IPv4 lookup",
+			method: http.MethodGet,
+			path:   "/lookup/1.1.1.1",
+			status: http.StatusOK,
+			body: `{"network":"1.1.1.0/24","data":{"big":18446744073709551615,` +
+				`"bytes":"AQI=","country":{"iso_code":"AU"},"list":[true,-1]}}`,
+		},
+		{
+			name:   "IPv4-mapped lookup",
+			method: http.MethodGet,
+			path:   "/lookup/::ffff:1.1.1.1",
+			status: http.StatusOK,
+			body: `{"network":"1.1.1.0/24","data":{"big":18446744073709551615,` +
+				`"bytes":"AQI=","country":{"iso_code":"AU"},"list":[true,-1]}}`,
+		},
+		{
+			name:   "not found",
+			method: http.MethodGet,
+			path:   "/lookup/2.2.2.2",
+			status: http.StatusNotFound,
+			body:   `{"error":"no data found for 2.2.2.2"}`,
+		},
+		{
+			name:   "invalid IP",
+			method: http.MethodGet,
+			path:   "/lookup/foo",
+			status: http.StatusBadRequest,
+			body:   `{"error":"invalid IP address: \"foo\""}`,
+		},
+		{
+			name:   "unknown path",
+			method: http.MethodGet,
+			path:   "/foo",
+			status: http.StatusNotFound,
+			body:   `{"error":"not found"}`,
+		},
+		{
+			name:   "bad method",
+			method: http.MethodPost,
+			path:   "/lookup/1.1.1.1",
+			status: http.StatusMethodNotAllowed,
+			body:   `{"error":"method not allowed"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			server.ServeHTTP(rec, httptest.NewRequest(test.method, test.path, nil))
+
+			assert.Equal(t, test.status, rec.Code)
+			assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+			assert.JSONEq(t, test.body, rec.Body.String())
+		})
+	}
+}
+
+func TestServerSetTree(t *testing.T) {
+	server := New(newTestTree(t, "1.1.1.0/24", mmdbtype.String("old")))
+	server.SetTree(newTestTree(t, "1.1.1.0/24", mmdbtype.String("new")))
+
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/lookup/1.1.1.1", nil))
+	assert.JSONEq(t, `{"network":"1.1.1.0/24","data":"new"}`, rec.Body.String())
+}
+
+func TestServerUnencodableValue(t *testing.T) {
+	server := New(newTestTree(t, "1.1.1.0/24", mmdbtype.Float64(math.NaN())))
+
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/lookup/1.1.1.1", nil))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.JSONEq(t, `{"error":"NaN cannot be represented in JSON"}`, rec.Body.String())
+}
+
+func TestServerTemplatedValue(t *testing.T) {
+	server := New(newTestTree(t, "1.1.1.0/24", mmdbtype.Templated{
+		Template:  mmdbtype.Map{"country": mmdbtype.String("AU"), "city": mmdbtype.String("Sydney")},
+		Overrides: mmdbtype.Map{"city": mmdbtype.String("Melbourne")},
+	}))
+
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/lookup/1.1.1.1", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(
+		t,
+		`{"network":"1.1.1.0/24","data":{"country":"AU","city":"Melbourne"}}`,
+		rec.Body.String(),
+	)
+}
+
+func TestServerNestedUnencodableValue(t *testing.T) {
+	server := New(newTestTree(t, "1.1.1.0/24", mmdbtype.Map{
+		"location": mmdbtype.Slice{mmdbtype.Float32(float32(math.Inf(1)))},
+	}))
+
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/lookup/1.1.1.1", nil))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.JSONEq(t, `{"error":"+Inf cannot be represented in JSON"}`, rec.Body.String())
+}
@@ -0,0 +1,74 @@
+package mmdbwriter
+
+import (
+	"sync"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+// Session is a pool of interned values shared by several trees, e.g., the
+// Country, City, and ASN databases built in one process from the same
+// sources. When a tree stores a value that is equal to a value already
+// stored by another tree in the session, it uses the existing value and its
+// key rather than keeping its own copies, so values shared between the
+// trees are only held in memory once.
+//
+// The session keeps every distinct value stored through it until the
+// session itself is no longer referenced, even if the trees that stored the
+// value have removed it or have been discarded. A session should therefore
+// be used for a single build rather than for the life of a long-running
+// process.
+//
+// A Session is safe to use from multiple goroutines, so trees in the same
+// session may be built concurrently.
+type Session struct {
+	mu     sync.Mutex
+	values map[dataMapKey]internedValue
+}
+
+// internedValue holds the key as well as the value so that the trees use
+// the session's copy of the key rather than their own.
+type internedValue struct {
+	key  dataMapKey
+	data mmdbtype.DataType
+}
+
+// NewSession returns a new, empty Session.
+func NewSession() *Session {
+	return &Session{values: map[dataMapKey]internedValue{}}
+}
+
+// New creates a new Tree in the session. It is the same as calling New with
+// Options.Session set to s.
+func (s *Session) New(opts Options) (*Tree, error) {
+	opts.Session = s
+	return New(opts)
+}
+
+// Len returns the number of distinct values in the session.
+func (s *Session) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.values)
+}
+
+// intern returns the interned key and value for the value with the given
+// key, adding the value to the session if it is not there yet. If clone is
+// set, a copy of the value is added rather than the value itself.
+func (s *Session) intern(
+	key dataMapKey,
+	v mmdbtype.DataType,
+	clone bool,
+) (dataMapKey, mmdbtype.DataType) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if iv, ok := s.values[key]; ok {
+		return iv.key, iv.data
+	}
+	if clone {
+		v = v.Copy()
+	}
+	s.values[key] = internedValue{key: key, data: v}
+	return key, v
+}
@@ -0,0 +1,115 @@
+package mmdbwriter
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSession(t *testing.T) {
+	for _, cloneValues := range []bool{false, true} {
+		s := NewSession()
+
+		country, err := s.New(Options{CloneValues: cloneValues})
+		require.NoError(t, err)
+		city, err := New(Options{Session: s, CloneValues: cloneValues})
+		require.NoError(t, err)
+
+		// The values are equal but are separate instances.
+		newValue := func() mmdbtype.Map {
+			return mmdbtype.Map{"country": mmdbtype.Map{"iso_code": mmdbtype.String("US")}}
+		}
+		require.NoError(t, country.Insert(mustNetwork(t, "1.1.1.0/24"), newValue()))
+		require.NoError(t, city.Insert(mustNetwork(t, "1.1.1.0/24"), newValue()))
+		require.NoError(t, city.Insert(
+			mustNetwork(t, "2.2.2.0/24"),
+			mmdbtype.Map{"city": mmdbtype.String("x")},
+		))
+		assert.Equal(t, 2, s.Len())
+
+		countryValue := onlyValue(t, country, "1.1.1.0/24")
+		cityValue := onlyValue(t, city, "1.1.1.0/24")
+		assert.Equal(t, countryValue.key, cityValue.key)
+		assert.Equal(
+			t,
+			reflect.ValueOf(countryValue.data).Pointer(),
+			reflect.ValueOf(cityValue.data).Pointer(),
+			"the trees share the value",
+		)
+
+		// Removing a value from a tree does not affect the other trees.
+		_, err = country.RemoveIf(func(_ *net.IPNet, _ mmdbtype.DataType) bool { return true })
+		require.NoError(t, err)
+		assert.Equal(t, newValue(), cityValue.data)
+		assertRefCounts(t, city)
+		require.NoError(t, city.Check())
+
+		// The trees write the same databases as trees without a session.
+		standalone, err := New(Options{CloneValues: cloneValues, BuildEpoch: 1})
+		require.NoError(t, err)
+		require.NoError(t, standalone.Insert(mustNetwork(t, "1.1.1.0/24"), newValue()))
+		require.NoError(t, standalone.Insert(
+			mustNetwork(t, "2.2.2.0/24"),
+			mmdbtype.Map{"city": mmdbtype.String("x")},
+		))
+		city.buildEpoch = 1
+
+		expected := &bytes.Buffer{}
+		_, err = standalone.WriteTo(expected)
+		require.NoError(t, err)
+		actual := &bytes.Buffer{}
+		_, err = city.WriteTo(actual)
+		require.NoError(t, err)
+		assert.Equal(t, expected.Bytes(), actual.Bytes())
+
+		extracted, err := city.Extract([]*net.IPNet{mustNetwork(t, "1.0.0.0/8")})
+		require.NoError(t, err)
+		assert.Same(t, s, extracted.session)
+	}
+}
+
+func TestSessionConcurrent(t *testing.T) {
+	s := NewSession()
+
+	var wg sync.WaitGroup
+	trees := make([]*Tree, 4)
+	for i := range trees {
+		tree, err := s.New(Options{IPVersion: 4, IncludeReservedNetworks: true})
+		require.NoError(t, err)
+		trees[i] = tree
+		records := testNetworkRecords(t, 4)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, r := range records {
+				assert.NoError(t, tree.Insert(r.Network, r.Value))
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 4, s.Len())
+	for _, tree := range trees {
+		assertRefCounts(t, tree)
+	}
+}
+
+// onlyValue returns the dataMapValue of the network.
+func onlyValue(t *testing.T, tree *Tree, network string) *dataMapValue {
+	ip, prefixLen := tree.treeNetwork(mustNetwork(t, network))
+	var value *dataMapValue
+	err := tree.walkWithin(ip, prefixLen, func(_ net.IP, _ int, r record) error {
+		value = tree.dataMap.get(r.value)
+		return nil
+	})
+	require.NoError(t, err)
+	require.NotNil(t, value)
+	return value
+}
@@ -0,0 +1,48 @@
+package mmdbwriter
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// WriteToSigned writes the tree to the provided Writer and returns a
+// detached Ed25519 signature for the written database. The signed message
+// is the SHA-256 digest of the entire database, which allows the signature
+// to be created and verified without holding the database in memory.
+//
+// The signature is typically published alongside the database, e.g., as
+// "GeoLite2-Custom.mmdb.sig", and verified by consumers with
+// VerifySignature.
+func (t *Tree) WriteToSigned(w io.Writer, key ed25519.PrivateKey) (int64, []byte, error) {
+	if len(key) != ed25519.PrivateKeySize {
+		return 0, nil, fmt.Errorf("invalid Ed25519 private key length: %d", len(key))
+	}
+
+	h := sha256.New()
+	n, err := t.WriteTo(io.MultiWriter(w, h))
+	if err != nil {
+		return n, nil, err
+	}
+	return n, ed25519.Sign(key, h.Sum(nil)), nil
+}
+
+// VerifySignature verifies a detached signature created by WriteToSigned
+// for the database read from r. An error is returned if the signature is
+// not valid for the database and key.
+func VerifySignature(r io.Reader, signature []byte, key ed25519.PublicKey) error {
+	if len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("invalid Ed25519 public key length: %d", len(key))
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return fmt.Errorf("reading database: %w", err)
+	}
+	if !ed25519.Verify(key, h.Sum(nil), signature) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
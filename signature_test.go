@@ -0,0 +1,58 @@
+package mmdbwriter
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"net"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignature(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+
+	tree, err := New(Options{})
+	require.NoError(t, err)
+
+	_, network, err := net.ParseCIDR("1.1.1.0/24")
+	require.NoError(t, err)
+	require.NoError(t, tree.Insert(network, mmdbtype.String("value")))
+
+	buf := &bytes.Buffer{}
+	n, signature, err := tree.WriteToSigned(buf, privateKey)
+	require.NoError(t, err)
+	assert.Equal(t, int64(buf.Len()), n)
+	assert.Len(t, signature, ed25519.SignatureSize)
+
+	db := buf.Bytes()
+	require.NoError(t, VerifySignature(bytes.NewReader(db), signature, publicKey))
+
+	corrupt := append([]byte(nil), db...)
+	corrupt[len(corrupt)-1] ^= 0xff
+	assert.EqualError(
+		t,
+		VerifySignature(bytes.NewReader(corrupt), signature, publicKey),
+		"invalid signature",
+	)
+
+	otherKey, _, err := ed25519.GenerateKey(nil)
+	require.NoError(t, err)
+	assert.EqualError(
+		t,
+		VerifySignature(bytes.NewReader(db), signature, otherKey),
+		"invalid signature",
+	)
+
+	assert.EqualError(
+		t,
+		VerifySignature(bytes.NewReader(db), signature, publicKey[:10]),
+		"invalid Ed25519 public key length: 10",
+	)
+
+	_, _, err = tree.WriteToSigned(buf, privateKey[:10])
+	assert.EqualError(t, err, "invalid Ed25519 private key length: 10")
+}
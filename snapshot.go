@@ -0,0 +1,107 @@
+package mmdbwriter
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+// Snapshot is an immutable copy of the search tree of a Tree. Unlike the
+// Tree, it is safe to call Get and Lookup on a Snapshot from multiple
+// goroutines while another goroutine continues to insert into the Tree.
+//
+// A typical use is to build a new Snapshot after each batch of inserts and
+// publish it to readers with an atomic.Value, replacing the previous one.
+type Snapshot struct {
+	dataMap    *dataMap
+	root       *node
+	treeDepth  int
+	ipv6Only   bool
+	ipv4Prefix net.IP
+}
+
+// Snapshot returns a Snapshot of the current state of the tree. Creating a
+// Snapshot copies every node of the search tree and the references to the
+// distinct values, so its cost is proportional to the size of the tree.
+// The data values are shared with the tree rather than copied, except with
+// ValueStorageFile, where each distinct value is decoded into memory so
+// that the Snapshot remains usable after Tree.Close.
+//
+// This is not safe to call concurrently with inserts into the tree.
+func (t *Tree) Snapshot() *Snapshot {
+	c := &snapshotCopier{fixed: map[*node]*node{}}
+	return &Snapshot{
+		dataMap:    t.dataMap.snapshot(),
+		root:       c.copyNode(t.root),
+		treeDepth:  t.treeDepth,
+		ipv6Only:   t.ipv6Only,
+		ipv4Prefix: t.ipv4Prefix,
+	}
+}
+
+// snapshotCopier copies a search tree. The fixed nodes are tracked so that
+// the copied aliases point to the copy of the IPv4 subtree.
+type snapshotCopier struct {
+	fixed map[*node]*node
+}
+
+func (c *snapshotCopier) copyNode(n *node) *node {
+	cp := &node{children: n.children}
+	for i := range cp.children {
+		r := &cp.children[i]
+		switch r.recordType {
+		case recordTypeNode:
+			r.node = c.copyNode(r.node)
+		case recordTypeFixedNode, recordTypeAlias:
+			r.node = c.copyFixedNode(r.node)
+		default:
+		}
+	}
+	return cp
+}
+
+func (c *snapshotCopier) copyFixedNode(n *node) *node {
+	if cp, ok := c.fixed[n]; ok {
+		return cp
+	}
+	cp := c.copyNode(n)
+	c.fixed[n] = cp
+	return cp
+}
+
+// Get returns the network and value for the IP address, the same as
+// Tree.Get.
+func (s *Snapshot) Get(ip net.IP) (*net.IPNet, mmdbtype.DataType) {
+	network, value, _ := s.GetRecord(ip)
+	return network, value
+}
+
+// GetRecord returns the network and value for the IP address and whether
+// there is data for it, the same as Tree.GetRecord.
+func (s *Snapshot) GetRecord(ip net.IP) (*net.IPNet, mmdbtype.DataType, bool) {
+	if s.ipv6Only && ip.To4() != nil {
+		return nil, nil, false
+	}
+	return get(s.dataMap, s.root, s.treeDepth, s.ipv4Prefix, ip)
+}
+
+// Lookup decodes the data for the IP address into result, the same as
+// Tree.Lookup.
+func (s *Snapshot) Lookup(ip net.IP, result any) error {
+	rv := reflect.ValueOf(result)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return errors.New("result param must be a pointer")
+	}
+	if s.ipv6Only && ip.To4() != nil {
+		return fmt.Errorf("cannot look up %s: %w", ip, ErrIPv4InIPv6OnlyTree)
+	}
+
+	_, value := s.Get(ip)
+	if value == nil {
+		return nil
+	}
+	return unmarshal(value, rv)
+}
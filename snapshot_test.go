@@ -0,0 +1,79 @@
+package mmdbwriter
+
+import (
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshot(t *testing.T) {
+	tree, err := New(Options{})
+	require.NoError(t, err)
+
+	insert(t, tree, "1.1.1.0/24", "a")
+	insert(t, tree, "2600::/32", "b")
+
+	snapshot := tree.Snapshot()
+
+	insert(t, tree, "1.1.1.0/25", "c")
+	insert(t, tree, "2600::/32", "d")
+
+	tests := []struct {
+		ip               string
+		expectedNetwork  string
+		expectedTree     mmdbtype.DataType
+		expectedSnapshot mmdbtype.DataType
+	}{
+		{ip: "1.1.1.1", expectedNetwork: "1.1.1.0/25", expectedTree: mmdbtype.String("c")},
+		{ip: "1.1.1.200", expectedNetwork: "1.1.1.128/25", expectedTree: mmdbtype.String("a")},
+		{ip: "2002:101:101::", expectedNetwork: "2002:101:100::/41", expectedTree: mmdbtype.String("c")},
+		{ip: "2600::1", expectedNetwork: "2600::/32", expectedTree: mmdbtype.String("d")},
+	}
+	for _, test := range tests {
+		network, value := tree.Get(net.ParseIP(test.ip))
+		assert.Equal(t, test.expectedNetwork, network.String(), test.ip)
+		assert.Equal(t, test.expectedTree, value, test.ip)
+	}
+
+	for _, ip := range []string{"1.1.1.1", "1.1.1.200", "2002:101:101::"} {
+		_, value := snapshot.Get(net.ParseIP(ip))
+		assert.Equal(t, mmdbtype.String("a"), value, ip)
+	}
+	_, value := snapshot.Get(net.ParseIP("2600::1"))
+	assert.Equal(t, mmdbtype.String("b"), value)
+
+	var s string
+	require.NoError(t, snapshot.Lookup(net.ParseIP("2600::1"), &s))
+	assert.Equal(t, "b", s)
+}
+
+func TestSnapshotConcurrentReads(t *testing.T) {
+	tree, err := New(Options{})
+	require.NoError(t, err)
+	insert(t, tree, "1.1.1.0/24", "a")
+
+	snapshot := tree.Snapshot()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				_, value := snapshot.Get(net.ParseIP("1.1.1.1"))
+				assert.Equal(t, mmdbtype.String("a"), value)
+			}
+		}()
+	}
+
+	for _, network := range []string{"1.1.1.0/24", "1.1.1.0/25", "1.0.0.0/8"} {
+		_, ipNet, err := net.ParseCIDR(network)
+		require.NoError(t, err)
+		require.NoError(t, tree.Insert(ipNet, mmdbtype.String(network)))
+	}
+	wg.Wait()
+}
@@ -0,0 +1,97 @@
+package mmdbwriter
+
+import (
+	"net"
+)
+
+// sourceTracker records the source label of the most recent insert for each
+// network. It is a simple binary trie that is independent of the search
+// tree so that it does not affect node merging or the written database.
+type sourceTracker struct {
+	root    sourceNode
+	current string
+}
+
+type sourceNode struct {
+	children [2]*sourceNode
+	source   string
+	set      bool
+}
+
+// insert records the current source for the network. As the new insert
+// supersedes any earlier inserts within the network, we drop the subtree
+// below it. This means the deepest set node along a path is always the most
+// recent insert for that address.
+func (st *sourceTracker) insert(ip net.IP, prefixLen int) {
+	n := &st.root
+	for depth := 0; depth < prefixLen; depth++ {
+		bit := bitAt(ip, depth)
+		if n.children[bit] == nil {
+			n.children[bit] = &sourceNode{}
+		}
+		n = n.children[bit]
+	}
+	n.children = [2]*sourceNode{}
+	n.source = st.current
+	n.set = true
+}
+
+// lookup returns the prefix length of the network of the most recent insert
+// containing the IP and its source.
+func (st *sourceTracker) lookup(ip net.IP, treeDepth int) (int, string, bool) {
+	n := &st.root
+	prefixLen, source, ok := 0, "", false
+	for depth := 0; n != nil; depth++ {
+		if n.set {
+			prefixLen, source, ok = depth, n.source, true
+		}
+		if depth == treeDepth {
+			break
+		}
+		n = n.children[bitAt(ip, depth)]
+	}
+	return prefixLen, source, ok
+}
+
+// SetSource sets the source label that is recorded for subsequent inserts
+// when Options.TrackSources is enabled. The labels are only kept in memory
+// and are never written to the database.
+func (t *Tree) SetSource(source string) {
+	if t.sources != nil {
+		t.sources.current = source
+	}
+}
+
+// Source returns the network and source label of the most recent insert
+// containing the IP. The bool is false if Options.TrackSources is not
+// enabled or if there have been no inserts containing the IP.
+//
+// Inserts that remove data, e.g., with inserter.Remove, are still recorded
+// as the most recent insert for the network.
+func (t *Tree) Source(ip net.IP) (*net.IPNet, string, bool) {
+	if t.sources == nil {
+		return nil, "", false
+	}
+
+	lookupIP := ip
+	if t.treeDepth == 128 {
+		if ipv4 := ip.To4(); ipv4 != nil {
+			lookupIP = t.ipv4ToTree(ipv4)
+		}
+	}
+
+	prefixLen, source, ok := t.sources.lookup(lookupIP, t.treeDepth)
+	if !ok {
+		return nil, "", false
+	}
+
+	// See the comment in Get.
+	if prefixLen >= 96 && len(ip) == 4 {
+		prefixLen -= 96
+	}
+	mask := net.CIDRMask(prefixLen, t.treeDepth)
+	return &net.IPNet{
+		IP:   ip.Mask(mask),
+		Mask: mask,
+	}, source, true
+}
@@ -0,0 +1,71 @@
+package mmdbwriter
+
+import (
+	"net"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSource(t *testing.T) {
+	tree, err := New(Options{TrackSources: true})
+	require.NoError(t, err)
+
+	inserts := []struct {
+		source  string
+		network string
+	}{
+		{source: "geonames", network: "1.1.0.0/16"},
+		{source: "whois", network: "1.1.1.0/24"},
+		{source: "corrections", network: "1.1.1.128/25"},
+		{source: "whois", network: "2003::/16"},
+		// This replaces all of the above within 2.0.0.0/8.
+		{source: "bgp", network: "2.2.0.0/16"},
+		{source: "geonames", network: "2.2.2.0/24"},
+		{source: "bgp", network: "2.0.0.0/8"},
+	}
+	for _, insert := range inserts {
+		_, network, err := net.ParseCIDR(insert.network)
+		require.NoError(t, err)
+
+		tree.SetSource(insert.source)
+		require.NoError(t, tree.Insert(network, mmdbtype.String(insert.network)))
+	}
+
+	tests := []struct {
+		ip              string
+		expectedNetwork string
+		expectedSource  string
+	}{
+		{ip: "1.1.0.1", expectedNetwork: "1.1.0.0/16", expectedSource: "geonames"},
+		{ip: "1.1.1.1", expectedNetwork: "1.1.1.0/24", expectedSource: "whois"},
+		{ip: "1.1.1.200", expectedNetwork: "1.1.1.128/25", expectedSource: "corrections"},
+		{ip: "2003:1::", expectedNetwork: "2003::/16", expectedSource: "whois"},
+		{ip: "2.2.2.2", expectedNetwork: "2.0.0.0/8", expectedSource: "bgp"},
+	}
+	for _, test := range tests {
+		network, source, ok := tree.Source(net.ParseIP(test.ip))
+		require.True(t, ok, "source found for %s", test.ip)
+		assert.Equal(t, test.expectedNetwork, network.String(), "network for %s", test.ip)
+		assert.Equal(t, test.expectedSource, source, "source for %s", test.ip)
+	}
+
+	_, _, ok := tree.Source(net.ParseIP("3.3.3.3"))
+	assert.False(t, ok)
+}
+
+func TestSourceDisabled(t *testing.T) {
+	tree, err := New(Options{})
+	require.NoError(t, err)
+
+	_, network, err := net.ParseCIDR("1.1.1.0/24")
+	require.NoError(t, err)
+
+	tree.SetSource("whois")
+	require.NoError(t, tree.Insert(network, mmdbtype.String("value")))
+
+	_, _, ok := tree.Source(net.ParseIP("1.1.1.1"))
+	assert.False(t, ok)
+}
@@ -0,0 +1,67 @@
+package mmdbwriter
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/maxmind/mmdbwriter/inserter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+// SplitBy partitions the tree by the value of the field at the
+// dot-separated path, e.g., "country.iso_code", returning a new tree for
+// each value with the networks whose records have it, e.g., to write a
+// small database per country. The trees are keyed by the string form of
+// the values, which is the same as for NetworksWhere. If a field within
+// the path is a slice, e.g., "subdivisions.iso_code", a record is in the
+// tree of each of the values of its elements. Records without the field
+// are not in any of the trees.
+//
+// The trees have the same options as t, and the records are copied as with
+// Extract. The original tree is not modified.
+func (t *Tree) SplitBy(fieldPath string) (map[string]*Tree, error) {
+	var path []mmdbtype.String
+	for _, key := range strings.Split(fieldPath, ".") {
+		if key == "" {
+			return nil, fmt.Errorf("invalid field: %q", fieldPath)
+		}
+		path = append(path, mmdbtype.String(key))
+	}
+
+	trees := map[string]*Tree{}
+	err := t.root.walk(make(net.IP, t.treeDepth/8), 0, func(ip net.IP, prefixLen int, r record) error {
+		if r.recordType != recordTypeData {
+			return nil
+		}
+		value := t.dataMap.load(r.value)
+		for _, key := range indexKeys(value, path, nil) {
+			tree, ok := trees[key]
+			if !ok {
+				var err error
+				tree, err = New(t.options())
+				if err != nil {
+					return err
+				}
+				trees[key] = tree
+			}
+			network := &net.IPNet{
+				IP:   make(net.IP, len(ip)),
+				Mask: net.CIDRMask(prefixLen, t.treeDepth),
+			}
+			copy(network.IP, ip)
+			err := tree.insert(network, recordTypeData, inserter.ReplaceWith(value), nil)
+			if err != nil {
+				return fmt.Errorf("inserting %s into the tree for %q: %w", network, key, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		for _, tree := range trees {
+			tree.Close() //nolint:errcheck // the walk error is more relevant
+		}
+		return nil, err
+	}
+	return trees, nil
+}
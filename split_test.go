@@ -0,0 +1,69 @@
+package mmdbwriter
+
+import (
+	"net"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitBy(t *testing.T) {
+	tree, err := New(Options{DatabaseType: "City"})
+	require.NoError(t, err)
+
+	record := func(country string, subdivisions ...string) mmdbtype.Map {
+		s := mmdbtype.Slice{}
+		for _, subdivision := range subdivisions {
+			s = append(s, mmdbtype.Map{"iso_code": mmdbtype.String(subdivision)})
+		}
+		return mmdbtype.Map{
+			"country":      mmdbtype.Map{"iso_code": mmdbtype.String(country)},
+			"subdivisions": s,
+		}
+	}
+	us := record("US", "CA")
+	de := record("DE", "BE", "BB")
+	require.NoError(t, tree.Insert(mustNetwork(t, "1.1.1.0/24"), us))
+	require.NoError(t, tree.Insert(mustNetwork(t, "2.2.2.0/24"), de))
+	require.NoError(t, tree.Insert(mustNetwork(t, "3.3.3.0/24"), us))
+	require.NoError(t, tree.Insert(mustNetwork(t, "2003::/16"), de))
+	require.NoError(t, tree.Insert(mustNetwork(t, "4.4.4.0/24"), mmdbtype.Map{"other": mmdbtype.Bool(true)}))
+
+	networks := func(tree *Tree) map[string]mmdbtype.DataType {
+		records := map[string]mmdbtype.DataType{}
+		require.NoError(t, tree.Walk(func(network *net.IPNet, value mmdbtype.DataType) (bool, error) {
+			if value != nil {
+				records[network.String()] = value
+			}
+			return true, nil
+		}))
+		return records
+	}
+
+	trees, err := tree.SplitBy("country.iso_code")
+	require.NoError(t, err)
+	require.Len(t, trees, 2)
+	assert.Equal(t, map[string]mmdbtype.DataType{
+		"1.1.1.0/24": us,
+		"3.3.3.0/24": us,
+	}, networks(trees["US"]))
+	assert.Equal(t, map[string]mmdbtype.DataType{
+		"2.2.2.0/24": de,
+		"2003::/16":  de,
+	}, networks(trees["DE"]))
+	assert.Equal(t, "City", trees["US"].databaseType)
+
+	trees, err = tree.SplitBy("subdivisions.iso_code")
+	require.NoError(t, err)
+	assert.Len(t, trees, 3)
+	assert.Equal(t, networks(trees["BE"]), networks(trees["BB"]))
+	assert.Len(t, networks(trees["CA"]), 2)
+
+	_, value := tree.Get(net.ParseIP("1.1.1.1"))
+	assert.Equal(t, us, value, "the original tree is not modified")
+
+	_, err = tree.SplitBy("country.")
+	assert.EqualError(t, err, `invalid field: "country."`)
+}
@@ -0,0 +1,7 @@
+module github.com/maxmind/mmdbwriter/sqlite/drivertest
+
+go 1.18
+
+require github.com/maxmind/mmdbwriter v0.0.0
+
+replace github.com/maxmind/mmdbwriter => ../..
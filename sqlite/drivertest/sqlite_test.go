@@ -0,0 +1,149 @@
+// Package drivertest tests the sqlite package with the modernc.org/sqlite
+// driver. It is a separate module so that the driver is not a dependency of
+// mmdbwriter. The driver is not pinned in go.mod; run go mod tidy in this
+// directory before go test.
+package drivertest
+
+import (
+	"context"
+	"database/sql"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/maxmind/mmdbwriter/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	_ "modernc.org/sqlite"
+)
+
+func TestExportImport(t *testing.T) {
+	records := map[string]mmdbtype.DataType{
+		"1.1.1.0/24": mmdbtype.Map{
+			"country": mmdbtype.Map{"iso_code": mmdbtype.String("AU")},
+			"asn":     mmdbtype.Uint32(13335),
+		},
+		"2.2.2.0/23": mmdbtype.Map{"lat": mmdbtype.Float64(48.85)},
+		"3.3.3.3/32": mmdbtype.String("x"),
+	}
+
+	tests := []struct {
+		name     string
+		opts     sqlite.Options
+		expected map[string]mmdbtype.DataType
+	}{
+		{
+			name: "plain",
+			expected: map[string]mmdbtype.DataType{
+				"1.1.1.0/24": mmdbtype.Map{
+					"country": mmdbtype.Map{"iso_code": mmdbtype.String("AU")},
+					"asn":     mmdbtype.Int32(13335),
+				},
+				"2.2.2.0/23": mmdbtype.Map{"lat": mmdbtype.Float64(48.85)},
+				"3.3.3.3/32": mmdbtype.String("x"),
+			},
+		},
+		{
+			name:     "typed",
+			opts:     sqlite.Options{Typed: true, Table: "snapshot_1"},
+			expected: records,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := openDB(t)
+			ctx := context.Background()
+
+			tree := newTree(t)
+			for network, value := range records {
+				require.NoError(t, tree.Insert(mustNetwork(t, network), value))
+			}
+
+			// A second export replaces the rows of the first.
+			_, err := sqlite.Export(ctx, db, tree, test.opts)
+			require.NoError(t, err)
+			n, err := sqlite.Export(ctx, db, tree, test.opts)
+			require.NoError(t, err)
+			assert.Equal(t, len(records), n)
+
+			imported := newTree(t)
+			n, err = sqlite.Import(ctx, db, imported, test.opts)
+			require.NoError(t, err)
+			assert.Equal(t, len(records), n)
+
+			actual := map[string]mmdbtype.DataType{}
+			err = imported.Walk(func(network *net.IPNet, value mmdbtype.DataType) (bool, error) {
+				if value != nil {
+					actual[network.String()] = value
+				}
+				return true, nil
+			})
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestImportEditedWithSQL(t *testing.T) {
+	db := openDB(t)
+	ctx := context.Background()
+
+	tree := newTree(t)
+	require.NoError(t, tree.Insert(
+		mustNetwork(t, "11.0.0.0/8"),
+		mmdbtype.Map{"country": mmdbtype.String("US")},
+	))
+	_, err := sqlite.Export(ctx, db, tree, sqlite.Options{})
+	require.NoError(t, err)
+
+	// The network column sorts 11.0.0.0/16 before 11.0.0.0/8.
+	_, err = db.Exec(
+		`INSERT INTO networks (network, record) VALUES ('11.0.0.0/16', json_object('country', 'CA'))`,
+	)
+	require.NoError(t, err)
+	_, err = db.Exec(
+		`UPDATE networks SET record = json_set(record, '$.asn', 64512) WHERE network = '11.0.0.0/8'`,
+	)
+	require.NoError(t, err)
+
+	// The network is the primary key.
+	_, err = db.Exec(`INSERT INTO networks (network, record) VALUES ('11.0.0.0/8', '{}')`)
+	assert.Error(t, err)
+
+	imported := newTree(t)
+	n, err := sqlite.Import(ctx, db, imported, sqlite.Options{})
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	_, value := imported.Get(net.ParseIP("11.0.0.1").To4())
+	assert.Equal(t, mmdbtype.Map{"country": mmdbtype.String("CA")}, value)
+
+	_, value = imported.Get(net.ParseIP("11.1.0.1").To4())
+	assert.Equal(
+		t,
+		mmdbtype.Map{"country": mmdbtype.String("US"), "asn": mmdbtype.Int32(64512)},
+		value,
+	)
+}
+
+func openDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func newTree(t *testing.T) *mmdbwriter.Tree {
+	tree, err := mmdbwriter.New(mmdbwriter.Options{IPVersion: 4})
+	require.NoError(t, err)
+	return tree
+}
+
+func mustNetwork(t *testing.T, s string) *net.IPNet {
+	_, network, err := net.ParseCIDR(s)
+	require.NoError(t, err)
+	return network
+}
@@ -0,0 +1,240 @@
+// Package sqlite exports the networks and records of an mmdbwriter.Tree to
+// a SQLite table and imports them back, allowing the contents of a database
+// to be edited with SQL between builds.
+//
+// The table has the schema
+//
+//	CREATE TABLE networks (network TEXT PRIMARY KEY, record JSON NOT NULL)
+//
+// where network is in CIDR notation and record is the JSON encoding of the
+// record. By default, records are plain JSON, e.g., {"country":"US"}, which
+// works well with the SQLite JSON functions but does not preserve the
+// MaxMind DB types of the values. Set Options.Typed to use the typed
+// encoding of the mmdbtype MarshalJSON methods instead.
+//
+// The package uses database/sql and does not import a SQLite driver. The
+// caller opens the *sql.DB with the driver of their choice, e.g.,
+// modernc.org/sqlite or github.com/mattn/go-sqlite3.
+package sqlite
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+// DefaultTable is the name of the table if Options.Table is not set.
+const DefaultTable = "networks"
+
+// Options configures Export and Import.
+type Options struct {
+	// Table is the name of the table. It defaults to DefaultTable.
+	Table string
+
+	// Typed causes the records to be encoded with the typed JSON
+	// representation of the mmdbtype package, e.g.,
+	// {"type":"map","value":{"country":{"type":"string","value":"US"}}},
+	// rather than as plain JSON. This preserves the MaxMind DB types, e.g.,
+	// uint32 versus int32, through an export and import.
+	Typed bool
+}
+
+var tableNameRE = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func (o Options) table() (string, error) {
+	if o.Table == "" {
+		return DefaultTable, nil
+	}
+	if !tableNameRE.MatchString(o.Table) {
+		return "", fmt.Errorf("invalid table name: %q", o.Table)
+	}
+	return o.Table, nil
+}
+
+// Export writes the networks in the tree and their records to the table,
+// creating the table if it does not exist and replacing any rows it
+// already contains. The export runs in a single transaction. It returns the
+// number of rows written.
+func Export(ctx context.Context, db *sql.DB, tree *mmdbwriter.Tree, opts Options) (int, error) {
+	table, err := opts.table()
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("beginning transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, fmt.Sprintf(
+		`CREATE TABLE IF NOT EXISTS %s (network TEXT PRIMARY KEY, record JSON NOT NULL)`,
+		table,
+	))
+	if err != nil {
+		return 0, fmt.Errorf("creating table %s: %w", table, err)
+	}
+	if _, err := tx.ExecContext(ctx, fmt.Sprintf(`DELETE FROM %s`, table)); err != nil {
+		return 0, fmt.Errorf("deleting existing rows from %s: %w", table, err)
+	}
+
+	stmt, err := tx.PrepareContext(ctx, fmt.Sprintf(
+		`INSERT INTO %s (network, record) VALUES (?, ?)`,
+		table,
+	))
+	if err != nil {
+		return 0, fmt.Errorf("preparing insert: %w", err)
+	}
+	defer stmt.Close()
+
+	count := 0
+	err = tree.Walk(func(network *net.IPNet, value mmdbtype.DataType) (bool, error) {
+		if value == nil {
+			return true, nil
+		}
+		record, err := encodeRecord(value, opts.Typed)
+		if err != nil {
+			return false, fmt.Errorf("encoding record for %s: %w", network, err)
+		}
+		if _, err := stmt.ExecContext(ctx, network.String(), record); err != nil {
+			return false, fmt.Errorf("inserting %s: %w", network, err)
+		}
+		count++
+		return true, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("committing transaction: %w", err)
+	}
+	return count, nil
+}
+
+// Import inserts the networks and records in the table into the tree. The
+// rows are inserted with Tree.Insert, so the tree's inserter and other
+// insert options apply. The rows are inserted from the least to the most
+// specific network, so that a row for a network within another row's
+// network is not overwritten by it, and in the order of the network column
+// otherwise. The rows are read before any are inserted. It returns the
+// number of rows inserted.
+func Import(ctx context.Context, db *sql.DB, tree *mmdbwriter.Tree, opts Options) (int, error) {
+	table, err := opts.table()
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := readRows(ctx, db, table, opts.Typed)
+	if err != nil {
+		return 0, err
+	}
+
+	// The network column sorts the networks lexically, e.g., 10.0.0.0/16
+	// before 10.0.0.0/8.
+	sort.SliceStable(rows, func(i, j int) bool {
+		return rows[i].prefixLen < rows[j].prefixLen
+	})
+
+	for i, r := range rows {
+		if err := tree.Insert(r.network, r.value); err != nil {
+			return i, fmt.Errorf("inserting %s: %w", r.cidr, err)
+		}
+	}
+	return len(rows), nil
+}
+
+type row struct {
+	cidr    string
+	network *net.IPNet
+	// prefixLen is the prefix length of the network as an IPv6 network,
+	// so that IPv4 and IPv6 networks may be compared.
+	prefixLen int
+	value     mmdbtype.DataType
+}
+
+func readRows(ctx context.Context, db *sql.DB, table string, typed bool) ([]row, error) {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(
+		`SELECT network, record FROM %s ORDER BY network`,
+		table,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("querying %s: %w", table, err)
+	}
+	defer rows.Close()
+
+	var result []row
+	for rows.Next() {
+		var (
+			cidr   string
+			record []byte
+		)
+		if err := rows.Scan(&cidr, &record); err != nil {
+			return nil, fmt.Errorf("scanning row: %w", err)
+		}
+
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("parsing network: %w", err)
+		}
+		value, err := decodeRecord(record, typed)
+		if err != nil {
+			return nil, fmt.Errorf("decoding record for %s: %w", cidr, err)
+		}
+		prefixLen, bits := network.Mask.Size()
+		if bits == 8*net.IPv4len {
+			prefixLen += 8 * (net.IPv6len - net.IPv4len)
+		}
+		result = append(result, row{
+			cidr:      cidr,
+			network:   network,
+			prefixLen: prefixLen,
+			value:     value,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading rows: %w", err)
+	}
+	return result, nil
+}
+
+func encodeRecord(value mmdbtype.DataType, typed bool) (string, error) {
+	var (
+		b   []byte
+		err error
+	)
+	if typed {
+		b, err = json.Marshal(value)
+	} else {
+		var v any
+		v, err = mmdbtype.ToAny(value)
+		if err == nil {
+			b, err = json.Marshal(v)
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func decodeRecord(record []byte, typed bool) (mmdbtype.DataType, error) {
+	if typed {
+		return mmdbtype.UnmarshalJSON(record)
+	}
+	d := json.NewDecoder(bytes.NewReader(record))
+	d.UseNumber()
+	var v any
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	return mmdbtype.FromAny(v)
+}
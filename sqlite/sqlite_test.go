@@ -0,0 +1,315 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportImport(t *testing.T) {
+	u := mmdbtype.Uint128(*big.NewInt(8))
+	records := map[string]mmdbtype.DataType{
+		"1.1.1.0/24": mmdbtype.Map{
+			"country": mmdbtype.Map{"iso_code": mmdbtype.String("AU")},
+			"asn":     mmdbtype.Uint32(13335),
+			"tags":    mmdbtype.Slice{mmdbtype.String("a"), mmdbtype.Bool(true)},
+		},
+		"2.2.2.0/23": mmdbtype.Map{
+			"lat":   mmdbtype.Float64(48.85),
+			"bytes": mmdbtype.Bytes{1, 2},
+			"big":   &u,
+		},
+		"3.3.3.3/32": mmdbtype.String("x"),
+	}
+
+	tests := []struct {
+		name     string
+		opts     Options
+		expected map[string]mmdbtype.DataType
+	}{
+		{
+			name: "plain",
+			expected: map[string]mmdbtype.DataType{
+				"1.1.1.0/24": mmdbtype.Map{
+					"country": mmdbtype.Map{"iso_code": mmdbtype.String("AU")},
+					"asn":     mmdbtype.Int32(13335),
+					"tags":    mmdbtype.Slice{mmdbtype.String("a"), mmdbtype.Bool(true)},
+				},
+				"2.2.2.0/23": mmdbtype.Map{
+					"lat":   mmdbtype.Float64(48.85),
+					"bytes": mmdbtype.String("AQI="),
+					"big":   mmdbtype.Int32(8),
+				},
+				"3.3.3.3/32": mmdbtype.String("x"),
+			},
+		},
+		{
+			name:     "typed",
+			opts:     Options{Typed: true, Table: "snapshot_1"},
+			expected: records,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := openTestDB(t)
+			// Export replaces existing rows.
+			table := test.opts.Table
+			if table == "" {
+				table = DefaultTable
+			}
+			_, err := db.Exec(
+				`INSERT INTO `+table+` (network, record) VALUES (?, ?)`,
+				"9.9.9.0/24",
+				`"stale"`,
+			)
+			require.NoError(t, err)
+
+			tree, err := mmdbwriter.New(mmdbwriter.Options{IPVersion: 4})
+			require.NoError(t, err)
+			for network, value := range records {
+				require.NoError(t, tree.Insert(mustNetwork(t, network), value))
+			}
+
+			n, err := Export(context.Background(), db, tree, test.opts)
+			require.NoError(t, err)
+			assert.Equal(t, len(records), n)
+
+			imported, err := mmdbwriter.New(mmdbwriter.Options{IPVersion: 4})
+			require.NoError(t, err)
+			n, err = Import(context.Background(), db, imported, test.opts)
+			require.NoError(t, err)
+			assert.Equal(t, len(records), n)
+
+			actual := map[string]mmdbtype.DataType{}
+			err = imported.Walk(func(network *net.IPNet, value mmdbtype.DataType) (bool, error) {
+				if value != nil {
+					actual[network.String()] = value
+				}
+				return true, nil
+			})
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestImportEdited(t *testing.T) {
+	db := openTestDB(t)
+	for _, row := range [][2]string{
+		{"1.1.1.0/24", `{"country":"AU"}`},
+		{"1.1.1.128/25", `{"country":"NZ","asn":4294967296}`},
+	} {
+		_, err := db.Exec(`INSERT INTO networks (network, record) VALUES (?, ?)`, row[0], row[1])
+		require.NoError(t, err)
+	}
+
+	tree, err := mmdbwriter.New(mmdbwriter.Options{IPVersion: 4})
+	require.NoError(t, err)
+	n, err := Import(context.Background(), db, tree, Options{})
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	_, value := tree.Get(net.ParseIP("1.1.1.1").To4())
+	assert.Equal(t, mmdbtype.Map{"country": mmdbtype.String("AU")}, value)
+
+	_, value = tree.Get(net.ParseIP("1.1.1.129").To4())
+	assert.Equal(
+		t,
+		mmdbtype.Map{"country": mmdbtype.String("NZ"), "asn": mmdbtype.Uint64(4294967296)},
+		value,
+	)
+}
+
+func TestImportOverlapping(t *testing.T) {
+	// The network column sorts 11.0.0.0/16 before 11.0.0.0/8, which
+	// would overwrite it if the rows were inserted in that order.
+	db := openTestDB(t)
+	for _, row := range [][2]string{
+		{"11.0.0.0/8", `{"country":"US"}`},
+		{"11.0.0.0/16", `{"country":"CA"}`},
+		{"11.0.0.0/24", `{"country":"MX"}`},
+	} {
+		_, err := db.Exec(`INSERT INTO networks (network, record) VALUES (?, ?)`, row[0], row[1])
+		require.NoError(t, err)
+	}
+
+	tree, err := mmdbwriter.New(mmdbwriter.Options{IPVersion: 4})
+	require.NoError(t, err)
+	n, err := Import(context.Background(), db, tree, Options{})
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+
+	for ip, country := range map[string]string{
+		"11.0.0.1": "MX",
+		"11.0.1.1": "CA",
+		"11.1.0.1": "US",
+	} {
+		_, value := tree.Get(net.ParseIP(ip).To4())
+		assert.Equal(t, mmdbtype.Map{"country": mmdbtype.String(country)}, value, ip)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	ctx := context.Background()
+	tree, err := mmdbwriter.New(mmdbwriter.Options{IPVersion: 4})
+	require.NoError(t, err)
+
+	db := openTestDB(t)
+
+	_, err = Export(ctx, db, tree, Options{Table: "networks; DROP TABLE x"})
+	assert.EqualError(t, err, `invalid table name: "networks; DROP TABLE x"`)
+
+	_, err = Import(ctx, db, tree, Options{Table: "1networks"})
+	assert.EqualError(t, err, `invalid table name: "1networks"`)
+
+	for _, test := range []struct {
+		network     string
+		record      string
+		opts        Options
+		expectedErr string
+	}{
+		{
+			network:     "1.1.1.1",
+			record:      `{}`,
+			expectedErr: "parsing network: invalid CIDR address: 1.1.1.1",
+		},
+		{
+			network:     "1.1.1.0/24",
+			record:      `{`,
+			expectedErr: "decoding record for 1.1.1.0/24: unexpected EOF",
+		},
+		{
+			network:     "1.1.1.0/24",
+			record:      `{"a":-3000000000}`,
+			expectedErr: `decoding record for 1.1.1.0/24: map key "a": -3000000000 is less than the minimum int32`,
+		},
+		{
+			network:     "1.1.1.0/24",
+			record:      `{"a":1}`,
+			opts:        Options{Typed: true},
+			expectedErr: "decoding record for 1.1.1.0/24: unmarshaling MaxMind DB value: the value is missing",
+		},
+		{
+			network:     "2001:db8::/32",
+			record:      `{}`,
+			expectedErr: "inserting 2001:db8::/32: 2001:db8::/32 is not a valid network for an IPv4 tree",
+		},
+	} {
+		t.Run(test.expectedErr, func(t *testing.T) {
+			db := openTestDB(t)
+			_, err := db.Exec(
+				`INSERT INTO networks (network, record) VALUES (?, ?)`,
+				test.network,
+				test.record,
+			)
+			require.NoError(t, err)
+
+			_, err = Import(ctx, db, tree, test.opts)
+			assert.EqualError(t, err, test.expectedErr)
+		})
+	}
+}
+
+func mustNetwork(t *testing.T, s string) *net.IPNet {
+	_, network, err := net.ParseCIDR(s)
+	require.NoError(t, err)
+	return network
+}
+
+// The test driver below is an in-memory implementation of
+// database/sql/driver that understands only the statements issued by this
+// package. It ignores the table schema. The drivertest module runs the
+// package against a real SQLite driver.
+
+func openTestDB(t *testing.T) *sql.DB {
+	db := sql.OpenDB(&testDB{tables: map[string][][]driver.Value{}})
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// testDB is both the connector and the connection, so all of the
+// connections of a *sql.DB share its tables.
+type testDB struct {
+	mu     sync.Mutex
+	tables map[string][][]driver.Value
+}
+
+func (db *testDB) Connect(context.Context) (driver.Conn, error) { return db, nil }
+func (db *testDB) Driver() driver.Driver                        { return nil }
+
+func (db *testDB) Prepare(query string) (driver.Stmt, error) {
+	return &testStmt{db: db, query: query}, nil
+}
+
+func (*testDB) Close() error                 { return nil }
+func (db *testDB) Begin() (driver.Tx, error) { return db, nil }
+func (*testDB) Commit() error                { return nil }
+func (*testDB) Rollback() error              { return nil }
+
+type testStmt struct {
+	db    *testDB
+	query string
+}
+
+func (*testStmt) Close() error  { return nil }
+func (*testStmt) NumInput() int { return -1 }
+
+func (s *testStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	fields := strings.Fields(s.query)
+	switch fields[0] {
+	case "CREATE":
+	case "DELETE":
+		delete(s.db.tables, fields[2])
+	case "INSERT":
+		s.db.tables[fields[2]] = append(s.db.tables[fields[2]], args)
+	default:
+		return nil, errors.New("unsupported statement: " + s.query)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+// Query only supports the SELECT of readRows, which orders the rows by
+// network.
+func (s *testStmt) Query([]driver.Value) (driver.Rows, error) {
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+
+	rows := append([][]driver.Value(nil), s.db.tables[strings.Fields(s.query)[4]]...)
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i][0].(string) < rows[j][0].(string)
+	})
+	return &testRows{rows: rows}, nil
+}
+
+type testRows struct {
+	rows [][]driver.Value
+}
+
+func (*testRows) Columns() []string { return []string{"network", "record"} }
+func (*testRows) Close() error      { return nil }
+
+func (r *testRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
@@ -0,0 +1,225 @@
+package mmdbwriter
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/maxmind/mmdbwriter/inserter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+// stateMagic starts a state written by SaveState. The byte following it is
+// the version of the format.
+var stateMagic = []byte("MMDBWRITER-STATE")
+
+const stateVersion = 1
+
+// The entries of a state. Each value entry is followed by the length of
+// the encoded value and the value. Each record entry is followed by the
+// prefix length, the address in the tree's form, and the index of its
+// value, in the order the values were written.
+const (
+	stateEntryEnd byte = iota
+	stateEntryValue
+	stateEntryRecord
+)
+
+// SaveState writes the networks and values of the tree to w so that a long
+// running import may be checkpointed and resumed with LoadState, e.g.,
+// after a crash, without reading all of the sources again. Each distinct
+// value is written once.
+//
+// Only the records are saved. The options are not, as some of them are
+// functions, and neither is the state kept for the insert methods with
+// extra tracking, such as the priorities of InsertWithPriority, the
+// expiries of InsertWithExpiry, the sources of SetSource, or the networks
+// seen for DuplicatePolicy. A Templated value is saved as its composed
+// Map.
+func (t *Tree) SaveState(w io.Writer) error {
+	buf := bufio.NewWriter(w)
+
+	header := append([]byte(nil), stateMagic...)
+	header = append(header, stateVersion)
+	header = appendUvarint(header, uint64(t.treeDepth))
+	header = appendUvarint(header, uint64(t.ipv4Placement))
+	if _, err := buf.Write(header); err != nil {
+		return fmt.Errorf("writing state header: %w", err)
+	}
+
+	valueIndexes := map[valueRef]uint64{}
+	var scratch []byte
+	err := t.root.walk(make(net.IP, t.treeDepth/8), 0, func(ip net.IP, prefixLen int, r record) error {
+		if r.recordType != recordTypeData {
+			return nil
+		}
+
+		index, ok := valueIndexes[r.value]
+		if !ok {
+			encoded, err := mmdbtype.Encode(t.dataMap.load(r.value))
+			if err != nil {
+				return fmt.Errorf("encoding the value for %s: %w", t.walkNetwork(ip, prefixLen), err)
+			}
+			index = uint64(len(valueIndexes))
+			valueIndexes[r.value] = index
+
+			scratch = append(scratch[:0], stateEntryValue)
+			scratch = appendUvarint(scratch, uint64(len(encoded)))
+			scratch = append(scratch, encoded...)
+			if _, err := buf.Write(scratch); err != nil {
+				return fmt.Errorf("writing state value: %w", err)
+			}
+		}
+
+		scratch = append(scratch[:0], stateEntryRecord)
+		scratch = appendUvarint(scratch, uint64(prefixLen))
+		scratch = append(scratch, ip...)
+		scratch = appendUvarint(scratch, index)
+		if _, err := buf.Write(scratch); err != nil {
+			return fmt.Errorf("writing state record: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := buf.WriteByte(stateEntryEnd); err != nil {
+		return fmt.Errorf("writing state end: %w", err)
+	}
+	if err := buf.Flush(); err != nil {
+		return fmt.Errorf("flushing buffer to writer: %w", err)
+	}
+	return nil
+}
+
+// appendUvarint appends the varint encoding of v to b.
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	return append(b, buf[:n]...)
+}
+
+// walkNetwork returns the network of a record visited by the internal walk.
+func (t *Tree) walkNetwork(ip net.IP, prefixLen int) *net.IPNet {
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(prefixLen, t.treeDepth)}
+}
+
+// LoadState creates a new tree with opts and inserts the records saved with
+// SaveState from r. The options should be the ones of the tree that saved
+// the state, as the values were already processed by its inserter, e.g., the
+// rounding of FloatDecimalPlaces, and are inserted as they are. Neither the
+// Inserter nor the InsertMiddleware are called. The IP version and the
+// IPv4Placement of the options must match the saved tree.
+func LoadState(r io.Reader, opts Options) (*Tree, error) {
+	tree, err := New(opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := tree.loadState(bufio.NewReader(r)); err != nil {
+		tree.Close() //nolint:errcheck // the load error is more relevant
+		return nil, err
+	}
+	return tree, nil
+}
+
+func (t *Tree) loadState(r *bufio.Reader) error {
+	header := make([]byte, len(stateMagic)+1)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return fmt.Errorf("reading state header: %w", err)
+	}
+	if !bytes.Equal(header[:len(stateMagic)], stateMagic) {
+		return errors.New("the state does not start with the state marker")
+	}
+	if version := header[len(stateMagic)]; version != stateVersion {
+		return fmt.Errorf("unsupported state version: %d", version)
+	}
+
+	treeDepth, err := binary.ReadUvarint(r)
+	if err != nil {
+		return fmt.Errorf("reading state header: %w", err)
+	}
+	if treeDepth != uint64(t.treeDepth) {
+		return fmt.Errorf(
+			"the state is for a tree with %d bit addresses rather than %d",
+			treeDepth,
+			t.treeDepth,
+		)
+	}
+	placement, err := binary.ReadUvarint(r)
+	if err != nil {
+		return fmt.Errorf("reading state header: %w", err)
+	}
+	if placement != uint64(t.ipv4Placement) {
+		return fmt.Errorf(
+			"the state is for a tree with IPv4Placement %d rather than %d",
+			placement,
+			t.ipv4Placement,
+		)
+	}
+
+	var values []mmdbtype.DataType
+	var encoded bytes.Buffer
+	for {
+		entry, err := r.ReadByte()
+		if err != nil {
+			return fmt.Errorf("reading state entry: %w", err)
+		}
+		switch entry {
+		case stateEntryEnd:
+			return nil
+		case stateEntryValue:
+			size, err := binary.ReadUvarint(r)
+			if err != nil {
+				return fmt.Errorf("reading state value: %w", err)
+			}
+			// The value is copied rather than read into a buffer of the
+			// given size so that a corrupt size cannot cause a huge
+			// allocation.
+			encoded.Reset()
+			n, err := io.Copy(&encoded, io.LimitReader(r, int64(size)))
+			if err != nil {
+				return fmt.Errorf("reading state value: %w", err)
+			}
+			if uint64(n) != size {
+				return fmt.Errorf("reading state value: %w", io.ErrUnexpectedEOF)
+			}
+			value, err := mmdbtype.Decode(encoded.Bytes())
+			if err != nil {
+				return fmt.Errorf("decoding state value %d: %w", len(values), err)
+			}
+			values = append(values, value)
+		case stateEntryRecord:
+			prefixLen, err := binary.ReadUvarint(r)
+			if err != nil {
+				return fmt.Errorf("reading state record: %w", err)
+			}
+			if prefixLen > uint64(t.treeDepth) {
+				return fmt.Errorf("invalid prefix length in state record: %d", prefixLen)
+			}
+			ip := make(net.IP, t.treeDepth/8)
+			if _, err := io.ReadFull(r, ip); err != nil {
+				return fmt.Errorf("reading state record: %w", err)
+			}
+			index, err := binary.ReadUvarint(r)
+			if err != nil {
+				return fmt.Errorf("reading state record: %w", err)
+			}
+			if index >= uint64(len(values)) {
+				return fmt.Errorf("state record refers to unknown value %d", index)
+			}
+
+			network := t.walkNetwork(ip, int(prefixLen))
+			err = t.insert(network, recordTypeData, inserter.ReplaceWith(values[index]), nil)
+			if err != nil {
+				return fmt.Errorf("inserting %s from state: %w", network, err)
+			}
+		default:
+			return fmt.Errorf("unknown state entry: %d", entry)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package mmdbwriter
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveAndLoadState(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     Options
+		networks []string
+	}{
+		{
+			name:     "IPv6",
+			opts:     Options{BuildEpoch: 1},
+			networks: []string{"1.1.1.0/24", "1.1.2.0/25", "2003::/16", "2003:1::/32"},
+		},
+		{
+			name:     "IPv4",
+			opts:     Options{BuildEpoch: 1, IPVersion: 4},
+			networks: []string{"1.1.1.0/24", "1.1.2.0/25", "2.0.0.0/8"},
+		},
+		{
+			name:     "IPv4PlacementMapped",
+			opts:     Options{BuildEpoch: 1, IPv4Placement: IPv4PlacementMapped},
+			networks: []string{"1.1.1.0/24", "2003::/16"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tree, err := New(test.opts)
+			require.NoError(t, err)
+			for i, network := range test.networks {
+				require.NoError(t, tree.Insert(mustNetwork(t, network), mmdbtype.Map{
+					"n":      mmdbtype.Uint32(i % 2),
+					"shared": mmdbtype.String("value"),
+				}))
+			}
+
+			var state bytes.Buffer
+			require.NoError(t, tree.SaveState(&state))
+
+			loaded, err := LoadState(&state, test.opts)
+			require.NoError(t, err)
+			require.NoError(t, loaded.Check())
+			assert.Len(t, loaded.dataMap.data, 2, "each distinct value is stored once")
+
+			var want, got bytes.Buffer
+			_, err = tree.WriteTo(&want)
+			require.NoError(t, err)
+			_, err = loaded.WriteTo(&got)
+			require.NoError(t, err)
+			assert.Equal(t, want.Bytes(), got.Bytes())
+		})
+	}
+}
+
+func TestLoadStateErrors(t *testing.T) {
+	tree, err := New(Options{})
+	require.NoError(t, err)
+	require.NoError(t, tree.Insert(mustNetwork(t, "1.1.1.0/24"), mmdbtype.String("value")))
+	var buf bytes.Buffer
+	require.NoError(t, tree.SaveState(&buf))
+	state := buf.Bytes()
+
+	tests := []struct {
+		name  string
+		state []byte
+		opts  Options
+		err   string
+	}{
+		{
+			name:  "not a state",
+			state: []byte("not a state, but long enough"),
+			err:   "the state does not start with the state marker",
+		},
+		{
+			name:  "version",
+			state: append(append([]byte(nil), stateMagic...), 2),
+			err:   "unsupported state version: 2",
+		},
+		{
+			name:  "IP version",
+			state: state,
+			opts:  Options{IPVersion: 4},
+			err:   "the state is for a tree with 128 bit addresses rather than 32",
+		},
+		{
+			name:  "IPv4Placement",
+			state: state,
+			opts:  Options{IPv4Placement: IPv4PlacementMapped},
+			err: fmt.Sprintf(
+				"the state is for a tree with IPv4Placement %d rather than %d",
+				IPv4PlacementCompatible,
+				IPv4PlacementMapped,
+			),
+		},
+		{
+			name:  "truncated",
+			state: state[:len(state)-1],
+			err:   "reading state entry: EOF",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := LoadState(bytes.NewReader(test.state), test.opts)
+			assert.EqualError(t, err, test.err)
+		})
+	}
+}
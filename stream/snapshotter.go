@@ -0,0 +1,260 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/maxmind/mmdbwriter"
+)
+
+// DefaultQueueSize is the number of mutations that may be queued if
+// SnapshotterOptions.QueueSize is not set.
+const DefaultQueueSize = 1024
+
+// ErrQueueFull is returned by Snapshotter.TrySubmit if the queue is full.
+var ErrQueueFull = errors.New("the mutation queue is full")
+
+// Mutation modifies the tree owned by a Snapshotter. Event.Apply is a
+// Mutation.
+type Mutation func(tree *mmdbwriter.Tree) error
+
+// SnapshotterOptions configures a Snapshotter.
+type SnapshotterOptions struct {
+	// Path is the file the database is written to. Snapshots are written
+	// atomically as with WriteFile.
+	Path string
+
+	// Interval is the time between snapshots. A snapshot is only written if
+	// mutations were applied since the previous one. It defaults to
+	// DefaultInterval.
+	Interval time.Duration
+
+	// QueueSize is the number of mutations that may be queued before Submit
+	// blocks and TrySubmit fails. It defaults to DefaultQueueSize.
+	QueueSize int
+
+	// ErrorHandler, if set, is called when a mutation returns an error. If
+	// it returns nil, Run continues. Otherwise, Run returns the error. If
+	// ErrorHandler is not set, failed mutations are only counted in the
+	// Status.
+	ErrorHandler func(err error) error
+}
+
+// Status describes the state of a Snapshotter.
+type Status struct {
+	// LastSuccess is the time the last snapshot was written. It is zero if
+	// no snapshot has been written.
+	LastSuccess time.Time
+
+	// LastSize is the size in bytes of the last snapshot written.
+	LastSize int64
+
+	// LastError is the error of the last snapshot attempt if it failed. It
+	// is nil if the last attempt succeeded.
+	LastError error
+
+	// Snapshots is the number of snapshots written.
+	Snapshots int
+
+	// Applied is the number of mutations applied successfully.
+	Applied int
+
+	// Failed is the number of mutations that returned an error.
+	Failed int
+
+	// Queued is the number of mutations waiting to be applied.
+	Queued int
+}
+
+// Snapshotter owns a Tree, applies the mutations submitted to it, and writes
+// the tree to disk on an interval or when triggered. Mutations are queued,
+// and Submit blocks while the queue is full, so that producers are slowed
+// down rather than the process running out of memory if the tree cannot
+// keep up.
+//
+// Submit, TrySubmit, Trigger, and Status may be called from multiple
+// goroutines while Run is running.
+type Snapshotter struct {
+	tree     *mmdbwriter.Tree
+	opts     SnapshotterOptions
+	queue    chan Mutation
+	triggers chan chan error
+
+	// dirty is only accessed by Run.
+	dirty bool
+
+	mu     sync.Mutex
+	status Status
+}
+
+// NewSnapshotter returns a Snapshotter for the tree. The Snapshotter owns
+// the tree; it must only be accessed through mutations.
+func NewSnapshotter(tree *mmdbwriter.Tree, opts SnapshotterOptions) (*Snapshotter, error) {
+	if opts.Path == "" {
+		return nil, errors.New("path is required")
+	}
+	if opts.Interval < 0 {
+		return nil, fmt.Errorf("invalid interval: %s", opts.Interval)
+	}
+	if opts.Interval == 0 {
+		opts.Interval = DefaultInterval
+	}
+	if opts.QueueSize < 0 {
+		return nil, fmt.Errorf("invalid queue size: %d", opts.QueueSize)
+	}
+	if opts.QueueSize == 0 {
+		opts.QueueSize = DefaultQueueSize
+	}
+	return &Snapshotter{
+		tree:     tree,
+		opts:     opts,
+		queue:    make(chan Mutation, opts.QueueSize),
+		triggers: make(chan chan error),
+	}, nil
+}
+
+// Submit queues the mutation, blocking while the queue is full. It returns
+// the context's error if the context is done before the mutation is queued.
+func (s *Snapshotter) Submit(ctx context.Context, m Mutation) error {
+	select {
+	case s.queue <- m:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// TrySubmit queues the mutation if there is room in the queue and returns
+// ErrQueueFull otherwise.
+func (s *Snapshotter) TrySubmit(m Mutation) error {
+	select {
+	case s.queue <- m:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+// Trigger requests a snapshot and waits for it to be written. The snapshot
+// includes the mutations queued before Trigger was called. The snapshot is
+// written even if no mutations were applied since the previous one. Trigger
+// blocks until Run picks up the request or the context is done.
+func (s *Snapshotter) Trigger(ctx context.Context) error {
+	result := make(chan error, 1)
+	select {
+	case s.triggers <- result:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Status returns the current status.
+func (s *Snapshotter) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := s.status
+	status.Queued = len(s.queue)
+	return status
+}
+
+// Run applies the queued mutations and writes snapshots until the context
+// is done. A failed snapshot is recorded in the Status and retried at the
+// next interval rather than stopping Run.
+//
+// When the context is done, Run applies the mutations that are already
+// queued, writes a final snapshot if any mutations were applied since the
+// previous one, and returns the context's error, or the snapshot error if
+// the final snapshot fails.
+func (s *Snapshotter) Run(ctx context.Context) error {
+	ticker := time.NewTicker(s.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := s.drain(); err != nil {
+				return err
+			}
+			if s.dirty {
+				if err := s.snapshot(); err != nil {
+					return err
+				}
+			}
+			return ctx.Err()
+		case m := <-s.queue:
+			if err := s.apply(m); err != nil {
+				return err
+			}
+		case result := <-s.triggers:
+			if err := s.drain(); err != nil {
+				result <- err
+				return err
+			}
+			result <- s.snapshot()
+		case <-ticker.C:
+			if s.dirty {
+				// The error is recorded in the status.
+				s.snapshot() //nolint:errcheck // see above
+			}
+		}
+	}
+}
+
+// drain applies the mutations currently in the queue.
+func (s *Snapshotter) drain() error {
+	for {
+		select {
+		case m := <-s.queue:
+			if err := s.apply(m); err != nil {
+				return err
+			}
+		default:
+			return nil
+		}
+	}
+}
+
+func (s *Snapshotter) apply(m Mutation) error {
+	err := m(s.tree)
+
+	s.mu.Lock()
+	if err == nil {
+		s.status.Applied++
+	} else {
+		s.status.Failed++
+	}
+	s.mu.Unlock()
+
+	// A failed mutation may have partially modified the tree.
+	s.dirty = true
+
+	if err != nil && s.opts.ErrorHandler != nil {
+		return s.opts.ErrorHandler(err)
+	}
+	return nil
+}
+
+func (s *Snapshotter) snapshot() error {
+	size, err := WriteFile(s.opts.Path, s.tree)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status.LastError = err
+	if err != nil {
+		return err
+	}
+	s.dirty = false
+	s.status.LastSuccess = time.Now()
+	s.status.LastSize = size
+	s.status.Snapshots++
+	return nil
+}
@@ -0,0 +1,124 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/oschwald/maxminddb-golang"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.mmdb")
+	tree, err := mmdbwriter.New(mmdbwriter.Options{IPVersion: 4})
+	require.NoError(t, err)
+
+	s, err := NewSnapshotter(tree, SnapshotterOptions{Path: path, Interval: time.Hour})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- s.Run(ctx) }()
+
+	e := Event{Network: mustNetwork(t, "1.1.1.0/24"), Value: mmdbtype.String("a")}
+	require.NoError(t, s.Submit(ctx, e.Apply))
+	require.NoError(t, s.Submit(ctx, Event{}.Apply))
+	require.NoError(t, s.Trigger(ctx))
+
+	status := s.Status()
+	assert.Equal(t, 1, status.Snapshots)
+	assert.Equal(t, 1, status.Applied)
+	assert.Equal(t, 1, status.Failed)
+	assert.NoError(t, status.LastError)
+	assert.False(t, status.LastSuccess.IsZero())
+	assertLookup(t, path, "1.1.1.1", "a")
+
+	// The final snapshot includes the mutations queued before shutdown.
+	e = Event{Network: mustNetwork(t, "1.1.1.0/24"), Value: mmdbtype.String("b")}
+	require.NoError(t, s.Submit(ctx, e.Apply))
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+
+	status = s.Status()
+	assert.Equal(t, 2, status.Snapshots)
+	assert.Equal(t, 2, status.Applied)
+	assert.Zero(t, status.Queued)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, status.LastSize, info.Size())
+	assertLookup(t, path, "1.1.1.1", "b")
+}
+
+func TestSnapshotterBackpressure(t *testing.T) {
+	tree, err := mmdbwriter.New(mmdbwriter.Options{IPVersion: 4})
+	require.NoError(t, err)
+
+	s, err := NewSnapshotter(tree, SnapshotterOptions{
+		Path:      filepath.Join(t.TempDir(), "test.mmdb"),
+		QueueSize: 1,
+	})
+	require.NoError(t, err)
+
+	noop := func(*mmdbwriter.Tree) error { return nil }
+	require.NoError(t, s.TrySubmit(noop))
+	assert.ErrorIs(t, s.TrySubmit(noop), ErrQueueFull)
+	assert.Equal(t, 1, s.Status().Queued)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, s.Submit(ctx, noop), context.DeadlineExceeded)
+	assert.ErrorIs(t, s.Trigger(ctx), context.DeadlineExceeded)
+}
+
+func TestSnapshotterErrors(t *testing.T) {
+	tree, err := mmdbwriter.New(mmdbwriter.Options{IPVersion: 4})
+	require.NoError(t, err)
+
+	_, err = NewSnapshotter(tree, SnapshotterOptions{})
+	assert.EqualError(t, err, "path is required")
+
+	_, err = NewSnapshotter(tree, SnapshotterOptions{Path: "x", Interval: -time.Second})
+	assert.EqualError(t, err, "invalid interval: -1s")
+
+	_, err = NewSnapshotter(tree, SnapshotterOptions{Path: "x", QueueSize: -1})
+	assert.EqualError(t, err, "invalid queue size: -1")
+
+	// A failed snapshot is reported but does not stop Run.
+	s, err := NewSnapshotter(tree, SnapshotterOptions{
+		Path:         filepath.Join(t.TempDir(), "missing", "test.mmdb"),
+		ErrorHandler: func(err error) error { return err },
+	})
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	done := make(chan error)
+	go func() { done <- s.Run(ctx) }()
+
+	require.Error(t, s.Trigger(ctx))
+	status := s.Status()
+	assert.Error(t, status.LastError)
+	assert.True(t, status.LastSuccess.IsZero())
+
+	// A failed mutation stops Run if the ErrorHandler returns an error.
+	errStop := errors.New("stop")
+	require.NoError(t, s.Submit(ctx, func(*mmdbwriter.Tree) error { return errStop }))
+	assert.ErrorIs(t, <-done, errStop)
+}
+
+func assertLookup(t *testing.T, path, ip, expected string) {
+	reader, err := maxminddb.Open(path)
+	require.NoError(t, err)
+	defer reader.Close()
+
+	var value string
+	require.NoError(t, reader.Lookup(net.ParseIP(ip), &value))
+	assert.Equal(t, expected, value)
+}
@@ -0,0 +1,255 @@
+// Package stream applies a stream of network upserts and deletes to a live
+// mmdbwriter.Tree and periodically writes the tree to disk. This supports
+// databases that are updated continuously, e.g., from a Kafka topic, rather
+// than rebuilt from scratch.
+//
+// The events are read from a channel so that the package does not depend on
+// a particular message queue. An adapter reads the messages from the queue,
+// converts them to Events, e.g., with ParseEvent, and sends them on the
+// channel. See examples/kafka-consumer for an example.
+//
+// Consumer applies the events from a single channel. Snapshotter accepts
+// arbitrary mutations of the tree from multiple goroutines through a bounded
+// queue and also writes snapshots on demand.
+package stream
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/inserter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+// DefaultInterval is the time between snapshots if Options.Interval is not
+// set.
+const DefaultInterval = time.Minute
+
+// Event is an upsert or delete of a network.
+type Event struct {
+	// Network is the network to update.
+	Network *net.IPNet
+
+	// Value is the record for the network. It is inserted with Tree.Insert,
+	// so the tree's inserter applies. It is ignored for deletes.
+	Value mmdbtype.DataType
+
+	// Delete causes the data for the network to be removed.
+	Delete bool
+}
+
+// jsonEvent is the JSON representation of an Event used by ParseEvent.
+type jsonEvent struct {
+	Network string          `json:"network"`
+	Record  json.RawMessage `json:"record"`
+	Delete  bool            `json:"delete"`
+}
+
+// ParseEvent parses an event encoded as a JSON object, e.g.,
+//
+//	{"network": "1.1.1.0/24", "record": {"score": 10}}
+//	{"network": "1.1.1.0/24", "delete": true}
+//
+// The record is converted with mmdbtype.FromAny, with integers preserved
+// as described there.
+func ParseEvent(data []byte) (Event, error) {
+	var je jsonEvent
+	if err := json.Unmarshal(data, &je); err != nil {
+		return Event{}, fmt.Errorf("parsing event: %w", err)
+	}
+	if je.Network == "" {
+		return Event{}, errors.New("parsing event: the network is missing")
+	}
+	_, network, err := net.ParseCIDR(je.Network)
+	if err != nil {
+		return Event{}, fmt.Errorf("parsing event: %w", err)
+	}
+	e := Event{Network: network, Delete: je.Delete}
+	if je.Delete {
+		return e, nil
+	}
+	if len(je.Record) == 0 {
+		return Event{}, fmt.Errorf("parsing event for %s: the record is missing", je.Network)
+	}
+
+	d := json.NewDecoder(bytes.NewReader(je.Record))
+	d.UseNumber()
+	var v any
+	if err := d.Decode(&v); err != nil {
+		return Event{}, fmt.Errorf("parsing event for %s: %w", je.Network, err)
+	}
+	e.Value, err = mmdbtype.FromAny(v)
+	if err != nil {
+		return Event{}, fmt.Errorf("parsing event for %s: %w", je.Network, err)
+	}
+	return e, nil
+}
+
+// Apply applies the event to the tree. It may be submitted to a Snapshotter
+// as a Mutation.
+func (e Event) Apply(tree *mmdbwriter.Tree) error {
+	if e.Network == nil {
+		return errors.New("the event has no network")
+	}
+	var err error
+	if e.Delete {
+		err = tree.InsertFunc(e.Network, inserter.Remove)
+	} else {
+		err = tree.Insert(e.Network, e.Value)
+	}
+	if err != nil {
+		return fmt.Errorf("applying event for %s: %w", e.Network, err)
+	}
+	return nil
+}
+
+// Options configures a Consumer.
+type Options struct {
+	// Path is the file the database is written to. Each snapshot is
+	// written to a temporary file in the same directory, which is then
+	// renamed to Path, so that readers never see a partial database.
+	Path string
+
+	// Interval is the time between snapshots. A snapshot is only written if
+	// events were applied since the previous one. It defaults to
+	// DefaultInterval.
+	Interval time.Duration
+
+	// ErrorHandler, if set, is called when an event cannot be applied to the
+	// tree. If it returns nil, the event is skipped and Run continues.
+	// Otherwise, Run returns the error. If ErrorHandler is not set, Run
+	// returns the error.
+	ErrorHandler func(e Event, err error) error
+
+	// OnSnapshot, if set, is called after each snapshot is written with the
+	// number of bytes written.
+	OnSnapshot func(size int64)
+}
+
+// Consumer applies events to a Tree and writes snapshots of it.
+type Consumer struct {
+	tree  *mmdbwriter.Tree
+	opts  Options
+	dirty bool
+}
+
+// NewConsumer returns a Consumer that applies events to the tree. The
+// Consumer owns the tree while Run is running; it must not be accessed
+// elsewhere.
+func NewConsumer(tree *mmdbwriter.Tree, opts Options) (*Consumer, error) {
+	if opts.Path == "" {
+		return nil, errors.New("path is required")
+	}
+	if opts.Interval < 0 {
+		return nil, fmt.Errorf("invalid interval: %s", opts.Interval)
+	}
+	if opts.Interval == 0 {
+		opts.Interval = DefaultInterval
+	}
+	return &Consumer{tree: tree, opts: opts}, nil
+}
+
+// Run applies the events from the channel to the tree, writing a snapshot
+// every Options.Interval. When the channel is closed, Run writes a final
+// snapshot, if there are unwritten events, and returns nil. If the context
+// is canceled, Run returns the context's error without writing a snapshot.
+func (c *Consumer) Run(ctx context.Context, events <-chan Event) error {
+	ticker := time.NewTicker(c.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case e, ok := <-events:
+			if !ok {
+				return c.snapshot()
+			}
+			if err := c.Apply(e); err != nil {
+				if c.opts.ErrorHandler == nil {
+					return err
+				}
+				if err := c.opts.ErrorHandler(e, err); err != nil {
+					return err
+				}
+			}
+		case <-ticker.C:
+			if err := c.snapshot(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Apply applies a single event to the tree. It is not safe to call while
+// Run is running.
+func (c *Consumer) Apply(e Event) error {
+	if err := e.Apply(c.tree); err != nil {
+		return err
+	}
+	c.dirty = true
+	return nil
+}
+
+func (c *Consumer) snapshot() error {
+	if !c.dirty {
+		return nil
+	}
+	return c.Snapshot()
+}
+
+// Snapshot writes the tree to Options.Path, regardless of whether any events
+// were applied since the previous snapshot. It is not safe to call while
+// Run is running.
+func (c *Consumer) Snapshot() error {
+	size, err := WriteFile(c.opts.Path, c.tree)
+	if err != nil {
+		return err
+	}
+	c.dirty = false
+	if c.opts.OnSnapshot != nil {
+		c.opts.OnSnapshot(size)
+	}
+	return nil
+}
+
+// WriteFile writes the tree to path atomically. The database is written to
+// a temporary file in the same directory, which is synced and then renamed
+// to path. It returns the number of bytes written.
+func WriteFile(path string, tree *mmdbwriter.Tree) (int64, error) {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return 0, fmt.Errorf("creating temporary file: %w", err)
+	}
+	tmp := f.Name()
+
+	// os.CreateTemp creates the file readable only by the owner, but the
+	// database is typically read by other processes.
+	err = f.Chmod(0o644)
+	var size int64
+	if err == nil {
+		size, err = tree.WriteTo(f)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, path)
+	}
+	if err != nil {
+		os.Remove(tmp) //nolint:errcheck // the write error is more relevant
+		return 0, fmt.Errorf("writing %s: %w", path, err)
+	}
+	return size, nil
+}
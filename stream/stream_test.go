@@ -0,0 +1,216 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/maxmind/mmdbwriter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/oschwald/maxminddb-golang"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseEvent(t *testing.T) {
+	tests := []struct {
+		input       string
+		expected    Event
+		expectedErr string
+	}{
+		{
+			input: `{"network":"1.1.1.0/24","record":{"score":10,"tags":["a"]}}`,
+			expected: Event{
+				Network: mustNetwork(t, "1.1.1.0/24"),
+				Value: mmdbtype.Map{
+					"score": mmdbtype.Int32(10),
+					"tags":  mmdbtype.Slice{mmdbtype.String("a")},
+				},
+			},
+		},
+		{
+			input:    `{"network":"2001:db8::/32","delete":true}`,
+			expected: Event{Network: mustNetwork(t, "2001:db8::/32"), Delete: true},
+		},
+		{
+			input:       `{"record":{}}`,
+			expectedErr: "parsing event: the network is missing",
+		},
+		{
+			input:       `{"network":"1.1.1.1","record":{}}`,
+			expectedErr: "parsing event: invalid CIDR address: 1.1.1.1",
+		},
+		{
+			input:       `{"network":"1.1.1.0/24"}`,
+			expectedErr: "parsing event for 1.1.1.0/24: the record is missing",
+		},
+		{
+			input:       `{"network":"1.1.1.0/24","record":{"a":[null]}}`,
+			expectedErr: `parsing event for 1.1.1.0/24: map key "a": slice index 0: nil values are not supported`,
+		},
+		{
+			input:       `[]`,
+			expectedErr: "parsing event: json: cannot unmarshal array into Go value of type stream.jsonEvent",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			e, err := ParseEvent([]byte(test.input))
+			if test.expectedErr != "" {
+				assert.EqualError(t, err, test.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, e)
+		})
+	}
+}
+
+func TestEventApplyDeleteDuplicateError(t *testing.T) {
+	tree, err := mmdbwriter.New(mmdbwriter.Options{DuplicatePolicy: mmdbwriter.DuplicateError})
+	require.NoError(t, err)
+
+	_, network, err := net.ParseCIDR("1.1.1.0/24")
+	require.NoError(t, err)
+	require.NoError(t, Event{Network: network, Value: mmdbtype.String("a")}.Apply(tree))
+	require.NoError(t, Event{Network: network, Delete: true}.Apply(tree))
+
+	_, value := tree.Get(net.ParseIP("1.1.1.1"))
+	assert.Nil(t, value)
+	assert.Equal(t, 0, tree.DuplicateCount())
+}
+
+func TestConsumerRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.mmdb")
+	tree, err := mmdbwriter.New(mmdbwriter.Options{IPVersion: 4, RecordSize: 24})
+	require.NoError(t, err)
+
+	var (
+		sizes   []int64
+		skipped []error
+	)
+	c, err := NewConsumer(tree, Options{
+		Path:     path,
+		Interval: time.Hour,
+		ErrorHandler: func(_ Event, err error) error {
+			skipped = append(skipped, err)
+			return nil
+		},
+		OnSnapshot: func(size int64) { sizes = append(sizes, size) },
+	})
+	require.NoError(t, err)
+
+	events := make(chan Event, 4)
+	events <- Event{Network: mustNetwork(t, "1.1.1.0/24"), Value: mmdbtype.String("a")}
+	events <- Event{Network: mustNetwork(t, "2.2.2.0/24"), Value: mmdbtype.String("b")}
+	events <- Event{Network: mustNetwork(t, "2.2.2.0/24"), Delete: true}
+	events <- Event{Network: mustNetwork(t, "2001::/16"), Value: mmdbtype.String("c")}
+	close(events)
+
+	require.NoError(t, c.Run(context.Background(), events))
+	require.Len(t, sizes, 1)
+	require.Len(t, skipped, 1)
+	assert.EqualError(
+		t,
+		skipped[0],
+		"applying event for 2001::/16: 2001::/16 is not a valid network for an IPv4 tree",
+	)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, sizes[0], info.Size())
+	assert.Equal(t, os.FileMode(0o644), info.Mode().Perm())
+
+	reader, err := maxminddb.Open(path)
+	require.NoError(t, err)
+	defer reader.Close()
+
+	var value string
+	require.NoError(t, reader.Lookup(net.ParseIP("1.1.1.1"), &value))
+	assert.Equal(t, "a", value)
+
+	_, ok, err := reader.LookupNetwork(net.ParseIP("2.2.2.2"), &value)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "temporary files are removed")
+}
+
+func TestConsumerInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.mmdb")
+	tree, err := mmdbwriter.New(mmdbwriter.Options{IPVersion: 4})
+	require.NoError(t, err)
+
+	written := make(chan struct{}, 1)
+	c, err := NewConsumer(tree, Options{
+		Path:     path,
+		Interval: time.Millisecond,
+		OnSnapshot: func(int64) {
+			select {
+			case written <- struct{}{}:
+			default:
+			}
+		},
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events := make(chan Event)
+	done := make(chan error)
+	go func() { done <- c.Run(ctx, events) }()
+
+	events <- Event{Network: mustNetwork(t, "1.1.1.0/24"), Value: mmdbtype.String("a")}
+	select {
+	case <-written:
+	case <-time.After(10 * time.Second):
+		t.Fatal("no snapshot was written")
+	}
+	_, err = os.Stat(path)
+	require.NoError(t, err)
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
+
+func TestConsumerErrors(t *testing.T) {
+	tree, err := mmdbwriter.New(mmdbwriter.Options{IPVersion: 4})
+	require.NoError(t, err)
+
+	_, err = NewConsumer(tree, Options{})
+	assert.EqualError(t, err, "path is required")
+
+	_, err = NewConsumer(tree, Options{Path: "x", Interval: -time.Second})
+	assert.EqualError(t, err, "invalid interval: -1s")
+
+	errStop := errors.New("stop")
+	c, err := NewConsumer(tree, Options{
+		Path:         filepath.Join(t.TempDir(), "test.mmdb"),
+		ErrorHandler: func(Event, error) error { return errStop },
+	})
+	require.NoError(t, err)
+
+	events := make(chan Event, 1)
+	events <- Event{}
+	assert.ErrorIs(t, c.Run(context.Background(), events), errStop)
+
+	c.opts.ErrorHandler = nil
+	events <- Event{}
+	assert.EqualError(t, c.Run(context.Background(), events), "the event has no network")
+
+	c.opts.Path = filepath.Join(t.TempDir(), "missing", "test.mmdb")
+	_, err = WriteFile(c.opts.Path, tree)
+	assert.ErrorContains(t, err, "creating temporary file: ")
+}
+
+func mustNetwork(t *testing.T, s string) *net.IPNet {
+	_, network, err := net.ParseCIDR(s)
+	require.NoError(t, err)
+	return network
+}
@@ -4,9 +4,14 @@ package mmdbwriter
 
 import (
 	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
+	"math"
 	"net"
 	"time"
 
@@ -16,6 +21,11 @@ import (
 	"go4.org/netipx"
 )
 
+// maxMetadataSize is the maximum size of the metadata, including the start
+// marker. Readers only search the last 128 KiB of the database for the
+// marker.
+const maxMetadataSize = 128 * 1024
+
 var (
 	metadataStartMarker  = []byte("\xAB\xCD\xEFMaxMind.com")
 	dataSectionSeparator = []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
@@ -24,16 +34,25 @@ var (
 // Options holds configuration parameters for the writer.
 type Options struct {
 	// BuildEpoch is the database build timestamp as a Unix epoch value. It
-	// defaults to the epoch of when New was called.
+	// defaults to the epoch of when New was called. It must not be negative.
+	// BuildTime is preferred.
 	BuildEpoch int64
 
+	// BuildTime is the database build time. It is stored in the database as
+	// a Unix epoch value, so any sub-second precision is discarded. It must
+	// not be before the Unix epoch. If both BuildTime and BuildEpoch are
+	// set, they must refer to the same second.
+	BuildTime time.Time
+
 	// DatabaseType is a string that indicates the structure of each data record
 	// associated with an IP address. The actual definition of these structures
 	// is left up to the database creator.
 	DatabaseType string
 
 	// Description is a map where the key is a language code and the value is
-	// the description of the database in that language.
+	// the description of the database in that language. The language codes
+	// must be BCP 47 language tags. If Languages is not empty, each language
+	// code must also be in Languages.
 	Description map[string]string
 
 	// DisableIPv4Aliasing will disable the IPv4 aliasing in IPv6 trees. This
@@ -41,6 +60,36 @@ type Options struct {
 	// ::ffff:0:0/96.
 	DisableIPv4Aliasing bool
 
+	// ReserveIPv4MappedNetwork inserts a reserved record for ::ffff:0:0/96
+	// in an IPv6 tree with DisableIPv4Aliasing set. Lookups of IPv4-mapped
+	// addresses then deterministically find no data rather than the data of
+	// a larger network that contains the IPv4-mapped network, e.g., ::/0.
+	// As with other reserved networks, inserting into the network results
+	// in an error and inserting a network that contains it leaves it
+	// empty. It may only be set when DisableIPv4Aliasing is set.
+	ReserveIPv4MappedNetwork bool
+
+	// IPv6Only creates an IPv6 tree for a database that intentionally
+	// contains no IPv4 data. Rather than being mapped into ::/96, IPv4
+	// networks, including IPv4-mapped IPv6 networks, are rejected by the
+	// inserts with an error wrapping ErrIPv4InIPv6OnlyTree, and IPv4
+	// addresses are not found by Get and GetRecord and are rejected by
+	// Lookup. The IPv4 aliases are not inserted, and ::/96 and
+	// ::ffff:0:0/96 are reserved, so that readers looking up IPv4 addresses
+	// never find data. It requires IPVersion to be 6.
+	IPv6Only bool
+
+	// IPv4Placement is the network where the IPv4 addresses are stored in an
+	// IPv6 tree. The default, IPv4PlacementCompatible, stores them in ::/96,
+	// where the MaxMind DB readers look them up. IPv4PlacementMapped stores
+	// them in ::ffff:0:0/96 for ecosystems that expect IPv4 data there.
+	// Inserted IPv4 networks, Get, and the other methods taking IPv4
+	// addresses use the chosen network, and the networks within it are
+	// reported as IPv4 networks, e.g., by Walk. IPv4PlacementMapped
+	// requires an IPv6 tree and cannot be used with IPv6Only or
+	// ReserveIPv4MappedNetwork.
+	IPv4Placement IPv4Placement
+
 	// IncludeReservedNetworks will allow reserved networks to be added to the
 	// database.
 	//
@@ -53,88 +102,587 @@ type Options struct {
 
 	// IPVersion indicates whether an IPv4 or IPv6 database should be built. An
 	// IPv6 database supports both IPv4 and IPv6 lookups. The default value is
-	// "6" for IPv6.
+	// "6" for IPv6. An IPv4 database accepts IPv4-mapped IPv6 networks, e.g.,
+	// ::ffff:1.1.1.0/120, and inserts them as the IPv4 networks they map to.
 	IPVersion int
 
 	// Languages is a slice of strings, each of which is a locale code. A given
 	// record may contain data items that have been localized to some or all of
 	// these locales. Records should not contain localized data for locales not
-	// included in this slice.
+	// included in this slice. The locale codes must be BCP 47 language tags.
 	Languages []string
 
+	// PopulateLanguagesFromDescription adds any language in Description that
+	// is not already in Languages to Languages.
+	PopulateLanguagesFromDescription bool
+
+	// FilterNamesByLanguages removes the languages that are not in
+	// Languages from the "names" maps of inserted records, e.g.,
+	// {"city": {"names": {"en": "Munich", "de": "München"}}} becomes
+	// {"city": {"names": {"en": "Munich"}}} with Languages set to ["en"].
+	// This is how localized variants of the GeoIP2 databases are produced
+	// and may reduce the size of the database substantially. A names map
+	// without any of the languages is removed. It requires Languages to be
+	// set.
+	FilterNamesByLanguages bool
+
+	// LanguageFallbacks maps a language to the languages, in order of
+	// preference, whose names are used for it when a "names" map of an
+	// inserted record does not have it, e.g., {"de": ["en"]} adds the
+	// English name as the German name where there is no German name. This
+	// way, consumers always find a name in the languages they request. The
+	// languages must be BCP 47 language tags, and if Languages is set, the
+	// languages with fallbacks must be in it. The fallbacks are applied
+	// before FilterNamesByLanguages, so the fallback languages need not be
+	// in Languages.
+	LanguageFallbacks map[string][]string
+
 	// RecordSize indicates the number of bits in a record in the search tree.
 	// The supported values are 24, 28, and 32. A smaller size will result in a
 	// smaller database, but it will limit the maximum size of the database.
-	// The default is 28.
+	// The default is 28. See AutoRecordSize.
 	RecordSize int
 
+	// AutoRecordSize causes the tree to increase its record size to the
+	// smallest supported size that the database fits in when it does not
+	// fit in RecordSize, rather than returning an error when the tree is
+	// finalized. A warning is logged to Logger when the record size is
+	// increased. The data section offsets are checked as with
+	// ValidateRecordSize.
+	AutoRecordSize bool
+
 	// DisableMetadataPointers prevents the use of pointers in the metadata
 	// section of the database. This option exists to avoid bugs in reader
 	// implementations that do not correctly handle metadata pointers. Its
 	// use should primarily be limited to existing database types.
 	DisableMetadataPointers bool
 
+	// BinaryFormatMajorVersion and BinaryFormatMinorVersion override the
+	// binary_format_major_version and binary_format_minor_version written
+	// to the metadata. The defaults are 2 and 0, the version of the format
+	// that is written. Readers reject databases with a major version other
+	// than 2, so these should only be set for readers that expect other
+	// values. They do not change how the database is encoded; use
+	// DisabledFormatFeatures for that.
+	BinaryFormatMajorVersion int
+	BinaryFormatMinorVersion int
+
+	// DisabledFormatFeatures are the features of the format, e.g.,
+	// FormatFeaturePointers, not to use so that the database may be read
+	// by readers that do not support them.
+	DisabledFormatFeatures []FormatFeature
+
 	// Inserter is the insert function used when calling `Insert`. It defaults
 	// to `inserter.ReplaceWith`, which replaces any conflicting old value
 	// entirely with the new.
 	Inserter inserter.FuncGenerator
+
+	// FloatDecimalPlaces, when greater than zero, causes all Float32 and
+	// Float64 values in inserted records to be rounded to the given number
+	// of decimal places before they are stored. This is primarily useful
+	// for coordinates, where rounding to a few decimal places has no
+	// meaningful effect on accuracy but allows more records to be
+	// deduplicated, reducing the size of the database. It must not be
+	// greater than 15, as a float64 has no more significant decimal digits.
+	FloatDecimalPlaces int
+
+	// FloatEpsilon, when greater than zero, causes all Float32 and Float64
+	// values in the inserted data to be replaced by the closest value
+	// within FloatEpsilon that was inserted before, if there is one. This
+	// allows records that only differ by floating point noise from their
+	// sources to be deduplicated and their networks merged. Unlike
+	// FloatDecimalPlaces, values are never changed by more than
+	// FloatEpsilon, but the result depends on the order of the inserts.
+	// The tree keeps every distinct value that it did not replace. If
+	// FloatDecimalPlaces is also set, the values are rounded first.
+	FloatEpsilon float64
+
+	// EnumFields is a list of dot-separated paths to string fields in the
+	// records, e.g., "location.time_zone". When writing the database, the
+	// string values of these fields are replaced by small integers. A
+	// lookup table mapping these integers back to the strings is written
+	// once at the start of the data section. See EnumTableKey for the
+	// format and ReadEnumTable to read the table from the database.
+	//
+	// This is intended for fields with many repeated values and reduces the
+	// size of the database. However, readers that are not aware of the
+	// lookup table will see the integers rather than the strings.
+	EnumFields []string
+
+	// DictionaryFields is a list of dot-separated paths to string fields in
+	// the records, e.g., "organization", with long values that are repeated
+	// in many records. When writing the database, the distinct string values
+	// of these fields are written once as a dictionary at the start of the
+	// data section, and the fields hold the index of their value in the
+	// dictionary. The most common values have the smallest indexes. See
+	// DictionaryKey for the format and ReadDictionary to read the
+	// dictionary.
+	//
+	// As the data section already stores each distinct string once and
+	// refers to it with a pointer, the dictionary mainly reduces the size of
+	// each reference, which may matter for a large database where pointers
+	// take 4 or 5 bytes. Unlike EnumFields, the table is shared by the
+	// fields and is not limited to 65,536 values. Readers that are not
+	// aware of the dictionary will see the integers rather than the strings.
+	// A field may not be in both EnumFields and DictionaryFields.
+	DictionaryFields []string
+
+	// MapKeyOrder is a list of map keys to write before the other keys of
+	// the maps in the records, in the given order, e.g., to put the fields
+	// that are most likely to be accessed first. It applies to maps at all
+	// levels of the records. The other keys follow in sorted order, which is
+	// the order of all keys if MapKeyOrder is not set. In either case, equal
+	// maps are always written as the same bytes, so they are deduplicated in
+	// the data section and the database is reproducible. The metadata is
+	// always written with sorted keys.
+	MapKeyOrder []string
+
+	// NetworkFields is a list of fields derived from the network of each
+	// record, e.g., NetworkFieldNetwork, to add to the records when the
+	// tree is written. Some consumers expect these fields, and adding them
+	// when writing avoids storing them with each insert, which would also
+	// prevent networks with otherwise equal records from being merged.
+	// The fields are only added to records that are maps, and they replace
+	// existing fields with the same keys. As the records of different
+	// networks then differ, the data section is larger, especially with
+	// NetworkFieldNetwork, where no two records are the same.
+	NetworkFields []NetworkField
+
+	// IndexFields is a list of dot-separated paths to fields in the
+	// records, e.g., "country.iso_code", to index so that the networks
+	// with a value for one of the fields may be listed with
+	// Tree.NetworksWhere without walking the tree for each query.
+	IndexFields []string
+
+	// TrackSources enables tracking of the source of each insert. The
+	// source label is set with Tree.SetSource and may be retrieved for an
+	// IP address with Tree.Source. This is intended for debugging builds
+	// that combine data from multiple sources. The sources are only kept in
+	// memory and are not written to the database.
+	TrackSources bool
+
+	// ValidateRecordSize causes the tree to always verify that all record
+	// values, including the data section offsets, fit in RecordSize bits
+	// when it is finalized, before anything is written. This requires
+	// encoding the data section an additional time. Without this option,
+	// the offsets are still checked when finalizing unless the total size
+	// of the distinct values rules out their being too large. With
+	// EnumFields, DictionaryFields, or NetworkFields, the data section may
+	// be larger than the values, so the offsets are always checked.
+	ValidateRecordSize bool
+
+	// CloneValues causes the tree to store a deep copy of each distinct
+	// value inserted rather than the value itself. This allows the caller
+	// to modify a value after inserting it. Without this option, values
+	// must not be modified after they are inserted as they are shared
+	// between all of the records that contain them.
+	CloneValues bool
+
+	// EmbedChecksum causes the SHA-256 digest of the search tree and data
+	// section to be written to the metadata under ChecksumKey. Use
+	// VerifyChecksum to verify a database against the embedded digest.
+	EmbedChecksum bool
+
+	// DataSectionAlignment, if set, aligns the start of the data section to
+	// a multiple of this many bytes from the start of the database. It must
+	// be a power of two. This allows readers that memory map the database
+	// to access the data section at an aligned address, e.g., a page
+	// boundary.
+	//
+	// A database is laid out as follows:
+	//
+	//	search tree       node_count * record_size / 4 bytes
+	//	separator         16 zero bytes
+	//	data section      variable length
+	//	metadata marker   "\xAB\xCD\xEFMaxMind.com"
+	//	metadata          variable length
+	//
+	// As readers expect the data section to immediately follow the
+	// separator, the alignment is achieved by appending unused nodes with
+	// empty records to the search tree. These are included in node_count.
+	// Up to DataSectionAlignment additional nodes may be written. Use
+	// Tree.DataSectionOffset to get the resulting offset.
+	DataSectionAlignment int
+
+	// MaxNodes, if greater than zero, is the maximum number of nodes allowed
+	// in the search tree. An insert that causes the tree to exceed this
+	// returns an error wrapping ErrLimitExceeded. This is intended to
+	// protect services building databases from untrusted input from
+	// running out of memory. As the insert that exceeded the limit has
+	// already been applied, the tree should be discarded after such an
+	// error.
+	MaxNodes int
+
+	// MaxDataSize, if greater than zero, is the maximum total size in bytes
+	// of the distinct values stored in the tree, measured by their encoded
+	// size without pointers. This is an upper bound on the size of the data
+	// section. It is enforced in the same way as MaxNodes.
+	MaxDataSize int
+
+	// MaxRecordSize, if greater than zero, is the maximum size in bytes of
+	// a single record, measured by its encoded size without pointers. An
+	// insert whose inserter function returns a larger value returns an
+	// error wrapping ErrLimitExceeded that includes the network, so that a
+	// malformed source, e.g., one with a huge names map, is found rather
+	// than silently bloating the database. Checking the size requires
+	// encoding each value returned by the inserter function.
+	MaxRecordSize int
+
+	// NodeStorage selects where the nodes of the search tree are stored
+	// while building. The default, NodeStorageMemory, stores them in memory.
+	// NodeStorageFile stores them in a memory-mapped temporary file, which
+	// allows building trees with more nodes than fit in memory at the cost
+	// of speed. Call Tree.Close to release the file when the tree is no
+	// longer needed.
+	NodeStorage NodeStorage
+
+	// NodeStorageDir is the directory in which the file for
+	// NodeStorageFile is created. If empty, the default directory for
+	// temporary files is used.
+	NodeStorageDir string
+
+	// ValueStorage selects where the distinct values of the records are
+	// stored while building. The default, ValueStorageMemory, stores them
+	// in memory. ValueStorageFile stores them encoded in a memory-mapped
+	// temporary file and decodes them when they are needed, which reduces
+	// the memory used by trees with a lot of data at the cost of speed. It
+	// cannot be used with Session. Call Tree.Close to release the file
+	// when the tree is no longer needed.
+	ValueStorage ValueStorage
+
+	// ValueStorageDir is the directory in which the file for
+	// ValueStorageFile is created. If empty, the default directory for
+	// temporary files is used.
+	ValueStorageDir string
+
+	// InsertMiddleware is a list of middleware that wraps the inserter
+	// function of each data insert, including those by Insert, InsertFunc,
+	// InsertRange, and InsertReader. The first middleware is the outermost.
+	// It is not applied by Extract, which copies the existing data.
+	InsertMiddleware []InsertMiddleware
+
+	// OverwritePolicy determines how inserting a network affects the
+	// existing data for more specific networks within it. The default is
+	// OverwriteMerge, which calls the inserter function for each of them.
+	OverwritePolicy OverwritePolicy
+
+	// DataSectionSpool, if set, is called when writing the tree to get the
+	// io.ReadWriteSeeker that the data section is spooled to until the
+	// search tree has been written. By default, the data section is
+	// accumulated in memory, which may double the peak memory use when
+	// writing a large database. If the returned value implements io.Closer,
+	// it is closed when the write completes. TempFileSpool returns a
+	// function that spools to a temporary file.
+	DataSectionSpool func() (io.ReadWriteSeeker, error)
+
+	// MetadataHook, if set, is called with the metadata just before it is
+	// written and returns the metadata to write. It may add, change, or
+	// remove keys, e.g., to add the revision of the build. The keys
+	// required by the MaxMind DB format must be kept, and node_count,
+	// record_size, and ip_version must not be changed.
+	MetadataHook func(mmdbtype.Map) mmdbtype.Map
+
+	// DuplicatePolicy determines how an insert of exactly the same network
+	// as an earlier insert is handled. The default is DuplicateMerge, which
+	// inserts it like any other insert. Setting another policy enables
+	// tracking of the inserted networks, as with TrackDuplicates.
+	DuplicatePolicy DuplicatePolicy
+
+	// TrackDuplicates enables tracking of the inserted networks so that
+	// Tree.DuplicateCount reports the number of duplicate inserts. This
+	// uses memory for each distinct network inserted. Inserts that remove
+	// data, e.g., with inserter.Remove, are also tracked.
+	TrackDuplicates bool
+
+	// Metrics, if set, receives measurements of the inserts, finalization,
+	// and writes, and the memory used by the tree.
+	Metrics Metrics
+
+	// Logger, if set, receives warnings about duplicate inserts, networks
+	// truncated to the maximum prefix length, and records exceeding
+	// MaxRecordSize, as well as the progress of finalizing and writing the
+	// tree. A *slog.Logger may be used.
+	Logger Logger
+
+	// MembershipOnly builds a database that only records which networks
+	// are in a set, e.g., a blocklist. The data of each insert is replaced
+	// by an empty Map, so all the networks share one record, which allows
+	// them to be aggregated and reduces the data section to a single byte.
+	// Inserts whose inserter function returns nil still remove networks.
+	MembershipOnly bool
+
+	// MaxIPv4PrefixLength, if greater than zero, is the maximum prefix
+	// length of the IPv4 networks that may be inserted, e.g., 24. This
+	// bounds the size of the database, e.g., for edge deployments. The
+	// PrefixLengthPolicy determines how more specific networks are handled.
+	// In an IPv6 tree, it applies to the networks within the IPv4 subtree,
+	// ::/96 unless IPv4Placement is set, which includes the IPv4 networks
+	// inserted.
+	MaxIPv4PrefixLength int
+
+	// MaxIPv6PrefixLength, if greater than zero, is the maximum prefix
+	// length of the IPv6 networks that may be inserted, e.g., 48. It may
+	// only be set for an IPv6 tree.
+	MaxIPv6PrefixLength int
+
+	// PrefixLengthPolicy determines how an insert of a network more
+	// specific than MaxIPv4PrefixLength or MaxIPv6PrefixLength is handled.
+	// The default is PrefixLengthError, which returns an error.
+	PrefixLengthPolicy PrefixLengthPolicy
+
+	// Session, if set, is the Session whose pool of interned values the
+	// tree shares with the other trees in the session.
+	Session *Session
 }
 
+// ErrLimitExceeded is wrapped by the errors returned when the tree exceeds
+// Options.MaxNodes, Options.MaxDataSize, or Options.MaxRecordSize.
+var ErrLimitExceeded = errors.New("limit exceeded")
+
 // Tree represents an MaxMind DB search tree.
 type Tree struct {
 	buildEpoch              int64
 	databaseType            string
 	dataMap                 *dataMap
 	description             map[string]string
+	disableIPv4Aliasing     bool
+	reserveIPv4Mapped       bool
+	ipv6Only                bool
+	ipv4Placement           IPv4Placement
+	ipv4Prefix              net.IP
 	disableMetadataPointers bool
+	format                  formatOptions
+	includeReservedNetworks bool
 	ipVersion               int
 	languages               []string
 	recordSize              int
 	root                    *node
 	treeDepth               int
 	// This is set when the tree is finalized
-	nodeCount       int
-	inserterFuncGen inserter.FuncGenerator
+	nodeCount          int
+	inserterFuncGen    inserter.FuncGenerator
+	floatDecimalPlaces int
+	floatEpsilon       *floatCanonicalizer
+	enumFields         []string
+	dictionaryFields   []string
+	mapKeyOrder        []string
+	networkFields      []NetworkField
+	indexFields        []string
+	index              *fieldIndex
+	sources            *sourceTracker
+	expiries           *expiryTracker
+	priorities         *priorityTracker
+	validateRecordSize bool
+	autoRecordSize     bool
+	cloneValues        bool
+	embedChecksum      bool
+	// dataSectionAlignment and paddingNodes are used to align the data
+	// section. paddingNodes is set when the tree is finalized and is
+	// included in nodeCount.
+	dataSectionAlignment int
+	paddingNodes         int
+	// liveNodes is the current number of nodes in the tree. Unlike
+	// nodeCount, it is kept up to date while inserting.
+	liveNodes   int
+	maxNodes    int
+	maxDataSize int
+	// maxRecordSize is the maximum encoded size of a single record.
+	maxRecordSize int
+	// nodes allocates the nodes of the tree.
+	nodes            nodeStore
+	nodeStorage      NodeStorage
+	nodeStorageDir   string
+	valueStorage     ValueStorage
+	valueStorageDir  string
+	overwritePolicy  OverwritePolicy
+	insertMiddleware []InsertMiddleware
+	dataSectionSpool func() (io.ReadWriteSeeker, error)
+	metadataHook     func(mmdbtype.Map) mmdbtype.Map
+	duplicatePolicy  DuplicatePolicy
+	duplicates       *duplicateTracker
+	report           BuildReport
+	metrics          Metrics
+	logger           Logger
+	memoryHighWater  int64
+	membershipOnly   bool
+	filterNames      map[mmdbtype.String]struct{}
+	nameFallbacks    languageFallbacks
+	prefixLengths    *prefixLengthLimit
+	session          *Session
 }
 
 // New creates a new Tree.
 func New(opts Options) (*Tree, error) {
+	return newTree(opts, loadedMetadata{})
+}
+
+func newTree(opts Options, loaded loadedMetadata) (*Tree, error) {
 	tree := &Tree{
 		buildEpoch:              time.Now().Unix(),
 		dataMap:                 newDataMap(),
 		databaseType:            opts.DatabaseType,
 		description:             map[string]string{},
+		disableIPv4Aliasing:     opts.DisableIPv4Aliasing || opts.IPv6Only,
+		reserveIPv4Mapped:       opts.ReserveIPv4MappedNetwork,
+		ipv6Only:                opts.IPv6Only,
+		ipv4Placement:           opts.IPv4Placement,
+		ipv4Prefix:              opts.IPv4Placement.prefix(),
 		disableMetadataPointers: opts.DisableMetadataPointers,
+		includeReservedNetworks: opts.IncludeReservedNetworks,
 		ipVersion:               6,
 		recordSize:              28,
-		root:                    &node{},
 		inserterFuncGen:         inserter.ReplaceWith,
+		floatDecimalPlaces:      opts.FloatDecimalPlaces,
+		enumFields:              opts.EnumFields,
+		dictionaryFields:        opts.DictionaryFields,
+		mapKeyOrder:             append([]string(nil), opts.MapKeyOrder...),
+		networkFields:           append([]NetworkField(nil), opts.NetworkFields...),
+		indexFields:             append([]string(nil), opts.IndexFields...),
+		validateRecordSize:      opts.ValidateRecordSize,
+		autoRecordSize:          opts.AutoRecordSize,
+		cloneValues:             opts.CloneValues,
+		embedChecksum:           opts.EmbedChecksum,
+		dataSectionAlignment:    opts.DataSectionAlignment,
+		liveNodes:               1,
+		maxNodes:                opts.MaxNodes,
+		maxDataSize:             opts.MaxDataSize,
+		maxRecordSize:           opts.MaxRecordSize,
+		nodeStorage:             opts.NodeStorage,
+		nodeStorageDir:          opts.NodeStorageDir,
+		valueStorage:            opts.ValueStorage,
+		valueStorageDir:         opts.ValueStorageDir,
+		overwritePolicy:         opts.OverwritePolicy,
+		insertMiddleware:        append([]InsertMiddleware(nil), opts.InsertMiddleware...),
+		dataSectionSpool:        opts.DataSectionSpool,
+		metadataHook:            opts.MetadataHook,
+		duplicatePolicy:         opts.DuplicatePolicy,
+		metrics:                 opts.Metrics,
+		logger:                  opts.Logger,
+		membershipOnly:          opts.MembershipOnly,
+		session:                 opts.Session,
 	}
+	tree.dataMap.cloneValues = opts.CloneValues
+	tree.dataMap.session = opts.Session
 
+	if opts.OverwritePolicy < OverwriteMerge || opts.OverwritePolicy > OverwriteError {
+		return nil, fmt.Errorf("unsupported OverwritePolicy: %d", int(opts.OverwritePolicy))
+	}
+	if opts.DuplicatePolicy < DuplicateMerge || opts.DuplicatePolicy > DuplicateError {
+		return nil, fmt.Errorf("unsupported DuplicatePolicy: %d", opts.DuplicatePolicy)
+	}
+	if opts.TrackDuplicates || opts.DuplicatePolicy != DuplicateMerge {
+		tree.duplicates = &duplicateTracker{seen: map[string]struct{}{}}
+	}
+
+	if opts.BuildEpoch < 0 {
+		return nil, fmt.Errorf("BuildEpoch must not be negative: %d", opts.BuildEpoch)
+	}
 	if opts.BuildEpoch != 0 {
 		tree.buildEpoch = opts.BuildEpoch
 	}
 
-	if opts.Description != nil {
-		tree.description = opts.Description
+	if !opts.BuildTime.IsZero() {
+		epoch := opts.BuildTime.Unix()
+		if epoch < 0 {
+			return nil, fmt.Errorf("BuildTime must not be before the Unix epoch: %s", opts.BuildTime)
+		}
+		if opts.BuildEpoch != 0 && opts.BuildEpoch != epoch {
+			return nil, fmt.Errorf(
+				"BuildTime (%s) and BuildEpoch (%d) are both set but do not match",
+				opts.BuildTime,
+				opts.BuildEpoch,
+			)
+		}
+		tree.buildEpoch = epoch
 	}
 
-	if opts.IPVersion != 0 {
-		tree.ipVersion = opts.IPVersion
+	if opts.DataSectionAlignment < 0 ||
+		opts.DataSectionAlignment&(opts.DataSectionAlignment-1) != 0 {
+		return nil, fmt.Errorf(
+			"DataSectionAlignment must be a power of two: %d",
+			opts.DataSectionAlignment,
+		)
+	}
+
+	if opts.FloatDecimalPlaces < 0 || opts.FloatDecimalPlaces > maxFloatDecimalPlaces {
+		return nil, fmt.Errorf(
+			"FloatDecimalPlaces must be between 0 and %d: %d",
+			maxFloatDecimalPlaces,
+			opts.FloatDecimalPlaces,
+		)
+	}
+
+	if opts.FloatEpsilon < 0 || math.IsNaN(opts.FloatEpsilon) || math.IsInf(opts.FloatEpsilon, 0) {
+		return nil, fmt.Errorf("FloatEpsilon must be a finite, non-negative number: %v", opts.FloatEpsilon)
 	}
+	if opts.FloatEpsilon > 0 {
+		tree.floatEpsilon = newFloatCanonicalizer(opts.FloatEpsilon)
+	}
+
+	if err := validateNetworkFields(opts.NetworkFields); err != nil {
+		return nil, err
+	}
+	seenKeys := map[string]struct{}{}
+	for _, key := range opts.MapKeyOrder {
+		if _, ok := seenKeys[key]; ok {
+			return nil, fmt.Errorf("duplicate key in MapKeyOrder: %q", key)
+		}
+		seenKeys[key] = struct{}{}
+	}
+	for _, field := range opts.DictionaryFields {
+		for _, enumField := range opts.EnumFields {
+			if field == enumField {
+				return nil, fmt.Errorf("%s is in both EnumFields and DictionaryFields", field)
+			}
+		}
+	}
+
+	index, err := newFieldIndex(opts.IndexFields)
+	if err != nil {
+		return nil, err
+	}
+	tree.index = index
+
+	if err := tree.setLanguages(opts, loaded); err != nil {
+		return nil, err
+	}
+	if opts.FilterNamesByLanguages {
+		if len(tree.languages) == 0 {
+			return nil, errors.New("FilterNamesByLanguages requires Languages to be set")
+		}
+		tree.filterNames = languageSet(tree.languages)
+	}
+	format, err := newFormatOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	tree.format = format
 
-	if opts.Languages != nil {
-		tree.languages = opts.Languages
+	nameFallbacks, err := newLanguageFallbacks(opts.LanguageFallbacks, tree.languages)
+	if err != nil {
+		return nil, err
+	}
+	tree.nameFallbacks = nameFallbacks
+
+	if opts.IPVersion != 0 {
+		tree.ipVersion = opts.IPVersion
 	}
 
 	if opts.RecordSize != 0 {
 		tree.recordSize = opts.RecordSize
 	}
+	if _, err := newRecordEncoder(tree.recordSize); err != nil {
+		return nil, err
+	}
 
 	if opts.Inserter != nil {
 		tree.inserterFuncGen = opts.Inserter
 	}
 
+	if opts.TrackSources {
+		tree.sources = &sourceTracker{}
+	}
+
 	switch tree.ipVersion {
 	case 6:
 		tree.treeDepth = 128
@@ -144,20 +692,184 @@ func New(opts Options) (*Tree, error) {
 		return nil, fmt.Errorf("unsupported IPVersion: %d", tree.ipVersion)
 	}
 
-	if tree.ipVersion == 6 && !opts.DisableIPv4Aliasing {
-		if err := tree.insertIPv4Aliases(); err != nil {
-			return nil, err
+	if err := validateIPv4Placement(opts, tree.ipVersion); err != nil {
+		return nil, err
+	}
+
+	if opts.IPv6Only && tree.ipVersion != 6 {
+		return nil, errors.New("IPv6Only requires IPVersion to be 6")
+	}
+
+	if opts.ReserveIPv4MappedNetwork && (tree.ipVersion != 6 || !tree.disableIPv4Aliasing) {
+		return nil, errors.New(
+			"ReserveIPv4MappedNetwork requires an IPv6 tree with DisableIPv4Aliasing set",
+		)
+	}
+
+	prefixLengths, err := newPrefixLengthLimit(opts, tree.ipVersion)
+	if err != nil {
+		return nil, err
+	}
+	tree.prefixLengths = prefixLengths
+
+	if opts.ValueStorage == ValueStorageFile && opts.Session != nil {
+		return nil, errors.New("ValueStorageFile cannot be used with Session")
+	}
+	values, err := newValueStore(opts.ValueStorage, opts.ValueStorageDir)
+	if err != nil {
+		return nil, err
+	}
+	tree.dataMap.values = values
+
+	nodes, err := newNodeStore(opts.NodeStorage, opts.NodeStorageDir)
+	if err != nil {
+		tree.closeValues() //nolint:errcheck // the node store error is more relevant
+		return nil, err
+	}
+	tree.nodes = nodes
+
+	if err := tree.init(opts); err != nil {
+		tree.Close() //nolint:errcheck // the init error is more relevant
+		return nil, err
+	}
+
+	return tree, nil
+}
+
+// init creates the root node and inserts the aliased and reserved networks.
+func (t *Tree) init(opts Options) error {
+	root, err := t.nodes.newNode()
+	if err != nil {
+		return err
+	}
+	t.root = root
+
+	if t.ipVersion == 6 && !t.disableIPv4Aliasing {
+		if err := t.insertIPv4Aliases(); err != nil {
+			return err
+		}
+	}
+
+	if t.ipv6Only {
+		if err := t.insertIPv6OnlyReservations(); err != nil {
+			return err
+		}
+	} else if opts.ReserveIPv4MappedNetwork {
+		err := t.insertStringNetwork(ipv4MappedNetwork, recordTypeReserved, nil, nil)
+		if err != nil {
+			return err
 		}
 	}
 
 	if !opts.IncludeReservedNetworks {
-		err := tree.insertReservedNetworks()
+		err := t.insertReservedNetworks()
 		if err != nil {
-			return nil, err
+			return err
 		}
 	}
+	return nil
+}
 
-	return tree, nil
+// Close releases the resources used by the tree, such as the files used by
+// NodeStorageFile and ValueStorageFile. The tree must not be used after it
+// is closed.
+func (t *Tree) Close() error {
+	err := t.nodes.close()
+	if valuesErr := t.closeValues(); valuesErr != nil && err == nil {
+		err = valuesErr
+	}
+	return err
+}
+
+func (t *Tree) closeValues() error {
+	if t.dataMap.values == nil {
+		return nil
+	}
+	return t.dataMap.values.close()
+}
+
+// BuildTime returns the build time that will be written to the database.
+func (t *Tree) BuildTime() time.Time {
+	return time.Unix(t.buildEpoch, 0)
+}
+
+// options returns the Options that would create an empty tree with the
+// same configuration as t.
+func (t *Tree) options() Options {
+	description := make(map[string]string, len(t.description))
+	for k, v := range t.description {
+		description[k] = v
+	}
+
+	var floatEpsilon float64
+	if t.floatEpsilon != nil {
+		floatEpsilon = t.floatEpsilon.epsilon
+	}
+
+	var (
+		maxIPv4PrefixLength int
+		maxIPv6PrefixLength int
+		prefixLengthPolicy  PrefixLengthPolicy
+	)
+	if t.prefixLengths != nil {
+		maxIPv4PrefixLength = t.prefixLengths.ipv4
+		maxIPv6PrefixLength = t.prefixLengths.ipv6
+		prefixLengthPolicy = t.prefixLengths.policy
+	}
+
+	return Options{
+		BuildEpoch:               t.buildEpoch,
+		DatabaseType:             t.databaseType,
+		Description:              description,
+		DisableIPv4Aliasing:      t.disableIPv4Aliasing,
+		ReserveIPv4MappedNetwork: t.reserveIPv4Mapped,
+		IPv6Only:                 t.ipv6Only,
+		IPv4Placement:            t.ipv4Placement,
+		IncludeReservedNetworks:  t.includeReservedNetworks,
+		IPVersion:                t.ipVersion,
+		Languages:                append([]string(nil), t.languages...),
+		RecordSize:               t.recordSize,
+		DisableMetadataPointers:  t.disableMetadataPointers,
+		BinaryFormatMajorVersion: t.format.majorVersion,
+		BinaryFormatMinorVersion: t.format.minorVersion,
+		DisabledFormatFeatures:   append([]FormatFeature(nil), t.format.disabled...),
+		Inserter:                 t.inserterFuncGen,
+		FloatDecimalPlaces:       t.floatDecimalPlaces,
+		FloatEpsilon:             floatEpsilon,
+		EnumFields:               append([]string(nil), t.enumFields...),
+		DictionaryFields:         append([]string(nil), t.dictionaryFields...),
+		MapKeyOrder:              append([]string(nil), t.mapKeyOrder...),
+		NetworkFields:            append([]NetworkField(nil), t.networkFields...),
+		IndexFields:              append([]string(nil), t.indexFields...),
+		TrackSources:             t.sources != nil,
+		ValidateRecordSize:       t.validateRecordSize,
+		AutoRecordSize:           t.autoRecordSize,
+		CloneValues:              t.cloneValues,
+		EmbedChecksum:            t.embedChecksum,
+		DataSectionAlignment:     t.dataSectionAlignment,
+		MaxNodes:                 t.maxNodes,
+		MaxDataSize:              t.maxDataSize,
+		MaxRecordSize:            t.maxRecordSize,
+		NodeStorage:              t.nodeStorage,
+		NodeStorageDir:           t.nodeStorageDir,
+		ValueStorage:             t.valueStorage,
+		ValueStorageDir:          t.valueStorageDir,
+		OverwritePolicy:          t.overwritePolicy,
+		InsertMiddleware:         append([]InsertMiddleware(nil), t.insertMiddleware...),
+		DataSectionSpool:         t.dataSectionSpool,
+		MetadataHook:             t.metadataHook,
+		DuplicatePolicy:          t.duplicatePolicy,
+		TrackDuplicates:          t.duplicates != nil,
+		Metrics:                  t.metrics,
+		Logger:                   t.logger,
+		MembershipOnly:           t.membershipOnly,
+		FilterNamesByLanguages:   t.filterNames != nil,
+		LanguageFallbacks:        t.nameFallbacks.options(),
+		MaxIPv4PrefixLength:      maxIPv4PrefixLength,
+		MaxIPv6PrefixLength:      maxIPv6PrefixLength,
+		PrefixLengthPolicy:       prefixLengthPolicy,
+		Session:                  t.session,
+	}
 }
 
 // Load an existing database into the writer.
@@ -173,8 +885,10 @@ func Load(path string, opts Options) (*Tree, error) {
 		opts.DatabaseType = metadata.DatabaseType
 	}
 
+	var loaded loadedMetadata
 	if opts.Description == nil {
 		opts.Description = metadata.Description
+		loaded.description = true
 	}
 
 	if opts.IPVersion == 0 {
@@ -183,48 +897,88 @@ func Load(path string, opts Options) (*Tree, error) {
 
 	if opts.Languages == nil {
 		opts.Languages = metadata.Languages
+		loaded.languages = true
 	}
 
 	if opts.RecordSize == 0 {
 		opts.RecordSize = int(metadata.RecordSize)
 	}
 
-	tree, err := New(opts)
+	tree, err := newTree(opts, loaded)
 	if err != nil {
 		return nil, err
 	}
 
-	dser := newDeserializer()
+	if err := tree.InsertReader(db); err != nil {
+		return nil, err
+	}
+	return tree, nil
+}
 
+// InsertReader inserts the networks and records from the reader into the
+// tree using the Tree's inserter function. If any networks are provided,
+// only the data within those networks is inserted. Otherwise, all of the
+// data in the reader is inserted.
+//
+// If the tree has IPv4 aliasing enabled, the aliased networks in the reader
+// are skipped.
+//
+// This is not safe to call from multiple threads.
+func (t *Tree) InsertReader(reader *maxminddb.Reader, networks ...*net.IPNet) error {
 	var networkOpts []maxminddb.NetworksOption
-	if opts.IPVersion == 6 && !opts.DisableIPv4Aliasing {
+	if t.ipVersion == 6 && !t.disableIPv4Aliasing {
 		networkOpts = append(networkOpts, maxminddb.SkipAliasedNetworks)
 	}
 
-	networks := db.Networks(networkOpts...)
-	for networks.Next() {
-		var network *net.IPNet
+	if len(networks) == 0 {
+		return t.insertNetworks(reader.Networks(networkOpts...), nil)
+	}
+	for _, network := range networks {
+		err := t.insertNetworks(reader.NetworksWithin(network, networkOpts...), network)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
+// insertNetworks inserts the networks from the iterator. If within is
+// non-nil, any network containing it is reduced to within.
+func (t *Tree) insertNetworks(networks *maxminddb.Networks, within *net.IPNet) error {
+	var withinPrefixLen int
+	if within != nil {
+		withinPrefixLen, _ = within.Mask.Size()
+	}
+
+	dser := newDeserializer()
+	for networks.Next() {
 		dser.clear()
-		network, err = networks.Network(dser)
+		network, err := networks.Network(dser)
 		if err != nil {
-			return nil, err
+			return err
+		}
+
+		// NetworksWithin returns the containing network when within is
+		// inside of a single record.
+		if prefixLen, _ := network.Mask.Size(); within != nil && prefixLen < withinPrefixLen {
+			network = within
 		}
 
-		err = tree.Insert(network, dser.rv)
+		err = t.Insert(network, dser.rv)
 		if err != nil {
-			return nil, err
+			return err
 		}
 	}
-	if err := networks.Err(); err != nil {
-		return nil, err
-	}
-	return tree, nil
+	return networks.Err()
 }
 
 // Insert a data value into the tree using the Tree's inserter function
 // (defaults to inserter.ReplaceWith).
 //
+// Adjacent networks with equal data are aggregated into the largest
+// possible networks as they are inserted, e.g., four consecutive /26
+// networks become a /24, so no separate aggregation pass is needed.
+//
 // This is not safe to call from multiple threads.
 func (t *Tree) Insert(network *net.IPNet, value mmdbtype.DataType) error {
 	return t.InsertFunc(network, t.inserterFuncGen(value))
@@ -249,7 +1003,35 @@ func (t *Tree) InsertFunc(
 	network *net.IPNet,
 	inserterFunc inserter.Func,
 ) error {
-	return t.insert(network, recordTypeData, inserterFunc, nil)
+	return t.insert(network, recordTypeData, t.applyMiddleware(network, inserterFunc), nil)
+}
+
+// InsertCanonical is the same as Insert, except it also returns the network
+// the data was inserted into. This is the network as stored in the tree:
+// the address is masked, an IPv4 network in an IPv6 tree is mapped to its
+// IPv6 form, e.g., ::1.1.1.0/120 with the default IPv4Placement, and the
+// prefix length is truncated to the maximum prefix length when
+// PrefixLengthTruncate is used. The network matches the one returned by Get
+// for a 16-byte address within it, provided that the record has not been
+// aggregated into a larger network.
+//
+// The network is returned without an error when the insert is skipped as a
+// duplicate by DuplicateKeep.
+//
+// This is not safe to call from multiple threads.
+func (t *Tree) InsertCanonical(network *net.IPNet, value mmdbtype.DataType) (*net.IPNet, error) {
+	return t.InsertFuncCanonical(network, t.inserterFuncGen(value))
+}
+
+// InsertFuncCanonical is the same as InsertFunc, except it also returns the
+// network the data was inserted into, as with InsertCanonical.
+//
+// This is not safe to call from multiple threads.
+func (t *Tree) InsertFuncCanonical(
+	network *net.IPNet,
+	inserterFunc inserter.Func,
+) (*net.IPNet, error) {
+	return t.insertCanonical(network, recordTypeData, t.applyMiddleware(network, inserterFunc), nil)
 }
 
 func (t *Tree) insert(
@@ -258,29 +1040,180 @@ func (t *Tree) insert(
 	inserterFunc inserter.Func,
 	node *node,
 ) error {
+	_, err := t.insertCanonical(network, recordType, inserterFunc, node)
+	return err
+}
+
+// insertCanonical inserts into the network and returns the network, in tree
+// form, that was inserted into.
+func (t *Tree) insertCanonical(
+	network *net.IPNet,
+	recordType recordType,
+	inserterFunc inserter.Func,
+	node *node,
+) (*net.IPNet, error) {
 	// We set this to 0 so that the tree must be finalized again.
 	t.nodeCount = 0
+	t.invalidateIndex()
+
+	// This must be checked before the inserter function is wrapped.
+	checkDuplicate := t.duplicates != nil && recordType == recordTypeData && !isRemove(inserterFunc)
+
+	if recordType == recordTypeData {
+		t.report.Inserts++
+		start := time.Now()
+		defer func() {
+			d := time.Since(start)
+			t.report.InsertDuration += d
+			if t.metrics != nil {
+				t.metrics.ObserveInsert(d)
+				t.observeMemory()
+			}
+		}()
+	}
+
+	if recordType == recordTypeData && t.membershipOnly {
+		inserterFunc = membershipInserter(inserterFunc)
+	} else if recordType == recordTypeData {
+		if t.floatDecimalPlaces > 0 {
+			inserterFunc = roundFloatsInserter(inserterFunc, t.floatDecimalPlaces)
+		}
+		if t.floatEpsilon != nil {
+			inserterFunc = floatEpsilonInserter(inserterFunc, t.floatEpsilon, true)
+		}
+	}
+	if recordType == recordTypeData && t.nameFallbacks != nil && !t.membershipOnly {
+		inserterFunc = fallbackNamesInserter(inserterFunc, t.nameFallbacks)
+	}
+	if recordType == recordTypeData && t.filterNames != nil && !t.membershipOnly {
+		inserterFunc = filterNamesInserter(inserterFunc, t.filterNames)
+	}
+	if recordType == recordTypeData && t.format.disableUint128 {
+		inserterFunc = noUint128Inserter(inserterFunc, network)
+	}
+	if recordType == recordTypeData && t.maxRecordSize > 0 {
+		inserterFunc = maxRecordSizeInserter(inserterFunc, network, t.maxRecordSize, t.logger)
+	}
+
+	if recordType == recordTypeData {
+		if err := t.checkIPv6Only(network); err != nil {
+			return nil, err
+		}
+	}
 
 	prefixLen, _ := network.Mask.Size()
 
 	ip := network.IP
-	if t.treeDepth == 128 && len(ip) == 4 {
-		ip = ipV4ToV6(ip)
+	switch {
+	case t.treeDepth == 128 && len(ip) == 4:
+		ip = t.ipv4ToTree(ip)
 		prefixLen += 96
+	case t.treeDepth == 32 && len(ip) == net.IPv6len:
+		ip, prefixLen = unmapIPv4(ip, prefixLen)
+		if len(ip) != net.IPv4len {
+			return nil, fmt.Errorf("%s is not a valid network for an IPv4 tree", network)
+		}
+	}
+
+	if t.prefixLengths != nil && recordType == recordTypeData {
+		var err error
+		prefixLen, err = t.checkPrefixLength(network, ip, prefixLen)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	mask := net.CIDRMask(prefixLen, t.treeDepth)
+	canonical := &net.IPNet{IP: ip.Mask(mask), Mask: mask}
+
+	if checkDuplicate {
+		f, key, err := t.checkDuplicate(network, ip, prefixLen, inserterFunc)
+		if err != nil {
+			return nil, err
+		}
+		if f == nil {
+			return canonical, nil
+		}
+		if err := t.insertPrioritized(ip, prefixLen, recordType, f, node); err != nil {
+			return nil, err
+		}
+		t.duplicates.add(key)
+		return canonical, nil
 	}
+	if err := t.insertPrioritized(ip, prefixLen, recordType, inserterFunc, node); err != nil {
+		return nil, err
+	}
+	return canonical, nil
+}
+
+// insertPrioritized inserts into the network, which is in tree form,
+// applying the priorities from InsertWithPriority.
+func (t *Tree) insertPrioritized(
+	ip net.IP,
+	prefixLen int,
+	recordType recordType,
+	inserterFunc inserter.Func,
+	node *node,
+) error {
+	if t.priorities != nil && recordType == recordTypeData {
+		for _, allowed := range t.priorities.allowed(ip, prefixLen) {
+			err := t.insertTreeNetwork(allowed.ip, allowed.prefixLen, recordType, inserterFunc, node)
+			if err != nil {
+				return err
+			}
+			t.priorities.insert(allowed.ip, allowed.prefixLen)
+		}
+		return nil
+	}
+	return t.insertTreeNetwork(ip, prefixLen, recordType, inserterFunc, node)
+}
 
-	return t.root.insert(
+// insertTreeNetwork inserts into the network, which is in tree form.
+func (t *Tree) insertTreeNetwork(
+	ip net.IP,
+	prefixLen int,
+	recordType recordType,
+	inserterFunc inserter.Func,
+	node *node,
+) error {
+	if recordType == recordTypeData && t.overwritePolicy == OverwriteError {
+		if err := t.checkOverwrite(ip.Mask(net.CIDRMask(prefixLen, t.treeDepth)), prefixLen); err != nil {
+			return err
+		}
+	}
+
+	err := t.root.insert(
 		insertRecord{
 			ip:           ip,
 			prefixLen:    prefixLen,
 			recordType:   recordType,
 			inserter:     inserterFunc,
 			insertedNode: node,
+			nodeCount:    &t.liveNodes,
+			report:       &t.report,
 
 			dataMap: t.dataMap,
+			nodes:   t.nodes,
+
+			overwritePolicy: t.overwritePolicy,
 		},
 		0,
 	)
+	if err != nil {
+		return err
+	}
+
+	if err := t.checkLimits(); err != nil {
+		return err
+	}
+
+	if t.sources != nil && recordType == recordTypeData {
+		t.sources.insert(ip, prefixLen)
+	}
+	if t.expiries != nil && recordType == recordTypeData {
+		t.expiries.insert(ip, prefixLen)
+	}
+	return nil
 }
 
 // InsertRange is the same as Insert, except it will insert all subnets within
@@ -303,6 +1236,35 @@ func (t *Tree) InsertRangeFunc(
 	return t.insertRange(start, end, recordTypeData, inserterFunc, nil)
 }
 
+// InsertMany is the same as Insert, except it inserts the value for all of
+// the networks. The networks are first collapsed into the smallest set of
+// networks that covers the same addresses, e.g., 1.1.0.0/23 for 1.1.0.0/24
+// and 1.1.1.0/24, which is much cheaper than inserting many adjacent small
+// networks. Overlapping networks are only inserted once.
+//
+// As with InsertRange, IPv4-mapped IPv6 networks are treated as IPv4
+// networks.
+func (t *Tree) InsertMany(networks []*net.IPNet, value mmdbtype.DataType) error {
+	return t.InsertManyFunc(networks, t.inserterFuncGen(value))
+}
+
+// InsertManyFunc is the same as InsertFunc, except it inserts into all of
+// the networks after collapsing them as with InsertMany. The inserter
+// function is called for the records of the collapsed networks.
+func (t *Tree) InsertManyFunc(networks []*net.IPNet, inserterFunc inserter.Func) error {
+	collapsed, err := collapseNetworks(networks)
+	if err != nil {
+		return err
+	}
+	for _, network := range collapsed {
+		f := t.applyMiddleware(network, inserterFunc)
+		if err := t.insert(network, recordTypeData, f, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (t *Tree) insertRange(
 	start net.IP,
 	end net.IP,
@@ -310,27 +1272,71 @@ func (t *Tree) insertRange(
 	inserterFunc inserter.Func,
 	node *node,
 ) error {
+	networks, err := rangeNetworks(start, end)
+	if err != nil {
+		return err
+	}
+	for _, network := range networks {
+		f := inserterFunc
+		if recordType == recordTypeData {
+			f = t.applyMiddleware(network, f)
+		}
+		if err := t.insert(network, recordType, f, node); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rangeNetworks returns the networks that make up the range of IPs
+// specified by `[start,end]`.
+// collapseNetworks returns the smallest set of networks that covers the
+// same addresses as the networks, in order.
+func collapseNetworks(networks []*net.IPNet) ([]*net.IPNet, error) {
+	var b netipx.IPSetBuilder
+	for _, network := range networks {
+		if network == nil {
+			return nil, errors.New("the network is nil")
+		}
+		prefix, ok := netipx.FromStdIPNet(network)
+		if !ok {
+			return nil, fmt.Errorf("invalid network: %s", network)
+		}
+		b.AddPrefix(prefix)
+	}
+	set, err := b.IPSet()
+	if err != nil {
+		return nil, err
+	}
+	prefixes := set.Prefixes()
+	collapsed := make([]*net.IPNet, len(prefixes))
+	for i, prefix := range prefixes {
+		collapsed[i] = netipx.PrefixIPNet(prefix)
+	}
+	return collapsed, nil
+}
+
+func rangeNetworks(start, end net.IP) ([]*net.IPNet, error) {
 	startNetIP, ok := netipx.FromStdIP(start)
 	if !ok {
-		return errors.New("start IP is invalid")
+		return nil, errors.New("start IP is invalid")
 	}
 	endNetIP, ok := netipx.FromStdIP(end)
 	if !ok {
-		return errors.New("end IP is invalid")
+		return nil, errors.New("end IP is invalid")
 	}
 
 	r := netipx.IPRangeFrom(startNetIP, endNetIP)
 	if !r.IsValid() {
-		return errors.New("start & end IPs did not give valid range")
+		return nil, errors.New("start & end IPs did not give valid range")
 	}
 	subnets := r.Prefixes()
-	for _, subnet := range subnets {
-		if err := t.insert(netipx.PrefixIPNet(subnet), recordType, inserterFunc, node); err != nil {
-			return err
-		}
+	networks := make([]*net.IPNet, len(subnets))
+	for i, subnet := range subnets {
+		networks[i] = netipx.PrefixIPNet(subnet)
 	}
-
-	return nil
+	return networks, nil
 }
 
 func (t *Tree) insertStringNetwork(
@@ -353,20 +1359,29 @@ var ipv4AliasNetworks = []string{
 }
 
 func (t *Tree) insertIPv4Aliases() error {
-	_, ipv4Root, err := net.ParseCIDR("::/96")
+	_, ipv4Root, err := net.ParseCIDR(t.ipv4Placement.network())
 	if err != nil {
 		return fmt.Errorf("parsing IPv4 root: %w", err)
 	}
 
-	ipv4RootNode := &node{}
+	ipv4RootNode, err := t.nodes.newNode()
+	if err != nil {
+		return err
+	}
 
-	// Make ::/96, the IPv4 root, a fixed node.
+	// Make the IPv4 root, ::/96 by default, a fixed node.
 	err = t.insert(ipv4Root, recordTypeFixedNode, nil, ipv4RootNode)
 	if err != nil {
 		return err
 	}
 
-	for _, network := range ipv4AliasNetworks {
+	aliases := ipv4AliasNetworks
+	if t.ipv4Placement == IPv4PlacementMapped {
+		// ::/96 is where readers look up IPv4 addresses, so it takes the
+		// place of ::ffff:0:0/96 as an alias.
+		aliases = append([]string{ipv4CompatibleNetwork}, ipv4AliasNetworks[1:]...)
+	}
+	for _, network := range aliases {
 		err := t.insertStringNetwork(network, recordTypeAlias, nil, ipv4RootNode)
 		if err != nil {
 			return err
@@ -378,6 +1393,10 @@ func (t *Tree) insertIPv4Aliases() error {
 func (t *Tree) insertReservedNetworks() error {
 	// the reserved networks are in reserved.go
 	networks := reservedNetworksIPv4
+	if t.ipv6Only {
+		// The IPv4 networks are already reserved in their entirety.
+		networks = nil
+	}
 	if t.ipVersion == 6 {
 		networks = append(networks, reservedNetworksIPv6...)
 	}
@@ -394,125 +1413,652 @@ func (t *Tree) insertReservedNetworks() error {
 // Get the value for the given IP address from the tree. If the nil interface
 // is returned, that means the tree does not have a value for the IP.
 func (t *Tree) Get(ip net.IP) (*net.IPNet, mmdbtype.DataType) {
+	network, value, _ := t.GetRecord(ip)
+	return network, value
+}
+
+// GetRecord is the same as Get, except it also returns whether the tree has
+// a record with data for the IP address. This distinguishes a network
+// without data from a record whose value is, e.g., an empty Map. The
+// network is returned in either case. If there is no data, it is the
+// largest network around the IP address without data, or the reserved
+// network containing it. In a tree with Options.IPv6Only set, the network
+// is nil for IPv4 addresses.
+func (t *Tree) GetRecord(ip net.IP) (*net.IPNet, mmdbtype.DataType, bool) {
+	if t.ipv6Only && ip.To4() != nil {
+		return nil, nil, false
+	}
+	return get(t.dataMap, t.root, t.treeDepth, t.ipv4Prefix, ip)
+}
+
+// get looks up the IP address in the search tree rooted at root, whose
+// values are in dm. The ipv4Prefix is the prefix of the IPv4 addresses in
+// an IPv6 tree.
+func get(
+	dm *dataMap,
+	root *node,
+	treeDepth int,
+	ipv4Prefix, ip net.IP,
+) (*net.IPNet, mmdbtype.DataType, bool) {
 	lookupIP := ip
 
-	if t.treeDepth == 128 {
+	if treeDepth == 128 {
 		// We use To4() here as Go will parse an IPv4 address to a 16 byte
 		// IPv6-mapped IPv4 address, e.g.:
 		//
 		// len(net.ParseIP("1.1.1.1")) == 16
 		//
 		// The parsed address above is equal to ::ffff:1.1.1.1. However,
-		// the MaxMind DB format has the record for 1.1.1.1 at ::1.1.1.1.
+		// the MaxMind DB format has the record for 1.1.1.1 at ::1.1.1.1,
+		// or wherever Options.IPv4Placement put it.
 		if ipv4 := ip.To4(); ipv4 != nil {
-			lookupIP = ipV4ToV6(ipv4)
+			lookupIP = append(append(make(net.IP, 0, net.IPv6len), ipv4Prefix...), ipv4...)
 		}
 	}
 
-	prefixLen, r := t.root.get(lookupIP, 0)
+	prefixLen, r := root.get(lookupIP, 0)
 
 	// This is so that if you look up an IPv4 address in a database that has
 	// an IPv4 subtree, you will get back an IPv4 network. This matches what
 	// github.com/oschwald/maxminddb-golang does.
+	bits := treeDepth
 	if prefixLen >= 96 && len(ip) == 4 {
 		prefixLen -= 96
+		bits = 32
 	}
 
-	mask := net.CIDRMask(prefixLen, t.treeDepth)
+	mask := net.CIDRMask(prefixLen, bits)
 
 	var value mmdbtype.DataType
-	if r.recordType == recordTypeData {
-		value = r.value.data
+	found := r.recordType == recordTypeData
+	if found {
+		value = dm.load(r.value)
 	}
 
 	return &net.IPNet{
 		IP:   ip.Mask(mask),
 		Mask: mask,
-	}, value
+	}, value, found
 }
 
-// finalize prepares the tree for writing. It is not threadsafe.
-func (t *Tree) finalize() {
-	t.nodeCount = t.root.finalize(0)
+// Finalize prepares the tree for writing and returns the number of nodes in
+// the search tree, including any padding nodes added for
+// Options.DataSectionAlignment. It returns an error if the tree will not
+// fit in the record size or exceeds Options.MaxNodes or
+// Options.MaxDataSize.
+//
+// Calling Finalize is optional. The write methods, e.g., WriteTo, finalize
+// the tree themselves if it has been modified since it was last finalized.
+// Finalize allows the size of the tree to be checked before writing.
+//
+// This is not safe to call from multiple threads.
+func (t *Tree) Finalize() (int, error) {
+	if err := t.finalize(); err != nil {
+		return 0, err
+	}
+	return t.nodeCount, nil
 }
 
-// WriteTo writes the tree to the provided Writer.
-func (t *Tree) WriteTo(w io.Writer) (int64, error) {
+// finalize prepares the tree for writing. It returns an error if the tree
+// will not fit in the record size. It is not threadsafe.
+func (t *Tree) finalize() error {
+	start := time.Now()
+	defer func() {
+		t.report.FinalizeDuration = time.Since(start)
+		if t.metrics != nil {
+			t.metrics.ObserveFinalize(t.report.FinalizeDuration)
+		}
+	}()
+
+	if err := t.checkLimits(); err != nil {
+		return err
+	}
+
+	nodes := t.root.finalize(0)
+	t.paddingNodes = t.alignmentPadding(nodes)
+	t.nodeCount = nodes + t.paddingNodes
+
+	maxOffset := -1
+	if t.mustCheckDataOffsets() {
+		// We only need the offsets of the values.
+		dataWriter, _, err := t.newDataWriter(&countingBuffer{})
+		if err != nil {
+			t.nodeCount = 0
+			return err
+		}
+		maxOffset, err = t.maxDataOffset(t.root, make(net.IP, t.treeDepth/8), 0, dataWriter)
+		if err != nil {
+			t.nodeCount = 0
+			return err
+		}
+	}
+
+	for {
+		// Without data, the largest record value is that of an empty
+		// record, which is the node count.
+		maxRecord := t.nodeCount
+		if maxOffset >= 0 {
+			maxRecord = t.nodeCount + len(dataSectionSeparator) + maxOffset
+		}
+		if maxRecord < 1<<t.recordSize {
+			break
+		}
+
+		size := requiredRecordSize(maxRecord)
+		if !t.autoRecordSize || size == 0 {
+			// We reset the node count so that the check is done again if
+			// the caller tries to write the tree anyway.
+			t.nodeCount = 0
+			return recordSizeError(maxRecord, t.recordSize)
+		}
+		if t.logger != nil {
+			t.logger.Warn(
+				"increasing the record size to fit the database",
+				"record_size", size,
+				"previous_record_size", t.recordSize,
+				"max_record", maxRecord,
+			)
+		}
+		t.recordSize = size
+		// The padding depends on the size of the nodes.
+		t.paddingNodes = t.alignmentPadding(nodes)
+		t.nodeCount = nodes + t.paddingNodes
+	}
+	if t.logger != nil {
+		t.logger.Info(
+			"finalized tree",
+			"nodes", t.nodeCount,
+			"data_values", len(t.dataMap.data),
+			"duration", time.Since(start),
+		)
+	}
+	return nil
+}
+
+// checkLimits returns an error if the tree exceeds Options.MaxNodes or
+// Options.MaxDataSize.
+func (t *Tree) checkLimits() error {
+	if t.maxNodes > 0 && t.liveNodes > t.maxNodes {
+		return fmt.Errorf(
+			"the tree has %d nodes, which exceeds MaxNodes (%d): %w",
+			t.liveNodes,
+			t.maxNodes,
+			ErrLimitExceeded,
+		)
+	}
+	if t.maxDataSize > 0 && t.dataMap.size > t.maxDataSize {
+		return fmt.Errorf(
+			"the tree has %d bytes of data, which exceeds MaxDataSize (%d): %w",
+			t.dataMap.size,
+			t.maxDataSize,
+			ErrLimitExceeded,
+		)
+	}
+	return nil
+}
+
+// maxRecordSizeInserter wraps an inserter function so that it returns an
+// error if the encoded size of the value it returns exceeds maxSize.
+func maxRecordSizeInserter(
+	f inserter.Func,
+	network *net.IPNet,
+	maxSize int,
+	logger Logger,
+) inserter.Func {
+	return func(existing mmdbtype.DataType) (mmdbtype.DataType, error) {
+		v, err := f(existing)
+		if err != nil || v == nil {
+			return v, err
+		}
+		b, err := mmdbtype.Encode(v)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) > maxSize {
+			if logger != nil {
+				logger.Warn(
+					"record exceeds MaxRecordSize",
+					"network", network.String(),
+					"size", len(b),
+					"max_size", maxSize,
+				)
+			}
+			return nil, fmt.Errorf(
+				"the record for %s is %d bytes, which exceeds MaxRecordSize (%d): %w",
+				network,
+				len(b),
+				maxSize,
+				ErrLimitExceeded,
+			)
+		}
+		return v, nil
+	}
+}
+
+// alignmentPadding returns the number of nodes that must be added to a
+// search tree with nodeCount nodes to align the data section.
+func (t *Tree) alignmentPadding(nodeCount int) int {
+	if t.dataSectionAlignment <= 1 {
+		return 0
+	}
+	nodeSize := t.recordSize / 4
+	padding := 0
+	for ((nodeCount+padding)*nodeSize+len(dataSectionSeparator))%t.dataSectionAlignment != 0 {
+		padding++
+	}
+	return padding
+}
+
+// DataSectionOffset returns the offset of the data section from the start
+// of the database. The tree will be finalized if it has not been already.
+func (t *Tree) DataSectionOffset() (int64, error) {
 	if t.nodeCount == 0 {
-		t.finalize()
+		if err := t.finalize(); err != nil {
+			return 0, err
+		}
+	}
+	return int64(t.nodeCount*t.recordSize/4 + len(dataSectionSeparator)), nil
+}
+
+// mustCheckDataOffsets returns whether the data section offsets must be
+// checked when finalizing. The node count must be set. Unless the records
+// are transformed when written, the data section is no larger than the
+// total size of the distinct values, so the offsets only need to be checked
+// if that does not fit in the record size. The size of the transformed
+// records is only known once they are encoded, so they are always checked.
+func (t *Tree) mustCheckDataOffsets() bool {
+	if t.validateRecordSize {
+		return true
+	}
+	if len(t.enumFields) > 0 || len(t.dictionaryFields) > 0 || len(t.networkFields) > 0 {
+		return true
+	}
+	return t.nodeCount+len(dataSectionSeparator)+t.dataMap.size >= 1<<t.recordSize
+}
+
+// requiredRecordSize returns the smallest supported record size that
+// maxRecord fits in, or 0 if there is none.
+func requiredRecordSize(maxRecord int) int {
+	for _, size := range []int{24, 28, 32} {
+		if maxRecord < 1<<size {
+			return size
+		}
+	}
+	return 0
+}
+
+func recordSizeError(maxRecord, recordSize int) error {
+	if size := requiredRecordSize(maxRecord); size != 0 {
+		return fmt.Errorf(
+			"the database requires a record size of at least %d bits but RecordSize is %d; "+
+				"the largest record value is %d and the maximum for %d bits is %d. "+
+				"Increase RecordSize to %d or reduce the size of the database",
+			size,
+			recordSize,
+			maxRecord,
+			recordSize,
+			1<<recordSize-1,
+			size,
+		)
+	}
+	return fmt.Errorf(
+		"the largest record value, %d, exceeds the capacity of all supported record sizes; "+
+			"reduce the size of the database",
+		maxRecord,
+	)
+}
+
+// maxDataOffset returns the largest data section offset that a record in
+// the subtree will point to, or -1 if there are no data records. It writes
+// the data in the same order as WriteTo. The ip and depth are those of the
+// node.
+func (t *Tree) maxDataOffset(
+	n *node,
+	ip net.IP,
+	depth int,
+	dataWriter *dataWriter,
+) (int, error) {
+	maxOffset := -1
+	for i := 0; i < 2; i++ {
+		r := n.children[i]
+		if r.recordType != recordTypeData {
+			continue
+		}
+		if i == 1 {
+			setBitAt(ip, depth)
+		}
+		offset, err := dataWriter.writeRecord(t.dataMap.get(r.value), ip, depth+1)
+		if i == 1 {
+			clearBitAt(ip, depth)
+		}
+		if err != nil {
+			return 0, err
+		}
+		if offset > maxOffset {
+			maxOffset = offset
+		}
 	}
 
+	for i := 0; i < 2; i++ {
+		r := n.children[i]
+		if r.recordType != recordTypeNode && r.recordType != recordTypeFixedNode {
+			continue
+		}
+		if i == 1 {
+			setBitAt(ip, depth)
+		}
+		offset, err := t.maxDataOffset(r.node, ip, depth+1, dataWriter)
+		if i == 1 {
+			clearBitAt(ip, depth)
+		}
+		if err != nil {
+			return 0, err
+		}
+		if offset > maxOffset {
+			maxOffset = offset
+		}
+	}
+	return maxOffset, nil
+}
+
+// newDataWriter returns the dataWriter to use for the data section, which
+// is written to buf. The enum table of Options.EnumFields and the dictionary
+// of Options.DictionaryFields, if any, are written first. The returned map holds the entries to add to the metadata
+// so that readers can decode the records, e.g., the enum table.
+func (t *Tree) newDataWriter(buf dataBuffer) (*dataWriter, mmdbtype.Map, error) {
+	usePointers := !t.format.disablePointers
+	dataWriter := newDataWriter(t.dataMap, usePointers)
+	dataWriter.dataBuffer = buf
+	if len(t.mapKeyOrder) > 0 {
+		dataWriter.keyRanks = make(map[string]int, len(t.mapKeyOrder))
+		for i, key := range t.mapKeyOrder {
+			dataWriter.keyRanks[key] = i
+		}
+	}
+	if len(t.networkFields) > 0 {
+		dataWriter.annotate = t.annotateNetwork
+	}
+
+	metadata := mmdbtype.Map{}
+	var transforms []func(mmdbtype.DataType) mmdbtype.DataType
+	if len(t.enumFields) > 0 {
+		enumEnc, err := newEnumEncoder(t.enumFields, t.dataMap)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := enumEnc.write(dataWriter); err != nil {
+			return nil, nil, err
+		}
+		transforms = append(transforms, enumEnc.encode)
+		metadata[EnumTableKey] = enumEnc.metadataValue()
+	}
+	if len(t.dictionaryFields) > 0 {
+		dictEnc, err := newDictionaryEncoder(t.dictionaryFields, t.dataMap)
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := dictEnc.write(dataWriter); err != nil {
+			return nil, nil, err
+		}
+		transforms = append(transforms, dictEnc.encode)
+		metadata[DictionaryKey] = dictEnc.metadataValue()
+	}
+
+	switch len(transforms) {
+	case 0:
+	case 1:
+		dataWriter.transform = transforms[0]
+	default:
+		dataWriter.transform = func(v mmdbtype.DataType) mmdbtype.DataType {
+			for _, transform := range transforms {
+				v = transform(v)
+			}
+			return v
+		}
+	}
+	return dataWriter, metadata, nil
+}
+
+// WriteTo writes the tree to the provided Writer. The data for networks
+// whose expiration time has passed is removed first. See InsertWithExpiry.
+func (t *Tree) WriteTo(w io.Writer) (int64, error) {
+	var h hash.Hash
+	if t.embedChecksum {
+		h = sha256.New()
+	}
+	return t.writeTo(w, h)
+}
+
+// WriteToWithChecksum writes the tree to the provided Writer and returns the
+// SHA-256 digest of the search tree and data section, i.e., of everything
+// written before the metadata. This is the digest embedded in the metadata
+// when Options.EmbedChecksum is set.
+func (t *Tree) WriteToWithChecksum(w io.Writer) (int64, [sha256.Size]byte, error) {
+	var sum [sha256.Size]byte
+	h := sha256.New()
+	n, err := t.writeTo(w, h)
+	if err != nil {
+		return n, sum, err
+	}
+	copy(sum[:], h.Sum(nil))
+	return n, sum, nil
+}
+
+// WriteSections writes the sections of the database to separate writers:
+// the search tree, followed by the data section separator, to nodeW, the
+// data section to dataW, and the metadata, starting with the metadata
+// start marker, to metaW. Concatenating what was written to the three
+// writers, in that order, results in the same database as WriteTo. This
+// allows the sections to be stored separately, e.g., to deduplicate the
+// data sections of successive builds in content-addressed storage.
+//
+// As the records of the search tree point into the data section, a search
+// tree may only be reassembled with the data section written with it or
+// one that is identical to it. The number of bytes written to all of the
+// writers is returned.
+func (t *Tree) WriteSections(nodeW, dataW, metaW io.Writer) (int64, error) {
+	var h hash.Hash
+	if t.embedChecksum {
+		h = sha256.New()
+	}
+
+	nodeBuf := bufio.NewWriter(nodeW)
+	dataBuf := bufio.NewWriter(dataW)
+	metaBuf := bufio.NewWriter(metaW)
+	numBytes, err := t.writeSections(nodeBuf, dataBuf, metaBuf, h)
+	for _, buf := range []*bufio.Writer{nodeBuf, dataBuf, metaBuf} {
+		if flushErr := buf.Flush(); flushErr != nil && err == nil {
+			err = fmt.Errorf("flushing buffer to writer: %w", flushErr)
+		}
+	}
+	return numBytes, err
+}
+
+// writeTo writes the tree to w. If h is not nil, the search tree and data
+// section are also written to h.
+func (t *Tree) writeTo(w io.Writer, h hash.Hash) (int64, error) {
 	buf := bufio.NewWriter(w)
 	//nolint:errcheck // We check the error on flush the only place that matters.
 	defer buf.Flush()
 
+	numBytes, err := t.writeSections(buf, buf, buf, h)
+	if err != nil {
+		return numBytes, err
+	}
+
+	err = buf.Flush()
+	if err != nil {
+		return numBytes, fmt.Errorf("flushing buffer to writer: %w", err)
+	}
+	return numBytes, nil
+}
+
+// writeSections writes the search tree and data section separator to
+// nodeW, the data section to dataW, and the metadata to metaW. If h is not
+// nil, the search tree and data section are also written to h.
+func (t *Tree) writeSections(nodeW, dataW, metaW io.Writer, h hash.Hash) (int64, error) {
+	writeStart := time.Now()
+	if _, err := t.Expire(time.Now()); err != nil {
+		return 0, err
+	}
+
+	if t.nodeCount == 0 {
+		if err := t.finalize(); err != nil {
+			return 0, err
+		}
+	}
+
+	nodeSectionWriter := nodeW
+	dataSectionWriter := dataW
+	if h != nil {
+		nodeSectionWriter = io.MultiWriter(nodeW, h)
+		dataSectionWriter = io.MultiWriter(dataW, h)
+	}
+
+	enc, err := newRecordEncoder(t.recordSize)
+	if err != nil {
+		return 0, err
+	}
+
 	// We create this here so that we don't have to allocate millions of these. This
 	// may no longer make sense now that we are using a bufio.Writer anyway, which has
 	// WriteByte, but we should probably do some testing.
-	recordBuf := make([]byte, 2*t.recordSize/8)
+	recordBuf := make([]byte, enc.nodeSize())
+	ip := make(net.IP, t.treeDepth/8)
 
-	usePointers := true
-	dataWriter := newDataWriter(t.dataMap, usePointers)
+	var dataBuf dataBuffer = &bytes.Buffer{}
+	if t.dataSectionSpool != nil {
+		spool, err := t.dataSectionSpool()
+		if err != nil {
+			return 0, err
+		}
+		if c, ok := spool.(io.Closer); ok {
+			//nolint:errcheck // The spool is only read during the write.
+			defer c.Close()
+		}
+		dataBuf = newSpoolBuffer(spool)
+	}
+	dataWriter, dataMetadata, err := t.newDataWriter(dataBuf)
+	if err != nil {
+		return 0, err
+	}
 
-	nodeCount, numBytes, err := t.writeNode(buf, t.root, dataWriter, recordBuf)
+	start := time.Now()
+	nodeCount, numBytes, err := t.writeNode(nodeSectionWriter, enc, t.root, ip, 0, dataWriter, recordBuf)
 	if err != nil {
 		return numBytes, err
 	}
-	if nodeCount != t.nodeCount {
+	if nodeCount != t.nodeCount-t.paddingNodes {
 		// This should only happen if there is a programming bug
 		// in this library.
 		return numBytes, fmt.Errorf(
 			"number of nodes written (%d) doesn't match number expected (%d)",
 			nodeCount,
-			t.nodeCount,
+			t.nodeCount-t.paddingNodes,
 		)
 	}
 
-	nb, err := buf.Write(dataSectionSeparator)
+	if t.paddingNodes > 0 {
+		// The padding nodes only have empty records.
+		if err := t.copyNode(enc, recordBuf, &node{}, ip, 0, dataWriter); err != nil {
+			return numBytes, err
+		}
+		for i := 0; i < t.paddingNodes; i++ {
+			nb, err := nodeSectionWriter.Write(recordBuf)
+			numBytes += int64(nb)
+			if err != nil {
+				return numBytes, fmt.Errorf("writing padding node: %w", err)
+			}
+		}
+	}
+
+	nb, err := nodeSectionWriter.Write(dataSectionSeparator)
 	numBytes += int64(nb)
 	if err != nil {
 		return numBytes, fmt.Errorf("writing data section separator: %w", err)
 	}
 
-	nb64, err := dataWriter.WriteTo(buf)
+	t.report.SearchTreeDuration = time.Since(start)
+	if t.logger != nil {
+		t.logger.Debug(
+			"wrote search tree",
+			"nodes", t.nodeCount,
+			"duration", t.report.SearchTreeDuration,
+		)
+	}
+
+	start = time.Now()
+	nb64, err := dataWriter.WriteTo(dataSectionWriter)
 	numBytes += nb64
 	if err != nil {
 		return numBytes, err
 	}
+	t.report.DataSectionDuration = time.Since(start)
+	if t.logger != nil {
+		t.logger.Debug(
+			"wrote data section",
+			"bytes", nb64,
+			"duration", t.report.DataSectionDuration,
+		)
+	}
 
-	nb, err = buf.Write(metadataStartMarker)
-	numBytes += int64(nb)
+	start = time.Now()
+
+	usePointers := !t.disableMetadataPointers && !t.format.disablePointers
+	metadataWriter := newDataWriter(dataWriter.dataMap, usePointers)
+	var checksum []byte
+	if t.embedChecksum {
+		checksum = h.Sum(nil)
+	}
+	_, err = t.writeMetadata(metadataWriter, dataMetadata, checksum)
 	if err != nil {
-		return numBytes, fmt.Errorf("writing metadata start marker: %w", err)
+		return numBytes, fmt.Errorf("writing metadata: %w", err)
+	}
+	if size := len(metadataStartMarker) + metadataWriter.Len(); size > maxMetadataSize {
+		return numBytes, fmt.Errorf(
+			"the metadata is %d bytes, which exceeds the maximum of %d bytes that readers search for it",
+			size,
+			maxMetadataSize,
+		)
 	}
 
-	metadataWriter := newDataWriter(dataWriter.dataMap, !t.disableMetadataPointers)
-	_, err = t.writeMetadata(metadataWriter)
+	nb, err = metaW.Write(metadataStartMarker)
+	numBytes += int64(nb)
 	if err != nil {
-		return numBytes, fmt.Errorf("writing metadata: %w", err)
+		return numBytes, fmt.Errorf("writing metadata start marker: %w", err)
 	}
 
-	nb64, err = metadataWriter.WriteTo(buf)
+	nb64, err = metadataWriter.WriteTo(metaW)
 	numBytes += nb64
 	if err != nil {
 		return numBytes, fmt.Errorf("writing metadata to buffer: %w", err)
 	}
+	t.report.MetadataDuration = time.Since(start)
 
-	err = buf.Flush()
-	if err != nil {
-		return numBytes, fmt.Errorf("flushing buffer to writer: %w", err)
+	if t.metrics != nil {
+		t.metrics.ObserveWrite(numBytes, time.Since(writeStart))
+	}
+	if t.logger != nil {
+		t.logger.Info(
+			"wrote database",
+			"bytes", numBytes,
+			"duration", time.Since(writeStart),
+		)
 	}
 
-	return numBytes, err
+	return numBytes, nil
 }
 
 func (t *Tree) writeNode(
 	w io.Writer,
+	enc recordEncoder,
 	n *node,
+	ip net.IP,
+	depth int,
 	dataWriter *dataWriter,
 	recordBuf []byte,
 ) (int, int64, error) {
-	err := t.copyNode(recordBuf, n, dataWriter)
+	err := t.copyNode(enc, recordBuf, n, ip, depth, dataWriter)
 	if err != nil {
 		return 0, 0, err
 	}
@@ -530,12 +2076,21 @@ func (t *Tree) writeNode(
 		if child.recordType != recordTypeNode && child.recordType != recordTypeFixedNode {
 			continue
 		}
+		if i == 1 {
+			setBitAt(ip, depth)
+		}
 		addedNodes, addedBytes, err := t.writeNode(
 			w,
+			enc,
 			n.children[i].node,
+			ip,
+			depth+1,
 			dataWriter,
 			recordBuf,
 		)
+		if i == 1 {
+			clearBitAt(ip, depth)
+		}
 		nodesWritten += addedNodes
 		numBytes += addedBytes
 		if err != nil {
@@ -546,13 +2101,17 @@ func (t *Tree) writeNode(
 	return nodesWritten, numBytes, nil
 }
 
+// recordValue returns the value of the record. The ip and prefixLen are
+// those of the record's network.
 func (t *Tree) recordValue(
 	r record,
+	ip net.IP,
+	prefixLen int,
 	dataWriter *dataWriter,
 ) (int, error) {
 	switch r.recordType {
 	case recordTypeData:
-		offset, err := dataWriter.maybeWrite(r.value)
+		offset, err := dataWriter.writeRecord(t.dataMap.get(r.value), ip, prefixLen)
 		return t.nodeCount + len(dataSectionSeparator) + offset, err
 	case recordTypeEmpty, recordTypeReserved:
 		return t.nodeCount, nil
@@ -561,65 +2120,51 @@ func (t *Tree) recordValue(
 	}
 }
 
-func (t *Tree) copyNode(buf []byte, n *node, dataWriter *dataWriter) error {
-	left, err := t.recordValue(n.children[0], dataWriter)
+// copyNode encodes the node into buf. The ip and depth are those of the
+// node.
+func (t *Tree) copyNode(
+	enc recordEncoder,
+	buf []byte,
+	n *node,
+	ip net.IP,
+	depth int,
+	dataWriter *dataWriter,
+) error {
+	left, err := t.recordValue(n.children[0], ip, depth+1, dataWriter)
 	if err != nil {
 		return err
 	}
-	right, err := t.recordValue(n.children[1], dataWriter)
+	setBitAt(ip, depth)
+	right, err := t.recordValue(n.children[1], ip, depth+1, dataWriter)
+	clearBitAt(ip, depth)
 	if err != nil {
 		return err
 	}
+	return encodeNode(enc, buf, left, right)
+}
 
-	maxRecord := 1 << t.recordSize
-	if left >= maxRecord || right >= maxRecord {
-		return fmt.Errorf(
-			"exceeded record capacity by attempting to write (%d, %d) to node with %d bit record size; "+
-				"try increasing RecordSize or reducing the size of the database",
-			left,
-			right,
-			t.recordSize,
-		)
-	}
+var v4Prefix = net.IP{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
 
-	switch t.recordSize {
-	case 24:
-		buf[0] = byte((left >> 16) & 0xFF)
-		buf[1] = byte((left >> 8) & 0xFF)
-		buf[2] = byte(left & 0xFF)
-		buf[3] = byte((right >> 16) & 0xFF)
-		buf[4] = byte((right >> 8) & 0xFF)
-		buf[5] = byte(right & 0xFF)
-	case 28:
-		buf[0] = byte((left >> 16) & 0xFF)
-		buf[1] = byte((left >> 8) & 0xFF)
-		buf[2] = byte(left & 0xFF)
-		buf[3] = byte((((left >> 24) & 0x0F) << 4) | (right >> 24 & 0x0F))
-		buf[4] = byte((right >> 16) & 0xFF)
-		buf[5] = byte((right >> 8) & 0xFF)
-		buf[6] = byte(right & 0xFF)
-	case 32:
-		buf[0] = byte((left >> 24) & 0xFF)
-		buf[1] = byte((left >> 16) & 0xFF)
-		buf[2] = byte((left >> 8) & 0xFF)
-		buf[3] = byte(left & 0xFF)
-		buf[4] = byte((right >> 24) & 0xFF)
-		buf[5] = byte((right >> 16) & 0xFF)
-		buf[6] = byte((right >> 8) & 0xFF)
-		buf[7] = byte(right & 0xFF)
-	default:
-		return fmt.Errorf("unsupported record size of %d", t.recordSize)
+// unmapIPv4 returns the IPv4 form of an IPv4-mapped IPv6 network, e.g.,
+// 1.1.1.0/24 for ::ffff:1.1.1.0/120. This allows IPv4 trees to be built
+// from data that uses IPv4-mapped notation. Other networks are returned
+// unchanged.
+func unmapIPv4(ip net.IP, prefixLen int) (net.IP, int) {
+	if len(ip) == net.IPv6len && prefixLen >= 96 && ip.To4() != nil {
+		return ip[12:], prefixLen - 96
 	}
-	return nil
+	return ip, prefixLen
 }
 
-var v4Prefix = net.IP{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
-
 func ipV4ToV6(ip net.IP) net.IP {
 	return append(v4Prefix, ip...)
 }
 
-func (t *Tree) writeMetadata(dw *dataWriter) (int64, error) {
+func (t *Tree) writeMetadata(
+	dw *dataWriter,
+	dataMetadata mmdbtype.Map,
+	checksum []byte,
+) (int64, error) {
 	description := mmdbtype.Map{}
 	for k, v := range t.description {
 		description[mmdbtype.String(k)] = mmdbtype.String(v)
@@ -630,8 +2175,8 @@ func (t *Tree) writeMetadata(dw *dataWriter) (int64, error) {
 		languages = append(languages, mmdbtype.String(v))
 	}
 	metadata := mmdbtype.Map{
-		"binary_format_major_version": mmdbtype.Uint16(2),
-		"binary_format_minor_version": mmdbtype.Uint16(0),
+		"binary_format_major_version": mmdbtype.Uint16(t.format.majorVersion),
+		"binary_format_minor_version": mmdbtype.Uint16(t.format.minorVersion),
 		"build_epoch":                 mmdbtype.Uint64(t.buildEpoch),
 		"database_type":               mmdbtype.String(t.databaseType),
 		"description":                 description,
@@ -640,5 +2185,18 @@ func (t *Tree) writeMetadata(dw *dataWriter) (int64, error) {
 		"node_count":                  mmdbtype.Uint32(t.nodeCount),
 		"record_size":                 mmdbtype.Uint16(t.recordSize),
 	}
+	for k, v := range dataMetadata {
+		metadata[k] = v
+	}
+	if checksum != nil {
+		metadata[ChecksumKey] = mmdbtype.String(hex.EncodeToString(checksum))
+	}
+	if t.metadataHook != nil {
+		var err error
+		metadata, err = applyMetadataHook(t.metadataHook, metadata)
+		if err != nil {
+			return 0, err
+		}
+	}
 	return metadata.WriteTo(dw)
 }
@@ -6,6 +6,7 @@ import (
 	"math/big"
 	"net"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -519,7 +520,7 @@ func TestTreeInsertAndGet(t *testing.T) {
 						}
 					}
 
-					tree.finalize()
+					require.NoError(t, tree.finalize())
 
 					for _, get := range test.gets {
 						network, value := tree.Get(net.ParseIP(get.ip))
@@ -645,7 +646,1022 @@ func TestInsertFunc_RemovalAndLaterInsert(t *testing.T) {
 	assert.Nil(t, recValue)
 }
 
+func TestGetIPv4(t *testing.T) {
+	tree, err := New(Options{})
+	require.NoError(t, err)
+
+	_, network, err := net.ParseCIDR("1.1.1.0/24")
+	require.NoError(t, err)
+	require.NoError(t, tree.Insert(network, mmdbtype.String("value")))
+
+	recNetwork, recValue := tree.Get(net.ParseIP("1.1.1.1").To4())
+	assert.Equal(t, "1.1.1.0/24", recNetwork.String())
+	assert.Equal(t, mmdbtype.String("value"), recValue)
+}
+
+func TestInsertMany(t *testing.T) {
+	var networks []*net.IPNet
+	for i := 0; i < 256; i++ {
+		networks = append(networks, &net.IPNet{
+			IP:   net.IPv4(1, 1, 1, byte(i)).To4(),
+			Mask: net.CIDRMask(32, 32),
+		})
+	}
+	networks = append(networks, mustNetwork(t, "1.1.1.0/25"), mustNetwork(t, "2.0.0.0/8"))
+
+	tree, err := New(Options{IPVersion: 4})
+	require.NoError(t, err)
+	require.NoError(t, tree.InsertMany(networks, mmdbtype.String("value")))
+	assert.Equal(t, 2, tree.report.Inserts, "collapsed to 1.1.1.0/24 and 2.0.0.0/8")
+
+	var got []string
+	require.NoError(t, tree.Walk(func(network *net.IPNet, value mmdbtype.DataType) (bool, error) {
+		if value != nil {
+			got = append(got, network.String())
+		}
+		return true, nil
+	}))
+	assert.Equal(t, []string{"1.1.1.0/24", "2.0.0.0/8"}, got)
+
+	individual, err := New(Options{IPVersion: 4})
+	require.NoError(t, err)
+	for _, network := range networks {
+		require.NoError(t, individual.Insert(network, mmdbtype.String("value")))
+	}
+	var want, gotBuf bytes.Buffer
+	individual.buildEpoch = 1
+	tree.buildEpoch = 1
+	_, err = individual.WriteTo(&want)
+	require.NoError(t, err)
+	_, err = tree.WriteTo(&gotBuf)
+	require.NoError(t, err)
+	assert.Equal(t, want.Bytes(), gotBuf.Bytes())
+
+	err = tree.InsertMany([]*net.IPNet{nil}, mmdbtype.String("value"))
+	assert.EqualError(t, err, "the network is nil")
+}
+
+func TestGetRecord(t *testing.T) {
+	tree, err := New(Options{})
+	require.NoError(t, err)
+
+	require.NoError(t, tree.Insert(mustNetwork(t, "1.1.1.0/24"), mmdbtype.Map{}))
+	require.NoError(t, tree.Insert(mustNetwork(t, "2003::/16"), mmdbtype.String("value")))
+
+	tests := []struct {
+		ip      string
+		network string
+		value   mmdbtype.DataType
+		found   bool
+	}{
+		{ip: "1.1.1.1", network: "1.1.1.0/24", value: mmdbtype.Map{}, found: true},
+		{ip: "2003::1", network: "2003::/16", value: mmdbtype.String("value"), found: true},
+		{ip: "1.1.0.1", network: "1.1.0.0/24"},
+		{ip: "10.0.0.1", network: "10.0.0.0/8"},
+	}
+	for _, test := range tests {
+		t.Run(test.ip, func(t *testing.T) {
+			ip := net.ParseIP(test.ip)
+			if ipv4 := ip.To4(); ipv4 != nil {
+				ip = ipv4
+			}
+			network, value, found := tree.GetRecord(ip)
+			assert.Equal(t, test.network, network.String())
+			assert.Equal(t, test.value, value)
+			assert.Equal(t, test.found, found)
+
+			getNetwork, getValue := tree.Get(ip)
+			assert.Equal(t, network, getNetwork)
+			assert.Equal(t, value, getValue)
+		})
+	}
+}
+
+func TestInsertCanonical(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    Options
+		network string
+		want    string
+	}{
+		{name: "IPv6", network: "2003::1/16", want: "2003::/16"},
+		{name: "IPv4 in IPv6 tree", network: "1.1.1.1/24", want: "::101:100/120"},
+		{name: "IPv4 tree", opts: Options{IPVersion: 4, RecordSize: 24}, network: "1.1.1.1/24", want: "1.1.1.0/24"},
+		{
+			name:    "IPv4-mapped in IPv4 tree",
+			opts:    Options{IPVersion: 4, RecordSize: 24},
+			network: "::ffff:1.1.1.0/120",
+			want:    "1.1.1.0/24",
+		},
+		{
+			name: "truncated",
+			opts: Options{
+				MaxIPv4PrefixLength: 24,
+				PrefixLengthPolicy:  PrefixLengthTruncate,
+			},
+			network: "1.1.1.128/25",
+			want:    "::101:100/120",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tree, err := New(test.opts)
+			require.NoError(t, err)
+
+			network, err := tree.InsertCanonical(mustNetwork(t, test.network), mmdbtype.String("a"))
+			require.NoError(t, err)
+			assert.Equal(t, test.want, network.String())
+
+			getNetwork, value := tree.Get(network.IP)
+			assert.Equal(t, network, getNetwork)
+			assert.Equal(t, mmdbtype.String("a"), value)
+		})
+	}
+
+	tree, err := New(Options{IPv6Only: true})
+	require.NoError(t, err)
+	network, err := tree.InsertCanonical(mustNetwork(t, "1.1.1.0/24"), mmdbtype.String("a"))
+	assert.Error(t, err)
+	assert.Nil(t, network)
+}
+
+// TestInsertSplit tests that splitting a record keeps a reference to its data
+// and that the split is undone when the inserted data is the same.
+func TestWriteSections(t *testing.T) {
+	for _, embedChecksum := range []bool{false, true} {
+		t.Run(fmt.Sprintf("EmbedChecksum %t", embedChecksum), func(t *testing.T) {
+			tree, err := New(Options{BuildEpoch: 1, EmbedChecksum: embedChecksum})
+			require.NoError(t, err)
+			for i, network := range []string{"1.1.1.0/24", "2003::/16"} {
+				require.NoError(t, tree.Insert(mustNetwork(t, network), mmdbtype.Uint32(i)))
+			}
+
+			var want bytes.Buffer
+			wantBytes, err := tree.WriteTo(&want)
+			require.NoError(t, err)
+
+			var nodes, data, metadata bytes.Buffer
+			n, err := tree.WriteSections(&nodes, &data, &metadata)
+			require.NoError(t, err)
+			assert.Equal(t, wantBytes, n)
+
+			assert.True(t, bytes.HasSuffix(nodes.Bytes(), dataSectionSeparator))
+			assert.True(t, bytes.HasPrefix(metadata.Bytes(), metadataStartMarker))
+
+			db := append(append(nodes.Bytes(), data.Bytes()...), metadata.Bytes()...)
+			assert.Equal(t, want.Bytes(), db)
+
+			reader, err := maxminddb.FromBytes(db)
+			require.NoError(t, err)
+			var record uint32
+			require.NoError(t, reader.Lookup(net.ParseIP("2003::1"), &record))
+			assert.Equal(t, uint32(1), record)
+		})
+	}
+}
+
+func TestInsertSplit(t *testing.T) {
+	tree, err := New(Options{IPVersion: 4})
+	require.NoError(t, err)
+
+	_, network, err := net.ParseCIDR("1.1.1.0/24")
+	require.NoError(t, err)
+	require.NoError(t, tree.Insert(network, mmdbtype.String("a")))
+	nodes := tree.liveNodes
+
+	_, subnet, err := net.ParseCIDR("1.1.1.0/25")
+	require.NoError(t, err)
+	require.NoError(t, tree.Insert(subnet, mmdbtype.String("a")))
+	assert.Equal(t, nodes, tree.liveNodes, "split is merged")
+	assert.Len(t, tree.dataMap.data, 1)
+
+	require.NoError(t, tree.Insert(subnet, mmdbtype.String("b")))
+	assert.Equal(t, nodes+1, tree.liveNodes)
+	assert.Len(t, tree.dataMap.data, 2)
+	assert.Equal(t, 4, tree.dataMap.size)
+	assertRefCounts(t, tree)
+
+	_, value := tree.Get(net.ParseIP("1.1.1.200").To4())
+	assert.Equal(t, mmdbtype.String("a"), value)
+}
+
+// TestInsertAggregation tests that adjacent networks with equal data are
+// aggregated into the largest possible networks as they are inserted,
+// regardless of the insert order or whether they come from a range.
+func TestInsertAggregation(t *testing.T) {
+	quarters := []string{"1.1.1.0/26", "1.1.1.64/26", "1.1.1.128/26", "1.1.1.192/26"}
+
+	tests := []struct {
+		name    string
+		inserts func(t *testing.T, tree *Tree)
+	}{
+		{
+			name: "in order",
+			inserts: func(t *testing.T, tree *Tree) {
+				for _, network := range quarters {
+					insert(t, tree, network, "a")
+				}
+			},
+		},
+		{
+			name: "out of order",
+			inserts: func(t *testing.T, tree *Tree) {
+				for _, i := range []int{2, 0, 3, 1} {
+					insert(t, tree, quarters[i], "a")
+				}
+			},
+		},
+		{
+			name: "ranges",
+			inserts: func(t *testing.T, tree *Tree) {
+				require.NoError(t, tree.InsertRange(
+					net.ParseIP("1.1.1.100"), net.ParseIP("1.1.1.255"), mmdbtype.String("a"),
+				))
+				require.NoError(t, tree.InsertRange(
+					net.ParseIP("1.1.1.0"), net.ParseIP("1.1.1.99"), mmdbtype.String("a"),
+				))
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tree, err := New(Options{IPVersion: 4})
+			require.NoError(t, err)
+
+			test.inserts(t, tree)
+
+			network, value := tree.Get(net.ParseIP("1.1.1.1").To4())
+			assert.Equal(t, "1.1.1.0/24", network.String())
+			assert.Equal(t, mmdbtype.String("a"), value)
+			assertAggregated(t, tree.root)
+			assertRefCounts(t, tree)
+		})
+	}
+}
+
+// assertAggregated asserts that no node in the subtree has children that
+// could be merged.
+func assertAggregated(t *testing.T, n *node) {
+	for _, r := range n.children {
+		if r.recordType != recordTypeNode && r.recordType != recordTypeFixedNode {
+			continue
+		}
+		if r.recordType == recordTypeNode {
+			children := r.node.children
+			mergeable := children[0].recordType == children[1].recordType &&
+				(children[0].recordType == recordTypeEmpty ||
+					children[0].recordType == recordTypeReserved ||
+					(children[0].recordType == recordTypeData &&
+						children[0].value == children[1].value))
+			assert.False(t, mergeable, "node with mergeable children")
+		}
+		assertAggregated(t, r.node)
+	}
+}
+
+func TestInsertIPv4Mapped(t *testing.T) {
+	tree, err := New(Options{IPVersion: 4})
+	require.NoError(t, err)
+
+	insert(t, tree, "::ffff:1.1.1.0/120", "a")
+	require.NoError(t, tree.InsertRange(
+		net.ParseIP("::ffff:2.2.2.0"),
+		net.ParseIP("::ffff:2.2.2.255"),
+		mmdbtype.String("b"),
+	))
+
+	network, value := tree.Get(net.ParseIP("1.1.1.1").To4())
+	assert.Equal(t, "1.1.1.0/24", network.String())
+	assert.Equal(t, mmdbtype.String("a"), value)
+
+	network, value = tree.Get(net.ParseIP("2.2.2.2").To4())
+	assert.Equal(t, "2.2.2.0/24", network.String())
+	assert.Equal(t, mmdbtype.String("b"), value)
+
+	_, network, err = net.ParseCIDR("2600::/32")
+	require.NoError(t, err)
+	err = tree.Insert(network, mmdbtype.String("c"))
+	assert.EqualError(t, err, "2600::/32 is not a valid network for an IPv4 tree")
+
+	builder, err := NewParallelBuilder(Options{IPVersion: 4}, ParallelOptions{})
+	require.NoError(t, err)
+	records := make(chan NetworkRecord, 1)
+	_, network, err = net.ParseCIDR("::ffff:1.1.1.0/120")
+	require.NoError(t, err)
+	records <- NetworkRecord{Network: network, Value: mmdbtype.String("a")}
+	close(records)
+	parallel, err := builder.Build(records)
+	require.NoError(t, err)
+
+	network, value = parallel.Get(net.ParseIP("1.1.1.1").To4())
+	assert.Equal(t, "1.1.1.0/24", network.String())
+	assert.Equal(t, mmdbtype.String("a"), value)
+}
+
+func TestInsertFillGaps(t *testing.T) {
+	tree, err := New(Options{IPVersion: 4})
+	require.NoError(t, err)
+
+	_, network, err := net.ParseCIDR("1.1.1.128/25")
+	require.NoError(t, err)
+	require.NoError(t, tree.Insert(network, mmdbtype.String("high")))
+
+	_, supernet, err := net.ParseCIDR("1.1.0.0/16")
+	require.NoError(t, err)
+	require.NoError(t, tree.InsertFunc(supernet, inserter.FillGapsWith(mmdbtype.String("low"))))
+
+	tests := []struct {
+		ip              string
+		expectedNetwork string
+		expectedValue   mmdbtype.DataType
+	}{
+		{ip: "1.1.1.200", expectedNetwork: "1.1.1.128/25", expectedValue: mmdbtype.String("high")},
+		{ip: "1.1.1.1", expectedNetwork: "1.1.1.0/25", expectedValue: mmdbtype.String("low")},
+		{ip: "1.1.5.5", expectedNetwork: "1.1.4.0/22", expectedValue: mmdbtype.String("low")},
+		{ip: "1.2.0.0", expectedNetwork: "1.2.0.0/15", expectedValue: nil},
+	}
+	for _, test := range tests {
+		network, value := tree.Get(net.ParseIP(test.ip).To4())
+		assert.Equal(t, test.expectedNetwork, network.String(), test.ip)
+		assert.Equal(t, test.expectedValue, value, test.ip)
+	}
+}
+
+// assertRefCounts checks that the reference counts in the tree's dataMap
+// match the number of records referencing each value.
+func assertRefCounts(t *testing.T, tree *Tree) {
+	counts := map[valueRef]uint32{}
+	var count func(n *node)
+	count = func(n *node) {
+		for _, r := range n.children {
+			switch r.recordType {
+			case recordTypeData:
+				counts[r.value]++
+			case recordTypeNode, recordTypeFixedNode:
+				count(r.node)
+			default:
+			}
+		}
+	}
+	count(tree.root)
+
+	assert.Equal(t, len(tree.dataMap.data), len(counts))
+	for ref, c := range counts {
+		v := tree.dataMap.get(ref)
+		require.NotNil(t, v)
+		assert.Equal(t, c, v.refCount)
+		assert.Same(t, v, tree.dataMap.data[v.key])
+	}
+}
+
 func s2ip(v string) *any { //nolint:gocritic // test
 	i := any(v)
 	return &i
 }
+
+func TestFloatDecimalPlaces(t *testing.T) {
+	tree, err := New(
+		Options{
+			FloatDecimalPlaces:      2,
+			IncludeReservedNetworks: true,
+		},
+	)
+	require.NoError(t, err)
+
+	original := mmdbtype.Map{
+		"location": mmdbtype.Map{
+			"latitude":  mmdbtype.Float64(47.61234),
+			"longitude": mmdbtype.Float32(-122.33671),
+		},
+		"name": mmdbtype.String("Seattle"),
+	}
+	originalCopy := original.Copy()
+
+	_, network, err := net.ParseCIDR("1.1.1.0/24")
+	require.NoError(t, err)
+	require.NoError(t, tree.Insert(network, original))
+
+	_, network, err = net.ParseCIDR("1.1.0.0/24")
+	require.NoError(t, err)
+	require.NoError(t, tree.Insert(network, mmdbtype.Map{
+		"location": mmdbtype.Map{
+			"latitude":  mmdbtype.Float64(47.6149),
+			"longitude": mmdbtype.Float32(-122.3354),
+		},
+		"name": mmdbtype.String("Seattle"),
+	}))
+
+	assert.Equal(t, originalCopy, original, "inserted value is not modified")
+
+	expected := mmdbtype.Map{
+		"location": mmdbtype.Map{
+			"latitude":  mmdbtype.Float64(47.61),
+			"longitude": mmdbtype.Float32(-122.34),
+		},
+		"name": mmdbtype.String("Seattle"),
+	}
+
+	network, value := tree.Get(net.ParseIP("1.1.1.1"))
+	assert.Equal(t, expected, value)
+	assert.Equal(t, "1.1.0.0/23", network.String(), "rounded records are merged")
+	assert.Len(t, tree.dataMap.data, 1)
+}
+
+func TestFloatDecimalPlacesInvalid(t *testing.T) {
+	for _, places := range []int{-1, 16, 309} {
+		_, err := New(Options{FloatDecimalPlaces: places})
+		assert.EqualError(
+			t,
+			err,
+			fmt.Sprintf("FloatDecimalPlaces must be between 0 and 15: %d", places),
+		)
+	}
+
+	_, err := New(Options{FloatDecimalPlaces: 15})
+	require.NoError(t, err)
+}
+
+func TestInsertTemplated(t *testing.T) {
+	tree, err := New(Options{IPVersion: 4, FloatDecimalPlaces: 1})
+	require.NoError(t, err)
+
+	template := mmdbtype.Map{
+		"city":     mmdbtype.Map{"names": mmdbtype.Map{"en": mmdbtype.String("Berlin")}},
+		"location": mmdbtype.Map{"latitude": mmdbtype.Float64(52.5), "longitude": mmdbtype.Float64(13.4)},
+	}
+	overrides := map[string]mmdbtype.Map{
+		"1.1.1.0/24": nil,
+		"2.2.2.0/24": {"location": mmdbtype.Map{"latitude": mmdbtype.Float64(52.61)}},
+		"3.3.3.0/24": {"location": mmdbtype.Map{"latitude": mmdbtype.Float64(52.58)}},
+	}
+	for network, o := range overrides {
+		require.NoError(t, tree.Insert(
+			mustNetwork(t, network),
+			mmdbtype.Templated{Template: template, Overrides: o},
+		))
+	}
+	assert.Len(t, tree.dataMap.data, 2, "the rounded overrides are deduplicated")
+
+	_, value := tree.Get(net.ParseIP("2.2.2.2").To4())
+	require.IsType(t, mmdbtype.Templated{}, value)
+	assert.Equal(
+		t,
+		mmdbtype.Map{"location": mmdbtype.Map{"latitude": mmdbtype.Float64(52.6)}},
+		value.(mmdbtype.Templated).Overrides,
+	)
+
+	buf := &bytes.Buffer{}
+	_, err = tree.WriteTo(buf)
+	require.NoError(t, err)
+
+	reader, err := maxminddb.FromBytes(buf.Bytes())
+	require.NoError(t, err)
+
+	var record map[string]any
+	require.NoError(t, reader.Lookup(net.ParseIP("1.1.1.1"), &record))
+	assert.Equal(
+		t,
+		map[string]any{
+			"city":     map[string]any{"names": map[string]any{"en": "Berlin"}},
+			"location": map[string]any{"latitude": 52.5, "longitude": 13.4},
+		},
+		record,
+	)
+
+	record = nil
+	require.NoError(t, reader.Lookup(net.ParseIP("3.3.3.3"), &record))
+	assert.Equal(
+		t,
+		map[string]any{
+			"city":     map[string]any{"names": map[string]any{"en": "Berlin"}},
+			"location": map[string]any{"latitude": 52.6},
+		},
+		record,
+	)
+}
+
+func TestInsertReader(t *testing.T) {
+	source, err := New(Options{DatabaseType: "mmdbwriter-test"})
+	require.NoError(t, err)
+
+	for _, insert := range []testInsert{
+		{network: "1.1.1.0/24", value: mmdbtype.String("one")},
+		{network: "2.2.2.0/24", value: mmdbtype.String("two")},
+		{network: "2003::/16", value: mmdbtype.String("three")},
+		{network: "2.2.3.0/24", value: mmdbtype.Map{"a": mmdbtype.Uint32(1)}},
+		{network: "1.1.0.0/24", value: mmdbtype.Slice{mmdbtype.Bool(true)}},
+		{network: "2003:1::/32", value: mmdbtype.Float64(1.5)},
+	} {
+		_, ipNet, err := net.ParseCIDR(insert.network)
+		require.NoError(t, err)
+		require.NoError(t, source.Insert(ipNet, insert.value))
+	}
+
+	buf := &bytes.Buffer{}
+	_, err = source.WriteTo(buf)
+	require.NoError(t, err)
+
+	reader, err := maxminddb.FromBytes(buf.Bytes())
+	require.NoError(t, err)
+
+	t.Run("all networks", func(t *testing.T) {
+		tree, err := New(Options{})
+		require.NoError(t, err)
+		require.NoError(t, tree.InsertReader(reader))
+
+		for _, ip := range []string{"1.1.1.1", "1.1.0.1", "2.2.2.2", "2.2.3.3", "2003::", "2003:1::"} {
+			_, expected := source.Get(net.ParseIP(ip))
+			_, actual := tree.Get(net.ParseIP(ip))
+			assert.Equal(t, expected, actual, "value for %s", ip)
+		}
+	})
+
+	t.Run("restricted to networks", func(t *testing.T) {
+		tree, err := New(Options{})
+		require.NoError(t, err)
+
+		_, within1, err := net.ParseCIDR("1.1.0.0/16")
+		require.NoError(t, err)
+		_, within2, err := net.ParseCIDR("2003:1::/32")
+		require.NoError(t, err)
+		// This is within a larger network in the reader.
+		_, within3, err := net.ParseCIDR("2003:2::/32")
+		require.NoError(t, err)
+		require.NoError(t, tree.InsertReader(reader, within1, within2, within3))
+
+		network, value := tree.Get(net.ParseIP("2003:2::"))
+		assert.Equal(t, "2003:2::/32", network.String())
+		assert.Equal(t, mmdbtype.String("three"), value)
+
+		_, value = tree.Get(net.ParseIP("1.1.1.1"))
+		assert.Equal(t, mmdbtype.String("one"), value)
+		_, value = tree.Get(net.ParseIP("1.1.0.1"))
+		assert.Equal(t, mmdbtype.Slice{mmdbtype.Bool(true)}, value)
+		_, value = tree.Get(net.ParseIP("2003:1::"))
+		assert.Equal(t, mmdbtype.Float64(1.5), value)
+
+		for _, ip := range []string{"2.2.2.2", "2.2.3.3", "2003::"} {
+			_, value := tree.Get(net.ParseIP(ip))
+			assert.Nil(t, value, "value for %s", ip)
+		}
+	})
+}
+
+func TestValidateRecordSize(t *testing.T) {
+	recordSizeErr := "^the database requires a record size of at least 28 bits but RecordSize is 24; " +
+		`the largest record value is \d+ and the maximum for 24 bits is 16777215. ` +
+		"Increase RecordSize to 28 or reduce the size of the database$"
+
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{name: "ValidateRecordSize", opts: Options{ValidateRecordSize: true}},
+		// The size of the values shows that the offsets must be checked.
+		{name: "default"},
+		// The size of the data section is not bounded by the values, so
+		// the offsets are always checked.
+		{name: "EnumFields", opts: Options{EnumFields: []string{"enum"}}},
+		{name: "DictionaryFields", opts: Options{DictionaryFields: []string{"dict"}}},
+		{
+			name: "EnumFields with ValidateRecordSize",
+			opts: Options{EnumFields: []string{"enum"}, ValidateRecordSize: true},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.opts.RecordSize = 24
+			tree, err := New(test.opts)
+			require.NoError(t, err)
+
+			// The second record will be at an offset greater than 2^24.
+			for _, insert := range []testInsert{
+				{network: "1.1.1.0/24", value: make(mmdbtype.Bytes, 1<<24)},
+				{network: "2.2.2.0/24", value: mmdbtype.String("after")},
+			} {
+				_, network, err := net.ParseCIDR(insert.network)
+				require.NoError(t, err)
+				require.NoError(t, tree.Insert(network, insert.value))
+			}
+
+			_, err = tree.Finalize()
+			require.Error(t, err)
+			assert.Regexp(t, recordSizeErr, err.Error())
+
+			buf := &bytes.Buffer{}
+			_, err = tree.WriteTo(buf)
+			require.Error(t, err)
+			assert.Regexp(t, recordSizeErr, err.Error())
+			assert.Zero(t, buf.Len(), "nothing was written")
+		})
+	}
+}
+
+func TestAutoRecordSize(t *testing.T) {
+	l := &recordingLogger{}
+	tree, err := New(Options{RecordSize: 24, AutoRecordSize: true, Logger: l})
+	require.NoError(t, err)
+
+	insert(t, tree, "1.1.1.0/24", "a")
+	require.NoError(t, tree.Insert(mustNetwork(t, "2.2.2.0/24"), make(mmdbtype.Bytes, 1<<24)))
+	insert(t, tree, "3.3.3.0/24", "after")
+
+	buf := &bytes.Buffer{}
+	_, err = tree.WriteTo(buf)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"increasing the record size to fit the database"}, l.messages("warn"))
+	assert.Equal(t, 28, tree.options().RecordSize)
+
+	reader, err := maxminddb.FromBytes(buf.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, uint(28), reader.Metadata.RecordSize)
+	var value string
+	require.NoError(t, reader.Lookup(net.ParseIP("3.3.3.3"), &value))
+	assert.Equal(t, "after", value)
+}
+
+func TestRecordSizeError(t *testing.T) {
+	assert.EqualError(
+		t,
+		recordSizeError(1<<24, 24),
+		"the database requires a record size of at least 28 bits but RecordSize is 24; "+
+			"the largest record value is 16777216 and the maximum for 24 bits is 16777215. "+
+			"Increase RecordSize to 28 or reduce the size of the database",
+	)
+	assert.EqualError(
+		t,
+		recordSizeError(1<<32, 32),
+		"the largest record value, 4294967296, exceeds the capacity of all supported record sizes; "+
+			"reduce the size of the database",
+	)
+}
+
+func TestBuildTime(t *testing.T) {
+	buildTime := time.Date(2022, 11, 1, 12, 30, 15, 500, time.UTC)
+
+	tree, err := New(Options{BuildTime: buildTime})
+	require.NoError(t, err)
+	assert.True(t, buildTime.Truncate(time.Second).Equal(tree.BuildTime()))
+
+	tree, err = New(Options{BuildTime: buildTime, BuildEpoch: buildTime.Unix()})
+	require.NoError(t, err)
+	assert.Equal(t, buildTime.Unix(), tree.BuildTime().Unix())
+
+	tree, err = New(Options{BuildEpoch: 1000})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1000), tree.BuildTime().Unix())
+
+	_, err = New(Options{BuildTime: buildTime, BuildEpoch: 1000})
+	assert.EqualError(
+		t,
+		err,
+		"BuildTime (2022-11-01 12:30:15.0000005 +0000 UTC) and BuildEpoch (1000) are both set but do not match",
+	)
+
+	_, err = New(Options{BuildEpoch: -1})
+	assert.EqualError(t, err, "BuildEpoch must not be negative: -1")
+
+	_, err = New(Options{BuildTime: time.Date(1969, 1, 1, 0, 0, 0, 0, time.UTC)})
+	assert.EqualError(t, err, "BuildTime must not be before the Unix epoch: 1969-01-01 00:00:00 +0000 UTC")
+
+	tree, err = New(Options{BuildTime: buildTime, DatabaseType: "mmdbwriter-test"})
+	require.NoError(t, err)
+	buf := &bytes.Buffer{}
+	_, err = tree.WriteTo(buf)
+	require.NoError(t, err)
+
+	reader, err := maxminddb.FromBytes(buf.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, uint(buildTime.Unix()), reader.Metadata.BuildEpoch)
+}
+
+func TestLanguageValidation(t *testing.T) {
+	tests := []struct {
+		name              string
+		opts              Options
+		expectedLanguages []string
+		expectedErr       string
+	}{
+		{
+			name: "description without languages",
+			opts: Options{Description: map[string]string{"en": "Test"}},
+		},
+		{
+			name: "description languages in languages",
+			opts: Options{
+				Description: map[string]string{"en": "Test", "pt-BR": "Teste"},
+				Languages:   []string{"en", "pt-BR", "zh-CN"},
+			},
+			expectedLanguages: []string{"en", "pt-BR", "zh-CN"},
+		},
+		{
+			name: "description language not in languages",
+			opts: Options{
+				Description: map[string]string{"en": "Test", "de": "Test"},
+				Languages:   []string{"en"},
+			},
+			expectedErr: `the Description language "de" is not in Languages; ` +
+				"add it to Languages or set PopulateLanguagesFromDescription",
+		},
+		{
+			name: "populate languages from description",
+			opts: Options{
+				Description:                      map[string]string{"en": "Test", "de": "Test"},
+				Languages:                        []string{"en"},
+				PopulateLanguagesFromDescription: true,
+			},
+			expectedLanguages: []string{"en", "de"},
+		},
+		{
+			name: "invalid description language",
+			opts: Options{
+				Description: map[string]string{"en_US": "Test"},
+			},
+			expectedErr: `invalid language in Description: "en_US" is not a BCP 47 language tag`,
+		},
+		{
+			name: "invalid language",
+			opts: Options{
+				Languages: []string{"en", ""},
+			},
+			expectedErr: `invalid language in Languages: "" is not a BCP 47 language tag`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tree, err := New(test.opts)
+			if test.expectedErr != "" {
+				assert.EqualError(t, err, test.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expectedLanguages, tree.languages)
+		})
+	}
+}
+
+func TestLoadInconsistentLanguages(t *testing.T) {
+	// Databases written before the validation may have a description in a
+	// language that is not in languages.
+	tree, err := New(Options{
+		IPVersion:   4,
+		Description: map[string]string{"en": "Test"},
+		MetadataHook: func(m mmdbtype.Map) mmdbtype.Map {
+			m["languages"] = mmdbtype.Slice{mmdbtype.String("de")}
+			return m
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, tree.Insert(mustNetwork(t, "1.1.1.0/24"), mmdbtype.String("a")))
+
+	path := filepath.Join(t.TempDir(), "test.mmdb")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	_, err = tree.WriteTo(f)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	loaded, err := Load(path, Options{})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"en": "Test"}, loaded.description)
+	assert.Equal(t, []string{"de"}, loaded.languages)
+
+	// Options that are set are still validated.
+	_, err = Load(path, Options{Languages: []string{"fr"}, Description: map[string]string{"de": "Test"}})
+	assert.EqualError(
+		t,
+		err,
+		`the Description language "de" is not in Languages; `+
+			"add it to Languages or set PopulateLanguagesFromDescription",
+	)
+}
+
+func TestDataSectionAlignment(t *testing.T) {
+	for _, recordSize := range []int{24, 28, 32} {
+		for _, alignment := range []int{16, 4096} {
+			t.Run(fmt.Sprintf("%d-bit records aligned to %d", recordSize, alignment), func(t *testing.T) {
+				tree, err := New(
+					Options{
+						DatabaseType:         "mmdbwriter-test",
+						Description:          map[string]string{"en": "Test database"},
+						DataSectionAlignment: alignment,
+						RecordSize:           recordSize,
+					},
+				)
+				require.NoError(t, err)
+
+				_, network, err := net.ParseCIDR("1.1.1.0/24")
+				require.NoError(t, err)
+				require.NoError(t, tree.Insert(network, mmdbtype.String("value")))
+
+				nodeCount, err := tree.Finalize()
+				require.NoError(t, err)
+
+				offset, err := tree.DataSectionOffset()
+				require.NoError(t, err)
+				assert.Zero(t, offset%int64(alignment))
+
+				buf := &bytes.Buffer{}
+				_, err = tree.WriteTo(buf)
+				require.NoError(t, err)
+
+				db := buf.Bytes()
+				assert.Equal(
+					t,
+					dataSectionSeparator,
+					db[offset-int64(len(dataSectionSeparator)):offset],
+				)
+
+				reader, err := maxminddb.FromBytes(db)
+				require.NoError(t, err)
+				require.NoError(t, reader.Verify())
+				assert.Equal(
+					t,
+					uint(offset)-16,
+					reader.Metadata.NodeCount*reader.Metadata.RecordSize/4,
+				)
+				assert.Equal(t, uint(nodeCount), reader.Metadata.NodeCount)
+
+				var value string
+				require.NoError(t, reader.Lookup(net.ParseIP("1.1.1.1"), &value))
+				assert.Equal(t, "value", value)
+			})
+		}
+	}
+
+	_, err := New(Options{DataSectionAlignment: 24})
+	assert.EqualError(t, err, "DataSectionAlignment must be a power of two: 24")
+}
+
+func TestLimits(t *testing.T) {
+	t.Run("live node count matches finalized count", func(t *testing.T) {
+		tree, err := New(Options{})
+		require.NoError(t, err)
+
+		for _, n := range []string{"1.1.1.0/24", "1.1.0.0/24", "2.0.0.0/8", "1.1.1.128/25"} {
+			_, network, err := net.ParseCIDR(n)
+			require.NoError(t, err)
+			require.NoError(t, tree.Insert(network, mmdbtype.String("a")))
+		}
+		_, network, err := net.ParseCIDR("2.2.0.0/16")
+		require.NoError(t, err)
+		require.NoError(t, tree.InsertFunc(network, inserter.Remove))
+		_, network, err = net.ParseCIDR("1.0.0.0/8")
+		require.NoError(t, err)
+		require.NoError(t, tree.InsertFunc(network, inserter.Remove))
+
+		liveNodes := tree.liveNodes
+		nodeCount, err := tree.Finalize()
+		require.NoError(t, err)
+		assert.Equal(t, liveNodes, nodeCount)
+	})
+
+	t.Run("MaxNodes", func(t *testing.T) {
+		tree, err := New(Options{})
+		require.NoError(t, err)
+		initialNodes := tree.liveNodes
+
+		tree, err = New(Options{MaxNodes: initialNodes + 10})
+		require.NoError(t, err)
+
+		_, network, err := net.ParseCIDR("1.1.1.0/24")
+		require.NoError(t, err)
+		err = tree.Insert(network, mmdbtype.String("a"))
+		require.ErrorIs(t, err, ErrLimitExceeded)
+
+		_, finalizeErr := tree.Finalize()
+		assert.Equal(t, err, finalizeErr)
+
+		assert.EqualError(
+			t,
+			err,
+			fmt.Sprintf(
+				"the tree has %d nodes, which exceeds MaxNodes (%d): limit exceeded",
+				tree.liveNodes,
+				initialNodes+10,
+			),
+		)
+	})
+
+	t.Run("MaxDataSize", func(t *testing.T) {
+		tree, err := New(Options{MaxDataSize: 9})
+		require.NoError(t, err)
+
+		_, network, err := net.ParseCIDR("1.1.1.0/24")
+		require.NoError(t, err)
+		require.NoError(t, tree.Insert(network, mmdbtype.String("abcd")))
+
+		// Replacing the value frees its space.
+		require.NoError(t, tree.Insert(network, mmdbtype.String("efgh")))
+
+		_, network, err = net.ParseCIDR("1.1.2.0/24")
+		require.NoError(t, err)
+		err = tree.Insert(network, mmdbtype.String("ijkl"))
+		require.ErrorIs(t, err, ErrLimitExceeded)
+		assert.EqualError(
+			t,
+			err,
+			"the tree has 10 bytes of data, which exceeds MaxDataSize (9): limit exceeded",
+		)
+
+		_, err = tree.WriteTo(&bytes.Buffer{})
+		assert.ErrorIs(t, err, ErrLimitExceeded)
+	})
+
+	t.Run("MaxRecordSize", func(t *testing.T) {
+		tree, err := New(Options{MaxRecordSize: 5})
+		require.NoError(t, err)
+
+		// A string of 4 bytes is encoded with 5 bytes.
+		_, network, err := net.ParseCIDR("1.1.1.0/24")
+		require.NoError(t, err)
+		require.NoError(t, tree.Insert(network, mmdbtype.String("abcd")))
+
+		_, network, err = net.ParseCIDR("1.1.2.0/24")
+		require.NoError(t, err)
+		err = tree.Insert(network, mmdbtype.String("abcde"))
+		require.ErrorIs(t, err, ErrLimitExceeded)
+		assert.EqualError(
+			t,
+			err,
+			"the record for 1.1.2.0/24 is 6 bytes, which exceeds MaxRecordSize (5): limit exceeded",
+		)
+
+		// The tree is not modified by the rejected insert.
+		_, value := tree.Get(net.ParseIP("1.1.2.1"))
+		assert.Nil(t, value)
+		require.NoError(t, tree.Check())
+		assertRefCounts(t, tree)
+	})
+}
+
+func TestReserveIPv4MappedNetwork(t *testing.T) {
+	tree, err := New(Options{
+		DisableIPv4Aliasing:      true,
+		IncludeReservedNetworks:  true,
+		ReserveIPv4MappedNetwork: true,
+	})
+	require.NoError(t, err)
+
+	insert(t, tree, "::/0", "all")
+
+	mapped := net.ParseIP("::ffff:1.1.1.1")
+	prefixLen, r := tree.root.get(mapped, 0)
+	assert.Equal(t, 96, prefixLen)
+	assert.Equal(t, recordTypeReserved, r.recordType)
+
+	_, value := tree.Get(net.ParseIP("2600::1"))
+	assert.Equal(t, mmdbtype.String("all"), value)
+
+	err = tree.Insert(mustNetwork(t, "::ffff:1.1.1.0/120"), mmdbtype.String("mapped"))
+	require.ErrorContains(t, err, "reserved network")
+	require.NoError(t, tree.Check())
+
+	for _, opts := range []Options{
+		{ReserveIPv4MappedNetwork: true},
+		{IPVersion: 4, DisableIPv4Aliasing: true, ReserveIPv4MappedNetwork: true},
+	} {
+		_, err := New(opts)
+		require.EqualError(
+			t,
+			err,
+			"ReserveIPv4MappedNetwork requires an IPv6 tree with DisableIPv4Aliasing set",
+		)
+	}
+}
+
+func TestMembershipOnly(t *testing.T) {
+	tree, err := New(Options{IPVersion: 4, MembershipOnly: true})
+	require.NoError(t, err)
+
+	insert(t, tree, "1.1.0.0/24", "a")
+	insert(t, tree, "1.1.1.0/24", "b")
+	require.NoError(t, tree.Insert(mustNetwork(t, "2.2.2.0/24"), mmdbtype.Map{"c": mmdbtype.Bool(true)}))
+	require.NoError(t, tree.InsertFunc(mustNetwork(t, "2.2.2.128/25"), inserter.Remove))
+
+	network, value := tree.Get(net.ParseIP("1.1.1.1").To4())
+	assert.Equal(t, "1.1.0.0/23", network.String(), "adjacent networks are aggregated")
+	assert.Equal(t, mmdbtype.Map{}, value)
+
+	_, value = tree.Get(net.ParseIP("2.2.2.200").To4())
+	assert.Nil(t, value)
+
+	assert.Equal(t, 1, len(tree.dataMap.data))
+	assert.Equal(t, 1, tree.dataMap.size, "the data section is a single byte")
+
+	buf := &bytes.Buffer{}
+	_, err = tree.WriteTo(buf)
+	require.NoError(t, err)
+
+	reader, err := maxminddb.FromBytes(buf.Bytes())
+	require.NoError(t, err)
+	var record map[string]any
+	network, ok, err := reader.LookupNetwork(net.ParseIP("2.2.2.1"), &record)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "2.2.2.0/25", network.String())
+	assert.Empty(t, record)
+}
@@ -0,0 +1,235 @@
+package mmdbwriter
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/maxmind/mmdbwriter/inserter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+// ErrTxDone is returned by the methods of a Tx that has already been
+// committed or rolled back.
+var ErrTxDone = errors.New("the transaction has already been committed or rolled back")
+
+// Tx is a batch of inserts and removals that are applied to a Tree
+// atomically. A Tx is created with Tree.Begin. The operations are recorded
+// as they are added and are only applied to the tree by Commit.
+//
+// A Tx is not safe for use from multiple threads.
+type Tx struct {
+	tree *Tree
+	ops  []txOp
+	done bool
+}
+
+// txOp is an operation in a transaction and the networks it modifies.
+type txOp struct {
+	networks []*net.IPNet
+	apply    func() error
+}
+
+// undoRecord is the data for a network before an operation was applied. A
+// nil value means the network had no data.
+type undoRecord struct {
+	ip        net.IP
+	prefixLen int
+	value     mmdbtype.DataType
+}
+
+// Begin starts a transaction on the tree.
+func (t *Tree) Begin() *Tx {
+	return &Tx{tree: t}
+}
+
+// Insert adds Tree.Insert to the transaction.
+func (tx *Tx) Insert(network *net.IPNet, value mmdbtype.DataType) error {
+	return tx.InsertFunc(network, tx.tree.inserterFuncGen(value))
+}
+
+// InsertFunc adds Tree.InsertFunc to the transaction.
+func (tx *Tx) InsertFunc(network *net.IPNet, inserterFunc inserter.Func) error {
+	return tx.add(
+		[]*net.IPNet{network},
+		func() error { return tx.tree.InsertFunc(network, inserterFunc) },
+	)
+}
+
+// InsertRange adds Tree.InsertRange to the transaction.
+func (tx *Tx) InsertRange(start, end net.IP, value mmdbtype.DataType) error {
+	return tx.InsertRangeFunc(start, end, tx.tree.inserterFuncGen(value))
+}
+
+// InsertRangeFunc adds Tree.InsertRangeFunc to the transaction.
+func (tx *Tx) InsertRangeFunc(start, end net.IP, inserterFunc inserter.Func) error {
+	networks, err := rangeNetworks(start, end)
+	if err != nil {
+		return err
+	}
+	return tx.add(
+		networks,
+		func() error { return tx.tree.InsertRangeFunc(start, end, inserterFunc) },
+	)
+}
+
+// Remove adds the removal of the data for the network to the transaction.
+// It is the same as calling Tree.InsertFunc with inserter.Remove.
+func (tx *Tx) Remove(network *net.IPNet) error {
+	return tx.InsertFunc(network, inserter.Remove)
+}
+
+func (tx *Tx) add(networks []*net.IPNet, apply func() error) error {
+	if tx.done {
+		return ErrTxDone
+	}
+	tx.ops = append(tx.ops, txOp{networks: networks, apply: apply})
+	return nil
+}
+
+// Commit applies the operations in the transaction to the tree in the order
+// they were added. If an operation returns an error, the changes made by
+// the transaction are undone and the error is returned, leaving the tree as
+// it was before Commit.
+//
+// Before each operation, Commit records the existing data for the networks
+// it modifies, so the cost of a transaction grows with the number of
+// existing records within the networks. The duplicate tracking and the
+// BuildReport counters are also restored when the changes are undone. If
+// sources, expiries, or priorities are tracked, e.g., with Tree.SetSource,
+// Commit also copies their trackers before applying the operations, which
+// costs time and memory proportional to the number of networks they track,
+// however few networks the transaction modifies.
+func (tx *Tx) Commit() error {
+	if tx.done {
+		return ErrTxDone
+	}
+	tx.done = true
+	if len(tx.ops) == 0 {
+		return nil
+	}
+
+	t := tx.tree
+	trackers := t.cloneTrackers()
+	report := t.report
+	if t.duplicates != nil {
+		t.duplicates.begin()
+	}
+	var undo [][]undoRecord
+	for _, op := range tx.ops {
+		var records []undoRecord
+		for _, network := range op.networks {
+			records = t.appendUndoRecords(records, network)
+		}
+		undo = append(undo, records)
+
+		if err := op.apply(); err != nil {
+			if t.duplicates != nil {
+				t.duplicates.end(true)
+			}
+			if undoErr := t.undo(undo); undoErr != nil {
+				return fmt.Errorf("undoing the transaction after %v: %w", err, undoErr)
+			}
+			t.sources, t.expiries, t.priorities = trackers.sources, trackers.expiries, trackers.priorities
+			t.report = report
+			return err
+		}
+	}
+	if t.duplicates != nil {
+		t.duplicates.end(false)
+	}
+	return nil
+}
+
+// Rollback discards the operations in the transaction without applying
+// them.
+func (tx *Tx) Rollback() error {
+	if tx.done {
+		return ErrTxDone
+	}
+	tx.done = true
+	tx.ops = nil
+	return nil
+}
+
+// appendUndoRecords appends the existing data for the records within the
+// network to records.
+func (t *Tree) appendUndoRecords(records []undoRecord, network *net.IPNet) []undoRecord {
+	ip, prefixLen := t.treeNetwork(network)
+	//nolint:errcheck // walkWithin only returns the errors returned by fn.
+	t.walkWithin(ip, prefixLen, func(recIP net.IP, recPrefixLen int, r record) error {
+		var value mmdbtype.DataType
+		switch r.recordType {
+		case recordTypeData:
+			value = t.dataMap.load(r.value)
+		case recordTypeEmpty:
+		default:
+			return nil
+		}
+		undoIP := make(net.IP, len(recIP))
+		copy(undoIP, recIP)
+		records = append(records, undoRecord{ip: undoIP, prefixLen: recPrefixLen, value: value})
+		return nil
+	})
+	return records
+}
+
+// undo restores the records, undoing the most recent operations first.
+func (t *Tree) undo(undo [][]undoRecord) error {
+	for i := len(undo) - 1; i >= 0; i-- {
+		for _, r := range undo[i] {
+			if err := t.replaceNetwork(r.ip, r.prefixLen, r.value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// trackers holds copies of the tree's insert trackers.
+type trackers struct {
+	sources    *sourceTracker
+	expiries   *expiryTracker
+	priorities *priorityTracker
+}
+
+func (t *Tree) cloneTrackers() trackers {
+	var c trackers
+	if t.sources != nil {
+		c.sources = &sourceTracker{root: *t.sources.root.clone(), current: t.sources.current}
+	}
+	if t.expiries != nil {
+		c.expiries = &expiryTracker{root: *t.expiries.root.clone(), current: t.expiries.current}
+	}
+	if t.priorities != nil {
+		c.priorities = &priorityTracker{root: *t.priorities.root.clone(), current: t.priorities.current}
+	}
+	return c
+}
+
+func (n *sourceNode) clone() *sourceNode {
+	if n == nil {
+		return nil
+	}
+	c := *n
+	c.children = [2]*sourceNode{n.children[0].clone(), n.children[1].clone()}
+	return &c
+}
+
+func (n *expiryNode) clone() *expiryNode {
+	if n == nil {
+		return nil
+	}
+	c := *n
+	c.children = [2]*expiryNode{n.children[0].clone(), n.children[1].clone()}
+	return &c
+}
+
+func (n *priorityNode) clone() *priorityNode {
+	if n == nil {
+		return nil
+	}
+	c := *n
+	c.children = [2]*priorityNode{n.children[0].clone(), n.children[1].clone()}
+	return &c
+}
@@ -0,0 +1,152 @@
+package mmdbwriter
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTx(t *testing.T) {
+	errInsert := errors.New("insert failed")
+
+	tests := []struct {
+		name        string
+		ops         func(t *testing.T, tx *Tx)
+		expectedErr error
+	}{
+		{
+			name: "inserter error",
+			ops: func(t *testing.T, tx *Tx) {
+				require.NoError(t, tx.Insert(mustNetwork(t, "1.1.0.0/16"), mmdbtype.String("c")))
+				require.NoError(t, tx.Remove(mustNetwork(t, "2.2.2.0/24")))
+				require.NoError(t, tx.InsertRange(
+					net.ParseIP("3.0.0.0"), net.ParseIP("3.0.1.127"), mmdbtype.String("d"),
+				))
+				require.NoError(t, tx.InsertFunc(
+					mustNetwork(t, "4.0.0.0/8"),
+					func(mmdbtype.DataType) (mmdbtype.DataType, error) {
+						return nil, errInsert
+					},
+				))
+			},
+			expectedErr: errInsert,
+		},
+		{
+			name: "limit exceeded",
+			ops: func(t *testing.T, tx *Tx) {
+				tx.tree.maxNodes = tx.tree.liveNodes + 10
+				require.NoError(t, tx.Insert(mustNetwork(t, "1.1.1.0/25"), mmdbtype.String("c")))
+				require.NoError(t, tx.Insert(mustNetwork(t, "5.5.5.5/32"), mmdbtype.String("c")))
+			},
+			expectedErr: ErrLimitExceeded,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tree, err := New(Options{TrackSources: true})
+			require.NoError(t, err)
+
+			tree.SetSource("before")
+			insert(t, tree, "1.1.1.0/24", "a")
+			insert(t, tree, "2.2.0.0/16", "b")
+			tree.SetSource("tx")
+
+			liveNodes := tree.liveNodes
+			expected := &bytes.Buffer{}
+			_, err = tree.WriteTo(expected)
+			require.NoError(t, err)
+
+			tx := tree.Begin()
+			test.ops(t, tx)
+			require.ErrorIs(t, tx.Commit(), test.expectedErr)
+
+			actual := &bytes.Buffer{}
+			_, err = tree.WriteTo(actual)
+			require.NoError(t, err)
+			assert.Equal(t, expected.Bytes(), actual.Bytes(), "the tree is unchanged")
+			assert.Equal(t, liveNodes, tree.liveNodes)
+			assertRefCounts(t, tree)
+
+			_, source, ok := tree.Source(net.ParseIP("1.1.1.1"))
+			assert.True(t, ok)
+			assert.Equal(t, "before", source)
+
+			assert.ErrorIs(t, tx.Commit(), ErrTxDone)
+		})
+	}
+}
+
+func TestTxCommitAndRollback(t *testing.T) {
+	tree, err := New(Options{IPVersion: 4})
+	require.NoError(t, err)
+	insert(t, tree, "1.1.1.0/24", "a")
+
+	tx := tree.Begin()
+	require.NoError(t, tx.Insert(mustNetwork(t, "2.2.2.0/24"), mmdbtype.String("b")))
+	require.NoError(t, tx.Remove(mustNetwork(t, "1.1.1.0/24")))
+
+	_, value := tree.Get(net.ParseIP("2.2.2.2").To4())
+	assert.Nil(t, value, "operations are not applied before commit")
+
+	require.NoError(t, tx.Commit())
+	_, value = tree.Get(net.ParseIP("2.2.2.2").To4())
+	assert.Equal(t, mmdbtype.String("b"), value)
+	_, value = tree.Get(net.ParseIP("1.1.1.1").To4())
+	assert.Nil(t, value)
+
+	tx = tree.Begin()
+	require.NoError(t, tx.Insert(mustNetwork(t, "3.3.3.0/24"), mmdbtype.String("c")))
+	require.NoError(t, tx.Rollback())
+	_, value = tree.Get(net.ParseIP("3.3.3.3").To4())
+	assert.Nil(t, value)
+
+	assert.ErrorIs(t, tx.Rollback(), ErrTxDone)
+	assert.ErrorIs(t, tx.Insert(mustNetwork(t, "3.3.3.0/24"), mmdbtype.String("c")), ErrTxDone)
+	assertRefCounts(t, tree)
+}
+
+func TestTxCommitErrorRestoresDuplicatesAndReport(t *testing.T) {
+	tree, err := New(Options{IPVersion: 4, DuplicatePolicy: DuplicateError})
+	require.NoError(t, err)
+	insert(t, tree, "2.2.2.0/24", "a")
+	report := tree.BuildReport()
+
+	network := mustNetwork(t, "1.1.1.0/24")
+	tx := tree.Begin()
+	require.NoError(t, tx.Insert(network, mmdbtype.String("b")))
+	require.NoError(t, tx.InsertFunc(
+		mustNetwork(t, "3.3.3.0/24"),
+		func(mmdbtype.DataType) (mmdbtype.DataType, error) {
+			return nil, errors.New("failed")
+		},
+	))
+	require.EqualError(t, tx.Commit(), "failed")
+
+	tx = tree.Begin()
+	require.NoError(t, tx.Insert(network, mmdbtype.String("b")))
+	require.NoError(t, tx.Insert(mustNetwork(t, "2.2.2.0/24"), mmdbtype.String("c")))
+	require.ErrorIs(t, tx.Commit(), ErrDuplicateNetwork)
+
+	assert.Equal(t, report.Inserts, tree.BuildReport().Inserts)
+	assert.Equal(t, report.Overwrites, tree.BuildReport().Overwrites)
+	assert.Equal(t, report.Merges, tree.BuildReport().Merges)
+	assert.Equal(t, report.RemovedNetworks, tree.BuildReport().RemovedNetworks)
+	assert.Zero(t, tree.DuplicateCount(), "the duplicate in the transaction is not counted")
+
+	require.NoError(t, tree.Insert(network, mmdbtype.String("b")))
+	_, value := tree.Get(net.ParseIP("1.1.1.1").To4())
+	assert.Equal(t, mmdbtype.String("b"), value)
+	assertRefCounts(t, tree)
+}
+
+func mustNetwork(t *testing.T, network string) *net.IPNet {
+	_, ipNet, err := net.ParseCIDR(network)
+	require.NoError(t, err)
+	return ipNet
+}
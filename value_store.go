@@ -0,0 +1,59 @@
+package mmdbwriter
+
+import "fmt"
+
+// ValueStorage selects where the distinct values of the records are stored
+// while the tree is being built.
+type ValueStorage int
+
+const (
+	// ValueStorageMemory stores the values in memory. This is the default.
+	ValueStorageMemory ValueStorage = iota
+
+	// ValueStorageFile stores the values encoded in a memory-mapped
+	// temporary file. Only the keys of the values, which are SHA-256 hashes
+	// of their encodings, are kept in memory, so trees with more data than
+	// fits in memory may be built, as the operating system may page the
+	// values out to the file. Each time a value is needed, e.g., when
+	// inserting into a network with data, for Get, or when writing the
+	// tree, it is decoded from the file, so building is slower and the
+	// values returned are copies. The space of values that are removed
+	// from the tree is not reused.
+	//
+	// This is only supported on Unix-like systems.
+	ValueStorageFile
+)
+
+func (s ValueStorage) String() string {
+	switch s {
+	case ValueStorageMemory:
+		return "ValueStorageMemory"
+	case ValueStorageFile:
+		return "ValueStorageFile"
+	default:
+		return fmt.Sprintf("ValueStorage(%d)", int(s))
+	}
+}
+
+// valueStore holds the encoded values of a dataMap outside of the Go heap.
+type valueStore interface {
+	// put copies the encoded value into the store and returns the stored
+	// copy. The copy remains valid until the store is closed.
+	put(encoded []byte) ([]byte, error)
+	// close releases the resources used by the store. The stored values
+	// must not be used afterward.
+	close() error
+}
+
+// newValueStore returns the store for the storage. It is nil for
+// ValueStorageMemory.
+func newValueStore(storage ValueStorage, dir string) (valueStore, error) {
+	switch storage {
+	case ValueStorageMemory:
+		return nil, nil
+	case ValueStorageFile:
+		return newFileValueStore(dir)
+	default:
+		return nil, fmt.Errorf("unsupported ValueStorage: %d", int(storage))
+	}
+}
@@ -0,0 +1,90 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+
+package mmdbwriter
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// fileValueStoreChunkSize is the minimum number of bytes mapped at a time.
+// The chunks are mapped separately so that stored values never move.
+const fileValueStoreChunkSize = 64 << 20
+
+// fileValueStore appends encoded values to a memory-mapped file.
+type fileValueStore struct {
+	file   *os.File
+	size   int64
+	chunks [][]byte
+	// next is the unused part of the last chunk.
+	next []byte
+}
+
+func newFileValueStore(dir string) (valueStore, error) {
+	f, err := os.CreateTemp(dir, "mmdbwriter-values-")
+	if err != nil {
+		return nil, fmt.Errorf("creating value storage file: %w", err)
+	}
+	// We remove the file immediately so that it is cleaned up even if the
+	// tree is never closed. It remains usable until it is closed.
+	if err := os.Remove(f.Name()); err != nil {
+		f.Close() //nolint:errcheck // the remove error is more relevant
+		return nil, fmt.Errorf("removing value storage file: %w", err)
+	}
+	return &fileValueStore{file: f}, nil
+}
+
+func (s *fileValueStore) put(encoded []byte) ([]byte, error) {
+	if len(s.next) < len(encoded) {
+		if err := s.grow(len(encoded)); err != nil {
+			return nil, err
+		}
+	}
+	stored := s.next[:len(encoded):len(encoded)]
+	copy(stored, encoded)
+	s.next = s.next[len(encoded):]
+	return stored, nil
+}
+
+// grow extends the file and maps another chunk with room for at least
+// minSize bytes.
+func (s *fileValueStore) grow(minSize int) error {
+	length := fileValueStoreChunkSize
+	if minSize > length {
+		pageSize := os.Getpagesize()
+		length = (minSize + pageSize - 1) / pageSize * pageSize
+	}
+	if err := s.file.Truncate(s.size + int64(length)); err != nil {
+		return fmt.Errorf("extending value storage file: %w", err)
+	}
+	b, err := syscall.Mmap(
+		int(s.file.Fd()),
+		s.size,
+		length,
+		syscall.PROT_READ|syscall.PROT_WRITE,
+		syscall.MAP_SHARED,
+	)
+	if err != nil {
+		return fmt.Errorf("mapping value storage file: %w", err)
+	}
+	s.size += int64(length)
+	s.chunks = append(s.chunks, b)
+	s.next = b
+	return nil
+}
+
+func (s *fileValueStore) close() error {
+	var err error
+	for _, b := range s.chunks {
+		if unmapErr := syscall.Munmap(b); unmapErr != nil && err == nil {
+			err = fmt.Errorf("unmapping value storage file: %w", unmapErr)
+		}
+	}
+	s.chunks = nil
+	s.next = nil
+	if closeErr := s.file.Close(); closeErr != nil && err == nil {
+		err = fmt.Errorf("closing value storage file: %w", closeErr)
+	}
+	return err
+}
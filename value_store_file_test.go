@@ -0,0 +1,122 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+
+package mmdbwriter
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/inserter"
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValueStorageFile(t *testing.T) {
+	dir := t.TempDir()
+	records := testNetworkRecords(t, 6)
+
+	build := func(opts Options) []byte {
+		opts.BuildEpoch = 1000
+		tree, err := New(opts)
+		require.NoError(t, err)
+		defer func() {
+			require.NoError(t, tree.Close())
+		}()
+
+		for _, r := range records {
+			require.NoError(t, tree.Insert(r.Network, r.Value))
+		}
+		for _, r := range records[:len(records)/2] {
+			require.NoError(t, tree.InsertFunc(r.Network, inserter.Remove))
+		}
+		// Filling the gaps requires the existing values to be loaded.
+		for _, r := range records[len(records)/4:] {
+			require.NoError(t, tree.InsertFunc(r.Network, inserter.FillGapsWith(r.Value)))
+		}
+		require.NoError(t, tree.Check())
+
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		assert.Empty(t, entries, "the file is removed once created")
+
+		buf := &bytes.Buffer{}
+		_, err = tree.WriteTo(buf)
+		require.NoError(t, err)
+		return buf.Bytes()
+	}
+
+	expected := build(Options{IncludeReservedNetworks: true})
+	actual := build(Options{
+		IncludeReservedNetworks: true,
+		ValueStorage:            ValueStorageFile,
+		ValueStorageDir:         dir,
+	})
+	assert.Equal(t, expected, actual)
+}
+
+func TestValueStorageFileSnapshot(t *testing.T) {
+	tree, err := New(Options{
+		IPVersion:       4,
+		ValueStorage:    ValueStorageFile,
+		ValueStorageDir: t.TempDir(),
+	})
+	require.NoError(t, err)
+	insert(t, tree, "1.1.1.0/24", "a")
+	insert(t, tree, "2.2.2.0/24", "b")
+
+	snapshot := tree.Snapshot()
+	insert(t, tree, "1.1.1.0/24", "c")
+	require.NoError(t, tree.Close())
+
+	// The snapshot does not read the values from the closed store.
+	_, value := snapshot.Get(net.ParseIP("1.1.1.1").To4())
+	assert.Equal(t, mmdbtype.String("a"), value)
+	_, value = snapshot.Get(net.ParseIP("2.2.2.2").To4())
+	assert.Equal(t, mmdbtype.String("b"), value)
+}
+
+func TestValueStorageFileGrow(t *testing.T) {
+	store, err := newFileValueStore(t.TempDir())
+	require.NoError(t, err)
+
+	small, err := store.put([]byte("small"))
+	require.NoError(t, err)
+	large := bytes.Repeat([]byte{1}, fileValueStoreChunkSize+1)
+	storedLarge, err := store.put(large)
+	require.NoError(t, err)
+	assert.Len(t, store.(*fileValueStore).chunks, 2)
+
+	assert.Equal(t, []byte("small"), small)
+	assert.Equal(t, large, storedLarge)
+
+	require.NoError(t, store.close())
+}
+
+func TestValueStorageErrors(t *testing.T) {
+	_, err := New(Options{ValueStorage: ValueStorage(5)})
+	assert.EqualError(t, err, "unsupported ValueStorage: 5")
+
+	_, err = New(Options{
+		ValueStorage:    ValueStorageFile,
+		ValueStorageDir: "/does/not/exist",
+	})
+	assert.ErrorContains(t, err, "creating value storage file: ")
+
+	_, err = New(Options{ValueStorage: ValueStorageFile, Session: NewSession()})
+	assert.EqualError(t, err, "ValueStorageFile cannot be used with Session")
+
+	_, err = NewParallelBuilder(Options{ValueStorage: ValueStorageFile}, ParallelOptions{})
+	assert.EqualError(t, err, "only ValueStorageMemory is supported by ParallelBuilder")
+
+	_, network, err := net.ParseCIDR("1.1.1.0/24")
+	require.NoError(t, err)
+	tree, err := New(Options{ValueStorage: ValueStorageFile})
+	require.NoError(t, err)
+	require.NoError(t, tree.Insert(network, mmdbtype.Map{"a": mmdbtype.String("value")}))
+	_, value := tree.Get(net.ParseIP("1.1.1.1"))
+	assert.Equal(t, mmdbtype.Map{"a": mmdbtype.String("value")}, value)
+	require.NoError(t, tree.Close())
+}
@@ -0,0 +1,9 @@
+//go:build !(aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris)
+
+package mmdbwriter
+
+import "errors"
+
+func newFileValueStore(string) (valueStore, error) {
+	return nil, errors.New("ValueStorageFile is not supported on this platform")
+}
@@ -0,0 +1,289 @@
+package mmdbwriter
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"os"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// VerifyFinding is a problem found in a database by Verify.
+type VerifyFinding struct {
+	// Section is the part of the database with the problem: "metadata",
+	// "search tree", or "data section".
+	Section string
+
+	// Network is the network whose record has the problem. It is nil for
+	// problems that are not about a single record.
+	Network *net.IPNet
+
+	Message string
+}
+
+func (f VerifyFinding) String() string {
+	if f.Network == nil {
+		return f.Section + ": " + f.Message
+	}
+	return fmt.Sprintf("%s: %s: %s", f.Section, f.Network, f.Message)
+}
+
+// VerifyFile verifies the database at path. See Verify.
+func VerifyFile(path string) ([]VerifyFinding, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() //nolint:errcheck // the file is only read
+	return Verify(f)
+}
+
+// Verify validates the database read from r against the MaxMind DB
+// specification, similar to the mmdbverify tool. It checks that:
+//
+//   - the metadata can be found and has the required keys with the types
+//     and values of the specification;
+//   - the database can be opened with maxminddb-golang;
+//   - the data section separator is present;
+//   - every record of the search tree, reached from the root, points to a
+//     node, is empty, or points into the data section; and
+//   - every record in the data section that the search tree points to can
+//     be decoded.
+//
+// The problems are returned as findings rather than an error, so that a
+// pipeline may report all of them. If the metadata is invalid, the search
+// tree and data section are not checked. Nodes reached through more than
+// one record, such as those of the IPv4 aliases, are only checked once.
+// The error is only set if r cannot be read.
+func Verify(r io.ReaderAt) ([]VerifyFinding, error) {
+	buf, err := io.ReadAll(io.NewSectionReader(r, 0, math.MaxInt64))
+	if err != nil {
+		return nil, fmt.Errorf("reading database: %w", err)
+	}
+	v := &verifier{buf: buf}
+	v.verify()
+	return v.findings, nil
+}
+
+type verifier struct {
+	buf      []byte
+	findings []VerifyFinding
+
+	enc       recordEncoder
+	nodeCount int
+	bitCount  int
+	tree      []byte
+	data      []byte
+
+	visited map[int]struct{}
+	decoded map[int]struct{}
+}
+
+func (v *verifier) addf(section string, network *net.IPNet, format string, args ...any) {
+	v.findings = append(v.findings, VerifyFinding{
+		Section: section,
+		Network: network,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (v *verifier) verify() {
+	if !v.verifyMetadata() {
+		return
+	}
+
+	if _, err := maxminddb.FromBytes(v.buf); err != nil {
+		v.addf("metadata", nil, "maxminddb-golang cannot open the database: %v", err)
+	}
+
+	separator := v.buf[len(v.tree) : len(v.tree)+len(dataSectionSeparator)]
+	if !bytes.Equal(separator, dataSectionSeparator) {
+		v.addf("data section", nil, "the data section separator is not %d zero bytes", len(dataSectionSeparator))
+	}
+
+	v.visited = map[int]struct{}{}
+	v.decoded = map[int]struct{}{}
+	v.verifyNode(0, make(net.IP, v.bitCount/8), 0)
+}
+
+// verifyMetadata checks the metadata and sets up the verifier for the
+// search tree and data section. It returns false if they cannot be checked.
+func (v *verifier) verifyMetadata() bool {
+	metadata, err := readMetadata(v.buf)
+	if err != nil {
+		v.addf("metadata", nil, "%v", err)
+		return false
+	}
+	valid := true
+
+	uintValue := func(key mmdbtype.String) (uint64, bool) {
+		value, ok := metadata[key]
+		if !ok {
+			v.addf("metadata", nil, "the required key %q is missing", key)
+			return 0, false
+		}
+		switch value := value.(type) {
+		case mmdbtype.Uint16:
+			return uint64(value), true
+		case mmdbtype.Uint32:
+			return uint64(value), true
+		case mmdbtype.Uint64:
+			return uint64(value), true
+		default:
+			v.addf("metadata", nil, "%q is a %T rather than an unsigned integer", key, value)
+			return 0, false
+		}
+	}
+
+	major, ok := uintValue("binary_format_major_version")
+	switch {
+	case !ok:
+		valid = false
+	case major != 2:
+		v.addf("metadata", nil, "unsupported binary_format_major_version: %d", major)
+		valid = false
+	}
+	uintValue("binary_format_minor_version")
+	uintValue("build_epoch")
+
+	switch dbType := metadata["database_type"].(type) {
+	case nil:
+		v.addf("metadata", nil, "the required key %q is missing", "database_type")
+	case mmdbtype.String:
+		if dbType == "" {
+			v.addf("metadata", nil, "database_type is empty")
+		}
+	default:
+		v.addf("metadata", nil, "%q is a %T rather than a String", "database_type", dbType)
+	}
+
+	ipVersion, ok := uintValue("ip_version")
+	switch {
+	case !ok:
+		valid = false
+	case ipVersion == 4:
+		v.bitCount = 32
+	case ipVersion == 6:
+		v.bitCount = 128
+	default:
+		v.addf("metadata", nil, "unsupported ip_version: %d", ipVersion)
+		valid = false
+	}
+
+	if recordSize, ok := uintValue("record_size"); ok {
+		enc, err := newRecordEncoder(int(recordSize))
+		if err != nil {
+			v.addf("metadata", nil, "%v", err)
+			valid = false
+		}
+		v.enc = enc
+	} else {
+		valid = false
+	}
+
+	nodeCount, ok := uintValue("node_count")
+	if !ok {
+		valid = false
+	}
+
+	if languages, ok := metadata["languages"]; ok {
+		s, isSlice := languages.(mmdbtype.Slice)
+		if !isSlice {
+			v.addf("metadata", nil, "%q is a %T rather than a Slice", "languages", languages)
+		}
+		for _, lang := range s {
+			if _, ok := lang.(mmdbtype.String); !ok {
+				v.addf("metadata", nil, "languages contains a %T rather than a String", lang)
+			}
+		}
+	}
+	if description, ok := metadata["description"]; ok {
+		m, isMap := description.(mmdbtype.Map)
+		if !isMap {
+			v.addf("metadata", nil, "%q is a %T rather than a Map", "description", description)
+		}
+		for lang, desc := range m {
+			if _, ok := desc.(mmdbtype.String); !ok {
+				v.addf("metadata", nil, "the description for %q is a %T rather than a String", lang, desc)
+			}
+		}
+	}
+
+	if !valid {
+		return false
+	}
+
+	metadataStart := bytes.LastIndex(v.buf, metadataStartMarker)
+	treeSize := nodeCount * uint64(v.enc.nodeSize())
+	if nodeCount == 0 || treeSize+uint64(len(dataSectionSeparator)) > uint64(metadataStart) {
+		v.addf(
+			"metadata",
+			nil,
+			"the node_count of %d does not fit in the %d bytes before the metadata",
+			nodeCount,
+			metadataStart,
+		)
+		return false
+	}
+	v.nodeCount = int(nodeCount)
+	v.tree = v.buf[:treeSize]
+	v.data = v.buf[int(treeSize)+len(dataSectionSeparator) : metadataStart]
+	return true
+}
+
+func (v *verifier) verifyNode(node int, ip net.IP, depth int) {
+	if _, ok := v.visited[node]; ok {
+		return
+	}
+	v.visited[node] = struct{}{}
+
+	nodeSize := v.enc.nodeSize()
+	left, right := v.enc.decode(v.tree[node*nodeSize : (node+1)*nodeSize])
+
+	v.verifyRecord(left, ip, depth+1)
+
+	rightIP := make(net.IP, len(ip))
+	copy(rightIP, ip)
+	setBitAt(rightIP, depth)
+	v.verifyRecord(right, rightIP, depth+1)
+}
+
+func (v *verifier) verifyRecord(r int, ip net.IP, prefixLen int) {
+	network := func() *net.IPNet {
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(prefixLen, v.bitCount)}
+	}
+
+	switch {
+	case r < v.nodeCount:
+		if prefixLen >= v.bitCount {
+			v.addf("search tree", network(), "the record points to node %d below the maximum depth", r)
+			return
+		}
+		v.verifyNode(r, ip, prefixLen)
+	case r == v.nodeCount:
+		// An empty record.
+	default:
+		offset := r - v.nodeCount - len(dataSectionSeparator)
+		if offset < 0 || offset >= len(v.data) {
+			v.addf(
+				"search tree",
+				network(),
+				"the record points to offset %d, which is outside of the %d byte data section",
+				offset,
+				len(v.data),
+			)
+			return
+		}
+		if _, ok := v.decoded[offset]; ok {
+			return
+		}
+		v.decoded[offset] = struct{}{}
+		if _, _, err := mmdbtype.DecodeAt(v.data, offset); err != nil {
+			v.addf("data section", network(), "decoding the record at offset %d: %v", offset, err)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package mmdbwriter
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/oschwald/maxminddb-golang"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeVerifyTestDB(t *testing.T, opts Options) []byte {
+	tree, err := New(opts)
+	require.NoError(t, err)
+	for i, network := range []string{"1.1.1.0/24", "2.0.0.0/8", "1.1.2.0/23"} {
+		require.NoError(t, tree.Insert(mustNetwork(t, network), mmdbtype.Map{
+			"n": mmdbtype.Uint32(i),
+			"s": mmdbtype.String("shared"),
+		}))
+	}
+	buf := &bytes.Buffer{}
+	_, err = tree.WriteTo(buf)
+	require.NoError(t, err)
+	return buf.Bytes()
+}
+
+func TestVerify(t *testing.T) {
+	for _, ipVersion := range []int{4, 6} {
+		for _, recordSize := range []int{24, 28, 32} {
+			t.Run(fmt.Sprintf("IPv%d %d bits", ipVersion, recordSize), func(t *testing.T) {
+				db := writeVerifyTestDB(t, Options{
+					DatabaseType: "Test",
+					IPVersion:    ipVersion,
+					RecordSize:   recordSize,
+				})
+
+				findings, err := Verify(bytes.NewReader(db))
+				require.NoError(t, err)
+				assert.Empty(t, findings)
+
+				reader, err := maxminddb.FromBytes(db)
+				require.NoError(t, err)
+				var record map[string]any
+				require.NoError(t, reader.Lookup(net.ParseIP("1.1.2.1"), &record))
+				assert.Equal(t, map[string]any{"n": uint64(2), "s": "shared"}, record)
+			})
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "test.mmdb")
+	require.NoError(t, os.WriteFile(path, writeVerifyTestDB(t, Options{DatabaseType: "Test"}), 0o600))
+	findings, err := VerifyFile(path)
+	require.NoError(t, err)
+	assert.Empty(t, findings)
+
+	_, err = VerifyFile(filepath.Join(t.TempDir(), "missing.mmdb"))
+	assert.Error(t, err)
+}
+
+func TestVerifyFindings(t *testing.T) {
+	valid := writeVerifyTestDB(t, Options{DatabaseType: "Test", IPVersion: 4, RecordSize: 24})
+	reader, err := maxminddb.FromBytes(valid)
+	require.NoError(t, err)
+	nodeCount := int(reader.Metadata.NodeCount)
+	treeSize := nodeCount * 6
+	metadataStart := bytes.LastIndex(valid, metadataStartMarker)
+	dataSize := metadataStart - treeSize - 16
+
+	corrupt := func(fn func(db []byte)) []byte {
+		db := append([]byte(nil), valid...)
+		fn(db)
+		return db
+	}
+	encodedMap, err := mmdbtype.Encode(mmdbtype.Map{})
+	require.NoError(t, err)
+	emptyMetadata := append(append([]byte(nil), metadataStartMarker...), encodedMap...)
+
+	tests := []struct {
+		name string
+		db   []byte
+		// findings are the expected findings. If nil, only findings for
+		// records in the data section are expected.
+		findings []string
+	}{
+		{
+			name:     "no metadata",
+			db:       []byte("not a database"),
+			findings: []string{"metadata: invalid MaxMind DB: metadata start marker not found"},
+		},
+		{
+			name: "missing metadata keys",
+			db:   emptyMetadata,
+			findings: []string{
+				`metadata: the required key "binary_format_major_version" is missing`,
+				`metadata: the required key "binary_format_minor_version" is missing`,
+				`metadata: the required key "build_epoch" is missing`,
+				`metadata: the required key "database_type" is missing`,
+				`metadata: the required key "ip_version" is missing`,
+				`metadata: the required key "record_size" is missing`,
+				`metadata: the required key "node_count" is missing`,
+			},
+		},
+		{
+			name: "separator",
+			db: corrupt(func(db []byte) {
+				db[treeSize+3] = 1
+			}),
+			findings: []string{"data section: the data section separator is not 16 zero bytes"},
+		},
+		{
+			name: "records outside of the data section",
+			db: corrupt(func(db []byte) {
+				for i := 0; i < 6; i++ {
+					db[i] = 0xFF
+				}
+			}),
+			findings: []string{
+				fmt.Sprintf(
+					"search tree: 0.0.0.0/1: the record points to offset %d, which is outside of the %d byte data section",
+					1<<24-1-nodeCount-16,
+					dataSize,
+				),
+				fmt.Sprintf(
+					"search tree: 128.0.0.0/1: the record points to offset %d, which is outside of the %d byte data section",
+					1<<24-1-nodeCount-16,
+					dataSize,
+				),
+			},
+		},
+		{
+			name: "undecodable data",
+			db: corrupt(func(db []byte) {
+				for i := treeSize + 16; i < metadataStart; i++ {
+					db[i] = 0xFF
+				}
+			}),
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			findings, err := Verify(bytes.NewReader(test.db))
+			require.NoError(t, err)
+			require.NotEmpty(t, findings)
+			if test.findings == nil {
+				for _, f := range findings {
+					assert.Equal(t, "data section", f.Section)
+					assert.NotNil(t, f.Network)
+				}
+				return
+			}
+			actual := make([]string, len(findings))
+			for i, f := range findings {
+				actual[i] = f.String()
+			}
+			assert.Equal(t, test.findings, actual)
+		})
+	}
+}
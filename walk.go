@@ -0,0 +1,85 @@
+package mmdbwriter
+
+import (
+	"errors"
+	"net"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+)
+
+// ErrStopWalk may be returned by a WalkFunc to stop Walk without an error
+// being returned by Walk.
+var ErrStopWalk = errors.New("stop walk")
+
+// WalkFunc is called by Walk for each network in the tree. The value is nil
+// for a network that is split into more specific networks, i.e., a node in
+// the search tree. For such a network, returning false skips the more
+// specific networks. The return value is ignored for networks with data.
+//
+// Returning an error stops the walk.
+type WalkFunc func(network *net.IPNet, value mmdbtype.DataType) (descend bool, err error)
+
+// Walk calls fn for each network in the tree in depth-first order, visiting
+// a network before the networks within it. Networks without data are
+// skipped, as are aliased networks, e.g., ::ffff:0:0/96, as the IPv4
+// networks they point to are visited through the IPv4 subtree. Networks in
+// the IPv4 subtree of an IPv6 tree are passed to fn as IPv4 networks.
+//
+// If fn returns an error, Walk stops and returns it, unless the error is
+// ErrStopWalk, in which case Walk returns nil.
+//
+// The tree must not be modified during the walk.
+func (t *Tree) Walk(fn WalkFunc) error {
+	ip := make(net.IP, t.treeDepth/8)
+	err := t.walkNode(t.root, ip, 0, fn)
+	if errors.Is(err, ErrStopWalk) {
+		return nil
+	}
+	return err
+}
+
+func (t *Tree) walkNode(n *node, ip net.IP, depth int, fn WalkFunc) error {
+	for i := 0; i < 2; i++ {
+		if i == 1 {
+			setBitAt(ip, depth)
+		}
+		err := t.walkRecord(n.children[i], ip, depth+1, fn)
+		if i == 1 {
+			clearBitAt(ip, depth)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *Tree) walkRecord(r record, ip net.IP, prefixLen int, fn WalkFunc) error {
+	switch r.recordType {
+	case recordTypeNode, recordTypeFixedNode:
+		descend, err := fn(t.network(ip, prefixLen), nil)
+		if err != nil || !descend {
+			return err
+		}
+		return t.walkNode(r.node, ip, prefixLen, fn)
+	case recordTypeData:
+		_, err := fn(t.network(ip, prefixLen), t.dataMap.load(r.value))
+		return err
+	default:
+		return nil
+	}
+}
+
+// network returns a new IPNet for the network in tree form. Networks in
+// the IPv4 subtree of an IPv6 tree are returned as IPv4 networks.
+func (t *Tree) network(ip net.IP, prefixLen int) *net.IPNet {
+	if t.inIPv4Subtree(ip, prefixLen) {
+		ipv4 := make(net.IP, net.IPv4len)
+		copy(ipv4, ip[12:])
+		return &net.IPNet{IP: ipv4, Mask: net.CIDRMask(prefixLen-96, 32)}
+	}
+	return &net.IPNet{
+		IP:   ip.Mask(net.CIDRMask(prefixLen, t.treeDepth)),
+		Mask: net.CIDRMask(prefixLen, t.treeDepth),
+	}
+}
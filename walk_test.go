@@ -0,0 +1,111 @@
+package mmdbwriter
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/maxmind/mmdbwriter/mmdbtype"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWalk(t *testing.T) {
+	tree, err := New(Options{IPVersion: 4, IncludeReservedNetworks: true})
+	require.NoError(t, err)
+
+	insert(t, tree, "0.0.0.0/1", "a")
+	insert(t, tree, "128.0.0.0/2", "b")
+	insert(t, tree, "192.0.0.0/3", "c")
+	insert(t, tree, "224.0.0.0/4", "d")
+
+	type visit struct {
+		network string
+		value   mmdbtype.DataType
+	}
+
+	tests := []struct {
+		name     string
+		fn       func(network *net.IPNet, value mmdbtype.DataType) (bool, error)
+		expected []visit
+	}{
+		{
+			name: "all",
+			fn: func(*net.IPNet, mmdbtype.DataType) (bool, error) {
+				return true, nil
+			},
+			expected: []visit{
+				{network: "0.0.0.0/1", value: mmdbtype.String("a")},
+				{network: "128.0.0.0/1"},
+				{network: "128.0.0.0/2", value: mmdbtype.String("b")},
+				{network: "192.0.0.0/2"},
+				{network: "192.0.0.0/3", value: mmdbtype.String("c")},
+				{network: "224.0.0.0/3"},
+				{network: "224.0.0.0/4", value: mmdbtype.String("d")},
+			},
+		},
+		{
+			name: "no descent below /2",
+			fn: func(network *net.IPNet, _ mmdbtype.DataType) (bool, error) {
+				ones, _ := network.Mask.Size()
+				return ones < 2, nil
+			},
+			expected: []visit{
+				{network: "0.0.0.0/1", value: mmdbtype.String("a")},
+				{network: "128.0.0.0/1"},
+				{network: "128.0.0.0/2", value: mmdbtype.String("b")},
+				{network: "192.0.0.0/2"},
+			},
+		},
+		{
+			name: "stop",
+			fn: func(_ *net.IPNet, value mmdbtype.DataType) (bool, error) {
+				if value == mmdbtype.String("b") {
+					return false, ErrStopWalk
+				}
+				return true, nil
+			},
+			expected: []visit{
+				{network: "0.0.0.0/1", value: mmdbtype.String("a")},
+				{network: "128.0.0.0/1"},
+				{network: "128.0.0.0/2", value: mmdbtype.String("b")},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var visits []visit
+			err := tree.Walk(func(network *net.IPNet, value mmdbtype.DataType) (bool, error) {
+				visits = append(visits, visit{network: network.String(), value: value})
+				return test.fn(network, value)
+			})
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, visits)
+		})
+	}
+
+	errWalk := errors.New("walk error")
+	err = tree.Walk(func(*net.IPNet, mmdbtype.DataType) (bool, error) {
+		return false, errWalk
+	})
+	assert.ErrorIs(t, err, errWalk)
+}
+
+func TestWalkIPv6(t *testing.T) {
+	tree, err := New(Options{})
+	require.NoError(t, err)
+
+	insert(t, tree, "1.1.1.0/24", "a")
+	insert(t, tree, "2600::/32", "b")
+
+	var networks []string
+	err = tree.Walk(func(network *net.IPNet, value mmdbtype.DataType) (bool, error) {
+		if value != nil {
+			networks = append(networks, network.String())
+		}
+		return true, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1.1.1.0/24", "2600::/32"}, networks)
+}